@@ -2,40 +2,505 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
+	// Embed the time zone database for X-Timezone and user time zones; the
+	// runtime image does not ship one
+	_ "time/tzdata"
 
+	"github.com/redis/go-redis/v9"
+
+	"go-backend/internal/attachment"
 	"go-backend/internal/cache"
+	"go-backend/internal/config"
+	"go-backend/internal/digest"
+	"go-backend/internal/events"
 	"go-backend/internal/handler"
+	"go-backend/internal/hashid"
+	"go-backend/internal/mail"
+	"go-backend/internal/middleware"
+	"go-backend/internal/report"
 	"go-backend/internal/store"
+	"go-backend/internal/unfurl"
+	"go-backend/internal/validator"
+	"go-backend/internal/webhook"
 )
 
 const (
-	defaultPort = "8080"
-	version     = "1.0.0"
+	shutdownTimeout = 10 * time.Second
+	version         = "1.0.0"
 )
 
 func main() {
 	startTime := time.Now()
 
-	// Initialize data store from persistence
-	dataStore := store.Initialize()
+	migrateTo := flag.Int("migrate-to", -1, "migrate the data file or database to this schema version and exit")
+	loader := config.NewLoader(flag.CommandLine, os.Getenv)
+	flag.Parse()
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if *migrateTo >= 0 {
+		migrate(cfg.Store, *migrateTo)
+		return
+	}
+
+	// Roles users may have, e.g. ALLOWED_ROLES=developer,designer,qa
+	if len(cfg.API.Roles) > 0 {
+		validator.SetRoles(cfg.API.Roles)
+	}
+
+	// Initialize data store
+	var dataStore store.Backend
+	if cfg.Store.Backend == config.StorePostgres {
+		dataStore = newPostgresStore(cfg.Store)
+	} else {
+		dataStore = newFileStore(cfg.Store)
+	}
+
+	// Count store operations for /metrics and /api/stats/store
+	storeMetrics := store.NewMetrics()
+	dataStore = store.Instrument(dataStore, storeMetrics)
 
-	// Initialize cache with 5 minute TTL
-	appCache := cache.New(5 * time.Minute)
+	appCache := newCache(cfg)
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	rateLimiter := newRateLimiter(cfg)
+	authRateLimiter := newAuthRateLimiter(cfg)
+	digestJob := digest.New(dataStore, newMailer(cfg.SMTP), cfg.Digest.Period)
+	attachments := newAttachments(cfg)
+	webhooks := webhook.New(dataStore, webhook.Options{})
+
+	// Applies changes to the configuration on SIGHUP and
+	// POST /api/admin/reload
+	reload := &reloader{
+		loader:          loader,
+		cache:           appCache,
+		rateLimiter:     rateLimiter,
+		authRateLimiter: authRateLimiter,
+		running:         cfg,
 	}
 
 	// Create handler with dependencies
 	h := handler.New(dataStore, appCache, handler.Config{
+		Version:              version,
+		StartTime:            startTime,
+		DebugHeaders:         cfg.API.DebugHeaders,
+		RateLimiter:          rateLimiter,
+		AuthRateLimiter:      authRateLimiter,
+		VersionPins:          versionPins(cfg.API.VersionPins),
+		FieldNamingPins:      cfg.API.FieldNamingPins,
+		IDCodec:              newIDCodec(cfg.API.IDObfuscationKey),
+		WarmCache:            cfg.Cache.Warm,
+		MaxInFlight:          cfg.Server.MaxInFlight,
+		OperationRetention:   cfg.API.OperationRetention,
+		Server:               serverConfig(cfg),
+		TLS:                  tlsConfig(cfg),
+		Digest:               digestJob,
+		CapacityWeights:      capacityWeights(cfg.Reports),
+		Attachments:          attachments,
+		ReportLimits:         reportLimits(cfg.Reports),
+		ReportRefresh:        cfg.Jobs.ReportRefreshInterval,
+		StatsRefresh:         cfg.Jobs.StatsRefreshInterval,
+		StatsHistoryInterval: cfg.Jobs.StatsHistoryInterval,
+		URLSigner:            newURLSigner(cfg.Files.SigningKey),
+		DownloadBaseURL:      cfg.Files.DownloadBaseURL,
+		Unfurler:             newUnfurler(cfg.API.UnfurlLinks),
+		Webhooks:             webhooks,
+		EventHeartbeat:       cfg.API.EventHeartbeat,
+		ConsistencyInterval:  cfg.Jobs.ConsistencyCheckInterval,
+		ResetKey:             resetKey(cfg.Reset),
+		Settings:             reload.Settings,
+		Reload:               reload.Reload,
+		StoreMetrics:         storeMetrics,
+	})
+	reload.handlers = append(reload.handlers, h)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Send digest emails on a schedule; enable this on one replica only
+	if cfg.Digest.Enabled {
+		go digestJob.Schedule(ctx)
+	}
+
+	// Keep materialized reports fresh
+	go h.ScheduleReports(ctx)
+	go h.ScheduleStats(ctx)
+
+	// Record stats over time for /api/stats/history
+	go h.ScheduleStatsHistory(ctx)
+
+	// Watch caches, stats and the data file for drift from the store
+	go h.ScheduleConsistencyChecks(ctx)
+
+	// Optionally start an isolated sandbox server for client development
+	var sandbox *handler.Handler
+	if cfg.Sandbox.Port != "" {
+		sandbox = startSandbox(ctx, cfg, startTime)
+	}
+
+	// Apply changes to the configuration file on SIGHUP
+	if sandbox != nil {
+		reload.handlers = append(reload.handlers, sandbox)
+	}
+	go reloadOnHangup(ctx, reload)
+
+	// Start the server
+	go h.Start(cfg.Server.Port)
+
+	<-ctx.Done()
+	log.Printf("Shutting down...")
+
+	// Both servers drain at the same time, then have shutdownTimeout to
+	// finish their requests
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.DrainDelay+shutdownTimeout)
+	defer cancel()
+
+	var shutdown sync.WaitGroup
+	if sandbox != nil {
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			if err := sandbox.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Warning: Sandbox shutdown failed: %v", err)
+			}
+		}()
+	}
+	if err := h.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: Server shutdown failed: %v", err)
+	}
+	shutdown.Wait()
+
+	if rateLimiter != nil {
+		rateLimiter.Close()
+	}
+	if authRateLimiter != nil {
+		authRateLimiter.Close()
+	}
+	webhooks.Close()
+	attachments.Close()
+	appCache.Close()
+	dataStore.Close()
+
+	log.Printf("Server stopped")
+}
+
+// migrate migrates the configured store to schema version to, e.g. before
+// rolling back to a release that expects an older schema. Stores are
+// migrated up to the latest version automatically at startup.
+func migrate(cfg config.StoreConfig, to int) {
+	if cfg.Backend == config.StorePostgres {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		from, err := store.MigratePostgres(ctx, cfg.DatabaseURL, to)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Migrated database from schema version %d to %d", from, to)
+		return
+	}
+
+	from, err := store.MigrateDataFile(cfg.DataFile, to)
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	log.Printf("Migrated %s from schema version %d to %d", cfg.DataFile, from, to)
+}
+
+// newFileStore loads the in-memory store from the configured JSON data
+// file.
+func newFileStore(cfg config.StoreConfig) *store.Store {
+	log.Printf("Data file: %s", cfg.DataFile)
+	dataStore := store.InitializeFrom(cfg.DataFile)
+	dataStore.SetPersistDelay(cfg.PersistDelay)
+	dataStore.SetCompactAfter(cfg.CompactAfter)
+	return dataStore
+}
+
+// newPostgresStore connects to the configured PostgreSQL database. The
+// server does not start without it.
+func newPostgresStore(cfg config.StoreConfig) *store.PostgresStore {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pgStore, err := store.NewPostgres(ctx, store.PostgresConfig{
+		URL:             cfg.DatabaseURL,
+		MaxConns:        int32(cfg.MaxConns),
+		MinConns:        int32(cfg.MinConns),
+		MaxConnLifetime: cfg.MaxConnLifetime,
+		MaxConnIdleTime: cfg.MaxConnIdleTime,
+		QueryTimeout:    cfg.QueryTimeout,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open PostgreSQL store: %v", err)
+	}
+	log.Printf("Store backend: postgres")
+	return pgStore
+}
+
+// resetKey returns the key of POST /api/admin/reset, or "" to leave the
+// endpoint out when it is not enabled.
+func resetKey(cfg config.ResetConfig) string {
+	if !cfg.Enabled {
+		return ""
+	}
+	log.Printf("Warning: POST /api/admin/reset is enabled and replaces all data")
+	return cfg.Key
+}
+
+// serverConfig returns the HTTP server configuration: its limits, who may
+// call it and which requests are logged. Authentication is on only when
+// enabled.
+func serverConfig(cfg *config.Config) handler.ServerConfig {
+	server := handler.ServerConfig{
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		RequestTimeout:    cfg.Server.RequestTimeout,
+		MaxBodyBytes:      cfg.Server.MaxBodyBytes,
+		MaxUploadBytes:    cfg.Server.MaxUploadBytes,
+		CompressMinBytes:  cfg.Server.CompressMinBytes,
+		CORSOrigins:       cfg.Server.CORSOrigins,
+		LogLevel:          cfg.Server.LogLevel,
+		DrainDelay:        cfg.Server.DrainDelay,
+		AdminKeys:         cfg.Auth.AdminKeys,
+	}
+	if cfg.Auth.Enabled {
+		server.APIKeys = cfg.Auth.APIKeys
+	}
+	return server
+}
+
+// tlsConfig returns how the main server serves HTTPS. Obtained
+// certificates are kept next to the data file unless a directory is set.
+func tlsConfig(cfg *config.Config) handler.TLSConfig {
+	cacheDir := cfg.TLS.CacheDir
+	if cacheDir == "" && len(cfg.TLS.Domains) > 0 {
+		cacheDir = filepath.Join(filepath.Dir(cfg.Store.DataFile), "certs")
+	}
+	return handler.TLSConfig{
+		CertFile:     cfg.TLS.CertFile,
+		KeyFile:      cfg.TLS.KeyFile,
+		Domains:      cfg.TLS.Domains,
+		CacheDir:     cacheDir,
+		Email:        cfg.TLS.Email,
+		RedirectPort: cfg.TLS.RedirectPort,
+	}
+}
+
+// startSandbox starts a server on its own port backed by an in-memory store
+// that is reset to sample data periodically until ctx is done.
+func startSandbox(ctx context.Context, cfg *config.Config, startTime time.Time) *handler.Handler {
+	sandboxStore := store.NewSandbox()
+	sandboxCache := cache.New(cfg.Cache.TTL)
+	sandboxEvents := events.New(events.Options{})
+
+	sandbox := handler.New(sandboxStore, sandboxCache, handler.Config{
 		Version:   version,
 		StartTime: startTime,
+		Sandbox:   true,
+		Server:    serverConfig(cfg),
+		Events:    sandboxEvents,
 	})
+	go sandbox.ScheduleReports(ctx)
 
-	// Start the server
-	h.Start(port)
+	go func() {
+		ticker := time.NewTicker(cfg.Sandbox.ResetInterval)
+		defer ticker.Stop()
+		defer sandboxCache.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sandboxStore.ResetToSampleData()
+				sandboxCache.InvalidateAll()
+				sandboxEvents.Publish(events.Reset, nil)
+				log.Printf("Sandbox data reset")
+			}
+		}
+	}()
+
+	go sandbox.Start(cfg.Sandbox.Port)
+
+	return sandbox
+}
+
+// newMailer returns an SMTP mailer for the configured relay, or one that
+// logs mail instead of sending it when no relay is set.
+func newMailer(cfg config.SMTPConfig) mail.Mailer {
+	if cfg.Addr == "" {
+		log.Printf("Mailer: log (SMTP_ADDR not set)")
+		return mail.LogMailer{}
+	}
+	log.Printf("Mailer: smtp (%s)", cfg.Addr)
+	return mail.NewSMTP(mail.SMTPConfig{
+		Addr:     cfg.Addr,
+		From:     cfg.From,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	})
+}
+
+// capacityWeights returns the capacity report weights. Unset weights use
+// the defaults.
+func capacityWeights(cfg config.ReportsConfig) report.CapacityWeights {
+	// Validated with the rest of the configuration
+	weights, _ := report.ParseCapacityWeights(cfg.CapacityWeights)
+	return weights
+}
+
+// newURLSigner returns the signer for attachment download URLs. Without a
+// key, URLs only work on this replica until it restarts.
+func newURLSigner(key string) *attachment.Signer {
+	if key == "" {
+		log.Printf("Warning: DOWNLOAD_SIGNING_KEY not set; signed download URLs will not survive a restart")
+	}
+	return attachment.NewSigner([]byte(key))
+}
+
+// newIDCodec returns the codec for the IDs clients see, or nil to show
+// plain IDs when no key is set.
+func newIDCodec(key string) middleware.IDCodec {
+	if key == "" {
+		return nil
+	}
+	return hashid.New(key)
+}
+
+// newUnfurler returns the link unfurler when enabled, or nil. Unfurling
+// fetches arbitrary URLs from task descriptions, so it is off unless asked
+// for.
+func newUnfurler(enabled bool) *unfurl.Unfurler {
+	if !enabled {
+		return nil
+	}
+	return unfurl.New(nil)
+}
+
+// newAttachments returns the attachment manager, keeping files in the
+// configured directory or an attachments directory next to the data file.
+// Files are scanned by clamd or, failing that, the scan API; with neither
+// they are not scanned.
+func newAttachments(cfg *config.Config) *attachment.Manager {
+	dir := cfg.Files.Dir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(cfg.Store.DataFile), "attachments")
+	}
+	storage, err := attachment.NewDisk(dir)
+	if err != nil {
+		log.Fatalf("Failed to open attachment storage: %v", err)
+	}
+
+	var scanner attachment.Scanner
+	switch {
+	case cfg.Files.ClamAVAddr != "":
+		scanner = attachment.ClamAV{Addr: cfg.Files.ClamAVAddr}
+		log.Printf("Attachment scanner: clamd (%s)", cfg.Files.ClamAVAddr)
+	case cfg.Files.ScanURL != "":
+		scanner = attachment.HTTPScanner{URL: cfg.Files.ScanURL}
+		log.Printf("Attachment scanner: %s", cfg.Files.ScanURL)
+	default:
+		log.Printf("Attachment scanner: none (CLAMAV_ADDR and SCAN_URL not set)")
+	}
+
+	manager, err := attachment.NewManager(context.Background(), storage, scanner)
+	if err != nil {
+		log.Fatalf("Failed to load attachments: %v", err)
+	}
+	return manager
+}
+
+// reportLimits returns the custom report cost limits.
+func reportLimits(cfg config.ReportsConfig) report.Limits {
+	return report.Limits{
+		MaxRows:        cfg.MaxRows,
+		MaxEvaluations: cfg.MaxEvaluations,
+		MaxGroups:      cfg.MaxGroups,
+	}
+}
+
+// newCache builds the response cache. The Redis backend shares the cache
+// across replicas; otherwise an in-memory LRU cache is used.
+func newCache(cfg *config.Config) cache.Cache {
+	if cfg.Cache.Backend == config.CacheRedis {
+		client := newRedisClient(cfg.Redis)
+		log.Printf("Cache backend: redis at %s", client.Options().Addr)
+		return cache.NewRedis(client, cfg.Cache.TTL)
+	}
+	return cache.NewWithLimit(cfg.Cache.TTL, cfg.Cache.MaxEntries)
+}
+
+// newRedisClient creates a Redis client, or returns nil when no Redis
+// address is configured.
+func newRedisClient(cfg config.RedisConfig) *redis.Client {
+	if cfg.Addr == "" {
+		return nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+// newRateLimiter builds the per-IP rate limiter, or returns nil when rate
+// limiting is off. With Redis configured, the limit is shared across
+// replicas.
+func newRateLimiter(cfg *config.Config) middleware.Limiter {
+	limit := cfg.RateLimit.Requests
+	if limit <= 0 {
+		return nil
+	}
+
+	client := newRedisClient(cfg.Redis)
+	if client == nil {
+		maxClients := cfg.RateLimit.MaxClients
+		log.Printf("Rate limiting enabled: %d req/min per IP (in-memory, max %d clients)", limit, maxClients)
+		return middleware.NewBoundedRateLimiter(limit, time.Minute, maxClients)
+	}
+
+	log.Printf("Rate limiting enabled: %d req/min per IP (redis at %s)", limit, client.Options().Addr)
+	return middleware.NewRedisRateLimiter(client, limit, time.Minute)
+}
+
+// newAuthRateLimiter builds the limiter for password attempts, applied per IP
+// and per target account, or returns nil when it is off.
+func newAuthRateLimiter(cfg *config.Config) middleware.Limiter {
+	limit, window := cfg.RateLimit.AuthAttempts, cfg.RateLimit.AuthWindow
+	if limit <= 0 {
+		return nil
+	}
+
+	client := newRedisClient(cfg.Redis)
+	if client == nil {
+		log.Printf("Auth rate limiting enabled: %d attempts per %v per IP and account (in-memory)", limit, window)
+		return middleware.NewBoundedRateLimiter(limit, window, cfg.RateLimit.MaxClients)
+	}
+
+	log.Printf("Auth rate limiting enabled: %d attempts per %v per IP and account (redis at %s)", limit, window, client.Options().Addr)
+	return middleware.NewRedisRateLimiter(client, limit, window)
+}
+
+// versionPins returns the API versions pinned by API key. The server does
+// not start if one is not supported.
+func versionPins(pins map[string]string) map[string]string {
+	if err := handler.ValidateVersionPins(pins); err != nil {
+		log.Fatalf("Invalid API_VERSION_PINS: %v", err)
+	}
+	return pins
 }
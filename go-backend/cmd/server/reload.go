@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go-backend/internal/cache"
+	"go-backend/internal/config"
+	"go-backend/internal/handler"
+	"go-backend/internal/middleware"
+)
+
+// reloader applies changes to the configuration while the server runs:
+// the log level and CORS origins of the handlers, the cache TTL and the
+// rate limits. Other changes take a restart.
+type reloader struct {
+	loader          *config.Loader
+	handlers        []*handler.Handler
+	cache           cache.Cache
+	rateLimiter     middleware.Limiter
+	authRateLimiter middleware.Limiter
+
+	mu sync.Mutex
+	// running is the configuration the server runs with
+	running *config.Config
+}
+
+// Reload loads the configuration again and applies it, returning the
+// changed settings that take a restart. A configuration that does not
+// load or validate is rejected as a whole, and the server keeps running
+// with the one it has.
+func (r *reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.loader.Load()
+	if err != nil {
+		return nil, err
+	}
+	restartNeeded := r.running.RestartNeeded(next)
+	if len(restartNeeded) > 0 {
+		log.Printf("Warning: Restart to apply changes to %s", strings.Join(restartNeeded, ", "))
+	}
+	running := r.running.Reloaded(next)
+
+	for _, h := range r.handlers {
+		h.SetLogLevel(running.Server.LogLevel)
+		h.SetCORSOrigins(running.Server.CORSOrigins)
+	}
+	r.cache.SetTTL(running.Cache.TTL)
+	if r.rateLimiter != nil {
+		r.rateLimiter.SetLimit(running.RateLimit.Requests, time.Minute)
+	}
+	if r.authRateLimiter != nil {
+		r.authRateLimiter.SetLimit(running.RateLimit.AuthAttempts, running.RateLimit.AuthWindow)
+	}
+	r.running = running
+	return restartNeeded, nil
+}
+
+// Settings returns the configuration the server runs with, secrets
+// redacted.
+func (r *reloader) Settings() (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.running.Redacted()
+}
+
+// reloadOnHangup reloads the configuration whenever the process gets
+// SIGHUP, until ctx is done. Edit the configuration file and send
+// SIGHUP to apply it; environment variables and flags, which cannot
+// change, still override it.
+func reloadOnHangup(ctx context.Context, r *reloader) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			if _, err := r.Reload(); err != nil {
+				log.Printf("Warning: Configuration not reloaded: %v", err)
+				continue
+			}
+			log.Printf("Configuration reloaded")
+		}
+	}
+}
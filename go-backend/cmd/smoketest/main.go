@@ -0,0 +1,192 @@
+// Package main is a post-deploy smoke test that exercises the critical API
+// paths against a live server and exits non-zero on any failure.
+//
+// Usage:
+//
+//	go run ./cmd/smoketest -base-url http://localhost:8080
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+const defaultBaseURL = "http://localhost:8080"
+
+// client wraps an HTTP client bound to a base URL.
+type client struct {
+	baseURL string
+	http    *http.Client
+	apiKey  string
+}
+
+// do sends a request with an optional JSON body, checks the status code,
+// and decodes the response into out when non-nil.
+func (c *client) do(method, path string, body interface{}, wantStatus int, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("expected status %d, got %d: %s", wantStatus, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// step is a single named smoke test check.
+type step struct {
+	name string
+	run  func() error
+}
+
+func main() {
+	baseURL := flag.String("base-url", envOr("SMOKE_BASE_URL", defaultBaseURL), "base URL of the server under test")
+	apiKey := flag.String("api-key", os.Getenv("SMOKE_API_KEY"), "API key sent as X-API-Key, if required")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout")
+	flag.Parse()
+
+	c := &client{
+		baseURL: strings.TrimRight(*baseURL, "/"),
+		http:    &http.Client{Timeout: *timeout},
+		apiKey:  *apiKey,
+	}
+
+	var (
+		user model.User
+		task model.Task
+	)
+
+	// Unique email so repeated runs don't collide with EMAIL_EXISTS
+	email := fmt.Sprintf("smoketest+%d@example.com", time.Now().UnixNano())
+
+	steps := []step{
+		{"health", func() error {
+			var health model.DetailedHealthResponse
+			if err := c.do(http.MethodGet, "/health", nil, http.StatusOK, &health); err != nil {
+				return err
+			}
+			if health.Status != "ok" {
+				return fmt.Errorf("expected status 'ok', got '%s'", health.Status)
+			}
+			return nil
+		}},
+		{"create user", func() error {
+			req := model.CreateUserRequest{Name: "Smoke Test", Email: email, Role: "developer"}
+			if err := c.do(http.MethodPost, "/api/users", req, http.StatusCreated, &user); err != nil {
+				return err
+			}
+			if user.ID == 0 || user.Email != email {
+				return fmt.Errorf("unexpected user in response: %+v", user)
+			}
+			return nil
+		}},
+		{"create task", func() error {
+			req := model.CreateTaskRequest{Title: "Smoke test task", Status: "pending", UserID: user.ID}
+			if err := c.do(http.MethodPost, "/api/tasks", req, http.StatusCreated, &task); err != nil {
+				return err
+			}
+			if task.ID == 0 || task.UserID != user.ID {
+				return fmt.Errorf("unexpected task in response: %+v", task)
+			}
+			return nil
+		}},
+		{"update task", func() error {
+			status := "completed"
+			req := model.UpdateTaskRequest{Status: &status}
+			var updated model.Task
+			if err := c.do(http.MethodPut, fmt.Sprintf("/api/tasks/%d", task.ID), req, http.StatusOK, &updated); err != nil {
+				return err
+			}
+			if updated.Status != status {
+				return fmt.Errorf("expected status '%s', got '%s'", status, updated.Status)
+			}
+			return nil
+		}},
+		{"stats", func() error {
+			var stats model.StatsResponse
+			if err := c.do(http.MethodGet, "/api/stats", nil, http.StatusOK, &stats); err != nil {
+				return err
+			}
+			if stats.Users.Total == 0 || stats.Tasks.Completed == 0 {
+				return fmt.Errorf("stats do not reflect created data: %+v", stats)
+			}
+			return nil
+		}},
+		{"cache stats", func() error {
+			var stats map[string]interface{}
+			if err := c.do(http.MethodGet, "/api/cache/stats", nil, http.StatusOK, &stats); err != nil {
+				return err
+			}
+			if _, ok := stats["hitRate"]; !ok {
+				return fmt.Errorf("cache stats missing hitRate: %v", stats)
+			}
+			return nil
+		}},
+	}
+
+	log.SetFlags(0)
+	log.Printf("Running smoke test against %s", c.baseURL)
+
+	for _, s := range steps {
+		start := time.Now()
+		if err := s.run(); err != nil {
+			log.Printf("FAIL %-12s %v", s.name, err)
+			os.Exit(1)
+		}
+		log.Printf("PASS %-12s (%v)", s.name, time.Since(start).Round(time.Millisecond))
+	}
+
+	log.Printf("All %d checks passed", len(steps))
+}
+
+// envOr returns the environment variable value or a fallback.
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
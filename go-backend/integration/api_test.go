@@ -0,0 +1,143 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"go-backend/internal/model"
+)
+
+// redisDB hands each Redis-backed server its own database, so cached
+// responses from one test never leak into another.
+var redisDB atomic.Int32
+
+// backends lists the server configurations the API flow runs against.
+// Configurations are skipped when the service they need is unavailable.
+func backends() []struct {
+	name          string
+	needsRedis    bool
+	needsPostgres bool
+	env           func() []string
+} {
+	return []struct {
+		name          string
+		needsRedis    bool
+		needsPostgres bool
+		env           func() []string
+	}{
+		{"memory", false, false, func() []string { return nil }},
+		{"redis", true, false, func() []string {
+			return []string{
+				"REDIS_ADDR=" + redisAddr,
+				"REDIS_DB=" + strconv.Itoa(int(redisDB.Add(1))%16),
+				"CACHE_BACKEND=redis",
+				"RATE_LIMIT=10000",
+			}
+		}},
+		{"postgres", false, true, func() []string {
+			return []string{
+				"STORE_BACKEND=postgres",
+				"DATABASE_URL=" + databaseURL,
+				"DB_MAX_CONNS=4",
+			}
+		}},
+	}
+}
+
+func TestAPIFlow_PersistsAcrossRestart(t *testing.T) {
+	for _, backend := range backends() {
+		t.Run(backend.name, func(t *testing.T) {
+			if backend.needsRedis && redisAddr == "" {
+				t.Skip("Redis not available")
+			}
+			if backend.needsPostgres && databaseURL == "" {
+				t.Skip("PostgreSQL not available")
+			}
+
+			srv := startServer(t, t.TempDir(), backend.env()...)
+
+			var health model.DetailedHealthResponse
+			srv.request(http.MethodGet, "/health", nil, http.StatusOK, &health)
+			if health.Status != "ok" {
+				t.Fatalf("expected health status 'ok', got '%s'", health.Status)
+			}
+
+			// Readiness checks the services each backend depends on
+			var ready model.ReadinessResponse
+			srv.request(http.MethodGet, "/health/ready", nil, http.StatusOK, &ready)
+			if (backend.needsRedis && ready.Checks["cache"] != "ok") || (backend.needsPostgres && ready.Checks["database"] != "ok") {
+				t.Fatalf("expected the backend's services checked, got %v", ready.Checks)
+			}
+
+			email := uniqueEmail(backend.name)
+			var user model.User
+			srv.request(http.MethodPost, "/api/users", model.CreateUserRequest{
+				Name: "Integration User", Email: email, Role: "developer",
+			}, http.StatusCreated, &user)
+
+			var task model.Task
+			srv.request(http.MethodPost, "/api/tasks", model.CreateTaskRequest{
+				Title: "Integration task", Status: "pending", UserID: user.ID,
+			}, http.StatusCreated, &task)
+
+			completed := "completed"
+			srv.request(http.MethodPut, fmt.Sprintf("/api/tasks/%d", task.ID), model.UpdateTaskRequest{
+				Status: &completed,
+			}, http.StatusOK, nil)
+
+			// Populate the cache so the restart also exercises cached reads
+			var stats model.StatsResponse
+			srv.request(http.MethodGet, "/api/stats", nil, http.StatusOK, &stats)
+			if stats.Tasks.Completed == 0 {
+				t.Fatalf("expected completed tasks in stats, got %+v", stats)
+			}
+
+			srv.restart()
+
+			var restoredUser model.User
+			srv.request(http.MethodGet, fmt.Sprintf("/api/users/%d", user.ID), nil, http.StatusOK, &restoredUser)
+			if restoredUser.Email != email {
+				t.Errorf("expected user email '%s' after restart, got '%s'", email, restoredUser.Email)
+			}
+
+			var restoredTask model.Task
+			srv.request(http.MethodGet, fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &restoredTask)
+			if restoredTask.Status != completed {
+				t.Errorf("expected task status '%s' after restart, got '%s'", completed, restoredTask.Status)
+			}
+		})
+	}
+}
+
+func TestCache_InvalidatedAfterWrite(t *testing.T) {
+	for _, backend := range backends() {
+		t.Run(backend.name, func(t *testing.T) {
+			if backend.needsRedis && redisAddr == "" {
+				t.Skip("Redis not available")
+			}
+			if backend.needsPostgres && databaseURL == "" {
+				t.Skip("PostgreSQL not available")
+			}
+
+			srv := startServer(t, t.TempDir(), backend.env()...)
+
+			var before model.UsersResponse
+			srv.request(http.MethodGet, "/api/users", nil, http.StatusOK, &before)
+
+			srv.request(http.MethodPost, "/api/users", model.CreateUserRequest{
+				Name: "Cache User", Email: uniqueEmail("cache"), Role: "developer",
+			}, http.StatusCreated, nil)
+
+			var after model.UsersResponse
+			srv.request(http.MethodGet, "/api/users", nil, http.StatusOK, &after)
+			if after.Count != before.Count+1 {
+				t.Errorf("expected %d users after create, got %d", before.Count+1, after.Count)
+			}
+		})
+	}
+}
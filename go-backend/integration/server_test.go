@@ -0,0 +1,153 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// server is a running instance of the server binary.
+type server struct {
+	t       *testing.T
+	dir     string
+	env     []string
+	baseURL string
+	cmd     *exec.Cmd
+}
+
+// startServer starts the server binary with its working directory in dir,
+// so data/data.json lives under dir and survives restarts.
+func startServer(t *testing.T, dir string, env ...string) *server {
+	t.Helper()
+
+	s := &server{t: t, dir: dir, env: env}
+	s.start()
+	t.Cleanup(func() { s.stop() })
+	return s
+}
+
+func (s *server) start() {
+	s.t.Helper()
+
+	port, err := freePort()
+	if err != nil {
+		s.t.Fatalf("failed to find free port: %v", err)
+	}
+	s.baseURL = "http://localhost:" + strconv.Itoa(port)
+
+	s.cmd = exec.Command(serverBinary)
+	s.cmd.Dir = s.dir
+	s.cmd.Env = append(os.Environ(), append(s.env, "PORT="+strconv.Itoa(port))...)
+	s.cmd.Stdout, s.cmd.Stderr = os.Stdout, os.Stderr
+	if err := s.cmd.Start(); err != nil {
+		s.t.Fatalf("failed to start server: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(s.baseURL + "/health/live")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.t.Fatalf("server did not become live at %s", s.baseURL)
+}
+
+// stop interrupts the server and waits for it to exit, which it does once
+// it has shut down and closed its store, saving pending writes. It returns
+// an error if the server failed or had to be killed.
+func (s *server) stop() error {
+	if s.cmd == nil || s.cmd.Process == nil {
+		return nil
+	}
+	defer func() { s.cmd = nil }()
+
+	s.cmd.Process.Signal(os.Interrupt)
+	done := make(chan error, 1)
+	go func() { done <- s.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(15 * time.Second):
+		s.cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("server did not shut down, killed it")
+	}
+}
+
+// restart stops the server and starts it again with the same data directory.
+func (s *server) restart() {
+	s.t.Helper()
+
+	// The server exits only after its pending writes are saved
+	if err := s.stop(); err != nil {
+		s.t.Fatalf("server did not stop cleanly: %v", err)
+	}
+	s.start()
+}
+
+// request sends a JSON request and decodes the JSON response into out.
+func (s *server) request(method, path string, body interface{}, wantStatus int, out interface{}) {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("failed to marshal request: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, s.baseURL+path, reader)
+	if err != nil {
+		s.t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		s.t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, wantStatus, resp.StatusCode, respBody)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			s.t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+}
+
+// freePort asks the kernel for an unused TCP port.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// uniqueEmail returns an email that will not collide across runs.
+func uniqueEmail(prefix string) string {
+	return fmt.Sprintf("%s+%d@example.com", prefix, time.Now().UnixNano())
+}
@@ -0,0 +1,629 @@
+// Package attachment stores files attached to tasks in a pluggable
+// Storage and scans them for malware before they can be downloaded.
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go-backend/internal/model"
+)
+
+// Errors returned by Manager.
+var (
+	ErrNotFound    = errors.New("attachment not found")
+	ErrNotScanned  = errors.New("attachment has not passed its scan")
+	ErrQuarantined = errors.New("attachment is quarantined")
+	ErrInvalidName = errors.New("filename must be 1-255 characters without path separators")
+	ErrClosed      = errors.New("attachment manager is closed")
+	ErrNoContent   = errors.New("no attachment has this content")
+)
+
+// MaxFilenameLength caps the length of attachment filenames, in bytes.
+const MaxFilenameLength = 255
+
+// Scans run in the background, at most maxScans at once, each bounded by
+// scanTimeout.
+const (
+	maxScans    = 4
+	scanTimeout = 2 * time.Minute
+)
+
+// Storage keys: content of clean or pending files and of infected files,
+// both by SHA-256 hash, uploads being hashed, and metadata. Attachments
+// stored before content was hashed kept their content under their ID.
+const (
+	filesPrefix      = "files/"
+	quarantinePrefix = "quarantine/"
+	uploadsPrefix    = "uploads/"
+	metaPrefix       = "meta/"
+)
+
+func fileKey(hash string) string       { return filesPrefix + hash }
+func quarantineKey(hash string) string { return quarantinePrefix + hash }
+func uploadKey(id int) string          { return uploadsPrefix + strconv.Itoa(id) }
+func metaKey(id int) string            { return metaPrefix + strconv.Itoa(id) + ".json" }
+
+// ValidHash reports whether s is a SHA-256 hash in lowercase hex, as
+// attachments report their content's hash.
+func ValidHash(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Manager keeps attachments and their metadata in a Storage and scans
+// each new file with a Scanner. Files can be downloaded once their scan is
+// clean; infected files are moved to quarantine and never served. Without
+// a Scanner, files are marked unscanned and served as they are.
+//
+// Content is stored once however many attachments share it: attachments
+// with the same SHA-256 hash share one blob, which is deleted with the
+// last of them. A scan verdict is about the content, so it applies to all
+// of them.
+type Manager struct {
+	storage Storage
+	scanner Scanner
+
+	mu     sync.RWMutex
+	items  map[int]model.Attachment
+	nextID int
+	closed bool
+
+	// blobMu serializes storing, moving and deleting shared content;
+	// refs counts the attachments sharing each hash
+	blobMu sync.Mutex
+	refs   map[string]int
+
+	scans sync.WaitGroup
+	slots chan struct{}
+
+	// onReady is called when an attachment can first be downloaded
+	onReady func(model.Attachment)
+}
+
+// NewManager loads the metadata in storage and returns a manager for it.
+// Scans interrupted by a restart are run again.
+func NewManager(ctx context.Context, storage Storage, scanner Scanner) (*Manager, error) {
+	m := &Manager{
+		storage: storage,
+		scanner: scanner,
+		items:   make(map[int]model.Attachment),
+		refs:    make(map[string]int),
+		nextID:  1,
+		slots:   make(chan struct{}, maxScans),
+	}
+
+	keys, err := storage.List(ctx, metaPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	for _, key := range keys {
+		a, err := m.readMeta(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		if a.SHA256 == "" {
+			if a, err = m.hashLegacy(ctx, a); err != nil {
+				return nil, fmt.Errorf("hash attachment %d: %w", a.ID, err)
+			}
+		}
+		m.items[a.ID] = a
+		m.refs[a.SHA256]++
+		if a.ID >= m.nextID {
+			m.nextID = a.ID + 1
+		}
+	}
+
+	// Uploads interrupted by a restart were never attached
+	uploads, err := storage.List(ctx, uploadsPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list uploads: %w", err)
+	}
+	for _, key := range uploads {
+		storage.Delete(ctx, key)
+	}
+
+	scanning := make(map[string]bool)
+	for _, a := range m.items {
+		if a.ScanStatus == model.ScanPending && !scanning[a.SHA256] {
+			scanning[a.SHA256] = true
+			m.startScan(a.ID)
+		}
+	}
+	return m, nil
+}
+
+func (m *Manager) readMeta(ctx context.Context, key string) (model.Attachment, error) {
+	rc, err := m.storage.Open(ctx, key)
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	defer rc.Close()
+	var a model.Attachment
+	err = json.NewDecoder(rc).Decode(&a)
+	return a, err
+}
+
+// hashLegacy moves the content of an attachment stored before content was
+// hashed from under its ID to under its hash, and records the hash.
+func (m *Manager) hashLegacy(ctx context.Context, a model.Attachment) (model.Attachment, error) {
+	from, to := filesPrefix+strconv.Itoa(a.ID), fileKey
+	if a.ScanStatus == model.ScanInfected {
+		from, to = quarantinePrefix+strconv.Itoa(a.ID), quarantineKey
+	}
+	rc, err := m.storage.Open(ctx, from)
+	if err != nil {
+		return a, err
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, rc)
+	rc.Close()
+	if err != nil {
+		return a, err
+	}
+	a.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	if err := m.move(ctx, from, to(a.SHA256)); err != nil {
+		return a, err
+	}
+	return a, m.writeMeta(ctx, a)
+}
+
+func (m *Manager) writeMeta(ctx context.Context, a model.Attachment) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = m.storage.Put(ctx, metaKey(a.ID), bytes.NewReader(data))
+	return err
+}
+
+// OnReady sets fn to be called with each attachment once it can be
+// downloaded: after a clean scan, or on upload without a Scanner. fn
+// should not block; it is used to start follow-up work such as
+// thumbnails.
+func (m *Manager) OnReady(fn func(model.Attachment)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onReady = fn
+}
+
+// ready calls the OnReady function, if any, with a.
+func (m *Manager) ready(a model.Attachment) {
+	m.mu.RLock()
+	fn := m.onReady
+	m.mu.RUnlock()
+	if fn != nil {
+		fn(a)
+	}
+}
+
+// ValidFilename reports whether name can be used as an attachment's
+// filename.
+func ValidFilename(name string) bool {
+	return name != "" && len(name) <= MaxFilenameLength && utf8.ValidString(name) &&
+		name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// Create stores the content of r as a new attachment of task taskID and
+// starts scanning it. Content the manager already has is not stored again,
+// and the attachment takes the verdict of its earlier scan.
+func (m *Manager) Create(ctx context.Context, taskID int, filename, contentType string, r io.Reader) (model.Attachment, error) {
+	if !ValidFilename(filename) {
+		return model.Attachment{}, ErrInvalidName
+	}
+	id, err := m.newID()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	h := sha256.New()
+	size, err := m.storage.Put(ctx, uploadKey(id), io.TeeReader(r, h))
+	if err != nil {
+		m.storage.Delete(context.WithoutCancel(ctx), uploadKey(id))
+		return model.Attachment{}, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	m.blobMu.Lock()
+	if m.refs[hash] > 0 {
+		err = m.storage.Delete(ctx, uploadKey(id))
+	} else {
+		err = m.move(ctx, uploadKey(id), fileKey(hash))
+	}
+	if err == nil {
+		m.refs[hash]++
+	}
+	m.blobMu.Unlock()
+	if err != nil {
+		m.storage.Delete(context.WithoutCancel(ctx), uploadKey(id))
+		return model.Attachment{}, err
+	}
+
+	return m.add(ctx, model.Attachment{
+		ID:          id,
+		TaskID:      taskID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hash,
+	})
+}
+
+// CreateFromHash adds an attachment of task taskID with content the
+// manager already has, identified by its SHA-256 hash, so clients need not
+// upload it again. An empty contentType takes the type the content was
+// last uploaded with. It returns ErrNoContent for an unknown hash.
+func (m *Manager) CreateFromHash(ctx context.Context, taskID int, filename, contentType, hash string) (model.Attachment, error) {
+	if !ValidFilename(filename) {
+		return model.Attachment{}, ErrInvalidName
+	}
+	id, err := m.newID()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	m.blobMu.Lock()
+	existing, ok := m.Lookup(hash)
+	if ok {
+		m.refs[hash]++
+	}
+	m.blobMu.Unlock()
+	if !ok {
+		return model.Attachment{}, ErrNoContent
+	}
+
+	if contentType == "" {
+		contentType = existing.ContentType
+	}
+	return m.add(ctx, model.Attachment{
+		ID:          id,
+		TaskID:      taskID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        existing.Size,
+		SHA256:      hash,
+	})
+}
+
+// Lookup returns the latest attachment with content of the given SHA-256
+// hash, if any.
+func (m *Manager) Lookup(hash string) (model.Attachment, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var latest model.Attachment
+	for _, a := range m.items {
+		if a.SHA256 == hash && a.ID > latest.ID {
+			latest = a
+		}
+	}
+	return latest, latest.ID != 0
+}
+
+func (m *Manager) newID() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+	id := m.nextID
+	m.nextID++
+	return id, nil
+}
+
+// add records a new attachment whose content is stored and counted. It
+// takes the verdict of another attachment with the same content if there
+// is one, or waits for its scan if that is pending, and otherwise starts
+// a scan.
+func (m *Manager) add(ctx context.Context, a model.Attachment) (model.Attachment, error) {
+	a.CreatedAt = time.Now().UTC()
+	a.ScanStatus = model.ScanPending
+	if m.scanner == nil {
+		a.ScanStatus = model.ScanUnscanned
+	}
+
+	// Take the verdict and add the attachment at once, so a scan of the
+	// same content finishing in between also updates it
+	scanning := false
+	m.mu.Lock()
+	if a.ScanStatus == model.ScanPending {
+		for _, s := range m.items {
+			if s.SHA256 != a.SHA256 {
+				continue
+			}
+			switch s.ScanStatus {
+			case model.ScanClean, model.ScanInfected:
+				a.ScanStatus, a.ScanSignature, a.ScannedAt = s.ScanStatus, s.ScanSignature, s.ScannedAt
+			case model.ScanPending:
+				scanning = true
+			}
+		}
+	}
+	m.items[a.ID] = a
+	m.mu.Unlock()
+
+	if err := m.writeMeta(ctx, a); err != nil {
+		m.mu.Lock()
+		delete(m.items, a.ID)
+		m.mu.Unlock()
+		m.release(context.WithoutCancel(ctx), a.SHA256)
+		return model.Attachment{}, err
+	}
+
+	switch {
+	case a.ScanStatus == model.ScanPending && !scanning:
+		m.startScan(a.ID)
+	case a.ScanStatus == model.ScanClean || a.ScanStatus == model.ScanUnscanned:
+		m.ready(a)
+	}
+	return a, nil
+}
+
+// release drops a reference to the content of the given hash, deleting
+// it with the last one.
+func (m *Manager) release(ctx context.Context, hash string) {
+	m.blobMu.Lock()
+	defer m.blobMu.Unlock()
+	if m.refs[hash]--; m.refs[hash] > 0 {
+		return
+	}
+	delete(m.refs, hash)
+	m.storage.Delete(ctx, fileKey(hash))
+	m.storage.Delete(ctx, quarantineKey(hash))
+}
+
+// Get returns the attachment with the given ID.
+func (m *Manager) Get(id int) (model.Attachment, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.items[id]
+	return a, ok
+}
+
+// List returns the attachments of task taskID, oldest first.
+func (m *Manager) List(taskID int) []model.Attachment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	attachments := []model.Attachment{}
+	for _, a := range m.items {
+		if a.TaskID == taskID {
+			attachments = append(attachments, a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments
+}
+
+// Open returns an attachment and its content for download. It returns
+// ErrNotScanned while the scan is pending or if it failed, and
+// ErrQuarantined for infected files.
+func (m *Manager) Open(ctx context.Context, id int) (model.Attachment, io.ReadCloser, error) {
+	a, ok := m.Get(id)
+	if !ok {
+		return model.Attachment{}, nil, ErrNotFound
+	}
+	if err := downloadable(a); err != nil {
+		return a, nil, err
+	}
+
+	rc, err := m.storage.Open(ctx, fileKey(a.SHA256))
+	if errors.Is(err, ErrNotExist) {
+		return a, nil, ErrNotFound
+	}
+	return a, rc, err
+}
+
+// downloadable returns the error to refuse a download of a with, or nil.
+func downloadable(a model.Attachment) error {
+	switch a.ScanStatus {
+	case model.ScanClean, model.ScanUnscanned:
+		return nil
+	case model.ScanInfected:
+		return ErrQuarantined
+	}
+	return ErrNotScanned
+}
+
+// Delete removes an attachment, including a quarantined one. Its content
+// is deleted unless other attachments share it.
+func (m *Manager) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	a, ok := m.items[id]
+	delete(m.items, id)
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+
+	// Remove the metadata first so a failure cannot leave an attachment
+	// whose content is gone
+	if err := m.storage.Delete(ctx, metaKey(id)); err != nil {
+		return err
+	}
+	m.release(ctx, a.SHA256)
+	m.deleteThumbnails(ctx, id)
+	return nil
+}
+
+// Rescan scans an attachment again, e.g. after a failed scan or a
+// signature update. A quarantined file stays quarantined unless the new
+// scan is clean. It does nothing without a Scanner or while a scan is
+// pending.
+func (m *Manager) Rescan(ctx context.Context, id int) (model.Attachment, error) {
+	m.mu.Lock()
+	a, ok := m.items[id]
+	if !ok {
+		m.mu.Unlock()
+		return model.Attachment{}, ErrNotFound
+	}
+	if m.scanner == nil || a.ScanStatus == model.ScanPending {
+		m.mu.Unlock()
+		return a, nil
+	}
+	a.ScanStatus = model.ScanPending
+	a.ScanSignature = ""
+	a.ScannedAt = nil
+	m.items[id] = a
+	m.mu.Unlock()
+
+	if err := m.writeMeta(ctx, a); err != nil {
+		log.Printf("Warning: Failed to record rescan of attachment %d: %v", id, err)
+	}
+	m.startScan(id)
+	return a, nil
+}
+
+// startScan scans an attachment in the background.
+func (m *Manager) startScan(id int) {
+	m.scans.Add(1)
+	go func() {
+		defer m.scans.Done()
+		m.slots <- struct{}{}
+		defer func() { <-m.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+		defer cancel()
+		m.scan(ctx, id)
+	}()
+}
+
+// scan runs the scanner over an attachment's content and records its
+// verdict. Infected content is moved to quarantine before the verdict is
+// recorded, so it is never served. The verdict applies to every attachment
+// sharing the content, except that a failed scan leaves earlier verdicts
+// in place.
+func (m *Manager) scan(ctx context.Context, id int) {
+	a, ok := m.Get(id)
+	if !ok {
+		return
+	}
+	hash := a.SHA256
+
+	// A rescan of quarantined content reads it from quarantine
+	key := fileKey(hash)
+	if rc, err := m.storage.Open(ctx, quarantineKey(hash)); err == nil {
+		rc.Close()
+		key = quarantineKey(hash)
+	}
+
+	verdict, err := m.scanKey(ctx, key)
+	status := model.ScanClean
+	switch {
+	case err != nil:
+		log.Printf("Warning: Failed to scan attachment %d: %v", id, err)
+		status = model.ScanFailed
+	case verdict.Infected:
+		status = model.ScanInfected
+		if key == fileKey(hash) {
+			if err := m.moveShared(ctx, hash, key, quarantineKey(hash)); err != nil {
+				log.Printf("Warning: Failed to quarantine attachment %d: %v", id, err)
+			}
+		}
+		log.Printf("Attachment %d quarantined: %s", id, verdict.Signature)
+	case key == quarantineKey(hash):
+		if err := m.moveShared(ctx, hash, key, fileKey(hash)); err != nil {
+			log.Printf("Warning: Failed to release attachment %d: %v", id, err)
+			status = model.ScanFailed
+		}
+	}
+
+	now := time.Now().UTC()
+	var updated, readied []model.Attachment
+	m.mu.Lock()
+	for _, s := range m.items {
+		if s.SHA256 != hash {
+			continue
+		}
+		if s.ID != id && status == model.ScanFailed && s.ScanStatus != model.ScanPending {
+			continue
+		}
+		wasReady := s.ScanStatus == model.ScanClean
+		s.ScanStatus = status
+		s.ScanSignature = verdict.Signature
+		s.ScannedAt = &now
+		if status == model.ScanInfected {
+			s.Thumbnails = nil
+		}
+		m.items[s.ID] = s
+		updated = append(updated, s)
+		if status == model.ScanClean && !wasReady && len(s.Thumbnails) == 0 {
+			readied = append(readied, s)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, s := range updated {
+		if status == model.ScanInfected {
+			m.deleteThumbnails(ctx, s.ID)
+		}
+		if err := m.writeMeta(ctx, s); err != nil {
+			log.Printf("Warning: Failed to record scan of attachment %d: %v", s.ID, err)
+		}
+	}
+	for _, s := range readied {
+		m.ready(s)
+	}
+}
+
+// moveShared moves the content of the given hash between files and
+// quarantine, unless it was deleted in the meantime.
+func (m *Manager) moveShared(ctx context.Context, hash, from, to string) error {
+	m.blobMu.Lock()
+	defer m.blobMu.Unlock()
+	if m.refs[hash] == 0 {
+		return nil
+	}
+	return m.move(ctx, from, to)
+}
+
+func (m *Manager) scanKey(ctx context.Context, key string) (Verdict, error) {
+	rc, err := m.storage.Open(ctx, key)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer rc.Close()
+	return m.scanner.Scan(ctx, rc)
+}
+
+// move copies a blob to a new key and deletes the original.
+func (m *Manager) move(ctx context.Context, from, to string) error {
+	rc, err := m.storage.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	_, err = m.storage.Put(ctx, to, rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	return m.storage.Delete(ctx, from)
+}
+
+// Close stops accepting attachments and waits for running scans to
+// finish. Attachments still pending are scanned again on the next start.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.scans.Wait()
+}
@@ -0,0 +1,357 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// fakeScanner flags content containing "EICAR" and fails on "broken".
+type fakeScanner struct{}
+
+func (fakeScanner) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Verdict{}, err
+	}
+	switch {
+	case strings.Contains(string(data), "EICAR"):
+		return Verdict{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	case strings.Contains(string(data), "broken"):
+		return Verdict{}, errors.New("scanner unavailable")
+	}
+	return Verdict{}, nil
+}
+
+// waitScanned waits for an attachment's scan to finish.
+func waitScanned(t *testing.T, m *Manager, id int) model.Attachment {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if a, _ := m.Get(id); a.ScanStatus != model.ScanPending {
+			return a
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("attachment %d still pending", id)
+	return model.Attachment{}
+}
+
+func newTestManager(t *testing.T, storage Storage, scanner Scanner) *Manager {
+	t.Helper()
+	m, err := NewManager(context.Background(), storage, scanner)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestManager_Scan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := NewMemory()
+	m := newTestManager(t, storage, fakeScanner{})
+
+	clean, err := m.Create(ctx, 1, "notes.txt", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if clean.ScanStatus != model.ScanPending || clean.Size != 5 {
+		t.Errorf("expected a pending 5 byte attachment, got %+v", clean)
+	}
+	if a := waitScanned(t, m, clean.ID); a.ScanStatus != model.ScanClean || a.ScannedAt == nil {
+		t.Errorf("expected a clean scan, got %+v", a)
+	}
+	_, rc, err := m.Open(ctx, clean.ID)
+	if err != nil {
+		t.Fatalf("expected a clean file to open, got %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" {
+		t.Errorf("expected the stored content, got %q", data)
+	}
+
+	infected, _ := m.Create(ctx, 1, "virus.exe", "application/octet-stream", strings.NewReader("EICAR"))
+	if a := waitScanned(t, m, infected.ID); a.ScanStatus != model.ScanInfected || a.ScanSignature != "Eicar-Test-Signature" {
+		t.Errorf("expected an infected verdict, got %+v", a)
+	}
+	if _, _, err := m.Open(ctx, infected.ID); !errors.Is(err, ErrQuarantined) {
+		t.Errorf("expected ErrQuarantined, got %v", err)
+	}
+	if keys, _ := storage.List(ctx, quarantinePrefix); len(keys) != 1 {
+		t.Errorf("expected the infected file in quarantine, got %v", keys)
+	}
+	if keys, _ := storage.List(ctx, filesPrefix); len(keys) != 1 {
+		t.Errorf("expected only the clean file outside quarantine, got %v", keys)
+	}
+
+	failed, _ := m.Create(ctx, 2, "broken.bin", "application/octet-stream", strings.NewReader("broken"))
+	if a := waitScanned(t, m, failed.ID); a.ScanStatus != model.ScanFailed {
+		t.Errorf("expected a failed scan, got %+v", a)
+	}
+	if _, _, err := m.Open(ctx, failed.ID); !errors.Is(err, ErrNotScanned) {
+		t.Errorf("expected ErrNotScanned, got %v", err)
+	}
+
+	if got := m.List(1); len(got) != 2 || got[0].ID != clean.ID {
+		t.Errorf("expected task 1's two attachments in order, got %+v", got)
+	}
+}
+
+func TestManager_Reload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDisk failed: %v", err)
+	}
+	m := newTestManager(t, storage, nil)
+	a, _ := m.Create(ctx, 3, "report.pdf", "application/pdf", strings.NewReader("%PDF"))
+	if a.ScanStatus != model.ScanUnscanned {
+		t.Errorf("expected an unscanned attachment without a scanner, got %+v", a)
+	}
+
+	reloaded := newTestManager(t, storage, nil)
+	if got, ok := reloaded.Get(a.ID); !ok || got.Filename != "report.pdf" {
+		t.Errorf("expected the attachment after reloading, got %+v", got)
+	}
+	next, _ := reloaded.Create(ctx, 3, "second.txt", "text/plain", strings.NewReader("x"))
+	if next.ID != a.ID+1 {
+		t.Errorf("expected IDs to continue after reloading, got %d", next.ID)
+	}
+
+	if err := reloaded.Delete(ctx, a.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, _, err := reloaded.Open(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deleting, got %v", err)
+	}
+}
+
+func TestManager_Dedup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := NewMemory()
+	m := newTestManager(t, storage, fakeScanner{})
+
+	first, _ := m.Create(ctx, 1, "notes.txt", "text/plain", strings.NewReader("hello"))
+	waitScanned(t, m, first.ID)
+	// sha256("hello")
+	const hash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if first.SHA256 != hash {
+		t.Errorf("expected the content's hash, got %q", first.SHA256)
+	}
+
+	second, err := m.Create(ctx, 2, "copy.txt", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if second.ScanStatus != model.ScanClean {
+		t.Errorf("expected the earlier verdict for known content, got %q", second.ScanStatus)
+	}
+	linked, err := m.CreateFromHash(ctx, 3, "linked.txt", "", hash)
+	if err != nil {
+		t.Fatalf("CreateFromHash failed: %v", err)
+	}
+	if linked.Size != 5 || linked.ContentType != "text/plain" || linked.ScanStatus != model.ScanClean {
+		t.Errorf("expected the known content's size, type and verdict, got %+v", linked)
+	}
+	if keys, _ := storage.List(ctx, filesPrefix); len(keys) != 1 {
+		t.Errorf("expected the content stored once, got %v", keys)
+	}
+	if _, err := m.CreateFromHash(ctx, 3, "missing.txt", "", strings.Repeat("0", 64)); !errors.Is(err, ErrNoContent) {
+		t.Errorf("expected ErrNoContent for an unknown hash, got %v", err)
+	}
+
+	m.Delete(ctx, first.ID)
+	m.Delete(ctx, second.ID)
+	_, rc, err := m.Open(ctx, linked.ID)
+	if err != nil {
+		t.Fatalf("expected shared content to outlive other attachments, got %v", err)
+	}
+	rc.Close()
+	m.Delete(ctx, linked.ID)
+	if keys, _ := storage.List(ctx, filesPrefix); len(keys) != 0 {
+		t.Errorf("expected the content deleted with its last attachment, got %v", keys)
+	}
+	if _, ok := m.Lookup(hash); ok {
+		t.Error("expected no attachment with the content after deleting them")
+	}
+
+	// A verdict applies to all attachments sharing the content
+	a, _ := m.Create(ctx, 1, "a.exe", "application/octet-stream", strings.NewReader("EICAR"))
+	b, _ := m.Create(ctx, 1, "b.exe", "application/octet-stream", strings.NewReader("EICAR"))
+	waitScanned(t, m, a.ID)
+	if got := waitScanned(t, m, b.ID); got.ScanStatus != model.ScanInfected {
+		t.Errorf("expected both copies infected, got %+v", got)
+	}
+}
+
+func TestManager_HashesLegacyContent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := NewMemory()
+	storage.Put(ctx, "files/1", strings.NewReader("hello"))
+	storage.Put(ctx, "meta/1.json", strings.NewReader(`{"id":1,"taskId":1,"filename":"notes.txt","contentType":"text/plain","size":5,"scanStatus":"unscanned"}`))
+
+	m := newTestManager(t, storage, nil)
+	a, _ := m.Get(1)
+	if !ValidHash(a.SHA256) {
+		t.Fatalf("expected the content hashed on load, got %+v", a)
+	}
+	if keys, _ := storage.List(ctx, filesPrefix); len(keys) != 1 || keys[0] != fileKey(a.SHA256) {
+		t.Errorf("expected the content moved under its hash, got %v", keys)
+	}
+	if reloaded := newTestManager(t, storage, nil); reloaded.refs[a.SHA256] != 1 {
+		t.Errorf("expected the hash recorded, got %v", reloaded.refs)
+	}
+}
+
+func TestManager_Thumbnails(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newTestManager(t, NewMemory(), nil)
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 1024, 256)))
+	a, _ := m.Create(ctx, 1, "banner.png", "image/png", &img)
+	if _, _, err := m.OpenThumbnail(ctx, a.ID, "thumb"); !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("expected ErrNoThumbnail before generating, got %v", err)
+	}
+
+	if err := m.GenerateThumbnails(ctx, a.ID); err != nil {
+		t.Fatalf("GenerateThumbnails failed: %v", err)
+	}
+	if got, _ := m.Get(a.ID); strings.Join(got.Thumbnails, ",") != "thumb,medium" {
+		t.Errorf("expected both sizes recorded, got %v", got.Thumbnails)
+	}
+	for size, want := range map[string]image.Point{"thumb": {128, 32}, "medium": {512, 128}} {
+		_, rc, err := m.OpenThumbnail(ctx, a.ID, size)
+		if err != nil {
+			t.Fatalf("OpenThumbnail(%s) failed: %v", size, err)
+		}
+		cfg, err := png.DecodeConfig(rc)
+		rc.Close()
+		if err != nil || cfg.Width != want.X || cfg.Height != want.Y {
+			t.Errorf("expected a %dx%d %s thumbnail, got %dx%d, %v", want.X, want.Y, size, cfg.Width, cfg.Height, err)
+		}
+	}
+	if _, _, err := m.OpenThumbnail(ctx, a.ID, "huge"); !errors.Is(err, ErrUnknownSize) {
+		t.Errorf("expected ErrUnknownSize, got %v", err)
+	}
+
+	text, _ := m.Create(ctx, 1, "notes.txt", "text/plain", strings.NewReader("hello"))
+	if err := m.GenerateThumbnails(ctx, text.ID); !errors.Is(err, ErrNotImage) {
+		t.Errorf("expected ErrNotImage for a text file, got %v", err)
+	}
+}
+
+func TestValidFilename(t *testing.T) {
+	for name, want := range map[string]bool{
+		"notes.txt":              true,
+		"Résumé 2026.pdf":        true,
+		"":                       false,
+		"..":                     false,
+		"../etc/passwd":          false,
+		`dir\file`:               false,
+		strings.Repeat("a", 256): false,
+	} {
+		if got := ValidFilename(name); got != want {
+			t.Errorf("ValidFilename(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	signer := NewSigner([]byte("secret"))
+	now := time.Unix(1_800_000_000, 0)
+	expires := now.Add(time.Minute)
+	signature := signer.Sign(7, expires)
+
+	if err := signer.Verify(7, expires.Unix(), signature, now); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := signer.Verify(8, expires.Unix(), signature, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected another ID to be rejected, got %v", err)
+	}
+	if err := signer.Verify(7, expires.Unix()+60, signature, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected an extended expiry to be rejected, got %v", err)
+	}
+	if err := signer.Verify(7, expires.Unix(), signature, expires); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("expected the URL to expire, got %v", err)
+	}
+	if err := NewSigner([]byte("other")).Verify(7, expires.Unix(), signature, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected another key to reject the signature, got %v", err)
+	}
+}
+
+func TestClamAV(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// A minimal clamd: read the INSTREAM chunks and flag EICAR content
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				cmd := make([]byte, len("zINSTREAM\x00"))
+				io.ReadFull(conn, cmd)
+				var data []byte
+				for {
+					var size uint32
+					if binary.Read(conn, binary.BigEndian, &size) != nil || size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					io.ReadFull(conn, chunk)
+					data = append(data, chunk...)
+				}
+				if strings.Contains(string(data), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+
+	scanner := ClamAV{Addr: ln.Addr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if v, err := scanner.Scan(ctx, strings.NewReader("hello")); err != nil || v.Infected {
+		t.Errorf("expected a clean verdict, got %+v, %v", v, err)
+	}
+	v, err := scanner.Scan(ctx, strings.NewReader("X5O!P%@AP EICAR test"))
+	if err != nil || !v.Infected || v.Signature != "Eicar-Test-Signature" {
+		t.Errorf("expected an infected verdict, got %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,127 @@
+package attachment
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Verdict is the outcome of scanning a file.
+type Verdict struct {
+	Infected bool
+	// Signature names what was found in an infected file.
+	Signature string
+}
+
+// Scanner checks file content for malware.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Verdict, error)
+}
+
+// ClamAV scans files with a clamd daemon over its INSTREAM command.
+type ClamAV struct {
+	// Addr is the daemon's TCP address, e.g. "localhost:3310".
+	Addr string
+}
+
+// clamChunkSize is the size of the chunks streamed to clamd. It must not
+// exceed the daemon's StreamMaxLength.
+const clamChunkSize = 64 << 10
+
+// Scan streams r to clamd and parses its reply, "stream: OK" for a clean
+// file or "stream: <signature> FOUND" for an infected one.
+func (c ClamAV) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	buf := make([]byte, clamChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.Write(w, binary.BigEndian, uint32(n))
+			w.Write(buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Verdict{}, err
+		}
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return Verdict{}, fmt.Errorf("clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && reply == "" {
+		return Verdict{}, fmt.Errorf("clamd: %w", err)
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	}
+	return Verdict{}, fmt.Errorf("clamd: %s", reply)
+}
+
+// HTTPScanner scans files with an external API. The file is POSTed as the
+// request body; the API replies with JSON such as
+// {"infected": true, "signature": "Eicar-Test-Signature"}.
+type HTTPScanner struct {
+	URL string
+	// Client sends the requests; nil uses a client with a 2 minute timeout.
+	Client *http.Client
+}
+
+var defaultScanClient = &http.Client{Timeout: 2 * time.Minute}
+
+func (s HTTPScanner) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	client := s.Client
+	if client == nil {
+		client = defaultScanClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, r)
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("scan API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("scan API: %s", resp.Status)
+	}
+
+	var result struct {
+		Infected  bool   `json:"infected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return Verdict{}, fmt.Errorf("scan API: invalid response: %w", err)
+	}
+	return Verdict{Infected: result.Infected, Signature: result.Signature}, nil
+}
@@ -0,0 +1,66 @@
+package attachment
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Limits on how long signed download URLs stay valid.
+const (
+	DefaultURLExpiry = 15 * time.Minute
+	MaxURLExpiry     = 7 * 24 * time.Hour
+)
+
+// Errors returned by Signer.Verify.
+var (
+	ErrURLExpired       = errors.New("download URL has expired")
+	ErrInvalidSignature = errors.New("download URL signature is invalid")
+)
+
+// Signer signs download URLs so an attachment can be fetched until a
+// deadline without API credentials, e.g. by a browser or through a CDN.
+// A signature covers the attachment ID and the expiry time, so neither can
+// be changed without invalidating it.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a signer using key. With an empty key, a random one is
+// generated: URLs then stop working on restart and are only accepted by
+// the instance that signed them.
+func NewSigner(key []byte) *Signer {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic("attachment: cannot generate a signing key: " + err.Error())
+		}
+	}
+	return &Signer{key: append([]byte{}, key...)}
+}
+
+// Sign returns the signature of a download URL for attachment id that
+// expires at expires.
+func (s *Signer) Sign(id int, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(strconv.Itoa(id) + ":" + strconv.FormatInt(expires.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a download URL for attachment id that
+// expires at the Unix time expires. It returns ErrInvalidSignature for a
+// forged or altered URL and ErrURLExpired once now is past the expiry.
+func (s *Signer) Verify(id int, expires int64, signature string, now time.Time) error {
+	want := s.Sign(id, time.Unix(expires, 0))
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() >= expires {
+		return ErrURLExpired
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"go-backend/internal/model"
+
+	// Register the GIF decoder; GIF thumbnails are encoded as PNG
+	_ "image/gif"
+)
+
+// ThumbnailSizes maps the standard thumbnail sizes to the longest side
+// they are scaled to, in pixels. Images already that small are not
+// scaled up.
+var ThumbnailSizes = map[string]int{
+	"thumb":  128,
+	"medium": 512,
+}
+
+// MaxImagePixels caps the images thumbnails are made of, so a small file
+// that decodes to a huge image cannot exhaust memory.
+const MaxImagePixels = 40_000_000
+
+// Errors returned for thumbnails.
+var (
+	ErrNotImage      = errors.New("attachment is not an image")
+	ErrNoThumbnail   = errors.New("thumbnail has not been generated")
+	ErrUnknownSize   = errors.New("unknown thumbnail size")
+	ErrImageTooLarge = errors.New("image is too large for thumbnails")
+)
+
+const thumbsPrefix = "thumbs/"
+
+func thumbKey(id int, size string) string { return fmt.Sprintf("%s%d-%s", thumbsPrefix, id, size) }
+
+// IsImage reports whether thumbnails can be made of content of type
+// contentType.
+func IsImage(contentType string) bool {
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// ThumbnailType returns the content type of the thumbnails of an image of
+// type contentType: JPEG for JPEG images, PNG for others to keep
+// transparency.
+func ThumbnailType(contentType string) string {
+	if strings.HasPrefix(contentType, "image/jpeg") {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// GenerateThumbnails makes the standard thumbnails of an image attachment
+// and stores them alongside it. It may be run again, e.g. after new sizes
+// are added.
+func (m *Manager) GenerateThumbnails(ctx context.Context, id int) error {
+	a, rc, err := m.Open(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if !IsImage(a.ContentType) {
+		return ErrNotImage
+	}
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return ErrImageTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	var sizes []string
+	for size, side := range ThumbnailSizes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		thumb := scaleDown(src, side)
+		if ThumbnailType(a.ContentType) == "image/jpeg" {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			return fmt.Errorf("encode %s thumbnail: %w", size, err)
+		}
+		if _, err := m.storage.Put(ctx, thumbKey(id, size), &buf); err != nil {
+			return err
+		}
+		sizes = append(sizes, size)
+	}
+
+	m.mu.Lock()
+	a, ok := m.items[id]
+	if !ok {
+		// Deleted while the thumbnails were made
+		m.mu.Unlock()
+		m.deleteThumbnails(ctx, id)
+		return ErrNotFound
+	}
+	a.Thumbnails = sortedSizes(sizes)
+	m.items[id] = a
+	m.mu.Unlock()
+	return m.writeMeta(ctx, a)
+}
+
+// OpenThumbnail returns an attachment and its thumbnail of the given size.
+// Like Open, it refuses files that have not passed their scan.
+func (m *Manager) OpenThumbnail(ctx context.Context, id int, size string) (model.Attachment, io.ReadCloser, error) {
+	if _, ok := ThumbnailSizes[size]; !ok {
+		return model.Attachment{}, nil, ErrUnknownSize
+	}
+	a, ok := m.Get(id)
+	if !ok {
+		return model.Attachment{}, nil, ErrNotFound
+	}
+	if err := downloadable(a); err != nil {
+		return a, nil, err
+	}
+	if !IsImage(a.ContentType) {
+		return a, nil, ErrNotImage
+	}
+	if !hasSize(a.Thumbnails, size) {
+		return a, nil, ErrNoThumbnail
+	}
+
+	rc, err := m.storage.Open(ctx, thumbKey(id, size))
+	if errors.Is(err, ErrNotExist) {
+		return a, nil, ErrNoThumbnail
+	}
+	return a, rc, err
+}
+
+// deleteThumbnails removes every stored thumbnail of an attachment.
+func (m *Manager) deleteThumbnails(ctx context.Context, id int) {
+	for size := range ThumbnailSizes {
+		m.storage.Delete(ctx, thumbKey(id, size))
+	}
+}
+
+func hasSize(sizes []string, size string) bool {
+	for _, s := range sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedSizes orders sizes from smallest to largest.
+func sortedSizes(sizes []string) []string {
+	for i := 1; i < len(sizes); i++ {
+		for j := i; j > 0 && ThumbnailSizes[sizes[j]] < ThumbnailSizes[sizes[j-1]]; j-- {
+			sizes[j], sizes[j-1] = sizes[j-1], sizes[j]
+		}
+	}
+	return sizes
+}
+
+// scaleDown shrinks src so its longest side is at most side pixels,
+// averaging the source pixels each thumbnail pixel covers.
+func scaleDown(src image.Image, side int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= side && h <= side {
+		return src
+	}
+	dw, dh := side, h*side/w
+	if h > w {
+		dw, dh = w*side/h, side
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, al, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, al, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), al+uint64(ca), n+1
+				}
+			}
+			c := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(al / n)}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
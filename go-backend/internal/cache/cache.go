@@ -1,129 +1,56 @@
-// Package cache provides a thread-safe TTL-based caching layer.
+// Package cache provides a thread-safe TTL-based caching layer with
+// in-memory and Redis implementations.
 package cache
 
 import (
-	"sync"
-	"sync/atomic"
+	"context"
 	"time"
 )
 
-// Entry represents a cached item with expiration.
-type Entry struct {
-	Data      interface{}
-	ExpiresAt time.Time
-}
-
-// Cache provides thread-safe caching with TTL expiration.
-type Cache struct {
-	mu      sync.RWMutex
-	entries map[string]Entry
-	ttl     time.Duration
-	hits    atomic.Int64
-	misses  atomic.Int64
-}
-
-// New creates a new Cache with the specified TTL.
-// It starts a background goroutine to clean up expired entries.
-func New(ttl time.Duration) *Cache {
-	c := &Cache{
-		entries: make(map[string]Entry),
-		ttl:     ttl,
-	}
-
-	go c.cleanupExpired()
-
-	return c
-}
-
-// Get retrieves a value from the cache.
-// Returns the value and true if found and not expired, nil and false otherwise.
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	entry, exists := c.entries[key]
-	if !exists {
-		c.misses.Add(1)
-		return nil, false
-	}
-
-	if time.Now().After(entry.ExpiresAt) {
-		c.misses.Add(1)
-		return nil, false
-	}
-
-	c.hits.Add(1)
-	return entry.Data, true
-}
-
-// Set stores a value in the cache with the default TTL.
-func (c *Cache) Set(key string, data interface{}) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.entries[key] = Entry{
-		Data:      data,
-		ExpiresAt: time.Now().Add(c.ttl),
-	}
-}
-
-// Invalidate removes specified keys from the cache.
-func (c *Cache) Invalidate(keys ...string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Special TTL values for SetWithTTL and Touch.
+const (
+	// NoExpiration keeps an entry until it is invalidated.
+	NoExpiration time.Duration = -1
+	// DefaultExpiration uses the TTL the cache was created with.
+	DefaultExpiration time.Duration = 0
+)
 
-	for _, key := range keys {
-		delete(c.entries, key)
-	}
+// Cache is a TTL-based key/value cache shared by the handlers.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get retrieves a value. Returns the value and true if found and not expired.
+	Get(key string) (interface{}, bool)
+	// Set stores a value with the default TTL.
+	Set(key string, data interface{})
+	// SetWithTTL stores a value with a specific TTL, NoExpiration or DefaultExpiration.
+	SetWithTTL(key string, data interface{}, ttl time.Duration)
+	// Touch extends the expiration of an existing entry.
+	// Returns false if the key is missing or already expired.
+	Touch(key string, ttl time.Duration) bool
+	// Invalidate removes the specified keys.
+	Invalidate(keys ...string)
+	// InvalidatePrefix removes every key starting with prefix.
+	InvalidatePrefix(prefix string)
+	// InvalidateAll removes every entry.
+	InvalidateAll()
+	// SetTTL changes the default TTL of entries stored from now on, e.g.
+	// when the configuration is reloaded.
+	SetTTL(ttl time.Duration)
+	// Stats returns cache statistics.
+	Stats() map[string]interface{}
+	// Close stops background work and releases resources.
+	// The cache must not be used after Close.
+	Close()
 }
 
-// InvalidateAll clears all entries from the cache.
-func (c *Cache) InvalidateAll() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.entries = make(map[string]Entry)
+// Pinger is implemented by caches kept on another server, which may be
+// unreachable. A cache that cannot be reached misses rather than failing.
+type Pinger interface {
+	// Ping checks that the cache's server can be reached.
+	Ping(ctx context.Context) error
 }
 
-// Stats returns cache statistics.
-func (c *Cache) Stats() map[string]interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	hits := c.hits.Load()
-	misses := c.misses.Load()
-	total := hits + misses
-
-	var hitRate float64
-	if total > 0 {
-		hitRate = float64(hits) / float64(total) * 100
-	}
-
-	return map[string]interface{}{
-		"hits":    hits,
-		"misses":  misses,
-		"total":   total,
-		"hitRate": hitRate,
-		"entries": len(c.entries),
-		"ttl":     c.ttl.String(),
-	}
-}
-
-func (c *Cache) cleanupExpired() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for key, entry := range c.entries {
-			if now.After(entry.ExpiresAt) {
-				delete(c.entries, key)
-			}
-		}
-		c.mu.Unlock()
-	}
-}
+var _ Pinger = (*RedisCache)(nil)
 
 // Key generators for common cache keys.
 
@@ -132,9 +59,15 @@ func UsersKey() string {
 	return "users"
 }
 
-// TasksKey returns the cache key for tasks with optional filters.
-func TasksKey(status, userID string) string {
-	return "tasks:" + status + ":" + userID
+// TasksKey returns the cache key for tasks with optional filters and
+// sort order. tags identifies a tag filter, including its mode.
+func TasksKey(status, userID, priority, tags, blocked, project, sort string) string {
+	return TasksPrefix() + status + ":" + userID + ":" + priority + ":" + tags + ":" + blocked + ":" + project + ":" + sort
+}
+
+// TasksPrefix returns the prefix shared by all task list cache keys.
+func TasksPrefix() string {
+	return "tasks:"
 }
 
 // StatsKey returns the cache key for statistics.
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_SetAndGet(t *testing.T) {
+	c := New(time.Minute)
+	t.Cleanup(c.Close)
+
+	c.Set("key", "value")
+
+	got, found := c.Get("key")
+	if !found {
+		t.Fatal("expected key to be found")
+	}
+	if got != "value" {
+		t.Errorf("expected 'value', got %v", got)
+	}
+}
+
+func TestCache_SetWithTTL(t *testing.T) {
+	c := New(time.Minute)
+	t.Cleanup(c.Close)
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wait      time.Duration
+		wantFound bool
+	}{
+		{"short ttl expires", 10 * time.Millisecond, 20 * time.Millisecond, false},
+		{"long ttl survives", time.Hour, 0, true},
+		{"no expiration", NoExpiration, 20 * time.Millisecond, true},
+		{"default expiration", DefaultExpiration, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.SetWithTTL(tt.name, "value", tt.ttl)
+			time.Sleep(tt.wait)
+
+			if _, found := c.Get(tt.name); found != tt.wantFound {
+				t.Errorf("expected found=%v, got %v", tt.wantFound, found)
+			}
+		})
+	}
+}
+
+func TestCache_SetTTL(t *testing.T) {
+	c := New(time.Hour)
+	t.Cleanup(c.Close)
+
+	c.Set("before", "value")
+	c.SetTTL(10 * time.Millisecond)
+	c.Set("after", "value")
+	time.Sleep(20 * time.Millisecond)
+
+	if _, found := c.Get("before"); !found {
+		t.Error("expected entries stored before the change to keep their TTL")
+	}
+	if _, found := c.Get("after"); found {
+		t.Error("expected entries stored after the change to expire with the new TTL")
+	}
+	if ttl := c.Stats()["ttl"]; ttl != "10ms" {
+		t.Errorf("expected stats to show the new TTL, got %v", ttl)
+	}
+}
+
+func TestCache_Touch(t *testing.T) {
+	c := New(time.Minute)
+	t.Cleanup(c.Close)
+
+	c.SetWithTTL("key", "value", 20*time.Millisecond)
+	if !c.Touch("key", time.Hour) {
+		t.Fatal("expected Touch to succeed for existing key")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, found := c.Get("key"); !found {
+		t.Error("expected touched key to still be cached")
+	}
+	if c.Touch("missing", time.Hour) {
+		t.Error("expected Touch to fail for missing key")
+	}
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := New(time.Minute)
+	t.Cleanup(c.Close)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Invalidate("a")
+
+	if _, found := c.Get("a"); found {
+		t.Error("expected 'a' to be invalidated")
+	}
+	if _, found := c.Get("b"); !found {
+		t.Error("expected 'b' to remain cached")
+	}
+}
+
+func TestCache_LRUEviction(t *testing.T) {
+	c := NewWithLimit(time.Minute, 2)
+	t.Cleanup(c.Close)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// Touch "a" so "b" becomes the least recently used entry
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, found := c.Get("b"); found {
+		t.Error("expected 'b' to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found := c.Get(key); !found {
+			t.Errorf("expected '%s' to remain cached", key)
+		}
+	}
+
+	stats := c.Stats()
+	if stats["evictions"] != int64(1) {
+		t.Errorf("expected 1 eviction, got %v", stats["evictions"])
+	}
+	if stats["entries"] != 2 {
+		t.Errorf("expected 2 entries, got %v", stats["entries"])
+	}
+}
+
+func TestCache_InvalidatePrefix(t *testing.T) {
+	c := New(time.Minute)
+	t.Cleanup(c.Close)
+
+	c.Set(UsersKey(), "users")
+	c.Set(TasksKey("", "", "", "", "", "", ""), "all tasks")
+	c.Set(TasksKey("pending", "1", "", "", "", "", ""), "filtered tasks")
+
+	c.InvalidatePrefix(TasksPrefix())
+
+	if _, found := c.Get(UsersKey()); !found {
+		t.Error("expected users entry to survive task invalidation")
+	}
+	for _, key := range []string{TasksKey("", "", "", "", "", "", ""), TasksKey("pending", "1", "", "", "", "", "")} {
+		if _, found := c.Get(key); found {
+			t.Errorf("expected '%s' to be invalidated", key)
+		}
+	}
+}
+
+func TestMemoryCache_Close(t *testing.T) {
+	c := New(time.Minute)
+	c.Set("key", "value")
+
+	c.Close()
+	c.Close() // safe to call twice
+
+	if _, found := c.Get("key"); !found {
+		t.Error("expected entries to remain readable after Close")
+	}
+}
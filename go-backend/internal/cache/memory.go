@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry represents a cached item with expiration.
+// A zero ExpiresAt means the entry never expires.
+type Entry struct {
+	Data      interface{}
+	ExpiresAt time.Time
+}
+
+// expired reports whether the entry has expired at the given time.
+func (e Entry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// lruItem is the value stored in each element of the recency list.
+type lruItem struct {
+	key   string
+	entry Entry
+}
+
+// MemoryCache provides thread-safe in-process caching with TTL expiration
+// and optional LRU eviction once maxEntries is reached.
+type MemoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	recency    *list.List   // front is most recently used
+	ttl        atomic.Int64 // default TTL as a time.Duration
+	maxEntries int
+	hits       atomic.Int64
+	misses     atomic.Int64
+	evictions  atomic.Int64
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// New creates a new unbounded MemoryCache with the specified TTL.
+// It starts a background goroutine to clean up expired entries,
+// which runs until Close is called.
+func New(ttl time.Duration) *MemoryCache {
+	return NewWithLimit(ttl, 0)
+}
+
+// NewWithLimit creates a new MemoryCache with the specified TTL that holds at most
+// maxEntries items, evicting the least recently used entry when full.
+// A maxEntries of zero or less means unbounded.
+func NewWithLimit(ttl time.Duration, maxEntries int) *MemoryCache {
+	c := &MemoryCache{
+		entries:    make(map[string]*list.Element),
+		recency:    list.New(),
+		maxEntries: maxEntries,
+		done:       make(chan struct{}),
+	}
+	c.ttl.Store(int64(ttl))
+
+	go c.cleanupExpired()
+
+	return c
+}
+
+// Get retrieves a value from the cache.
+// Returns the value and true if found and not expired, nil and false otherwise.
+func (c *MemoryCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	item := elem.Value.(*lruItem)
+	if item.entry.expired(time.Now()) {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.recency.MoveToFront(elem)
+	c.hits.Add(1)
+	return item.entry.Data, true
+}
+
+// Set stores a value in the cache with the default TTL.
+func (c *MemoryCache) Set(key string, data interface{}) {
+	c.SetWithTTL(key, data, DefaultExpiration)
+}
+
+// SetWithTTL stores a value in the cache with a specific TTL.
+// Use NoExpiration to keep the entry until it is invalidated,
+// or DefaultExpiration to use the cache's TTL.
+func (c *MemoryCache) SetWithTTL(key string, data interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := Entry{
+		Data:      data,
+		ExpiresAt: c.expiresAt(ttl),
+	}
+
+	if elem, exists := c.entries[key]; exists {
+		elem.Value.(*lruItem).entry = entry
+		c.recency.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.recency.PushFront(&lruItem{key: key, entry: entry})
+	c.evictOverflow()
+}
+
+// evictOverflow removes least recently used entries until the cache is
+// within maxEntries. Must be called with mu held.
+func (c *MemoryCache) evictOverflow() {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	for c.recency.Len() > c.maxEntries {
+		c.removeElement(c.recency.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// removeElement removes an element from both the map and the recency list.
+// Must be called with mu held.
+func (c *MemoryCache) removeElement(elem *list.Element) {
+	c.recency.Remove(elem)
+	delete(c.entries, elem.Value.(*lruItem).key)
+}
+
+// Touch extends the expiration of an existing entry to ttl from now.
+// Returns false if the key is missing or already expired.
+func (c *MemoryCache) Touch(key string, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return false
+	}
+
+	item := elem.Value.(*lruItem)
+	if item.entry.expired(time.Now()) {
+		return false
+	}
+
+	item.entry.ExpiresAt = c.expiresAt(ttl)
+	c.recency.MoveToFront(elem)
+	return true
+}
+
+// expiresAt converts a TTL into an absolute expiration time.
+func (c *MemoryCache) expiresAt(ttl time.Duration) time.Time {
+	switch {
+	case ttl == NoExpiration:
+		return time.Time{}
+	case ttl == DefaultExpiration:
+		ttl = time.Duration(c.ttl.Load())
+	}
+	return time.Now().Add(ttl)
+}
+
+// Invalidate removes specified keys from the cache.
+func (c *MemoryCache) Invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, exists := c.entries[key]; exists {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// InvalidatePrefix removes every key starting with prefix from the cache.
+func (c *MemoryCache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// InvalidateAll clears all entries from the cache.
+func (c *MemoryCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.recency.Init()
+}
+
+// Stats returns cache statistics.
+func (c *MemoryCache) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hits := c.hits.Load()
+	misses := c.misses.Load()
+	total := hits + misses
+
+	var hitRate float64
+	if total > 0 {
+		hitRate = float64(hits) / float64(total) * 100
+	}
+
+	return map[string]interface{}{
+		"backend":    "memory",
+		"hits":       hits,
+		"misses":     misses,
+		"total":      total,
+		"hitRate":    hitRate,
+		"entries":    len(c.entries),
+		"maxEntries": c.maxEntries,
+		"evictions":  c.evictions.Load(),
+		"ttl":        time.Duration(c.ttl.Load()).String(),
+	}
+}
+
+// SetTTL changes the default TTL of entries stored from now on.
+func (c *MemoryCache) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
+
+// Close stops the background cleanup goroutine. It is safe to call more than once.
+func (c *MemoryCache) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+func (c *MemoryCache) cleanupExpired() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		now := time.Now()
+		for _, elem := range c.entries {
+			if elem.Value.(*lruItem).entry.expired(now) {
+				c.removeElement(elem)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
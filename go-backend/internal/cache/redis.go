@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each Redis round trip so a slow Redis degrades to cache misses.
+const redisTimeout = 500 * time.Millisecond
+
+// RedisCache stores cached responses in Redis so they survive restarts
+// and are shared across replicas. Values are stored as JSON and returned
+// as json.RawMessage, which encodes back to the same JSON.
+// Redis errors are logged and treated as cache misses.
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+	ttl    atomic.Int64 // default TTL as a time.Duration
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// NewRedis creates a RedisCache with the specified default TTL.
+func NewRedis(client *redis.Client, ttl time.Duration) *RedisCache {
+	c := &RedisCache{
+		client: client,
+		prefix: "cache:",
+	}
+	c.ttl.Store(int64(ttl))
+	return c
+}
+
+// Ping checks that the Redis server can be reached.
+func (c *RedisCache) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return c.client.Ping(ctx).Err()
+}
+
+// Get retrieves a value from the cache.
+// Returns the value and true if found and not expired, nil and false otherwise.
+func (c *RedisCache) Get(key string) (interface{}, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.logError("get", err)
+		}
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	return json.RawMessage(data), true
+}
+
+// Set stores a value in the cache with the default TTL.
+func (c *RedisCache) Set(key string, data interface{}) {
+	c.SetWithTTL(key, data, DefaultExpiration)
+}
+
+// SetWithTTL stores a value in the cache with a specific TTL.
+// Use NoExpiration to keep the entry until it is invalidated,
+// or DefaultExpiration to use the cache's TTL.
+func (c *RedisCache) SetWithTTL(key string, data interface{}, ttl time.Duration) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		c.logError("marshal", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.client.Set(ctx, c.prefix+key, payload, c.expiration(ttl)).Err(); err != nil {
+		c.logError("set", err)
+	}
+}
+
+// Touch extends the expiration of an existing entry to ttl from now.
+// Returns false if the key is missing or already expired.
+func (c *RedisCache) Touch(key string, ttl time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	var (
+		ok  bool
+		err error
+	)
+	if ttl == NoExpiration {
+		ok, err = c.client.Persist(ctx, c.prefix+key).Result()
+		if err == nil && !ok {
+			// PERSIST returns false for keys without a TTL; check existence instead
+			var n int64
+			n, err = c.client.Exists(ctx, c.prefix+key).Result()
+			ok = n > 0
+		}
+	} else {
+		ok, err = c.client.Expire(ctx, c.prefix+key, c.expiration(ttl)).Result()
+	}
+	if err != nil {
+		c.logError("touch", err)
+		return false
+	}
+
+	return ok
+}
+
+// Invalidate removes specified keys from the cache.
+func (c *RedisCache) Invalidate(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.client.Del(ctx, prefixed...).Err(); err != nil {
+		c.logError("invalidate", err)
+	}
+}
+
+// InvalidatePrefix removes every key starting with prefix from the cache.
+func (c *RedisCache) InvalidatePrefix(prefix string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	iter := c.client.Scan(ctx, 0, globEscape(c.prefix+prefix)+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		c.logError("scan", err)
+		return
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		c.logError("invalidate prefix", err)
+	}
+}
+
+// InvalidateAll clears all entries from the cache.
+// Only keys under this cache's prefix are removed.
+func (c *RedisCache) InvalidateAll() {
+	c.InvalidatePrefix("")
+}
+
+// SetTTL changes the default TTL of entries stored from now on.
+func (c *RedisCache) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
+
+// Close closes the Redis client.
+func (c *RedisCache) Close() {
+	if err := c.client.Close(); err != nil {
+		c.logError("close", err)
+	}
+}
+
+// Stats returns cache statistics.
+// Hit and miss counters are local to this replica.
+func (c *RedisCache) Stats() map[string]interface{} {
+	hits := c.hits.Load()
+	misses := c.misses.Load()
+	total := hits + misses
+
+	var hitRate float64
+	if total > 0 {
+		hitRate = float64(hits) / float64(total) * 100
+	}
+
+	return map[string]interface{}{
+		"backend": "redis",
+		"hits":    hits,
+		"misses":  misses,
+		"total":   total,
+		"hitRate": hitRate,
+		"errors":  c.errors.Load(),
+		"ttl":     time.Duration(c.ttl.Load()).String(),
+	}
+}
+
+// expiration converts a TTL into a Redis expiration, where 0 means no expiry.
+func (c *RedisCache) expiration(ttl time.Duration) time.Duration {
+	switch ttl {
+	case NoExpiration:
+		return 0
+	case DefaultExpiration:
+		return time.Duration(c.ttl.Load())
+	}
+	return ttl
+}
+
+// globEscape escapes Redis glob metacharacters so s matches literally.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (c *RedisCache) logError(op string, err error) {
+	c.errors.Add(1)
+	log.Printf("Warning: Redis cache %s failed: %v", op, err)
+}
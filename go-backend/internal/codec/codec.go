@@ -0,0 +1,66 @@
+// Package codec encodes JSON values in other media types, such as XML and
+// MessagePack, and decodes them back, for clients that cannot speak JSON.
+//
+// Values are those encoding/json decodes into an interface{} with
+// UseNumber: nil, bool, json.Number, string, []interface{} and
+// map[string]interface{}. Decoding returns the same types, so a value
+// survives the round trip from JSON and back.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Codec encodes values in a media type and decodes them back.
+type Codec interface {
+	// MediaTypes returns the media types the codec reads and writes, the
+	// one to label responses with first.
+	MediaTypes() []string
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+// maxDepth bounds the nesting of decoded values, as encoding/json does.
+const maxDepth = 10000
+
+// errTooDeep is returned for values nested deeper than maxDepth.
+var errTooDeep = errors.New("codec: value nested too deeply")
+
+// Defaults returns the codecs the server offers besides JSON.
+func Defaults() []Codec {
+	return []Codec{XML{}, MessagePack{}}
+}
+
+// sortedKeys returns the keys of m in order, so encodings are stable.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// number returns the JSON number of a value that holds one.
+func number(v interface{}) (json.Number, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return v, true
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), true
+	case int:
+		return json.Number(strconv.Itoa(v)), true
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), true
+	}
+	return "", false
+}
+
+// unsupported returns the error for a value that is not a JSON value.
+func unsupported(v interface{}) error {
+	return fmt.Errorf("codec: unsupported value of type %T", v)
+}
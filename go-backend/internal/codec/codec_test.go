@@ -0,0 +1,119 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sample is a value with every type, decoded from JSON as clients send it.
+const sample = `{
+	"id": 7,
+	"title": "Write <docs> & ship",
+	"ratio": -0.0015,
+	"big": 18446744073709551615,
+	"negative": -40000,
+	"done": false,
+	"dueAt": null,
+	"tags": ["docs", ""],
+	"empty": [],
+	"summary": {"2026-03": 2, "in-progress": 1},
+	"settings": {},
+	"long": "` + "0123456789abcdef0123456789abcdef0123456789" + `"
+}`
+
+func decodeSample(t *testing.T) interface{} {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(sample))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("invalid sample: %v", err)
+	}
+	return v
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	want := decodeSample(t)
+	for _, c := range Defaults() {
+		encoded, err := c.Encode(want)
+		if err != nil {
+			t.Fatalf("%s: Encode failed: %v", c.MediaTypes()[0], err)
+		}
+		got, err := c.Decode(encoded)
+		if err != nil {
+			t.Fatalf("%s: Decode failed: %v", c.MediaTypes()[0], err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v back, got %v", c.MediaTypes()[0], want, got)
+		}
+	}
+}
+
+func TestXML_Format(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := XML{}.Encode(map[string]interface{}{
+		"id":      json.Number("7"),
+		"title":   "A & B",
+		"tags":    []interface{}{"docs"},
+		"dueAt":   nil,
+		"done":    true,
+		"2026-03": json.Number("2"),
+	})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<response><entry key="2026-03" type="number">2</entry><done type="boolean">true</done><dueAt nil="true"></dueAt>` +
+		`<id type="number">7</id><tags type="array"><item>docs</item></tags><title>A &amp; B</title></response>` + "\n"
+	if string(encoded) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, encoded)
+	}
+
+	// Clients may indent their documents and name the root as they like
+	got, err := XML{}.Decode([]byte(`<task>
+		<title>Write docs</title>
+		<userId type="number">2</userId>
+	</task>`))
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{"title": "Write docs", "userId": json.Number("2")}) {
+		t.Errorf("expected an indented document decoded, got %v, %v", got, err)
+	}
+
+	for _, invalid := range []string{"", "<a>", `<a type="number">seven</a>`, `<a type="date">x</a>`, `<a type="boolean">maybe</a>`} {
+		if _, err := (XML{}).Decode([]byte(invalid)); err == nil {
+			t.Errorf("expected %q rejected", invalid)
+		}
+	}
+}
+
+func TestMessagePack_Format(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := MessagePack{}.Encode(map[string]interface{}{"id": json.Number("7"), "ok": true, "n": json.Number("-300")})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	// A fixmap of 3: "id" 7, "n" int16 -300, "ok" true
+	want := []byte{0x83, 0xa2, 'i', 'd', 0x07, 0xa1, 'n', 0xd1, 0xfe, 0xd4, 0xa2, 'o', 'k', 0xc3}
+	if !bytes.Equal(encoded, want) {
+		t.Errorf("expected % x, got % x", want, encoded)
+	}
+
+	for name, invalid := range map[string][]byte{
+		"empty":         {},
+		"truncated":     {0xa5, 'a'},
+		"huge array":    {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"extension":     {0xd4, 0x01, 0x00},
+		"integer key":   {0x81, 0x01, 0x02},
+		"trailing data": {0xc0, 0xc0},
+	} {
+		if _, err := (MessagePack{}).Decode(invalid); err == nil {
+			t.Errorf("expected %s rejected", name)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MessagePack encodes values in the MessagePack binary format. Integers
+// are written in the smallest form that holds them and other numbers as
+// 64-bit floats. Decoding accepts every form of nil, booleans, integers,
+// floats, strings, binaries (read as strings), arrays and maps with string
+// keys; extension types are rejected.
+type MessagePack struct{}
+
+func (MessagePack) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack"}
+}
+
+func (MessagePack) Encode(v interface{}) ([]byte, error) {
+	return appendMsgpack(nil, v)
+}
+
+// appendMsgpack appends the encoding of v to b.
+func appendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []interface{}:
+		b = appendMsgpackLength(b, len(v), 0x90, 0xdc)
+		for _, item := range v {
+			var err error
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = appendMsgpackLength(b, len(v), 0x80, 0xde)
+		for _, key := range sortedKeys(v) {
+			b = appendMsgpackString(b, key)
+			var err error
+			if b, err = appendMsgpack(b, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	n, ok := number(v)
+	if !ok {
+		return nil, unsupported(v)
+	}
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return appendMsgpackInt(b, i), nil
+	}
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), u), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("codec: invalid number %q", n)
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f)), nil
+}
+
+// appendMsgpackInt appends i in the smallest integer form holding it.
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+// appendMsgpackString appends s as a string.
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgpackLength appends the header of an array or map of n
+// elements: fix is its fixed form and wide its 16-bit form, followed by
+// the 32-bit form.
+func appendMsgpackLength(b []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, wide), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, wide+1), uint32(n))
+}
+
+// errMsgpackShort is returned for data that ends in the middle of a value.
+var errMsgpackShort = errors.New("codec: MessagePack data ends unexpectedly")
+
+func (MessagePack) Decode(data []byte) (interface{}, error) {
+	d := msgpackDecoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("codec: MessagePack data has more than one value")
+	}
+	return v, nil
+}
+
+// msgpackDecoder reads values from data, starting at pos, depth arrays
+// and maps deep.
+type msgpackDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// next returns the next n bytes.
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// value reads the next value.
+func (d *msgpackDecoder) value() (interface{}, error) {
+	head, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := head[0]; {
+	case c <= 0x7f:
+		return json.Number(strconv.Itoa(int(c))), nil
+	case c >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(c)))), nil
+	case c&0xf0 == 0x80:
+		return d.fields(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.items(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.string(int(c & 0x1f))
+	}
+
+	switch c := head[0]; c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		// Binaries and strings with a 1, 2 or 4 byte length
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[c]
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		return d.string(int(min(n, math.MaxInt32)))
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return floatNumber(float64(math.Float32frombits(uint32(u))))
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return floatNumber(math.Float64frombits(u))
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from size bytes
+		shift := 64 - 8*size
+		return json.Number(strconv.FormatInt(int64(u<<shift)>>shift, 10)), nil
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.items(int(min(n, math.MaxInt32)))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.fields(int(min(n, math.MaxInt32)))
+	}
+	return nil, fmt.Errorf("codec: unsupported MessagePack type 0x%02x", head[0])
+}
+
+// string reads a string of n bytes.
+func (d *msgpackDecoder) string(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// items reads an array of n values.
+func (d *msgpackDecoder) items(n int) (interface{}, error) {
+	// Every value takes at least a byte, so a length beyond the data is
+	// not allocated
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	if d.depth++; d.depth > maxDepth {
+		return nil, errTooDeep
+	}
+	defer func() { d.depth-- }()
+	items := make([]interface{}, n)
+	for i := range items {
+		var err error
+		if items[i], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// fields reads a map of n string keys and their values.
+func (d *msgpackDecoder) fields(n int) (interface{}, error) {
+	if 2*n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	if d.depth++; d.depth > maxDepth {
+		return nil, errTooDeep
+	}
+	defer func() { d.depth-- }()
+	fields := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("codec: MessagePack map key %v is not a string", key)
+		}
+		if fields[name], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// floatNumber returns f as a JSON number. JSON has no infinities or NaN.
+func floatNumber(f float64) (interface{}, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("codec: %v cannot be sent as JSON", f)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
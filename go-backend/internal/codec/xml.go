@@ -0,0 +1,232 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// xmlRoot is the name of the element holding the value of a document.
+const xmlRoot = "response"
+
+// XML encodes values as XML elements named after their fields. Strings are
+// text, and other types are marked with a type attribute so they decode
+// back as they were:
+//
+//	<response>
+//	  <id type="number">7</id>
+//	  <title>Write docs</title>
+//	  <tags type="array"><item>docs</item></tags>
+//	  <dueAt nil="true"/>
+//	</response>
+//
+// Fields whose names are not XML names, such as "2026-03", are written as
+// <entry key="2026-03">. An empty object has type="object", so it is not
+// read as an empty string.
+type XML struct{}
+
+func (XML) MediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (XML) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXML(enc, xmlRoot, v); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// encodeXML writes v as an element named name.
+func encodeXML(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	attr := func(name, value string) {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+
+	var text string
+	var children func() error
+	switch v := v.(type) {
+	case nil:
+		attr("nil", "true")
+	case bool:
+		attr("type", "boolean")
+		text = strconv.FormatBool(v)
+	case string:
+		text = v
+	case []interface{}:
+		attr("type", "array")
+		children = func() error {
+			for _, item := range v {
+				if err := encodeXML(enc, "item", item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case map[string]interface{}:
+		if len(v) == 0 {
+			attr("type", "object")
+		}
+		children = func() error {
+			for _, key := range sortedKeys(v) {
+				if err := encodeXML(enc, key, v[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		n, ok := number(v)
+		if !ok {
+			return unsupported(v)
+		}
+		attr("type", "number")
+		text = n.String()
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if children != nil {
+		if err := children(); err != nil {
+			return err
+		}
+	} else if text != "" {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// isXMLName reports whether name can be used as an element name. Names
+// starting with "xml" are reserved.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (XML) Decode(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("codec: XML document has no element")
+			}
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			_, v, err := decodeXML(dec, start, 0)
+			return v, err
+		}
+	}
+}
+
+// decodeXML reads the rest of the element started by start, depth
+// elements deep, returning the name of the field it holds and its value.
+func decodeXML(dec *xml.Decoder, start xml.StartElement, depth int) (string, interface{}, error) {
+	if depth > maxDepth {
+		return "", nil, errTooDeep
+	}
+	name := start.Name.Local
+	var typ string
+	isNil := false
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "type":
+			typ = attr.Value
+		case "nil":
+			isNil = attr.Value == "true"
+		case "key":
+			if name == "entry" {
+				name = attr.Value
+			}
+		}
+	}
+
+	var text strings.Builder
+	var items []interface{}
+	fields := make(map[string]interface{})
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			key, value, err := decodeXML(dec, tok, depth+1)
+			if err != nil {
+				return "", nil, err
+			}
+			items = append(items, value)
+			fields[key] = value
+		case xml.EndElement:
+			v, err := xmlValue(typ, isNil, text.String(), items, fields)
+			if err != nil {
+				return "", nil, fmt.Errorf("codec: element <%s>: %w", start.Name.Local, err)
+			}
+			return name, v, nil
+		}
+	}
+}
+
+// xmlValue returns the value of an element from its attributes and
+// content.
+func xmlValue(typ string, isNil bool, text string, items []interface{}, fields map[string]interface{}) (interface{}, error) {
+	switch {
+	case isNil:
+		return nil, nil
+	case typ == "array":
+		if items == nil {
+			items = []interface{}{}
+		}
+		return items, nil
+	case typ == "object" || len(fields) > 0:
+		return fields, nil
+	case typ == "number":
+		n := strings.TrimSpace(text)
+		if !isJSONNumber(n) {
+			return nil, fmt.Errorf("invalid number %q", text)
+		}
+		return json.Number(n), nil
+	case typ == "boolean":
+		return strconv.ParseBool(strings.TrimSpace(text))
+	case typ == "" || typ == "string":
+		return text, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+// isJSONNumber reports whether s is a number as JSON writes them.
+func isJSONNumber(s string) bool {
+	return s != "" && (s[0] == '-' || s[0] >= '0' && s[0] <= '9') && json.Valid([]byte(s))
+}
@@ -0,0 +1,570 @@
+// Package config loads the server configuration from a YAML file,
+// environment variables and command-line flags, and validates it.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"go-backend/internal/report"
+	"go-backend/internal/store"
+)
+
+// Defaults of settings whose zero value means something else.
+const (
+	DefaultPort                 = "8080"
+	DefaultCacheTTL             = 5 * time.Minute
+	DefaultCacheMaxEntries      = 1000
+	DefaultRateLimitMaxClients  = 100000
+	DefaultAuthRateLimitWindow  = 15 * time.Minute
+	DefaultSandboxResetInterval = 1 * time.Hour
+)
+
+// Redacted replaces secrets in the settings returned by Config.Redacted.
+const Redacted = "REDACTED"
+
+// Store backends.
+const (
+	StoreFile     = "file"
+	StorePostgres = "postgres"
+)
+
+// Cache backends.
+const (
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+// Config is the configuration of the server. Its YAML form nests the
+// groups below, e.g.
+//
+//	server:
+//	  port: "8080"
+//	  requestTimeout: 30s
+//	cache:
+//	  ttl: 10m
+//	auth:
+//	  enabled: true
+//	  apiKeys: [secret-key-1]
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Store     StoreConfig     `yaml:"store"`
+	Cache     CacheConfig     `yaml:"cache"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Sandbox   SandboxConfig   `yaml:"sandbox"`
+	Reset     ResetConfig     `yaml:"reset"`
+	API       APIConfig       `yaml:"api"`
+	Jobs      JobsConfig      `yaml:"jobs"`
+	Reports   ReportsConfig   `yaml:"reports"`
+	Files     FilesConfig     `yaml:"files"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Digest    DigestConfig    `yaml:"digest"`
+}
+
+// ServerConfig configures the HTTP server. Zero limits and timeouts use
+// the handler's defaults.
+type ServerConfig struct {
+	Port              string        `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	RequestTimeout    time.Duration `yaml:"requestTimeout"`
+	MaxBodyBytes      int64         `yaml:"maxBodyBytes"`
+	MaxUploadBytes    int64         `yaml:"maxUploadBytes"`
+	CompressMinBytes  int           `yaml:"compressMinBytes"`
+	// MaxInFlight caps concurrent requests; zero is unlimited.
+	MaxInFlight int `yaml:"maxInFlight"`
+	// CORSOrigins are the origins browser scripts may call from; empty
+	// allows any.
+	CORSOrigins []string `yaml:"corsOrigins"`
+	// LogLevel is debug, info, warn or error.
+	LogLevel slog.Level `yaml:"logLevel"`
+	// DrainDelay is how long requests are still served on shutdown, with
+	// readiness checks failing.
+	DrainDelay time.Duration `yaml:"drainDelay"`
+}
+
+// TLSConfig configures HTTPS, with a certificate from CertFile and
+// KeyFile or obtained over ACME for Domains. Neither serves plain HTTP.
+type TLSConfig struct {
+	CertFile string   `yaml:"certFile"`
+	KeyFile  string   `yaml:"keyFile"`
+	Domains  []string `yaml:"domains"`
+	// CacheDir keeps obtained certificates; empty uses a certs directory
+	// next to the data file.
+	CacheDir string `yaml:"cacheDir"`
+	Email    string `yaml:"email"`
+	// RedirectPort, when set, serves plain HTTP redirects to HTTPS.
+	RedirectPort string `yaml:"redirectPort"`
+}
+
+// AuthConfig configures API key authentication.
+type AuthConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	APIKeys []string `yaml:"apiKeys"`
+	// AdminKeys may call the admin endpoints, whether or not Enabled is
+	// set. Without any, those endpoints are off.
+	AdminKeys []string `yaml:"adminKeys"`
+}
+
+// StoreConfig configures where data is kept: a JSON data file, or
+// PostgreSQL.
+type StoreConfig struct {
+	// Backend is StoreFile or StorePostgres.
+	Backend      string        `yaml:"backend"`
+	DataFile     string        `yaml:"dataFile"`
+	PersistDelay time.Duration `yaml:"persistDelay"`
+	CompactAfter int           `yaml:"compactAfter"`
+	DatabaseURL  string        `yaml:"databaseURL"`
+	// Pool settings; zero uses the store's defaults.
+	MaxConns        int           `yaml:"maxConns"`
+	MinConns        int           `yaml:"minConns"`
+	MaxConnLifetime time.Duration `yaml:"maxConnLifetime"`
+	MaxConnIdleTime time.Duration `yaml:"maxConnIdleTime"`
+	QueryTimeout    time.Duration `yaml:"queryTimeout"`
+}
+
+// CacheConfig configures the response cache.
+type CacheConfig struct {
+	// Backend is CacheMemory or CacheRedis.
+	Backend string        `yaml:"backend"`
+	TTL     time.Duration `yaml:"ttl"`
+	// MaxEntries bounds the in-memory cache.
+	MaxEntries int  `yaml:"maxEntries"`
+	Warm       bool `yaml:"warm"`
+}
+
+// RateLimitConfig configures rate limiting. Zero limits disable it.
+type RateLimitConfig struct {
+	// Requests is the number of requests allowed per minute per IP.
+	Requests int `yaml:"requests"`
+	// AuthAttempts is the number of password attempts allowed per
+	// AuthWindow, per IP and per account.
+	AuthAttempts int           `yaml:"authAttempts"`
+	AuthWindow   time.Duration `yaml:"authWindow"`
+	// MaxClients bounds the IPs each in-memory limiter tracks; zero is
+	// unbounded.
+	MaxClients int `yaml:"maxClients"`
+}
+
+// RedisConfig configures the Redis server shared by replicas for rate
+// limits and, optionally, the cache. An empty Addr means no Redis.
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// SandboxConfig configures the sandbox server, started when Port is set.
+type SandboxConfig struct {
+	Port          string        `yaml:"port"`
+	ResetInterval time.Duration `yaml:"resetInterval"`
+}
+
+// ResetConfig configures POST /api/admin/reset.
+type ResetConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Key     string `yaml:"key"`
+}
+
+// APIConfig configures how the API behaves for clients. Zero durations use
+// the handler's defaults.
+type APIConfig struct {
+	// DebugHeaders adds X-Cache and X-Response-Time-ms to responses.
+	DebugHeaders bool `yaml:"debugHeaders"`
+	// VersionPins and FieldNamingPins map API keys to the API version and
+	// field naming their clients get.
+	VersionPins     map[string]string `yaml:"versionPins"`
+	FieldNamingPins map[string]string `yaml:"fieldNamingPins"`
+	// IDObfuscationKey encodes the IDs clients see; empty shows plain IDs.
+	IDObfuscationKey string `yaml:"idObfuscationKey"`
+	// Roles are the roles users may have; empty allows the built-in ones.
+	Roles []string `yaml:"roles"`
+	// UnfurlLinks fetches previews of links in task descriptions.
+	UnfurlLinks        bool          `yaml:"unfurlLinks"`
+	EventHeartbeat     time.Duration `yaml:"eventHeartbeat"`
+	OperationRetention time.Duration `yaml:"operationRetention"`
+}
+
+// JobsConfig configures the background jobs. Zero intervals use the
+// handler's defaults; a zero StatsRefreshInterval computes stats per
+// request.
+type JobsConfig struct {
+	ReportRefreshInterval    time.Duration `yaml:"reportRefreshInterval"`
+	StatsRefreshInterval     time.Duration `yaml:"statsRefreshInterval"`
+	StatsHistoryInterval     time.Duration `yaml:"statsHistoryInterval"`
+	ConsistencyCheckInterval time.Duration `yaml:"consistencyCheckInterval"`
+}
+
+// ReportsConfig configures reports. Zero limits use the report package's
+// defaults.
+type ReportsConfig struct {
+	MaxRows        int `yaml:"maxRows"`
+	MaxEvaluations int `yaml:"maxEvaluations"`
+	MaxGroups      int `yaml:"maxGroups"`
+	// CapacityWeights are name=value pairs, as for
+	// report.ParseCapacityWeights; unset weights use the defaults.
+	CapacityWeights string `yaml:"capacityWeights"`
+}
+
+// FilesConfig configures attachments and their download URLs.
+type FilesConfig struct {
+	// Dir keeps attachments; empty uses an attachments directory next to
+	// the data file.
+	Dir string `yaml:"dir"`
+	// Attachments are scanned by the clamd daemon at ClamAVAddr or,
+	// failing that, the scan API at ScanURL.
+	ClamAVAddr string `yaml:"clamavAddr"`
+	ScanURL    string `yaml:"scanURL"`
+	// SigningKey signs download URLs; empty uses a key that changes on
+	// restart.
+	SigningKey      string `yaml:"signingKey"`
+	DownloadBaseURL string `yaml:"downloadBaseURL"`
+}
+
+// SMTPConfig configures the relay email is sent through. An empty Addr
+// logs mail instead.
+type SMTPConfig struct {
+	Addr     string `yaml:"addr"`
+	From     string `yaml:"from"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// DigestConfig configures the digest email; a zero Period uses the
+// digest package's default.
+type DigestConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Period  time.Duration `yaml:"period"`
+}
+
+// Default returns the configuration used where nothing else is set.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{Port: DefaultPort},
+		Store: StoreConfig{
+			Backend:      StoreFile,
+			DataFile:     store.DefaultDataPath,
+			PersistDelay: store.DefaultPersistDelay,
+			CompactAfter: store.DefaultCompactAfter,
+		},
+		Cache: CacheConfig{
+			Backend:    CacheMemory,
+			TTL:        DefaultCacheTTL,
+			MaxEntries: DefaultCacheMaxEntries,
+		},
+		RateLimit: RateLimitConfig{
+			AuthWindow: DefaultAuthRateLimitWindow,
+			MaxClients: DefaultRateLimitMaxClients,
+		},
+		Sandbox: SandboxConfig{ResetInterval: DefaultSandboxResetInterval},
+	}
+}
+
+// Loader loads the configuration, and loads it again when asked, e.g. to
+// pick up changes to the file while the server runs.
+type Loader struct {
+	fs     *flag.FlagSet
+	path   *string
+	flags  []*settingFlag
+	getenv func(string) string
+}
+
+// NewLoader returns a loader of the configuration from, in increasing
+// precedence: the defaults, the YAML file named by -config or
+// CONFIG_FILE, environment variables read with getenv, and flags. It
+// defines the flags on fs, which may define flags of its own; parse fs
+// before calling Load.
+func NewLoader(fs *flag.FlagSet, getenv func(string) string) *Loader {
+	l := &Loader{
+		fs:     fs,
+		path:   fs.String("config", "", "path of a YAML configuration file (env CONFIG_FILE)"),
+		flags:  make([]*settingFlag, len(settings)),
+		getenv: getenv,
+	}
+	for i, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		l.flags[i] = &settingFlag{isBool: s.isBool}
+		fs.Var(l.flags[i], s.flag, s.usage+" (env "+s.env+")")
+	}
+	return l
+}
+
+// Load returns the configuration, reading the file again. It is
+// validated; all problems found are returned together.
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+	path := *l.path
+	if path == "" {
+		path = l.getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if raw := l.getenv(s.env); raw != "" {
+			if err := s.set(cfg, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for i, s := range settings {
+		if l.flags[i] != nil && l.flags[i].isSet {
+			if err := s.set(cfg, l.flags[i].value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Load defines the configuration flags on fs, parses args and returns
+// the configuration; see NewLoader.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	l := NewLoader(fs, getenv)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return l.Load()
+}
+
+// loadFile overlays c with the settings in the YAML file at path. Unknown
+// settings are an error, so misspelled ones are not silently ignored.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Redacted returns the settings of c as they are named in the YAML file,
+// with secrets replaced by Redacted, to be shown to operators.
+func (c *Config) Redacted() (map[string]interface{}, error) {
+	redacted := *c
+	redact := func(secret *string) {
+		if *secret != "" {
+			*secret = Redacted
+		}
+	}
+	redact(&redacted.Store.DatabaseURL)
+	redact(&redacted.Redis.Password)
+	redact(&redacted.Reset.Key)
+	redact(&redacted.API.IDObfuscationKey)
+	redact(&redacted.Files.SigningKey)
+	redact(&redacted.SMTP.Password)
+	redactAll := func(secrets []string) []string {
+		masked := make([]string, len(secrets))
+		for i := range masked {
+			masked[i] = Redacted
+		}
+		return masked
+	}
+	redacted.Auth.APIKeys = redactAll(c.Auth.APIKeys)
+	redacted.Auth.AdminKeys = redactAll(c.Auth.AdminKeys)
+	redacted.API.VersionPins = redactKeys(c.API.VersionPins)
+	redacted.API.FieldNamingPins = redactKeys(c.API.FieldNamingPins)
+
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// redactKeys returns pins with the API keys shortened to their first four
+// characters, as clients are identified in usage statistics, so operators
+// can tell the pins apart.
+func redactKeys(pins map[string]string) map[string]string {
+	if pins == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(pins))
+	for key := range pins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	masked := make(map[string]string, len(pins))
+	for _, key := range keys {
+		prefix := ""
+		if len(key) > 4 {
+			prefix = key[:4]
+		}
+		name := prefix + "****"
+		// Keys sharing a prefix are kept apart
+		for _, taken := masked[name]; taken; _, taken = masked[name] {
+			name += "*"
+		}
+		masked[name] = pins[key]
+	}
+	return masked
+}
+
+// Validate reports every setting of c that is out of range or missing a
+// setting it needs.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port %q is not a port number", c.Server.Port)
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.requestTimeout", c.Server.RequestTimeout},
+		{"server.drainDelay", c.Server.DrainDelay},
+		{"store.persistDelay", c.Store.PersistDelay},
+		{"store.maxConnLifetime", c.Store.MaxConnLifetime},
+		{"store.maxConnIdleTime", c.Store.MaxConnIdleTime},
+		{"store.queryTimeout", c.Store.QueryTimeout},
+		{"rateLimit.authWindow", c.RateLimit.AuthWindow},
+		{"api.eventHeartbeat", c.API.EventHeartbeat},
+		{"api.operationRetention", c.API.OperationRetention},
+		{"jobs.reportRefreshInterval", c.Jobs.ReportRefreshInterval},
+		{"jobs.statsRefreshInterval", c.Jobs.StatsRefreshInterval},
+		{"jobs.statsHistoryInterval", c.Jobs.StatsHistoryInterval},
+		{"jobs.consistencyCheckInterval", c.Jobs.ConsistencyCheckInterval},
+		{"digest.period", c.Digest.Period},
+	} {
+		check(d.value >= 0, "%s must not be negative", d.name)
+	}
+	for _, n := range []struct {
+		name  string
+		value int64
+	}{
+		{"server.maxHeaderBytes", int64(c.Server.MaxHeaderBytes)},
+		{"server.maxBodyBytes", c.Server.MaxBodyBytes},
+		{"server.maxUploadBytes", c.Server.MaxUploadBytes},
+		{"server.compressMinBytes", int64(c.Server.CompressMinBytes)},
+		{"server.maxInFlight", int64(c.Server.MaxInFlight)},
+		{"store.compactAfter", int64(c.Store.CompactAfter)},
+		{"store.maxConns", int64(c.Store.MaxConns)},
+		{"store.minConns", int64(c.Store.MinConns)},
+		{"cache.maxEntries", int64(c.Cache.MaxEntries)},
+		{"rateLimit.requests", int64(c.RateLimit.Requests)},
+		{"rateLimit.authAttempts", int64(c.RateLimit.AuthAttempts)},
+		{"rateLimit.maxClients", int64(c.RateLimit.MaxClients)},
+		{"redis.db", int64(c.Redis.DB)},
+		{"reports.maxRows", int64(c.Reports.MaxRows)},
+		{"reports.maxEvaluations", int64(c.Reports.MaxEvaluations)},
+		{"reports.maxGroups", int64(c.Reports.MaxGroups)},
+	} {
+		check(n.value >= 0, "%s must not be negative", n.name)
+	}
+
+	check(!c.Auth.Enabled || len(c.Auth.APIKeys) > 0, "auth.enabled requires auth.apiKeys")
+	switch c.Store.Backend {
+	case StoreFile:
+		check(c.Store.DataFile != "", "store.dataFile is required with the file store")
+	case StorePostgres:
+		check(c.Store.DatabaseURL != "", "store.backend %q requires store.databaseURL", StorePostgres)
+		check(c.Store.MaxConns == 0 || c.Store.MinConns <= c.Store.MaxConns, "store.minConns must not exceed store.maxConns")
+	default:
+		check(false, "store.backend %q is not %q or %q", c.Store.Backend, StoreFile, StorePostgres)
+	}
+	switch c.Cache.Backend {
+	case CacheMemory:
+	case CacheRedis:
+		check(c.Redis.Addr != "", "cache.backend %q requires redis.addr", CacheRedis)
+	default:
+		check(false, "cache.backend %q is not %q or %q", c.Cache.Backend, CacheMemory, CacheRedis)
+	}
+	check(c.Cache.TTL > 0, "cache.ttl must be positive")
+	if c.Sandbox.Port != "" {
+		check(validPort(c.Sandbox.Port), "sandbox.port %q is not a port number", c.Sandbox.Port)
+		check(c.Sandbox.Port != c.Server.Port, "sandbox.port must differ from server.port")
+		check(c.Sandbox.ResetInterval > 0, "sandbox.resetInterval must be positive")
+	}
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.certFile and tls.keyFile must be set together")
+	check(c.TLS.CertFile == "" || len(c.TLS.Domains) == 0, "tls.domains cannot be set with tls.certFile")
+	if c.TLS.RedirectPort != "" {
+		check(c.TLS.CertFile != "" || len(c.TLS.Domains) > 0, "tls.redirectPort requires tls.certFile or tls.domains")
+		check(validPort(c.TLS.RedirectPort), "tls.redirectPort %q is not a port number", c.TLS.RedirectPort)
+		check(c.TLS.RedirectPort != c.Server.Port && c.TLS.RedirectPort != c.Sandbox.Port,
+			"tls.redirectPort must differ from server.port and sandbox.port")
+	}
+	check(!c.Reset.Enabled || c.Reset.Key != "", "reset.enabled requires reset.key")
+
+	for _, p := range []struct {
+		name string
+		pins map[string]string
+	}{
+		{"api.versionPins", c.API.VersionPins},
+		{"api.fieldNamingPins", c.API.FieldNamingPins},
+	} {
+		for key, value := range p.pins {
+			if key == "" || value == "" {
+				check(false, "%s must pin non-empty API keys to non-empty values", p.name)
+				break
+			}
+		}
+	}
+	if _, err := report.ParseCapacityWeights(c.Reports.CapacityWeights); err != nil {
+		check(false, "reports.capacityWeights: %v", err)
+	}
+	check(c.SMTP.Addr == "" || c.SMTP.From != "", "smtp.addr requires smtp.from")
+	check(c.Files.ScanURL == "" || validURL(c.Files.ScanURL), "files.scanURL %q is not an absolute URL", c.Files.ScanURL)
+	check(c.Files.DownloadBaseURL == "" || validURL(c.Files.DownloadBaseURL) || strings.HasPrefix(c.Files.DownloadBaseURL, "/"),
+		"files.downloadBaseURL %q is not an absolute URL or path", c.Files.DownloadBaseURL)
+	return errors.Join(errs...)
+}
+
+// validURL reports whether raw is an absolute HTTP or HTTPS URL.
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validPort reports whether port is a TCP port number.
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
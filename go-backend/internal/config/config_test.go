@@ -0,0 +1,291 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load loads the configuration from the file with content file, if any,
+// env and args.
+func load(t *testing.T, file string, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, func(name string) string { return env[name] })
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := load(t, "", nil)
+	if err != nil {
+		t.Fatalf("expected the defaults to be valid: %v", err)
+	}
+	if cfg.Server.Port != DefaultPort || cfg.Cache.TTL != DefaultCacheTTL || cfg.Store.Backend != StoreFile ||
+		cfg.Server.LogLevel != slog.LevelInfo || cfg.Auth.Enabled {
+		t.Errorf("expected the defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	t.Parallel()
+
+	file := `
+server:
+  port: "9000"
+  requestTimeout: 45s
+  logLevel: warn
+  corsOrigins: [https://app.example.com]
+cache:
+  ttl: 10m
+rateLimit:
+  requests: 100
+auth:
+  enabled: true
+  apiKeys: [file-key]
+`
+	env := map[string]string{
+		"PORT":       "9001",
+		"RATE_LIMIT": "200",
+		"API_KEYS":   "env-key-1, env-key-2",
+		"DATA_DIR":   "/var/lib/app",
+	}
+	cfg, err := load(t, file, env, "-port", "9002", "-cache-ttl=1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != "9002" {
+		t.Errorf("expected the flag to win, got port %q", cfg.Server.Port)
+	}
+	if cfg.RateLimit.Requests != 200 || strings.Join(cfg.Auth.APIKeys, ",") != "env-key-1,env-key-2" {
+		t.Errorf("expected the environment over the file, got %+v %+v", cfg.RateLimit, cfg.Auth)
+	}
+	if cfg.Cache.TTL != time.Minute {
+		t.Errorf("expected the flag TTL, got %v", cfg.Cache.TTL)
+	}
+	if cfg.Server.RequestTimeout != 45*time.Second || cfg.Server.LogLevel != slog.LevelWarn ||
+		!cfg.Auth.Enabled || len(cfg.Server.CORSOrigins) != 1 {
+		t.Errorf("expected the file's settings, got %+v %+v", cfg.Server, cfg.Auth)
+	}
+	if cfg.Store.DataFile != filepath.Join("/var/lib/app", "data.json") {
+		t.Errorf("expected the data file in DATA_DIR, got %q", cfg.Store.DataFile)
+	}
+
+	cfg, err = load(t, "", map[string]string{"DATA_DIR": "/var/lib/app", "DATA_FILE": "/tmp/data.json", "AUTH_ENABLED": "true", "API_KEYS": "k"}, "-auth-enabled=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Store.DataFile != "/tmp/data.json" || cfg.Auth.Enabled {
+		t.Errorf("expected DATA_FILE over DATA_DIR and the flag over AUTH_ENABLED, got %q %v", cfg.Store.DataFile, cfg.Auth.Enabled)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "unparsable values",
+			env:  map[string]string{"RATE_LIMIT": "lots", "CACHE_TTL": "5"},
+			want: []string{"RATE_LIMIT", "CACHE_TTL"},
+		},
+		{
+			name: "unknown file setting",
+			file: "server:\n  prot: \"9000\"\n",
+			want: []string{"prot"},
+		},
+		{
+			name: "out of range and missing settings",
+			env: map[string]string{
+				"PORT":           "99999",
+				"MAX_BODY_BYTES": "-1",
+				"AUTH_ENABLED":   "true",
+				"STORE_BACKEND":  "postgres",
+				"CACHE_BACKEND":  "redis",
+			},
+			want: []string{"server.port", "server.maxBodyBytes", "auth.apiKeys", "store.databaseURL", "redis.addr"},
+		},
+		{
+			name: "unparsable feature settings",
+			env: map[string]string{
+				"OPERATION_RETENTION": "soon",
+				"API_VERSION_PINS":    "partner-key",
+			},
+			want: []string{"OPERATION_RETENTION", "API_VERSION_PINS"},
+		},
+		{
+			name: "invalid feature settings",
+			env: map[string]string{
+				"DIGEST_PERIOD":    "-1h",
+				"REPORT_MAX_ROWS":  "-5",
+				"CAPACITY_WEIGHTS": "overdue=-1",
+				"SMTP_ADDR":        "smtp.example.com:25",
+				"SCAN_URL":         "scanner:8080",
+			},
+			want: []string{"digest.period", "reports.maxRows", "reports.capacityWeights", "smtp.from", "files.scanURL"},
+		},
+		{
+			name: "incomplete TLS settings",
+			file: "tls:\n  certFile: cert.pem\n  domains: [example.com]\n  redirectPort: \"8080\"\n",
+			want: []string{"tls.keyFile", "tls.domains", "tls.redirectPort"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := load(t, tt.file, tt.env)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected the error to name %s, got %v", want, err)
+				}
+			}
+			if strings.Contains(err.Error(), "partner-key") {
+				t.Errorf("expected the API key left out of the error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_FeatureSettings(t *testing.T) {
+	t.Parallel()
+
+	file := `
+api:
+  versionPins:
+    partner-key: "1"
+  roles: [developer, qa]
+jobs:
+  statsHistoryInterval: 30m
+reports:
+  maxRows: 500
+files:
+  dir: /var/lib/app/files
+smtp:
+  addr: smtp.example.com:25
+  from: tasks@example.com
+`
+	env := map[string]string{
+		"API_VERSION_PINS":  "mobile-key=2, web-key=1",
+		"DIGEST_ENABLED":    "true",
+		"DOWNLOAD_BASE_URL": "https://cdn.example.com",
+	}
+	cfg, err := load(t, file, env, "-report-max-rows", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.API.VersionPins) != 2 || cfg.API.VersionPins["mobile-key"] != "2" {
+		t.Errorf("expected the environment's pins over the file's, got %v", cfg.API.VersionPins)
+	}
+	if strings.Join(cfg.API.Roles, ",") != "developer,qa" || cfg.Jobs.StatsHistoryInterval != 30*time.Minute ||
+		cfg.Files.Dir != "/var/lib/app/files" || cfg.SMTP.From != "tasks@example.com" {
+		t.Errorf("expected the file's settings, got %+v %+v %+v %+v", cfg.API, cfg.Jobs, cfg.Files, cfg.SMTP)
+	}
+	if cfg.Reports.MaxRows != 1000 || !cfg.Digest.Enabled || cfg.Files.DownloadBaseURL != "https://cdn.example.com" {
+		t.Errorf("expected the flag and environment settings, got %+v %+v %+v", cfg.Reports, cfg.Digest, cfg.Files)
+	}
+}
+
+func TestConfig_RestartNeeded(t *testing.T) {
+	t.Parallel()
+
+	running, err := load(t, "", map[string]string{"RATE_LIMIT": "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := load(t, "", map[string]string{"RATE_LIMIT": "50", "CACHE_TTL": "1m", "LOG_LEVEL": "error", "CORS_ORIGINS": "https://app.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := running.RestartNeeded(next); len(names) != 0 {
+		t.Errorf("expected reloadable changes only, got %v", names)
+	}
+	reloaded := running.Reloaded(next)
+	if reloaded.RateLimit.Requests != 50 || reloaded.Cache.TTL != time.Minute || reloaded.Server.LogLevel != slog.LevelError {
+		t.Errorf("expected the reloadable settings applied, got %+v", reloaded)
+	}
+
+	next, err = load(t, "", map[string]string{"PORT": "9000", "LOG_LEVEL": "debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := running.RestartNeeded(next)
+	if strings.Join(names, ",") != "server.port,rateLimit.requests" {
+		t.Errorf("expected the port and turning rate limiting off to need a restart, got %v", names)
+	}
+	reloaded = running.Reloaded(next)
+	if reloaded.Server.Port != DefaultPort || reloaded.RateLimit.Requests != 100 || reloaded.Server.LogLevel != slog.LevelDebug {
+		t.Errorf("expected only the log level applied, got %+v", reloaded)
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := load(t, "", map[string]string{
+		"API_KEYS":             "key-1,key-2",
+		"ADMIN_API_KEYS":       "admin-1",
+		"DATABASE_URL":         "postgres://app:hunter2@db/app",
+		"REDIS_PASSWORD":       "hunter3",
+		"CACHE_TTL":            "90s",
+		"ID_OBFUSCATION_KEY":   "hunter4",
+		"DOWNLOAD_SIGNING_KEY": "hunter5",
+		"SMTP_ADDR":            "smtp.example.com:25",
+		"SMTP_FROM":            "tasks@example.com",
+		"SMTP_PASSWORD":        "hunter6",
+		"API_VERSION_PINS":     "partner-key=1,partner-key-2=2",
+		"FIELD_NAMING_PINS":    "old-app-key=snake_case",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := cfg.Redacted()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := fmt.Sprint(settings)
+	for _, secret := range []string{"key-1", "key-2", "admin-1", "hunter2", "hunter3", "hunter4", "hunter5", "hunter6", "partner-key", "old-app-key"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %s redacted, got %s", secret, out)
+		}
+	}
+	cache, _ := settings["cache"].(map[string]interface{})
+	if cache["ttl"] != "1m30s" || len(settings["auth"].(map[string]interface{})["apiKeys"].([]interface{})) != 2 {
+		t.Errorf("expected the settings named as in the file, got %s", out)
+	}
+	api, _ := settings["api"].(map[string]interface{})
+	pins, _ := api["versionPins"].(map[string]interface{})
+	if len(pins) != 2 || pins["part****"] != "1" {
+		t.Errorf("expected the pinned keys shortened and kept apart, got %v", pins)
+	}
+	if cfg.Auth.APIKeys[0] != "key-1" {
+		t.Errorf("expected the configuration left as it was, got %v", cfg.Auth.APIKeys)
+	}
+}
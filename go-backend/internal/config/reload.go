@@ -0,0 +1,65 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable are the settings that can be changed while the server runs.
+var reloadable = map[string]bool{
+	"server.logLevel":        true,
+	"server.corsOrigins":     true,
+	"cache.ttl":              true,
+	"rateLimit.requests":     true,
+	"rateLimit.authAttempts": true,
+	"rateLimit.authWindow":   true,
+}
+
+// RestartNeeded returns the names of the settings that differ in next
+// from c, the configuration the server runs with, and that take a
+// restart to change. Rate limits can change while the server runs, but
+// rate limiting cannot be turned on or off.
+func (c *Config) RestartNeeded(next *Config) []string {
+	var names []string
+	diff(reflect.ValueOf(*c), reflect.ValueOf(*next), "", &names)
+	if (c.RateLimit.Requests > 0) != (next.RateLimit.Requests > 0) {
+		names = append(names, "rateLimit.requests")
+	}
+	if (c.RateLimit.AuthAttempts > 0) != (next.RateLimit.AuthAttempts > 0) {
+		names = append(names, "rateLimit.authAttempts")
+	}
+	return names
+}
+
+// Reloaded returns c with the settings that can change while the server
+// runs taken from next: the configuration the server runs with once next
+// is applied.
+func (c *Config) Reloaded(next *Config) *Config {
+	running := *c
+	running.Server.LogLevel = next.Server.LogLevel
+	running.Server.CORSOrigins = next.Server.CORSOrigins
+	running.Cache.TTL = next.Cache.TTL
+	if (c.RateLimit.Requests > 0) == (next.RateLimit.Requests > 0) {
+		running.RateLimit.Requests = next.RateLimit.Requests
+	}
+	if (c.RateLimit.AuthAttempts > 0) == (next.RateLimit.AuthAttempts > 0) {
+		running.RateLimit.AuthAttempts = next.RateLimit.AuthAttempts
+		running.RateLimit.AuthWindow = next.RateLimit.AuthWindow
+	}
+	return &running
+}
+
+// diff adds the names, prefixed with prefix, of the settings that differ
+// between the groups or configurations a and b and are not reloadable.
+func diff(a, b reflect.Value, prefix string, names *[]string) {
+	for i := 0; i < a.NumField(); i++ {
+		name := prefix + strings.Split(a.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if a.Field(i).Kind() == reflect.Struct {
+			diff(a.Field(i), b.Field(i), name+".", names)
+			continue
+		}
+		if !reloadable[name] && !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			*names = append(*names, name)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/store"
+)
+
+// setting is a configuration value that can be set by an environment
+// variable and, unless flag is empty, a command-line flag. Secrets have
+// no flag, as command lines are visible to other users of the machine.
+type setting struct {
+	env, flag, usage string
+	isBool           bool
+	set              func(c *Config, raw string) error
+}
+
+// settings are applied in order, so a later one wins when two set the
+// same value, as DATA_FILE does over DATA_DIR.
+var settings = []setting{
+	stringSetting("PORT", "port", "port to listen on", func(c *Config) *string { return &c.Server.Port }),
+	durationSetting("HTTP_READ_HEADER_TIMEOUT", "read-header-timeout", "time to read request headers", func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout }),
+	durationSetting("HTTP_READ_TIMEOUT", "read-timeout", "time to read a whole request", func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationSetting("HTTP_WRITE_TIMEOUT", "write-timeout", "time to write a response", func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	durationSetting("HTTP_IDLE_TIMEOUT", "idle-timeout", "time a keep-alive connection may idle", func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	intSetting("HTTP_MAX_HEADER_BYTES", "max-header-bytes", "maximum size of request headers", func(c *Config) *int { return &c.Server.MaxHeaderBytes }),
+	durationSetting("REQUEST_TIMEOUT", "request-timeout", "time a request may run", func(c *Config) *time.Duration { return &c.Server.RequestTimeout }),
+	int64Setting("MAX_BODY_BYTES", "max-body-bytes", "maximum size of request bodies", func(c *Config) *int64 { return &c.Server.MaxBodyBytes }),
+	int64Setting("MAX_UPLOAD_BYTES", "max-upload-bytes", "maximum size of uploads", func(c *Config) *int64 { return &c.Server.MaxUploadBytes }),
+	intSetting("COMPRESS_MIN_BYTES", "compress-min-bytes", "size from which responses are gzipped", func(c *Config) *int { return &c.Server.CompressMinBytes }),
+	intSetting("MAX_IN_FLIGHT", "max-in-flight", "maximum concurrent requests, 0 for unlimited", func(c *Config) *int { return &c.Server.MaxInFlight }),
+	listSetting("CORS_ORIGINS", "cors-origins", "comma-separated origins allowed to call the API", func(c *Config) *[]string { return &c.Server.CORSOrigins }),
+	durationSetting("SHUTDOWN_DRAIN_DELAY", "shutdown-drain-delay", "time requests are still served on shutdown", func(c *Config) *time.Duration { return &c.Server.DrainDelay }),
+	{env: "LOG_LEVEL", flag: "log-level", usage: "requests logged: debug, info, warn or error", set: func(c *Config, raw string) error {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(raw)); err != nil {
+			return err
+		}
+		c.Server.LogLevel = level
+		return nil
+	}},
+
+	stringSetting("TLS_CERT_FILE", "tls-cert-file", "PEM certificate file to serve HTTPS with", func(c *Config) *string { return &c.TLS.CertFile }),
+	stringSetting("TLS_KEY_FILE", "tls-key-file", "PEM private key file of the certificate", func(c *Config) *string { return &c.TLS.KeyFile }),
+	listSetting("TLS_DOMAINS", "tls-domains", "comma-separated domains to obtain certificates for over ACME", func(c *Config) *[]string { return &c.TLS.Domains }),
+	stringSetting("TLS_CACHE_DIR", "tls-cache-dir", "directory keeping obtained certificates", func(c *Config) *string { return &c.TLS.CacheDir }),
+	stringSetting("TLS_EMAIL", "tls-email", "contact email given to the certificate authority", func(c *Config) *string { return &c.TLS.Email }),
+	stringSetting("HTTP_REDIRECT_PORT", "http-redirect-port", "port redirecting plain HTTP to HTTPS", func(c *Config) *string { return &c.TLS.RedirectPort }),
+
+	boolSetting("AUTH_ENABLED", "auth-enabled", "require an API key", func(c *Config) *bool { return &c.Auth.Enabled }),
+	listSetting("API_KEYS", "", "comma-separated API keys", func(c *Config) *[]string { return &c.Auth.APIKeys }),
+	listSetting("ADMIN_API_KEYS", "", "comma-separated API keys that may call the admin endpoints", func(c *Config) *[]string { return &c.Auth.AdminKeys }),
+
+	stringSetting("STORE_BACKEND", "store-backend", "where data is kept: file or postgres", func(c *Config) *string { return &c.Store.Backend }),
+	{env: "DATA_DIR", usage: "directory of the data file", set: func(c *Config, raw string) error {
+		c.Store.DataFile = filepath.Join(raw, filepath.Base(store.DefaultDataPath))
+		return nil
+	}},
+	stringSetting("DATA_FILE", "data-file", "path of the JSON data file", func(c *Config) *string { return &c.Store.DataFile }),
+	durationSetting("PERSIST_DELAY", "persist-delay", "time data file writes are deferred", func(c *Config) *time.Duration { return &c.Store.PersistDelay }),
+	intSetting("PERSIST_COMPACT_AFTER", "persist-compact-after", "journal entries before compaction", func(c *Config) *int { return &c.Store.CompactAfter }),
+	stringSetting("DATABASE_URL", "", "PostgreSQL connection URL", func(c *Config) *string { return &c.Store.DatabaseURL }),
+	intSetting("DB_MAX_CONNS", "db-max-conns", "maximum PostgreSQL connections", func(c *Config) *int { return &c.Store.MaxConns }),
+	intSetting("DB_MIN_CONNS", "db-min-conns", "minimum PostgreSQL connections", func(c *Config) *int { return &c.Store.MinConns }),
+	durationSetting("DB_MAX_CONN_LIFETIME", "db-max-conn-lifetime", "time before connections are recycled", func(c *Config) *time.Duration { return &c.Store.MaxConnLifetime }),
+	durationSetting("DB_MAX_CONN_IDLE_TIME", "db-max-conn-idle-time", "time before idle connections are closed", func(c *Config) *time.Duration { return &c.Store.MaxConnIdleTime }),
+	durationSetting("DB_QUERY_TIMEOUT", "db-query-timeout", "time a query may run", func(c *Config) *time.Duration { return &c.Store.QueryTimeout }),
+
+	stringSetting("CACHE_BACKEND", "cache-backend", "response cache: memory or redis", func(c *Config) *string { return &c.Cache.Backend }),
+	durationSetting("CACHE_TTL", "cache-ttl", "time responses are cached", func(c *Config) *time.Duration { return &c.Cache.TTL }),
+	intSetting("CACHE_MAX_ENTRIES", "cache-max-entries", "maximum in-memory cache entries", func(c *Config) *int { return &c.Cache.MaxEntries }),
+	boolSetting("CACHE_WARM", "cache-warm", "pre-populate the cache", func(c *Config) *bool { return &c.Cache.Warm }),
+
+	intSetting("RATE_LIMIT", "rate-limit", "requests per minute per IP, 0 for unlimited", func(c *Config) *int { return &c.RateLimit.Requests }),
+	intSetting("AUTH_RATE_LIMIT", "auth-rate-limit", "password attempts per window, 0 for unlimited", func(c *Config) *int { return &c.RateLimit.AuthAttempts }),
+	durationSetting("AUTH_RATE_LIMIT_WINDOW", "auth-rate-limit-window", "window of password attempts", func(c *Config) *time.Duration { return &c.RateLimit.AuthWindow }),
+	intSetting("RATE_LIMIT_MAX_CLIENTS", "rate-limit-max-clients", "IPs tracked per in-memory limiter", func(c *Config) *int { return &c.RateLimit.MaxClients }),
+
+	stringSetting("REDIS_ADDR", "redis-addr", "Redis address (host:port)", func(c *Config) *string { return &c.Redis.Addr }),
+	stringSetting("REDIS_USERNAME", "redis-username", "Redis username", func(c *Config) *string { return &c.Redis.Username }),
+	stringSetting("REDIS_PASSWORD", "", "Redis password", func(c *Config) *string { return &c.Redis.Password }),
+	intSetting("REDIS_DB", "redis-db", "Redis database number", func(c *Config) *int { return &c.Redis.DB }),
+
+	stringSetting("SANDBOX_PORT", "sandbox-port", "port of the sandbox server", func(c *Config) *string { return &c.Sandbox.Port }),
+	durationSetting("SANDBOX_RESET_INTERVAL", "sandbox-reset-interval", "time between sandbox resets", func(c *Config) *time.Duration { return &c.Sandbox.ResetInterval }),
+
+	boolSetting("DATA_RESET_ENABLED", "data-reset-enabled", "enable POST /api/admin/reset", func(c *Config) *bool { return &c.Reset.Enabled }),
+	stringSetting("DATA_RESET_KEY", "", "key required by POST /api/admin/reset", func(c *Config) *string { return &c.Reset.Key }),
+
+	boolSetting("DEBUG_HEADERS", "debug-headers", "add X-Cache and X-Response-Time-ms headers", func(c *Config) *bool { return &c.API.DebugHeaders }),
+	pinsSetting("API_VERSION_PINS", "comma-separated apiKey=version pairs", func(c *Config) *map[string]string { return &c.API.VersionPins }),
+	pinsSetting("FIELD_NAMING_PINS", "comma-separated apiKey=naming pairs", func(c *Config) *map[string]string { return &c.API.FieldNamingPins }),
+	stringSetting("ID_OBFUSCATION_KEY", "", "key the IDs clients see are encoded with", func(c *Config) *string { return &c.API.IDObfuscationKey }),
+	listSetting("ALLOWED_ROLES", "allowed-roles", "comma-separated roles users may have", func(c *Config) *[]string { return &c.API.Roles }),
+	boolSetting("UNFURL_LINKS", "unfurl-links", "fetch previews of links in task descriptions", func(c *Config) *bool { return &c.API.UnfurlLinks }),
+	durationSetting("EVENT_HEARTBEAT_INTERVAL", "event-heartbeat-interval", "time between heartbeats of idle event streams", func(c *Config) *time.Duration { return &c.API.EventHeartbeat }),
+	durationSetting("OPERATION_RETENTION", "operation-retention", "time finished async operations can be polled", func(c *Config) *time.Duration { return &c.API.OperationRetention }),
+
+	durationSetting("REPORT_REFRESH_INTERVAL", "report-refresh-interval", "time between recomputing materialized reports", func(c *Config) *time.Duration { return &c.Jobs.ReportRefreshInterval }),
+	durationSetting("STATS_REFRESH_INTERVAL", "stats-refresh-interval", "time between recomputing stats, 0 for per request", func(c *Config) *time.Duration { return &c.Jobs.StatsRefreshInterval }),
+	durationSetting("STATS_HISTORY_INTERVAL", "stats-history-interval", "time between stats snapshots", func(c *Config) *time.Duration { return &c.Jobs.StatsHistoryInterval }),
+	durationSetting("CONSISTENCY_CHECK_INTERVAL", "consistency-check-interval", "time between consistency checks", func(c *Config) *time.Duration { return &c.Jobs.ConsistencyCheckInterval }),
+
+	intSetting("REPORT_MAX_ROWS", "report-max-rows", "maximum rows a custom report may scan", func(c *Config) *int { return &c.Reports.MaxRows }),
+	intSetting("REPORT_MAX_EVALUATIONS", "report-max-evaluations", "maximum expression evaluations of a custom report", func(c *Config) *int { return &c.Reports.MaxEvaluations }),
+	intSetting("REPORT_MAX_GROUPS", "report-max-groups", "maximum groups of a custom report", func(c *Config) *int { return &c.Reports.MaxGroups }),
+	stringSetting("CAPACITY_WEIGHTS", "capacity-weights", "comma-separated name=value capacity report weights", func(c *Config) *string { return &c.Reports.CapacityWeights }),
+
+	stringSetting("ATTACHMENTS_DIR", "attachments-dir", "directory attachments are stored in", func(c *Config) *string { return &c.Files.Dir }),
+	stringSetting("CLAMAV_ADDR", "clamav-addr", "clamd address (host:port) to scan attachments with", func(c *Config) *string { return &c.Files.ClamAVAddr }),
+	stringSetting("SCAN_URL", "scan-url", "scanning API to POST attachments to", func(c *Config) *string { return &c.Files.ScanURL }),
+	stringSetting("DOWNLOAD_SIGNING_KEY", "", "key download URLs are signed with", func(c *Config) *string { return &c.Files.SigningKey }),
+	stringSetting("DOWNLOAD_BASE_URL", "download-base-url", "prefix of signed download URLs", func(c *Config) *string { return &c.Files.DownloadBaseURL }),
+
+	stringSetting("SMTP_ADDR", "smtp-addr", "SMTP relay address (host:port)", func(c *Config) *string { return &c.SMTP.Addr }),
+	stringSetting("SMTP_FROM", "smtp-from", "sender address of email", func(c *Config) *string { return &c.SMTP.From }),
+	stringSetting("SMTP_USERNAME", "smtp-username", "SMTP username", func(c *Config) *string { return &c.SMTP.Username }),
+	stringSetting("SMTP_PASSWORD", "", "SMTP password", func(c *Config) *string { return &c.SMTP.Password }),
+
+	boolSetting("DIGEST_ENABLED", "digest-enabled", "send the digest email on a schedule", func(c *Config) *bool { return &c.Digest.Enabled }),
+	durationSetting("DIGEST_PERIOD", "digest-period", "time between digests and the period they cover", func(c *Config) *time.Duration { return &c.Digest.Period }),
+}
+
+func stringSetting(env, flag, usage string, field func(c *Config) *string) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, raw string) error {
+		*field(c) = raw
+		return nil
+	}}
+}
+
+func intSetting(env, flag, usage string, field func(c *Config) *int) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, raw string) error {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func int64Setting(env, flag, usage string, field func(c *Config) *int64) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, raw string) error {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func durationSetting(env, flag, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, raw string) error {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+// boolSetting is true only when set to "true", as the environment
+// variables it replaces were.
+func boolSetting(env, flag, usage string, field func(c *Config) *bool) setting {
+	return setting{env: env, flag: flag, usage: usage, isBool: true, set: func(c *Config, raw string) error {
+		*field(c) = raw == "true"
+		return nil
+	}}
+}
+
+// listSetting takes comma-separated values, without blanks.
+func listSetting(env, flag, usage string, field func(c *Config) *[]string) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, raw string) error {
+		var values []string
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		*field(c) = values
+		return nil
+	}}
+}
+
+// pinsSetting takes comma-separated apiKey=value pairs. It has no flag, as
+// API keys are secrets.
+func pinsSetting(env, usage string, field func(c *Config) *map[string]string) setting {
+	return setting{env: env, usage: usage, set: func(c *Config, raw string) error {
+		pins := make(map[string]string)
+		for i, pair := range strings.Split(raw, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" || value == "" {
+				// The pair is left out of the error, as it holds an API key
+				return fmt.Errorf("pin %d is not apiKey=value", i+1)
+			}
+			pins[key] = value
+		}
+		*field(c) = pins
+		return nil
+	}}
+}
+
+// settingFlag is the flag.Value of a setting. It keeps the raw value, to
+// be applied over the environment only if the flag is given.
+type settingFlag struct {
+	value  string
+	isSet  bool
+	isBool bool
+}
+
+func (f *settingFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *settingFlag) Set(value string) error {
+	f.value, f.isSet = value, true
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be given as -name alone.
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.isBool
+}
@@ -0,0 +1,199 @@
+// Package consistency runs checks that compare copies of data, such as
+// cached responses, counters and saved files, with the data they are
+// derived from, on a schedule and on demand. It keeps the latest results
+// and totals across runs for the health check and for alerting.
+package consistency
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Defaults for Options.
+const (
+	DefaultInterval     = 15 * time.Minute
+	DefaultRecheckDelay = time.Second
+)
+
+// maxListed is how many discrepancies of a check a report lists.
+const maxListed = 20
+
+// Report statuses.
+const (
+	StatusOK           = "ok"
+	StatusInconsistent = "inconsistent"
+	StatusError        = "error"
+	StatusPending      = "pending"
+)
+
+// Check returns a description of each discrepancy it finds, or an error if
+// it cannot tell.
+type Check func(ctx context.Context) ([]string, error)
+
+// Options configure a Checker. Zero fields use the defaults.
+type Options struct {
+	// Interval is how often Schedule runs the checks.
+	Interval time.Duration
+	// RecheckDelay is how long a check that found discrepancies waits
+	// before looking again. Only discrepancies found both times are
+	// reported, so a change made while a check runs is not mistaken for
+	// one.
+	RecheckDelay time.Duration
+}
+
+// Checker runs registered checks and keeps their latest results.
+type Checker struct {
+	interval     time.Duration
+	recheckDelay time.Duration
+
+	mu     sync.Mutex
+	checks map[string]Check
+	last   model.ConsistencyReport
+	totals map[string]*model.ConsistencyCheck
+
+	// runMu serializes runs so a scheduled run and a manual one cannot
+	// record their results out of order.
+	runMu sync.Mutex
+}
+
+// New returns a checker with no checks.
+func New(opts Options) *Checker {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.RecheckDelay <= 0 {
+		opts.RecheckDelay = DefaultRecheckDelay
+	}
+	return &Checker{
+		interval:     opts.Interval,
+		recheckDelay: opts.RecheckDelay,
+		checks:       make(map[string]Check),
+		last:         model.ConsistencyReport{Status: StatusPending, Checks: []model.ConsistencyCheck{}},
+		totals:       make(map[string]*model.ConsistencyCheck),
+	}
+}
+
+// Register adds check under name.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+	c.totals[name] = &model.ConsistencyCheck{Name: name}
+}
+
+// Last returns the report of the latest run, with status StatusPending if
+// there has been none.
+func (c *Checker) Last() model.ConsistencyReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Run runs every check, in name order, and returns the report. Checks
+// that find discrepancies or fail are logged.
+func (c *Checker) Run(ctx context.Context) model.ConsistencyReport {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	c.mu.Lock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.Unlock()
+
+	report := model.ConsistencyReport{
+		Status:    StatusOK,
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+		Checks:    make([]model.ConsistencyCheck, 0, len(names)),
+	}
+	for i, name := range names {
+		result := model.ConsistencyCheck{Name: name, Status: StatusOK, Discrepancies: []string{}}
+		found, err := c.check(ctx, checks[i])
+		switch {
+		case err != nil:
+			result.Status, result.Error = StatusError, err.Error()
+			report.Status = StatusError
+			log.Printf("Warning: Consistency check %s failed: %v", name, err)
+		case len(found) > 0:
+			result.Status, result.Count = StatusInconsistent, len(found)
+			result.Discrepancies = found[:min(len(found), maxListed)]
+			if report.Status == StatusOK {
+				report.Status = StatusInconsistent
+			}
+			log.Printf("Warning: Consistency check %s found %d discrepancies, first: %s", name, len(found), found[0])
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, result := range report.Checks {
+		totals := c.totals[result.Name]
+		totals.TotalDiscrepancies += int64(result.Count)
+		if result.Status == StatusError {
+			totals.Failures++
+		}
+		report.Checks[i].TotalDiscrepancies = totals.TotalDiscrepancies
+		report.Checks[i].Failures = totals.Failures
+	}
+	report.Runs = c.last.Runs + 1
+	c.last = report
+	return report
+}
+
+// check runs check and, if it finds discrepancies, runs it again after
+// the recheck delay, returning those found both times.
+func (c *Checker) check(ctx context.Context, check Check) ([]string, error) {
+	first, err := check(ctx)
+	if err != nil || len(first) == 0 {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(c.recheckDelay):
+	}
+	second, err := check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	again := make(map[string]bool, len(second))
+	for _, d := range second {
+		again[d] = true
+	}
+	var found []string
+	for _, d := range first {
+		if again[d] {
+			found = append(found, d)
+		}
+	}
+	return found, nil
+}
+
+// Schedule runs the checks every interval until ctx is done. The first
+// run is after one interval, once caches have filled.
+func (c *Checker) Schedule(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Run(ctx)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChecker_Run(t *testing.T) {
+	t.Parallel()
+
+	c := New(Options{RecheckDelay: time.Millisecond})
+	if last := c.Last(); last.Status != StatusPending || last.Runs != 0 {
+		t.Errorf("expected no run yet, got %+v", last)
+	}
+
+	var runs int
+	c.Register("cache", func(ctx context.Context) ([]string, error) {
+		runs++
+		// Only the first discrepancy is still there on the second look
+		if runs%2 == 1 {
+			return []string{"users differ", "tasks differ"}, nil
+		}
+		return []string{"users differ"}, nil
+	})
+	c.Register("stats", func(ctx context.Context) ([]string, error) {
+		var found []string
+		for i := 0; i < 25; i++ {
+			found = append(found, fmt.Sprintf("counter %d differs", i))
+		}
+		return found, nil
+	})
+	c.Register("persistence", func(ctx context.Context) ([]string, error) {
+		return nil, errors.New("disk unreadable")
+	})
+	c.Register("empty", func(ctx context.Context) ([]string, error) {
+		return nil, nil
+	})
+
+	c.Run(context.Background())
+	report := c.Run(context.Background())
+	if report.Status != StatusError || report.Runs != 2 || len(report.Checks) != 4 || report.CheckedAt == "" {
+		t.Fatalf("expected two runs of four checks ending in an error, got %+v", report)
+	}
+	byName := make(map[string]int)
+	for i, check := range report.Checks {
+		byName[check.Name] = i
+	}
+
+	if cache := report.Checks[byName["cache"]]; cache.Status != StatusInconsistent || cache.Count != 1 || cache.Discrepancies[0] != "users differ" || cache.TotalDiscrepancies != 2 {
+		t.Errorf("expected only the lasting discrepancy, counted over both runs, got %+v", cache)
+	}
+	if stats := report.Checks[byName["stats"]]; stats.Count != 25 || len(stats.Discrepancies) != maxListed {
+		t.Errorf("expected 25 discrepancies counted and %d listed, got %+v", maxListed, stats)
+	}
+	if persistence := report.Checks[byName["persistence"]]; persistence.Status != StatusError || persistence.Error != "disk unreadable" || persistence.Failures != 2 {
+		t.Errorf("expected the failure reported and counted, got %+v", persistence)
+	}
+	if empty := report.Checks[byName["empty"]]; empty.Status != StatusOK || empty.Discrepancies == nil {
+		t.Errorf("expected an ok check with an empty list, got %+v", empty)
+	}
+	if last := c.Last(); last.Runs != 2 || last.Status != report.Status {
+		t.Errorf("expected Last to return the latest report, got %+v", last)
+	}
+}
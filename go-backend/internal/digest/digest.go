@@ -0,0 +1,218 @@
+// Package digest compiles and sends the weekly digest email: for each
+// user, the tasks they completed and were newly assigned during the
+// period, and those overdue at its end.
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/internal/mail"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+	"go-backend/internal/timezone"
+)
+
+// DefaultPeriod is how often digests are sent and how far back they look.
+const DefaultPeriod = 7 * 24 * time.Hour
+
+// sendTimeout bounds sending one digest.
+const sendTimeout = 30 * time.Second
+
+// Options select what a run does.
+type Options struct {
+	// UserID limits the run to one user when non-zero.
+	UserID int
+	// DryRun compiles digests and returns them without sending any.
+	DryRun bool
+}
+
+// Job compiles digests from a store and sends them through a mailer.
+type Job struct {
+	store  store.Backend
+	mailer mail.Mailer
+	period time.Duration
+	// mu serializes runs that send, so a scheduled run and a manual one
+	// cannot mail the same digest twice at once.
+	mu sync.Mutex
+}
+
+// New returns a job covering period; zero uses DefaultPeriod.
+func New(s store.Backend, m mail.Mailer, period time.Duration) *Job {
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+	return &Job{store: s, mailer: m, period: period}
+}
+
+// Schedule runs the job every period until ctx is done. Each run covers
+// the period before it. Runs are not coordinated across replicas, so
+// schedule the job on one only.
+func (j *Job) Schedule(ctx context.Context) {
+	ticker := time.NewTicker(j.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			result, err := j.Run(ctx, now, Options{})
+			if err != nil {
+				log.Printf("Warning: Digest not sent: %v", err)
+				continue
+			}
+			log.Printf("Digest sent to %d users (%d opted out, %d with nothing to report, %d failed)",
+				result.Sent, result.OptedOut, result.Empty, result.Failed)
+		}
+	}
+}
+
+// Run compiles the digests for the period ending at until and, unless
+// opts.DryRun is set, mails them. Users who opted out or have nothing to
+// report are skipped. A failed send is logged and counted; the run goes
+// on with the next user. It fails, sending nothing, if the users and
+// tasks cannot be read.
+func (j *Job) Run(ctx context.Context, until time.Time, opts Options) (model.DigestRunResponse, error) {
+	if !opts.DryRun {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+	}
+
+	until = until.UTC()
+	since := until.Add(-j.period)
+	result := model.DigestRunResponse{
+		Since:  since.Format(time.RFC3339),
+		Until:  until.Format(time.RFC3339),
+		DryRun: opts.DryRun,
+	}
+
+	tasks, err := j.store.GetTasks(ctx, "", "")
+	if err != nil {
+		return result, err
+	}
+	users, err := j.store.GetUsers(ctx)
+	if err != nil {
+		return result, err
+	}
+	byUser := make(map[int][]model.Task)
+	for _, task := range tasks {
+		byUser[task.UserID] = append(byUser[task.UserID], task)
+	}
+
+	for _, user := range users {
+		if opts.UserID != 0 && user.ID != opts.UserID {
+			continue
+		}
+		if user.Preferences.DigestOptOut {
+			result.OptedOut++
+			continue
+		}
+		digest := Compile(user, byUser[user.ID], since, until)
+		if Empty(digest) {
+			result.Empty++
+			continue
+		}
+		if opts.DryRun {
+			result.Digests = append(result.Digests, digest)
+			continue
+		}
+		if ctx.Err() != nil {
+			result.Failed++
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := j.mailer.Send(sendCtx, Message(user, digest, since, until))
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Failed to send digest to user %d: %v", user.ID, err)
+			result.Failed++
+			continue
+		}
+		result.Sent++
+	}
+	return result, nil
+}
+
+// Compile returns the digest of user's tasks for the period [since,
+// until).
+func Compile(user model.User, tasks []model.Task, since, until time.Time) model.Digest {
+	digest := model.Digest{
+		UserID:    user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Completed: []model.Task{},
+		Assigned:  []model.Task{},
+		Overdue:   []model.Task{},
+	}
+	within := func(t time.Time) bool {
+		return !t.Before(since) && t.Before(until)
+	}
+
+	for _, task := range tasks {
+		if task.UserID != user.ID {
+			continue
+		}
+		if task.CompletedAt != nil && within(*task.CompletedAt) {
+			digest.Completed = append(digest.Completed, task)
+		}
+		if within(task.AssignedAt) {
+			digest.Assigned = append(digest.Assigned, task)
+		}
+		if task.Overdue(until) {
+			digest.Overdue = append(digest.Overdue, task)
+		}
+	}
+	return digest
+}
+
+// Empty reports whether a digest has nothing to report.
+func Empty(d model.Digest) bool {
+	return len(d.Completed) == 0 && len(d.Assigned) == 0 && len(d.Overdue) == 0
+}
+
+// Message renders a digest as an email to user, with dates in the user's
+// time zone.
+func Message(user model.User, d model.Digest, since, until time.Time) mail.Message {
+	loc := time.UTC
+	if user.Timezone != "" {
+		if l, err := timezone.Load(user.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n", user.Name)
+	fmt.Fprintf(&b, "Here is your task digest for %s to %s.\n",
+		since.In(loc).Format("Mon Jan 2"), until.In(loc).Format("Mon Jan 2, 2006"))
+
+	section := func(title string, tasks []model.Task) {
+		if len(tasks) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s (%d)\n", title, len(tasks))
+		for _, task := range tasks {
+			fmt.Fprintf(&b, "  - %s", task.Title)
+			if task.DueAt != nil {
+				fmt.Fprintf(&b, " (due %s)", task.DueAt.In(loc).Format("Mon Jan 2, 15:04 MST"))
+			}
+			b.WriteString("\n")
+		}
+	}
+	section("Completed", d.Completed)
+	section("Newly assigned", d.Assigned)
+	section("Overdue", d.Overdue)
+
+	b.WriteString("\nTo stop these emails, turn on digestOptOut in your preferences.\n")
+
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Your weekly task digest",
+		Body:    b.String(),
+	}
+}
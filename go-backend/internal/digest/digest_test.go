@@ -0,0 +1,137 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-backend/internal/mail"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+)
+
+// recordingMailer keeps sent messages and fails for addresses in fail.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+	fail map[string]bool
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail[msg.To] {
+		return errors.New("relay unavailable")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var (
+	until = time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	since = until.Add(-DefaultPeriod)
+)
+
+func at(t time.Time) *time.Time {
+	return &t
+}
+
+func newTestStore() *store.Store {
+	old := since.Add(-24 * time.Hour)
+	recent := since.Add(24 * time.Hour)
+	return store.NewWithData(
+		[]model.User{
+			{ID: 1, Name: "Ann", Email: "ann@example.com", Timezone: "Europe/Berlin"},
+			{ID: 2, Name: "Ben", Email: "ben@example.com", Preferences: model.UserPreferences{DigestOptOut: true}},
+			{ID: 3, Name: "Cy", Email: "cy@example.com"},
+			{ID: 4, Name: "Di", Email: "di@example.com"},
+		},
+		[]model.Task{
+			{ID: 1, Title: "Shipped", Status: "completed", UserID: 1, CreatedAt: old, AssignedAt: old, CompletedAt: &recent},
+			{ID: 2, Title: "New", Status: "pending", UserID: 1, CreatedAt: recent, AssignedAt: recent},
+			{ID: 3, Title: "Late", Status: "in-progress", UserID: 1, CreatedAt: old, AssignedAt: old, DueAt: at(until.Add(-time.Hour))},
+			{ID: 4, Title: "Done long ago", Status: "completed", UserID: 1, CreatedAt: old, AssignedAt: old, CompletedAt: &old},
+			{ID: 5, Title: "Opted out", Status: "pending", UserID: 2, CreatedAt: recent, AssignedAt: recent},
+			{ID: 6, Title: "Quiet", Status: "pending", UserID: 3, CreatedAt: old, AssignedAt: old},
+			{ID: 7, Title: "Assigned", Status: "pending", UserID: 4, CreatedAt: recent, AssignedAt: recent},
+		},
+	)
+}
+
+func taskIDs(tasks []model.Task) []int {
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore()
+	found, _ := s.GetUserByID(context.Background(), 1)
+	user := *found
+	tasks, _ := s.GetTasks(context.Background(), "", "")
+	d := Compile(user, tasks, since, until)
+
+	if got := taskIDs(d.Completed); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected task 1 completed during the period, got %v", got)
+	}
+	if got := taskIDs(d.Assigned); len(got) != 1 || got[0] != 2 {
+		t.Errorf("expected task 2 newly assigned, got %v", got)
+	}
+	if got := taskIDs(d.Overdue); len(got) != 1 || got[0] != 3 {
+		t.Errorf("expected task 3 overdue, got %v", got)
+	}
+	if Empty(d) {
+		t.Error("expected the digest not to be empty")
+	}
+}
+
+func TestJob_Run(t *testing.T) {
+	t.Parallel()
+
+	mailer := &recordingMailer{fail: map[string]bool{"di@example.com": true}}
+	job := New(newTestStore(), mailer, 0)
+
+	result, err := job.Run(context.Background(), until, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Sent != 1 || result.OptedOut != 1 || result.Empty != 1 || result.Failed != 1 {
+		t.Errorf("expected 1 sent, opted out, empty and failed, got %+v", result)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "ann@example.com" {
+		t.Fatalf("expected one digest to ann@example.com, got %+v", mailer.sent)
+	}
+	body := mailer.sent[0].Body
+	for _, want := range []string{"Completed (1)", "Newly assigned (1)", "Overdue (1)", "Late (due Mon Mar 9, 08:00 CET)"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the digest to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestJob_Run_DryRun(t *testing.T) {
+	t.Parallel()
+
+	mailer := &recordingMailer{}
+	job := New(newTestStore(), mailer, 0)
+
+	result, err := job.Run(context.Background(), until, Options{UserID: 4, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mailer.sent) != 0 {
+		t.Errorf("expected a dry run not to send, got %d messages", len(mailer.sent))
+	}
+	if len(result.Digests) != 1 || result.Digests[0].UserID != 4 {
+		t.Errorf("expected only user 4's digest, got %+v", result.Digests)
+	}
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"go-backend/internal/model"
+)
+
+// Entities whose changes are emitted on a Bus.
+const (
+	EntityTask         = "task"
+	EntityUser         = "user"
+	EntityProject      = "project"
+	EntityAnnouncement = "announcement"
+	EntityShare        = "share"
+	EntityAttachment   = "attachment"
+	EntityWebhook      = "webhook"
+	// A backup or a fixture profile replaces all data, with an
+	// ActionRestore or an ActionReset.
+	EntityBackup  = "backup"
+	EntityFixture = "fixture"
+)
+
+// Change actions. They are also the actions of the audit trail.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionReset   = "reset"
+)
+
+// Change is one mutation of an entity, emitted once the store has made it.
+type Change struct {
+	Entity string
+	Action string
+	// ID is the entity's ID, or the name of a fixture profile.
+	ID string
+	// Actor is the client that made the change, as middleware.ClientID
+	// names it.
+	Actor string
+	// Before and After are the entity's values, e.g. a model.Task, before
+	// and after the change: Before is nil for a created entity and After
+	// for a deleted one. Both are copies the store no longer changes.
+	Before interface{}
+	After  interface{}
+	// Fields, if not nil, lists the fields changed for changes Before and
+	// After cannot show, such as a new password or a wholesale replace.
+	Fields []model.FieldChange
+}
+
+// Handler is called with each change emitted on a Bus.
+type Handler func(ctx context.Context, c Change)
+
+// Bus runs side effects of changes, such as auditing and cache
+// invalidation, for every mutation, so code making a change only emits
+// it. Handlers run synchronously, in the order they subscribed, before
+// Emit returns: the response to a change is sent after its side effects.
+type Bus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers []subscriber
+}
+
+type subscriber struct {
+	id      int
+	name    string
+	handler Handler
+}
+
+// NewBus returns a bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls handler with every change emitted from now on, until the
+// returned function is called. name identifies the handler in logs.
+func (b *Bus) Subscribe(name string, handler Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.handlers = append(b.handlers, subscriber{id: id, name: name, handler: handler})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.handlers {
+			if s.id == id {
+				// Copy rather than delete in place: Emit may be iterating
+				// over the old slice
+				b.handlers = append(append([]subscriber{}, b.handlers[:i]...), b.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Emit calls every handler with c. The change has already been made, so
+// ctx is not cancelled for the handlers even if the caller's is. A handler
+// that panics is logged and the rest still run.
+func (b *Bus) Emit(ctx context.Context, c Change) {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for _, s := range handlers {
+		s.run(ctx, c)
+	}
+}
+
+func (s subscriber) run(ctx context.Context, c Change) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Warning: %s failed on %s %s %s: %v\n%s", s.name, c.Entity, c.Action, c.ID, err, debug.Stack())
+		}
+	}()
+	s.handler(ctx, c)
+}
@@ -0,0 +1,228 @@
+// Package events carries changes within the server. A Bus runs the side
+// effects of every mutation, such as auditing and cache invalidation, and
+// a Hub publishes changes to tasks and users to clients following them
+// live through GET /api/events and /ws.
+//
+// Each event a hub publishes gets the next ID. IDs start from the time the
+// hub was created, so they keep growing across restarts. The hub keeps the
+// most recent events, so a subscriber that reconnects can resume after the
+// last ID it saw, as Server-Sent Events clients do with Last-Event-ID.
+package events
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TaskCreated = "task.created"
+	TaskUpdated = "task.updated"
+	UserCreated = "user.created"
+	// Reset is published when data is replaced wholesale, e.g. by a
+	// restore, after which clients should reload rather than apply changes.
+	Reset = "reset"
+)
+
+var types = []string{TaskCreated, TaskUpdated, UserCreated, Reset}
+
+// ValidType reports whether typ is an event type.
+func ValidType(typ string) bool {
+	return slices.Contains(types, typ)
+}
+
+// Defaults for Options.
+const (
+	DefaultHistory        = 1000
+	DefaultBuffer         = 64
+	DefaultMaxSubscribers = 1000
+)
+
+var (
+	// ErrTooManySubscribers is returned by Subscribe when the hub already
+	// has Options.MaxSubscribers subscribers.
+	ErrTooManySubscribers = errors.New("events: too many subscribers")
+	// ErrClosed is returned by Subscribe once the hub is closed.
+	ErrClosed = errors.New("events: hub closed")
+)
+
+// Event is one change.
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	At   time.Time   `json:"at"`
+	Data interface{} `json:"data"`
+}
+
+// Options configure a Hub. Zero fields use the defaults.
+type Options struct {
+	// History is how many recent events are kept for subscribers resuming
+	// after an event.
+	History int
+	// Buffer is how many events may be queued for a subscriber. A
+	// subscriber that falls further behind is dropped, and can resume from
+	// the history.
+	Buffer int
+	// MaxSubscribers caps the number of subscribers at once.
+	MaxSubscribers int
+}
+
+// Hub delivers published events to its subscribers.
+type Hub struct {
+	buffer         int
+	maxSubscribers int
+
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// New returns an empty hub.
+func New(opts Options) *Hub {
+	if opts.History <= 0 {
+		opts.History = DefaultHistory
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	if opts.MaxSubscribers <= 0 {
+		opts.MaxSubscribers = DefaultMaxSubscribers
+	}
+	return &Hub{
+		nextID:         uint64(time.Now().UnixMicro()),
+		buffer:         opts.Buffer,
+		maxSubscribers: opts.MaxSubscribers,
+		historySize:    opts.History,
+		subscribers:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events published after it was made.
+type Subscription struct {
+	hub *Hub
+	// types limits the subscription to these event types, and Reset.
+	// Empty means every type.
+	types map[string]bool
+	c     chan Event
+}
+
+// Events returns the channel events are delivered on. It is closed when
+// the subscription ends: when it is closed, falls too far behind, or the
+// hub is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// wants reports whether the subscription is for events of type typ.
+func (s *Subscription) wants(typ string) bool {
+	return len(s.types) == 0 || s.types[typ] || typ == Reset
+}
+
+// Publish sends an event to every subscriber and returns it.
+func (h *Hub) Publish(typ string, data interface{}) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	event := Event{ID: h.nextID, Type: typ, At: time.Now().UTC(), Data: data}
+	h.history = append(h.history, event)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for s := range h.subscribers {
+		if !s.wants(typ) {
+			continue
+		}
+		select {
+		case s.c <- event:
+		default:
+			// Too far behind: end the subscription rather than block
+			// publishers. The subscriber can resume from the history.
+			h.drop(s)
+		}
+	}
+	return event
+}
+
+// Subscribe subscribes to events of the given types, or all types if
+// none are given. If after is not zero, the events after it are returned,
+// to be handled before those the subscription receives. If some of them
+// have been forgotten, a single Reset event with the ID of the last event
+// is returned instead, as the subscriber must reload rather than catch up.
+func (h *Hub) Subscribe(after uint64, types ...string) (*Subscription, []Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, nil, ErrClosed
+	}
+	if len(h.subscribers) >= h.maxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	s := &Subscription{hub: h, c: make(chan Event, h.buffer)}
+	if len(types) > 0 {
+		s.types = make(map[string]bool, len(types))
+		for _, typ := range types {
+			s.types[typ] = true
+		}
+	}
+	h.subscribers[s] = struct{}{}
+
+	if after == 0 || after == h.nextID {
+		return s, nil, nil
+	}
+	// IDs are consecutive, so the events after it are all there if the
+	// oldest kept follows it directly. An ID from the future was issued by
+	// another hub, e.g. before a restart.
+	if after > h.nextID || len(h.history) == 0 || h.history[0].ID > after+1 {
+		return s, []Event{{ID: h.nextID, Type: Reset, At: time.Now().UTC()}}, nil
+	}
+	var missed []Event
+	for _, event := range h.history {
+		if event.ID > after && s.wants(event.Type) {
+			missed = append(missed, event)
+		}
+	}
+	return s, missed, nil
+}
+
+// Subscribers returns the number of subscribers.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// Close ends every subscription, letting long-lived streams finish so the
+// server can shut down, and refuses new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subscribers {
+		h.drop(s)
+	}
+}
+
+// drop ends a subscription. h.mu must be held.
+func (h *Hub) drop(s *Subscription) {
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.c)
+	}
+}
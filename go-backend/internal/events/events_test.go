@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// receive returns the next event of s, failing if there is none waiting.
+func receive(t *testing.T, s *Subscription) Event {
+	t.Helper()
+	select {
+	case event, ok := <-s.Events():
+		if !ok {
+			t.Fatal("expected an event, got the subscription closed")
+		}
+		return event
+	default:
+		t.Fatal("expected an event, got none")
+		return Event{}
+	}
+}
+
+func TestHub_PublishAndFilter(t *testing.T) {
+	t.Parallel()
+
+	h := New(Options{})
+	all, _, _ := h.Subscribe(0)
+	users, _, _ := h.Subscribe(0, UserCreated)
+
+	created := h.Publish(TaskCreated, "task")
+	h.Publish(UserCreated, "user")
+	h.Publish(Reset, nil)
+
+	if got := receive(t, all); got.ID != created.ID || got.Type != TaskCreated || got.Data != "task" {
+		t.Errorf("expected the task event first, got %+v", got)
+	}
+	if got := receive(t, all); got.ID != created.ID+1 {
+		t.Errorf("expected consecutive IDs, got %d after %d", got.ID, created.ID)
+	}
+	if got := receive(t, users); got.Type != UserCreated {
+		t.Errorf("expected only the user event, got %+v", got)
+	}
+	if got := receive(t, users); got.Type != Reset {
+		t.Errorf("expected resets to reach filtered subscriptions, got %+v", got)
+	}
+
+	users.Close()
+	users.Close()
+	if h.Subscribers() != 1 {
+		t.Errorf("expected one subscriber left, got %d", h.Subscribers())
+	}
+}
+
+func TestHub_Resume(t *testing.T) {
+	t.Parallel()
+
+	h := New(Options{History: 3})
+	first := h.Publish(TaskCreated, 1)
+	for i := 2; i <= 4; i++ {
+		h.Publish(TaskUpdated, i)
+	}
+
+	// The first was forgotten, but everything after it is kept
+	_, missed, _ := h.Subscribe(first.ID)
+	if len(missed) != 3 || missed[0].Data != 2 || missed[2].Data != 4 {
+		t.Errorf("expected the three events after the first, got %+v", missed)
+	}
+
+	// A client from before the first, or from another hub, must reload
+	for _, after := range []uint64{first.ID - 1, first.ID + 100, 1} {
+		_, missed, _ = h.Subscribe(after)
+		if len(missed) != 1 || missed[0].Type != Reset || missed[0].ID != first.ID+3 {
+			t.Errorf("after %d: expected a reset at the last event, got %+v", after, missed)
+		}
+	}
+
+	// Nothing was missed by a client up to date
+	if _, missed, _ = h.Subscribe(first.ID + 3); len(missed) != 0 {
+		t.Errorf("expected nothing missed, got %+v", missed)
+	}
+}
+
+func TestHub_DropsSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	h := New(Options{Buffer: 2})
+	slow, _, _ := h.Subscribe(0)
+	for i := 0; i < 3; i++ {
+		h.Publish(TaskUpdated, i)
+	}
+
+	receive(t, slow)
+	receive(t, slow)
+	if _, ok := <-slow.Events(); ok {
+		t.Error("expected the subscription closed after falling behind")
+	}
+	if h.Subscribers() != 0 {
+		t.Errorf("expected no subscribers, got %d", h.Subscribers())
+	}
+}
+
+func TestHub_Limits(t *testing.T) {
+	t.Parallel()
+
+	h := New(Options{MaxSubscribers: 1})
+	s, _, err := h.Subscribe(0)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, _, err := h.Subscribe(0); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("expected ErrTooManySubscribers, got %v", err)
+	}
+
+	h.Close()
+	if _, ok := <-s.Events(); ok {
+		t.Error("expected closing the hub to end subscriptions")
+	}
+	if _, _, err := h.Subscribe(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestBus_Emit(t *testing.T) {
+	t.Parallel()
+
+	b := NewBus()
+	var got []string
+	b.Subscribe("first", func(ctx context.Context, c Change) {
+		if ctx.Err() != nil {
+			t.Error("expected the handlers' context not cancelled")
+		}
+		got = append(got, "first "+c.ID)
+	})
+	b.Subscribe("panics", func(ctx context.Context, c Change) {
+		panic("subscriber failed")
+	})
+	unsubscribe := b.Subscribe("last", func(ctx context.Context, c Change) {
+		got = append(got, "last "+c.ID)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Emit(ctx, Change{Entity: EntityTask, Action: ActionCreate, ID: "1"})
+	unsubscribe()
+	b.Emit(ctx, Change{Entity: EntityTask, Action: ActionUpdate, ID: "2"})
+
+	if want := []string{"first 1", "last 1", "first 2"}; !slices.Equal(got, want) {
+		t.Errorf("expected handlers in order past the panic, %v, got %v", want, got)
+	}
+}
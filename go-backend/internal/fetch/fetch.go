@@ -0,0 +1,240 @@
+// Package fetch makes outbound HTTP requests to URLs that users choose,
+// such as links to unfurl.
+//
+// Such URLs may point back into the network the server runs in, so a
+// Client only reaches destinations its Policy allows: public addresses on
+// allowed schemes and ports. Addresses are checked as each connection is
+// made, after DNS resolution, so a name that resolves to an internal
+// address, or is rebound to one after an earlier lookup, is refused too.
+// Responses are size-capped.
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Defaults for Policy.
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxBytes     = 1 << 20
+	DefaultMaxRedirects = 3
+)
+
+// Default allowlists for Policy.
+var (
+	DefaultSchemes = []string{"http", "https"}
+	DefaultPorts   = []int{80, 443}
+)
+
+var (
+	// ErrForbidden is returned for requests to a destination the policy
+	// does not allow.
+	ErrForbidden = errors.New("fetch: destination not allowed")
+	// ErrTooLarge is returned reading a response body past the policy's
+	// MaxBytes.
+	ErrTooLarge = errors.New("fetch: response too large")
+)
+
+// Policy says which destinations a Client may reach and bounds its
+// responses. Zero fields use the defaults.
+type Policy struct {
+	// Schemes are the allowed URL schemes.
+	Schemes []string
+	// Ports are the allowed ports. URLs without a port use their scheme's.
+	Ports []int
+	// AllowNets are non-public networks that may be reached anyway, such
+	// as a trusted internal service. Leave empty for URLs from users.
+	AllowNets []*net.IPNet
+	// Timeout bounds a whole request, redirects and body included.
+	Timeout time.Duration
+	// MaxBytes caps each response body.
+	MaxBytes int64
+	// MaxRedirects is how many redirects are followed.
+	MaxRedirects int
+}
+
+// withDefaults returns p with zero fields set to their defaults.
+func (p Policy) withDefaults() Policy {
+	if len(p.Schemes) == 0 {
+		p.Schemes = DefaultSchemes
+	}
+	if len(p.Ports) == 0 {
+		p.Ports = DefaultPorts
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultTimeout
+	}
+	if p.MaxBytes <= 0 {
+		p.MaxBytes = DefaultMaxBytes
+	}
+	if p.MaxRedirects <= 0 {
+		p.MaxRedirects = DefaultMaxRedirects
+	}
+	return p
+}
+
+// Client is an HTTP client that enforces a Policy. It is safe for
+// concurrent use.
+type Client struct {
+	policy Policy
+	client *http.Client
+}
+
+// New returns a client enforcing policy.
+func New(policy Policy) *Client {
+	policy = policy.withDefaults()
+	c := &Client{policy: policy}
+	dialer := &net.Dialer{
+		Timeout: policy.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return c.checkAddress(address)
+		},
+	}
+	c.client = &http.Client{
+		Timeout: policy.Timeout,
+		Transport: &http.Transport{
+			// Proxies from the environment would connect on our behalf,
+			// unchecked
+			Proxy:                  nil,
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    policy.Timeout,
+			ResponseHeaderTimeout:  policy.Timeout,
+			MaxResponseHeaderBytes: 64 << 10,
+			DisableKeepAlives:      true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > policy.MaxRedirects {
+				return fmt.Errorf("fetch: more than %d redirects", policy.MaxRedirects)
+			}
+			return c.checkURL(req.URL)
+		},
+	}
+	return c
+}
+
+// Do sends req if the policy allows its URL. The response body fails with
+// ErrTooLarge once more than MaxBytes are read from it.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if err := c.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &cappedBody{body: resp.Body, remaining: c.policy.MaxBytes}
+	return resp, nil
+}
+
+// checkURL checks the scheme and port of u. Its address is checked when
+// connecting.
+func (c *Client) checkURL(u *url.URL) error {
+	if !slices.Contains(c.policy.Schemes, u.Scheme) {
+		return fmt.Errorf("%w: scheme %q", ErrForbidden, u.Scheme)
+	}
+	port := u.Port()
+	switch {
+	case port != "":
+	case u.Scheme == "https":
+		port = "443"
+	default:
+		port = "80"
+	}
+	return c.checkPort(port)
+}
+
+// checkPort checks that port is allowed.
+func (c *Client) checkPort(port string) error {
+	if n, err := strconv.Atoi(port); err != nil || !slices.Contains(c.policy.Ports, n) {
+		return fmt.Errorf("%w: port %s", ErrForbidden, port)
+	}
+	return nil
+}
+
+// checkAddress checks a resolved host:port about to be connected to.
+func (c *Client) checkAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if err := c.checkPort(port); err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: address %s", ErrForbidden, host)
+	}
+	for _, n := range c.policy.AllowNets {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+	if Forbidden(ip) {
+		return fmt.Errorf("%w: address %s is not publicly routable", ErrForbidden, host)
+	}
+	return nil
+}
+
+// reservedNets are IPv4 ranges that are not publicly routable, beyond
+// those net.IP reports on: "this network", shared address space for
+// carrier-grade NAT, IETF protocol assignments, benchmarking, and the
+// reserved and broadcast ranges.
+var reservedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// Forbidden reports whether ip is not a public unicast address, such as a
+// loopback, private, link-local or multicast one.
+func Forbidden(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		for _, n := range reservedNets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback()
+}
+
+// cappedBody is a response body that fails once more than remaining
+// bytes are read.
+type cappedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (b *cappedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	// Read one byte more than allowed to tell a body of exactly the cap
+	// from a longer one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrTooLarge
+	}
+	return n, err
+}
+
+func (b *cappedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,142 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns a server on loopback and a policy allowing it.
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, Policy) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	return srv, Policy{Ports: []int{port}, AllowNets: []*net.IPNet{loopback}}
+}
+
+func get(c *Client, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func TestClient_RefusesDestinations(t *testing.T) {
+	srv, allowed := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to reach the server, got %s", r.URL)
+	})
+	u, _ := url.Parse(srv.URL)
+
+	tests := []struct {
+		name   string
+		policy Policy
+		url    string
+	}{
+		{"loopback", Policy{Ports: allowed.Ports}, srv.URL},
+		{"port", Policy{AllowNets: allowed.AllowNets}, srv.URL},
+		{"scheme", allowed, "ftp://" + u.Host + "/file"},
+		{"name resolving to loopback", Policy{Ports: allowed.Ports}, "http://localhost:" + u.Port()},
+		{"metadata service", Policy{}, "http://169.254.169.254/latest/meta-data/"},
+	}
+
+	for _, tt := range tests {
+		if _, err := get(New(tt.policy), tt.url); !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestClient_Redirects(t *testing.T) {
+	srv, policy := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal":
+			http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		default:
+			io.WriteString(w, "page")
+		}
+	})
+	c := New(policy)
+
+	resp, err := get(c, srv.URL+"/moved")
+	if err != nil {
+		t.Fatalf("expected an allowed redirect to be followed, got %v", err)
+	}
+	resp.Body.Close()
+	if _, err := get(c, srv.URL+"/internal"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected a redirect to an internal address to be refused, got %v", err)
+	}
+	if _, err := get(c, srv.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("expected too many redirects, got %v", err)
+	}
+}
+
+func TestClient_MaxBytes(t *testing.T) {
+	srv, policy := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 10))
+	})
+
+	for _, tt := range []struct {
+		maxBytes int64
+		wantErr  error
+	}{
+		{10, nil},
+		{9, ErrTooLarge},
+	} {
+		policy.MaxBytes = tt.maxBytes
+		resp, err := get(New(policy), srv.URL)
+		if err != nil {
+			t.Fatalf("MaxBytes %d: %v", tt.maxBytes, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !errors.Is(err, tt.wantErr) || int64(len(body)) > tt.maxBytes {
+			t.Errorf("MaxBytes %d: read %d bytes, err %v, want err %v", tt.maxBytes, len(body), err, tt.wantErr)
+		}
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+	}
+
+	for _, tt := range tests {
+		if got := Forbidden(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Forbidden(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
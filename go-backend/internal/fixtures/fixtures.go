@@ -0,0 +1,64 @@
+// Package fixtures holds named data sets, called profiles, that all data
+// can be reset to with POST /api/admin/reset, e.g. between end-to-end test
+// runs. Each profile is a backup file in profiles/, with fixed IDs and
+// timestamps so tests can rely on them.
+package fixtures
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"go-backend/internal/store"
+)
+
+// profileFiles holds the profiles, named NAME.json, in the backup format.
+//
+//go:embed profiles/*.json
+var profileFiles embed.FS
+
+// Default is the profile used when none is named: the sample data a new
+// server starts with.
+const Default = "default"
+
+// ErrUnknownProfile is returned by Load for a name that is not a profile.
+var ErrUnknownProfile = errors.New("fixtures: unknown profile")
+
+// Profiles returns the names of the profiles, sorted.
+func Profiles() []string {
+	files, _ := fs.Glob(profileFiles, "profiles/*.json")
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = strings.TrimSuffix(path.Base(file), ".json")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the data of the named profile.
+func Load(name string) (store.Snapshot, error) {
+	if !isProfile(name) {
+		return store.Snapshot{}, fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+	f, err := profileFiles.Open("profiles/" + name + ".json")
+	if err != nil {
+		return store.Snapshot{}, err
+	}
+	defer f.Close()
+	return store.ReadBackup(f)
+}
+
+// isProfile reports whether name is one of Profiles, so names cannot
+// reach outside profiles/.
+func isProfile(name string) bool {
+	for _, profile := range Profiles() {
+		if profile == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package fixtures
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	if got, want := Profiles(), []string{"default", "demo", "empty"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected profiles %v, got %v", want, got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range Profiles() {
+		if _, err := Load(name); err != nil {
+			t.Errorf("profile %q failed to load: %v", name, err)
+		}
+	}
+
+	snap, _ := Load(Default)
+	if len(snap.Users()) != 3 || len(snap.Tasks()) != 3 {
+		t.Errorf("expected the sample data, got %d users and %d tasks", len(snap.Users()), len(snap.Tasks()))
+	}
+	if snap, _ = Load("empty"); snap.Tasks() == nil || len(snap.Tasks()) != 0 || len(snap.Users()) != 0 {
+		t.Errorf("expected no data, got %+v", snap)
+	}
+
+	for _, name := range []string{"missing", "../profiles/demo", ""} {
+		if _, err := Load(name); !errors.Is(err, ErrUnknownProfile) {
+			t.Errorf("Load(%q): expected ErrUnknownProfile, got %v", name, err)
+		}
+	}
+}
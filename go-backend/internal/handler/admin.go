@@ -0,0 +1,434 @@
+package handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/digest"
+	"go-backend/internal/events"
+	"go-backend/internal/fixtures"
+	"go-backend/internal/middleware"
+	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/store"
+	"go-backend/internal/validator"
+)
+
+func (h *Handler) handleRouteUsage(w http.ResponseWriter, r *http.Request) {
+	routes := h.usage.Snapshot()
+	h.writeJSON(w, http.StatusOK, model.RouteUsageResponse{
+		Routes: routes,
+		Count:  len(routes),
+	})
+}
+
+// handleBackup streams a backup of all data. Password hashes are left
+// out unless ?passwordHashes=true asks for them.
+func (h *Handler) handleBackup(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.store.Snapshot(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read data", "INTERNAL_ERROR")
+		return
+	}
+	if r.URL.Query().Get("passwordHashes") != "true" {
+		snap = snap.WithoutPasswordHashes()
+	}
+	filename := "backup-" + time.Now().UTC().Format("20060102-150405") + ".json"
+
+	var out io.Writer = w
+	if r.URL.Query().Get("format") == "gzip" {
+		filename += ".gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	setSnapshotRevision(w, snap)
+
+	if err := store.WriteBackup(out, snap); err != nil {
+		log.Printf("Warning: Backup failed: %v", err)
+	}
+}
+
+func (h *Handler) handleRestore(w http.ResponseWriter, r *http.Request) {
+	body, err := backupReader(r.Body)
+	if writeBodyTooLarge(w, err) {
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid gzip data", "INVALID_BACKUP")
+		return
+	}
+
+	// A small gzipped body can expand enormously, so the limit also applies
+	// to what it decompresses to
+	limit := h.config.Server.withDefaults().MaxUploadBytes
+	snap, err := store.ReadBackup(http.MaxBytesReader(w, io.NopCloser(body), limit))
+	if writeBodyTooLarge(w, err) {
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON format", "INVALID_JSON")
+		return
+	}
+
+	if err := validateBackup(snap); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error(), "INVALID_BACKUP")
+		return
+	}
+
+	actor := middleware.ClientID(r)
+	if wantsAsync(r) {
+		h.startOperation(w, "restore", func(ctx context.Context, p *operation.Progress) (interface{}, error) {
+			// The restore is a single swap: cancelling before it leaves the
+			// data untouched, and once it has started it cannot be undone.
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			p.SetTotal(len(snap.Users()) + len(snap.Tasks()))
+			result, err := h.restore(context.WithoutCancel(ctx), actor, snap)
+			if err != nil {
+				return nil, err
+			}
+			p.Add(len(snap.Users()) + len(snap.Tasks()))
+			return result, nil
+		})
+		return
+	}
+
+	result, err := h.restore(context.WithoutCancel(r.Context()), actor, snap)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to restore backup", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// restore swaps in a validated backup for actor and clears the caches. ctx
+// should not be cancellable: a restore abandoned halfway would leave
+// partial data. Users the backup has no password hash for keep the one
+// they have, if they are the same user by ID and email, so a backup taken
+// without hashes does not leave passwords for anyone to set. The audit
+// trail records the revision the backup was taken at, if it has one. If
+// the store fails, the data is left as it was.
+func (h *Handler) restore(ctx context.Context, actor string, snap store.Snapshot) (model.SuccessResponse, error) {
+	current, err := h.store.GetUsers(ctx)
+	if err != nil {
+		return model.SuccessResponse{}, err
+	}
+	snap = snap.WithPasswordHashesFrom(current)
+
+	change := events.Change{Entity: events.EntityBackup, Action: events.ActionRestore, Actor: actor}
+	message := fmt.Sprintf("Restored %d users and %d tasks", len(snap.Users()), len(snap.Tasks()))
+	if revision := snap.Revision(); revision > 0 {
+		change.ID = strconv.FormatInt(revision, 10)
+		message += fmt.Sprintf(" from revision %d", revision)
+	}
+	if err := h.replaceData(ctx, change, snap); err != nil {
+		return model.SuccessResponse{}, err
+	}
+
+	return model.SuccessResponse{
+		Success: true,
+		Message: message,
+	}, nil
+}
+
+// replaceData replaces all data with snap and emits change. The change
+// lists how many users and tasks there were before and after, not every
+// record replaced. Nothing is emitted if the store fails.
+func (h *Handler) replaceData(ctx context.Context, change events.Change, snap store.Snapshot) error {
+	users, err := h.store.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	tasks, err := h.store.GetTasks(ctx, "", "")
+	if err != nil {
+		return err
+	}
+	if err := h.store.Restore(ctx, snap); err != nil {
+		return err
+	}
+	change.Fields = []model.FieldChange{
+		{Field: "tasks", Old: len(tasks), New: len(snap.Tasks())},
+		{Field: "users", Old: len(users), New: len(snap.Users())},
+	}
+	h.bus.Emit(ctx, change)
+	return nil
+}
+
+// handleReset replaces all data with the fixture profile named by
+// ?profile=, or fixtures.Default, for end-to-end test runs. Unless
+// Config.ResetKey is set, the endpoint is not routed, and requests must
+// carry the key in X-API-Key. Webhooks and the audit trail are kept.
+func (h *Handler) handleReset(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if subtle.ConstantTimeCompare([]byte(key), []byte(h.config.ResetKey)) != 1 {
+		h.writeError(w, http.StatusUnauthorized, "Invalid or missing API key", "UNAUTHORIZED")
+		return
+	}
+
+	profile := r.URL.Query().Get("profile")
+	if profile == "" {
+		profile = fixtures.Default
+	}
+	snap, err := fixtures.Load(profile)
+	if errors.Is(err, fixtures.ErrUnknownProfile) {
+		h.writeError(w, http.StatusBadRequest, "Unknown profile. Must be one of: "+strings.Join(fixtures.Profiles(), ", "), "INVALID_PROFILE")
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to load fixture profile %q: %v", profile, err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to load profile", "INTERNAL_ERROR")
+		return
+	}
+
+	// Like a restore, a reset must not be abandoned halfway
+	change := events.Change{Entity: events.EntityFixture, Action: events.ActionReset, ID: profile, Actor: middleware.ClientID(r)}
+	if err := h.replaceData(context.WithoutCancel(r.Context()), change, snap); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to reset data", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, model.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Reset to profile %q with %d users and %d tasks", profile, len(snap.Users()), len(snap.Tasks())),
+	})
+}
+
+// handleCacheFlush clears the response cache, e.g. when it holds
+// responses that should not be served, without a restart.
+func (h *Handler) handleCacheFlush(w http.ResponseWriter, r *http.Request) {
+	h.FlushCaches()
+	log.Printf("Cache flushed by %s", middleware.ClientID(r))
+
+	h.writeJSON(w, http.StatusOK, model.SuccessResponse{
+		Success: true,
+		Message: "Cache flushed",
+	})
+}
+
+// handlePersist saves all data now rather than when writes are next
+// persisted: a file store writes the data file and truncates its journal,
+// and a database store, which saves every change as it is made, checks
+// the database is reachable.
+func (h *Handler) handlePersist(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Persist(r.Context()); err != nil {
+		log.Printf("Warning: Persist requested by %s failed: %v", middleware.ClientID(r), err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to save data", "PERSIST_FAILED")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, model.SuccessResponse{
+		Success: true,
+		Message: "Data saved",
+	})
+}
+
+// handleConfig returns the configuration the server runs with, secrets
+// redacted.
+func (h *Handler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.config.Settings()
+	if err != nil {
+		log.Printf("Warning: Failed to list settings: %v", err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to list settings", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleReload reloads the configuration, as SIGHUP does. A configuration
+// that does not load or validate is rejected with the reasons, and the
+// server keeps the one it has.
+func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
+	restartNeeded, err := h.config.Reload()
+	if err != nil {
+		log.Printf("Warning: Configuration not reloaded: %v", err)
+		h.writeError(w, http.StatusUnprocessableEntity, "Configuration not reloaded: "+err.Error(), "INVALID_CONFIG")
+		return
+	}
+	log.Printf("Configuration reloaded by %s", middleware.ClientID(r))
+
+	message := "Configuration reloaded"
+	if len(restartNeeded) > 0 {
+		message += "; restart to apply changes to " + strings.Join(restartNeeded, ", ")
+	}
+	h.writeJSON(w, http.StatusOK, model.ReloadResponse{
+		Success:       true,
+		Message:       message,
+		RestartNeeded: restartNeeded,
+	})
+}
+
+// backupReader returns a reader over body, decompressing it if it is gzipped.
+func backupReader(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// validateBackup checks that a backup is internally consistent and that
+// every record would pass the API's own validation.
+func validateBackup(snap store.Snapshot) error {
+	userIDs := make(map[int]bool)
+	emails := make(map[string]bool)
+	for _, user := range snap.Users() {
+		switch {
+		case user.ID <= 0:
+			return fmt.Errorf("user has invalid ID %d", user.ID)
+		case userIDs[user.ID]:
+			return fmt.Errorf("duplicate user ID %d", user.ID)
+		case !validator.NonEmpty(user.Name) || !validator.NonEmpty(user.Role):
+			return fmt.Errorf("user %d is missing a name or role", user.ID)
+		case !validator.Email(user.Email) || user.Email != validator.NormalizeEmail(user.Email):
+			return fmt.Errorf("user %d has an invalid or unnormalized email", user.ID)
+		case emails[user.Email]:
+			return fmt.Errorf("duplicate user email %q", user.Email)
+		}
+		userIDs[user.ID] = true
+		emails[user.Email] = true
+	}
+
+	projectIDs := make(map[int]bool)
+	for _, project := range snap.Projects() {
+		switch {
+		case project.ID <= 0:
+			return fmt.Errorf("project has invalid ID %d", project.ID)
+		case projectIDs[project.ID]:
+			return fmt.Errorf("duplicate project ID %d", project.ID)
+		case !validator.NonEmpty(project.Name):
+			return fmt.Errorf("project %d is missing a name", project.ID)
+		}
+		projectIDs[project.ID] = true
+	}
+
+	taskIDs := make(map[int]bool)
+	for _, task := range snap.Tasks() {
+		switch {
+		case task.ID <= 0:
+			return fmt.Errorf("task has invalid ID %d", task.ID)
+		case taskIDs[task.ID]:
+			return fmt.Errorf("duplicate task ID %d", task.ID)
+		case !validator.NonEmpty(task.Title):
+			return fmt.Errorf("task %d is missing a title", task.ID)
+		case !validator.Status(task.Status):
+			return fmt.Errorf("task %d has an invalid status", task.ID)
+		case !validator.Priority(task.Priority):
+			return fmt.Errorf("task %d has an invalid priority", task.ID)
+		case !validTags(task.Tags):
+			return fmt.Errorf("task %d has invalid tags", task.ID)
+		case !userIDs[task.UserID]:
+			return fmt.Errorf("task %d references unknown user %d", task.ID, task.UserID)
+		case task.ProjectID != 0 && !projectIDs[task.ProjectID]:
+			return fmt.Errorf("task %d references unknown project %d", task.ID, task.ProjectID)
+		}
+		taskIDs[task.ID] = true
+	}
+	for _, task := range snap.Tasks() {
+		for _, id := range task.BlockedBy {
+			if !taskIDs[id] {
+				return fmt.Errorf("task %d is blocked by unknown task %d", task.ID, id)
+			}
+		}
+	}
+	if store.HasDependencyCycle(snap.Tasks()) {
+		return fmt.Errorf("task dependencies form a cycle")
+	}
+
+	shareIDs := make(map[string]bool)
+	for _, share := range snap.Shares() {
+		switch {
+		case share.ID == "" || share.TokenHash == "":
+			return fmt.Errorf("share of task %d is missing an ID or token hash", share.TaskID)
+		case shareIDs[share.ID]:
+			return fmt.Errorf("duplicate share ID %q", share.ID)
+		case !taskIDs[share.TaskID]:
+			return fmt.Errorf("share %q references unknown task %d", share.ID, share.TaskID)
+		}
+		shareIDs[share.ID] = true
+	}
+
+	announcementIDs := make(map[int]bool)
+	for _, a := range snap.Announcements() {
+		switch {
+		case a.ID <= 0:
+			return fmt.Errorf("announcement has invalid ID %d", a.ID)
+		case announcementIDs[a.ID]:
+			return fmt.Errorf("duplicate announcement ID %d", a.ID)
+		case !validator.NonEmpty(a.Message) || !validator.Level(a.Level):
+			return fmt.Errorf("announcement %d is missing a message or has an invalid level", a.ID)
+		}
+		announcementIDs[a.ID] = true
+	}
+
+	return nil
+}
+
+// handleDigest runs the digest email job now, for testing templates and
+// mail delivery. dryRun=true returns the digests instead of sending them;
+// userId limits the run to one user.
+func (h *Handler) handleDigest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := digest.Options{DryRun: query.Get("dryRun") == "true"}
+	if raw := query.Get("userId"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid user ID", "INVALID_ID")
+			return
+		}
+		if h.findUser(w, r, id) == nil {
+			return
+		}
+		opts.UserID = id
+	}
+
+	now := time.Now()
+	if wantsAsync(r) {
+		h.startOperation(w, "digest", func(ctx context.Context, p *operation.Progress) (interface{}, error) {
+			return h.digest.Run(ctx, now, opts)
+		})
+		return
+	}
+
+	result, err := h.digest.Run(r.Context(), now, opts)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to run the digest", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// validTags reports whether tags are valid, normalized and unique, as the
+// store keeps them.
+func validTags(tags []string) bool {
+	if len(tags) > validator.MaxTags {
+		return false
+	}
+	normalized := validator.NormalizeTags(tags)
+	if len(normalized) != len(tags) {
+		return false
+	}
+	for i, tag := range tags {
+		if tag != normalized[i] || !validator.Tag(tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-backend/internal/events"
+	"go-backend/internal/middleware"
+	"go-backend/internal/model"
+	"go-backend/internal/validator"
+)
+
+// listAnnouncements serves GET /api/announcements: all announcements,
+// active or not.
+func (h *Handler) listAnnouncements(w http.ResponseWriter, r *http.Request) {
+	announcements := h.store.GetAnnouncements(r.Context())
+	h.writeJSON(w, http.StatusOK, model.AnnouncementsResponse{Announcements: announcements, Count: len(announcements)})
+}
+
+// handleActiveAnnouncements serves GET /api/announcements/active: the
+// announcements every client should show now.
+func (h *Handler) handleActiveAnnouncements(w http.ResponseWriter, r *http.Request) {
+	active := h.activeAnnouncements(r)
+	h.writeJSON(w, http.StatusOK, model.AnnouncementsResponse{Announcements: active, Count: len(active)})
+}
+
+// handleMe serves GET /api/me: who the caller is and the announcements
+// active now, so a client learns both in one request when it starts.
+func (h *Handler) handleMe(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, model.MeResponse{
+		Client:        middleware.ClientID(r),
+		Admin:         h.isAdmin(r),
+		Announcements: h.activeAnnouncements(r),
+	})
+}
+
+func (h *Handler) getAnnouncement(w http.ResponseWriter, r *http.Request, id int) {
+	a := h.store.GetAnnouncementByID(r.Context(), id)
+	if a == nil {
+		h.writeError(w, http.StatusNotFound, "Announcement not found", "ANNOUNCEMENT_NOT_FOUND")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) deleteAnnouncement(w http.ResponseWriter, r *http.Request, id int) {
+	existing := h.store.GetAnnouncementByID(r.Context(), id)
+	if existing == nil {
+		h.writeError(w, http.StatusNotFound, "Announcement not found", "ANNOUNCEMENT_NOT_FOUND")
+		return
+	}
+	deleted, err := h.store.DeleteAnnouncement(r.Context(), id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete announcement", "INTERNAL_ERROR")
+		return
+	}
+	if !deleted {
+		h.writeError(w, http.StatusNotFound, "Announcement not found", "ANNOUNCEMENT_NOT_FOUND")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityAnnouncement, Action: events.ActionDelete, ID: strconv.Itoa(id), Before: *existing})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) createAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateAnnouncementRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	ferrs := fieldErrors(validator.Validate(req))
+	a := model.Announcement{Message: req.Message, Level: req.Level}
+	var ferr *fieldError
+	if a.StartsAt, ferr = parseAnnouncementTime("startsAt", req.StartsAt); ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	if a.EndsAt, ferr = parseAnnouncementTime("endsAt", req.EndsAt); ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	if ferr := checkAnnouncementWindow(a); ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	created, err := h.store.CreateAnnouncement(r.Context(), a)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create announcement", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityAnnouncement, Action: events.ActionCreate, ID: strconv.Itoa(created.ID), After: created})
+	h.writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) updateAnnouncement(w http.ResponseWriter, r *http.Request, id int) {
+	existing := h.store.GetAnnouncementByID(r.Context(), id)
+	if existing == nil {
+		h.writeError(w, http.StatusNotFound, "Announcement not found", "ANNOUNCEMENT_NOT_FOUND")
+		return
+	}
+
+	var req model.UpdateAnnouncementRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	ferrs := fieldErrors(validator.Validate(req))
+	update := model.AnnouncementUpdate{Message: req.Message, Level: req.Level}
+	// The window is checked as it will be after the update
+	after := *existing
+	if req.StartsAt != nil {
+		startsAt, ferr := parseAnnouncementTime("startsAt", *req.StartsAt)
+		if ferr != nil {
+			ferrs = append(ferrs, ferr)
+		}
+		update.StartsAt, after.StartsAt = &startsAt, startsAt
+	}
+	if req.EndsAt != nil {
+		endsAt, ferr := parseAnnouncementTime("endsAt", *req.EndsAt)
+		if ferr != nil {
+			ferrs = append(ferrs, ferr)
+		}
+		update.EndsAt, after.EndsAt = &endsAt, endsAt
+	}
+	if ferr := checkAnnouncementWindow(after); ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	// Read before updating: the store may return its own record
+	before := *existing
+	updated, err := h.store.UpdateAnnouncement(r.Context(), id, update)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update announcement", "INTERNAL_ERROR")
+		return
+	}
+	if updated == nil {
+		h.writeError(w, http.StatusNotFound, "Announcement not found", "ANNOUNCEMENT_NOT_FOUND")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityAnnouncement, Action: events.ActionUpdate, ID: strconv.Itoa(id), Before: before, After: *updated})
+	h.writeJSON(w, http.StatusOK, updated)
+}
+
+// activeAnnouncements returns the announcements active now, in ID order.
+func (h *Handler) activeAnnouncements(r *http.Request) []model.Announcement {
+	now := time.Now()
+	active := []model.Announcement{}
+	for _, a := range h.store.GetAnnouncements(r.Context()) {
+		if a.Active(now) {
+			active = append(active, a)
+		}
+	}
+	return active
+}
+
+// parseAnnouncementTime parses the RFC 3339 time given for field, startsAt
+// or endsAt, or returns nil if value is empty.
+func parseAnnouncementTime(field, value string) (*time.Time, *fieldError) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		code := map[string]string{"startsAt": "INVALID_STARTS_AT", "endsAt": "INVALID_ENDS_AT"}[field]
+		return nil, &fieldError{field, code, "Invalid " + field + ". Must be an RFC 3339 time, e.g. 2026-03-08T09:00:00Z"}
+	}
+	return &t, nil
+}
+
+// checkAnnouncementWindow checks that an announcement ends after it
+// starts.
+func checkAnnouncementWindow(a model.Announcement) *fieldError {
+	if a.StartsAt != nil && a.EndsAt != nil && !a.EndsAt.After(*a.StartsAt) {
+		return &fieldError{"endsAt", "INVALID_ENDS_AT", "EndsAt must be after startsAt"}
+	}
+	return nil
+}
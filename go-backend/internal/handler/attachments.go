@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/attachment"
+	"go-backend/internal/events"
+	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/validator"
+)
+
+// sniffLen is how much of an upload is read to detect its content type.
+const sniffLen = 512
+
+// listAttachments serves GET /api/tasks/{id}/attachments.
+func (h *Handler) listAttachments(w http.ResponseWriter, r *http.Request, id int) {
+	if h.findTask(w, r, id) == nil {
+		return
+	}
+	attachments := h.attachments.List(id)
+	h.writeJSON(w, http.StatusOK, model.AttachmentsResponse{
+		Attachments: attachments,
+		Count:       len(attachments),
+	})
+}
+
+// uploadAttachment stores the "file" part of a multipart/form-data body
+// as an attachment of task taskID. The file is streamed to storage rather
+// than buffered. A JSON body attaches content already stored instead; see
+// attachFromHash.
+func (h *Handler) uploadAttachment(w http.ResponseWriter, r *http.Request, taskID int) {
+	if h.findTask(w, r, taskID) == nil {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		h.attachFromHash(w, r, taskID)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Request body must be multipart/form-data with a file field", "INVALID_UPLOAD")
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if writeBodyTooLarge(w, err) {
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Request body must be multipart/form-data with a file field", "INVALID_UPLOAD")
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		filename := part.FileName()
+		if !attachment.ValidFilename(filename) {
+			h.writeError(w, http.StatusBadRequest, "Invalid filename: "+attachment.ErrInvalidName.Error(), "INVALID_FILENAME")
+			return
+		}
+
+		body := bufio.NewReaderSize(part, sniffLen)
+		contentType := part.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			head, _ := body.Peek(sniffLen)
+			contentType = http.DetectContentType(head)
+		}
+
+		a, err := h.attachments.Create(r.Context(), taskID, filename, contentType, body)
+		if writeBodyTooLarge(w, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to store attachment: %v", err)
+			h.writeError(w, http.StatusInternalServerError, "Failed to store attachment", "INTERNAL_ERROR")
+			return
+		}
+		h.emit(r, events.Change{Entity: events.EntityAttachment, Action: events.ActionCreate, ID: strconv.Itoa(a.ID), After: a})
+		h.writeJSON(w, http.StatusCreated, a)
+		return
+	}
+}
+
+// attachFromHash attaches content the server already has to task taskID,
+// so clients that find a file's hash with HEAD /api/attachments/{hash}
+// need not upload it again.
+func (h *Handler) attachFromHash(w http.ResponseWriter, r *http.Request, taskID int) {
+	var req model.AttachFromHashRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	ferrs := fieldErrors(validator.Validate(req))
+	if req.Filename != "" && !attachment.ValidFilename(req.Filename) {
+		ferrs = append(ferrs, &fieldError{"filename", "INVALID_FILENAME", "Invalid filename: " + attachment.ErrInvalidName.Error()})
+	}
+	if req.SHA256 != "" && !attachment.ValidHash(req.SHA256) {
+		ferrs = append(ferrs, &fieldError{"sha256", "INVALID_HASH", "sha256 must be a SHA-256 hash in lowercase hex"})
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	a, err := h.attachments.CreateFromHash(r.Context(), taskID, req.Filename, req.ContentType, req.SHA256)
+	if errors.Is(err, attachment.ErrNoContent) {
+		h.writeError(w, http.StatusNotFound, "No attachment has this content; upload the file instead", "CONTENT_NOT_FOUND")
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to store attachment: %v", err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to store attachment", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityAttachment, Action: events.ActionCreate, ID: strconv.Itoa(a.ID), After: a})
+	h.writeJSON(w, http.StatusCreated, a)
+}
+
+// handleAttachmentHead serves HEAD /api/attachments/{hash}, which tells
+// clients whether the server already has a file before they upload it:
+// 200 with the content's size if it does, 404 if not. HEAD of an
+// attachment ID is answered as GET.
+func (h *Handler) handleAttachmentHead(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("id")
+	if !attachment.ValidHash(hash) {
+		h.withID("attachment", h.getAttachment)(w, r)
+		return
+	}
+	a, ok := h.attachments.Lookup(hash)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// getAttachment serves GET /api/attachments/{id}.
+func (h *Handler) getAttachment(w http.ResponseWriter, r *http.Request, id int) {
+	a, ok := h.attachments.Get(id)
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "Attachment not found", "ATTACHMENT_NOT_FOUND")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, a)
+}
+
+// rescanAttachment serves POST /api/attachments/{id}/scan, which scans
+// the attachment again, e.g. after the scanner's signatures are updated.
+func (h *Handler) rescanAttachment(w http.ResponseWriter, r *http.Request, id int) {
+	a, err := h.attachments.Rescan(r.Context(), id)
+	if errors.Is(err, attachment.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "Attachment not found", "ATTACHMENT_NOT_FOUND")
+		return
+	}
+	h.writeJSON(w, http.StatusAccepted, a)
+}
+
+func (h *Handler) deleteAttachment(w http.ResponseWriter, r *http.Request, id int) {
+	existing, _ := h.attachments.Get(id)
+	err := h.attachments.Delete(r.Context(), id)
+	if errors.Is(err, attachment.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "Attachment not found", "ATTACHMENT_NOT_FOUND")
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to delete attachment %d: %v", id, err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete attachment", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityAttachment, Action: events.ActionDelete, ID: strconv.Itoa(id), Before: existing})
+	h.writeJSON(w, http.StatusOK, model.SuccessResponse{
+		Success: true,
+		Message: "Attachment deleted",
+	})
+}
+
+// signAttachmentURL returns a download URL for an attachment that works
+// without credentials for ?expiresIn (default 15m). Files that cannot be
+// downloaded are refused here already rather than when the URL is used.
+func (h *Handler) signAttachmentURL(w http.ResponseWriter, r *http.Request, id int) {
+	expiry := attachment.DefaultURLExpiry
+	if param := r.URL.Query().Get("expiresIn"); param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil || d < time.Second || d > attachment.MaxURLExpiry {
+			h.writeError(w, http.StatusBadRequest, "Invalid expiresIn: must be a duration between 1s and "+attachment.MaxURLExpiry.String(), "INVALID_EXPIRY")
+			return
+		}
+		expiry = d
+	}
+
+	a, ok := h.attachments.Get(id)
+	switch {
+	case !ok:
+		h.writeError(w, http.StatusNotFound, "Attachment not found", "ATTACHMENT_NOT_FOUND")
+		return
+	case a.ScanStatus == model.ScanInfected:
+		h.writeError(w, http.StatusForbidden, "Attachment is quarantined: "+a.ScanSignature, "ATTACHMENT_QUARANTINED")
+		return
+	case a.ScanStatus != model.ScanClean && a.ScanStatus != model.ScanUnscanned:
+		h.writeError(w, http.StatusConflict, "Attachment has not passed its virus scan (status: "+a.ScanStatus+")", "ATTACHMENT_NOT_SCANNED")
+		return
+	}
+
+	expires := time.Now().Add(expiry).Truncate(time.Second).UTC()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", h.signer.Sign(id, expires))
+	h.writeJSON(w, http.StatusOK, model.SignedURLResponse{
+		URL:       strings.TrimSuffix(h.config.DownloadBaseURL, "/") + "/files/attachments/" + strconv.Itoa(id) + "?" + query.Encode(),
+		ExpiresAt: expires,
+	})
+}
+
+// handleSignedDownload serves /files/attachments/{id}?expires=&signature=,
+// the URLs from signAttachmentURL. The signature stands in for
+// credentials, so responses may be cached publicly until the URL expires.
+func (h *Handler) handleSignedDownload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Not found", "NOT_FOUND")
+		return
+	}
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		h.writeError(w, http.StatusForbidden, attachment.ErrInvalidSignature.Error(), "INVALID_SIGNATURE")
+		return
+	}
+
+	now := time.Now()
+	switch err := h.signer.Verify(id, expires, query.Get("signature"), now); {
+	case errors.Is(err, attachment.ErrURLExpired):
+		h.writeError(w, http.StatusForbidden, err.Error(), "URL_EXPIRED")
+		return
+	case err != nil:
+		h.writeError(w, http.StatusForbidden, err.Error(), "INVALID_SIGNATURE")
+		return
+	}
+
+	maxAge := expires - now.Unix()
+	h.downloadAttachment(w, r, id, "public, max-age="+strconv.FormatInt(maxAge, 10))
+}
+
+// downloadAttachment streams an attachment's content, or with ?size its
+// thumbnail of that size, with cacheControl as its Cache-Control header
+// if set. Files that have not passed their scan are refused.
+func (h *Handler) downloadAttachment(w http.ResponseWriter, r *http.Request, id int, cacheControl string) {
+	size := r.URL.Query().Get("size")
+	var (
+		a   model.Attachment
+		rc  io.ReadCloser
+		err error
+	)
+	if size == "" {
+		a, rc, err = h.attachments.Open(r.Context(), id)
+	} else {
+		a, rc, err = h.attachments.OpenThumbnail(r.Context(), id, size)
+	}
+	switch {
+	case errors.Is(err, attachment.ErrUnknownSize):
+		h.writeError(w, http.StatusBadRequest, "Invalid size. Must be one of: "+strings.Join(thumbnailSizes(), ", "), "INVALID_SIZE")
+		return
+	case errors.Is(err, attachment.ErrNotImage):
+		h.writeError(w, http.StatusBadRequest, "Attachment is not an image, so it has no thumbnails", "NOT_AN_IMAGE")
+		return
+	case errors.Is(err, attachment.ErrNoThumbnail):
+		h.writeError(w, http.StatusConflict, "Thumbnail has not been generated yet", "THUMBNAIL_NOT_READY")
+		return
+	case errors.Is(err, attachment.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "Attachment not found", "ATTACHMENT_NOT_FOUND")
+		return
+	case errors.Is(err, attachment.ErrQuarantined):
+		h.writeError(w, http.StatusForbidden, "Attachment is quarantined: "+a.ScanSignature, "ATTACHMENT_QUARANTINED")
+		return
+	case errors.Is(err, attachment.ErrNotScanned):
+		h.writeError(w, http.StatusConflict, "Attachment has not passed its virus scan (status: "+a.ScanStatus+")", "ATTACHMENT_NOT_SCANNED")
+		return
+	case err != nil:
+		log.Printf("Warning: Failed to open attachment %d: %v", id, err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to read attachment", "INTERNAL_ERROR")
+		return
+	}
+	defer rc.Close()
+
+	if size == "" {
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	} else {
+		w.Header().Set("Content-Type", attachment.ThumbnailType(a.ContentType))
+		w.Header().Set("Content-Disposition", "inline")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	io.Copy(w, rc)
+}
+
+// startThumbnails generates the thumbnails of an image attachment in a
+// "thumbnails" operation, once the attachment can be downloaded. It is the
+// attachment manager's OnReady function.
+func (h *Handler) startThumbnails(a model.Attachment) {
+	if !attachment.IsImage(a.ContentType) {
+		return
+	}
+	_, err := h.ops.Start("thumbnails", func(ctx context.Context, p *operation.Progress) (interface{}, error) {
+		p.SetTotal(1)
+		if err := h.attachments.GenerateThumbnails(ctx, a.ID); err != nil {
+			log.Printf("Warning: Failed to generate thumbnails of attachment %d: %v", a.ID, err)
+			return nil, err
+		}
+		p.Add(1)
+		updated, _ := h.attachments.Get(a.ID)
+		return updated, nil
+	})
+	if err != nil {
+		log.Printf("Warning: Thumbnails of attachment %d not generated: %v", a.ID, err)
+	}
+}
+
+// thumbnailSizes returns the standard thumbnail sizes, sorted.
+func thumbnailSizes() []string {
+	sizes := make([]string, 0, len(attachment.ThumbnailSizes))
+	for size := range attachment.ThumbnailSizes {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+	return sizes
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Audit list limits: GET /api/audit returns defaultAuditLimit events
+// unless ?limit= asks for more, up to maxAuditLimit.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditFields returns the fields of an entity as they appear in responses,
+// or nil if v is nil. Fields never sent to clients, such as password
+// hashes, are left out and so never reach the audit trail.
+func auditFields(v interface{}) map[string]interface{} {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// auditChanges lists the fields that differ between before and after, in
+// field order.
+func auditChanges(before, after map[string]interface{}) []model.FieldChange {
+	changes := []model.FieldChange{}
+	for field, old := range before {
+		if new, ok := after[field]; !ok || !reflect.DeepEqual(old, new) {
+			changes = append(changes, model.FieldChange{Field: field, Old: old, New: after[field]})
+		}
+	}
+	for field, new := range after {
+		if _, ok := before[field]; !ok {
+			changes = append(changes, model.FieldChange{Field: field, New: new})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// recordAudit stores an audit event. The change it records has already
+// been made, so the event is stored even if the request was cancelled.
+func (h *Handler) recordAudit(ctx context.Context, event model.AuditEvent) {
+	h.store.RecordAudit(context.WithoutCancel(ctx), event)
+}
+
+// handleAudit serves GET /api/audit: the audit trail, newest first,
+// filtered by entity, entityId, actor, action and a since/until time range.
+func (h *Handler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.AuditFilter{
+		Entity:   query.Get("entity"),
+		EntityID: query.Get("entityId"),
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+	}
+	h.listAudit(w, r, filter)
+}
+
+// handleTaskHistory serves GET /api/tasks/{id}/history: every recorded
+// change to a task, newest first.
+func (h *Handler) handleTaskHistory(w http.ResponseWriter, r *http.Request, id int) {
+	if h.findTask(w, r, id) == nil {
+		return
+	}
+	h.listAudit(w, r, model.AuditFilter{Entity: "task", EntityID: strconv.Itoa(id)})
+}
+
+// listAudit writes the audit events matching filter and the query's
+// since, until and limit parameters.
+func (h *Handler) listAudit(w http.ResponseWriter, r *http.Request, filter model.AuditFilter) {
+	query := r.URL.Query()
+	var ferrs []*fieldError
+	var ferr *fieldError
+	if filter.Since, ferr = parseAuditTime("since", "INVALID_SINCE", query.Get("since")); ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	if filter.Until, ferr = parseAuditTime("until", "INVALID_UNTIL", query.Get("until")); ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+
+	filter.Limit = defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			ferrs = append(ferrs, &fieldError{"limit", "INVALID_LIMIT", "Invalid limit. Must be between 1 and " + strconv.Itoa(maxAuditLimit)})
+		}
+		filter.Limit = limit
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	events := h.store.GetAuditEvents(r.Context(), filter)
+	h.writeJSON(w, http.StatusOK, model.AuditResponse{Events: events, Count: len(events)})
+}
+
+// parseAuditTime parses the RFC 3339 time given for field, or returns the
+// zero time if value is empty.
+func parseAuditTime(field, code, value string) (time.Time, *fieldError) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, &fieldError{field, code, "Invalid " + field + ". Must be an RFC 3339 time, e.g. 2026-03-08T09:00:00Z"}
+	}
+	return t, nil
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-backend/internal/model"
+)
+
+// Types of API changes.
+const (
+	changeAdded      = "added"
+	changeChanged    = "changed"
+	changeDeprecated = "deprecated"
+	changeRemoved    = "removed"
+)
+
+// changelog lists the changes to API behavior in each release, newest
+// first. Add an entry under the version being released with every new
+// field or route, changed default or behavior, deprecation and removal,
+// so clients can find what changed after a deploy. Deprecations are also
+// added to the deprecations table.
+var changelog = []model.ChangelogRelease{
+	{Version: "1.0.0", Changes: []model.APIChange{
+		{Type: changeChanged, Subject: "Configuration", Description: "The mailer, digest, attachment, report, job interval and API settings can be set in the configuration file and by flags, are validated at startup instead of falling back to defaults, and are shown by GET /api/admin/config with their secrets redacted."},
+		{Type: changeChanged, Subject: "Persistence", Description: "The data file and its directory are synced before the journal is removed, failed writes are retried with backoff without waiting for the next change, and a journal with an unreadable entry before its last is moved aside instead of being cut short."},
+		{Type: changeAdded, Subject: "GET /api/me", Description: "Describes the caller, its masked API key and whether it is an admin, with the announcements active now."},
+		{Type: changeChanged, Subject: "POST, PUT, DELETE /api/announcements", Description: "Require one of ADMIN_API_KEYS, responding 401 without a key and 403 with another; GET /api/announcements/active needs no key."},
+		{Type: changeChanged, Subject: "POST /api/admin/restore", Description: "Users the backup has no password hash for keep their current one if their ID and email match, instead of being left without a password."},
+		{Type: changeChanged, Subject: "POST /api/users/{id}/password", Description: "Setting the first password of a user without one requires one of ADMIN_API_KEYS, responding 403 with code FORBIDDEN otherwise."},
+		{Type: changeChanged, Subject: "GET /ws", Description: "With CORS_ORIGINS set, handshakes from other origins are refused with 403 and code ORIGIN_NOT_ALLOWED."},
+		{Type: changeChanged, Subject: "?async=true, Prefer: respond-async", Description: "At most 8 operations run at once, others responding 503 with code TOO_MANY_OPERATIONS; operations fail after 10 minutes, and only the 100 most recent are kept."},
+		{Type: changeChanged, Subject: "GET /api/admin/route-usage", Description: "Counts requests by the route pattern they matched, leaving out paths no route matches, and keeps the 10,000 most recently seen route and client combinations."},
+		{Type: changeChanged, Subject: "STORE_BACKEND=postgres", Description: "Writes, restores, user and task reads, email checks, backups, exports and reports the database fails respond 500 with code INTERNAL_ERROR instead of succeeding with nothing saved, a missing record or empty data."},
+		{Type: changeChanged, Subject: "/api/admin", Description: "Every admin endpoint but reset requires one of ADMIN_API_KEYS, responding 401 without a key and 403 with another, and is not routed without any."},
+		{Type: changeChanged, Subject: "GET /api/admin/backup, POST /api/admin/restore", Description: "Require one of ADMIN_API_KEYS, responding 401 without a key and 403 with another; backups leave out password hashes unless ?passwordHashes=true."},
+		{Type: changeAdded, Subject: "GET /api/stats/store, GET /metrics", Description: "Count store reads and writes by operation, with their records and duration, as JSON and in the Prometheus text format."},
+		{Type: changeAdded, Subject: "GET /health lastPersistedAt", Description: "Health checks report when data was last saved, and no longer save data themselves."},
+		{Type: changeChanged, Subject: "GET /health/ready", Description: "Checks data file writes, the database and the Redis cache, listing each under checks, and responds 503 when one fails or the server is shutting down."},
+		{Type: changeAdded, Subject: "POST /api/admin/cache/flush, POST /api/admin/persist", Description: "Clear the response cache and save all data now, without a restart."},
+		{Type: changeAdded, Subject: "GET /api/admin/config, POST /api/admin/reload", Description: "Show the running configuration with secrets redacted, and reload it, listing the changes that take a restart."},
+		{Type: changeAdded, Subject: "X-Request-ID", Description: "Every response carries a request ID, the one sent in X-Request-ID if any; unexpected server failures get 500 with code INTERNAL_ERROR instead of an empty reply."},
+		{Type: changeAdded, Subject: "X-API-Key", Description: "Deployments with authentication enabled reject requests without a valid API key with 401; CORS preflights allow the header."},
+		{Type: changeChanged, Subject: "routing", Description: "Paths below a record, such as /api/tasks/1/anything, get 404 instead of the record; unknown routes and methods get JSON errors, 405 with Allow."},
+		{Type: changeAdded, Subject: "Content-Encoding: gzip", Description: "Responses of 1 KiB or more are gzipped for clients sending Accept-Encoding: gzip."},
+		{Type: changeAdded, Subject: "application/xml, application/msgpack", Description: "Clients can send request bodies and get responses in XML or MessagePack, chosen with Content-Type and Accept."},
+		{Type: changeAdded, Subject: "application/vnd.api+json", Description: "Clients accepting JSON:API get documents with resource links, relationships and JSON:API error objects."},
+		{Type: changeAdded, Subject: "GET /api/admin/diagnose", Description: "Runs self-checks of disk, permissions, clocks, configuration, dangling references and goroutines, and lists the findings by severity."},
+		{Type: changeAdded, Subject: "X-Snapshot-Revision", Description: "Exports, reports and backups are taken from one snapshot of the store and give its revision; restores name the revision of the backup."},
+		{Type: changeChanged, Subject: "CSV exports", Description: "Exports follow the delimiter, decimal separator and date format of the Accept-Language locale, or of ?delimiter=, ?decimal= and ?dateFormat=; task exports add createdAt, completedAt and dueAt columns."},
+		{Type: changeAdded, Subject: "GET /api/stats/history", Description: "Lists the stats recorded over time, one snapshot per interval, for charting burn-down."},
+		{Type: changeChanged, Subject: "GET /api/stats", Description: "Deployments with a stats refresh interval serve stats recomputed in the background, with Last-Modified set."},
+		{Type: changeAdded, Subject: "GET /api/stats/users", Description: "Reports each user's tasks by status, average open task age and completion rate over a window."},
+		{Type: changeChanged, Subject: "id", Description: "Deployments with ID obfuscation show IDs as opaque strings and reject plain numbers."},
+		{Type: changeAdded, Subject: "GET /api/users/{id}/tasks", Description: "Lists a user's tasks with the filters of GET /api/tasks and a summary of all of them by status."},
+		{Type: changeAdded, Subject: "GET /api/admin/consistency", Description: "Reports drift of caches, stats and saved data from the store; the health check includes the latest result."},
+		{Type: changeChanged, Subject: "auditEvent.actor", Description: "Changes made by the server itself, such as link previews, are recorded with actor \"system:unfurl\"."},
+		{Type: changeAdded, Subject: "GET /api/events", Description: "Streams task and user changes as Server-Sent Events."},
+		{Type: changeAdded, Subject: "X-Field-Naming", Description: "Clients can send and receive snake_case field names."},
+		{Type: changeChanged, Subject: "responses", Description: "Empty lists and maps are sent as [] and {} rather than null."},
+		{Type: changeAdded, Subject: "task.revision", Description: "Task lists carry the store revision, and ?since= lists the tasks changed after it."},
+		{Type: changeAdded, Subject: "task.priority", Description: "Tasks have a priority, \"medium\" unless given."},
+	}},
+}
+
+// handleChangelog serves /api/changelog: the changes to API behavior in
+// each release, or with ?since= only in releases after that version.
+func (h *Handler) handleChangelog(w http.ResponseWriter, r *http.Request) {
+	releases := changelog
+	if since := r.URL.Query().Get("since"); since != "" {
+		if _, ok := parseVersion(since); !ok {
+			h.writeError(w, http.StatusBadRequest, "Invalid since. Must be a version such as 1.2.0", "INVALID_VERSION")
+			return
+		}
+		releases = []model.ChangelogRelease{}
+		for _, release := range changelog {
+			if compareVersions(release.Version, since) > 0 {
+				releases = append(releases, release)
+			}
+		}
+	}
+	h.writeJSON(w, http.StatusOK, model.ChangelogResponse{Current: h.config.Version, Releases: releases})
+}
+
+// parseVersion parses a version of dot-separated numbers, such as 1.2.0.
+func parseVersion(version string) ([]int, bool) {
+	parts := strings.Split(version, ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		numbers[i] = n
+	}
+	return numbers, true
+}
+
+// compareVersions returns -1, 0 or 1 as version a is before, the same as
+// or after version b. Missing parts count as 0, so 1.2 is 1.2.0.
+func compareVersions(a, b string) int {
+	x, _ := parseVersion(a)
+	y, _ := parseVersion(b)
+	for i := 0; i < max(len(x), len(y)); i++ {
+		var m, n int
+		if i < len(x) {
+			m = x[i]
+		}
+		if i < len(y) {
+			n = y[i]
+		}
+		switch {
+		case m < n:
+			return -1
+		case m > n:
+			return 1
+		}
+	}
+	return 0
+}
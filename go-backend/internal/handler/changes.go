@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-backend/internal/events"
+	"go-backend/internal/middleware"
+	"go-backend/internal/model"
+	"go-backend/internal/webhook"
+)
+
+// Every mutation is emitted on h.bus as an events.Change, and the side
+// effects of changes are its subscribers, so handlers only make the change
+// and emit it. Persistence is not among them: the store journals a change
+// as part of making it.
+
+// subscribeSideEffects subscribes the side effects of changes to h.bus, in
+// the order they run: a change is in the audit trail and the caches are
+// cleared before anyone is told about it.
+func (h *Handler) subscribeSideEffects() {
+	h.bus.Subscribe("audit", h.auditChange)
+	h.bus.Subscribe("cache invalidation", h.invalidateChange)
+	h.bus.Subscribe("live events", h.publishChange)
+	h.bus.Subscribe("webhooks", h.notifyChange)
+}
+
+type batchContextKey struct{}
+
+// inBatch marks ctx as making a batch of changes, such as an import, whose
+// caches are invalidated once when it ends rather than after each change.
+func inBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchContextKey{}, true)
+}
+
+// emit emits a change made by the client of r.
+func (h *Handler) emit(r *http.Request, c events.Change) {
+	c.Actor = middleware.ClientID(r)
+	h.bus.Emit(r.Context(), c)
+}
+
+// auditChange records c in the audit trail. Updates that change nothing
+// are not recorded.
+func (h *Handler) auditChange(ctx context.Context, c events.Change) {
+	changes := c.Fields
+	if changes == nil {
+		changes = auditChanges(auditFields(c.Before), auditFields(c.After))
+		if c.Action == events.ActionUpdate && len(changes) == 0 {
+			return
+		}
+	}
+	h.recordAudit(ctx, model.AuditEvent{
+		Actor:    c.Actor,
+		Action:   c.Action,
+		Entity:   c.Entity,
+		EntityID: c.ID,
+		Changes:  changes,
+	})
+}
+
+// invalidateChange clears the caches c makes stale, unless it is part of
+// a batch.
+func (h *Handler) invalidateChange(ctx context.Context, c events.Change) {
+	if batch, _ := ctx.Value(batchContextKey{}).(bool); batch {
+		return
+	}
+	switch c.Entity {
+	case events.EntityTask:
+		h.InvalidateTaskCaches()
+	case events.EntityUser:
+		h.InvalidateUserCaches()
+	case events.EntityBackup, events.EntityFixture:
+		h.InvalidateUserCaches()
+		h.InvalidateTaskCaches()
+	}
+}
+
+// publishChange publishes changes to tasks and users to live clients, and
+// tells them to reload when all data is replaced.
+func (h *Handler) publishChange(ctx context.Context, c events.Change) {
+	switch {
+	case c.Entity == events.EntityTask && c.Action == events.ActionCreate:
+		h.events.Publish(events.TaskCreated, c.After)
+	case c.Entity == events.EntityTask && c.Action == events.ActionUpdate:
+		h.events.Publish(events.TaskUpdated, c.After)
+	case c.Entity == events.EntityUser && c.Action == events.ActionCreate:
+		h.events.Publish(events.UserCreated, c.After)
+	case c.Entity == events.EntityBackup, c.Entity == events.EntityFixture:
+		h.events.Publish(events.Reset, nil)
+	}
+}
+
+// notifyChange sends changes to tasks and users to webhooks, along with
+// task.completed for an update that completed a task.
+func (h *Handler) notifyChange(ctx context.Context, c events.Change) {
+	switch after := c.After.(type) {
+	case model.Task:
+		switch c.Action {
+		case events.ActionCreate:
+			h.webhooks.Notify(ctx, webhook.TaskCreated, fmt.Sprintf("Task #%d %q was created", after.ID, after.Title), after)
+		case events.ActionUpdate:
+			h.webhooks.Notify(ctx, webhook.TaskUpdated, fmt.Sprintf("Task #%d %q was updated", after.ID, after.Title), after)
+			if before, _ := c.Before.(model.Task); after.Status == "completed" && before.Status != "completed" {
+				h.webhooks.Notify(ctx, webhook.TaskCompleted, fmt.Sprintf("Task #%d %q was completed", after.ID, after.Title), after)
+			}
+		}
+	case model.User:
+		if c.Action == events.ActionCreate {
+			h.webhooks.Notify(ctx, webhook.UserCreated, fmt.Sprintf("User #%d %q was created", after.ID, after.Name), after)
+		}
+	}
+}
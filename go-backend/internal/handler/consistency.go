@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go-backend/internal/cache"
+	"go-backend/internal/consistency"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+	"go-backend/internal/validator"
+)
+
+// Consistency checks.
+const (
+	cacheCheck       = "cache"
+	statsCheck       = "stats"
+	persistenceCheck = "persistence"
+)
+
+// cacheSampleUsers is how many users' task lists the cache check samples.
+const cacheSampleUsers = 5
+
+func (h *Handler) registerConsistencyChecks() {
+	h.consistency.Register(cacheCheck, h.checkCache)
+	h.consistency.Register(statsCheck, h.checkStats)
+	if verifier, ok := store.Unwrap(h.store).(store.PersistenceVerifier); ok {
+		h.consistency.Register(persistenceCheck, verifier.VerifyPersisted)
+	}
+}
+
+// ScheduleConsistencyChecks runs the consistency checks every
+// Config.ConsistencyInterval until ctx is done.
+func (h *Handler) ScheduleConsistencyChecks(ctx context.Context) {
+	h.consistency.Schedule(ctx)
+}
+
+// checkCache compares a sample of the cached responses with the responses
+// computed afresh: the users list, stats, the unfiltered task list and the
+// lists by status, and the task lists of a few users picked at random.
+// Entries that are not cached are skipped. A task list's revision is not
+// compared: it may lag behind changes to other records, which only makes
+// ?since= list some changes again.
+func (h *Handler) checkCache(ctx context.Context) ([]string, error) {
+	filters := []taskFilter{{}}
+	for _, status := range validator.Statuses() {
+		filters = append(filters, taskFilter{status: status})
+	}
+	users, err := h.store.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range rand.Perm(len(users))[:min(len(users), cacheSampleUsers)] {
+		filters = append(filters, taskFilter{userID: strconv.Itoa(users[i].ID)})
+	}
+
+	var diffs []string
+	compare := func(key string, fresh func() (interface{}, error), ignore ...string) error {
+		cached, found := h.cache.Get(key)
+		if !found {
+			return nil
+		}
+		response, err := fresh()
+		if err != nil {
+			return err
+		}
+		if !sameJSON(cached, normalizeJSON(response), ignore...) {
+			diffs = append(diffs, fmt.Sprintf("cache entry %q is stale", key))
+		}
+		return nil
+	}
+	if err := compare(cache.UsersKey(), func() (interface{}, error) { return h.usersResponse(ctx) }); err != nil {
+		return nil, err
+	}
+	if err := compare(cache.StatsKey(), func() (interface{}, error) { return h.store.GetStats(ctx), nil }); err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		if err := compare(filter.cacheKey(), func() (interface{}, error) { return h.tasksResponse(ctx, filter, nil) }, "revision"); err != nil {
+			return nil, err
+		}
+	}
+	return diffs, nil
+}
+
+// sameJSON reports whether a and b encode to the same JSON values, apart
+// from the top-level fields ignored, so a cached response can be compared
+// whether the cache kept it as a value or as encoded JSON.
+func sameJSON(a, b interface{}, ignore ...string) bool {
+	decode := func(v interface{}) interface{} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var decoded interface{}
+		json.Unmarshal(data, &decoded)
+		if fields, ok := decoded.(map[string]interface{}); ok {
+			for _, field := range ignore {
+				delete(fields, field)
+			}
+		}
+		return decoded
+	}
+	return reflect.DeepEqual(decode(a), decode(b))
+}
+
+// checkStats compares the statistics the store reports, overall and per
+// user, with counts of the users and tasks it lists.
+func (h *Handler) checkStats(ctx context.Context) ([]string, error) {
+	users, err := h.store.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := h.store.GetTasks(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	reported, counted := h.store.GetStats(ctx), store.CountStats(users, tasks)
+	if !reflect.DeepEqual(reported, counted) {
+		diffs = append(diffs, fmt.Sprintf("stats report %+v but the store holds %+v", reported, counted))
+	}
+
+	byUser := make(map[int][]model.Task)
+	for _, task := range tasks {
+		byUser[task.UserID] = append(byUser[task.UserID], task)
+	}
+	h.store.EachUserStats(ctx, func(row model.UserStats) bool {
+		if counted := store.CountStats(nil, byUser[row.UserID]).Tasks; row.Tasks != counted {
+			diffs = append(diffs, fmt.Sprintf("user %d stats report %+v but the user has %+v", row.UserID, row.Tasks, counted))
+		}
+		return true
+	})
+	return diffs, nil
+}
+
+// getConsistency serves GET /api/admin/consistency: the report of the
+// latest consistency check run.
+func (h *Handler) getConsistency(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.consistency.Last())
+}
+
+// runConsistency serves POST /api/admin/consistency, which runs the
+// checks now.
+func (h *Handler) runConsistency(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.consistency.Run(r.Context()))
+}
+
+// consistencyHealth summarizes the latest consistency check run for the
+// health check.
+func (h *Handler) consistencyHealth() string {
+	report := h.consistency.Last()
+	switch report.Status {
+	case consistency.StatusOK:
+		return "ok"
+	case consistency.StatusPending:
+		return "pending"
+	}
+	var failed []string
+	for _, check := range report.Checks {
+		if check.Status != consistency.StatusOK {
+			failed = append(failed, check.Name)
+		}
+	}
+	return fmt.Sprintf("warning: %s: %s", report.Status, strings.Join(failed, ", "))
+}
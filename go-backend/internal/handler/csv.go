@@ -0,0 +1,537 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/events"
+	"go-backend/internal/locale"
+	"go-backend/internal/middleware"
+	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/validator"
+)
+
+// csvContentType is the content type of CSV exports.
+const csvContentType = "text/csv; charset=utf-8"
+
+// csvSchema describes the columns of a CSV import or export.
+type csvSchema struct {
+	columns  []string // in export order
+	required []string
+}
+
+var (
+	userCSV = csvSchema{
+		columns:  []string{"id", "name", "email", "role"},
+		required: []string{"name", "email", "role"},
+	}
+	taskCSV = csvSchema{
+		columns:  []string{"id", "title", "status", "userId", "priority", "tags", "description", "createdAt", "completedAt", "dueAt"},
+		required: []string{"title", "status", "userId"},
+	}
+)
+
+// csvFormat is how an export writes fields, numbers and times.
+type csvFormat struct {
+	delimiter rune
+	decimal   string
+	// dates writes times; nil writes them in RFC 3339.
+	dates *locale.Pattern
+}
+
+// csvDelimiters and csvDecimals are the values ?delimiter= and ?decimal=
+// accept, by name or as the character itself.
+var (
+	csvDelimiters = map[string]rune{"comma": ',', ",": ',', "semicolon": ';', ";": ';', "tab": '\t', "pipe": '|', "|": '|'}
+	csvDecimals   = map[string]string{"point": ".", ".": ".", "comma": ",", ",": ","}
+)
+
+// parseCSVDelimiter parses the ?delimiter= of an export or import,
+// defaulting to a comma.
+func parseCSVDelimiter(value string) (rune, *fieldError) {
+	if value == "" {
+		return ',', nil
+	}
+	delimiter, ok := csvDelimiters[value]
+	if !ok {
+		return 0, &fieldError{"delimiter", "INVALID_DELIMITER", "Invalid delimiter. Must be one of: comma, semicolon, tab, pipe"}
+	}
+	return delimiter, nil
+}
+
+// csvExportFormat negotiates how an export is written. ?delimiter=,
+// ?decimal= and ?dateFormat= set each explicitly. Otherwise an
+// Accept-Language header picks the conventions of its locale, and without
+// one fields are separated by commas, decimals by points and times are
+// written in RFC 3339. It writes an error and returns false if an option,
+// or ?format=, is invalid.
+func (h *Handler) csvExportFormat(w http.ResponseWriter, r *http.Request) (csvFormat, bool) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "csv" {
+		h.writeError(w, http.StatusBadRequest, "Unsupported format. Must be: csv", "UNSUPPORTED_FORMAT")
+		return csvFormat{}, false
+	}
+
+	f := csvFormat{delimiter: ',', decimal: "."}
+	if r.Header.Get("Accept-Language") != "" {
+		opts, _ := requestOptions(r)
+		// Every locale's pattern is supported
+		dates, _ := locale.ParsePattern(opts.Locale.DateTimeFormat())
+		f = csvFormat{delimiter: opts.Locale.ListSeparator, decimal: opts.Locale.DecimalSeparator, dates: &dates}
+		setLocaleHeaders(w, opts.Locale)
+	} else {
+		w.Header().Add("Vary", "Accept-Language")
+	}
+
+	var ferrs []*fieldError
+	if value := query.Get("delimiter"); value != "" {
+		var ferr *fieldError
+		if f.delimiter, ferr = parseCSVDelimiter(value); ferr != nil {
+			ferrs = append(ferrs, ferr)
+		}
+	}
+	if value := query.Get("decimal"); value != "" {
+		var ok bool
+		if f.decimal, ok = csvDecimals[value]; !ok {
+			ferrs = append(ferrs, &fieldError{"decimal", "INVALID_DECIMAL", "Invalid decimal. Must be one of: point, comma"})
+		}
+	}
+	switch value := query.Get("dateFormat"); value {
+	case "":
+	case "iso":
+		f.dates = nil
+	default:
+		dates, err := locale.ParsePattern(value)
+		if err != nil {
+			ferrs = append(ferrs, &fieldError{"dateFormat", "INVALID_DATE_FORMAT", "Invalid dateFormat. Must be iso or a date pattern such as dd.MM.yyyy HH:mm: " + err.Error()})
+		}
+		f.dates = &dates
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return csvFormat{}, false
+	}
+	return f, true
+}
+
+// writer returns a CSV writer to w separating fields with the format's
+// delimiter.
+func (f csvFormat) writer(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	cw.Comma = f.delimiter
+	return cw
+}
+
+// time writes *t in loc, or nothing if t is nil or zero.
+func (f csvFormat) time(t *time.Time, loc *time.Location) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	if f.dates == nil {
+		return t.In(loc).Format(time.RFC3339)
+	}
+	return f.dates.Format(t.In(loc))
+}
+
+// value writes a report value; unset values are empty.
+func (f csvFormat) value(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", f.decimal, 1)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// csvRow is one data row of an import, keyed by column name.
+type csvRow struct {
+	line   int // line in the file, counting the header as line 1
+	values map[string]string
+}
+
+// parseHeader maps column names to their index, rejecting unknown,
+// duplicate and missing required columns.
+func (cs csvSchema) parseHeader(header []string) (map[string]int, error) {
+	known := make(map[string]bool, len(cs.columns))
+	for _, col := range cs.columns {
+		known[col] = true
+	}
+
+	index := make(map[string]int, len(header))
+	for i, col := range header {
+		col = strings.TrimSpace(col)
+		if !known[col] {
+			return nil, fmt.Errorf("unknown column %q; expected columns: %s", col, strings.Join(cs.columns, ", "))
+		}
+		if _, dup := index[col]; dup {
+			return nil, fmt.Errorf("duplicate column %q", col)
+		}
+		index[col] = i
+	}
+
+	for _, col := range cs.required {
+		if _, ok := index[col]; !ok {
+			return nil, fmt.Errorf("missing required column %q", col)
+		}
+	}
+	return index, nil
+}
+
+// readCSV reads a CSV body with a header row, its fields separated by
+// delimiter. Rows with the wrong number of fields are reported as row
+// errors rather than failing the whole file.
+func (cs csvSchema) readCSV(body io.Reader, delimiter rune) ([]csvRow, []model.ImportRowError, error) {
+	reader := csv.NewReader(body)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("CSV body is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	index, err := cs.parseHeader(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []csvRow
+	var rowErrors []model.ImportRowError
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		if len(record) != len(header) {
+			rowErrors = append(rowErrors, model.ImportRowError{
+				Row:     line,
+				Code:    "INVALID_ROW",
+				Message: fmt.Sprintf("expected %d fields, got %d", len(header), len(record)),
+			})
+			continue
+		}
+
+		values := make(map[string]string, len(index))
+		for col, i := range index {
+			values[col] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, csvRow{line: line, values: values})
+	}
+
+	return rows, rowErrors, nil
+}
+
+// rowError converts a field error into a row error for the report.
+func rowError(line int, ferr *fieldError) model.ImportRowError {
+	return model.ImportRowError{
+		Row:     line,
+		Field:   ferr.Field,
+		Code:    ferr.Code,
+		Message: ferr.Message,
+	}
+}
+
+// fieldRowErrors converts the field errors of one row.
+func fieldRowErrors(line int, ferrs []*fieldError) []model.ImportRowError {
+	errs := make([]model.ImportRowError, len(ferrs))
+	for i, ferr := range ferrs {
+		errs[i] = rowError(line, ferr)
+	}
+	return errs
+}
+
+// startCSVDownload sets the headers for a CSV attachment.
+func startCSVDownload(w http.ResponseWriter, filename string) {
+	w.Header().Set("Content-Type", csvContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+}
+
+func (h *Handler) handleUsersExport(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.csvExportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	// Export from a snapshot, so the file is consistent however long it
+	// takes to send
+	snap, err := h.store.Snapshot(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to export data", "INTERNAL_ERROR")
+		return
+	}
+	setSnapshotRevision(w, snap)
+	startCSVDownload(w, "users.csv")
+
+	cw := f.writer(w)
+	cw.Write(userCSV.columns)
+	for _, user := range snap.Users() {
+		cw.Write([]string{strconv.Itoa(user.ID), user.Name, user.Email, user.Role})
+	}
+	cw.Flush()
+}
+
+func (h *Handler) handleTasksExport(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.csvExportFormat(w, r)
+	if !ok {
+		return
+	}
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+
+	// Export from a snapshot, so the file is consistent however long it
+	// takes to send, and every task's assignee is present
+	snap, err := h.store.Snapshot(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to export data", "INTERNAL_ERROR")
+		return
+	}
+	setSnapshotRevision(w, snap)
+	startCSVDownload(w, "tasks.csv")
+
+	zone := zonesOf(snap.Users(), opts.Location)
+	cw := f.writer(w)
+	cw.Write(taskCSV.columns)
+	for _, task := range snap.Tasks() {
+		loc := zone(task.UserID)
+		cw.Write([]string{strconv.Itoa(task.ID), task.Title, task.Status, strconv.Itoa(task.UserID), task.Priority, strings.Join(task.Tags, ","), task.Description,
+			f.time(&task.CreatedAt, loc), f.time(task.CompletedAt, loc), f.time(task.DueAt, loc)})
+	}
+	cw.Flush()
+}
+
+func (h *Handler) handleUsersImport(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.ClientID(r)
+	h.handleImport(w, r, csvImport{
+		schema:     userCSV,
+		kind:       "users",
+		validate:   h.validateUserRows,
+		invalidate: h.InvalidateUserCaches,
+		create: func(ctx context.Context, row csvRow) error {
+			user, err := h.store.CreateUser(ctx, model.User{Name: row.values["name"], Email: row.values["email"], Role: row.values["role"]})
+			if err != nil {
+				return err
+			}
+			h.bus.Emit(ctx, events.Change{Entity: events.EntityUser, Action: events.ActionCreate, ID: strconv.Itoa(user.ID), Actor: actor, After: user})
+			return nil
+		},
+	})
+}
+
+func (h *Handler) handleTasksImport(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.ClientID(r)
+	h.handleImport(w, r, csvImport{
+		schema:     taskCSV,
+		kind:       "tasks",
+		validate:   h.validateTaskRows,
+		invalidate: h.InvalidateTaskCaches,
+		create: func(ctx context.Context, row csvRow) error {
+			userID, _ := strconv.Atoi(row.values["userId"])
+			task, err := h.store.CreateTask(ctx, model.Task{Title: row.values["title"], Description: row.values["description"], Status: row.values["status"], Priority: row.values["priority"], Tags: csvTags(row.values["tags"]), UserID: userID})
+			if err != nil {
+				return err
+			}
+			h.bus.Emit(ctx, events.Change{Entity: events.EntityTask, Action: events.ActionCreate, ID: strconv.Itoa(task.ID), Actor: actor, After: task})
+			return nil
+		},
+	})
+}
+
+// csvImport describes how to import one kind of record.
+type csvImport struct {
+	schema     csvSchema
+	kind       string
+	validate   func(context.Context, []csvRow) ([]model.ImportRowError, error)
+	create     func(context.Context, csvRow) error
+	invalidate func()
+}
+
+// handleImport reads a CSV body, validates every row and, unless the file
+// has errors or ?dryRun=true is set, creates a record per row. Imports are
+// all or nothing: one invalid row rejects the whole file. IDs and times in
+// the file are ignored; new records get server-assigned IDs. ?delimiter=
+// reads files whose fields are not separated by commas.
+//
+// With ?async=true, validation and creation run as an operation instead;
+// an invalid file fails the operation with the report as its result.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request, imp csvImport) {
+	delimiter, ferr := parseCSVDelimiter(r.URL.Query().Get("delimiter"))
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+
+	rows, rowErrors, err := imp.schema.readCSV(r.Body, delimiter)
+	if writeBodyTooLarge(w, err) {
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error(), "INVALID_CSV")
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	if wantsAsync(r) {
+		h.startOperation(w, "import-"+imp.kind, func(ctx context.Context, p *operation.Progress) (interface{}, error) {
+			report, err := imp.run(ctx, p, rows, rowErrors, dryRun)
+			if err == nil && !report.Success {
+				err = fmt.Errorf("import has %d invalid rows", len(report.Errors))
+			}
+			return report, err
+		})
+		return
+	}
+
+	// Without cancellation, only the store failing stops the import
+	report, err := imp.run(context.WithoutCancel(r.Context()), nil, rows, rowErrors, dryRun)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to import %s after %d rows", imp.kind, report.Imported), "INTERNAL_ERROR")
+		return
+	}
+
+	status := http.StatusOK
+	if !report.Success && !dryRun {
+		status = http.StatusBadRequest
+	}
+	h.writeJSON(w, status, report)
+}
+
+// run validates rows and, if they are all valid and this is not a dry run,
+// creates them in file order, reporting each row to p.
+//
+// Cancelling ctx stops the import before the next row and returns
+// ctx.Err(), as a row the store fails to create stops it with the
+// store's error. Records already created are kept rather than rolled back:
+// the report's Imported count says how many, and they are always the
+// first rows of the file, so the rest can be imported again on their own.
+func (imp csvImport) run(ctx context.Context, p *operation.Progress, rows []csvRow,
+	rowErrors []model.ImportRowError, dryRun bool) (model.ImportReport, error) {
+	p.SetTotal(len(rows))
+
+	invalid, err := imp.validate(ctx, rows)
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	errs := append(append([]model.ImportRowError{}, rowErrors...), invalid...)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+	p.AddErrors(len(errs))
+
+	report := model.ImportReport{
+		Success: len(errs) == 0,
+		DryRun:  dryRun,
+		Total:   len(rows) + len(rowErrors),
+		Errors:  errs,
+	}
+	// A lookup cut short by cancellation can misreport a row as invalid
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	if !report.Success || dryRun {
+		return report, nil
+	}
+
+	defer func() {
+		if report.Imported > 0 {
+			imp.invalidate()
+		}
+	}()
+
+	// Each row is created whole, even if ctx is cancelled meanwhile. The
+	// caches are invalidated once, above, rather than per row.
+	createCtx := inBatch(context.WithoutCancel(ctx))
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := imp.create(createCtx, row); err != nil {
+			return report, err
+		}
+		report.Imported++
+		p.Add(1)
+	}
+	return report, nil
+}
+
+// validateUserRows validates user rows against the store and each other.
+func (h *Handler) validateUserRows(ctx context.Context, rows []csvRow) ([]model.ImportRowError, error) {
+	var errs []model.ImportRowError
+	seen := make(map[string]int)
+	for _, row := range rows {
+		email := validator.NormalizeEmail(row.values["email"])
+		req := model.CreateUserRequest{Name: row.values["name"], Email: email, Role: row.values["role"]}
+		ferrs, err := h.validateNewUser(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(ferrs) > 0 {
+			errs = append(errs, fieldRowErrors(row.line, ferrs)...)
+			continue
+		}
+		if first, dup := seen[email]; dup {
+			errs = append(errs, rowError(row.line, &fieldError{
+				"email", "EMAIL_EXISTS", fmt.Sprintf("Email duplicates row %d", first),
+			}))
+			continue
+		}
+		seen[email] = row.line
+	}
+	return errs, nil
+}
+
+// csvTags splits the comma-separated tags column of a task row.
+func csvTags(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// validateTaskRows validates task rows against the store.
+func (h *Handler) validateTaskRows(ctx context.Context, rows []csvRow) ([]model.ImportRowError, error) {
+	var errs []model.ImportRowError
+	for _, row := range rows {
+		req := model.CreateTaskRequest{Title: row.values["title"], Description: row.values["description"], Status: row.values["status"], Priority: row.values["priority"], Tags: csvTags(row.values["tags"])}
+		userID, err := strconv.Atoi(row.values["userId"])
+		if err != nil {
+			ferrs := append(fieldErrors(validator.Validate(req)),
+				&fieldError{"userId", "INVALID_USER_ID", "User ID must be a number"})
+			errs = append(errs, fieldRowErrors(row.line, ferrs)...)
+			continue
+		}
+		req.UserID = userID
+		ferrs, err := h.validateNewTask(ctx, req, nil)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, fieldRowErrors(row.line, ferrs)...)
+	}
+	return errs, nil
+}
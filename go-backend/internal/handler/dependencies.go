@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-backend/internal/events"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+	"go-backend/internal/validator"
+)
+
+// handleRemoveDependency serves DELETE
+// /api/tasks/{id}/dependencies/{blockerId}.
+func (h *Handler) handleRemoveDependency(w http.ResponseWriter, r *http.Request, id int) {
+	blockerID, err := strconv.Atoi(r.PathValue("blockerId"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid blocker ID", "INVALID_ID")
+		return
+	}
+	h.removeDependency(w, r, id, blockerID)
+}
+
+// getDependencies lists the tasks blocking a task and the tasks it blocks.
+func (h *Handler) getDependencies(w http.ResponseWriter, r *http.Request, id int) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+	task := h.findTask(w, r, id)
+	if task == nil {
+		return
+	}
+
+	tasks, err := h.store.GetTasks(r.Context(), "", "")
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list dependencies", "INTERNAL_ERROR")
+		return
+	}
+	response := model.DependenciesResponse{BlockedBy: []model.Task{}, Blocks: []model.Task{}}
+	for _, t := range tasks {
+		if slices.Contains(task.BlockedBy, t.ID) {
+			response.BlockedBy = append(response.BlockedBy, t)
+		}
+		if slices.Contains(t.BlockedBy, id) {
+			response.Blocks = append(response.Blocks, t)
+		}
+	}
+	response.BlockedBy = h.renderTasks(r.Context(), response.BlockedBy, opts.Location)
+	response.Blocks = h.renderTasks(r.Context(), response.Blocks, opts.Location)
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// addDependency records that the task in the body blocks task id and
+// returns the updated task. Dependencies that would make a task block
+// itself are rejected.
+func (h *Handler) addDependency(w http.ResponseWriter, r *http.Request, id int) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+	task := h.findTask(w, r, id)
+	if task == nil {
+		return
+	}
+
+	var req model.AddDependencyRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	ferrs := fieldErrors(validator.Validate(req))
+	if req.BlockedBy != nil {
+		blocker, err := h.store.GetTaskByID(r.Context(), *req.BlockedBy)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to read task", "INTERNAL_ERROR")
+			return
+		}
+		if blocker == nil {
+			ferrs = append(ferrs, &fieldError{"blockedBy", "INVALID_BLOCKED_BY", "Task does not exist"})
+		}
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	// Read before updating: the store may return its own record
+	before := *task
+	updated, err := h.store.AddBlocker(r.Context(), id, *req.BlockedBy)
+	switch {
+	case errors.Is(err, store.ErrDependencyCycle):
+		h.writeError(w, http.StatusConflict, fmt.Sprintf("Task %d already depends on task %d", *req.BlockedBy, id), "DEPENDENCY_CYCLE")
+		return
+	case errors.Is(err, store.ErrTaskNotFound):
+		h.writeError(w, http.StatusNotFound, "Task not found", "TASK_NOT_FOUND")
+		return
+	case err != nil:
+		h.writeError(w, http.StatusInternalServerError, "Failed to add dependency", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityTask, Action: events.ActionUpdate, ID: strconv.Itoa(id), Before: before, After: *updated})
+
+	h.writeJSON(w, http.StatusOK, renderTask(*updated, h.taskLocation(r.Context(), opts.Location, updated.UserID)))
+}
+
+// removeDependency records that task blockerID no longer blocks task id.
+func (h *Handler) removeDependency(w http.ResponseWriter, r *http.Request, id, blockerID int) {
+	task := h.findTask(w, r, id)
+	if task == nil {
+		return
+	}
+	before := *task
+	updated, err := h.store.RemoveBlocker(r.Context(), id, blockerID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to remove dependency", "INTERNAL_ERROR")
+		return
+	}
+	if updated == nil {
+		h.writeError(w, http.StatusNotFound, "Dependency not found", "DEPENDENCY_NOT_FOUND")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityTask, Action: events.ActionUpdate, ID: strconv.Itoa(id), Before: before, After: *updated})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// openBlockers returns the IDs of the tasks blocking task that are not
+// completed yet.
+func (h *Handler) openBlockers(ctx context.Context, task model.Task) ([]int, error) {
+	var open []int
+	for _, id := range task.BlockedBy {
+		blocker, err := h.store.GetTaskByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if blocker != nil && blocker.Status != "completed" {
+			open = append(open, id)
+		}
+	}
+	return open, nil
+}
+
+// openTaskIDs returns the IDs of all tasks that are not completed.
+func (h *Handler) openTaskIDs(ctx context.Context) (map[int]bool, error) {
+	tasks, err := h.store.GetTasks(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	open := make(map[int]bool)
+	for _, task := range tasks {
+		if task.Status != "completed" {
+			open[task.ID] = true
+		}
+	}
+	return open, nil
+}
+
+// joinIDs formats ids as a comma-separated list.
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,16 @@
+package handler
+
+import "go-backend/internal/middleware"
+
+// deprecations is the route metadata table of deprecated routes and fields.
+// Add an entry here before removing or renaming anything in the public API
+// so consumers receive Deprecation/Sunset headers ahead of the change.
+//
+// Example:
+//
+//	"GET /api/stats": {
+//		Sunset: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+//		Link:   "https://example.com/docs/migrations/stats-v2",
+//		Fields: []string{"users.total"},
+//	},
+var deprecations = middleware.DeprecationTable{}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+)
+
+// Severities of diagnostic findings, most severe first.
+const (
+	severityCritical = "critical"
+	severityWarning  = "warning"
+	severityInfo     = "info"
+)
+
+var severityRank = map[string]int{severityCritical: 0, severityWarning: 1, severityInfo: 2}
+
+// Thresholds of the self-checks.
+const (
+	// diskCriticalBytes and diskWarningShare are the free space below
+	// which the data disk is reported, in bytes and as a share of the disk.
+	diskCriticalBytes = 100 << 20
+	diskWarningShare  = 0.1
+	// clockWarningSkew and clockCriticalSkew are how far the server's
+	// clock may drift, from the store's or from when it started, before
+	// it is reported.
+	clockWarningSkew  = 5 * time.Second
+	clockCriticalSkew = time.Minute
+	// goroutineBudget is how many goroutines the server may run besides
+	// goroutinesPerStream for each event stream and socket before a leak
+	// is suspected.
+	goroutineBudget     = 1000
+	goroutinesPerStream = 4
+	// maxDanglingFindings is how many dangling references are listed.
+	maxDanglingFindings = 20
+)
+
+// diagnostic is a self-check run by /api/admin/diagnose.
+type diagnostic struct {
+	name  string
+	check func(ctx context.Context) []model.DiagnosticFinding
+}
+
+func (h *Handler) diagnostics() []diagnostic {
+	return []diagnostic{
+		{"disk", h.diagnoseDisk},
+		{"permissions", h.diagnosePermissions},
+		{"clock", h.diagnoseClock},
+		{"config", h.diagnoseConfig},
+		{"references", h.diagnoseReferences},
+		{"goroutines", h.diagnoseGoroutines},
+	}
+}
+
+// handleDiagnose serves /api/admin/diagnose: it runs the self-checks and
+// lists what they found, most severe first, with what to do about each.
+func (h *Handler) handleDiagnose(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.diagnose(r.Context()))
+}
+
+// diagnose runs every self-check.
+func (h *Handler) diagnose(ctx context.Context) model.Diagnosis {
+	diagnosis := model.Diagnosis{
+		Status:    "ok",
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+		Checks:    []string{},
+		Findings:  []model.DiagnosticFinding{},
+	}
+	for _, d := range h.diagnostics() {
+		diagnosis.Checks = append(diagnosis.Checks, d.name)
+		for _, finding := range d.check(ctx) {
+			finding.Check = d.name
+			diagnosis.Findings = append(diagnosis.Findings, finding)
+		}
+	}
+
+	// Most severe first, keeping the order of the checks within a severity
+	sort.SliceStable(diagnosis.Findings, func(i, j int) bool {
+		return severityRank[diagnosis.Findings[i].Severity] < severityRank[diagnosis.Findings[j].Severity]
+	})
+	if len(diagnosis.Findings) > 0 && diagnosis.Findings[0].Severity != severityInfo {
+		diagnosis.Status = diagnosis.Findings[0].Severity
+	}
+	diagnosis.Count = len(diagnosis.Findings)
+	return diagnosis
+}
+
+// finding returns a finding of the given severity.
+func finding(severity, action, format string, args ...interface{}) model.DiagnosticFinding {
+	return model.DiagnosticFinding{Severity: severity, Message: fmt.Sprintf(format, args...), Action: action}
+}
+
+// dataDir returns the directory the store saves its data file in, or ""
+// if it does not save to files.
+func (h *Handler) dataDir() string {
+	backed, ok := store.Unwrap(h.store).(store.FileBacked)
+	if !ok || backed.DataPath() == "" {
+		return ""
+	}
+	return filepath.Dir(backed.DataPath())
+}
+
+// diagnoseDisk reports the data disk running out of space.
+func (h *Handler) diagnoseDisk(ctx context.Context) []model.DiagnosticFinding {
+	dir := h.dataDir()
+	if dir == "" {
+		return []model.DiagnosticFinding{finding(severityInfo, "", "Not checked: the store does not save to files")}
+	}
+	available, total, err := diskSpace(dir)
+	if err != nil {
+		return []model.DiagnosticFinding{finding(severityInfo, "", "Could not read the free space of %s: %v", dir, err)}
+	}
+
+	const action = "Free up or add space; changes that cannot be saved are lost on restart."
+	switch {
+	case available < diskCriticalBytes:
+		return []model.DiagnosticFinding{finding(severityCritical, action, "Only %d MiB free on the disk of %s", available>>20, dir)}
+	case total > 0 && float64(available)/float64(total) < diskWarningShare:
+		return []model.DiagnosticFinding{finding(severityWarning, action, "Only %.0f%% of the disk of %s is free", 100*float64(available)/float64(total), dir)}
+	}
+	return nil
+}
+
+// diagnosePermissions reports a data file or directory the server cannot
+// write to. It creates and removes a temporary file to find out, as the
+// store does when it saves.
+func (h *Handler) diagnosePermissions(ctx context.Context) []model.DiagnosticFinding {
+	dir := h.dataDir()
+	if dir == "" {
+		return []model.DiagnosticFinding{finding(severityInfo, "", "Not checked: the store does not save to files")}
+	}
+
+	const action = "Give the user the server runs as write access to the data directory and file."
+	tmp, err := os.CreateTemp(dir, ".diagnose-*")
+	if err != nil {
+		return []model.DiagnosticFinding{finding(severityCritical, action, "Cannot write to the data directory: %v", err)}
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+
+	path := store.Unwrap(h.store).(store.FileBacked).DataPath()
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return []model.DiagnosticFinding{finding(severityInfo, "", "The data file %s has not been written yet", path)}
+	case err != nil:
+		return []model.DiagnosticFinding{finding(severityCritical, action, "Cannot read the data file: %v", err)}
+	case info.Mode().Perm()&0o200 == 0:
+		return []model.DiagnosticFinding{finding(severityCritical, action, "The data file %s is read-only", path)}
+	}
+	return nil
+}
+
+// diagnoseClock reports the server's clock drifting from the store's, or
+// jumping since the server started, and records dated in the future,
+// which suggest the clock was set back.
+func (h *Handler) diagnoseClock(ctx context.Context) []model.DiagnosticFinding {
+	var findings []model.DiagnosticFinding
+	const action = "Run a time synchronization service such as NTP; due dates, expiry and rate limits depend on the clock."
+
+	if clock, ok := store.Unwrap(h.store).(store.Clock); ok {
+		storeNow, err := clock.Now(ctx)
+		if err != nil {
+			findings = append(findings, finding(severityWarning, "Check the store is reachable.", "Could not read the store's clock: %v", err))
+		} else if skew := time.Since(storeNow).Abs(); skew > clockWarningSkew {
+			findings = append(findings, finding(clockSeverity(skew), action, "The server's clock is %s apart from the store's", skew.Round(time.Second)))
+		}
+	}
+
+	if start := h.config.StartTime; !start.IsZero() {
+		// The wall clock and the monotonic clock advance alike unless the
+		// wall clock is changed
+		if jump := (time.Now().Round(0).Sub(start.Round(0)) - time.Since(start)).Abs(); jump > clockWarningSkew {
+			findings = append(findings, finding(clockSeverity(jump), action, "The server's clock has been changed by %s since it started", jump.Round(time.Second)))
+		}
+	}
+
+	tasks, err := h.store.GetTasks(ctx, "", "")
+	if err != nil {
+		return append(findings, finding(severityWarning, "Check the store is reachable.", "Could not read the tasks: %v", err))
+	}
+	future := 0
+	now := time.Now()
+	for _, task := range tasks {
+		if task.CreatedAt.Sub(now) > clockWarningSkew {
+			future++
+		}
+	}
+	if future > 0 {
+		findings = append(findings, finding(severityWarning, action, "%d tasks were created in the future; the clock may have been set back", future))
+	}
+	return findings
+}
+
+// clockSeverity returns the severity of a clock skew.
+func clockSeverity(skew time.Duration) string {
+	if skew > clockCriticalSkew {
+		return severityCritical
+	}
+	return severityWarning
+}
+
+// diagnoseConfig reports settings that are unsafe or contradict each
+// other.
+func (h *Handler) diagnoseConfig(ctx context.Context) []model.DiagnosticFinding {
+	var findings []model.DiagnosticFinding
+	cfg := h.config
+	server := cfg.Server.withDefaults()
+
+	if cfg.ResetKey != "" && !cfg.Sandbox {
+		findings = append(findings, finding(severityCritical, "Unset DATA_RESET_ENABLED, or reset only the sandbox instance.",
+			"Reset is enabled outside a sandbox: anyone with the key can replace all data"))
+	}
+	if server.WriteTimeout <= server.RequestTimeout {
+		findings = append(findings, finding(severityWarning, "Set HTTP_WRITE_TIMEOUT above REQUEST_TIMEOUT.",
+			"The write timeout (%s) does not exceed the request timeout (%s), so clients of timed-out requests are not told", server.WriteTimeout, server.RequestTimeout))
+	}
+	if server.MaxUploadBytes < server.MaxBodyBytes {
+		findings = append(findings, finding(severityWarning, "Set MAX_UPLOAD_BYTES to at least MAX_BODY_BYTES.",
+			"Uploads are capped at %d bytes, below the %d bytes of other requests", server.MaxUploadBytes, server.MaxBodyBytes))
+	}
+	if cfg.DebugHeaders {
+		findings = append(findings, finding(severityInfo, "Unset DEBUG_HEADERS for public deployments.", "Debug headers expose cache and timing internals"))
+	}
+	if cfg.RateLimiter == nil {
+		findings = append(findings, finding(severityInfo, "", "Requests are not rate limited"))
+	}
+	return findings
+}
+
+// diagnoseReferences reports records referring to records that do not
+// exist. A backup of them would be rejected on restore.
+func (h *Handler) diagnoseReferences(ctx context.Context) []model.DiagnosticFinding {
+	snap, err := h.store.Snapshot(ctx)
+	if err != nil {
+		return []model.DiagnosticFinding{finding(severityCritical, "Check the store is reachable.", "Could not read the data: %v", err)}
+	}
+	users := make(map[int]bool)
+	for _, user := range snap.Users() {
+		users[user.ID] = true
+	}
+	projects := make(map[int]bool)
+	for _, project := range snap.Projects() {
+		projects[project.ID] = true
+	}
+	tasks := make(map[int]bool)
+	for _, task := range snap.Tasks() {
+		tasks[task.ID] = true
+	}
+
+	var dangling []string
+	for _, task := range snap.Tasks() {
+		if !users[task.UserID] {
+			dangling = append(dangling, fmt.Sprintf("Task %d is assigned to unknown user %d", task.ID, task.UserID))
+		}
+		if task.ProjectID != 0 && !projects[task.ProjectID] {
+			dangling = append(dangling, fmt.Sprintf("Task %d is in unknown project %d", task.ID, task.ProjectID))
+		}
+		for _, id := range task.BlockedBy {
+			if !tasks[id] {
+				dangling = append(dangling, fmt.Sprintf("Task %d is blocked by unknown task %d", task.ID, id))
+			}
+		}
+	}
+	for _, share := range snap.Shares() {
+		if !tasks[share.TaskID] {
+			dangling = append(dangling, fmt.Sprintf("Share %s is of unknown task %d", share.ID, share.TaskID))
+		}
+	}
+
+	const action = "Update or delete the records; backups are rejected on restore until then."
+	var findings []model.DiagnosticFinding
+	for _, message := range dangling[:min(len(dangling), maxDanglingFindings)] {
+		findings = append(findings, finding(severityWarning, action, "%s", message))
+	}
+	if len(dangling) > maxDanglingFindings {
+		findings = append(findings, finding(severityWarning, action, "%d more dangling references", len(dangling)-maxDanglingFindings))
+	}
+	return findings
+}
+
+// diagnoseGoroutines reports more goroutines running than the open event
+// streams and sockets account for, a sign that some are leaking.
+func (h *Handler) diagnoseGoroutines(ctx context.Context) []model.DiagnosticFinding {
+	streams := h.events.Subscribers()
+	running := runtime.NumGoroutine()
+	if expected := goroutineBudget + goroutinesPerStream*streams; running > expected {
+		return []model.DiagnosticFinding{finding(severityWarning, "Check again later; if the count keeps growing, restart the server and report it.",
+			"%d goroutines are running, more than the %d expected with %d event streams and sockets open", running, expected, streams)}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package handler
+
+import "errors"
+
+// diskSpace is not supported on this platform.
+func diskSpace(path string) (available, total uint64, err error) {
+	return 0, 0, errors.New("disk space is not reported on this platform")
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package handler
+
+import "syscall"
+
+// diskSpace returns the bytes available to the server and the total size
+// of the file system holding path.
+func diskSpace(path string) (available, total uint64, err error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, 0, err
+	}
+	return uint64(fs.Bavail) * uint64(fs.Bsize), uint64(fs.Blocks) * uint64(fs.Bsize), nil
+}
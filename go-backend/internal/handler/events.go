@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/events"
+)
+
+// eventStreamContentType is the content type of Server-Sent Events streams.
+const eventStreamContentType = "text/event-stream"
+
+// DefaultEventHeartbeat is how often idle event streams send a comment, so
+// proxies and clients can tell a quiet stream from a dead one.
+const DefaultEventHeartbeat = 15 * time.Second
+
+// eventRetry is the reconnection delay event stream clients are told to use.
+const eventRetry = 3 * time.Second
+
+// handleEvents streams task and user changes as Server-Sent Events.
+// ?types= limits the stream to a comma-separated list of event types. A
+// client reconnecting with Last-Event-ID first gets the events it missed,
+// or a "reset" event if they are no longer known.
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var types []string
+	if raw := r.URL.Query().Get("types"); raw != "" {
+		for _, typ := range strings.Split(raw, ",") {
+			typ = strings.TrimSpace(typ)
+			if !events.ValidType(typ) {
+				h.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid event type %q", typ), "INVALID_EVENT_TYPE")
+				return
+			}
+			types = append(types, typ)
+		}
+	}
+
+	sub, missed, err := h.events.Subscribe(lastEventID(r), types...)
+	switch {
+	case errors.Is(err, events.ErrTooManySubscribers):
+		w.Header().Set("Retry-After", strconv.Itoa(int(eventRetry.Seconds())))
+		h.writeError(w, http.StatusServiceUnavailable, "Too many event streams", "TOO_MANY_STREAMS")
+		return
+	case err != nil:
+		h.writeError(w, http.StatusServiceUnavailable, "Server is shutting down", "SHUTTING_DOWN")
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stop proxies such as nginx from holding events back
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	for _, event := range missed {
+		if writeEvent(w, event) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.eventHeartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			// The subscription ends if the hub closes or the client falls
+			// too far behind; the client reconnects and catches up
+			if !ok || writeEvent(w, event) != nil || rc.Flush() != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// lastEventID returns the ID of the last event the client saw, from the
+// Last-Event-ID header browsers send when reconnecting, or the
+// ?lastEventId= parameter for clients that cannot set headers. It returns
+// 0 if there is none.
+func lastEventID(r *http.Request) uint64 {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+	id, _ := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	return id
+}
+
+// writeEvent writes event in the Server-Sent Events format. The data is
+// the whole event as one line of JSON.
+func writeEvent(w io.Writer, event events.Event) error {
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: ", event.ID, event.Type); err != nil {
+		return err
+	}
+	// The encoder ends the line; a blank line ends the event
+	if err := encodeJSON(w, event); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// eventHeartbeat returns how often idle event streams send a heartbeat.
+func (h *Handler) eventHeartbeat() time.Duration {
+	if h.config.EventHeartbeat > 0 {
+		return h.config.EventHeartbeat
+	}
+	return DefaultEventHeartbeat
+}
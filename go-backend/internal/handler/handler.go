@@ -2,96 +2,798 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go-backend/internal/attachment"
 	"go-backend/internal/cache"
+	"go-backend/internal/codec"
+	"go-backend/internal/consistency"
+	"go-backend/internal/digest"
+	"go-backend/internal/events"
+	"go-backend/internal/mail"
 	"go-backend/internal/middleware"
 	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/report"
 	"go-backend/internal/store"
+	"go-backend/internal/unfurl"
+	"go-backend/internal/webhook"
 )
 
+// statsCacheTTL is how long /api/stats responses are cached.
+const statsCacheTTL = 30 * time.Minute
+
+// ndjsonContentType is the content type of newline-delimited JSON streams.
+const ndjsonContentType = "application/x-ndjson"
+
 // Config holds handler configuration.
 type Config struct {
 	Version   string
 	StartTime time.Time
+	// DebugHeaders enables X-Cache and X-Response-Time-ms response headers.
+	// They expose internals, so keep this off for public deployments.
+	DebugHeaders bool
+	// RateLimiter enables per-IP rate limiting when set.
+	RateLimiter middleware.Limiter
+	// AuthRateLimiter limits password attempts per IP and per target account
+	// when set. It is configured separately so it can be stricter than RateLimiter.
+	AuthRateLimiter middleware.Limiter
+	// VersionPins maps API keys to the API version their responses are shaped for.
+	VersionPins map[string]string
+	// FieldNamingPins maps API keys to the field naming their clients
+	// expect: middleware.NamingSnakeCase for legacy clients.
+	FieldNamingPins map[string]string
+	// IDCodec, when set, shows clients encoded IDs instead of the integers
+	// records are stored under, so they cannot be enumerated; see
+	// middleware.ObfuscateIDs.
+	IDCodec middleware.IDCodec
+	// Encodings are the media types besides JSON clients may send and ask
+	// for, such as XML; see middleware.Encodings. When nil,
+	// codec.Defaults() are offered.
+	Encodings []codec.Codec
+	// Sandbox marks responses from a sandbox instance with an X-Sandbox header.
+	Sandbox bool
+	// MaxInFlight caps concurrent requests when positive. Under load, health
+	// and admin requests are admitted ahead of reads, reads ahead of writes,
+	// and writes ahead of exports.
+	MaxInFlight int
+	// OperationRetention is how long finished async operations can be polled.
+	// Zero uses operation.DefaultRetention.
+	OperationRetention time.Duration
+	// OperationLimits bound how many async operations run and are kept,
+	// and how long each may run. Zero fields use the operation defaults.
+	OperationLimits operation.Limits
+	// WarmCache pre-populates the users list, unfiltered tasks list and stats
+	// at startup and again after each invalidation.
+	WarmCache bool
+	// Server bounds how long clients may take over requests and responses.
+	Server ServerConfig
+	// TLS, when set, has Start serve HTTPS.
+	TLS TLSConfig
+	// Digest is the digest email job run by POST /api/admin/digest. When
+	// nil, a job that logs digests instead of mailing them is used.
+	Digest *digest.Job
+	// CapacityWeights configure /api/reports/capacity. Zero fields use
+	// the defaults.
+	CapacityWeights report.CapacityWeights
+	// Attachments stores files attached to tasks. When nil, attachments
+	// are kept in memory and not scanned.
+	Attachments *attachment.Manager
+	// ReportLimits cap the estimated cost of custom reports; costlier ones
+	// need ?force=true. Zero fields use the defaults.
+	ReportLimits report.Limits
+	// ReportRefresh is how often materialized reports such as
+	// /api/reports/capacity are recomputed by ScheduleReports. Zero uses
+	// report.DefaultRefreshInterval.
+	ReportRefresh time.Duration
+	// StatsRefresh, when positive, serves GET /api/stats from a value
+	// recomputed every StatsRefresh by ScheduleStats rather than computed
+	// per request, so its latency does not grow with the store. Zero
+	// computes stats on request, cached until the next write.
+	StatsRefresh time.Duration
+	// StatsHistoryInterval is how often ScheduleStatsHistory records a
+	// snapshot of the stats for /api/stats/history. Zero uses
+	// DefaultStatsHistoryInterval.
+	StatsHistoryInterval time.Duration
+	// URLSigner signs attachment download URLs. When nil, a signer with a
+	// random key is used, so URLs do not survive a restart.
+	URLSigner *attachment.Signer
+	// DownloadBaseURL is prepended to signed download URLs, e.g. the
+	// address of a CDN in front of /files/. Empty gives relative URLs.
+	DownloadBaseURL string
+	// Unfurler fetches previews of the links in task descriptions. When
+	// nil, links are not unfurled.
+	Unfurler *unfurl.Unfurler
+	// Webhooks delivers events to registered webhooks. When nil, a
+	// dispatcher with the default options is used.
+	Webhooks *webhook.Dispatcher
+	// Events publishes changes to GET /api/events streams. When nil, a hub
+	// with the default options is used.
+	Events *events.Hub
+	// Bus carries every change made through the handler, to the handler's
+	// side effects and to anything else subscribed to it. When nil, a new
+	// bus is used.
+	Bus *events.Bus
+	// EventHeartbeat is how often idle event streams send a heartbeat.
+	// Zero uses DefaultEventHeartbeat.
+	EventHeartbeat time.Duration
+	// ConsistencyInterval is how often ScheduleConsistencyChecks compares
+	// caches, stats and saved data with the store. Zero uses
+	// consistency.DefaultInterval.
+	ConsistencyInterval time.Duration
+	// ResetKey enables POST /api/admin/reset for requests carrying it in
+	// X-API-Key. Empty, the default, disables the endpoint. It replaces
+	// all data, so never set it in production.
+	ResetKey string
+	// Settings returns the configuration the server runs with, secrets
+	// redacted, for GET /api/admin/config. When nil, the endpoint is not
+	// routed.
+	Settings func() (interface{}, error)
+	// Reload loads the configuration again and applies what can change
+	// while the server runs, for POST /api/admin/reload. It returns the
+	// changed settings that take a restart. When nil, the endpoint is not
+	// routed.
+	Reload func() (restartNeeded []string, err error)
+	// StoreMetrics, when set, serves the store operations it counts at
+	// GET /metrics and GET /api/stats/store. Wrap the store passed to New
+	// with store.Instrument to count them.
+	StoreMetrics *store.Metrics
+}
+
+// Defaults for ServerConfig.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 64 << 10
+	DefaultRequestTimeout    = 30 * time.Second
+	DefaultMaxBodyBytes      = 1 << 20
+	DefaultMaxUploadBytes    = 32 << 20
+	DefaultCompressMinBytes  = 1 << 10
+)
+
+// ServerConfig hardens the HTTP server against slow or oversized requests,
+// and sets who may call it and which requests are logged. Zero fields use
+// the defaults. WriteTimeout should exceed RequestTimeout,
+// or a request that times out cannot be told so.
+type ServerConfig struct {
+	// ReadHeaderTimeout bounds reading the request headers.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading the whole request, including the body.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing the response, from the end of the headers.
+	// It is lifted for streamed responses such as exports.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection may wait for the next request.
+	IdleTimeout time.Duration
+	// MaxHeaderBytes caps the size of the request headers.
+	MaxHeaderBytes int
+	// RequestTimeout bounds each handler; requests still running after it
+	// get 503 with code REQUEST_TIMEOUT. Streamed responses are exempt.
+	RequestTimeout time.Duration
+	// MaxBodyBytes caps request bodies; larger ones get 413 with code
+	// REQUEST_TOO_LARGE.
+	MaxBodyBytes int64
+	// MaxUploadBytes replaces MaxBodyBytes for CSV imports and restores.
+	// It also caps a gzipped backup once decompressed.
+	MaxUploadBytes int64
+	// CompressMinBytes is the size from which responses are gzipped for
+	// clients that accept it; see middleware.Compress.
+	CompressMinBytes int
+	// APIKeys, when set, turns authentication on: requests must carry one
+	// of them in X-API-Key, or get 401 with code UNAUTHORIZED. Health
+	// checks, CORS preflights and signed downloads are exempt.
+	APIKeys []string
+	// AdminKeys may call the /api/admin routes, in X-API-Key, as well as
+	// the rest of the API. Without any, those routes are not routed;
+	// requests with another key get 403 with code FORBIDDEN. Reset takes
+	// ResetKey instead.
+	AdminKeys []string
+	// CORSOrigins are the origins browser scripts may call the API from,
+	// such as https://app.example.com. Empty, or "*", allows any origin.
+	// SetCORSOrigins changes them while the server runs.
+	CORSOrigins []string
+	// LogLevel sets which requests are logged; see middleware.LogRequests.
+	// The zero value, slog.LevelInfo, logs every request. SetLogLevel
+	// changes it while the server runs.
+	LogLevel slog.Level
+	// DrainDelay is how long Shutdown keeps serving requests, with
+	// /health/ready failing, before it stops accepting them, so load
+	// balancers stop sending requests first. Zero stops at once.
+	DrainDelay time.Duration
+}
+
+// withDefaults returns c with zero fields set to their defaults.
+func (c ServerConfig) withDefaults() ServerConfig {
+	if c.ReadHeaderTimeout <= 0 {
+		c.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = DefaultReadTimeout
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = DefaultWriteTimeout
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = DefaultIdleTimeout
+	}
+	if c.MaxHeaderBytes <= 0 {
+		c.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = DefaultRequestTimeout
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if c.MaxUploadBytes <= 0 {
+		c.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	if c.CompressMinBytes <= 0 {
+		c.CompressMinBytes = DefaultCompressMinBytes
+	}
+	return c
+}
+
+// bodyLimit returns the largest request body accepted for r.
+func (c ServerConfig) bodyLimit(r *http.Request) int64 {
+	if isUpload(r) {
+		return c.MaxUploadBytes
+	}
+	return c.MaxBodyBytes
+}
+
+// isUpload reports whether r uploads a file: a CSV import, a backup to
+// restore or a task attachment.
+func isUpload(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/import") || r.URL.Path == "/api/admin/restore" ||
+		strings.HasSuffix(r.URL.Path, "/attachments")
 }
 
 // Handler contains the HTTP handlers and their dependencies.
 type Handler struct {
-	store  *store.Store
-	cache  *cache.Cache
-	config Config
+	store       store.Backend
+	cache       cache.Cache
+	usage       *middleware.UsageTracker
+	ops         *operation.Manager
+	digest      *digest.Job
+	reports     *report.Materializer
+	stats       *report.Materializer // nil unless Config.StatsRefresh is set
+	attachments *attachment.Manager
+	signer      *attachment.Signer
+	unfurler    *unfurl.Unfurler
+	webhooks    *webhook.Dispatcher
+	events      *events.Hub
+	bus         *events.Bus
+	consistency *consistency.Checker
+	config      Config
+	// logLevel and corsOrigins start as configured in Config.Server and
+	// can be changed while the server runs
+	logLevel    *slog.LevelVar
+	corsOrigins *middleware.CORSOrigins
+	// cacheMu serializes invalidation and warming so a warm computed from
+	// older data cannot overwrite a newer invalidation.
+	cacheMu sync.Mutex
+
+	serverMu sync.Mutex
+	server   *http.Server
+	// redirect serves plain HTTP redirects to HTTPS, if configured
+	redirect *http.Server
+	// shuttingDown fails readiness checks once Shutdown is called
+	shuttingDown atomic.Bool
 }
 
 // New creates a new Handler with the given dependencies.
-func New(s *store.Store, c *cache.Cache, cfg Config) *Handler {
-	return &Handler{
-		store:  s,
-		cache:  c,
-		config: cfg,
+func New(s store.Backend, c cache.Cache, cfg Config) *Handler {
+	digestJob := cfg.Digest
+	if digestJob == nil {
+		digestJob = digest.New(s, mail.LogMailer{}, 0)
+	}
+	attachments := cfg.Attachments
+	if attachments == nil {
+		// Loading an empty in-memory storage cannot fail
+		attachments, _ = attachment.NewManager(context.Background(), attachment.NewMemory(), nil)
+	}
+	signer := cfg.URLSigner
+	if signer == nil {
+		signer = attachment.NewSigner(nil)
+	}
+	webhooks := cfg.Webhooks
+	if webhooks == nil {
+		webhooks = webhook.New(s, webhook.Options{})
 	}
+	hub := cfg.Events
+	if hub == nil {
+		hub = events.New(events.Options{})
+	}
+	bus := cfg.Bus
+	if bus == nil {
+		bus = events.NewBus()
+	}
+	h := &Handler{
+		store:       s,
+		cache:       c,
+		usage:       middleware.NewUsageTracker(),
+		ops:         operation.NewManagerWithLimits(cfg.OperationRetention, cfg.OperationLimits),
+		digest:      digestJob,
+		reports:     report.NewMaterializer(cfg.ReportRefresh),
+		attachments: attachments,
+		signer:      signer,
+		unfurler:    cfg.Unfurler,
+		webhooks:    webhooks,
+		events:      hub,
+		bus:         bus,
+		consistency: consistency.New(consistency.Options{Interval: cfg.ConsistencyInterval}),
+		config:      cfg,
+		logLevel:    new(slog.LevelVar),
+		corsOrigins: middleware.NewCORSOrigins(cfg.Server.CORSOrigins),
+	}
+	h.logLevel.Set(cfg.Server.LogLevel)
+	if cfg.StatsRefresh > 0 {
+		h.stats = report.NewMaterializer(cfg.StatsRefresh)
+	}
+	h.subscribeSideEffects()
+	h.registerReports()
+	h.registerConsistencyChecks()
+	attachments.OnReady(h.startThumbnails)
+	return h
 }
 
-// RegisterRoutes sets up all routes on the given mux.
+// RegisterRoutes sets up all routes on the given mux. Path parameters
+// such as {id} are read with r.PathValue; integer IDs are parsed by withID.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/health", h.handleHealth)
-	mux.HandleFunc("/health/live", h.handleLiveness)
-	mux.HandleFunc("/health/ready", h.handleReadiness)
-	mux.HandleFunc("/api/users", h.handleUsers)
-	mux.HandleFunc("/api/users/", h.handleUserByID)
-	mux.HandleFunc("/api/tasks", h.handleTasks)
-	mux.HandleFunc("/api/tasks/", h.handleTaskByID)
-	mux.HandleFunc("/api/stats", h.handleStats)
-	mux.HandleFunc("/api/cache/stats", h.handleCacheStats)
+	rt := newRouter(h, mux)
+	rt.handle("GET /health", h.handleHealth)
+	rt.handle("GET /health/live", h.handleLiveness)
+	rt.handle("GET /health/ready", h.handleReadiness)
+
+	rt.handle("GET /api/users", h.listUsers)
+	rt.handle("POST /api/users", h.createUser)
+	rt.handle("GET /api/users/{id}", h.withID("user", h.getUser))
+	rt.handle("GET /api/users/{id}/tasks", h.withID("user", h.handleUserTasks))
+	rt.handle("POST /api/users/{id}/password", h.withID("user", h.handleUserPassword))
+	rt.handle("GET /api/users/{id}/preferences", h.withID("user", h.getPreferences))
+	rt.handle("PUT /api/users/{id}/preferences", h.withID("user", h.updatePreferences))
+	rt.handle("GET /api/users/export", h.handleUsersExport)
+	rt.handle("POST /api/users/import", h.handleUsersImport)
+	rt.handle("POST /api/users/validate", h.handleUsersValidate)
+
+	rt.handle("GET /api/tasks", h.handleTasks)
+	rt.handle("POST /api/tasks", h.createTask)
+	rt.handle("GET /api/tasks/{id}", h.withID("task", h.getTaskByID))
+	rt.handle("PUT /api/tasks/{id}", h.withID("task", h.updateTask))
+	rt.handle("GET /api/tasks/{id}/attachments", h.withID("task", h.listAttachments))
+	rt.handle("POST /api/tasks/{id}/attachments", h.withID("task", h.uploadAttachment))
+	rt.handle("GET /api/tasks/{id}/share", h.withID("task", h.listShares))
+	rt.handle("POST /api/tasks/{id}/share", h.withID("task", h.createShare))
+	rt.handle("DELETE /api/tasks/{id}/share/{shareId}", h.withID("task", h.revokeShare))
+	rt.handle("GET /api/tasks/{id}/history", h.withID("task", h.handleTaskHistory))
+	rt.handle("GET /api/tasks/{id}/dependencies", h.withID("task", h.getDependencies))
+	rt.handle("POST /api/tasks/{id}/dependencies", h.withID("task", h.addDependency))
+	rt.handle("DELETE /api/tasks/{id}/dependencies/{blockerId}", h.withID("task", h.handleRemoveDependency))
+	rt.handle("GET /api/tasks/export", h.handleTasksExport)
+	rt.handle("POST /api/tasks/import", h.handleTasksImport)
+	rt.handle("POST /api/tasks/validate", h.handleTasksValidate)
+
+	// {id} is also the content hash of an attachment for HEAD
+	rt.handle("GET /api/attachments/{id}", h.withID("attachment", h.getAttachment))
+	rt.handle("HEAD /api/attachments/{id}", h.handleAttachmentHead)
+	rt.handle("DELETE /api/attachments/{id}", h.withID("attachment", h.deleteAttachment))
+	rt.handle("GET /api/attachments/{id}/download", h.withID("attachment", func(w http.ResponseWriter, r *http.Request, id int) {
+		h.downloadAttachment(w, r, id, "")
+	}))
+	rt.handle("GET /api/attachments/{id}/url", h.withID("attachment", h.signAttachmentURL))
+	rt.handle("POST /api/attachments/{id}/scan", h.withID("attachment", h.rescanAttachment))
+	rt.handle("GET /files/attachments/{id}", h.handleSignedDownload)
+	rt.handle("GET /share/{token}", h.handleSharedTask)
+
+	rt.handle("GET /api/projects", h.listProjects)
+	rt.handle("POST /api/projects", h.createProject)
+	rt.handle("GET /api/projects/{id}", h.withID("project", h.getProject))
+	rt.handle("PUT /api/projects/{id}", h.withID("project", h.updateProject))
+	rt.handle("DELETE /api/projects/{id}", h.withID("project", h.deleteProject))
+	rt.handle("GET /api/projects/{id}/tasks", h.withID("project", h.listProjectTasks))
+	rt.handle("GET /api/projects/{id}/stats", h.withID("project", h.handleProjectStats))
+
+	// Announcements reach every client, so only admins may change them
+	rt.handle("GET /api/announcements", h.listAnnouncements)
+	rt.handle("POST /api/announcements", h.adminOnly(h.createAnnouncement))
+	rt.handle("GET /api/announcements/{id}", h.withID("announcement", h.getAnnouncement))
+	rt.handle("PUT /api/announcements/{id}", h.adminOnly(h.withID("announcement", h.updateAnnouncement)))
+	rt.handle("DELETE /api/announcements/{id}", h.adminOnly(h.withID("announcement", h.deleteAnnouncement)))
+	rt.handle("GET /api/announcements/active", h.handleActiveAnnouncements)
+	rt.handle("GET /api/me", h.handleMe)
+
+	rt.handle("GET /api/audit", h.handleAudit)
+	rt.handle("GET /api/stats", h.handleStats)
+	rt.handle("GET /api/stats/users", h.handleUserStats)
+	rt.handle("GET /api/stats/history", h.handleStatsHistory)
+	if h.config.StoreMetrics != nil {
+		rt.handle("GET /api/stats/store", h.handleStoreStats)
+		rt.handle("GET /metrics", h.handleMetrics)
+	}
+	rt.handle("GET /api/events", h.handleEvents)
+	rt.handle("GET /ws", h.handleSocket)
+	rt.handle("GET /api/tags", h.handleTags)
+	rt.handle("POST /api/markdown", h.handleMarkdown)
+	rt.handle("GET /api/schema", h.handleSchema)
+	rt.handle("GET /api/statuses", h.handleStatuses)
+	rt.handle("GET /api/locale", h.handleLocale)
+	rt.handle("GET /api/roles", h.handleRoles)
+	rt.handle("GET /api/changelog", h.handleChangelog)
+	rt.handle("GET /api/reports", h.handleReportFields)
+	rt.handle("POST /api/reports", h.runReport)
+	rt.handle("GET /api/reports/capacity", h.handleCapacityReport)
+	rt.handle("GET /api/cache/stats", h.handleCacheStats)
+
+	if h.config.ResetKey != "" {
+		rt.handle("POST /api/admin/reset", h.handleReset)
+	}
+	if len(h.config.Server.AdminKeys) > 0 {
+		h.registerAdminRoutes(rt)
+	}
+	rt.handle("GET /api/operations", h.handleOperations)
+	rt.handle("GET /api/operations/{id}", h.getOperation)
+	rt.handle("DELETE /api/operations/{id}", h.cancelOperation)
+}
+
+// registerAdminRoutes adds the /api/admin routes, but for reset, which
+// takes its own key. Only requests carrying one of the admin keys reach
+// them; see ServerConfig.AdminKeys.
+func (h *Handler) registerAdminRoutes(rt *router) {
+	admin := func(pattern string, handler http.HandlerFunc) {
+		rt.handle(pattern, h.adminOnly(handler))
+	}
+	admin("GET /api/admin/route-usage", h.handleRouteUsage)
+	admin("GET /api/admin/backup", h.handleBackup)
+	admin("POST /api/admin/restore", h.handleRestore)
+	admin("POST /api/admin/cache/flush", h.handleCacheFlush)
+	admin("POST /api/admin/persist", h.handlePersist)
+	if h.config.Settings != nil {
+		admin("GET /api/admin/config", h.handleConfig)
+	}
+	if h.config.Reload != nil {
+		admin("POST /api/admin/reload", h.handleReload)
+	}
+	admin("POST /api/admin/digest", h.handleDigest)
+	admin("POST /api/admin/reports/refresh", h.handleReportsRefresh)
+	admin("GET /api/admin/consistency", h.getConsistency)
+	admin("POST /api/admin/consistency", h.runConsistency)
+	admin("GET /api/admin/diagnose", h.handleDiagnose)
+	admin("GET /api/admin/webhooks", h.listWebhooks)
+	admin("POST /api/admin/webhooks", h.createWebhook)
+	admin("GET /api/admin/webhooks/{id}", h.withID("webhook", h.getWebhook))
+	admin("DELETE /api/admin/webhooks/{id}", h.withID("webhook", h.deleteWebhook))
+	admin("GET /api/admin/webhooks/{id}/deliveries", h.withID("webhook", h.listWebhookDeliveries))
 }
 
 // Start starts the HTTP server on the given port.
+// It blocks until the server fails or Shutdown is called.
 func (h *Handler) Start(port string) {
 	mux := http.NewServeMux()
 	h.RegisterRoutes(mux)
 
-	// Apply middleware chain
-	// Only logging is enabled by default
+	serverConfig := h.config.Server.withDefaults()
+	handler := h.chain(serverConfig).Then(mux)
+
+	if h.config.WarmCache {
+		go func() {
+			start := time.Now()
+			h.WarmCache()
+			log.Printf("Cache warmed in %v", time.Since(start))
+		}()
+	}
 
-	// Optional: Enable authentication (bonus feature)
-	// Example usage:
-	// api keys would be taken from the database
-	// validKeys := []string{"secret-key-1", "secret-key-2"}
-	// handler := middleware.Auth(validKeys)(middleware.Logging(mux))
+	scheme := "http"
+	if h.config.TLS.enabled() {
+		scheme = "https"
+	}
+	if h.config.Sandbox {
+		log.Printf("Go sandbox server starting on %s://localhost:%s", scheme, port)
+		log.Printf("Serving isolated in-memory sample data")
+	} else {
+		log.Printf("Go backend server starting on %s://localhost:%s", scheme, port)
+		log.Printf("Serving data directly from Go backend")
+	}
 
-	// Optional: Enable rate limiting (bonus feature)
-	// Example usage:
-	// limiter := middleware.NewRateLimiter(100, 1*time.Minute) // 100 req/min
-	// handler := middleware.RateLimit(limiter)(middleware.Logging(mux))
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+		ReadTimeout:       serverConfig.ReadTimeout,
+		WriteTimeout:      serverConfig.WriteTimeout,
+		IdleTimeout:       serverConfig.IdleTimeout,
+		MaxHeaderBytes:    serverConfig.MaxHeaderBytes,
+	}
+	// Event streams never finish by themselves, so end them for Shutdown
+	server.RegisterOnShutdown(h.events.Close)
 
-	// Optional: Enable both auth and rate limiting
-	// Example usage:
-	// validKeys := []string{"secret-key-1"}
-	// limiter := middleware.NewRateLimiter(100, 1*time.Minute)
-	// handler := middleware.Auth(validKeys)(
-	//     middleware.RateLimit(limiter)(
-	//         middleware.Logging(mux)))
+	tlsConfig := h.config.TLS
+	manager := tlsConfig.manager()
+	if manager != nil {
+		server.TLSConfig = manager.TLSConfig()
+		log.Printf("Obtaining certificates for %s", strings.Join(tlsConfig.Domains, ", "))
+	}
+	var redirect *http.Server
+	if tlsConfig.enabled() && tlsConfig.RedirectPort != "" {
+		var redirectHandler http.Handler = redirectToHTTPS(port)
+		if manager != nil {
+			redirectHandler = manager.HTTPHandler(redirectHandler)
+		}
+		redirect = &http.Server{
+			Addr:              ":" + tlsConfig.RedirectPort,
+			Handler:           redirectHandler,
+			ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+			ReadTimeout:       serverConfig.ReadTimeout,
+			WriteTimeout:      serverConfig.WriteTimeout,
+			IdleTimeout:       serverConfig.IdleTimeout,
+			MaxHeaderBytes:    serverConfig.MaxHeaderBytes,
+		}
+	}
 
-	// Current configuration: Only logging middleware
-	handler := middleware.Logging(mux)
+	h.serverMu.Lock()
+	h.server = server
+	h.redirect = redirect
+	h.serverMu.Unlock()
 
-	log.Printf("Go backend server starting on http://localhost:%s", port)
-	log.Printf("Serving data directly from Go backend")
+	if redirect != nil {
+		log.Printf("Redirecting http://localhost:%s to HTTPS", tlsConfig.RedirectPort)
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("HTTP redirect server failed to start: %v", err)
+			}
+		}()
+	}
 
-	if err := http.ListenAndServe(":"+port, handler); err != nil {
+	var err error
+	if tlsConfig.enabled() {
+		// Certificates come from TLSConfig when obtained by the manager
+		err = server.ListenAndServeTLS(tlsConfig.CertFile, tlsConfig.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
 
+// Shutdown gracefully stops the server started by Start, waiting for
+// in-flight requests to finish until ctx is done. Readiness checks fail
+// from the start, and requests are still accepted for
+// Config.Server.DrainDelay.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.shuttingDown.Store(true)
+	if delay := h.config.Server.DrainDelay; delay > 0 {
+		log.Printf("Draining for %v before shutting down", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	h.serverMu.Lock()
+	server, redirect := h.server, h.redirect
+	h.serverMu.Unlock()
+
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			log.Printf("Warning: HTTP redirect server shutdown failed: %v", err)
+		}
+	}
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// chain returns the middleware every request goes through, outermost
+// first: request IDs, logging, CORS, panic recovery, optional in-flight
+// limit, body size limit, compression, other encodings, JSON:API
+// documents, request timeout, optional rate limiting and authentication,
+// optional debug timing, optional ID obfuscation, usage tracking, API
+// versioning, field naming, option negotiation and deprecation headers.
+// Panics anywhere within are logged and answered with 500, and requests
+// are logged with that status. Compression comes first, so
+// it sees the bytes sent. JSON:API documents and other encodings are made
+// from the final JSON, so they carry encoded IDs and cover errors from
+// the middleware too, and other encodings of request bodies are decoded
+// to JSON before anything else reads them. Rate limiting comes before
+// authentication, so API keys cannot be guessed at full speed, and IDs
+// are decoded before usage is tracked, so /api/tasks/{id} is counted as
+// one route whatever the encoded ID.
+func (h *Handler) chain(cfg ServerConfig) middleware.Chain {
+	chain := middleware.Chain{
+		middleware.RequestID,
+		middleware.LogRequests(h.logLevel),
+		middleware.CORS(h.corsOrigins),
+		middleware.Recover,
+	}
+	if h.config.MaxInFlight > 0 {
+		limiter := middleware.NewInFlightLimiter(h.config.MaxInFlight)
+		chain = chain.Append(middleware.LimitInFlight(limiter, requestPriority))
+	}
+	encodings := h.config.Encodings
+	if encodings == nil {
+		encodings = codec.Defaults()
+	}
+	chain = chain.Append(
+		middleware.LimitBody(cfg.bodyLimit),
+		middleware.Compress(cfg.CompressMinBytes),
+		middleware.Encodings(encodings...),
+		middleware.Hypermedia(h.hypermediaConfig()),
+		middleware.Timeout(cfg.RequestTimeout, streamsResponse),
+	)
+	if h.config.Sandbox {
+		chain = chain.Append(sandboxHeader)
+	}
+	if h.config.RateLimiter != nil {
+		chain = chain.Append(middleware.RateLimit(h.config.RateLimiter))
+	}
+	if len(cfg.APIKeys) > 0 {
+		keys := append(append([]string{}, cfg.APIKeys...), cfg.AdminKeys...)
+		chain = chain.Append(middleware.Unless(isPublic, middleware.Auth(keys)))
+	}
+	if h.config.DebugHeaders {
+		chain = chain.Append(middleware.Timing)
+	}
+	if h.config.IDCodec != nil {
+		chain = chain.Append(middleware.ObfuscateIDs(h.idConfig()))
+	}
+	return chain.Append(
+		middleware.Versioning(h.versionConfig()),
+		middleware.FieldNaming(h.namingConfig()),
+		negotiate,
+		middleware.Deprecations(deprecations),
+	)
+}
+
+// SetLogLevel changes which requests are logged while the server runs;
+// see middleware.LogRequests.
+func (h *Handler) SetLogLevel(level slog.Level) {
+	h.logLevel.Set(level)
+}
+
+// SetCORSOrigins changes the origins browser scripts may call the API
+// from while the server runs. Empty allows any origin.
+func (h *Handler) SetCORSOrigins(origins []string) {
+	h.corsOrigins.Set(origins)
+}
+
+// isPublic reports whether r may be made without an API key: health
+// checks, which load balancers make, CORS preflights, which browsers make
+// without credentials, signed downloads and shared task links, which
+// carry their own, data resets, which carry the reset key instead, and
+// the active announcements, which every client shows.
+func isPublic(r *http.Request) bool {
+	return r.Method == http.MethodOptions || r.URL.Path == "/health" ||
+		strings.HasPrefix(r.URL.Path, "/health/") || strings.HasPrefix(r.URL.Path, "/files/") ||
+		strings.HasPrefix(r.URL.Path, "/share/") || r.URL.Path == "/api/admin/reset" ||
+		(r.Method == http.MethodGet && r.URL.Path == "/api/announcements/active")
+}
+
+// adminOnly wraps next so only requests carrying one of the admin keys
+// reach it; see ServerConfig.AdminKeys.
+func (h *Handler) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.AdminAuth(h.config.Server.AdminKeys, h.config.Server.APIKeys)(next).ServeHTTP
+}
+
+// isAdmin reports whether r carries one of the admin keys in X-API-Key.
+func (h *Handler) isAdmin(r *http.Request) bool {
+	key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	return key != "" && slices.Contains(h.config.Server.AdminKeys, key)
+}
+
+// requestPriority classifies requests for the in-flight limiter.
+// Export routes end in "/export".
+func requestPriority(r *http.Request) middleware.Priority {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/health"), strings.HasPrefix(r.URL.Path, "/api/admin/"):
+		return middleware.PriorityCritical
+	case strings.HasSuffix(r.URL.Path, "/export"):
+		return middleware.PriorityExport
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+		return middleware.PriorityRead
+	default:
+		return middleware.PriorityWrite
+	}
+}
+
+// streamsResponse reports whether a request's response is streamed as it
+// is produced, so it must not be buffered or cut off by the request
+// timeout: exports, backups, attachment downloads, streamed stats, event
+// streams and WebSocket connections.
+func streamsResponse(r *http.Request) bool {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/export"), r.URL.Path == "/api/admin/backup",
+		strings.HasSuffix(r.URL.Path, "/download"), strings.HasPrefix(r.URL.Path, "/files/"),
+		r.URL.Path == "/api/events", r.URL.Path == "/ws":
+		return true
+	case r.URL.Path == "/api/stats":
+		return wantsStream(r)
+	}
+	return false
+}
+
+// sandboxHeader marks every response as coming from the sandbox.
+func sandboxHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Sandbox", "true")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authRateLimit wraps an authentication handler for account, a user ID
+// as parsed from the path, with the per-IP and per-account attempt limits,
+// if an AuthRateLimiter is configured.
+func (h *Handler) authRateLimit(account string, next http.HandlerFunc) http.Handler {
+	if h.config.AuthRateLimiter == nil {
+		return next
+	}
+	return middleware.AuthRateLimit(h.config.AuthRateLimiter, func(*http.Request) string {
+		return account
+	})(next)
+}
+
+// versionConfig returns the API versioning configuration.
+func (h *Handler) versionConfig() middleware.VersionConfig {
+	return middleware.VersionConfig{
+		Default:  defaultAPIVersion,
+		Adapters: apiVersions,
+		Pins:     h.config.VersionPins,
+	}
+}
+
+// namingConfig returns the field naming compatibility configuration.
+func (h *Handler) namingConfig() middleware.NamingConfig {
+	return middleware.NamingConfig{
+		Legacy: legacyFieldNames(),
+		Pins:   h.config.FieldNamingPins,
+	}
+}
+
 // writeJSON writes a JSON response with the given status code.
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+	encodeJSON(w, data)
+}
+
+// decodeJSON decodes a JSON request body into v. It writes an error and
+// returns false if the body is too large or not valid JSON.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if !writeBodyTooLarge(w, err) {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON format", "INVALID_JSON")
+		}
+		return false
+	}
+	return true
+}
+
+// writeBodyTooLarge writes a 413 response and returns true if err comes
+// from reading a request body past its size limit.
+func writeBodyTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	middleware.BodyTooLarge(w, tooLarge.Limit)
+	return true
 }
 
 // writeError writes a standardized error response.
@@ -104,21 +806,113 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, message, code st
 	h.writeJSON(w, status, response)
 }
 
-// handleCORS handles preflight OPTIONS requests.
-func (h *Handler) handleCORS(w http.ResponseWriter) {
+// writeFieldErrors writes a 400 listing every field error, with the first
+// one as the top-level error.
+func (h *Handler) writeFieldErrors(w http.ResponseWriter, ferrs []*fieldError) {
+	response := model.ErrorResponse{
+		Success: false,
+		Error:   ferrs[0].Message,
+		Code:    ferrs[0].Code,
+		Errors:  make([]model.FieldError, len(ferrs)),
+	}
+	for i, ferr := range ferrs {
+		response.Errors[i] = ferr.toModel()
+	}
+	h.writeJSON(w, http.StatusBadRequest, response)
+}
+
+// setCacheStatus sets the X-Cache header when debug headers are enabled.
+func (h *Handler) setCacheStatus(w http.ResponseWriter, hit bool) {
+	if !h.config.DebugHeaders {
+		return
+	}
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+}
+
+// snapshotRevisionHeader carries the store revision an export or backup
+// was taken at, so clients can list what changed after it with ?since=
+// and tell which point in time a backup restores.
+const snapshotRevisionHeader = "X-Snapshot-Revision"
+
+// setSnapshotRevision sets the snapshot revision header of a response
+// written from snap.
+func setSnapshotRevision(w http.ResponseWriter, snap store.Snapshot) {
+	w.Header().Set(snapshotRevisionHeader, strconv.FormatInt(snap.Revision(), 10))
+}
+
+// handleCORS handles preflight OPTIONS requests for a path with routes
+// for methods.
+func (h *Handler) handleCORS(w http.ResponseWriter, methods []string) {
+	allowed := strings.Join(append(methods[:len(methods):len(methods)], http.MethodOptions), ", ")
+	w.Header().Set("Allow", allowed)
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", allowed)
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
 	w.WriteHeader(http.StatusOK)
 }
 
 // InvalidateUserCaches clears user-related caches.
 func (h *Handler) InvalidateUserCaches() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
 	h.cache.Invalidate(cache.UsersKey())
 	h.cache.Invalidate(cache.StatsKey())
+
+	if h.config.WarmCache {
+		h.warmCache()
+	}
 }
 
 // InvalidateTaskCaches clears task-related caches.
+// Every filtered task list is dropped; the users list is left intact.
 func (h *Handler) InvalidateTaskCaches() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	h.cache.InvalidatePrefix(cache.TasksPrefix())
+	h.cache.Invalidate(cache.StatsKey())
+
+	if h.config.WarmCache {
+		h.warmCache()
+	}
+}
+
+// FlushCaches clears every cached response, re-warming the hot entries
+// if WarmCache is set.
+func (h *Handler) FlushCaches() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
 	h.cache.InvalidateAll()
+
+	if h.config.WarmCache {
+		h.warmCache()
+	}
+}
+
+// WarmCache pre-populates the cache with the users list, the unfiltered
+// tasks list and stats, so the first requests are served from cache.
+func (h *Handler) WarmCache() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	h.warmCache()
+}
+
+// warmCache populates the hot cache entries. Lists the store fails to
+// read are left for requests to fill. Must be called with cacheMu held.
+func (h *Handler) warmCache() {
+	ctx := context.Background()
+	if users, err := h.usersResponse(ctx); err == nil {
+		h.cache.Set(cache.UsersKey(), normalizeJSON(users))
+	}
+	if tasks, err := h.tasksResponse(ctx, taskFilter{}, nil); err == nil {
+		h.cache.Set(cache.TasksKey("", "", "", "", "", "", ""), normalizeJSON(tasks))
+	}
+	h.cache.SetWithTTL(cache.StatsKey(), normalizeJSON(h.store.GetStats(ctx)), statsCacheTTL)
 }
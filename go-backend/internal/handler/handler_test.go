@@ -1,41 +1,119 @@
 package handler
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"go-backend/internal/cache"
+	"go-backend/internal/consistency"
+	"go-backend/internal/events"
+	"go-backend/internal/fetch"
+	"go-backend/internal/fixtures"
+	"go-backend/internal/hashid"
+	"go-backend/internal/middleware"
 	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/report"
 	"go-backend/internal/store"
+	"go-backend/internal/unfurl"
+	"go-backend/internal/webhook"
+	"go-backend/internal/websocket"
+	"go-backend/internal/websocket/websockettest"
 )
 
-func newTestHandler() *Handler {
+// testIDStart is the first ID assigned to entities created in tests.
+const testIDStart = 100
+
+// newTestHandler returns a Handler with its own store, cache and data
+// directory, isolated from every other test so tests can run in parallel.
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
 	s := store.NewWithData(
 		[]model.User{
 			{ID: 1, Name: "John Doe", Email: "john@example.com", Role: "developer"},
 			{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Role: "designer"},
 		},
 		[]model.Task{
-			{ID: 1, Title: "Test task 1", Status: "pending", UserID: 1},
-			{ID: 2, Title: "Test task 2", Status: "in-progress", UserID: 2},
+			{ID: 1, Title: "Test task 1", Status: "pending", Priority: "medium", Tags: []string{"backend"}, UserID: 1},
+			{ID: 2, Title: "Test task 2", Status: "in-progress", Priority: "high", Tags: []string{"backend", "ui"}, UserID: 2},
 		},
 	)
+	s.SetIDGenerator(store.NewSequence(testIDStart))
+	s.SetDataPath(filepath.Join(t.TempDir(), "data.json"))
+
+	// Let background writes finish before the temp directory is removed
+	t.Cleanup(s.WaitForPersistence)
+
 	c := cache.New(5 * time.Minute)
-	cfg := Config{Version: "test", StartTime: time.Now()}
+	t.Cleanup(c.Close)
+
+	cfg := Config{Version: "test", StartTime: time.Now(), Server: ServerConfig{AdminKeys: []string{testAdminKey}}}
 	return New(s, c, cfg)
 }
 
+// testAdminKey is the admin key of test handlers.
+const testAdminKey = "admin-key"
+
+// adminRequest returns a request to an admin route carrying the admin key.
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("X-API-Key", testAdminKey)
+	return req
+}
+
+// routes returns h's routes, as the server serves them.
+func routes(h *Handler) http.Handler {
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	return mux
+}
+
+// serve routes r to the handler of its route.
+func serve(h *Handler, w http.ResponseWriter, r *http.Request) {
+	routes(h).ServeHTTP(w, r)
+}
+
+// must returns v, failing the test run if err is set. The test handlers
+// use the in-memory Store, which never fails.
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
 func TestHandler_HandleHealth(t *testing.T) {
-	h := newTestHandler()
+	t.Parallel()
+
+	h := newTestHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	rr := httptest.NewRecorder()
 
-	h.handleHealth(rr, req)
+	serve(h, rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", rr.Code)
@@ -49,15 +127,86 @@ func TestHandler_HandleHealth(t *testing.T) {
 	if response.Status != "ok" {
 		t.Errorf("expected status 'ok', got '%s'", response.Status)
 	}
+
+	// Health checks write nothing, but report the latest write
+	path := h.store.(*store.Store).DataPath()
+	if _, err := os.Stat(path); !os.IsNotExist(err) || response.LastPersistedAt != "" || response.Checks["persistence"] != "ok" {
+		t.Errorf("expected no data file written, got %v %+v", err, response)
+	}
+	if err := h.store.Persist(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	response = model.DetailedHealthResponse{}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if _, err := time.Parse(time.RFC3339, response.LastPersistedAt); err != nil {
+		t.Errorf("expected the time of the write, got %q", response.LastPersistedAt)
+	}
+}
+
+func TestHandler_Readiness(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	ready := func() (int, model.ReadinessResponse) {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		var response model.ReadinessResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return rr.Code, response
+	}
+
+	if code, response := ready(); code != http.StatusOK || response.Status != "ready" || response.Checks["persistence"] != "ok" {
+		t.Errorf("expected ready, got %d %+v", code, response)
+	}
+
+	// A data file that cannot be written fails the check until a write succeeds
+	s := h.store.(*store.Store)
+	path := s.DataPath()
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s.SetDataPath(filepath.Join(blocker, "data.json"))
+	if s.Persist(context.Background()) == nil {
+		t.Fatal("expected the write to fail")
+	}
+	code, response := ready()
+	if code != http.StatusServiceUnavailable || response.Status != "not ready" || !strings.HasPrefix(response.Checks["persistence"], "error:") {
+		t.Errorf("expected the persistence check to fail, got %d %+v", code, response)
+	}
+	s.SetDataPath(path)
+	if err := s.Persist(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := ready(); code != http.StatusOK {
+		t.Errorf("expected ready again, got %d", code)
+	}
+
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, response := ready(); code != http.StatusServiceUnavailable || response.Status != "shutting down" {
+		t.Errorf("expected shutting down, got %d %+v", code, response)
+	}
 }
 
 func TestHandler_HandleUsers_GET(t *testing.T) {
-	h := newTestHandler()
+	t.Parallel()
+
+	h := newTestHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
 	rr := httptest.NewRecorder()
 
-	h.handleUsers(rr, req)
+	serve(h, rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", rr.Code)
@@ -77,7 +226,9 @@ func TestHandler_HandleUsers_GET(t *testing.T) {
 }
 
 func TestHandler_HandleUsers_POST_Valid(t *testing.T) {
-	h := newTestHandler()
+	t.Parallel()
+
+	h := newTestHandler(t)
 
 	body := `{"name":"Test User","email":"test@example.com","role":"developer"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
@@ -101,13 +252,15 @@ func TestHandler_HandleUsers_POST_Valid(t *testing.T) {
 	if user.Email != "test@example.com" {
 		t.Errorf("expected email 'test@example.com', got '%s'", user.Email)
 	}
-	if user.ID == 0 {
-		t.Error("expected non-zero ID")
+	if user.ID != testIDStart {
+		t.Errorf("expected ID %d, got %d", testIDStart, user.ID)
 	}
 }
 
 func TestHandler_HandleUsers_POST_InvalidEmail(t *testing.T) {
-	h := newTestHandler()
+	t.Parallel()
+
+	h := newTestHandler(t)
 
 	body := `{"name":"Test User","email":"invalid-email","role":"developer"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
@@ -130,61 +283,97 @@ func TestHandler_HandleUsers_POST_InvalidEmail(t *testing.T) {
 	}
 }
 
-func TestHandler_HandleTasks_GET(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_HandleUsers_POST_MultipleErrors(t *testing.T) {
+	t.Parallel()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
-	rr := httptest.NewRecorder()
+	h := newTestHandler(t)
 
-	h.handleTasks(rr, req)
+	body := `{"name":"","email":"invalid-email","role":"developer"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", rr.Code)
+	rr := httptest.NewRecorder()
+	h.createUser(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
 	}
 
-	var response model.TasksResponse
+	var response model.ErrorResponse
 	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if response.Count != 2 {
-		t.Errorf("expected count 2, got %d", response.Count)
+	if response.Code != "INVALID_NAME" {
+		t.Errorf("expected code 'INVALID_NAME', got '%s'", response.Code)
+	}
+	if len(response.Errors) != 2 || response.Errors[0].Field != "name" || response.Errors[1].Code != "INVALID_EMAIL_FORMAT" {
+		t.Errorf("expected name and email errors, got %+v", response.Errors)
 	}
 }
 
-func TestHandler_HandleTasks_POST_Valid(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_HandleUsers_POST_InvalidRole(t *testing.T) {
+	t.Parallel()
 
-	body := `{"title":"New Task","status":"pending","userId":1}`
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	h := newTestHandler(t)
+
+	body := `{"name":"Test User","email":"test@example.com","role":"develoepr"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	h.createTask(rr, req)
+	h.createUser(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+
+	var response model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Code != "INVALID_ROLE" || !strings.Contains(response.Error, "developer, designer, manager, admin") {
+		t.Errorf("expected INVALID_ROLE listing the allowed roles, got %+v", response)
+	}
+}
+
+func TestHandler_HandleUsers_POST_WithPassword(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"name":"Test User","email":"test@example.com","role":"developer","password":"s3cret-pass"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	h.createUser(rr, req)
 
 	if rr.Code != http.StatusCreated {
-		t.Errorf("expected status 201, got %d", rr.Code)
+		t.Fatalf("expected status 201, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "s3cret-pass") || strings.Contains(strings.ToLower(rr.Body.String()), "password") {
+		t.Errorf("response must not expose password data: %s", rr.Body.String())
 	}
 
-	var task model.Task
-	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+	var user model.User
+	if err := json.NewDecoder(rr.Body).Decode(&user); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
-
-	if task.Title != "New Task" {
-		t.Errorf("expected title 'New Task', got '%s'", task.Title)
+	if stored := must(h.store.GetUserByID(context.Background(), user.ID)); stored == nil || stored.PasswordHash == "" {
+		t.Error("expected password hash to be stored")
 	}
 }
 
-func TestHandler_HandleTasks_POST_InvalidStatus(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_HandleUsers_POST_ShortPassword(t *testing.T) {
+	t.Parallel()
 
-	body := `{"title":"New Task","status":"invalid","userId":1}`
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	h := newTestHandler(t)
+
+	body := `{"name":"Test User","email":"test@example.com","role":"developer","password":"short"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	h.createTask(rr, req)
+	h.createUser(rr, req)
 
 	if rr.Code != http.StatusBadRequest {
 		t.Errorf("expected status 400, got %d", rr.Code)
@@ -195,118 +384,4138 @@ func TestHandler_HandleTasks_POST_InvalidStatus(t *testing.T) {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if response.Code != "INVALID_STATUS" {
-		t.Errorf("expected code 'INVALID_STATUS', got '%s'", response.Code)
+	if response.Code != "INVALID_PASSWORD" {
+		t.Errorf("expected code 'INVALID_PASSWORD', got '%s'", response.Code)
 	}
 }
 
-func TestHandler_HandleTasks_POST_InvalidUserID(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_ChangePassword(t *testing.T) {
+	t.Parallel()
 
-	body := `{"title":"New Task","status":"pending","userId":999}`
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	h := newTestHandler(t)
 
+	// An admin sets the first password without the current one
+	body := `{"newPassword":"first-password"}`
+	req := adminRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(body))
 	rr := httptest.NewRecorder()
-	h.createTask(rr, req)
+	serve(h, rr, req)
 
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status 400, got %d", rr.Code)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
 	}
 
-	var response model.ErrorResponse
-	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+	// Changing it afterwards requires the current password
+	body = `{"currentPassword":"wrong-password","newPassword":"second-password"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", rr.Code)
 	}
 
-	if response.Code != "INVALID_USER_ID" {
-		t.Errorf("expected code 'INVALID_USER_ID', got '%s'", response.Code)
+	body = `{"currentPassword":"first-password","newPassword":"second-password"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
 	}
 }
 
-func TestHandler_HandleTaskByID_GET(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_ChangePassword_FirstPasswordNeedsAdmin(t *testing.T) {
+	t.Parallel()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks/1", nil)
+	h := newTestHandler(t)
+	h.config.Server.APIKeys = []string{"key-1"}
+
+	for _, key := range []string{"", "key-1"} {
+		body := `{"newPassword":"taken-over"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+
+		if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "FORBIDDEN") {
+			t.Errorf("key %q: expected status 403, got %d: %s", key, rr.Code, rr.Body)
+		}
+	}
+	if user := must(h.store.GetUserByID(context.Background(), 1)); user.PasswordHash != "" {
+		t.Error("expected the user to still have no password")
+	}
+}
+
+func TestHandler_ChangePassword_UserNotFound(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"newPassword":"some-password"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/users/999/password", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestHandler_ChangePassword_AccountRateLimit(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	limiter := middleware.NewRateLimiter(2, time.Minute)
+	t.Cleanup(limiter.Close)
+	h.config.AuthRateLimiter = limiter
+
+	// Attempts against one account from different IPs share its limit
+	ips := []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"}
+	wantStatus := []int{http.StatusOK, http.StatusUnauthorized, http.StatusTooManyRequests}
+
+	for i, ip := range ips {
+		body := `{"currentPassword":"guess","newPassword":"first-password"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(body))
+		if i == 0 {
+			req.Header.Set("X-API-Key", testAdminKey)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+
+		if rr.Code != wantStatus[i] {
+			t.Errorf("attempt %d: expected status %d, got %d", i+1, wantStatus[i], rr.Code)
+		}
+	}
+
+	// Other accounts are unaffected
+	body := `{"newPassword":"first-password"}`
+	req := adminRequest(http.MethodPost, "/api/users/2/password", strings.NewReader(body))
+	req.Header.Set("X-Forwarded-For", "203.0.113.4")
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 for another account, got %d", rr.Code)
+	}
+}
+
+func TestHandler_ChangePassword_AccountRateLimitSpellings(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	limiter := middleware.NewRateLimiter(2, time.Minute)
+	t.Cleanup(limiter.Close)
+	h.config.AuthRateLimiter = limiter
+
+	// Spellings of one ID name one account, so share its limit
+	paths := []string{"/api/users/1/password", "/api/users/01/password", "/api/users/+1/password"}
+	wantStatus := []int{http.StatusOK, http.StatusUnauthorized, http.StatusTooManyRequests}
+
+	for i, path := range paths {
+		body := `{"currentPassword":"guess","newPassword":"first-password"}`
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if i == 0 {
+			req.Header.Set("X-API-Key", testAdminKey)
+		}
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+
+		if rr.Code != wantStatus[i] {
+			t.Errorf("%s: expected status %d, got %d", path, wantStatus[i], rr.Code)
+		}
+	}
+	if limiter.RetryAfter("account:1") == 0 {
+		t.Error(`expected the attempts limited under "account:1"`)
+	}
+}
+
+func TestHandler_HandleTasks_GET(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
 	rr := httptest.NewRecorder()
 
-	h.handleTaskByID(rr, req)
+	serve(h, rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", rr.Code)
 	}
 
-	var task model.Task
-	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+	var response model.TasksResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if task.ID != 1 {
-		t.Errorf("expected ID 1, got %d", task.ID)
+	if response.Count != 2 {
+		t.Errorf("expected count 2, got %d", response.Count)
 	}
 }
 
-func TestHandler_HandleTaskByID_GET_NotFound(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_CompactView(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	// The second request is served from the cache of the full view
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks?view=compact", nil))
+		var raw struct {
+			Tasks []map[string]interface{} `json:"tasks"`
+			Count int                      `json:"count"`
+		}
+		json.NewDecoder(rr.Body).Decode(&raw)
+		if rr.Code != http.StatusOK || raw.Count != 2 || len(raw.Tasks) != 2 {
+			t.Fatalf("expected 2 compact tasks, got %d %+v", rr.Code, raw)
+		}
+		if len(raw.Tasks[0]) != 3 || raw.Tasks[0]["title"] != "Test task 1" || raw.Tasks[0]["status"] != "pending" {
+			t.Errorf("expected only id, title and status, got %v", raw.Tasks[0])
+		}
+	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks/999", nil)
 	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+	if !strings.Contains(rr.Body.String(), `"priority"`) {
+		t.Errorf("expected the full view by default, got %s", rr.Body.String())
+	}
 
-	h.handleTaskByID(rr, req)
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks/1?view=compact", nil))
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"id":1,"title":"Test task 1","status":"pending"}` {
+		t.Errorf("expected the compact task, got %s", body)
+	}
 
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("expected status 404, got %d", rr.Code)
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/users?view=compact", nil))
+	var users model.CompactUsersResponse
+	json.NewDecoder(rr.Body).Decode(&users)
+	if users.Count != 2 || users.Users[0] != (model.UserSummary{ID: 1, Name: "John Doe"}) {
+		t.Errorf("expected compact users, got %+v", users)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks?view=tiny", nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_VIEW") {
+		t.Errorf("expected 400 INVALID_VIEW, got %d %s", rr.Code, rr.Body.String())
 	}
 }
 
-func TestHandler_HandleTaskByID_PUT(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_HandleTasks_GET_Priority(t *testing.T) {
+	t.Parallel()
 
-	body := `{"title":"Updated Task","status":"completed"}`
-	req := httptest.NewRequest(http.MethodPut, "/api/tasks/1", strings.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	h := newTestHandler(t)
+	h.store.CreateTask(context.Background(), model.Task{Title: "Urgent", Status: "pending", Priority: "urgent", UserID: 1})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"filter", "?priority=high", []int{2}},
+		{"sort", "?sort=priority", []int{testIDStart, 2, 1}},
+		{"filter and sort", "?status=pending&sort=priority", []int{testIDStart, 1}},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks"+tt.query, nil))
+		var response model.TasksResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		var got []int
+		for _, task := range response.Tasks {
+			got = append(got, task.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected tasks %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	for _, query := range []string{"?priority=critical", "?sort=title"} {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_HandleTasks_POST_Priority(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
 
 	rr := httptest.NewRecorder()
-	h.handleTaskByID(rr, req)
+	h.createTask(rr, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"title":"Default","status":"pending","userId":1}`)))
+	var task model.Task
+	json.NewDecoder(rr.Body).Decode(&task)
+	if rr.Code != http.StatusCreated || task.Priority != "medium" {
+		t.Errorf("expected a task with the default priority, got %d %+v", rr.Code, task)
+	}
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", rr.Code)
+	rr = httptest.NewRecorder()
+	h.createTask(rr, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"title":"Bad","status":"pending","priority":"asap","userId":1}`)))
+	var response model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if rr.Code != http.StatusBadRequest || response.Code != "INVALID_PRIORITY" {
+		t.Errorf("expected 400 INVALID_PRIORITY, got %d %+v", rr.Code, response)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/tasks/1", strings.NewReader(`{"priority":"urgent"}`))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	json.NewDecoder(rr.Body).Decode(&task)
+	if rr.Code != http.StatusOK || task.Priority != "urgent" || task.Title != "Test task 1" {
+		t.Errorf("expected only the priority to change, got %d %+v", rr.Code, task)
 	}
+}
+
+func TestHandler_HandleTasks_Tags(t *testing.T) {
+	t.Parallel()
 
+	h := newTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	h.createTask(rr, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"title":"Docs","status":"pending","userId":1,"tags":[" Needs Review","docs","DOCS"]}`)))
 	var task model.Task
-	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+	json.NewDecoder(rr.Body).Decode(&task)
+	if rr.Code != http.StatusCreated || strings.Join(task.Tags, ",") != "needs-review,docs" {
+		t.Errorf("expected normalized, deduplicated tags, got %d %+v", rr.Code, task)
 	}
 
-	if task.Title != "Updated Task" {
-		t.Errorf("expected title 'Updated Task', got '%s'", task.Title)
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"one tag", "?tag=ui", []int{2}},
+		{"all tags", "?tag=backend&tag=UI", []int{2}},
+		{"any tag", "?tag=ui,docs&tagMode=any", []int{2, task.ID}},
+		{"no match", "?tag=mobile", nil},
 	}
-	if task.Status != "completed" {
-		t.Errorf("expected status 'completed', got '%s'", task.Status)
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks"+tt.query, nil))
+		var response model.TasksResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		var got []int
+		for _, task := range response.Tasks {
+			got = append(got, task.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected tasks %v, got %v", tt.name, tt.want, got)
+		}
+	}
+	for _, query := range []string{"?tag=a%20b!", "?tag=ui&tagMode=some"} {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rr.Code)
+		}
+	}
+
+	tooMany := `{"tags":["a","b","c","d","e","f","g","h","i","j","k"]}`
+	for _, body := range []string{tooMany, `{"tags":["no/slashes"]}`} {
+		rr = httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodPut, "/api/tasks/1", strings.NewReader(body)))
+		var response model.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		if rr.Code != http.StatusBadRequest || response.Code != "INVALID_TAGS" {
+			t.Errorf("%s: expected 400 INVALID_TAGS, got %d %+v", body, rr.Code, response)
+		}
+	}
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPut, "/api/tasks/1", strings.NewReader(`{"tags":[]}`)))
+	task = model.Task{}
+	json.NewDecoder(rr.Body).Decode(&task)
+	if rr.Code != http.StatusOK || len(task.Tags) != 0 {
+		t.Errorf("expected empty tags to clear them, got %d %+v", rr.Code, task)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+	var tags model.TagsResponse
+	json.NewDecoder(rr.Body).Decode(&tags)
+	if fmt.Sprint(tags.Tags) != "[{backend 1} {docs 1} {needs-review 1} {ui 1}]" || tags.Count != 4 {
+		t.Errorf("expected tag usage counts, got %+v", tags)
 	}
 }
 
-func TestHandler_HandleStats(t *testing.T) {
-	h := newTestHandler()
+func TestHandler_HandleTasks_GET_CacheStatusHeader(t *testing.T) {
+	t.Parallel()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	h := newTestHandler(t)
+	h.config.DebugHeaders = true
+
+	for _, want := range []string{"MISS", "HIT"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+
+		if got := rr.Header().Get("X-Cache"); got != want {
+			t.Errorf("expected X-Cache %s, got %q", want, got)
+		}
+	}
+}
+
+func TestHandler_HandleTasks_GET_NoCacheHeaderByDefault(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
 	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if got := rr.Header().Get("X-Cache"); got != "" {
+		t.Errorf("expected no X-Cache header, got %q", got)
+	}
+}
 
-	h.handleStats(rr, req)
+func TestHandler_VersionPinning(t *testing.T) {
+	t.Parallel()
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", rr.Code)
+	h := newTestHandler(t)
+	h.config.VersionPins = map[string]string{"legacy-key": "1", "new-key": "2"}
+	versioned := middleware.Versioning(h.versionConfig())(routes(h))
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		header       string
+		wantStatus   int
+		wantVersion  string
+		wantEnvelope bool
+	}{
+		{"default version", "", "", http.StatusOK, "1", false},
+		{"pinned to v1", "legacy-key", "", http.StatusOK, "1", false},
+		{"pinned to v2", "new-key", "", http.StatusOK, "2", true},
+		{"header overrides pin", "new-key", "1", http.StatusOK, "1", false},
+		{"unsupported version", "", "99", http.StatusBadRequest, "", false},
 	}
 
-	var stats model.StatsResponse
-	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.header != "" {
+				req.Header.Set("X-API-Version", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			versioned.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("X-API-Version"); got != tt.wantVersion {
+				t.Errorf("expected X-API-Version %q, got %q", tt.wantVersion, got)
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if _, enveloped := body["data"]; enveloped != tt.wantEnvelope {
+				t.Errorf("expected envelope %v, got body %v", tt.wantEnvelope, body)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleTasks_POST_Valid(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"title":"New Task","status":"pending","userId":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	h.createTask(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rr.Code)
+	}
+
+	var task model.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if stats.Users.Total != 2 {
-		t.Errorf("expected 2 users, got %d", stats.Users.Total)
+	if task.Title != "New Task" {
+		t.Errorf("expected title 'New Task', got '%s'", task.Title)
 	}
-	if stats.Tasks.Total != 2 {
+}
+
+func TestHandler_CreateTask_KeepsUsersCache(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	serve(h, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	serve(h, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+
+	body := `{"title":"New Task","status":"pending","userId":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	h.createTask(httptest.NewRecorder(), req)
+
+	if _, found := h.cache.Get(cache.UsersKey()); !found {
+		t.Error("expected users cache to survive task creation")
+	}
+	if _, found := h.cache.Get(cache.TasksKey("", "", "", "", "", "", "")); found {
+		t.Error("expected tasks cache to be invalidated")
+	}
+}
+
+func TestHandler_HandleTasks_POST_InvalidStatus(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"title":"New Task","status":"invalid","userId":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	h.createTask(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+
+	var response model.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Code != "INVALID_STATUS" {
+		t.Errorf("expected code 'INVALID_STATUS', got '%s'", response.Code)
+	}
+}
+
+func TestHandler_HandleTasks_POST_InvalidUserID(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"title":"New Task","status":"pending","userId":999}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	h.createTask(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+
+	var response model.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Code != "INVALID_USER_ID" {
+		t.Errorf("expected code 'INVALID_USER_ID', got '%s'", response.Code)
+	}
+}
+
+func TestHandler_HandleTaskByID_GET(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/1", nil)
+	rr := httptest.NewRecorder()
+
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+
+	var task model.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if task.ID != 1 {
+		t.Errorf("expected ID 1, got %d", task.ID)
+	}
+}
+
+func TestHandler_HandleTaskByID_GET_RenderHTML(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	description := "Fix **login**\n\n<script>alert(1)</script>"
+	h.store.UpdateTask(context.Background(), 1, model.TaskUpdate{Description: &description})
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks/1?render=html", nil))
+	var task model.TaskHTMLResponse
+	json.NewDecoder(rr.Body).Decode(&task)
+	want := "<p>Fix <strong>login</strong></p>\n<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"
+	if rr.Code != http.StatusOK || task.DescriptionHTML != want || task.Description != description {
+		t.Errorf("expected the description and its sanitized HTML, got %d %+v", rr.Code, task)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks/1", nil))
+	if strings.Contains(rr.Body.String(), "descriptionHtml") {
+		t.Errorf("expected no HTML without render=html, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks/1?render=pdf", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown render format, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Markdown(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPost, "/api/markdown", strings.NewReader(`{"markdown":"- [docs](https://example.com)"}`)))
+	var response model.MarkdownResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	want := `<ul>` + "\n" + `<li><a href="https://example.com" rel="nofollow noopener noreferrer">docs</a></li>` + "\n</ul>\n"
+	if rr.Code != http.StatusOK || response.HTML != want {
+		t.Errorf("expected the rendered list, got %d %q", rr.Code, response.HTML)
+	}
+
+	long := strings.Repeat("a", 10001)
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPost, "/api/markdown", strings.NewReader(`{"markdown":"`+long+`"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for overlong Markdown, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.createTask(rr, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"title":"Long","status":"pending","userId":1,"description":"`+long+`"}`)))
+	var errResponse model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&errResponse)
+	if rr.Code != http.StatusBadRequest || errResponse.Code != "INVALID_DESCRIPTION" {
+		t.Errorf("expected 400 INVALID_DESCRIPTION, got %d %+v", rr.Code, errResponse)
+	}
+}
+
+func TestHandler_Tasks_UnfurlLinks(t *testing.T) {
+	t.Parallel()
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<title>Design doc</title>")
+	}))
+	defer page.Close()
+
+	h := newTestHandler(t)
+	// The test server is on loopback, which the default policy refuses
+	pageURL, _ := url.Parse(page.URL)
+	port, _ := strconv.Atoi(pageURL.Port())
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	h.unfurler = unfurl.New(fetch.New(fetch.Policy{Ports: []int{port}, AllowNets: []*net.IPNet{loopback}}))
+
+	body := fmt.Sprintf(`{"title":"Review","status":"pending","userId":1,"description":"See %s/doc."}`, page.URL)
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var created model.Task
+	json.NewDecoder(rr.Body).Decode(&created)
+
+	waitForUnfurls := func(want int) []model.Unfurl {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if task := must(h.store.GetTaskByID(context.Background(), created.ID)); len(task.Unfurls) == want {
+				return task.Unfurls
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("expected %d unfurls", want)
+		return nil
+	}
+
+	unfurls := waitForUnfurls(1)
+	if unfurls[0].URL != page.URL+"/doc" || unfurls[0].Title != "Design doc" || unfurls[0].FaviconURL != page.URL+"/favicon.ico" {
+		t.Errorf("unexpected unfurl %+v", unfurls[0])
+	}
+	// Saving the previews is a change like any other
+	if trail := h.store.GetAuditEvents(context.Background(), model.AuditFilter{Actor: unfurlActor}); len(trail) != 1 || trail[0].EntityID != strconv.Itoa(created.ID) {
+		t.Errorf("expected the previews audited, got %+v", trail)
+	}
+
+	// Removing the link removes its preview
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/tasks/%d", created.ID), strings.NewReader(`{"description":"No links"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	waitForUnfurls(0)
+}
+
+func TestHandler_HandleTaskByID_GET_NotFound(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/999", nil)
+	rr := httptest.NewRecorder()
+
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Routing(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	request := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+	code := func(rr *httptest.ResponseRecorder) string {
+		var body map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&body)
+		code, _ := body["code"].(string)
+		return code
+	}
+
+	// Paths below a record are not read as the record
+	for _, target := range []string{"/api/tasks/1/anything", "/api/users/1/tasks/2", "/nope"} {
+		if rr := request(http.MethodGet, target); rr.Code != http.StatusNotFound || code(rr) != "NOT_FOUND" {
+			t.Errorf("GET %s: expected 404 NOT_FOUND, got %d", target, rr.Code)
+		}
+	}
+
+	rr := request(http.MethodPatch, "/api/tasks/1")
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET, PUT, OPTIONS" || code(rr) != "METHOD_NOT_ALLOWED" {
+		t.Errorf("expected 405 allowing GET and PUT, got %d %q", rr.Code, rr.Header().Get("Allow"))
+	}
+	rr = request(http.MethodOptions, "/api/tasks/1/share")
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST, OPTIONS" {
+		t.Errorf("expected the preflight to list GET and POST, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Methods"))
+	}
+	if rr = request(http.MethodGet, "/api/tasks/abc"); rr.Code != http.StatusBadRequest || code(rr) != "INVALID_ID" {
+		t.Errorf("expected 400 INVALID_ID, got %d", rr.Code)
+	}
+
+	// Literal routes win over {id}
+	if rr = request(http.MethodGet, "/api/tasks/export"); rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != csvContentType {
+		t.Errorf("expected the export, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr = request(http.MethodHead, "/api/tasks/1"); rr.Code != http.StatusOK {
+		t.Errorf("expected HEAD served by GET, got %d", rr.Code)
+	}
+}
+
+func TestHandler_HandleTaskByID_PUT(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"title":"Updated Task","status":"completed"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/tasks/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+
+	var task model.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if task.Title != "Updated Task" {
+		t.Errorf("expected title 'Updated Task', got '%s'", task.Title)
+	}
+	if task.Status != "completed" {
+		t.Errorf("expected status 'completed', got '%s'", task.Status)
+	}
+}
+
+func TestHandler_HandleStats(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	rr := httptest.NewRecorder()
+
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+
+	var stats model.StatsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if stats.Users.Total != 2 {
+		t.Errorf("expected 2 users, got %d", stats.Users.Total)
+	}
+	if stats.Tasks.Total != 2 {
 		t.Errorf("expected 2 tasks, got %d", stats.Tasks.Total)
 	}
+	if want := (model.PriorityCounts{Medium: 1, High: 1}); stats.Priorities != want {
+		t.Errorf("expected %+v by priority, got %+v", want, stats.Priorities)
+	}
+}
+
+func TestHandler_HandleStats_Materialized(t *testing.T) {
+	t.Parallel()
+
+	base := newTestHandler(t)
+	h := New(base.store, base.cache, Config{Version: "test", StartTime: time.Now(), StatsRefresh: time.Hour})
+
+	get := func() (model.StatsResponse, string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		var stats model.StatsResponse
+		json.NewDecoder(rr.Body).Decode(&stats)
+		return stats, rr.Header().Get("Last-Modified")
+	}
+
+	// Scheduling refreshes at once, then waits for the interval
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.ScheduleStats(ctx)
+
+	before, generated := get()
+	if before.Tasks.Total != 2 || generated == "" {
+		t.Fatalf("expected the materialized stats with Last-Modified, got %+v at %q", before, generated)
+	}
+
+	h.store.CreateTask(context.Background(), model.Task{Title: "New", Status: "pending", UserID: 1})
+	if stale, modified := get(); stale.Tasks.Total != 2 || modified != generated {
+		t.Errorf("expected the stored stats until a refresh, got %+v at %q", stale, modified)
+	}
+
+	if _, err := h.stats.Refresh(context.Background(), statsReport); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if after, _ := get(); after.Tasks.Total != 3 {
+		t.Errorf("expected the refreshed stats to count the new task, got %+v", after)
+	}
+}
+
+func TestHandler_HandleRouteUsage(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	// Paths no route matches, such as below a task, count for nothing
+	for _, path := range []string{"/api/tasks/1", "/api/tasks/2", "/api/tasks/1/anything", "/api/nothing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "partner-secret")
+		serve(h, httptest.NewRecorder(), req)
+	}
+
+	req := adminRequest(http.MethodGet, "/api/admin/route-usage", nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var response model.RouteUsageResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// The report's own request is counted too, ahead of the tasks by route
+	if response.Count != 2 || response.Routes[0].Route != "/api/admin/route-usage" {
+		t.Fatalf("expected 2 route usage entries, got %+v", response.Routes)
+	}
+	usage := response.Routes[1]
+	if usage.Route != "/api/tasks/{id}" {
+		t.Errorf("expected route '/api/tasks/{id}', got '%s'", usage.Route)
+	}
+	if usage.Count != 2 {
+		t.Errorf("expected count 2, got %d", usage.Count)
+	}
+	if usage.Client != "key:part****" {
+		t.Errorf("expected masked client 'key:part****', got '%s'", usage.Client)
+	}
+}
+
+func TestHandler_WarmCache(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.WarmCache = true
+
+	h.WarmCache()
+
+	for _, key := range []string{cache.UsersKey(), cache.TasksKey("", "", "", "", "", "", ""), cache.StatsKey()} {
+		if _, found := h.cache.Get(key); !found {
+			t.Errorf("expected '%s' to be warmed", key)
+		}
+	}
+
+	// Writes invalidate and immediately re-warm with fresh data
+	body := `{"title":"New Task","status":"pending","userId":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	h.createTask(httptest.NewRecorder(), req)
+
+	cached, found := h.cache.Get(cache.TasksKey("", "", "", "", "", "", ""))
+	if !found {
+		t.Fatal("expected tasks list to be re-warmed after write")
+	}
+	if got := cached.(model.TasksResponse).Count; got != 3 {
+		t.Errorf("expected re-warmed count 3, got %d", got)
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method string
+		path   string
+		want   middleware.Priority
+	}{
+		{http.MethodGet, "/health/live", middleware.PriorityCritical},
+		{http.MethodGet, "/api/admin/route-usage", middleware.PriorityCritical},
+		{http.MethodGet, "/api/tasks", middleware.PriorityRead},
+		{http.MethodOptions, "/api/tasks", middleware.PriorityRead},
+		{http.MethodPost, "/api/tasks", middleware.PriorityWrite},
+		{http.MethodGet, "/api/tasks/export", middleware.PriorityExport},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := requestPriority(req); got != tt.want {
+			t.Errorf("%s %s: expected priority %d, got %d", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestHandler_BackupAndRestore(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"", "gzip"} {
+		format := format
+		t.Run("format="+format, func(t *testing.T) {
+			t.Parallel()
+
+			h := newTestHandler(t)
+			h.store.CreateTask(context.Background(), model.Task{Title: "Before backup", Status: "pending", UserID: 1})
+
+			req := adminRequest(http.MethodGet, "/api/admin/backup?format="+format, nil)
+			rr := httptest.NewRecorder()
+			serve(h, rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+			if !strings.HasPrefix(rr.Header().Get("Content-Disposition"), "attachment;") {
+				t.Errorf("expected attachment Content-Disposition, got %q", rr.Header().Get("Content-Disposition"))
+			}
+			backup := rr.Body.Bytes()
+			revision := rr.Header().Get("X-Snapshot-Revision")
+			if want := strconv.FormatInt(h.store.Revision(context.Background()), 10); revision != want {
+				t.Errorf("expected the backup taken at revision %s, got %q", want, revision)
+			}
+
+			// Change data, then restore the backup over it
+			h.store.CreateTask(context.Background(), model.Task{Title: "After backup", Status: "pending", UserID: 1})
+
+			req = adminRequest(http.MethodPost, "/api/admin/restore", bytes.NewReader(backup))
+			rr = httptest.NewRecorder()
+			serve(h, rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d (body: %s)", rr.Code, rr.Body.String())
+			}
+			if got := len(must(h.store.GetTasks(context.Background(), "", ""))); got != 3 {
+				t.Errorf("expected 3 tasks after restore, got %d", got)
+			}
+			if !strings.Contains(rr.Body.String(), "from revision "+revision) {
+				t.Errorf("expected the restore to name revision %s, got %s", revision, rr.Body)
+			}
+			trail := h.store.GetAuditEvents(context.Background(), model.AuditFilter{Entity: events.EntityBackup})
+			if len(trail) != 1 || trail[0].EntityID != revision {
+				t.Errorf("expected the restore audited with revision %s, got %+v", revision, trail)
+			}
+		})
+	}
+}
+
+func TestHandler_BackupRestoreKeepsPasswords(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	changePassword := func(req *http.Request) int {
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		return rr.Code
+	}
+	if code := changePassword(adminRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(`{"newPassword":"first-password"}`))); code != http.StatusOK {
+		t.Fatalf("expected the password set, got %d", code)
+	}
+
+	// The backup leaves out the hash
+	rr := httptest.NewRecorder()
+	serve(h, rr, adminRequest(http.MethodGet, "/api/admin/backup", nil))
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "$2") {
+		t.Fatalf("expected a backup without password hashes, got %d: %s", rr.Code, rr.Body)
+	}
+	backup := rr.Body.Bytes()
+	rr = httptest.NewRecorder()
+	serve(h, rr, adminRequest(http.MethodPost, "/api/admin/restore", bytes.NewReader(backup)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the backup restored, got %d: %s", rr.Code, rr.Body)
+	}
+
+	// The restored user still needs their password
+	tries := []struct {
+		body string
+		want int
+	}{
+		{`{"newPassword":"taken-over"}`, http.StatusUnauthorized},
+		{`{"currentPassword":"wrong-password","newPassword":"taken-over"}`, http.StatusUnauthorized},
+		{`{"currentPassword":"first-password","newPassword":"second-password"}`, http.StatusOK},
+	}
+	for _, try := range tries {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/1/password", strings.NewReader(try.body))
+		if code := changePassword(req); code != try.want {
+			t.Errorf("%s: expected status %d, got %d", try.body, try.want, code)
+		}
+	}
+}
+
+func TestHandler_BackupAccess(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.store.SetPasswordHash(context.Background(), 1, "$2a$10$secrethash")
+	h.config.Server.APIKeys = []string{"key-1"}
+	backup := func(target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		return rr
+	}
+
+	if rr := backup("/api/admin/backup", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a key, got %d", rr.Code)
+	}
+	if rr := backup("/api/admin/backup", "key-1"); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with an ordinary key, got %d", rr.Code)
+	}
+	rr := backup("/api/admin/backup", testAdminKey)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "secrethash") {
+		t.Errorf("expected a backup without password hashes, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := backup("/api/admin/backup?passwordHashes=true", testAdminKey); !strings.Contains(rr.Body.String(), "secrethash") {
+		t.Errorf("expected the password hashes when asked for, got %s", rr.Body)
+	}
+
+	// Without admin keys, backups and restores are off
+	h.config.Server.AdminKeys = nil
+	if rr := backup("/api/admin/backup", "key-1"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without admin keys, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Reset(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	reset := func(target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		return rr
+	}
+
+	// Without a key configured, the endpoint does not exist
+	if rr := reset("/api/admin/reset", "qa-key"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when disabled, got %d", rr.Code)
+	}
+
+	h.config.ResetKey = "qa-key"
+	if rr := reset("/api/admin/reset", "wrong-key"); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "UNAUTHORIZED") {
+		t.Errorf("expected 401 UNAUTHORIZED for a wrong key, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := reset("/api/admin/reset?profile=missing", "qa-key"); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_PROFILE") {
+		t.Errorf("expected 400 INVALID_PROFILE, got %d: %s", rr.Code, rr.Body)
+	}
+
+	sub, _, _ := h.events.Subscribe(0)
+	defer sub.Close()
+	rr := reset("/api/admin/reset?profile=demo", "qa-key")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	ctx := context.Background()
+	if users, tasks := len(must(h.store.GetUsers(ctx))), len(must(h.store.GetTasks(ctx, "", ""))); users != 5 || tasks != 12 {
+		t.Errorf("expected the demo data, got %d users and %d tasks", users, tasks)
+	}
+	if event := <-sub.Events(); event.Type != events.Reset {
+		t.Errorf("expected live clients told to reload, got %s", event.Type)
+	}
+	if trail := h.store.GetAuditEvents(ctx, model.AuditFilter{Entity: "fixture"}); len(trail) != 1 || trail[0].Action != events.ActionReset || trail[0].EntityID != "demo" {
+		t.Errorf("expected the reset audited, got %+v", trail)
+	}
+
+	// The default profile is the sample data
+	reset("/api/admin/reset", "qa-key")
+	if tasks := must(h.store.GetTasks(ctx, "", "")); len(tasks) != 3 || tasks[0].Title != "Implement authentication" {
+		t.Errorf("expected the sample tasks, got %+v", tasks)
+	}
+
+	// With authentication enabled, the reset key stands in for an API key
+	h.config.Server = ServerConfig{APIKeys: []string{"key-1"}, AdminKeys: []string{testAdminKey}}
+	handler := h.chain(h.config.Server.withDefaults()).Then(routes(h))
+	for _, tt := range []struct {
+		key  string
+		want int
+	}{
+		{"qa-key", http.StatusOK},
+		{"key-1", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/reset", nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("expected %d resetting with key %q and authentication enabled, got %d: %s", tt.want, tt.key, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestHandler_AdminRoutesRequireAdminKey(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.Settings = func() (interface{}, error) { return map[string]interface{}{}, nil }
+	h.config.Reload = func() ([]string, error) { return nil, nil }
+	h.config.Server = ServerConfig{APIKeys: []string{"key-1"}, AdminKeys: []string{testAdminKey}}
+	handler := h.chain(h.config.Server.withDefaults()).Then(routes(h))
+	serve := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	adminRoutes := []struct{ method, target string }{
+		{http.MethodGet, "/api/admin/route-usage"},
+		{http.MethodGet, "/api/admin/backup"},
+		{http.MethodPost, "/api/admin/restore"},
+		{http.MethodPost, "/api/admin/cache/flush"},
+		{http.MethodPost, "/api/admin/persist"},
+		{http.MethodGet, "/api/admin/config"},
+		{http.MethodPost, "/api/admin/reload"},
+		{http.MethodPost, "/api/admin/digest"},
+		{http.MethodPost, "/api/admin/reports/refresh"},
+		{http.MethodGet, "/api/admin/consistency"},
+		{http.MethodPost, "/api/admin/consistency"},
+		{http.MethodGet, "/api/admin/diagnose"},
+		{http.MethodGet, "/api/admin/webhooks"},
+		{http.MethodPost, "/api/admin/webhooks"},
+		{http.MethodGet, "/api/admin/webhooks/1"},
+		{http.MethodDelete, "/api/admin/webhooks/1"},
+		{http.MethodGet, "/api/admin/webhooks/1/deliveries"},
+	}
+	for _, route := range adminRoutes {
+		if rr := serve(route.method, route.target, ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected 401 without a key, got %d", route.method, route.target, rr.Code)
+		}
+		if rr := serve(route.method, route.target, "key-1"); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "FORBIDDEN") {
+			t.Errorf("%s %s: expected 403 with an ordinary key, got %d: %s", route.method, route.target, rr.Code, rr.Body)
+		}
+	}
+	if rr := serve(http.MethodGet, "/api/admin/diagnose", testAdminKey); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 with the admin key, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := serve(http.MethodGet, "/api/tasks", testAdminKey); rr.Code != http.StatusOK {
+		t.Errorf("expected the admin key to call the rest of the API, got %d", rr.Code)
+	}
+
+	// Without admin keys, the admin routes are not routed
+	h.config.Server.AdminKeys = nil
+	handler = h.chain(h.config.Server.withDefaults()).Then(routes(h))
+	for _, route := range adminRoutes {
+		if rr := serve(route.method, route.target, "key-1"); rr.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404 without admin keys, got %d", route.method, route.target, rr.Code)
+		}
+	}
+}
+
+func TestHandler_AdminCacheFlushAndPersist(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	post := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		serve(h, rr, adminRequest(http.MethodPost, target, nil))
+		return rr
+	}
+
+	h.cache.Set(cache.UsersKey(), "poisoned")
+	if rr := post("/api/admin/cache/flush"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if _, ok := h.cache.Get(cache.UsersKey()); ok {
+		t.Error("expected the cache flushed")
+	}
+
+	if rr := post("/api/admin/persist"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	data, err := store.LoadDataFrom(h.store.(*store.Store).DataPath())
+	if err != nil || len(data.Tasks) != 2 {
+		t.Errorf("expected the data file written, got %v, %v", data, err)
+	}
+}
+
+func TestHandler_AdminConfigAndReload(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	serveAdmin := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		serve(h, rr, adminRequest(method, target, nil))
+		return rr
+	}
+
+	// Without the server's configuration, the endpoints do not exist
+	if rr := serveAdmin(http.MethodGet, "/api/admin/config"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when not configured, got %d", rr.Code)
+	}
+
+	h.config.Settings = func() (interface{}, error) {
+		return map[string]interface{}{"auth": map[string]interface{}{"apiKeys": []string{"REDACTED"}}}, nil
+	}
+	rr := serveAdmin(http.MethodGet, "/api/admin/config")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"apiKeys":["REDACTED"]`) {
+		t.Errorf("expected the settings, got %d: %s", rr.Code, rr.Body)
+	}
+
+	reloadErr := errors.New("server.port \"x\" is not a port number")
+	h.config.Reload = func() ([]string, error) { return nil, reloadErr }
+	rr = serveAdmin(http.MethodPost, "/api/admin/reload")
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "server.port") {
+		t.Errorf("expected the reasons the configuration was rejected, got %d: %s", rr.Code, rr.Body)
+	}
+
+	h.config.Reload = func() ([]string, error) { return []string{"server.port"}, nil }
+	rr = serveAdmin(http.MethodPost, "/api/admin/reload")
+	var response model.ReloadResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", rr.Code, err)
+	}
+	if !response.Success || len(response.RestartNeeded) != 1 || response.RestartNeeded[0] != "server.port" {
+		t.Errorf("expected the settings needing a restart, got %+v", response)
+	}
+}
+
+func TestHandler_StoreMetrics(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without metrics, got %d", rr.Code)
+	}
+
+	h.config.StoreMetrics = store.NewMetrics()
+	h.store = store.Instrument(h.store, h.config.StoreMetrics)
+
+	// Checks of the wrapped store still find it saves to files
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"persistence":"ok"`) {
+		t.Errorf("expected the data file checked, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/stats/store", nil))
+	var stats model.StoreStatsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", rr.Code, err)
+	}
+	if stats.Reads == 0 || len(stats.Operations) == 0 || stats.Operations[0].Op == "" {
+		t.Errorf("expected the reads of the health check, got %+v", stats)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") ||
+		!strings.Contains(rr.Body.String(), `store_operations_total{op="GetUsers",kind="read"} 1`) {
+		t.Errorf("expected the counters in the Prometheus format, got %s", rr.Body)
+	}
+}
+
+func TestFixtures_Valid(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range fixtures.Profiles() {
+		snap, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatalf("profile %q failed to load: %v", name, err)
+		}
+		if err := validateBackup(snap); err != nil {
+			t.Errorf("profile %q is not a valid backup: %v", name, err)
+		}
+	}
+}
+
+func TestHandler_Restore_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode string
+	}{
+		{"malformed json", `{"users":`, "INVALID_JSON"},
+		{"duplicate user id", `{"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev"},{"id":1,"name":"B","email":"b@example.com","role":"dev"}],"tasks":[]}`, "INVALID_BACKUP"},
+		{"invalid email", `{"users":[{"id":1,"name":"A","email":"nope","role":"dev"}],"tasks":[]}`, "INVALID_BACKUP"},
+		{"unknown task user", `{"users":[],"tasks":[{"id":1,"title":"T","status":"pending","userId":9}]}`, "INVALID_BACKUP"},
+		{"invalid status", `{"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev"}],"tasks":[{"id":1,"title":"T","status":"done","userId":1}]}`, "INVALID_BACKUP"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newTestHandler(t)
+
+			req := adminRequest(http.MethodPost, "/api/admin/restore", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			serve(h, rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rr.Code)
+			}
+
+			var response model.ErrorResponse
+			json.NewDecoder(rr.Body).Decode(&response)
+			if response.Code != tt.wantCode {
+				t.Errorf("expected code %s, got %s", tt.wantCode, response.Code)
+			}
+
+			// The store is left untouched
+			if got := len(must(h.store.GetUsers(context.Background()))); got != 2 {
+				t.Errorf("expected 2 users to remain, got %d", got)
+			}
+		})
+	}
+}
+
+func TestHandler_DetailedStats(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats?detailed=true", nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	var response model.DetailedStatsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Tasks.Total != 2 || len(response.PerUser) != 2 {
+		t.Fatalf("expected 2 tasks and 2 user rows, got %d and %d", response.Tasks.Total, len(response.PerUser))
+	}
+	if row := response.PerUser[0]; row.UserID != 1 || row.Tasks.Pending != 1 {
+		t.Errorf("expected user 1 with 1 pending task, got %+v", row)
+	}
+}
+
+func TestHandler_DetailedStats_Stream(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats?detailed=true", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if got := rr.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("expected Content-Type application/x-ndjson, got %q", got)
+	}
+	if !rr.Flushed {
+		t.Error("expected rows to be flushed as they are written")
+	}
+
+	var types []string
+	dec := json.NewDecoder(rr.Body)
+	for dec.More() {
+		var event model.StatsStreamEvent
+		if err := dec.Decode(&event); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		types = append(types, event.Type)
+	}
+
+	if want := []string{"user", "user", "summary"}; strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("expected events %v, got %v", want, types)
+	}
+}
+
+// errStoreDown is the error failingStore returns.
+var errStoreDown = errors.New("store unreachable")
+
+// failingStore is a Backend whose operation named fail returns
+// errStoreDown, as a database store does when the database is down.
+type failingStore struct {
+	store.Backend
+	fail string
+}
+
+func (s failingStore) GetUsers(ctx context.Context) ([]model.User, error) {
+	if s.fail == "GetUsers" {
+		return nil, errStoreDown
+	}
+	return s.Backend.GetUsers(ctx)
+}
+
+func (s failingStore) GetUserByID(ctx context.Context, id int) (*model.User, error) {
+	if s.fail == "GetUserByID" {
+		return nil, errStoreDown
+	}
+	return s.Backend.GetUserByID(ctx, id)
+}
+
+func (s failingStore) UserExistsByEmail(ctx context.Context, email string) (bool, error) {
+	if s.fail == "UserExistsByEmail" {
+		return false, errStoreDown
+	}
+	return s.Backend.UserExistsByEmail(ctx, email)
+}
+
+func (s failingStore) GetTaskByID(ctx context.Context, id int) (*model.Task, error) {
+	if s.fail == "GetTaskByID" {
+		return nil, errStoreDown
+	}
+	return s.Backend.GetTaskByID(ctx, id)
+}
+
+func (s failingStore) Snapshot(ctx context.Context) (store.Snapshot, error) {
+	if s.fail == "Snapshot" {
+		return store.Snapshot{}, errStoreDown
+	}
+	return s.Backend.Snapshot(ctx)
+}
+
+func (s failingStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	if s.fail == "CreateUser" {
+		return model.User{}, errStoreDown
+	}
+	return s.Backend.CreateUser(ctx, user)
+}
+
+func (s failingStore) Restore(ctx context.Context, snap store.Snapshot) error {
+	if s.fail == "Restore" {
+		return errStoreDown
+	}
+	return s.Backend.Restore(ctx, snap)
+}
+
+func TestHandler_StoreErrors(t *testing.T) {
+	t.Parallel()
+
+	backup := `{"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev"}],"tasks":[]}`
+	tests := []struct {
+		fail   string
+		method string
+		target string
+		body   string
+	}{
+		{"GetUsers", http.MethodGet, "/api/users", ""},
+		{"GetUserByID", http.MethodGet, "/api/users/1", ""},
+		{"UserExistsByEmail", http.MethodPost, "/api/users", `{"name":"New","email":"new@example.com","role":"developer"}`},
+		{"GetTaskByID", http.MethodGet, "/api/tasks/1", ""},
+		{"Snapshot", http.MethodGet, "/api/admin/backup", ""},
+		{"Snapshot", http.MethodGet, "/api/users/export", ""},
+		{"CreateUser", http.MethodPost, "/api/users", `{"name":"New","email":"new@example.com","role":"developer"}`},
+		{"Restore", http.MethodPost, "/api/admin/restore", backup},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.fail+" "+tt.target, func(t *testing.T) {
+			t.Parallel()
+
+			base := newTestHandler(t)
+			h := New(failingStore{Backend: base.store, fail: tt.fail}, base.cache, base.config)
+			var changes []events.Change
+			h.bus.Subscribe("test", func(ctx context.Context, c events.Change) {
+				changes = append(changes, c)
+			})
+
+			rr := httptest.NewRecorder()
+			serve(h, rr, adminRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "INTERNAL_ERROR") {
+				t.Fatalf("expected status 500, got %d: %s", rr.Code, rr.Body)
+			}
+			if len(changes) != 0 {
+				t.Errorf("expected no change emitted, got %+v", changes)
+			}
+			if _, found := h.cache.Get(cache.UsersKey()); found {
+				t.Error("expected the failed read not to be cached")
+			}
+			if got := len(must(base.store.GetUsers(context.Background()))); got != 2 {
+				t.Errorf("expected the 2 users unchanged, got %d", got)
+			}
+		})
+	}
+}
+
+func TestHandler_OperationsLimited(t *testing.T) {
+	t.Parallel()
+
+	base := newTestHandler(t)
+	cfg := base.config
+	cfg.OperationLimits = operation.Limits{MaxRunning: 1}
+	h := New(base.store, base.cache, cfg)
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := h.ops.Start("test", func(context.Context, *operation.Progress) (interface{}, error) {
+		<-release
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("failed to start an operation: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/stats?detailed=true&async=true", nil))
+
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "TOO_MANY_OPERATIONS") || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After while the operation runs, got %d %v: %s", rr.Code, rr.Header(), rr.Body)
+	}
+	if ops := h.ops.List(); len(ops) != 1 {
+		t.Errorf("expected no operation started, got %+v", ops)
+	}
+}
+
+func TestHandler_Restore_Async(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev"}],"tasks":[]}`
+	req := adminRequest(http.MethodPost, "/api/admin/restore", strings.NewReader(body))
+	req.Header.Set("Prefer", "respond-async")
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rr.Code)
+	}
+	location := rr.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/operations/") {
+		t.Fatalf("expected Location of the operation, got %q", location)
+	}
+
+	var op model.Operation
+	deadline := time.Now().Add(time.Second)
+	for op.Status != model.OperationSucceeded && time.Now().Before(deadline) {
+		rr = httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, location, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200 polling the operation, got %d", rr.Code)
+		}
+		json.NewDecoder(rr.Body).Decode(&op)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if op.Status != model.OperationSucceeded || op.Kind != "restore" {
+		t.Fatalf("expected succeeded restore operation, got %+v", op)
+	}
+	if got := len(must(h.store.GetUsers(context.Background()))); got != 1 {
+		t.Errorf("expected 1 user after restore, got %d", got)
+	}
+}
+
+func TestHandler_OperationNotFound(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/operations/missing", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestHandler_TasksExport(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/export?format=csv", nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("expected CSV content type, got %q", got)
+	}
+	if got, want := rr.Header().Get("X-Snapshot-Revision"), strconv.FormatInt(h.store.Revision(context.Background()), 10); got != want {
+		t.Errorf("expected the export taken at revision %s, got %q", want, got)
+	}
+	want := "id,title,status,userId,priority,tags,description,createdAt,completedAt,dueAt\n1,Test task 1,pending,1,medium,backend,,,,\n2,Test task 2,in-progress,2,high,\"backend,ui\",,,,\n"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/export?format=xlsx", nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unsupported format, got %d", rr.Code)
+	}
+}
+
+func TestHandler_TasksExport_Formats(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	due := time.Date(2026, 3, 20, 17, 30, 0, 0, time.UTC)
+	task := must(h.store.CreateTask(context.Background(), model.Task{Title: "Dated", Status: "pending", Priority: "low", UserID: 1, DueAt: &due}))
+
+	export := func(query, language string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks/export?"+query, nil)
+		if language != "" {
+			req.Header.Set("Accept-Language", language)
+		}
+		req.Header.Set(timezoneHeader, "Europe/Berlin")
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		return rr
+	}
+	lastLine := func(rr *httptest.ResponseRecorder) string {
+		lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		return lines[len(lines)-1]
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load zone: %v", err)
+	}
+	created := func(layout string) string { return task.CreatedAt.In(berlin).Format(layout) }
+
+	rr := export("", "")
+	if want := ",Dated,pending,1,low,,," + created(time.RFC3339) + ",,2026-03-20T18:30:00+01:00"; !strings.HasSuffix(lastLine(rr), want) {
+		t.Errorf("expected commas and RFC 3339 times without Accept-Language, got %q", lastLine(rr))
+	}
+
+	rr = export("", "de-DE")
+	if want := ";Dated;pending;1;low;;;" + created("02.01.2006 15:04") + ";;20.03.2026 18:30"; !strings.HasSuffix(lastLine(rr), want) {
+		t.Errorf("expected German conventions, got %q", lastLine(rr))
+	}
+	if got := rr.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("expected Content-Language de, got %q", got)
+	}
+
+	rr = export("delimiter=tab&dateFormat=yyyy-MM-dd", "de")
+	if want := "\tDated\tpending\t1\tlow\t\t\t" + created("2006-01-02") + "\t\t2026-03-20"; !strings.HasSuffix(lastLine(rr), want) {
+		t.Errorf("expected parameters to override the locale, got %q", lastLine(rr))
+	}
+
+	for _, query := range []string{"delimiter=colon", "decimal=comma&dateFormat=EEEE", "format=xlsx"} {
+		if rr := export(query, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rr.Code)
+		}
+	}
+
+	spec := `{"entities":"tasks","metrics":[{"op":"avg","field":"userId"}],"format":"csv"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/reports?decimal=comma&delimiter=semicolon", strings.NewReader(spec))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if got := lastLine(rr); got != "1,3333333333333333" {
+		t.Errorf("expected a decimal comma in the report, got %q", got)
+	}
+
+	// A German export can be imported again
+	body := export("", "de").Body.String()
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks/import?delimiter=semicolon&dryRun=true", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	var imported model.ImportReport
+	json.NewDecoder(rr.Body).Decode(&imported)
+	if rr.Code != http.StatusOK || imported.Total != 3 || len(imported.Errors) != 0 {
+		t.Errorf("expected the export to import cleanly, got %d: %+v", rr.Code, imported)
+	}
+}
+
+func TestHandler_TasksImport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		query        string
+		body         string
+		wantStatus   int
+		wantErrorRow []int
+		wantTasks    int
+	}{
+		{
+			name:       "valid",
+			body:       "title,status,userId\nImported one,pending,1\n\"Imported, two\",completed,2\n",
+			wantStatus: http.StatusOK,
+			wantTasks:  4,
+		},
+		{
+			name:       "export columns accepted",
+			body:       "id,title,status,userId\n99,Imported,pending,1\n",
+			wantStatus: http.StatusOK,
+			wantTasks:  3,
+		},
+		{
+			name:         "invalid rows reject the file",
+			body:         "title,status,userId\nOk,pending,1\n,pending,1\nBad status,done,1\nNo user,pending,999\nShort row\n",
+			wantStatus:   http.StatusBadRequest,
+			wantErrorRow: []int{3, 4, 5, 6},
+			wantTasks:    2,
+		},
+		{
+			name:         "dry run reports without importing",
+			query:        "?dryRun=true",
+			body:         "title,status,userId\nOk,pending,1\nBad,done,1\n",
+			wantStatus:   http.StatusOK,
+			wantErrorRow: []int{3},
+			wantTasks:    2,
+		},
+		{
+			name:       "unknown column",
+			body:       "title,status,owner\nTask,pending,1\n",
+			wantStatus: http.StatusBadRequest,
+			wantTasks:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newTestHandler(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/tasks/import"+tt.query, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			serve(h, rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d (body: %s)", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			var report model.ImportReport
+			json.NewDecoder(rr.Body).Decode(&report)
+			var rows []int
+			for _, e := range report.Errors {
+				rows = append(rows, e.Row)
+			}
+			if fmt.Sprint(rows) != fmt.Sprint(tt.wantErrorRow) {
+				t.Errorf("expected errors on rows %v, got %v", tt.wantErrorRow, rows)
+			}
+
+			if got := len(must(h.store.GetTasks(context.Background(), "", ""))); got != tt.wantTasks {
+				t.Errorf("expected %d tasks, got %d", tt.wantTasks, got)
+			}
+		})
+	}
+}
+
+func TestHandler_UsersImport_DuplicateEmails(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := "name,email,role\nNew,new@example.com,developer\nAgain,New@Example.com,developer\nTaken,JOHN@example.com,developer\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/users/import?dryRun=true", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	var report model.ImportReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.Success || !report.DryRun || report.Total != 3 || len(report.Errors) != 2 {
+		t.Fatalf("expected failed dry run of 3 rows with 2 errors, got %+v", report)
+	}
+	for _, e := range report.Errors {
+		if e.Code != "EMAIL_EXISTS" {
+			t.Errorf("row %d: expected code EMAIL_EXISTS, got %s", e.Row, e.Code)
+		}
+	}
+}
+
+// waitForOperation polls the operation at location until it has finished.
+func waitForOperation(t *testing.T, h *Handler, location string) model.Operation {
+	t.Helper()
+
+	var op model.Operation
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, location, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200 polling the operation, got %d", rr.Code)
+		}
+		json.NewDecoder(rr.Body).Decode(&op)
+		if op.Status != model.OperationRunning {
+			return op
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("operation at %s did not finish", location)
+	return op
+}
+
+func TestHandler_TasksImport_AsyncProgress(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := "title,status,userId\nOne,pending,1\nTwo,pending,2\nBad,done,1\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/import?async=true", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rr.Code)
+	}
+	op := waitForOperation(t, h, rr.Header().Get("Location"))
+
+	if op.Status != model.OperationFailed || op.Result == nil {
+		t.Fatalf("expected failed import with its report, got %+v", op)
+	}
+	if op.Progress == nil || op.Progress.Total != 3 || op.Progress.Errors != 1 || op.Progress.Processed != 0 {
+		t.Errorf("expected 0 of 3 processed with 1 error, got %+v", op.Progress)
+	}
+
+	// A finished operation can no longer be cancelled
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodDelete, "/api/operations/"+op.ID, nil))
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status 409 cancelling a finished operation, got %d", rr.Code)
+	}
+}
+
+func TestCSVImport_CancelKeepsImportedRows(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	imp := csvImport{
+		schema:     taskCSV,
+		kind:       "tasks",
+		validate:   h.validateTaskRows,
+		invalidate: h.InvalidateTaskCaches,
+		create: func(ctx context.Context, row csvRow) error {
+			_, err := h.store.CreateTask(ctx, model.Task{Title: row.values["title"], Status: "pending", UserID: 1})
+			cancel()
+			return err
+		},
+	}
+	rows, _, err := taskCSV.readCSV(strings.NewReader("title,status,userId\nOne,pending,1\nTwo,pending,1\n"), ',')
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	report, err := imp.run(ctx, nil, rows, nil, false)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if report.Imported != 1 {
+		t.Errorf("expected 1 row imported before cancellation, got %d", report.Imported)
+	}
+	if got := len(must(h.store.GetTasks(context.Background(), "", ""))); got != 3 {
+		t.Errorf("expected the imported row to be kept, got %d tasks", got)
+	}
+}
+
+func TestHandler_TasksValidate(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `[{"title":"Ok","status":"pending","userId":1},{"title":"","status":"pending","userId":1},{"title":"No user","status":"pending","userId":999}]`
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPost, "/api/tasks/validate", strings.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var report model.ValidationReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.Valid || report.Total != 3 || report.Invalid != 2 {
+		t.Fatalf("expected 2 of 3 items invalid, got %+v", report)
+	}
+	if !report.Items[0].Valid {
+		t.Errorf("expected item 0 to be valid, got %+v", report.Items[0])
+	}
+	if got := report.Items[1].Errors[0].Code; got != "INVALID_TITLE" {
+		t.Errorf("expected item 1 to fail with INVALID_TITLE, got %s", got)
+	}
+	if got := report.Items[2].Errors[0].Code; got != "INVALID_USER_ID" {
+		t.Errorf("expected item 2 to fail with INVALID_USER_ID, got %s", got)
+	}
+	if got := len(must(h.store.GetTasks(context.Background(), "", ""))); got != 2 {
+		t.Errorf("expected nothing to be persisted, got %d tasks", got)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPost, "/api/tasks/validate", strings.NewReader(`{"title":"Ok"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a non-array body, got %d", rr.Code)
+	}
+}
+
+func TestHandler_UsersValidate(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `[{"name":"New","email":"new@example.com","role":"developer","password":"short"},{"name":"Again","email":"new@example.com","role":"developer"}]`
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodPost, "/api/users/validate", strings.NewReader(body)))
+
+	var report model.ValidationReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.Invalid != 2 {
+		t.Fatalf("expected both items invalid, got %+v", report)
+	}
+	if got := report.Items[0].Errors[0].Code; got != "INVALID_PASSWORD" {
+		t.Errorf("expected item 0 to fail with INVALID_PASSWORD, got %s", got)
+	}
+	if got := report.Items[1].Errors[0].Code; got != "EMAIL_EXISTS" {
+		t.Errorf("expected item 1 to duplicate item 0's email, got %s", got)
+	}
+}
+
+func TestHandler_Schema(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/schema", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var schema model.SchemaResponse
+	if err := json.NewDecoder(rr.Body).Decode(&schema); err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+
+	fields := make(map[string]model.FieldSchema)
+	for _, f := range schema.Models["task"].Fields {
+		fields[f.Name] = f
+	}
+	if got := strings.Join(fields["status"].Enum, ","); got != "pending,in-progress,completed" {
+		t.Errorf("expected task status enum from the validator, got %q", got)
+	}
+	if !fields["userId"].Required || fields["userId"].References != "user" {
+		t.Errorf("expected required userId referencing user, got %+v", fields["userId"])
+	}
+
+	for _, f := range schema.Models["user"].Fields {
+		if f.Name == "password" && (!f.WriteOnly || f.MinLength != 8 || f.MaxLength != 72) {
+			t.Errorf("expected write-only password of 8-72 characters, got %+v", f)
+		}
+	}
+}
+
+func TestHandler_Changelog(t *testing.T) {
+	t.Parallel()
+
+	// Keep the table in order, so ?since= and readers can rely on it
+	for i, release := range changelog {
+		if _, ok := parseVersion(release.Version); !ok || len(release.Changes) == 0 {
+			t.Errorf("expected release %q to have a valid version and changes", release.Version)
+		}
+		if i > 0 && compareVersions(release.Version, changelog[i-1].Version) >= 0 {
+			t.Errorf("expected release %s before %s, newest first", changelog[i-1].Version, release.Version)
+		}
+		for _, change := range release.Changes {
+			switch change.Type {
+			case changeAdded, changeChanged, changeDeprecated, changeRemoved:
+			default:
+				t.Errorf("release %s: unknown change type %q", release.Version, change.Type)
+			}
+			if change.Subject == "" || change.Description == "" {
+				t.Errorf("release %s: expected a subject and description, got %+v", release.Version, change)
+			}
+		}
+	}
+
+	h := newTestHandler(t)
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	var response model.ChangelogResponse
+	json.NewDecoder(get("/api/changelog").Body).Decode(&response)
+	if response.Current != "test" || len(response.Releases) != len(changelog) {
+		t.Errorf("expected every release and the server version, got %+v", response)
+	}
+	json.NewDecoder(get("/api/changelog?since=0.9").Body).Decode(&response)
+	if len(response.Releases) == 0 || response.Releases[0].Version != changelog[0].Version {
+		t.Errorf("expected the releases after 0.9, got %+v", response.Releases)
+	}
+	json.NewDecoder(get("/api/changelog?since=" + changelog[0].Version).Body).Decode(&response)
+	if response.Releases == nil || len(response.Releases) != 0 {
+		t.Errorf("expected no releases after the latest, got %+v", response.Releases)
+	}
+	if rr := get("/api/changelog?since=latest"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid version, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Enums(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/statuses", nil))
+
+	var statuses model.StatusesResponse
+	json.NewDecoder(rr.Body).Decode(&statuses)
+	if statuses.Count != 3 || statuses.Statuses[0] != "pending" {
+		t.Errorf("expected the validator's 3 statuses, got %+v", statuses)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/roles", nil))
+
+	var roles model.RolesResponse
+	json.NewDecoder(rr.Body).Decode(&roles)
+	if got := strings.Join(roles.Roles, ","); got != "developer,designer,manager,admin" || roles.AllowCustom {
+		t.Errorf("expected the allowed roles without custom ones, got %+v", roles)
+	}
+}
+
+func TestHandler_Localization(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/statuses", nil)
+	req.Header.Set("Accept-Language", "ja, de-AT;q=0.8, en;q=0.5")
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	var statuses model.StatusesResponse
+	json.NewDecoder(rr.Body).Decode(&statuses)
+	if statuses.Locale != "de" || statuses.Labels["in-progress"] != "In Bearbeitung" {
+		t.Errorf("expected German labels, got %+v", statuses)
+	}
+	if statuses.Statuses[1] != "in-progress" {
+		t.Errorf("expected canonical statuses to be unchanged, got %v", statuses.Statuses)
+	}
+	if got := rr.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("expected Content-Language de, got %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Accept-Language" {
+		t.Errorf("expected Vary: Accept-Language, got %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/locale", nil))
+
+	var loc model.LocaleResponse
+	json.NewDecoder(rr.Body).Decode(&loc)
+	if loc.Locale != "en" || loc.DateFormat != "MM/dd/yyyy" || loc.StatusLabels["pending"] != "Pending" {
+		t.Errorf("expected the default English locale, got %+v", loc)
+	}
+	if len(loc.Supported) < 2 {
+		t.Errorf("expected supported locales to be listed, got %v", loc.Supported)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	var got RequestOptions
+	var gotErr *fieldError
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, gotErr = requestOptions(r)
+	})
+	versions := middleware.Versioning(middleware.VersionConfig{Default: defaultAPIVersion, Adapters: apiVersions})
+	handler := versions(negotiate(next))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks?view=compact", nil)
+	req.Header.Set("Accept-Language", "fr-CA, en;q=0.5")
+	req.Header.Set(timezoneHeader, "Europe/Berlin")
+	req.Header.Set("X-API-Version", "2")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if gotErr != nil || got.Locale.Tag != "fr" || got.Location.String() != "Europe/Berlin" || got.Version != "2" || got.View != viewCompact {
+		t.Errorf("expected every preference negotiated, got %+v, %v", got, gotErr)
+	}
+
+	// Invalid preferences are left to the handlers that use them
+	req = httptest.NewRequest(http.MethodGet, "/health?view=wide", nil)
+	req.Header.Set(timezoneHeader, "Mars/Olympus_Mons")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || gotErr == nil || gotErr.Code != "INVALID_TIMEZONE" {
+		t.Errorf("expected the request served with the timezone error noted, got %d and %v", rr.Code, gotErr)
+	}
+	if got.Locale.Tag != "en" || got.Location != nil || got.Version != defaultAPIVersion || got.View != viewFull {
+		t.Errorf("expected the defaults for the rest, got %+v", got)
+	}
+
+	// Handlers called without the middleware negotiate for themselves
+	rr = httptest.NewRecorder()
+	h := newTestHandler(t)
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks?view=wide", nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_VIEW") {
+		t.Errorf("expected 400 INVALID_VIEW, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestHandler_TaskDueDates(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	do := func(method, path, body, zone string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if zone != "" {
+			req.Header.Set(timezoneHeader, zone)
+		}
+		rr := httptest.NewRecorder()
+		switch {
+		case path == "/api/users":
+			serve(h, rr, req)
+		case path == "/api/tasks":
+			serve(h, rr, req)
+		default:
+			serve(h, rr, req)
+		}
+		return rr
+	}
+	dueAt := func(rr *httptest.ResponseRecorder) string {
+		t.Helper()
+		var task struct {
+			ID    int    `json:"id"`
+			DueAt string `json:"dueAt"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return task.DueAt
+	}
+
+	rr := do(http.MethodPost, "/api/users", `{"name":"Nora","email":"nora@example.com","role":"developer","timezone":"Mars/Base"}`, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown time zone, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/api/users", `{"name":"Nora","email":"nora@example.com","role":"developer","timezone":"America/New_York"}`, "")
+	var user model.User
+	json.NewDecoder(rr.Body).Decode(&user)
+	if user.Timezone != "America/New_York" {
+		t.Fatalf("expected the time zone preference to be kept, got %+v", user)
+	}
+
+	// A date is due when the next day starts in the assignee's zone, which
+	// on the day clocks go forward is 23 hours after it began
+	rr = do(http.MethodPost, "/api/tasks", fmt.Sprintf(`{"title":"Taxes","status":"pending","userId":%d,"dueAt":"2026-03-08"}`, user.ID), "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created model.Task
+	json.NewDecoder(rr.Body).Decode(&created)
+	if got := created.DueAt.Format(time.RFC3339); got != "2026-03-09T00:00:00-04:00" {
+		t.Errorf("expected the due date in the assignee's zone, got %s", got)
+	}
+	stored := must(h.store.GetTaskByID(context.Background(), created.ID))
+	if stored.DueAt.Location() != time.UTC || stored.DueAt.Format(time.RFC3339) != "2026-03-09T04:00:00Z" {
+		t.Errorf("expected the due date stored in UTC, got %v", stored.DueAt)
+	}
+
+	path := fmt.Sprintf("/api/tasks/%d", created.ID)
+	if got := dueAt(do(http.MethodGet, path, "", "Europe/Berlin")); got != "2026-03-09T05:00:00+01:00" {
+		t.Errorf("expected the due date in the requested zone, got %s", got)
+	}
+	if rr := do(http.MethodGet, path, "", "Nowhere"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid %s header, got %d", timezoneHeader, rr.Code)
+	}
+
+	// The requested zone also decides how a new due date is read
+	if got := dueAt(do(http.MethodPut, path, `{"dueAt":"2026-03-10T09:00"}`, "Asia/Tokyo")); got != "2026-03-10T09:00:00+09:00" {
+		t.Errorf("expected the due date read in the requested zone, got %s", got)
+	}
+	if got := dueAt(do(http.MethodPut, path, `{"dueAt":""}`, "")); got != "" {
+		t.Errorf("expected an empty dueAt to clear the due date, got %s", got)
+	}
+	if rr := do(http.MethodPut, path, `{"dueAt":"next week"}`, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid due date, got %d", rr.Code)
+	}
+}
+
+func TestHandler_OverdueTasks(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	late := must(h.store.CreateTask(context.Background(), model.Task{Title: "Late", Status: "pending", UserID: 1, DueAt: &past}))
+	h.store.CreateTask(context.Background(), model.Task{Title: "Done late", Status: "completed", UserID: 1, DueAt: &past})
+	h.store.CreateTask(context.Background(), model.Task{Title: "Not yet", Status: "pending", UserID: 1, DueAt: &future})
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/tasks?overdue=true", nil))
+
+	var response model.TasksResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Count != 1 || response.Tasks[0].ID != late.ID {
+		t.Errorf("expected only the open task past its due date, got %+v", response.Tasks)
+	}
+	if _, found := h.cache.Get(cache.TasksKey("", "", "", "", "", "", "")); found {
+		t.Error("expected overdue lists not to be cached")
+	}
+}
+
+func TestHandler_BodyTooLarge(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	body := `{"name":"Big","email":"big@example.com","role":"` + strings.Repeat("x", 100) + `"}`
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+	req.Body = http.MaxBytesReader(rr, req.Body, 64)
+	h.createUser(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rr.Code)
+	}
+	var response model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Code != "REQUEST_TOO_LARGE" {
+		t.Errorf("expected code REQUEST_TOO_LARGE, got %q", response.Code)
+	}
+	if must(h.store.UserExistsByEmail(context.Background(), "big@example.com")) {
+		t.Error("expected the user not to be created")
+	}
+}
+
+func TestHandler_Restore_GzipExpansionLimited(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.Server.MaxUploadBytes = 1024
+
+	// Compresses to far less than the limit but expands past it
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"users":[],"tasks":[],"padding":"` + strings.Repeat("x", 64<<10) + `"}`))
+	gz.Close()
+	if buf.Len() >= 1024 {
+		t.Fatalf("expected the test backup to compress below the limit, got %d bytes", buf.Len())
+	}
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, adminRequest(http.MethodPost, "/api/admin/restore", &buf))
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServerConfig_BodyLimit(t *testing.T) {
+	t.Parallel()
+
+	cfg := ServerConfig{MaxBodyBytes: 10}.withDefaults()
+	tests := []struct {
+		path string
+		want int64
+	}{
+		{"/api/users", 10},
+		{"/api/tasks/import", DefaultMaxUploadBytes},
+		{"/api/admin/restore", DefaultMaxUploadBytes},
+	}
+	for _, tt := range tests {
+		if got := cfg.bodyLimit(httptest.NewRequest(http.MethodPost, tt.path, nil)); got != tt.want {
+			t.Errorf("bodyLimit(%s) = %d, want %d", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_ChainAuthAndCORS(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.SetCORSOrigins([]string{"https://app.example.com"})
+	cfg := ServerConfig{APIKeys: []string{"key-1"}}.withDefaults()
+	handler := h.chain(cfg).Then(routes(h))
+	serve := func(method, path, apiKey, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "/api/tasks", "", ""); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "UNAUTHORIZED") {
+		t.Errorf("expected 401 without a key, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodGet, "/api/tasks", "key-1", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 with a key, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodGet, "/health/live", "", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected health checks without a key, got %d", rr.Code)
+	}
+
+	rr := serve(http.MethodOptions, "/api/tasks", "", "https://app.example.com")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "X-API-Key") {
+		t.Errorf("expected a preflight allowing X-API-Key without a key, got %d %v", rr.Code, rr.Header())
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("expected the allowed origin, got %q", got)
+	}
+	rr = serve(http.MethodGet, "/api/tasks", "key-1", "https://evil.example.com")
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no allowed origin for another origin, got %q", got)
+	}
+	if !strings.Contains(strings.Join(rr.Header().Values("Vary"), ","), "Origin") {
+		t.Errorf("expected Vary: Origin, got %v", rr.Header().Values("Vary"))
+	}
+	// Origins can be changed while the server runs
+	h.SetCORSOrigins([]string{"https://evil.example.com"})
+	rr = serve(http.MethodGet, "/api/tasks", "key-1", "https://evil.example.com")
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://evil.example.com" {
+		t.Errorf("expected the new origin to be allowed, got %q", got)
+	}
+}
+
+func TestHandler_ChainRecoversPanics(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.SetCORSOrigins([]string{"https://app.example.com"})
+	handler := h.chain(ServerConfig{}.withDefaults()).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var task *model.Task
+		h.writeJSON(w, http.StatusOK, task.Title)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "INTERNAL_ERROR") {
+		t.Errorf("expected a 500 JSON error, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Request-ID") == "" || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("expected the request ID and allowed origin, got %v", rr.Header())
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method, host, port string
+		wantStatus         int
+		wantLocation       string
+	}{
+		{http.MethodGet, "example.com", "443", http.StatusMovedPermanently, "https://example.com/api/tasks?status=todo"},
+		{http.MethodGet, "example.com:8080", "8443", http.StatusMovedPermanently, "https://example.com:8443/api/tasks?status=todo"},
+		{http.MethodPost, "example.com", "443", http.StatusPermanentRedirect, "https://example.com/api/tasks?status=todo"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://"+tt.host+"/api/tasks?status=todo", nil)
+		rr := httptest.NewRecorder()
+		redirectToHTTPS(tt.port).ServeHTTP(rr, req)
+
+		if rr.Code != tt.wantStatus || rr.Header().Get("Location") != tt.wantLocation {
+			t.Errorf("%s %s to port %s: expected %d to %s, got %d to %s",
+				tt.method, tt.host, tt.port, tt.wantStatus, tt.wantLocation, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestHandler_UserPreferences(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/users/1/preferences", strings.NewReader(`{"digestOptOut":true}`))
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/users/1/preferences", nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	var prefs model.UserPreferences
+	json.NewDecoder(rr.Body).Decode(&prefs)
+	if !prefs.DigestOptOut {
+		t.Error("expected the opt-out to be saved")
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/users/999/preferences", strings.NewReader(`{}`))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown user, got %d", rr.Code)
+	}
+}
+
+func TestHandler_UserTasks(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/api/tasks", `{"title": "Ship it", "status": "completed", "priority": "low", "userId": 1}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body)
+	}
+
+	// ?userId= cannot name another user
+	rr := serve(http.MethodGet, "/api/users/1/tasks?status=pending&userId=2", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var list model.UserTasksResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.UserID != 1 || list.Count != 1 || list.Tasks[0].ID != 1 {
+		t.Errorf("expected only user 1's pending task, got %+v", list)
+	}
+	if want := (model.TaskCounts{Total: 2, Pending: 1, Completed: 1}); list.Summary != want {
+		t.Errorf("expected a summary of all of user 1's tasks %+v, got %+v", want, list.Summary)
+	}
+
+	// The list is cached as /api/tasks?userId=1 caches it
+	rr = serve(http.MethodGet, "/api/users/1/tasks?view=compact", "")
+	if _, found := h.cache.Get(taskFilter{userID: "1"}.cacheKey()); !found {
+		t.Error("expected the user's task list cached")
+	}
+	var compact model.CompactUserTasksResponse
+	json.NewDecoder(rr.Body).Decode(&compact)
+	if compact.Count != 2 || compact.Tasks[1].Title != "Ship it" || compact.Summary.Total != 2 {
+		t.Errorf("expected both tasks in the compact view with the summary, got %+v", compact)
+	}
+
+	if rr = serve(http.MethodGet, "/api/users/999/tasks", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown user, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodGet, "/api/users/1/tasks?sort=title", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid sort, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodPost, "/api/users/1/tasks", "{}"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Digest_DryRun(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	// A task assigned to user 2 now; user 1 opts out
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"title":"New","status":"pending","userId":2}`))
+	serve(h, httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodPut, "/api/users/1/preferences", strings.NewReader(`{"digestOptOut":true}`))
+	serve(h, httptest.NewRecorder(), req)
+
+	req = adminRequest(http.MethodPost, "/api/admin/digest?dryRun=true", nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result model.DigestRunResponse
+	json.NewDecoder(rr.Body).Decode(&result)
+	if result.Sent != 0 || result.OptedOut != 1 || len(result.Digests) != 1 {
+		t.Fatalf("expected one digest and one opted-out user without sending, got %+v", result)
+	}
+	if d := result.Digests[0]; d.UserID != 2 || len(d.Assigned) != 1 || d.Assigned[0].Title != "New" {
+		t.Errorf("expected user 2's digest to list the new task, got %+v", d)
+	}
+
+	req = adminRequest(http.MethodPost, "/api/admin/digest?userId=999", nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown user, got %d", rr.Code)
+	}
+}
+
+func TestHandler_CapacityReport(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.CapacityWeights = report.CapacityWeights{Capacity: 1}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reports/capacity", nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var rep model.CapacityReport
+	json.NewDecoder(rr.Body).Decode(&rep)
+
+	// User 2's in-progress task outweighs user 1's pending one
+	if len(rep.Users) != 2 || rep.Users[0].UserID != 2 || !rep.Users[0].OverAllocated || rep.Users[1].OverAllocated {
+		t.Errorf("expected user 2 first and only they over-allocated, got %+v", rep.Users)
+	}
+	if rep.OverAllocated != 1 {
+		t.Errorf("expected 1 over-allocated user, got %d", rep.OverAllocated)
+	}
+}
+
+func TestHandler_UserStats(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	completed := "completed"
+	if must(h.store.UpdateTask(context.Background(), 1, model.TaskUpdate{Status: &completed})) == nil {
+		t.Fatal("failed to complete task 1")
+	}
+
+	rr := get("/api/stats/users?window=7d")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var rep model.WorkloadReport
+	json.NewDecoder(rr.Body).Decode(&rep)
+	if len(rep.Users) != 2 || rep.Window != "168h0m0s" {
+		t.Fatalf("expected both users over 7 days, got %+v", rep)
+	}
+	if john := rep.Users[0]; john.Tasks.Completed != 1 || john.Completed != 1 || john.CompletionRate != 1 {
+		t.Errorf("expected user 1 to have completed their task in the window, got %+v", john)
+	}
+	if jane := rep.Users[1]; jane.Tasks.InProgress != 1 || jane.CompletionRate != 0 {
+		t.Errorf("expected user 2 to have an open task, got %+v", jane)
+	}
+
+	if rr := get("/api/stats/users?window=2y"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid window, got %d", rr.Code)
+	}
+}
+
+func TestHandler_StatsHistory(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	ctx := context.Background()
+	base := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	for i, completed := range []int{0, 1, 1, 2, 4} {
+		h.store.RecordStatsSnapshot(ctx, model.StatsSnapshot{At: base.Add(time.Duration(i) * time.Hour), Tasks: model.TaskCounts{Total: 5, Completed: completed}})
+	}
+	recorded := must(h.recordStatsSnapshot(ctx))
+	if recorded.Tasks.Total != 2 || recorded.At.IsZero() {
+		t.Errorf("expected the current stats recorded, got %+v", recorded)
+	}
+
+	get := func(query string) (*httptest.ResponseRecorder, model.StatsHistoryResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/history?"+query, nil)
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		var resp model.StatsHistoryResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr, resp
+	}
+
+	rr, resp := get("from=2026-03-09T00:00:00Z&to=2026-03-09T05:00:00Z&interval=2h")
+	if rr.Code != http.StatusOK || resp.Interval != "2h0m0s" || resp.Count != 3 {
+		t.Fatalf("expected 3 intervals of 2h, got %d: %+v", rr.Code, resp)
+	}
+	for i, want := range []int{1, 2, 4} {
+		if got := resp.Snapshots[i].Tasks.Completed; got != want {
+			t.Errorf("expected the latest snapshot of interval %d to have %d completed, got %d", i, want, got)
+		}
+	}
+
+	if _, resp = get(""); resp.Count != 1 || resp.Snapshots[0].At != recorded.At {
+		t.Errorf("expected only the snapshot recorded this week by default, got %+v", resp)
+	}
+
+	for _, query := range []string{"from=yesterday", "interval=0d", "from=2026-03-09T05:00:00Z&to=2026-03-09T00:00:00Z", "interval=1m"} {
+		if rr, _ := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_CapacityReport_Materialized(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	get := func() model.CapacityReport {
+		req := httptest.NewRequest(http.MethodGet, "/api/reports/capacity", nil)
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		if rr.Header().Get("Last-Modified") == "" {
+			t.Error("expected a Last-Modified header")
+		}
+		var rep model.CapacityReport
+		json.NewDecoder(rr.Body).Decode(&rep)
+		return rep
+	}
+	openTasks := func(rep model.CapacityReport) int {
+		total := 0
+		for _, user := range rep.Users {
+			total += user.OpenTasks
+		}
+		return total
+	}
+
+	before := get()
+	h.store.CreateTask(context.Background(), model.Task{Title: "New", Status: "pending", UserID: 1})
+	if stale := get(); openTasks(stale) != openTasks(before) || stale.GeneratedAt != before.GeneratedAt {
+		t.Errorf("expected the stored report until a refresh, got %+v", stale)
+	}
+
+	req := adminRequest(http.MethodPost, "/api/admin/reports/refresh?report=capacity", nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	var refresh model.ReportRefreshResponse
+	json.NewDecoder(rr.Body).Decode(&refresh)
+	if rr.Code != http.StatusOK || len(refresh.Reports) != 1 || refresh.Reports[0].Name != "capacity" {
+		t.Fatalf("expected the capacity report refreshed, got %d: %+v", rr.Code, refresh)
+	}
+	if after := get(); openTasks(after) != openTasks(before)+1 {
+		t.Errorf("expected the refreshed report to count the new task, got %+v", after)
+	}
+
+	req = adminRequest(http.MethodPost, "/api/admin/reports/refresh?report=missing", nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown report, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Consistency(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.consistency = consistency.New(consistency.Options{RecheckDelay: time.Millisecond})
+	h.registerConsistencyChecks()
+	ctx := context.Background()
+	if err := h.store.Persist(ctx); err != nil {
+		t.Fatalf("persist failed: %v", err)
+	}
+	check := func() model.ConsistencyReport {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		serve(h, rr, adminRequest(http.MethodPost, "/api/admin/consistency", nil))
+		var report model.ConsistencyReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("expected a report, got %d: %s", rr.Code, rr.Body)
+		}
+		return report
+	}
+	health := func() string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var response model.DetailedHealthResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response.Checks["consistency"]
+	}
+
+	if got := health(); got != "pending" {
+		t.Errorf("expected the health check to show no run yet, got %q", got)
+	}
+
+	h.WarmCache()
+	if report := check(); report.Status != consistency.StatusOK || len(report.Checks) != 3 {
+		t.Fatalf("expected the cache, stats and persistence checks to pass, got %+v", report)
+	}
+	if got := health(); got != "ok" {
+		t.Errorf("expected the health check to pass, got %q", got)
+	}
+
+	// An entry left behind by a change, as a lost invalidation would leave it
+	stale := must(h.usersResponse(ctx))
+	h.store.CreateUser(ctx, model.User{Name: "Ann", Email: "ann@example.com", Role: "developer"})
+	h.cache.Set(cache.UsersKey(), normalizeJSON(stale))
+
+	report := check()
+	if report.Status != consistency.StatusInconsistent || report.Runs != 2 {
+		t.Fatalf("expected an inconsistency, got %+v", report)
+	}
+	for _, result := range report.Checks {
+		// The users list and stats; the task lists are unaffected
+		wantCount := 0
+		if result.Name == cacheCheck {
+			wantCount = 2
+		}
+		if result.Count != wantCount {
+			t.Errorf("expected %d discrepancies in %s, got %+v", wantCount, result.Name, result)
+		}
+	}
+	if got := health(); got != "warning: inconsistent: cache" {
+		t.Errorf("expected the health check to name the cache, got %q", got)
+	}
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, adminRequest(http.MethodGet, "/api/admin/consistency", nil))
+	if !strings.Contains(rr.Body.String(), `cache entry \"users\" is stale`) {
+		t.Errorf("expected GET to return the latest report, got %s", rr.Body)
+	}
+}
+
+func TestHandler_Diagnose(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	ctx := context.Background()
+	diagnose := func() model.Diagnosis {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		serve(h, rr, adminRequest(http.MethodGet, "/api/admin/diagnose", nil))
+		var diagnosis model.Diagnosis
+		if err := json.NewDecoder(rr.Body).Decode(&diagnosis); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("expected a diagnosis, got %d: %s", rr.Code, rr.Body)
+		}
+		return diagnosis
+	}
+	if err := h.store.Persist(ctx); err != nil {
+		t.Fatalf("persist failed: %v", err)
+	}
+
+	got := diagnose()
+	if len(got.Checks) != 6 {
+		t.Fatalf("expected every check run, got %+v", got)
+	}
+	// Free disk space depends on where the test runs
+	for _, finding := range got.Findings {
+		if finding.Severity != "info" && finding.Check != "disk" {
+			t.Errorf("expected nothing to need attention, got %+v", finding)
+		}
+	}
+
+	// A task left assigned to a missing user, and a reset key outside a sandbox
+	h.store.Restore(ctx, store.NewSnapshot(must(h.store.GetUsers(ctx)), []model.Task{{ID: 1, Title: "Orphan", Status: "pending", Priority: "medium", UserID: 99}}))
+	h.config.ResetKey = "qa-key"
+
+	got = diagnose()
+	if got.Status != "critical" || got.Count != len(got.Findings) {
+		t.Fatalf("expected a critical diagnosis, got %+v", got)
+	}
+	if first := got.Findings[0]; first.Check != "config" || first.Severity != "critical" || first.Action == "" {
+		t.Errorf("expected the reset key listed first with an action, got %+v", first)
+	}
+	found := false
+	for _, finding := range got.Findings {
+		found = found || finding.Check == "references" && strings.Contains(finding.Message, "unknown user 99")
+	}
+	if !found {
+		t.Errorf("expected the dangling reference found, got %+v", got.Findings)
+	}
+}
+
+func TestHandler_CustomReport(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	spec := `{"entities":"tasks","filters":[{"field":"status","op":"ne","value":"completed"}],"groupBy":["user.name"],"metrics":[{"op":"count"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(spec))
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result model.ReportResult
+	json.NewDecoder(rr.Body).Decode(&result)
+	if result.Count != 2 || result.Rows[0][0] != "Jane Smith" || result.Rows[0][1] != float64(1) {
+		t.Errorf("expected a row per user sorted by name, got %+v", result)
+	}
+
+	spec = `{"entities":"tasks","groupBy":["status"],"format":"csv"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(spec))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if got, want := rr.Body.String(), "status,count\nin-progress,1\npending,1\n"; got != want {
+		t.Errorf("expected CSV %q, got %q", want, got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(`{"entities":"tasks","groupBy":["secret"]}`))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_REPORT") {
+		t.Errorf("expected 400 INVALID_REPORT, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_CustomReport_CostLimits(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.ReportLimits = report.Limits{MaxGroups: 1}
+
+	spec := `{"entities":"tasks","groupBy":["id"]}`
+	run := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/reports"+query, strings.NewReader(spec))
+		rr := httptest.NewRecorder()
+		serve(h, rr, req)
+		return rr
+	}
+
+	rr := run("")
+	var errResp model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&errResp)
+	if rr.Code != http.StatusUnprocessableEntity || errResp.Code != "REPORT_TOO_EXPENSIVE" {
+		t.Errorf("expected 422 REPORT_TOO_EXPENSIVE, got %d %s", rr.Code, errResp.Code)
+	}
+
+	rr = run("?estimate=true")
+	var cost model.ReportCostResponse
+	json.NewDecoder(rr.Body).Decode(&cost)
+	if rr.Code != http.StatusOK || cost.Rows != 2 || cost.Groups != 2 || cost.MaxGroups != 1 || cost.WithinLimits {
+		t.Errorf("expected an estimate of 2 rows and groups over the limit, got %d %+v", rr.Code, cost)
+	}
+
+	if rr = run("?force=true"); rr.Code != http.StatusOK {
+		t.Errorf("expected a forced report to run, got %d", rr.Code)
+	}
+}
+
+func TestHandler_TaskShares(t *testing.T) {
+	t.Parallel()
+
+	// With authentication enabled, as shared links are opened without a key
+	h := newTestHandler(t)
+	h.config.Server = ServerConfig{APIKeys: []string{"key-1"}, AdminKeys: []string{testAdminKey}}
+	handler := h.chain(h.config.Server.withDefaults()).Then(routes(h))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if !strings.HasPrefix(target, "/share/") {
+			req.Header.Set("X-API-Key", "key-1")
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/api/tasks/1/share", "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var created model.ShareResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Token == "" || created.URL != "/share/"+created.Token || created.TaskID != 1 {
+		t.Fatalf("unexpected share %+v", created)
+	}
+	if days := created.ExpiresAt.Sub(created.CreatedAt).Hours() / 24; days < 6.9 || days > 7.1 {
+		t.Errorf("expected the share to expire in 7 days, got %.1f", days)
+	}
+
+	rr = serve(http.MethodGet, created.URL, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the shared view, got %d: %s", rr.Code, rr.Body)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `"title":"Test task 1"`) || strings.Contains(body, "userId") {
+		t.Errorf("expected the shared view without the assignee, got %s", body)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("expected the shared view not to be cached, got %q", got)
+	}
+
+	rr = serve(http.MethodGet, "/api/tasks/1/share", "")
+	if strings.Contains(rr.Body.String(), created.Token) || !strings.Contains(rr.Body.String(), created.ID) {
+		t.Errorf("expected shares to be listed without their tokens, got %s", rr.Body)
+	}
+
+	if rr = serve(http.MethodDelete, "/api/tasks/2/share/"+created.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 revoking through another task, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodDelete, "/api/tasks/1/share/"+created.ID, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204 revoking the share, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodGet, created.URL, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a revoked share, got %d", rr.Code)
+	}
+
+	rr = serve(http.MethodPost, "/api/tasks/1/share", `{"expiresInDays": 91}`)
+	var errResponse model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&errResponse)
+	if rr.Code != http.StatusBadRequest || errResponse.Code != "INVALID_EXPIRES_IN_DAYS" {
+		t.Errorf("expected 400 INVALID_EXPIRES_IN_DAYS, got %d %+v", rr.Code, errResponse)
+	}
+	if rr = serve(http.MethodPost, "/api/tasks/999/share", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 sharing an unknown task, got %d", rr.Code)
+	}
+
+	token, tokenHash := newShareToken()
+	h.store.CreateShare(context.Background(), model.Share{ID: "old", TaskID: 1, TokenHash: tokenHash, ExpiresAt: time.Now().Add(-time.Minute)})
+	if rr = serve(http.MethodGet, "/share/"+token, ""); rr.Code != http.StatusGone {
+		t.Errorf("expected 410 for an expired share, got %d", rr.Code)
+	}
+}
+
+func TestHandler_TaskDependencies(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if strings.HasPrefix(target, "/api/tasks?") {
+			serve(h, rr, req)
+		} else {
+			serve(h, rr, req)
+		}
+		return rr
+	}
+	code := func(rr *httptest.ResponseRecorder) string {
+		var errResponse model.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&errResponse)
+		return errResponse.Code
+	}
+
+	// Task 2 blocks task 1
+	rr := serve(http.MethodPost, "/api/tasks/1/dependencies", `{"blockedBy": 2}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var task model.Task
+	json.NewDecoder(rr.Body).Decode(&task)
+	if len(task.BlockedBy) != 1 || task.BlockedBy[0] != 2 {
+		t.Errorf("expected task 1 to be blocked by task 2, got %v", task.BlockedBy)
+	}
+
+	rr = serve(http.MethodGet, "/api/tasks/2/dependencies", "")
+	var deps model.DependenciesResponse
+	json.NewDecoder(rr.Body).Decode(&deps)
+	if len(deps.BlockedBy) != 0 || len(deps.Blocks) != 1 || deps.Blocks[0].ID != 1 {
+		t.Errorf("expected task 2 to block only task 1, got %+v", deps)
+	}
+
+	if rr = serve(http.MethodPost, "/api/tasks/2/dependencies", `{"blockedBy": 1}`); rr.Code != http.StatusConflict || code(rr) != "DEPENDENCY_CYCLE" {
+		t.Errorf("expected 409 DEPENDENCY_CYCLE, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodPost, "/api/tasks/1/dependencies", `{"blockedBy": 999}`); rr.Code != http.StatusBadRequest || code(rr) != "INVALID_BLOCKED_BY" {
+		t.Errorf("expected 400 INVALID_BLOCKED_BY for an unknown blocker, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodPost, "/api/tasks/1/dependencies", `{}`); rr.Code != http.StatusBadRequest || code(rr) != "INVALID_BLOCKED_BY" {
+		t.Errorf("expected 400 INVALID_BLOCKED_BY for a missing blocker, got %d", rr.Code)
+	}
+
+	listed := func(blocked string) []model.Task {
+		t.Helper()
+		rr := serve(http.MethodGet, "/api/tasks?blocked="+blocked, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200 listing blocked=%s, got %d: %s", blocked, rr.Code, rr.Body)
+		}
+		var response model.TasksResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response.Tasks
+	}
+	if got := listed("true"); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("expected only task 1 to be blocked, got %+v", got)
+	}
+	if got := listed("false"); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("expected only task 2 to be unblocked, got %+v", got)
+	}
+	if rr = serve(http.MethodGet, "/api/tasks?blocked=maybe", ""); rr.Code != http.StatusBadRequest || code(rr) != "INVALID_BLOCKED" {
+		t.Errorf("expected 400 INVALID_BLOCKED, got %d", rr.Code)
+	}
+
+	if rr = serve(http.MethodPut, "/api/tasks/1", `{"status": "completed"}`); rr.Code != http.StatusConflict || code(rr) != "TASK_BLOCKED" {
+		t.Errorf("expected 409 TASK_BLOCKED completing a blocked task, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodPut, "/api/tasks/2", `{"status": "completed"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 completing the blocker, got %d: %s", rr.Code, rr.Body)
+	}
+	// A completed blocker no longer blocks, and the cached list must say so
+	if got := listed("true"); len(got) != 0 {
+		t.Errorf("expected no blocked tasks once the blocker is completed, got %+v", got)
+	}
+	if rr = serve(http.MethodPut, "/api/tasks/1", `{"status": "completed"}`); rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 completing the unblocked task, got %d: %s", rr.Code, rr.Body)
+	}
+
+	if rr = serve(http.MethodDelete, "/api/tasks/1/dependencies/2", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204 removing the dependency, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodDelete, "/api/tasks/1/dependencies/2", ""); rr.Code != http.StatusNotFound || code(rr) != "DEPENDENCY_NOT_FOUND" {
+		t.Errorf("expected 404 DEPENDENCY_NOT_FOUND removing it again, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Announcements(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	// Announcements are changed by an admin and read by anyone
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if method != http.MethodGet {
+			req.Header.Set("X-API-Key", testAdminKey)
+		}
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	active := func() []model.Announcement {
+		t.Helper()
+		rr := serve(http.MethodGet, "/api/announcements/active", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+		}
+		var response model.AnnouncementsResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response.Announcements
+	}
+
+	rr := serve(http.MethodPost, "/api/announcements", `{"message": "Maintenance tonight", "level": "warning"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var created model.Announcement
+	json.NewDecoder(rr.Body).Decode(&created)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	serve(http.MethodPost, "/api/announcements", `{"message": "Not yet", "startsAt": "`+future+`"}`)
+
+	if got := active(); len(got) != 1 || got[0].ID != created.ID {
+		t.Errorf("expected only the started announcement to be active, got %+v", got)
+	}
+	rr = serve(http.MethodGet, "/api/announcements", "")
+	var all model.AnnouncementsResponse
+	json.NewDecoder(rr.Body).Decode(&all)
+	if all.Count != 2 {
+		t.Errorf("expected the admin list to include scheduled announcements, got %+v", all)
+	}
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if rr = serve(http.MethodPut, fmt.Sprintf("/api/announcements/%d", created.ID), `{"endsAt": "`+past+`"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 ending the announcement, got %d: %s", rr.Code, rr.Body)
+	}
+	if got := active(); len(got) != 0 {
+		t.Errorf("expected no active announcements once it ended, got %+v", got)
+	}
+
+	for _, tt := range []struct {
+		body string
+		code string
+	}{
+		{`{"message": ""}`, "INVALID_MESSAGE"},
+		{`{"message": "Hi", "level": "loud"}`, "INVALID_LEVEL"},
+		{`{"message": "Hi", "startsAt": "tomorrow"}`, "INVALID_STARTS_AT"},
+		{`{"message": "Hi", "startsAt": "` + future + `", "endsAt": "` + past + `"}`, "INVALID_ENDS_AT"},
+	} {
+		rr = serve(http.MethodPost, "/api/announcements", tt.body)
+		var errResponse model.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&errResponse)
+		if rr.Code != http.StatusBadRequest || errResponse.Code != tt.code {
+			t.Errorf("%s: expected 400 %s, got %d %+v", tt.body, tt.code, rr.Code, errResponse)
+		}
+	}
+
+	if rr = serve(http.MethodDelete, fmt.Sprintf("/api/announcements/%d", created.ID), ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204 deleting the announcement, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodGet, fmt.Sprintf("/api/announcements/%d", created.ID), ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted announcement, got %d", rr.Code)
+	}
+}
+
+func TestHandler_AnnouncementsAdminOnly(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.Server.APIKeys = []string{"key-1"}
+	created := must(h.store.CreateAnnouncement(context.Background(), model.Announcement{Message: "Maintenance tonight", Level: "warning"}))
+	handler := h.chain(h.config.Server.withDefaults()).Then(routes(h))
+
+	target := fmt.Sprintf("/api/announcements/%d", created.ID)
+	for _, tt := range []struct {
+		method, target, body string
+	}{
+		{http.MethodPost, "/api/announcements", `{"message": "Hijacked"}`},
+		{http.MethodPut, target, `{"message": "Hijacked"}`},
+		{http.MethodDelete, target, ""},
+	} {
+		for key, want := range map[string]int{"key-1": http.StatusForbidden, "": http.StatusUnauthorized} {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if key != "" {
+				req.Header.Set("X-API-Key", key)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != want {
+				t.Errorf("%s %s with key %q: expected status %d, got %d: %s", tt.method, tt.target, key, want, rr.Code, rr.Body)
+			}
+		}
+	}
+	if got := h.store.GetAnnouncements(context.Background()); len(got) != 1 || got[0].Message != "Maintenance tonight" {
+		t.Errorf("expected the announcement unchanged, got %+v", got)
+	}
+
+	// Reading needs an ordinary key, or none for the active ones
+	for target, key := range map[string]string{"/api/announcements": "key-1", target: "key-1", "/api/announcements/active": ""} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("GET %s: expected status 200, got %d: %s", target, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestHandler_Me(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.Server.APIKeys = []string{"key-1"}
+	started := must(h.store.CreateAnnouncement(context.Background(), model.Announcement{Message: "Maintenance tonight", Level: "warning"}))
+	future := time.Now().Add(time.Hour)
+	must(h.store.CreateAnnouncement(context.Background(), model.Announcement{Message: "Not yet", Level: "info", StartsAt: &future}))
+	handler := h.chain(h.config.Server.withDefaults()).Then(routes(h))
+
+	me := func(key string) (int, model.MeResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		var response model.MeResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr.Code, response
+	}
+
+	code, response := me("key-1")
+	if code != http.StatusOK || response.Client != "key:key-****" || response.Admin {
+		t.Errorf("expected key-1 described as an ordinary client, got %d %+v", code, response)
+	}
+	if len(response.Announcements) != 1 || response.Announcements[0].ID != started.ID {
+		t.Errorf("expected only the started announcement, got %+v", response.Announcements)
+	}
+	if _, response = me(testAdminKey); !response.Admin {
+		t.Errorf("expected the admin key described as an admin, got %+v", response)
+	}
+	if code, _ = me(""); code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a key, got %d", code)
+	}
+}
+
+func TestHandler_AuditTrail(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "manager-key")
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodPut, "/api/tasks/1", `{"userId": 2}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 reassigning the task, got %d: %s", rr.Code, rr.Body)
+	}
+	// An update that changes nothing is not recorded
+	serve(http.MethodPut, "/api/tasks/1", `{"userId": 2}`)
+	serve(http.MethodPost, "/api/users", `{"name": "Ann", "email": "ann@example.com", "role": "developer", "password": "secret-password"}`)
+
+	rr := serve(http.MethodGet, "/api/tasks/1/history", "")
+	var history model.AuditResponse
+	json.NewDecoder(rr.Body).Decode(&history)
+	if rr.Code != http.StatusOK || history.Count != 1 {
+		t.Fatalf("expected one recorded change to the task, got %d %+v", rr.Code, history)
+	}
+	event := history.Events[0]
+	if event.Actor != "key:mana****" || event.Action != "update" {
+		t.Errorf("expected an update by the masked API key, got %+v", event)
+	}
+	var reassigned bool
+	for _, change := range event.Changes {
+		if change.Field == "userId" && change.Old == 1.0 && change.New == 2.0 {
+			reassigned = true
+		}
+	}
+	if !reassigned {
+		t.Errorf("expected userId to change from 1 to 2, got %+v", event.Changes)
+	}
+
+	rr = serve(http.MethodGet, "/api/audit?entity=user&action=create", "")
+	var audit model.AuditResponse
+	json.NewDecoder(rr.Body).Decode(&audit)
+	if audit.Count != 1 || strings.Contains(rr.Body.String(), "secret-password") || strings.Contains(rr.Body.String(), "$2") {
+		t.Errorf("expected the user's creation without password or hash, got %s", rr.Body)
+	}
+
+	if rr = serve(http.MethodGet, "/api/audit?since=yesterday&limit=0", ""); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_LIMIT") {
+		t.Errorf("expected 400 listing both invalid parameters, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serve(http.MethodGet, "/api/tasks/999/history", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown task, got %d", rr.Code)
+	}
+}
+
+func TestHandler_ChangesReachSideEffects(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	var changes []events.Change
+	h.bus.Subscribe("test", func(ctx context.Context, c events.Change) {
+		changes = append(changes, c)
+	})
+	sub, _, _ := h.events.Subscribe(0)
+	defer sub.Close()
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "manager-key")
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	serve(http.MethodGet, "/api/tasks", "")
+	if _, found := h.cache.Get(cache.TasksKey("", "", "", "", "", "", "")); !found {
+		t.Fatal("expected the task list cached")
+	}
+	if rr := serve(http.MethodPut, "/api/tasks/1", `{"status": "completed"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("expected one change, got %+v", changes)
+	}
+	c := changes[0]
+	before, _ := c.Before.(model.Task)
+	after, _ := c.After.(model.Task)
+	if c.Entity != events.EntityTask || c.Action != events.ActionUpdate || c.ID != "1" || c.Actor != "key:mana****" ||
+		before.Status == "completed" || after.Status != "completed" {
+		t.Errorf("expected task 1 completed by the masked API key, got %+v", c)
+	}
+	if _, found := h.cache.Get(cache.TasksKey("", "", "", "", "", "", "")); found {
+		t.Error("expected the task list invalidated")
+	}
+	if trail := h.store.GetAuditEvents(context.Background(), model.AuditFilter{Entity: "task", EntityID: "1"}); len(trail) != 1 {
+		t.Errorf("expected the update audited, got %+v", trail)
+	}
+	select {
+	case event := <-sub.Events():
+		if event.Type != events.TaskUpdated {
+			t.Errorf("expected task.updated published, got %+v", event)
+		}
+	default:
+		t.Error("expected task.updated published")
+	}
+
+	// Changes that fail are not emitted
+	serve(http.MethodDelete, "/api/projects/999", "")
+	if len(changes) != 1 {
+		t.Errorf("expected no change for a failed delete, got %+v", changes[1:])
+	}
+}
+
+func TestHandler_TaskChanges(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "manager-key")
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var list model.TasksResponse
+	json.NewDecoder(serve(http.MethodGet, "/api/tasks", "").Body).Decode(&list)
+	serve(http.MethodPut, "/api/tasks/1", `{"status": "completed"}`)
+
+	rr := serve(http.MethodGet, "/api/tasks?since="+strconv.FormatInt(list.Revision, 10), "")
+	var changes model.TaskChanges
+	json.NewDecoder(rr.Body).Decode(&changes)
+	if rr.Code != http.StatusOK || len(changes.Updated) != 1 || changes.Updated[0] != 1 || changes.Revision <= list.Revision {
+		t.Fatalf("expected task 1 updated since revision %d, got %d: %+v", list.Revision, rr.Code, changes)
+	}
+	if !strings.Contains(serve(http.MethodGet, "/api/tasks?since="+strconv.FormatInt(changes.Revision, 10), "").Body.String(), `"updated":[]`) {
+		t.Errorf("expected no changes since the latest revision")
+	}
+
+	if rr = serve(http.MethodGet, "/api/tasks?since=999999", ""); rr.Code != http.StatusGone {
+		t.Errorf("expected 410 for an unknown revision, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serve(http.MethodGet, "/api/tasks?since=abc", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid revision, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Events(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.EventHeartbeat = 10 * time.Millisecond
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Cleanup(h.events.Close)
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/api/events?types=task.deleted", nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_EVENT_TYPE") {
+		t.Errorf("expected 400 INVALID_EVENT_TYPE, got %d: %s", rr.Code, rr.Body)
+	}
+
+	// stream connects and returns a reader of the stream's events, which
+	// skips heartbeats, noting them in heartbeat
+	heartbeat := false
+	stream := func(after string) (func() (id, typ, data string), func()) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/events?types=task.created,task.updated", nil)
+		if after != "" {
+			req.Header.Set("Last-Event-ID", after)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		lines := bufio.NewScanner(resp.Body)
+		next := func() (id, typ, data string) {
+			t.Helper()
+			for lines.Scan() {
+				line := lines.Text()
+				switch {
+				case line == ": heartbeat":
+					heartbeat = true
+				case strings.HasPrefix(line, "id: "):
+					id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					typ = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					data = strings.TrimPrefix(line, "data: ")
+				case line == "" && typ != "":
+					return id, typ, data
+				}
+			}
+			t.Fatalf("stream ended: %v", lines.Err())
+			return
+		}
+		return next, func() { resp.Body.Close() }
+	}
+
+	next, disconnect := stream("")
+	serve := func(method, target, body string) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "manager-key")
+		mux.ServeHTTP(rr, req)
+	}
+	// Let the stream idle long enough for a heartbeat
+	time.Sleep(50 * time.Millisecond)
+	serve(http.MethodPost, "/api/users", `{"name": "Ann", "email": "ann@example.com", "role": "developer"}`)
+	serve(http.MethodPost, "/api/tasks", `{"title": "Live", "status": "pending", "userId": 1}`)
+
+	id, typ, data := next()
+	var event events.Event
+	json.Unmarshal([]byte(data), &event)
+	if typ != events.TaskCreated || strconv.FormatUint(event.ID, 10) != id || !strings.Contains(data, `"title":"Live"`) {
+		t.Fatalf("expected the task.created event, got %s %s: %s", id, typ, data)
+	}
+	if !heartbeat {
+		t.Error("expected a heartbeat while the stream was idle")
+	}
+	disconnect()
+
+	// Reconnecting resumes after the last event seen
+	serve(http.MethodPut, "/api/tasks/1", `{"status": "completed"}`)
+	next, disconnect = stream(id)
+	defer disconnect()
+	if _, typ, data = next(); typ != events.TaskUpdated || !strings.Contains(data, `"status":"completed"`) {
+		t.Errorf("expected the missed task.updated event, got %s: %s", typ, data)
+	}
+
+	// A restore tells clients to reload
+	h.restore(context.Background(), "test", must(h.store.Snapshot(context.Background())))
+	if _, typ, _ = next(); typ != events.Reset {
+		t.Errorf("expected a reset after the restore, got %s", typ)
+	}
+}
+
+func TestHandler_Socket(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	// Through middleware that buffers responses, which must still let the
+	// connection be taken over
+	srv := httptest.NewServer(middleware.FieldNaming(h.namingConfig())(mux))
+	defer srv.Close()
+
+	rr := httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if rr.Code != http.StatusUpgradeRequired || !strings.Contains(rr.Body.String(), "UPGRADE_REQUIRED") {
+		t.Errorf("expected 426 UPGRADE_REQUIRED, got %d: %s", rr.Code, rr.Body)
+	}
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "manager-key")
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	// createTask creates a pending task tagged "live" and returns its ID
+	createTask := func(title string) int {
+		var task model.Task
+		json.NewDecoder(serve(http.MethodPost, "/api/tasks", fmt.Sprintf(`{"title": %q, "status": "pending", "tags": ["live"], "userId": 1}`, title)).Body).Decode(&task)
+		return task.ID
+	}
+	openID := createTask("Open")
+
+	// Messages keep their camelCase names whatever the field naming
+	c := websockettest.Dial(t, srv.URL+"/ws", http.Header{"X-Field-Naming": {middleware.NamingSnakeCase}})
+	c.WriteJSON(model.SocketRequest{Type: "subscribe", ID: "pending", Filter: &model.TaskFilter{Status: "pending", UserID: 1, Tags: []string{"live"}}})
+	var snapshot model.SocketSnapshot
+	if c.ReadJSON(&snapshot); snapshot.Type != "snapshot" || snapshot.ID != "pending" || snapshot.Count != 1 || snapshot.Tasks[0].Title != "Open" {
+		t.Fatalf("expected a snapshot of the pending task, got %+v", snapshot)
+	}
+
+	c.WriteJSON(model.SocketRequest{Type: "subscribe", ID: "critical", Filter: &model.TaskFilter{Priority: "critical"}})
+	var notice model.SocketNotice
+	if c.ReadJSON(&notice); notice.Type != "error" || notice.ID != "critical" || notice.Code != "INVALID_PRIORITY" {
+		t.Errorf("expected INVALID_PRIORITY for the subscription, got %+v", notice)
+	}
+
+	// Tasks that start matching are upserted, and those that stop removed
+	newID := createTask("New")
+	var change model.SocketChange
+	if c.ReadJSON(&change); change.Type != "upsert" || change.TaskID != newID || change.Task == nil || change.Task.Title != "New" {
+		t.Errorf("expected the new task upserted, got %+v", change)
+	}
+	serve(http.MethodPut, fmt.Sprintf("/api/tasks/%d", openID), `{"status": "completed"}`)
+	change = model.SocketChange{}
+	if c.ReadJSON(&change); change.Type != "remove" || change.TaskID != openID || change.Task != nil {
+		t.Errorf("expected the completed task removed, got %+v", change)
+	}
+	// Changes to tasks that did not match are not sent, so the pong is next
+	serve(http.MethodPut, fmt.Sprintf("/api/tasks/%d", openID), `{"title": "Done"}`)
+	c.WriteJSON(model.SocketRequest{Type: "ping"})
+	if c.ReadJSON(&notice); notice.Type != "pong" {
+		t.Errorf("expected only a pong, got %+v", notice)
+	}
+
+	// A restore sends new snapshots
+	h.restore(context.Background(), "test", must(h.store.Snapshot(context.Background())))
+	snapshot = model.SocketSnapshot{}
+	if c.ReadJSON(&snapshot); snapshot.Type != "snapshot" || snapshot.Count != 1 || snapshot.Tasks[0].Title != "New" {
+		t.Errorf("expected a new snapshot after the restore, got %+v", snapshot)
+	}
+
+	c.WriteJSON(model.SocketRequest{Type: "unsubscribe", ID: "pending"})
+	if c.ReadJSON(&notice); notice.Type != "unsubscribed" || notice.ID != "pending" {
+		t.Errorf("expected the subscription ended, got %+v", notice)
+	}
+	c.WriteJSON(model.SocketRequest{Type: "unsubscribe", ID: "pending"})
+	if c.ReadJSON(&notice); notice.Code != "NOT_SUBSCRIBED" {
+		t.Errorf("expected NOT_SUBSCRIBED, got %+v", notice)
+	}
+
+	// Clients sending too many messages are told to slow down
+	limited := false
+	for i := 0; i < socketMessageLimit && !limited; i++ {
+		c.WriteJSON(model.SocketRequest{Type: "ping"})
+		notice = model.SocketNotice{}
+		c.ReadJSON(&notice)
+		limited = notice.Code == "RATE_LIMITED"
+	}
+	if !limited {
+		t.Errorf("expected RATE_LIMITED after %d messages", socketMessageLimit)
+	}
+
+	// Shutting down closes the connection as going away
+	h.events.Close()
+	if code := c.ReadClose(); code != websocket.CloseGoingAway {
+		t.Errorf("expected close %d on shutdown, got %d", websocket.CloseGoingAway, code)
+	}
+}
+
+func TestHandler_SocketOrigin(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.SetCORSOrigins([]string{"https://app.example.com"})
+	srv := httptest.NewServer(routes(h))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		origin string
+		want   int
+	}{
+		{"https://evil.example.com", http.StatusForbidden},
+		{"https://app.example.com", http.StatusSwitchingProtocols},
+		{"", http.StatusSwitchingProtocols},
+	} {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, resp, err := websockettest.Handshake(srv.URL+"/ws", header)
+		if err != nil {
+			t.Fatalf("handshake from %q failed: %v", tt.origin, err)
+		}
+		conn.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("expected status %d from origin %q, got %d", tt.want, tt.origin, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_Webhooks(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan *http.Request, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer hook.Close()
+
+	h := newTestHandler(t)
+	// The test server is on loopback, which the default policy refuses
+	hookURL, _ := url.Parse(hook.URL)
+	port, _ := strconv.Atoi(hookURL.Port())
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	h.webhooks = webhook.New(h.store, webhook.Options{Client: fetch.New(fetch.Policy{Ports: []int{port}, AllowNets: []*net.IPNet{loopback}})})
+	t.Cleanup(h.webhooks.Close)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", testAdminKey)
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/api/admin/webhooks", `{"url": "ftp://example.com", "events": ["task.deleted"], "secret": "short"}`)
+	for _, code := range []string{"INVALID_URL", "INVALID_EVENTS", "INVALID_SECRET"} {
+		if !strings.Contains(rr.Body.String(), code) {
+			t.Errorf("expected %s in the validation errors, got %d: %s", code, rr.Code, rr.Body)
+		}
+	}
+
+	rr = serve(http.MethodPost, "/api/admin/webhooks", fmt.Sprintf(`{"url": %q, "events": ["task.completed", "task.completed"]}`, hook.URL))
+	var created model.WebhookResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || len(created.Secret) != 64 || len(created.Events) != 1 {
+		t.Fatalf("expected a webhook with a generated secret, got %d: %+v", rr.Code, created)
+	}
+	if body := serve(http.MethodGet, "/api/admin/webhooks", "").Body.String(); !strings.Contains(body, `"count":1`) || strings.Contains(body, created.Secret) {
+		t.Errorf("expected the webhook listed without its secret, got %s", body)
+	}
+
+	// Only completing the task is delivered, as that is all it subscribed to
+	serve(http.MethodPut, "/api/tasks/1", `{"title": "Renamed"}`)
+	serve(http.MethodPut, "/api/tasks/1", `{"status": "completed"}`)
+	select {
+	case r := <-received:
+		if r.Header.Get(webhook.EventHeader) != webhook.TaskCompleted || !strings.HasPrefix(r.Header.Get(webhook.SignatureHeader), "sha256=") {
+			t.Errorf("expected a signed task.completed delivery, got headers %v", r.Header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the completion to be delivered")
+	}
+
+	path := "/api/admin/webhooks/" + strconv.Itoa(created.ID)
+	deadline := time.Now().Add(5 * time.Second)
+	var log model.WebhookDeliveriesResponse
+	for time.Now().Before(deadline) {
+		json.NewDecoder(serve(http.MethodGet, path+"/deliveries", "").Body).Decode(&log)
+		if log.Count == 1 && log.Deliveries[0].Status == webhook.StatusDelivered {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if log.Count != 1 || log.Deliveries[0].Status != webhook.StatusDelivered || log.Deliveries[0].Attempts != 1 {
+		t.Errorf("expected one delivered attempt in the log, got %+v", log)
+	}
+
+	if rr := serve(http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 deleting the webhook, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodGet, path+"/deliveries", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a deleted webhook's deliveries, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Projects(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+	code := func(rr *httptest.ResponseRecorder) string {
+		var errResponse model.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&errResponse)
+		return errResponse.Code
+	}
+
+	rr := serve(http.MethodPost, "/api/projects", `{"name": "Platform"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var project model.Project
+	json.NewDecoder(rr.Body).Decode(&project)
+	base := fmt.Sprintf("/api/projects/%d", project.ID)
+
+	rr = serve(http.MethodPost, "/api/tasks", fmt.Sprintf(`{"title": "Scoped", "status": "pending", "priority": "urgent", "userId": 1, "projectId": %d}`, project.ID))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 creating a task in the project, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serve(http.MethodPost, "/api/tasks", `{"title": "Lost", "status": "pending", "userId": 1, "projectId": 999}`); rr.Code != http.StatusBadRequest || code(rr) != "INVALID_PROJECT_ID" {
+		t.Errorf("expected 400 INVALID_PROJECT_ID for an unknown project, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodPut, "/api/tasks/2", fmt.Sprintf(`{"projectId": %d}`, project.ID)); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 moving a task into the project, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = serve(http.MethodGet, base+"/tasks?status=pending", "")
+	var tasks model.TasksResponse
+	json.NewDecoder(rr.Body).Decode(&tasks)
+	if tasks.Count != 1 || tasks.Tasks[0].Title != "Scoped" {
+		t.Errorf("expected only the pending task of the project, got %+v", tasks)
+	}
+
+	rr = serve(http.MethodGet, base+"/stats", "")
+	var stats model.ProjectStats
+	json.NewDecoder(rr.Body).Decode(&stats)
+	if stats.Tasks.Total != 2 || stats.Tasks.Pending != 1 || stats.Tasks.InProgress != 1 || stats.Priorities.Urgent != 1 {
+		t.Errorf("unexpected project stats %+v", stats)
+	}
+
+	if rr = serve(http.MethodDelete, base, ""); rr.Code != http.StatusConflict || code(rr) != "PROJECT_NOT_EMPTY" {
+		t.Errorf("expected 409 PROJECT_NOT_EMPTY deleting a project with tasks, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodGet, "/api/projects/999/tasks", ""); rr.Code != http.StatusNotFound || code(rr) != "PROJECT_NOT_FOUND" {
+		t.Errorf("expected 404 PROJECT_NOT_FOUND, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Attachments(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("meeting notes"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/1/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created model.Attachment
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.TaskID != 1 || created.Size != 13 || !strings.HasPrefix(created.ContentType, "text/plain") {
+		t.Errorf("expected a 13 byte text attachment of task 1, got %+v", created)
+	}
+	if created.ScanStatus != model.ScanUnscanned {
+		t.Errorf("expected the attachment unscanned without a scanner, got %q", created.ScanStatus)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/1/attachments", nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	var list model.AttachmentsResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.Count != 1 || list.Attachments[0].ID != created.ID {
+		t.Errorf("expected the attachment listed, got %+v", list)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/attachments/%d/download", created.ID), nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "meeting notes" {
+		t.Errorf("expected the file content, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename=notes.txt` {
+		t.Errorf("expected an attachment disposition, got %q", got)
+	}
+
+	sum := sha256.Sum256([]byte("meeting notes"))
+	hash := hex.EncodeToString(sum[:])
+	if created.SHA256 != hash {
+		t.Errorf("expected the content's hash, got %q", created.SHA256)
+	}
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodHead, "/api/attachments/"+hash, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Length") != "13" {
+		t.Errorf("expected status 200 for known content, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodHead, "/api/attachments/"+strings.Repeat("0", 64), nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown content, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks/2/attachments", strings.NewReader(`{"filename":"copy.txt","sha256":"`+hash+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	var linked model.Attachment
+	json.NewDecoder(rr.Body).Decode(&linked)
+	if rr.Code != http.StatusCreated || linked.TaskID != 2 || linked.Size != 13 || linked.SHA256 != hash {
+		t.Errorf("expected the known content attached to task 2, got %d %+v", rr.Code, linked)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks/2/attachments", strings.NewReader(`{"filename":"copy.txt","sha256":"`+strings.Repeat("0", 64)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown content, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks/999/attachments", strings.NewReader(""))
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown task, got %d", rr.Code)
+	}
+}
+
+func TestHandler_SignedDownloadURL(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.DownloadBaseURL = "https://cdn.example.com/"
+	a, err := h.attachments.Create(context.Background(), 1, "notes.txt", "text/plain", strings.NewReader("meeting notes"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/attachments/%d/url?expiresIn=1h", a.ID), nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+	var signed model.SignedURLResponse
+	json.NewDecoder(rr.Body).Decode(&signed)
+	if rr.Code != http.StatusOK || !strings.HasPrefix(signed.URL, "https://cdn.example.com/files/attachments/") {
+		t.Fatalf("expected a signed CDN URL, got %d %+v", rr.Code, signed)
+	}
+	if until := time.Until(signed.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("expected the URL to expire in an hour, got %v", signed.ExpiresAt)
+	}
+
+	path := strings.TrimPrefix(signed.URL, "https://cdn.example.com")
+	rr = httptest.NewRecorder()
+	serve(h, rr, httptest.NewRequest(http.MethodGet, path, nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "meeting notes" {
+		t.Errorf("expected the file content, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Cache-Control"); !strings.HasPrefix(got, "public, max-age=") {
+		t.Errorf("expected a public Cache-Control until expiry, got %q", got)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name string
+		path string
+		code string
+	}{
+		{"tampered ID", strings.Replace(path, fmt.Sprintf("/%d?", a.ID), "/2?", 1), "INVALID_SIGNATURE"},
+		{"missing signature", fmt.Sprintf("/files/attachments/%d", a.ID), "INVALID_SIGNATURE"},
+		{"expired", fmt.Sprintf("/files/attachments/%d?expires=%d&signature=%s", a.ID, expired.Unix(), h.signer.Sign(a.ID, expired)), "URL_EXPIRED"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		serve(h, rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		var response model.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		if rr.Code != http.StatusForbidden || response.Code != tt.code {
+			t.Errorf("%s: expected 403 %s, got %d %s", tt.name, tt.code, rr.Code, response.Code)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/attachments/%d/url?expiresIn=30d", a.ID), nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid expiry, got %d", rr.Code)
+	}
+}
+
+func TestHandler_AttachmentThumbnails(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 600, 300)))
+	a, err := h.attachments.Create(context.Background(), 1, "photo.png", "image/png", &img)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for got, _ := h.attachments.Get(a.ID); len(got.Thumbnails) == 0; got, _ = h.attachments.Get(a.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected thumbnails to be generated in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if ops := h.ops.List(); len(ops) != 1 || ops[0].Kind != "thumbnails" {
+		t.Errorf("expected a thumbnails operation, got %+v", ops)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/attachments/%d/download?size=thumb", a.ID), nil)
+	rr := httptest.NewRecorder()
+	serve(h, rr, req)
+	cfg, err := png.DecodeConfig(rr.Body)
+	if rr.Code != http.StatusOK || err != nil || cfg.Width != 128 || cfg.Height != 64 {
+		t.Errorf("expected a 128x64 thumbnail, got %d %dx%d, %v", rr.Code, cfg.Width, cfg.Height, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/attachments/%d/download?size=huge", a.ID), nil)
+	rr = httptest.NewRecorder()
+	serve(h, rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown size, got %d", rr.Code)
+	}
+}
+
+// nilCollections returns the paths of the nil slices and maps in v that
+// would encode as null.
+func nilCollections(v reflect.Value, path string) []string {
+	var found []string
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			found = append(found, nilCollections(v.Elem(), path)...)
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			return nil
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				found = append(found, nilCollections(v.Field(i), path+"."+v.Type().Field(i).Name)...)
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return []string{path}
+		}
+		for i := 0; i < v.Len(); i++ {
+			found = append(found, nilCollections(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return []string{path}
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			found = append(found, nilCollections(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()))...)
+		}
+	}
+	return found
+}
+
+func TestNormalizeJSON_AllResponseTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, response := range apiModels {
+		name := reflect.TypeOf(response).Name()
+		normalized := normalizeJSON(response)
+		if reflect.TypeOf(normalized) != reflect.TypeOf(response) {
+			t.Errorf("%s: expected the type to be kept, got %T", name, normalized)
+		}
+		if found := nilCollections(reflect.ValueOf(normalized), name); len(found) > 0 {
+			t.Errorf("%s: expected no nil slices or maps, got %v", name, found)
+		}
+	}
+
+	// Absent optional values stay absent
+	now := time.Now()
+	data, _ := json.Marshal(normalizeJSON(model.Task{CompletedAt: &now}))
+	if strings.Contains(string(data), `"dueAt"`) || !strings.Contains(string(data), `"completedAt"`) {
+		t.Errorf("expected nil pointers to be kept and set ones copied, got %s", data)
+	}
+}
+
+func TestAPIModels_FieldNaming(t *testing.T) {
+	t.Parallel()
+
+	for _, m := range apiModels {
+		if found := namingViolations(reflect.TypeOf(m)); len(found) > 0 {
+			t.Errorf("%T: expected camelCase JSON field names, got %v", m, found)
+		}
+	}
+
+	type drifted struct {
+		UserID    int `json:"user_id"`
+		CreatedAt int `json:"CreatedAt,omitempty"`
+		Embedded  struct {
+			DueAt int `json:"due-at"`
+		} `json:"embedded"`
+		Skipped int `json:"-"`
+	}
+	want := []string{"drifted.user_id", "drifted.CreatedAt", ".due-at"}
+	if got := namingViolations(reflect.TypeOf(drifted{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for name, want := range map[string]string{"id": "id", "userId": "user_id", "descriptionHtml": "description_html", "signedURL": "signed_url", "urlPath": "url_path"} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q): expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestHandler_LegacyFieldNaming(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	h.config.FieldNamingPins = map[string]string{"legacy-key": middleware.NamingSnakeCase}
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	handler := middleware.FieldNaming(h.namingConfig())(mux)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "legacy-key")
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/api/tasks", `{"title": "Legacy client", "status": "pending", "user_id": 2, "due_at": "2030-01-02T15:00:00Z"}`)
+	var created map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created["user_id"] != 2.0 || created["due_at"] == nil || created["created_at"] == nil || created["userId"] != nil {
+		t.Fatalf("expected the task created and returned with snake_case names, got %d: %v", rr.Code, created)
+	}
+
+	body := serve(http.MethodGet, "/api/tasks?user_id=2&status=pending", "").Body.String()
+	if !strings.Contains(body, `"user_id":2`) || !strings.Contains(body, `"count":1`) {
+		t.Errorf("expected the legacy query parameter to filter tasks, got %s", body)
+	}
+	// Statuses are data, not field names
+	if body := serve(http.MethodGet, "/api/statuses", "").Body.String(); !strings.Contains(body, `"in-progress"`) {
+		t.Errorf("expected status keys kept, got %s", body)
+	}
+}
+
+func TestHandler_ObfuscatedIDs(t *testing.T) {
+	t.Parallel()
+
+	codec := hashid.New("test-key")
+	h := newTestHandler(t)
+	h.config.IDCodec = codec
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	// Field naming sits inside, as in Start
+	handler := middleware.ObfuscateIDs(h.idConfig())(middleware.FieldNaming(h.namingConfig())(mux))
+	serve := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	user2, task1 := codec.Encode(2), codec.Encode(1)
+
+	rr := serve(http.MethodPost, "/api/tasks", fmt.Sprintf(`{"title": "Opaque", "status": "pending", "userId": %q}`, user2))
+	var created map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created["userId"] != user2 {
+		t.Fatalf("expected the task created with encoded IDs, got %d: %v", rr.Code, created)
+	}
+	newID, _ := created["id"].(string)
+	if id, ok := codec.Decode(newID); !ok || id != testIDStart {
+		t.Fatalf("expected the new task's ID encoded, got %v", created["id"])
+	}
+
+	rr = serve(http.MethodPost, "/api/tasks/"+newID+"/dependencies", fmt.Sprintf(`{"blockedBy": %q}`, task1))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), fmt.Sprintf(`"blockedBy":[%q]`, task1)) {
+		t.Errorf("expected the dependency added by encoded IDs, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serve(http.MethodGet, "/api/tasks/"+newID, ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"title":"Opaque"`) {
+		t.Errorf("expected the task by its encoded ID, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serve(http.MethodGet, "/api/tasks/1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected a plain ID not found, got %d", rr.Code)
+	}
+	if rr = serve(http.MethodGet, "/api/tasks?userId=2", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a plain ID in the query rejected, got %d", rr.Code)
+	}
+	body := serve(http.MethodGet, "/api/users/"+user2+"/tasks?status=pending", "").Body.String()
+	if !strings.Contains(body, `"count":1`) || !strings.Contains(body, fmt.Sprintf(`"userId":%q`, user2)) {
+		t.Errorf("expected user 2's task listed with encoded IDs, got %s", body)
+	}
+
+	// Legacy field names are encoded too
+	body = serve(http.MethodGet, "/api/tasks?user_id="+user2, "", "X-Field-Naming", middleware.NamingSnakeCase).Body.String()
+	if !strings.Contains(body, fmt.Sprintf(`"user_id":%q`, user2)) || strings.Contains(body, `"user_id":2`) {
+		t.Errorf("expected snake_case IDs encoded, got %s", body)
+	}
+}
+
+func TestHandler_IDFieldNames(t *testing.T) {
+	t.Parallel()
+
+	fields, lists := idFieldNames()
+	for _, name := range []string{"id", "userId", "taskId", "projectId", "user_id"} {
+		if !fields[name] {
+			t.Errorf("expected %q to be an ID field", name)
+		}
+	}
+	if !lists["blockedBy"] || !lists["created"] {
+		t.Errorf("expected blockedBy and created to be ID lists, got %v", lists)
+	}
+
+	// A number that is not an ID must not share a name with one, or it
+	// would be encoded too
+	seen := make(map[reflect.Type]bool)
+	for _, v := range apiModels {
+		jsonFields(reflect.TypeOf(v), seen, func(owner reflect.Type, name string, typ reflect.Type) {
+			for typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+			}
+			isInt := typ.Kind() == reflect.Int || typ.Kind() == reflect.Int64
+			isID := typ.Kind() == reflect.Int && (name == "id" || strings.HasSuffix(name, "Id")) || lists[name] && typ.Kind() == reflect.Int
+			if isInt && !isID && (fields[name] || lists[name]) {
+				t.Errorf("%s.%s is a number named like an ID field", owner.Name(), name)
+			}
+		})
+	}
+}
+
+func TestHandler_EmptyListsAreArrays(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	for _, target := range []string{
+		"/api/tasks?status=completed&userId=999",
+		"/api/tasks?status=completed&userId=999&view=compact",
+		"/api/tasks?overdue=true&userId=999",
+		"/api/projects",
+		"/api/announcements",
+	} {
+		// Twice, so the cached response is checked too
+		for i := 0; i < 2; i++ {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("X-API-Key", "manager-key")
+			mux.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "null") {
+				t.Errorf("GET %s: expected 200 with no nulls, got %d: %s", target, rr.Code, rr.Body)
+			}
+		}
+	}
 }
@@ -1,32 +1,45 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
+	"os"
 	"time"
 
+	"go-backend/internal/cache"
 	"go-backend/internal/model"
+	"go-backend/internal/store"
 )
 
-func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// readinessTimeout bounds the checks of /health/ready, so an unreachable
+// dependency fails the check rather than hanging it.
+const readinessTimeout = 2 * time.Second
 
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	checks := make(map[string]string)
 
 	// Check data store
-	users := h.store.GetUsers()
-	if users != nil {
+	if _, err := h.store.GetUsers(r.Context()); err == nil {
 		checks["datastore"] = "ok"
 	} else {
 		checks["datastore"] = "error"
 	}
 
-	// Check persistence
-	if err := h.store.Persist(); err != nil {
-		checks["persistence"] = "warning: " + err.Error()
+	// Check persistence, without writing anything: probes call this often
+	var persistErr error
+	var lastPersistedAt string
+	if h.dataDir() != "" {
+		persistErr = h.dataFileError()
+		if at := store.Unwrap(h.store).(store.FileBacked).LastPersisted(); !at.IsZero() {
+			lastPersistedAt = at.UTC().Format(time.RFC3339)
+		}
+	} else if pinger, ok := store.Unwrap(h.store).(store.Pinger); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		persistErr = pinger.Ping(ctx)
+		cancel()
+	}
+	if persistErr != nil {
+		checks["persistence"] = "warning: " + persistErr.Error()
 	} else {
 		checks["persistence"] = "ok"
 	}
@@ -39,26 +52,24 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 		checks["cache"] = "error"
 	}
 
+	checks["consistency"] = h.consistencyHealth()
+
 	response := model.DetailedHealthResponse{
-		Status:    "ok",
-		Message:   "Go backend is running",
-		Version:   h.config.Version,
-		Uptime:    time.Since(h.config.StartTime).String(),
-		Checks:    checks,
-		Timestamp: time.Now().Format(time.RFC3339),
+		Status:          "ok",
+		Message:         "Go backend is running",
+		Version:         h.config.Version,
+		Uptime:          time.Since(h.config.StartTime).String(),
+		Checks:          checks,
+		LastPersistedAt: lastPersistedAt,
+		Timestamp:       time.Now().Format(time.RFC3339),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, response)
 }
 
 func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	response := model.HealthResponse{
 		Status:  "ok",
 		Message: "Server is alive",
@@ -66,28 +77,72 @@ func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, response)
 }
 
+// handleReadiness reports whether the server can serve traffic: the data
+// file can be saved, the database and the Redis cache, if used, can be
+// reached, and the server is not shutting down. Otherwise it responds 503,
+// so load balancers send requests to other replicas.
 func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Check if the data store is accessible
-	users := h.store.GetUsers()
-	if users == nil {
-		h.writeError(w, http.StatusServiceUnavailable, "Data store not ready", "NOT_READY")
-		return
-	}
-
-	response := model.HealthResponse{
+	response := model.ReadinessResponse{
 		Status:  "ready",
 		Message: "Server is ready to serve traffic",
+		Checks:  h.readinessChecks(r.Context()),
+	}
+	status := http.StatusOK
+	for _, result := range response.Checks {
+		if result != "ok" {
+			response.Status = "not ready"
+			response.Message = "A dependency is unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if h.shuttingDown.Load() {
+		response.Status = "shutting down"
+		response.Message = "Server is shutting down"
+		status = http.StatusServiceUnavailable
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	json.NewEncoder(w).Encode(response)
+	w.WriteHeader(status)
+	encodeJSON(w, response)
+}
+
+// dataFileError returns the error of the latest write of the data file,
+// or an error if the data directory has gone since, e.g. with its volume
+// unmounted. Nothing is written. The store must save to files.
+func (h *Handler) dataFileError() error {
+	if err := store.Unwrap(h.store).(store.FileBacked).PersistError(); err != nil {
+		return err
+	}
+	_, err := os.Stat(h.dataDir())
+	return err
+}
+
+// readinessChecks checks the dependencies the server has, mapping each to
+// "ok" or what is wrong with it. The data file is not written to; the
+// result of the latest write is reported.
+func (h *Handler) readinessChecks(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	checks := make(map[string]string)
+	result := func(name string, err error) {
+		checks[name] = "ok"
+		if err != nil {
+			checks[name] = "error: " + err.Error()
+		}
+	}
+	if h.dataDir() != "" {
+		result("persistence", h.dataFileError())
+	}
+	if pinger, ok := store.Unwrap(h.store).(store.Pinger); ok {
+		result("database", pinger.Ping(ctx))
+	}
+	if pinger, ok := h.cache.(cache.Pinger); ok {
+		result("cache", pinger.Ping(ctx))
+	}
+	return checks
 }
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-backend/internal/model"
+	"go-backend/internal/report"
+)
+
+// DefaultStatsHistoryInterval is how often ScheduleStatsHistory records a
+// stats snapshot.
+const DefaultStatsHistoryInterval = time.Hour
+
+// Bounds of GET /api/stats/history.
+const (
+	defaultHistoryRange = 7 * 24 * time.Hour
+	maxHistoryIntervals = 1000
+)
+
+// statsHistoryInterval returns how often stats snapshots are recorded.
+func (h *Handler) statsHistoryInterval() time.Duration {
+	if h.config.StatsHistoryInterval > 0 {
+		return h.config.StatsHistoryInterval
+	}
+	return DefaultStatsHistoryInterval
+}
+
+// recordStatsSnapshot adds the current stats to the stats history.
+func (h *Handler) recordStatsSnapshot(ctx context.Context) (model.StatsSnapshot, error) {
+	stats := h.store.GetStats(ctx)
+	return h.store.RecordStatsSnapshot(ctx, model.StatsSnapshot{
+		At:         time.Now().UTC().Truncate(time.Second),
+		Users:      stats.Users.Total,
+		Tasks:      stats.Tasks,
+		Priorities: stats.Priorities,
+	})
+}
+
+// ScheduleStatsHistory records a stats snapshot now and then every
+// Config.StatsHistoryInterval until ctx is done.
+func (h *Handler) ScheduleStatsHistory(ctx context.Context) {
+	ticker := time.NewTicker(h.statsHistoryInterval())
+	defer ticker.Stop()
+
+	for {
+		if _, err := h.recordStatsSnapshot(ctx); err != nil {
+			log.Printf("Warning: Stats snapshot not recorded: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleStatsHistory serves /api/stats/history: the stats recorded from
+// ?from= up to ?to=, the past week unless given, with the latest snapshot
+// in each ?interval=, the recording interval unless given.
+func (h *Handler) handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var ferrs []*fieldError
+	from, ferr := parseAuditTime("from", "INVALID_FROM", query.Get("from"))
+	if ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	to, ferr := parseAuditTime("to", "INVALID_TO", query.Get("to"))
+	if ferr != nil {
+		ferrs = append(ferrs, ferr)
+	}
+	interval := h.statsHistoryInterval()
+	if raw := query.Get("interval"); raw != "" {
+		var err error
+		if interval, err = report.ParseWindow(raw); err != nil {
+			ferrs = append(ferrs, &fieldError{"interval", "INVALID_INTERVAL", "Invalid interval. Must be a number of days such as 1d, or a duration such as 6h, up to 365d"})
+		}
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultHistoryRange)
+	}
+	from, to = from.UTC(), to.UTC()
+	switch {
+	case !from.Before(to):
+		h.writeFieldErrors(w, []*fieldError{{"from", "INVALID_RANGE", "Invalid from. Must be before to"}})
+		return
+	case to.Sub(from)/interval > maxHistoryIntervals:
+		h.writeFieldErrors(w, []*fieldError{{"interval", "INVALID_INTERVAL", "Invalid interval. The range can span at most " + strconv.Itoa(maxHistoryIntervals) + " intervals"}})
+		return
+	}
+
+	snaps := latestPerInterval(h.store.GetStatsSnapshots(r.Context(), from, to), from, interval)
+	h.writeJSON(w, http.StatusOK, model.StatsHistoryResponse{
+		From:      from,
+		To:        to,
+		Interval:  interval.String(),
+		Snapshots: snaps,
+		Count:     len(snaps),
+	})
+}
+
+// latestPerInterval returns the latest of snaps, which are oldest first,
+// in each interval counted from from.
+func latestPerInterval(snaps []model.StatsSnapshot, from time.Time, interval time.Duration) []model.StatsSnapshot {
+	latest := []model.StatsSnapshot{}
+	for i, snap := range snaps {
+		if i+1 < len(snaps) && snaps[i+1].At.Sub(from)/interval == snap.At.Sub(from)/interval {
+			continue
+		}
+		latest = append(latest, snap)
+	}
+	return latest
+}
@@ -0,0 +1,36 @@
+package handler
+
+import "go-backend/internal/middleware"
+
+// resourceTypes maps the routes of resource collections to the resource
+// types JSON:API responses give their records.
+var resourceTypes = map[string]string{
+	"/api/users":          "users",
+	"/api/tasks":          "tasks",
+	"/api/projects":       "projects",
+	"/api/announcements":  "announcements",
+	"/api/attachments":    "attachments",
+	"/api/admin/webhooks": "webhooks",
+}
+
+// relationships maps the ID fields of records to the relationships
+// JSON:API responses link them as.
+var relationships = map[string]middleware.Relationship{
+	"userId":    {Name: "user", Type: "users"},
+	"projectId": {Name: "project", Type: "projects"},
+	"taskId":    {Name: "task", Type: "tasks"},
+	"blockedBy": {Name: "blockedBy", Type: "tasks"},
+}
+
+// hypermediaConfig configures JSON:API responses. Relationships are also
+// recognized under their legacy field names.
+func (h *Handler) hypermediaConfig() middleware.HypermediaConfig {
+	rels := make(map[string]middleware.Relationship, 2*len(relationships))
+	for field, rel := range relationships {
+		rels[field] = rel
+		if legacy, ok := legacyFieldNames()[field]; ok {
+			rels[legacy] = rel
+		}
+	}
+	return middleware.HypermediaConfig{Resources: resourceTypes, Relationships: rels}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"go-backend/internal/middleware"
+)
+
+// idRoutes are the routes with IDs in their path. Signed download URLs,
+// /files/attachments/{id}, keep plain IDs: they are built for one client
+// and cannot be guessed without their signature.
+var idRoutes = []string{
+	"/api/users/{id}",
+	"/api/tasks/{id}",
+	"/api/tasks/{id}/dependencies/{id}",
+	"/api/attachments/{id}",
+	"/api/projects/{id}",
+	"/api/announcements/{id}",
+	"/api/admin/webhooks/{id}",
+}
+
+// idFieldNames returns the JSON names of the ID fields of apiModels, and
+// their legacy names: integer fields named id or ending in Id, and fields
+// holding lists of integers, which all hold IDs.
+var idFieldNames = sync.OnceValues(func() (fields, lists map[string]bool) {
+	fields, lists = make(map[string]bool), make(map[string]bool)
+	seen := make(map[reflect.Type]bool)
+	for _, v := range apiModels {
+		jsonFields(reflect.TypeOf(v), seen, func(_ reflect.Type, name string, typ reflect.Type) {
+			for typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+			}
+			names := fields
+			switch {
+			case typ.Kind() == reflect.Int && (name == "id" || strings.HasSuffix(name, "Id")):
+			case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Int:
+				names = lists
+			default:
+				return
+			}
+			names[name] = true
+			if legacy, ok := legacyFieldNames()[name]; ok {
+				names[legacy] = true
+			}
+		})
+	}
+	return fields, lists
+})
+
+// idConfig configures ID obfuscation with the configured codec.
+func (h *Handler) idConfig() middleware.IDConfig {
+	fields, lists := idFieldNames()
+	return middleware.IDConfig{
+		Codec:  h.config.IDCodec,
+		Fields: fields,
+		Lists:  lists,
+		Routes: idRoutes,
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// handleMetrics serves /metrics: the store operations counted by
+// Config.StoreMetrics, in the Prometheus text format.
+func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := h.config.StoreMetrics.Stats()
+
+	w.Header().Set("Content-Type", metricsContentType)
+	bw := bufio.NewWriter(w)
+	metric := func(name, kind, help string, value func(i int) string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for i, op := range stats.Operations {
+			fmt.Fprintf(bw, "%s{op=%q,kind=%q} %s\n", name, op.Op, op.Kind, value(i))
+		}
+	}
+	metric("store_operations_total", "counter", "Store operations made since the server started.", func(i int) string {
+		return strconv.FormatInt(stats.Operations[i].Count, 10)
+	})
+	metric("store_operation_seconds_total", "counter", "Time spent in store operations.", func(i int) string {
+		return strconv.FormatFloat(stats.Operations[i].TotalMs/1000, 'g', -1, 64)
+	})
+	metric("store_records_total", "counter", "Records store operations returned or wrote.", func(i int) string {
+		return strconv.FormatInt(stats.Operations[i].Records, 10)
+	})
+	bw.Flush()
+}
+
+// handleStoreStats serves /api/stats/store: the store operations counted
+// by Config.StoreMetrics, by name.
+func (h *Handler) handleStoreStats(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.config.StoreMetrics.Stats())
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go-backend/internal/model"
+	"go-backend/internal/report"
+)
+
+// apiModels holds every type sent or accepted as JSON, with one zero
+// element in each list so the elements are walked too. Field names are
+// checked against the camelCase convention, and the legacy snake_case
+// names are derived, from these types; add new models here.
+var apiModels = []interface{}{
+	model.User{},
+	model.UsersResponse{Users: []model.User{{}}},
+	model.CompactUsersResponse{Users: []model.UserSummary{{}}},
+	model.Task{},
+	model.TasksResponse{Tasks: []model.Task{{Unfurls: []model.Unfurl{{}}}}},
+	model.CompactTasksResponse{Tasks: []model.TaskSummary{{}}},
+	model.UserTasksResponse{TasksResponse: model.TasksResponse{Tasks: []model.Task{{}}}},
+	model.CompactUserTasksResponse{CompactTasksResponse: model.CompactTasksResponse{Tasks: []model.TaskSummary{{}}}},
+	model.TaskChanges{},
+	model.DependenciesResponse{},
+	model.Share{},
+	model.ShareResponse{},
+	model.SharesResponse{},
+	model.SharedTask{},
+	model.Announcement{},
+	model.AnnouncementsResponse{Announcements: []model.Announcement{{}}},
+	model.TaskHTMLResponse{},
+	model.MarkdownResponse{},
+	model.StatsResponse{},
+	model.TagsResponse{Tags: []model.TagCount{{}}},
+	model.Project{},
+	model.ProjectsResponse{Projects: []model.Project{{}}},
+	model.CompactProjectsResponse{Projects: []model.ProjectSummary{{}}},
+	model.ProjectStats{},
+	model.AuditEvent{},
+	model.AuditResponse{Events: []model.AuditEvent{{Changes: []model.FieldChange{{}}}}},
+	model.Webhook{},
+	model.WebhookResponse{},
+	model.WebhooksResponse{Webhooks: []model.Webhook{{}}},
+	model.WebhookPayload{},
+	model.WebhookDeliveriesResponse{Deliveries: []model.WebhookDelivery{{}}},
+	model.SocketSnapshot{Tasks: []model.Task{{}}},
+	model.SocketChange{Task: &model.Task{}},
+	model.SocketNotice{},
+	model.DetailedStatsResponse{PerUser: []model.UserStats{{}}},
+	model.StatsStreamEvent{User: &model.UserStats{}, Summary: &model.StatsResponse{}},
+	model.HealthResponse{},
+	model.DetailedHealthResponse{},
+	model.RouteUsageResponse{},
+	model.DigestRunResponse{Digests: []model.Digest{{}}},
+	model.CapacityReport{Users: []model.UserCapacity{{}}},
+	model.WorkloadReport{Users: []model.UserWorkload{{}}},
+	model.StatsHistoryResponse{Snapshots: []model.StatsSnapshot{{}}},
+	model.ReportResult{Rows: [][]interface{}{nil}},
+	model.ReportCostResponse{},
+	model.ReportRefreshResponse{},
+	model.ConsistencyReport{Checks: []model.ConsistencyCheck{{}}},
+	model.Diagnosis{Findings: []model.DiagnosticFinding{{}}},
+	model.ReportFieldsResponse{},
+	model.Attachment{},
+	model.AttachmentsResponse{Attachments: []model.Attachment{{}}},
+	model.SignedURLResponse{},
+	model.Operation{Progress: &model.OperationProgress{}, Result: model.ImportReport{}},
+	model.OperationsResponse{Operations: []model.Operation{{}}},
+	model.ImportReport{Errors: []model.ImportRowError{{}}},
+	model.ValidationReport{Items: []model.ItemValidation{{}}},
+	model.StatusesResponse{},
+	model.LocaleResponse{},
+	model.RolesResponse{},
+	model.ChangelogResponse{Releases: []model.ChangelogRelease{{Changes: []model.APIChange{{}}}}},
+	model.SchemaResponse{Models: map[string]model.ModelSchema{"task": {Fields: []model.FieldSchema{{}}}}},
+	model.ErrorResponse{},
+	model.SuccessResponse{},
+
+	model.CreateUserRequest{},
+	model.ChangePasswordRequest{},
+	model.UpdatePreferencesRequest{},
+	model.CreateTaskRequest{},
+	model.UpdateTaskRequest{},
+	model.AddDependencyRequest{},
+	model.CreateShareRequest{},
+	model.CreateAnnouncementRequest{},
+	model.UpdateAnnouncementRequest{},
+	model.CreateProjectRequest{},
+	model.UpdateProjectRequest{},
+	model.CreateWebhookRequest{},
+	model.MarkdownRequest{},
+	model.AttachFromHashRequest{},
+	model.SocketRequest{Filter: &model.TaskFilter{}},
+	report.Spec{},
+}
+
+// camelCaseName matches field names following the API's convention:
+// lowerCamelCase letters and digits.
+var camelCaseName = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+// jsonFields calls fn with the JSON name and type of each field encoded
+// for t and for the types t contains, walking each type once.
+func jsonFields(t reflect.Type, seen map[reflect.Type]bool, fn func(owner reflect.Type, name string, typ reflect.Type)) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	// Types that encode themselves, such as times, have no fields to name
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" && field.Anonymous {
+			// Embedded fields are encoded as if they were t's own
+			jsonFields(field.Type, seen, fn)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fn(t, name, field.Type)
+		jsonFields(field.Type, seen, fn)
+	}
+}
+
+// namingViolations returns the fields of t, and of the types it contains,
+// whose JSON names are not camelCase, as "Type.name".
+func namingViolations(t reflect.Type) []string {
+	var found []string
+	jsonFields(t, make(map[reflect.Type]bool), func(owner reflect.Type, name string, _ reflect.Type) {
+		if !camelCaseName.MatchString(name) {
+			found = append(found, owner.Name()+"."+name)
+		}
+	})
+	return found
+}
+
+// checkedTypes records the struct types whose field names have been
+// checked as they were encoded.
+var checkedTypes sync.Map
+
+// checkFieldNames logs, once per type, the fields of t whose JSON names
+// are not camelCase. It is called as responses are encoded, so a response
+// type missing from apiModels, and from its naming test, is still caught.
+func checkFieldNames(t reflect.Type) {
+	if _, done := checkedTypes.LoadOrStore(t, true); done {
+		return
+	}
+	if found := namingViolations(t); len(found) > 0 {
+		log.Printf("Warning: JSON field names are not camelCase: %s", strings.Join(found, ", "))
+	}
+}
+
+// legacyFieldNames returns the snake_case name of every camelCase field
+// of apiModels that has one, keyed by its canonical name.
+var legacyFieldNames = sync.OnceValue(func() map[string]string {
+	names := make(map[string]string)
+	seen := make(map[reflect.Type]bool)
+	for _, v := range apiModels {
+		jsonFields(reflect.TypeOf(v), seen, func(_ reflect.Type, name string, _ reflect.Type) {
+			if legacy := snakeCase(name); legacy != name {
+				names[name] = legacy
+			}
+		})
+	}
+	return names
+})
+
+// snakeCase returns the snake_case form of a camelCase name, treating a
+// run of capitals as one word: "userId" is "user_id" and "signedURL" is
+// "signed_url".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			startsWord := i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+			if startsWord {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// normalizeJSON returns a copy of v in which every nil slice and map is
+// empty, so responses send [] and {} rather than null. Nil pointers, which
+// mark absent optional values, are kept, as are values that encode
+// themselves, such as times and raw JSON.
+func normalizeJSON(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return normalizeValue(reflect.ValueOf(v)).Interface()
+}
+
+// normalizeValue returns a copy of v with nil slices and maps made empty.
+func normalizeValue(v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(t.Elem())
+		out.Elem().Set(normalizeValue(v.Elem()))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(t).Elem()
+		out.Set(normalizeValue(v.Elem()))
+		return out
+	case reflect.Struct:
+		checkFieldNames(t)
+		out := reflect.New(t).Elem()
+		out.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				out.Field(i).Set(normalizeValue(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		// Byte slices encode as base64 strings, not arrays
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(normalizeValue(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(normalizeValue(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		out := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), normalizeValue(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
+
+// encodeJSON writes v as JSON with nil slices and maps sent as empty ones.
+func encodeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(normalizeJSON(v))
+}
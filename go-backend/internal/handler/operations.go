@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"go-backend/internal/model"
+	"go-backend/internal/operation"
+)
+
+// wantsAsync reports whether the client asked for an expensive request to
+// run as an operation, with ?async=true or Prefer: respond-async.
+func wantsAsync(r *http.Request) bool {
+	return r.URL.Query().Get("async") == "true" ||
+		strings.Contains(r.Header.Get("Prefer"), "respond-async")
+}
+
+// startOperation runs fn as an operation of the given kind and responds
+// with 202 Accepted and a Location to poll for the outcome, or with 503 if
+// too many operations are running to start another.
+func (h *Handler) startOperation(w http.ResponseWriter, kind string, fn operation.Func) {
+	op, err := h.ops.Start(kind, fn)
+	if err != nil {
+		w.Header().Set("Retry-After", "5")
+		h.writeError(w, http.StatusServiceUnavailable, "Too many operations running, try again later", "TOO_MANY_OPERATIONS")
+		return
+	}
+
+	w.Header().Set("Location", "/api/operations/"+op.ID)
+	h.writeJSON(w, http.StatusAccepted, op)
+}
+
+func (h *Handler) handleOperations(w http.ResponseWriter, r *http.Request) {
+	ops := h.ops.List()
+	h.writeJSON(w, http.StatusOK, model.OperationsResponse{
+		Operations: ops,
+		Count:      len(ops),
+	})
+}
+
+// getOperation serves GET /api/operations/{id}: the progress or outcome
+// of an operation.
+func (h *Handler) getOperation(w http.ResponseWriter, r *http.Request) {
+	op, found := h.ops.Get(r.PathValue("id"))
+	if !found {
+		h.writeError(w, http.StatusNotFound, "Operation not found", "OPERATION_NOT_FOUND")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, op)
+}
+
+// cancelOperation serves DELETE /api/operations/{id}.
+func (h *Handler) cancelOperation(w http.ResponseWriter, r *http.Request) {
+	op, found := h.ops.Cancel(r.PathValue("id"))
+	if !found {
+		h.writeError(w, http.StatusNotFound, "Operation not found", "OPERATION_NOT_FOUND")
+		return
+	}
+	if op.Status != model.OperationRunning {
+		h.writeError(w, http.StatusConflict, "Operation has already finished", "OPERATION_FINISHED")
+		return
+	}
+	// Cancellation takes effect once the operation notices; poll for the
+	// cancelled status.
+	h.writeJSON(w, http.StatusAccepted, op)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-backend/internal/locale"
+	"go-backend/internal/middleware"
+	"go-backend/internal/timezone"
+)
+
+// timezoneHeader names the IANA time zone a client wants due dates read
+// and shown in, overriding the assignee's preference.
+const timezoneHeader = "X-Timezone"
+
+// RequestOptions are the preferences a client states for how a response
+// is presented. They are negotiated once per request, by negotiate, and
+// handlers read them with requestOptions rather than parsing headers and
+// query parameters themselves.
+type RequestOptions struct {
+	// Locale is negotiated from Accept-Language.
+	Locale locale.Locale
+	// Location is the zone named in the X-Timezone header, or nil if there
+	// is none, in which case each task's due date is shown in the zone of
+	// its assignee.
+	Location *time.Location
+	// Version is the API version resolved by the versioning middleware.
+	Version string
+	// View is the response view asked for with ?view=: viewFull, the
+	// default, or viewCompact.
+	View string
+}
+
+type optionsContextKey struct{}
+
+// negotiated is what negotiate stores for a request.
+type negotiated struct {
+	opts RequestOptions
+	err  *fieldError
+}
+
+// negotiate negotiates the options of each request and stores them in its
+// context. Invalid preferences are not rejected here but by the handlers
+// that use them, so a bad X-Timezone does not fail requests that show no
+// dates.
+func negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, err := negotiateOptions(r)
+		ctx := context.WithValue(r.Context(), optionsContextKey{}, negotiated{opts, err})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestOptions returns the options negotiated for r, negotiating them
+// now if r did not pass through negotiate. If a preference is invalid, it
+// also returns the error to respond with; the other options are still set.
+func requestOptions(r *http.Request) (RequestOptions, *fieldError) {
+	if n, ok := r.Context().Value(optionsContextKey{}).(negotiated); ok {
+		return n.opts, n.err
+	}
+	return negotiateOptions(r)
+}
+
+// negotiateOptions parses the options of r, returning the first invalid
+// preference as an error.
+func negotiateOptions(r *http.Request) (RequestOptions, *fieldError) {
+	opts := RequestOptions{
+		Locale:  locale.Negotiate(r.Header.Get("Accept-Language")),
+		Version: middleware.VersionFromContext(r.Context()),
+		View:    viewFull,
+	}
+	if opts.Version == "" {
+		opts.Version = defaultAPIVersion
+	}
+
+	var err *fieldError
+	if name := r.Header.Get(timezoneHeader); name != "" {
+		loc, tzErr := timezone.Load(name)
+		if tzErr != nil {
+			err = &fieldError{"timezone", "INVALID_TIMEZONE", "Invalid " + timezoneHeader + " header: " + tzErr.Error()}
+		}
+		opts.Location = loc
+	}
+
+	switch view := r.URL.Query().Get("view"); view {
+	case "", viewFull:
+	case viewCompact:
+		opts.View = viewCompact
+	default:
+		if err == nil {
+			err = &fieldError{"view", "INVALID_VIEW", "Invalid view. Must be one of: compact, full"}
+		}
+	}
+	return opts, err
+}
+
+// setLocaleHeaders sets the headers of a response that depends on the
+// negotiated locale.
+func setLocaleHeaders(w http.ResponseWriter, loc locale.Locale) {
+	w.Header().Set("Content-Language", loc.Tag)
+	w.Header().Add("Vary", "Accept-Language")
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-backend/internal/events"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+	"go-backend/internal/validator"
+)
+
+func (h *Handler) listProjects(w http.ResponseWriter, r *http.Request) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+	projects := h.store.GetProjects(r.Context())
+	h.writeJSON(w, http.StatusOK, projectsView(model.ProjectsResponse{Projects: projects, Count: len(projects)}, opts.View))
+}
+
+func (h *Handler) getProject(w http.ResponseWriter, r *http.Request, id int) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+	project := h.store.GetProjectByID(r.Context(), id)
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+		return
+	}
+	if opts.View == viewCompact {
+		h.writeJSON(w, http.StatusOK, project.Summary())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, project)
+}
+
+// listProjectTasks serves GET /api/projects/{id}/tasks: the project's
+// tasks, filtered and ordered as /api/tasks lists them.
+func (h *Handler) listProjectTasks(w http.ResponseWriter, r *http.Request, id int) {
+	if h.store.GetProjectByID(r.Context(), id) == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+		return
+	}
+	h.listTasks(w, r, id)
+}
+
+// handleProjectStats serves GET /api/projects/{id}/stats.
+func (h *Handler) handleProjectStats(w http.ResponseWriter, r *http.Request, id int) {
+	project := h.store.GetProjectByID(r.Context(), id)
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+		return
+	}
+	stats, err := h.projectStats(r, *project)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to count project tasks", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, stats)
+}
+
+func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateProjectRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if ferrs := fieldErrors(validator.Validate(req)); len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	project, err := h.store.CreateProject(r.Context(), model.Project{Name: req.Name, Description: req.Description})
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create project", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityProject, Action: events.ActionCreate, ID: strconv.Itoa(project.ID), After: project})
+	h.writeJSON(w, http.StatusCreated, project)
+}
+
+func (h *Handler) updateProject(w http.ResponseWriter, r *http.Request, id int) {
+	existing := h.store.GetProjectByID(r.Context(), id)
+	if existing == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+		return
+	}
+
+	var req model.UpdateProjectRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if ferrs := fieldErrors(validator.Validate(req)); len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	// Read before updating: the store may return its own record
+	before := *existing
+	updated, err := h.store.UpdateProject(r.Context(), id, model.ProjectUpdate{Name: req.Name, Description: req.Description})
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update project", "INTERNAL_ERROR")
+		return
+	}
+	if updated == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityProject, Action: events.ActionUpdate, ID: strconv.Itoa(id), Before: before, After: *updated})
+	h.writeJSON(w, http.StatusOK, updated)
+}
+
+// deleteProject deletes a project that has no tasks.
+func (h *Handler) deleteProject(w http.ResponseWriter, r *http.Request, id int) {
+	existing := h.store.GetProjectByID(r.Context(), id)
+	if existing == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+		return
+	}
+	before := *existing
+	switch err := h.store.DeleteProject(r.Context(), id); {
+	case errors.Is(err, store.ErrProjectNotFound):
+		h.writeError(w, http.StatusNotFound, "Project not found", "PROJECT_NOT_FOUND")
+	case errors.Is(err, store.ErrProjectInUse):
+		h.writeError(w, http.StatusConflict, "Project still has tasks; move them to another project or none first", "PROJECT_NOT_EMPTY")
+	case err != nil:
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete project", "INTERNAL_ERROR")
+	default:
+		h.emit(r, events.Change{Entity: events.EntityProject, Action: events.ActionDelete, ID: strconv.Itoa(id), Before: before})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// projectStats counts the tasks of project by status and priority.
+func (h *Handler) projectStats(r *http.Request, project model.Project) (model.ProjectStats, error) {
+	tasks, err := h.store.GetTasks(r.Context(), "", "")
+	if err != nil {
+		return model.ProjectStats{}, err
+	}
+	stats := model.ProjectStats{ProjectID: project.ID, Name: project.Name}
+	now := time.Now()
+	for _, task := range tasks {
+		if task.ProjectID != project.ID {
+			continue
+		}
+		stats.Tasks.Total++
+		switch task.Status {
+		case "pending":
+			stats.Tasks.Pending++
+		case "in-progress":
+			stats.Tasks.InProgress++
+		case "completed":
+			stats.Tasks.Completed++
+		}
+		switch task.Priority {
+		case "low":
+			stats.Priorities.Low++
+		case "medium":
+			stats.Priorities.Medium++
+		case "high":
+			stats.Priorities.High++
+		case "urgent":
+			stats.Priorities.Urgent++
+		}
+		if task.Overdue(now) {
+			stats.Overdue++
+		}
+	}
+	return stats, nil
+}
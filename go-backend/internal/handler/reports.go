@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-backend/internal/model"
+	"go-backend/internal/report"
+)
+
+// Names of the materialized reports.
+const (
+	capacityReport = "capacity"
+	statsReport    = "stats"
+)
+
+// registerReports registers the reports served from the materialized
+// cache rather than computed per request.
+func (h *Handler) registerReports() {
+	h.reports.Register(capacityReport, func(ctx context.Context, now time.Time) (interface{}, error) {
+		// Read users and tasks together so every task's user is present
+		snap, err := h.store.Snapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return report.Capacity(snap.Users(), snap.Tasks(), now, h.config.CapacityWeights), nil
+	})
+	if h.stats != nil {
+		h.stats.Register(statsReport, func(ctx context.Context, _ time.Time) (interface{}, error) {
+			return h.store.GetStats(ctx), nil
+		})
+	}
+}
+
+// ScheduleReports refreshes the materialized reports every
+// Config.ReportRefresh until ctx is done.
+func (h *Handler) ScheduleReports(ctx context.Context) {
+	h.reports.Schedule(ctx)
+}
+
+// ScheduleStats refreshes the materialized /api/stats every
+// Config.StatsRefresh until ctx is done. It returns at once if stats are
+// computed on request.
+func (h *Handler) ScheduleStats(ctx context.Context) {
+	if h.stats == nil {
+		return
+	}
+	h.stats.Schedule(ctx)
+}
+
+func (h *Handler) handleCapacityReport(w http.ResponseWriter, r *http.Request) {
+	h.writeMaterialized(w, r, h.reports, capacityReport)
+}
+
+// handleUserStats serves /api/stats/users: each user's tasks by status,
+// the average age of their open tasks and their completion rate over
+// ?window=, 30 days unless given.
+func (h *Handler) handleUserStats(w http.ResponseWriter, r *http.Request) {
+	window := report.DefaultWorkloadWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		var err error
+		if window, err = report.ParseWindow(raw); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid window. Must be a number of days such as 30d, or a duration such as 72h, up to 365d", "INVALID_WINDOW")
+			return
+		}
+	}
+
+	// Read users and tasks together so every task's user is present
+	snap, err := h.store.Snapshot(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to generate report", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, report.Workload(snap.Users(), snap.Tasks(), time.Now(), window))
+}
+
+// writeMaterialized writes the latest result of a report materialized by
+// m, with Last-Modified set to when it was generated.
+func (h *Handler) writeMaterialized(w http.ResponseWriter, r *http.Request, m *report.Materializer, name string) {
+	result, err := m.Get(r.Context(), name)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to generate report", "INTERNAL_ERROR")
+		return
+	}
+	w.Header().Set("Last-Modified", result.GeneratedAt.Format(http.TimeFormat))
+	h.writeJSON(w, http.StatusOK, result.Report)
+}
+
+// handleReportsRefresh recomputes the materialized reports now, or only
+// the one named by ?report=.
+func (h *Handler) handleReportsRefresh(w http.ResponseWriter, r *http.Request) {
+	var results []report.Materialized
+	if name := r.URL.Query().Get("report"); name != "" {
+		result, err := h.reports.Refresh(r.Context(), name)
+		if errors.Is(err, report.ErrUnknownReport) {
+			h.writeError(w, http.StatusNotFound, "Report not found", "REPORT_NOT_FOUND")
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to generate report", "INTERNAL_ERROR")
+			return
+		}
+		results = append(results, result)
+	} else {
+		results = h.reports.RefreshAll(r.Context())
+	}
+
+	response := model.ReportRefreshResponse{Reports: make([]model.MaterializedReport, len(results))}
+	for i, result := range results {
+		response.Reports[i] = model.MaterializedReport{
+			Name:        result.Name,
+			GeneratedAt: result.GeneratedAt.Format(time.RFC3339),
+		}
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// handleReportFields serves GET /api/reports: the fields custom reports
+// can use.
+func (h *Handler) handleReportFields(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, model.ReportFieldsResponse{
+		Entities: map[string][]string{
+			"tasks": report.Fields("tasks"),
+			"users": report.Fields("users"),
+		},
+	})
+}
+
+// runReport serves POST /api/reports, which runs a report spec.
+func (h *Handler) runReport(w http.ResponseWriter, r *http.Request) {
+	var spec report.Spec
+
+	if !h.decodeJSON(w, r, &spec) {
+		return
+	}
+	var f csvFormat
+	if spec.Format == report.FormatCSV {
+		var ok bool
+		if f, ok = h.csvExportFormat(w, r); !ok {
+			return
+		}
+	}
+
+	// Reports run over a consistent snapshot, never the live data
+	snap, err := h.store.Snapshot(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to run report", "INTERNAL_ERROR")
+		return
+	}
+	users, tasks := snap.Users(), snap.Tasks()
+
+	// Estimate the cost first so an expensive report is refused before it
+	// ties up the store
+	cost, err := report.Estimate(spec, len(users), len(tasks))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid report: "+err.Error(), "INVALID_REPORT")
+		return
+	}
+	limits := h.config.ReportLimits.WithDefaults()
+	costErr := limits.Check(cost)
+
+	query := r.URL.Query()
+	if query.Get("estimate") == "true" {
+		h.writeJSON(w, http.StatusOK, model.ReportCostResponse{
+			Rows:           cost.Rows,
+			Evaluations:    cost.Evaluations,
+			Groups:         cost.Groups,
+			MaxRows:        limits.MaxRows,
+			MaxEvaluations: limits.MaxEvaluations,
+			MaxGroups:      limits.MaxGroups,
+			WithinLimits:   costErr == nil,
+		})
+		return
+	}
+	if costErr != nil && query.Get("force") != "true" {
+		h.writeError(w, http.StatusUnprocessableEntity,
+			"Report is too expensive: "+costErr.Error()+"; add ?force=true to run it anyway", "REPORT_TOO_EXPENSIVE")
+		return
+	}
+
+	result, err := report.Build(spec, users, tasks, time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid report: "+err.Error(), "INVALID_REPORT")
+		return
+	}
+
+	setSnapshotRevision(w, snap)
+	if spec.Format != report.FormatCSV {
+		h.writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	startCSVDownload(w, "report.csv")
+
+	cw := f.writer(w)
+	cw.Write(result.Columns)
+	for _, row := range result.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = f.value(v)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// routeMethods are the methods routes are registered for. HEAD is served
+// by GET routes and OPTIONS by the router.
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+
+// router registers routes on a ServeMux under method patterns such as
+// "GET /api/tasks/{id}", whose parameters handlers read with r.PathValue.
+// Every route allows cross-origin requests and counts its requests in the
+// route usage report, and every path answers the
+// OPTIONS preflight with the methods it has routes for. Requests that no
+// route matches get JSON errors like the handlers': 405 with code
+// METHOD_NOT_ALLOWED, listing the path's methods in Allow, or 404 with
+// code NOT_FOUND.
+type router struct {
+	h   *Handler
+	mux *http.ServeMux
+	// methods lists the methods routed for each path pattern
+	methods map[string][]string
+}
+
+func newRouter(h *Handler, mux *http.ServeMux) *router {
+	rt := &router{h: h, mux: mux, methods: make(map[string][]string)}
+	mux.HandleFunc("/", rt.handleNoRoute)
+	return rt
+}
+
+// handle registers handler for pattern, a method and a path.
+func (rt *router) handle(pattern string, handler http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	if _, ok := rt.methods[path]; !ok {
+		rt.mux.HandleFunc(http.MethodOptions+" "+path, func(w http.ResponseWriter, r *http.Request) {
+			rt.h.handleCORS(w, rt.methods[path])
+		})
+	}
+	rt.methods[path] = append(rt.methods[path], method)
+
+	rt.mux.Handle(pattern, rt.h.usage.Track(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		handler(w, r)
+	})))
+}
+
+// handleNoRoute answers requests that no route matches.
+func (rt *router) handleNoRoute(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, method := range routeMethods {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		if _, pattern := rt.mux.Handler(probe); pattern != "/" {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) == 0 {
+		rt.h.writeError(w, http.StatusNotFound, "Not found", "NOT_FOUND")
+		return
+	}
+	w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+	rt.h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "METHOD_NOT_ALLOWED")
+}
+
+// withID adapts handle, which takes the integer {id} of its route, to a
+// handler. An {id} that is not an integer gets 400 with code INVALID_ID;
+// entity names what it identifies in the message, such as "task".
+func (h *Handler) withID(entity string, handle func(w http.ResponseWriter, r *http.Request, id int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid "+entity+" ID", "INVALID_ID")
+			return
+		}
+		handle(w, r, id)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-backend/internal/locale"
+	"go-backend/internal/model"
+	"go-backend/internal/password"
+	"go-backend/internal/validator"
+)
+
+// apiSchema describes the users, tasks and projects accepted by the API. Constraints
+// come from the validator and password packages, so the schema follows
+// validation as it changes. Keep it in step with the validate tags of the
+// request types and with validateNewUser and validateNewTask.
+func apiSchema() model.SchemaResponse {
+	return model.SchemaResponse{
+		Models: map[string]model.ModelSchema{
+			"user": {Fields: []model.FieldSchema{
+				{Name: "id", Type: "integer", ReadOnly: true},
+				{Name: "name", Type: "string", Required: true, MinLength: 1, MaxLength: 120},
+				{Name: "email", Type: "string", Required: true, Unique: true, Format: "email", Pattern: validator.EmailPattern},
+				{Name: "role", Type: "string", Required: true, Enum: validator.Roles()},
+				{Name: "password", Type: "string", WriteOnly: true, MinLength: password.MinLength, MaxLength: password.MaxLength},
+				{Name: "timezone", Type: "string", Format: "iana-timezone"},
+				{Name: "preferences", Type: "object", ReadOnly: true},
+			}},
+			"task": {Fields: []model.FieldSchema{
+				{Name: "id", Type: "integer", ReadOnly: true},
+				{Name: "title", Type: "string", Required: true, MinLength: 1, MaxLength: 200},
+				{Name: "description", Type: "string", Format: "markdown", MaxLength: 10000},
+				{Name: "status", Type: "string", Required: true, Enum: validator.Statuses()},
+				{Name: "priority", Type: "string", Enum: validator.Priorities(), Default: validator.DefaultPriority},
+				{Name: "tags", Type: "array", Items: "string", MinLength: 1, MaxLength: validator.MaxTagLength, MaxItems: validator.MaxTags},
+				{Name: "projectId", Type: "integer", References: "project"},
+				{Name: "userId", Type: "integer", Required: true, References: "user"},
+				{Name: "createdAt", Type: "string", ReadOnly: true, Format: "date-time"},
+				{Name: "assignedAt", Type: "string", ReadOnly: true, Format: "date-time"},
+				{Name: "completedAt", Type: "string", ReadOnly: true, Format: "date-time"},
+				{Name: "dueAt", Type: "string", Format: "date-time"},
+			}},
+			"project": {Fields: []model.FieldSchema{
+				{Name: "id", Type: "integer", ReadOnly: true},
+				{Name: "name", Type: "string", Required: true, MinLength: 1, MaxLength: 120},
+				{Name: "description", Type: "string", MaxLength: 1000},
+				{Name: "createdAt", Type: "string", ReadOnly: true, Format: "date-time"},
+			}},
+		},
+	}
+}
+
+func (h *Handler) handleSchema(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, apiSchema())
+}
+
+func (h *Handler) handleStatuses(w http.ResponseWriter, r *http.Request) {
+	statuses := validator.Statuses()
+	opts, _ := requestOptions(r)
+	loc := opts.Locale
+	setLocaleHeaders(w, loc)
+	h.writeJSON(w, http.StatusOK, model.StatusesResponse{
+		Statuses: statuses,
+		Count:    len(statuses),
+		Locale:   loc.Tag,
+		Labels:   loc.StatusLabels(statuses),
+	})
+}
+
+// handleLocale describes the locale negotiated from Accept-Language, so
+// clients can display statuses and dates the way the user expects.
+func (h *Handler) handleLocale(w http.ResponseWriter, r *http.Request) {
+	opts, _ := requestOptions(r)
+	loc := opts.Locale
+	setLocaleHeaders(w, loc)
+	h.writeJSON(w, http.StatusOK, model.LocaleResponse{
+		Locale:           loc.Tag,
+		Supported:        locale.Supported(),
+		DateFormat:       loc.DateFormat,
+		TimeFormat:       loc.TimeFormat,
+		ListSeparator:    string(loc.ListSeparator),
+		DecimalSeparator: loc.DecimalSeparator,
+		StatusLabels:     loc.StatusLabels(validator.Statuses()),
+	})
+}
+
+// handleRoles lists the roles users may have, in their configured order.
+func (h *Handler) handleRoles(w http.ResponseWriter, r *http.Request) {
+	roles := validator.Roles()
+	h.writeJSON(w, http.StatusOK, model.RolesResponse{
+		Roles:       roles,
+		Count:       len(roles),
+		AllowCustom: false,
+	})
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"go-backend/internal/events"
+	"go-backend/internal/markdown"
+	"go-backend/internal/model"
+	"go-backend/internal/validator"
+)
+
+// defaultShareDays is how long shares last unless the request says
+// otherwise.
+const defaultShareDays = 7
+
+// listShares serves GET /api/tasks/{id}/share: the task's shares.
+func (h *Handler) listShares(w http.ResponseWriter, r *http.Request, id int) {
+	if h.findTask(w, r, id) == nil {
+		return
+	}
+	shares := h.store.GetShares(r.Context(), id)
+	h.writeJSON(w, http.StatusOK, model.SharesResponse{Shares: shares, Count: len(shares)})
+}
+
+// revokeShare serves DELETE /api/tasks/{id}/share/{shareId}.
+func (h *Handler) revokeShare(w http.ResponseWriter, r *http.Request, id int) {
+	if h.findTask(w, r, id) == nil {
+		return
+	}
+	shareID := r.PathValue("shareId")
+	var before model.Share
+	for _, share := range h.store.GetShares(r.Context(), id) {
+		if share.ID == shareID {
+			before = share
+		}
+	}
+	deleted, err := h.store.DeleteShare(r.Context(), id, shareID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete share", "INTERNAL_ERROR")
+		return
+	}
+	if !deleted {
+		h.writeError(w, http.StatusNotFound, "Share not found", "SHARE_NOT_FOUND")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityShare, Action: events.ActionDelete, ID: shareID, Before: before})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createShare shares a task, returning the token of the share link. The
+// token cannot be retrieved later.
+func (h *Handler) createShare(w http.ResponseWriter, r *http.Request, taskID int) {
+	if h.findTask(w, r, taskID) == nil {
+		return
+	}
+
+	var req model.CreateShareRequest
+	// The body is optional
+	if r.ContentLength != 0 && !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if ferrs := fieldErrors(validator.Validate(req)); len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+	days := defaultShareDays
+	if req.ExpiresInDays != nil {
+		days = *req.ExpiresInDays
+	}
+
+	token, tokenHash := newShareToken()
+	share, err := h.store.CreateShare(r.Context(), model.Share{
+		ID:        randomHex(8),
+		TaskID:    taskID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().UTC().AddDate(0, 0, days),
+	})
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create share", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityShare, Action: events.ActionCreate, ID: share.ID, After: share})
+
+	h.writeJSON(w, http.StatusCreated, model.ShareResponse{
+		Share: share,
+		Token: token,
+		URL:   "/share/" + token,
+	})
+}
+
+// handleSharedTask serves /share/{token}: the read-only view of a shared
+// task. It needs no credentials; the token is the credential.
+func (h *Handler) handleSharedTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Keep the token out of shared caches and other sites' logs
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+
+	token := r.PathValue("token")
+	share := h.store.GetShareByTokenHash(r.Context(), hashShareToken(token))
+	var task *model.Task
+	if share != nil {
+		var err error
+		if task, err = h.store.GetTaskByID(r.Context(), share.TaskID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to read task", "INTERNAL_ERROR")
+			return
+		}
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Share not found", "SHARE_NOT_FOUND")
+		return
+	}
+	if !time.Now().Before(share.ExpiresAt) {
+		h.writeError(w, http.StatusGone, "Share has expired", "SHARE_EXPIRED")
+		return
+	}
+
+	// There is no caller to take a time zone from, only the request
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	rendered := renderTask(*task, loc)
+	h.writeJSON(w, http.StatusOK, model.SharedTask{
+		Title:           rendered.Title,
+		Description:     rendered.Description,
+		DescriptionHTML: markdown.ToHTML(rendered.Description),
+		Status:          rendered.Status,
+		Priority:        rendered.Priority,
+		Tags:            rendered.Tags,
+		CreatedAt:       rendered.CreatedAt,
+		CompletedAt:     rendered.CompletedAt,
+		DueAt:           rendered.DueAt,
+		ExpiresAt:       share.ExpiresAt,
+	})
+}
+
+// newShareToken returns a random share token and its hash.
+func newShareToken() (token, tokenHash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashShareToken(token)
+}
+
+// hashShareToken returns the hash a share token is stored as. Tokens are
+// random, so an unsalted fast hash is enough to keep a leaked database from
+// exposing working links.
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
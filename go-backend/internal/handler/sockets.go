@@ -0,0 +1,396 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/events"
+	"go-backend/internal/middleware"
+	"go-backend/internal/model"
+	"go-backend/internal/validator"
+	"go-backend/internal/websocket"
+)
+
+// Limits of /ws connections.
+const (
+	// socketPingInterval is how often the server pings a connection.
+	socketPingInterval = 30 * time.Second
+	// socketPongWait is how long a connection may go without a message or
+	// pong from the client before it is closed as dead.
+	socketPongWait = 2 * socketPingInterval
+	// socketReadLimit caps the size of a client message.
+	socketReadLimit = 16 << 10
+	// socketMessageLimit is how many messages a client may send per
+	// socketRateWindow; more are answered with RATE_LIMITED.
+	socketMessageLimit = 20
+	socketRateWindow   = 10 * time.Second
+	// maxSocketSubscriptions caps the subscriptions of a connection.
+	maxSocketSubscriptions = 20
+	// maxSocketIDLength caps the length of subscription IDs.
+	maxSocketIDLength = 64
+)
+
+// Message types of the /ws protocol.
+const (
+	socketSubscribe    = "subscribe"
+	socketUnsubscribe  = "unsubscribe"
+	socketPing         = "ping"
+	socketSnapshot     = "snapshot"
+	socketUpsert       = "upsert"
+	socketRemove       = "remove"
+	socketUnsubscribed = "unsubscribed"
+	socketPong         = "pong"
+	socketError        = "error"
+)
+
+// socketEventTypes are the events /ws connections follow.
+var socketEventTypes = []string{events.TaskCreated, events.TaskUpdated}
+
+// handleSocket upgrades to a WebSocket over which clients subscribe to
+// filtered task lists and are sent each change to them, rather than
+// polling. See socket for the protocol.
+func (h *Handler) handleSocket(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsUpgrade(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Upgrade", "websocket")
+		h.writeError(w, http.StatusUpgradeRequired, "Expected a WebSocket upgrade", "UPGRADE_REQUIRED")
+		return
+	}
+
+	// Browsers let any page open a WebSocket, sending its Origin, and CORS
+	// does not apply to it, so pages from other origins are refused here
+	if origin := r.Header.Get("Origin"); origin != "" && !h.corsOrigins.Allows(origin) {
+		w.Header().Set("Content-Type", "application/json")
+		h.writeError(w, http.StatusForbidden, "Origin not allowed", "ORIGIN_NOT_ALLOWED")
+		return
+	}
+
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+
+	// Subscribe before upgrading, so a full or closing hub is refused with
+	// a status clients can act on
+	sub, _, err := h.events.Subscribe(0, socketEventTypes...)
+	switch {
+	case errors.Is(err, events.ErrTooManySubscribers):
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(eventRetry.Seconds())))
+		h.writeError(w, http.StatusServiceUnavailable, "Too many event streams", "TOO_MANY_STREAMS")
+		return
+	case err != nil:
+		w.Header().Set("Content-Type", "application/json")
+		h.writeError(w, http.StatusServiceUnavailable, "Server is shutting down", "SHUTTING_DOWN")
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		sub.Close()
+		return
+	}
+	defer conn.Close()
+
+	s := &socket{
+		h:             h,
+		conn:          conn,
+		loc:           opts.Location,
+		subscriptions: make(map[string]*socketSubscription),
+		limiter:       middleware.NewRateLimiter(socketMessageLimit, socketRateWindow),
+	}
+	defer s.limiter.Close()
+	s.serve(r.Context(), sub)
+}
+
+// socket is a /ws connection. Clients send model.SocketRequest messages:
+//
+//   - "subscribe" with an ID of the client's choosing and a filter, which
+//     is answered with a "snapshot" of the matching tasks. Subscribing
+//     again with the same ID replaces the filter.
+//   - "unsubscribe" with the ID, answered with "unsubscribed".
+//   - "ping", answered with "pong".
+//
+// After its snapshot, a subscription is sent an "upsert" for each task
+// created or updated that matches its filter, and a "remove" for each
+// task that stopped matching. When the data is replaced, e.g. by a
+// restore, or the connection falls behind, every subscription is sent a
+// new snapshot. Invalid or rate limited requests are answered with an
+// "error", and the connection stays open.
+type socket struct {
+	h             *Handler
+	conn          *websocket.Conn
+	loc           *time.Location
+	subscriptions map[string]*socketSubscription
+	limiter       *middleware.RateLimiter
+}
+
+// socketSubscription is a filtered task list followed by a client.
+type socketSubscription struct {
+	filter taskFilter
+	// tasks holds the IDs of the tasks the client was last told match.
+	tasks map[int]bool
+}
+
+// serve runs the connection until the client leaves, the context ends or
+// the hub closes for shutdown. All messages are written from here, apart
+// from the pongs answering pings.
+func (s *socket) serve(ctx context.Context, sub *events.Subscription) {
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	requests := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	readErr := make(chan error, 1)
+	go s.read(requests, readErr, done)
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.conn.WriteClose(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-readErr:
+			return
+		case data := <-requests:
+			if s.handleRequest(ctx, data) != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, or the hub closed for shutdown
+				var err error
+				if sub, _, err = s.h.events.Subscribe(0, socketEventTypes...); err != nil {
+					sub = nil
+					s.conn.WriteClose(websocket.CloseGoingAway, "server shutting down")
+					return
+				}
+				if s.resendSnapshots(ctx) != nil {
+					return
+				}
+				continue
+			}
+			if s.handleEvent(ctx, event) != nil {
+				return
+			}
+		case <-ping.C:
+			if s.conn.WriteControl(websocket.PingMessage, nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+// read reads the client's messages into requests until the connection
+// fails or closes, which it reports on readErr, or done is closed.
+func (s *socket) read(requests chan<- []byte, readErr chan<- error, done <-chan struct{}) {
+	s.conn.SetReadLimit(socketReadLimit)
+	s.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	s.conn.OnPong(func() {
+		s.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			readErr <- err
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+		if messageType != websocket.TextMessage {
+			s.conn.WriteClose(websocket.CloseUnsupportedData, "expected JSON text messages")
+			readErr <- errors.New("binary message")
+			return
+		}
+		select {
+		case requests <- data:
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleRequest handles a message from the client, returning an error
+// only if the connection failed.
+func (s *socket) handleRequest(ctx context.Context, data []byte) error {
+	if allowed, _ := s.limiter.Allow(""); !allowed {
+		return s.sendError("", "RATE_LIMITED", fmt.Sprintf("Too many messages. At most %d per %v", socketMessageLimit, socketRateWindow))
+	}
+
+	var req model.SocketRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return s.sendError("", "INVALID_JSON", "Invalid JSON message")
+	}
+
+	switch req.Type {
+	case socketPing:
+		return s.send(model.SocketNotice{Type: socketPong})
+	case socketSubscribe:
+		if code, message := validateSocketID(req.ID); code != "" {
+			return s.sendError(req.ID, code, message)
+		}
+		if _, exists := s.subscriptions[req.ID]; !exists && len(s.subscriptions) >= maxSocketSubscriptions {
+			return s.sendError(req.ID, "TOO_MANY_SUBSCRIPTIONS", fmt.Sprintf("At most %d subscriptions per connection", maxSocketSubscriptions))
+		}
+		var f model.TaskFilter
+		if req.Filter != nil {
+			f = *req.Filter
+		}
+		filter, code, message := socketFilter(f)
+		if code != "" {
+			return s.sendError(req.ID, code, message)
+		}
+		subscription := &socketSubscription{filter: filter}
+		s.subscriptions[req.ID] = subscription
+		return s.sendSnapshot(ctx, req.ID, subscription)
+	case socketUnsubscribe:
+		if _, exists := s.subscriptions[req.ID]; !exists {
+			return s.sendError(req.ID, "NOT_SUBSCRIBED", "No subscription with this ID")
+		}
+		delete(s.subscriptions, req.ID)
+		return s.send(model.SocketNotice{Type: socketUnsubscribed, ID: req.ID})
+	default:
+		return s.sendError(req.ID, "INVALID_TYPE", "Invalid message type. Must be one of: subscribe, unsubscribe, ping")
+	}
+}
+
+// validateSocketID checks a subscription ID, returning an error code and
+// message if it is invalid.
+func validateSocketID(id string) (code, message string) {
+	if id == "" || len(id) > maxSocketIDLength {
+		return "INVALID_ID", fmt.Sprintf("Subscription ID must be 1 to %d characters", maxSocketIDLength)
+	}
+	return "", ""
+}
+
+// socketFilter converts a subscription's filter to a taskFilter,
+// validating it as listTasks does its query. It returns an error code and
+// message if the filter is invalid.
+func socketFilter(f model.TaskFilter) (filter taskFilter, code, message string) {
+	if f.Priority != "" && !validator.Priority(f.Priority) {
+		return filter, "INVALID_PRIORITY", "Invalid priority. Must be one of: " + strings.Join(validator.Priorities(), ", ")
+	}
+	for _, tag := range f.Tags {
+		if !validator.Tag(tag) {
+			return filter, "INVALID_TAG", fmt.Sprintf("Invalid tag %q", tag)
+		}
+	}
+	if f.TagMode != "" && f.TagMode != "all" && f.TagMode != "any" {
+		return filter, "INVALID_TAG_MODE", "Invalid tagMode. Must be one of: all, any"
+	}
+
+	filter = taskFilter{
+		status:    f.Status,
+		priority:  f.Priority,
+		tags:      validator.NormalizeTags(f.Tags),
+		anyTag:    f.TagMode == "any",
+		projectID: f.ProjectID,
+	}
+	if f.UserID != 0 {
+		filter.userID = strconv.Itoa(f.UserID)
+	}
+	return filter, "", ""
+}
+
+// handleEvent sends the changes an event makes to the subscriptions.
+func (s *socket) handleEvent(ctx context.Context, event events.Event) error {
+	if event.Type == events.Reset {
+		return s.resendSnapshots(ctx)
+	}
+	task, ok := event.Data.(model.Task)
+	if !ok {
+		return nil
+	}
+
+	var rendered *model.Task
+	for _, id := range s.subscriptionIDs() {
+		subscription := s.subscriptions[id]
+		switch {
+		case subscription.filter.matches(task):
+			if rendered == nil {
+				rendered = &s.h.renderTasks(ctx, []model.Task{task}, s.loc)[0]
+			}
+			subscription.tasks[task.ID] = true
+			if err := s.send(model.SocketChange{Type: socketUpsert, ID: id, TaskID: task.ID, Task: rendered}); err != nil {
+				return err
+			}
+		case subscription.tasks[task.ID]:
+			delete(subscription.tasks, task.ID)
+			if err := s.send(model.SocketChange{Type: socketRemove, ID: id, TaskID: task.ID}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resendSnapshots sends every subscription a new snapshot.
+func (s *socket) resendSnapshots(ctx context.Context) error {
+	for _, id := range s.subscriptionIDs() {
+		if err := s.sendSnapshot(ctx, id, s.subscriptions[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscriptionIDs returns the IDs of the subscriptions in order, so
+// messages for one event are sent in a stable order.
+func (s *socket) subscriptionIDs() []string {
+	ids := make([]string, 0, len(s.subscriptions))
+	for id := range s.subscriptions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sendSnapshot sends the tasks matching a subscription, and remembers
+// them to tell when they stop matching.
+func (s *socket) sendSnapshot(ctx context.Context, id string, subscription *socketSubscription) error {
+	response, err := s.h.tasksResponse(ctx, subscription.filter, s.loc)
+	if err != nil {
+		return s.sendError(id, "INTERNAL_ERROR", "Failed to list tasks")
+	}
+	subscription.tasks = make(map[int]bool, len(response.Tasks))
+	for _, task := range response.Tasks {
+		subscription.tasks[task.ID] = true
+	}
+	return s.send(model.SocketSnapshot{
+		Type:     socketSnapshot,
+		ID:       id,
+		Tasks:    response.Tasks,
+		Count:    response.Count,
+		Revision: response.Revision,
+	})
+}
+
+// sendError sends an error message about the subscription with ID id, or
+// the connection if id is empty.
+func (s *socket) sendError(id, code, message string) error {
+	return s.send(model.SocketNotice{Type: socketError, ID: id, Code: code, Message: message})
+}
+
+// send sends msg as a JSON text message.
+func (s *socket) send(msg interface{}) error {
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, msg); err != nil {
+		return err
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
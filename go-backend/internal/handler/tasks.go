@@ -1,193 +1,612 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"go-backend/internal/cache"
+	"go-backend/internal/events"
+	"go-backend/internal/markdown"
 	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/store"
+	"go-backend/internal/unfurl"
 	"go-backend/internal/validator"
 )
 
+// handleTasks serves GET /api/tasks: the tasks matching the query, or
+// with ?since= the changes to them since a revision.
 func (h *Handler) handleTasks(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.URL.Query().Has("since") {
+		h.listTaskChanges(w, r)
+		return
+	}
+	h.listTasks(w, r, 0)
+}
+
+// taskFilter selects and orders the tasks of a list.
+type taskFilter struct {
+	status      string
+	userID      string
+	priority    string
+	tags        []string // normalized
+	anyTag      bool     // match tasks with any of tags rather than all
+	sort        string   // "" for ID order, or "priority" for most urgent first
+	overdueOnly bool
+	blocked     string // "" for all tasks, "true" for blocked ones or "false" for the rest
+	projectID   int    // 0 for tasks of any project or none
+}
+
+// matches reports whether task has the filter's status, assignee,
+// priority, tags and project. Overdue and blocked tasks, which depend on
+// the time and on other tasks, are matched separately.
+func (f taskFilter) matches(task model.Task) bool {
+	return (f.status == "" || task.Status == f.status) &&
+		(f.userID == "" || f.userID == strconv.Itoa(task.UserID)) &&
+		(f.priority == "" || task.Priority == f.priority) &&
+		f.matchesTags(task) &&
+		(f.projectID == 0 || task.ProjectID == f.projectID)
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.listTasks(w, r)
-	case http.MethodPost:
-		h.createTask(w, r)
-	case http.MethodOptions:
-		h.handleCORS(w)
-	default:
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "METHOD_NOT_ALLOWED")
+// matchesTags reports whether task has all of the filter's tags, or any
+// of them with anyTag.
+func (f taskFilter) matchesTags(task model.Task) bool {
+	if len(f.tags) == 0 {
+		return true
 	}
+	for _, want := range f.tags {
+		has := false
+		for _, tag := range task.Tags {
+			if tag == want {
+				has = true
+				break
+			}
+		}
+		if f.anyTag && has {
+			return true
+		}
+		if !f.anyTag && !has {
+			return false
+		}
+	}
+	return !f.anyTag
+}
+
+// matchesBlocked reports whether task is blocked by any of the open tasks,
+// or is not with blocked "false".
+func (f taskFilter) matchesBlocked(task model.Task, open map[int]bool) bool {
+	if f.blocked == "" {
+		return true
+	}
+	blocked := false
+	for _, id := range task.BlockedBy {
+		if open[id] {
+			blocked = true
+			break
+		}
+	}
+	return blocked == (f.blocked == "true")
 }
 
-func (h *Handler) listTasks(w http.ResponseWriter, r *http.Request) {
-	status := r.URL.Query().Get("status")
-	userID := r.URL.Query().Get("userId")
+// projectKey identifies the filter's project in cache keys.
+func (f taskFilter) projectKey() string {
+	if f.projectID == 0 {
+		return ""
+	}
+	return strconv.Itoa(f.projectID)
+}
 
-	cacheKey := cache.TasksKey(status, userID)
-	if cached, found := h.cache.Get(cacheKey); found {
-		json.NewEncoder(w).Encode(cached)
+// cacheKey returns the key the task list matching the filter is cached
+// under.
+func (f taskFilter) cacheKey() string {
+	return cache.TasksKey(f.status, f.userID, f.priority, f.tagKey(), f.blocked, f.projectKey(), f.sort)
+}
+
+// tagKey identifies the filter's tags and mode in cache keys.
+func (f taskFilter) tagKey() string {
+	if len(f.tags) == 0 {
+		return ""
+	}
+	mode := "all:"
+	if f.anyTag {
+		mode = "any:"
+	}
+	return mode + strings.Join(f.tags, ",")
+}
+
+// listTasks lists the tasks matching the query, only those of project
+// projectID unless it is 0.
+func (h *Handler) listTasks(w http.ResponseWriter, r *http.Request, projectID int) {
+	filter, ok := h.parseTaskFilter(w, r)
+	if !ok {
 		return
 	}
+	filter.projectID = projectID
 
-	tasks := h.store.GetTasks(status, userID)
-	response := model.TasksResponse{
-		Tasks: tasks,
-		Count: len(tasks),
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
 	}
+	list, err := h.taskList(w, r, filter, opts.Location)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tasks", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, tasksView(list, opts.View))
+}
 
-	h.cache.Set(cacheKey, response)
+// parseTaskFilter parses the filter and order of a task list from the
+// query, writing an error response and returning false if it is invalid.
+func (h *Handler) parseTaskFilter(w http.ResponseWriter, r *http.Request) (taskFilter, bool) {
+	query := r.URL.Query()
+	filter := taskFilter{
+		status:      query.Get("status"),
+		userID:      query.Get("userId"),
+		priority:    query.Get("priority"),
+		anyTag:      query.Get("tagMode") == "any",
+		sort:        query.Get("sort"),
+		overdueOnly: query.Get("overdue") == "true",
+		blocked:     query.Get("blocked"),
+	}
+	if filter.priority != "" && !validator.Priority(filter.priority) {
+		h.writeError(w, http.StatusBadRequest, "Invalid priority. Must be one of: "+strings.Join(validator.Priorities(), ", "), "INVALID_PRIORITY")
+		return taskFilter{}, false
+	}
+	// Tags may be repeated or comma-separated: ?tag=a&tag=b or ?tag=a,b
+	var tags []string
+	for _, param := range query["tag"] {
+		for _, tag := range strings.Split(param, ",") {
+			if !validator.Tag(tag) {
+				h.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tag %q", tag), "INVALID_TAG")
+				return taskFilter{}, false
+			}
+			tags = append(tags, tag)
+		}
+	}
+	filter.tags = validator.NormalizeTags(tags)
+	if mode := query.Get("tagMode"); mode != "" && mode != "all" && mode != "any" {
+		h.writeError(w, http.StatusBadRequest, "Invalid tagMode. Must be one of: all, any", "INVALID_TAG_MODE")
+		return taskFilter{}, false
+	}
+	if filter.blocked != "" && filter.blocked != "true" && filter.blocked != "false" {
+		h.writeError(w, http.StatusBadRequest, "Invalid blocked. Must be one of: true, false", "INVALID_BLOCKED")
+		return taskFilter{}, false
+	}
+	if filter.sort != "" && filter.sort != "priority" {
+		h.writeError(w, http.StatusBadRequest, "Invalid sort. Must be: priority", "INVALID_SORT")
+		return taskFilter{}, false
+	}
+	return filter, true
+}
+
+// taskList returns the full view of the task list matching filter, with
+// due dates in loc or each assignee's zone if it is nil, from the cache
+// if it was cached, and sets the cache status header. Compact views are
+// projected from it. A list the store fails to read is not cached.
+func (h *Handler) taskList(w http.ResponseWriter, r *http.Request, filter taskFilter, loc *time.Location) (interface{}, error) {
+	// Lists in a requested zone, and overdue tasks, which change with the
+	// time, are not cached
+	if filter.overdueOnly || loc != nil {
+		h.setCacheStatus(w, false)
+		return h.tasksResponse(r.Context(), filter, loc)
+	}
+
+	cacheKey := filter.cacheKey()
+	if cached, found := h.cache.Get(cacheKey); found {
+		h.setCacheStatus(w, true)
+		return cached, nil
+	}
+	h.setCacheStatus(w, false)
+
+	response, err := h.tasksResponse(r.Context(), filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	h.cache.Set(cacheKey, normalizeJSON(response))
+
+	return response, nil
+}
+
+// listTaskChanges lists the IDs of the tasks created, updated and deleted
+// since the revision given with ?since=, so clients polling a list fetch
+// only what changed.
+func (h *Handler) listTaskChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		h.writeError(w, http.StatusBadRequest, "Invalid since. Must be a revision from a tasks list", "INVALID_SINCE")
+		return
+	}
 
-	json.NewEncoder(w).Encode(response)
+	changes, err := h.store.TaskChanges(r.Context(), since)
+	if errors.Is(err, store.ErrUnknownRevision) {
+		h.writeError(w, http.StatusGone, "Changes since this revision are not available. Fetch the full list again", "REVISION_EXPIRED")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list task changes", "INTERNAL_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, changes)
+}
+
+// tasksResponse builds the filtered tasks list response from the store,
+// with due dates shown in loc or, if it is nil, each assignee's zone.
+func (h *Handler) tasksResponse(ctx context.Context, filter taskFilter, loc *time.Location) (model.TasksResponse, error) {
+	// Read before the tasks, so changes made in between are listed again
+	// rather than missed by ?since=
+	revision := h.store.Revision(ctx)
+	tasks, err := h.store.GetTasks(ctx, filter.status, filter.userID)
+	if err != nil {
+		return model.TasksResponse{}, err
+	}
+	if filter.overdueOnly || filter.priority != "" || len(filter.tags) > 0 || filter.blocked != "" || filter.projectID != 0 {
+		now := time.Now()
+		var open map[int]bool
+		if filter.blocked != "" {
+			if open, err = h.openTaskIDs(ctx); err != nil {
+				return model.TasksResponse{}, err
+			}
+		}
+		matched := []model.Task{}
+		for _, task := range tasks {
+			if (!filter.overdueOnly || task.Overdue(now)) && filter.matches(task) && filter.matchesBlocked(task, open) {
+				matched = append(matched, task)
+			}
+		}
+		tasks = matched
+	}
+	if filter.sort == "priority" {
+		// Most urgent first; the store's ID order breaks ties
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return validator.PriorityRank(tasks[i].Priority) > validator.PriorityRank(tasks[j].Priority)
+		})
+	}
+	tasks = h.renderTasks(ctx, tasks, loc)
+	return model.TasksResponse{
+		Tasks:    tasks,
+		Count:    len(tasks),
+		Revision: revision,
+	}, nil
 }
 
 func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateTaskRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON format", "INVALID_JSON")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Validate title
-	if !validator.NonEmpty(req.Title) {
-		h.writeError(w, http.StatusBadRequest, "Title is required and cannot be empty", "INVALID_TITLE")
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
 		return
 	}
 
-	// Validate status
-	if !validator.Status(req.Status) {
-		h.writeError(w, http.StatusBadRequest, "Invalid status. Must be one of: pending, in-progress, completed", "INVALID_STATUS")
+	// Validate fields, including that the user exists and the due date
+	ferrs, err := h.validateNewTask(r.Context(), req, opts.Location)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to validate task", "INTERNAL_ERROR")
 		return
 	}
-
-	// Validate userId exists
-	if h.store.GetUserByID(req.UserID) == nil {
-		h.writeError(w, http.StatusBadRequest, "User ID does not exist", "INVALID_USER_ID")
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
 		return
 	}
+	loc := h.taskLocation(r.Context(), opts.Location, req.UserID)
 
-	task := h.store.CreateTask(req.Title, req.Status, req.UserID)
+	newTask := model.Task{Title: req.Title, Description: req.Description, Status: req.Status, Priority: req.Priority, Tags: req.Tags, ProjectID: req.ProjectID, UserID: req.UserID}
+	if req.DueAt != "" {
+		due, _ := parseDueAt(req.DueAt, loc)
+		newTask.DueAt = &due
+	}
 
-	h.InvalidateTaskCaches()
+	task, err := h.store.CreateTask(r.Context(), newTask)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create task", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityTask, Action: events.ActionCreate, ID: strconv.Itoa(task.ID), After: task})
+	h.startUnfurl(task)
 
-	h.writeJSON(w, http.StatusCreated, task)
+	h.writeJSON(w, http.StatusCreated, renderTask(task, loc))
 }
 
-func (h *Handler) handleTaskByID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	// Extract ID from path
-	path := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
-	if path == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID is required", "MISSING_ID")
+// getTaskByID returns a task. With ?render=html the response adds the
+// description rendered to sanitized HTML as descriptionHtml, so clients
+// need not bundle a Markdown parser.
+func (h *Handler) getTaskByID(w http.ResponseWriter, r *http.Request, id int) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+	render := r.URL.Query().Get("render")
+	if render != "" && render != "html" {
+		h.writeError(w, http.StatusBadRequest, "Invalid render. Must be: html", "INVALID_RENDER")
 		return
 	}
 
-	id, err := strconv.Atoi(path)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid task ID", "INVALID_ID")
+	task := h.findTask(w, r, id)
+	if task == nil {
+		return
+	}
+	if opts.View == viewCompact {
+		h.writeJSON(w, http.StatusOK, task.Summary())
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getTaskByID(w, r, id)
-	case http.MethodPut:
-		h.updateTask(w, r, id)
-	case http.MethodOptions:
-		h.handleCORS(w)
-	default:
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "METHOD_NOT_ALLOWED")
+	rendered := renderTask(*task, h.taskLocation(r.Context(), opts.Location, task.UserID))
+	if render == "html" {
+		h.writeJSON(w, http.StatusOK, model.TaskHTMLResponse{
+			Task:            rendered,
+			DescriptionHTML: markdown.ToHTML(task.Description),
+		})
+		return
 	}
+	h.writeJSON(w, http.StatusOK, rendered)
 }
 
-func (h *Handler) getTaskByID(w http.ResponseWriter, r *http.Request, id int) {
-	task := h.store.GetTaskByID(id)
+// findTask returns task id or, if there is none or the store fails to
+// read it, writes a 404 or 500 and returns nil.
+func (h *Handler) findTask(w http.ResponseWriter, r *http.Request, id int) *model.Task {
+	task, err := h.store.GetTaskByID(r.Context(), id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read task", "INTERNAL_ERROR")
+		return nil
+	}
 	if task == nil {
 		h.writeError(w, http.StatusNotFound, "Task not found", "TASK_NOT_FOUND")
-		return
 	}
-
-	h.writeJSON(w, http.StatusOK, task)
+	return task
 }
 
 func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id int) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+
 	// Check if task exists first
-	if h.store.GetTaskByID(id) == nil {
-		h.writeError(w, http.StatusNotFound, "Task not found", "TASK_NOT_FOUND")
+	existing := h.findTask(w, r, id)
+	if existing == nil {
 		return
 	}
 
 	var req model.UpdateTaskRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON format", "INVALID_JSON")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Validate status if provided
-	if req.Status != nil && !validator.Status(*req.Status) {
-		h.writeError(w, http.StatusBadRequest, "Invalid status. Must be one of: pending, in-progress, completed", "INVALID_STATUS")
-		return
+	// Validate the fields provided
+	ferrs := fieldErrors(validator.Validate(req))
+	if req.UserID != nil {
+		user, err := h.store.GetUserByID(r.Context(), *req.UserID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to read user", "INTERNAL_ERROR")
+			return
+		}
+		if user == nil {
+			ferrs = append(ferrs, &fieldError{"userId", "INVALID_USER_ID", "User ID does not exist"})
+		}
 	}
 
-	// Validate userId if provided
-	if req.UserID != nil && h.store.GetUserByID(*req.UserID) == nil {
-		h.writeError(w, http.StatusBadRequest, "User ID does not exist", "INVALID_USER_ID")
+	if req.ProjectID != nil && *req.ProjectID != 0 && h.store.GetProjectByID(r.Context(), *req.ProjectID) == nil {
+		ferrs = append(ferrs, &fieldError{"projectId", "INVALID_PROJECT_ID", "Project does not exist"})
+	}
+
+	// Due dates are read in the zone of the task's assignee after the update
+	assignee := existing.UserID
+	if req.UserID != nil {
+		assignee = *req.UserID
+	}
+	loc := h.taskLocation(r.Context(), opts.Location, assignee)
+
+	update := model.TaskUpdate{Title: req.Title, Description: req.Description, Status: req.Status, Priority: req.Priority, Tags: req.Tags, ProjectID: req.ProjectID, UserID: req.UserID}
+	if req.DueAt != nil {
+		var due time.Time
+		if *req.DueAt != "" {
+			var ferr *fieldError
+			if due, ferr = parseDueAt(*req.DueAt, loc); ferr != nil {
+				ferrs = append(ferrs, ferr)
+			}
+		}
+		update.DueAt = &due
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
 		return
 	}
 
-	// Validate title if provided
-	if req.Title != nil && !validator.NonEmpty(*req.Title) {
-		h.writeError(w, http.StatusBadRequest, "Title cannot be empty", "INVALID_TITLE")
+	// A task cannot be completed while tasks blocking it are open
+	if req.Status != nil && *req.Status == "completed" && existing.Status != "completed" {
+		open, err := h.openBlockers(r.Context(), *existing)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to read blocking tasks", "INTERNAL_ERROR")
+			return
+		}
+		if len(open) > 0 {
+			h.writeError(w, http.StatusConflict, "Task is blocked by open tasks: "+joinIDs(open), "TASK_BLOCKED")
+			return
+		}
+	}
+
+	// Read before updating: the store may return its own record, which the
+	// update changes
+	links := unfurl.Links(existing.Description)
+	before := *existing
+	updatedTask, err := h.store.UpdateTask(r.Context(), id, update)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update task", "INTERNAL_ERROR")
 		return
 	}
+	if updatedTask == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found", "TASK_NOT_FOUND")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityTask, Action: events.ActionUpdate, ID: strconv.Itoa(id), Before: before, After: *updatedTask})
+	if !slices.Equal(links, unfurl.Links(updatedTask.Description)) {
+		h.startUnfurl(*updatedTask)
+	}
 
-	updatedTask := h.store.UpdateTask(id, req.Title, req.Status, req.UserID)
+	h.writeJSON(w, http.StatusOK, renderTask(*updatedTask, loc))
+}
 
-	h.InvalidateTaskCaches()
+// handleMarkdown renders Markdown the way task descriptions are rendered,
+// so clients can preview a description while it is edited.
+func (h *Handler) handleMarkdown(w http.ResponseWriter, r *http.Request) {
+	var req model.MarkdownRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if ferrs := fieldErrors(validator.Validate(req)); len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, model.MarkdownResponse{HTML: markdown.ToHTML(req.Markdown)})
+}
 
-	h.writeJSON(w, http.StatusOK, updatedTask)
+// handleTags lists the distinct task tags with the number of tasks using
+// each, most used first.
+func (h *Handler) handleTags(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.store.GetTasks(r.Context(), "", "")
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tags", "INTERNAL_ERROR")
+		return
+	}
+	counts := make(map[string]int)
+	for _, task := range tasks {
+		for _, tag := range task.Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]model.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, model.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	h.writeJSON(w, http.StatusOK, model.TagsResponse{Tags: tags, Count: len(tags)})
 }
 
 func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.URL.Query().Get("detailed") == "true" {
+		switch {
+		case wantsStream(r):
+			h.streamDetailedStats(w, r)
+		case wantsAsync(r):
+			h.startOperation(w, "stats", func(ctx context.Context, p *operation.Progress) (interface{}, error) {
+				stats, err := h.detailedStats(ctx, p)
+				if err != nil {
+					return nil, err
+				}
+				return stats, nil
+			})
+		default:
+			stats, _ := h.detailedStats(context.Background(), nil)
+			h.writeJSON(w, http.StatusOK, stats)
+		}
+		return
+	}
+
+	// Behind a status page, serve the latest materialized stats so no
+	// request waits for them to be computed
+	if h.stats != nil {
+		h.writeMaterialized(w, r, h.stats, statsReport)
 		return
 	}
 
 	cacheKey := cache.StatsKey()
 	if cached, found := h.cache.Get(cacheKey); found {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		json.NewEncoder(w).Encode(cached)
+		h.setCacheStatus(w, true)
+		h.writeJSON(w, http.StatusOK, cached)
 		return
 	}
+	h.setCacheStatus(w, false)
 
-	stats := h.store.GetStats()
+	stats := h.store.GetStats(r.Context())
 
-	h.cache.Set(cacheKey, stats)
+	// Stats are invalidated on every write, so they can live longer than lists
+	h.cache.SetWithTTL(cacheKey, normalizeJSON(stats), statsCacheTTL)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	json.NewEncoder(w).Encode(stats)
+	h.writeJSON(w, http.StatusOK, stats)
 }
 
-func (h *Handler) handleCacheStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "METHOD_NOT_ALLOWED")
+// wantsStream reports whether the client asked for a streamed response,
+// with ?stream=true or Accept: application/x-ndjson.
+func wantsStream(r *http.Request) bool {
+	return r.URL.Query().Get("stream") == "true" ||
+		strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
+
+// detailedStats computes per-user stats, reporting each user to p. Stats
+// are read-only, so cancelling ctx simply stops early with ctx.Err().
+func (h *Handler) detailedStats(ctx context.Context, p *operation.Progress) (model.DetailedStatsResponse, error) {
+	response := model.DetailedStatsResponse{
+		StatsResponse: h.store.GetStats(ctx),
+		PerUser:       []model.UserStats{},
+	}
+	p.SetTotal(response.Users.Total)
+	h.store.EachUserStats(ctx, func(row model.UserStats) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		response.PerUser = append(response.PerUser, row)
+		p.Add(1)
+		return true
+	})
+
+	return response, ctx.Err()
+}
+
+// streamDetailedStats writes stats as newline-delimited JSON: one "user"
+// event per user, flushed as it is computed, then a "summary" event.
+// Streaming stops early if the client goes away.
+func (h *Handler) streamDetailedStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+
+	ok := true
+	h.store.EachUserStats(r.Context(), func(row model.UserStats) bool {
+		if r.Context().Err() != nil {
+			ok = false
+			return false
+		}
+		if err := enc.Encode(normalizeJSON(model.StatsStreamEvent{Type: "user", User: &row})); err != nil {
+			ok = false
+			return false
+		}
+		rc.Flush()
+		return true
+	})
+	if !ok {
 		return
 	}
 
+	summary := h.store.GetStats(r.Context())
+	enc.Encode(normalizeJSON(model.StatsStreamEvent{Type: "summary", Summary: &summary}))
+}
+
+func (h *Handler) handleCacheStats(w http.ResponseWriter, r *http.Request) {
 	stats := h.cache.Stats()
 	h.writeJSON(w, http.StatusOK, stats)
 }
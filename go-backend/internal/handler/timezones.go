@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"go-backend/internal/model"
+	"go-backend/internal/timezone"
+)
+
+// userLocation returns the preferred zone of a user, or UTC if they have
+// none.
+func userLocation(user *model.User) *time.Location {
+	if user == nil || user.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := timezone.Load(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// taskLocation returns the zone to read and show a task's due date in:
+// the request's zone if it has one, otherwise that of the assignee.
+func (h *Handler) taskLocation(ctx context.Context, reqLoc *time.Location, userID int) *time.Location {
+	if reqLoc != nil {
+		return reqLoc
+	}
+	// Without the user, times are shown in UTC
+	user, _ := h.store.GetUserByID(ctx, userID)
+	return userLocation(user)
+}
+
+// parseDueAt parses a due date from a request in loc.
+func parseDueAt(value string, loc *time.Location) (time.Time, *fieldError) {
+	due, err := timezone.ParseDue(value, loc)
+	if err != nil {
+		return time.Time{}, &fieldError{"dueAt", "INVALID_DUE_DATE", err.Error()}
+	}
+	return due, nil
+}
+
+// renderTask returns task with its due date shown in loc.
+func renderTask(task model.Task, loc *time.Location) model.Task {
+	if task.DueAt != nil {
+		due := task.DueAt.In(loc)
+		task.DueAt = &due
+	}
+	return task
+}
+
+// renderTasks shows each task's due date in reqLoc or, if that is nil,
+// the zone of its assignee.
+func (h *Handler) renderTasks(ctx context.Context, tasks []model.Task, reqLoc *time.Location) []model.Task {
+	if tasks == nil {
+		return nil
+	}
+
+	zone := h.taskZones(ctx, reqLoc)
+	rendered := make([]model.Task, len(tasks))
+	for i, task := range tasks {
+		rendered[i] = renderTask(task, zone(task.UserID))
+	}
+	return rendered
+}
+
+// taskZones returns a function giving the zone to show the times of a
+// user's tasks in: reqLoc or, if that is nil, the zone of the user. Users
+// are read once, so it suits lists of tasks.
+func (h *Handler) taskZones(ctx context.Context, reqLoc *time.Location) func(userID int) *time.Location {
+	var users []model.User
+	if reqLoc == nil {
+		// Without the users, times are shown in UTC
+		users, _ = h.store.GetUsers(ctx)
+	}
+	return zonesOf(users, reqLoc)
+}
+
+// zonesOf is taskZones for the given users.
+func zonesOf(users []model.User, reqLoc *time.Location) func(userID int) *time.Location {
+	if reqLoc != nil {
+		return func(int) *time.Location { return reqLoc }
+	}
+
+	zones := make(map[int]*time.Location)
+	for _, user := range users {
+		zones[user.ID] = userLocation(&user)
+	}
+	return func(userID int) *time.Location {
+		if loc := zones[userID]; loc != nil {
+			return loc
+		}
+		return time.UTC
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSConfig makes Start serve HTTPS, with a certificate from files or
+// obtained automatically. The zero value serves plain HTTP.
+type TLSConfig struct {
+	// CertFile and KeyFile are PEM files of the certificate, followed by
+	// any intermediates, and of its private key.
+	CertFile string
+	KeyFile  string
+	// Domains, instead of CertFile and KeyFile, has certificates for
+	// these domains obtained from Let's Encrypt over ACME and renewed
+	// before they expire. The CA checks control of a domain by connecting
+	// to it on port 443, or on port 80 if RedirectPort is 80.
+	Domains []string
+	// CacheDir keeps obtained certificates across restarts, so they are
+	// not requested again; the CA limits how often it issues them.
+	CacheDir string
+	// Email is given to the CA for notices about the certificates.
+	Email string
+	// RedirectPort, when set, serves plain HTTP on this port, redirecting
+	// requests to HTTPS and answering ACME challenges.
+	RedirectPort string
+}
+
+// enabled reports whether HTTPS is configured.
+func (c TLSConfig) enabled() bool {
+	return c.CertFile != "" || len(c.Domains) > 0
+}
+
+// manager returns the ACME certificate manager for Domains, or nil when
+// the certificate is read from files.
+func (c TLSConfig) manager() *autocert.Manager {
+	if len(c.Domains) == 0 {
+		return nil
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Domains...),
+		Email:      c.Email,
+	}
+	if c.CacheDir != "" {
+		m.Cache = autocert.DirCache(c.CacheDir)
+	}
+	return m
+}
+
+// redirectToHTTPS redirects requests to the same URL over HTTPS on port.
+// Reads are moved permanently; other methods get 308, so clients repeat
+// them with their body.
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			host = name
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strconv"
+
+	"go-backend/internal/events"
+	"go-backend/internal/model"
+	"go-backend/internal/operation"
+	"go-backend/internal/unfurl"
+)
+
+// unfurlActor is the actor of the changes unfurling makes.
+const unfurlActor = "system:unfurl"
+
+// startUnfurl fetches previews of the links in task's description in the
+// background and replaces the task's previews with them. A description
+// without links clears them. Previews are dropped if the description's
+// links change before they are saved; the change starts its own unfurl.
+func (h *Handler) startUnfurl(task model.Task) {
+	if h.unfurler == nil {
+		return
+	}
+	links := unfurl.Links(task.Description)
+	if len(links) == 0 && len(task.Unfurls) == 0 {
+		return
+	}
+	_, err := h.ops.Start("unfurl", func(ctx context.Context, p *operation.Progress) (interface{}, error) {
+		p.SetTotal(len(links))
+		unfurls := make([]model.Unfurl, 0, len(links))
+		for _, link := range links {
+			unfurls = append(unfurls, h.unfurler.Unfurl(ctx, link))
+			p.Add(1)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		current, err := h.store.GetTaskByID(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || !slices.Equal(unfurl.Links(current.Description), links) {
+			return nil, nil
+		}
+		before := *current
+		updated, err := h.store.UpdateTask(ctx, task.ID, model.TaskUpdate{Unfurls: &unfurls})
+		if err != nil || updated == nil {
+			return nil, err
+		}
+		h.bus.Emit(ctx, events.Change{Entity: events.EntityTask, Action: events.ActionUpdate, ID: strconv.Itoa(task.ID), Actor: unfurlActor, Before: before, After: *updated})
+		return updated, nil
+	})
+	if err != nil {
+		log.Printf("Warning: Link previews of task %d not fetched: %v", task.ID, err)
+	}
+}
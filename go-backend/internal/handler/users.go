@@ -1,115 +1,280 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"go-backend/internal/cache"
+	"go-backend/internal/events"
 	"go-backend/internal/model"
-	"go-backend/internal/validator"
+	"go-backend/internal/password"
+	"go-backend/internal/store"
 )
 
-func (h *Handler) handleUsers(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	switch r.Method {
-	case http.MethodGet:
-		h.listUsers(w, r)
-	case http.MethodPost:
-		h.createUser(w, r)
-	case http.MethodOptions:
-		h.handleCORS(w)
-	default:
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "METHOD_NOT_ALLOWED")
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
 	}
-}
 
-func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
 	cacheKey := cache.UsersKey()
 	if cached, found := h.cache.Get(cacheKey); found {
-		json.NewEncoder(w).Encode(cached)
+		h.setCacheStatus(w, true)
+		h.writeJSON(w, http.StatusOK, usersView(cached, opts.View))
 		return
 	}
+	h.setCacheStatus(w, false)
 
-	users := h.store.GetUsers()
-	response := model.UsersResponse{
-		Users: users,
-		Count: len(users),
+	response, err := h.usersResponse(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list users", "INTERNAL_ERROR")
+		return
 	}
 
-	h.cache.Set(cacheKey, response)
+	h.cache.Set(cacheKey, normalizeJSON(response))
+
+	h.writeJSON(w, http.StatusOK, usersView(response, opts.View))
+}
 
-	json.NewEncoder(w).Encode(response)
+// usersResponse builds the users list response from the store.
+func (h *Handler) usersResponse(ctx context.Context) (model.UsersResponse, error) {
+	users, err := h.store.GetUsers(ctx)
+	if err != nil {
+		return model.UsersResponse{}, err
+	}
+	return model.UsersResponse{
+		Users: users,
+		Count: len(users),
+	}, nil
 }
 
 func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateUserRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON format", "INVALID_JSON")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Validate name
-	if !validator.NonEmpty(req.Name) {
-		h.writeError(w, http.StatusBadRequest, "Name is required and cannot be empty", "INVALID_NAME")
+	// Validate fields, including that the email is not taken
+	ferrs, err := h.validateNewUser(r.Context(), req)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to validate user", "INTERNAL_ERROR")
 		return
 	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	var passwordHash string
+	if req.Password != "" {
+		hash, err := password.Hash(req.Password)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to process password", "INTERNAL_ERROR")
+			return
+		}
+		passwordHash = hash
+	}
 
-	// Validate email
-	if !validator.NonEmpty(req.Email) {
-		h.writeError(w, http.StatusBadRequest, "Email is required and cannot be empty", "INVALID_EMAIL")
+	user, err := h.store.CreateUser(r.Context(), model.User{
+		Name:         req.Name,
+		Email:        req.Email,
+		Role:         req.Role,
+		Timezone:     req.Timezone,
+		PasswordHash: passwordHash,
+	})
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create user", "INTERNAL_ERROR")
 		return
 	}
+	h.emit(r, events.Change{Entity: events.EntityUser, Action: events.ActionCreate, ID: strconv.Itoa(user.ID), After: user})
+
+	h.writeJSON(w, http.StatusCreated, user)
+}
 
-	if !validator.Email(req.Email) {
-		h.writeError(w, http.StatusBadRequest, "Invalid email format", "INVALID_EMAIL_FORMAT")
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request, id int) {
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
 		return
 	}
 
-	// Validate role
-	if !validator.NonEmpty(req.Role) {
-		h.writeError(w, http.StatusBadRequest, "Role is required and cannot be empty", "INVALID_ROLE")
+	user := h.findUser(w, r, id)
+	if user == nil {
 		return
 	}
 
-	// Check if email already exists
-	if h.store.UserExistsByEmail(req.Email) {
-		h.writeError(w, http.StatusBadRequest, "Email already exists", "EMAIL_EXISTS")
+	if opts.View == viewCompact {
+		h.writeJSON(w, http.StatusOK, user.Summary())
 		return
 	}
+	h.writeJSON(w, http.StatusOK, user)
+}
 
-	user := h.store.CreateUser(req.Name, req.Email, req.Role)
+// findUser returns user id or, if there is none or the store fails to
+// read it, writes a 404 or 500 and returns nil.
+func (h *Handler) findUser(w http.ResponseWriter, r *http.Request, id int) *model.User {
+	user, err := h.store.GetUserByID(r.Context(), id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read user", "INTERNAL_ERROR")
+		return nil
+	}
+	if user == nil {
+		h.writeError(w, http.StatusNotFound, "User not found", "USER_NOT_FOUND")
+	}
+	return user
+}
 
-	h.InvalidateUserCaches()
+// handleUserTasks serves /api/users/{id}/tasks: the user's tasks, filtered
+// and ordered as /api/tasks lists them, with a summary of all of them by
+// status.
+func (h *Handler) handleUserTasks(w http.ResponseWriter, r *http.Request, id int) {
+	filter, ok := h.parseTaskFilter(w, r)
+	if !ok {
+		return
+	}
+	// The path names the assignee; ?userId= cannot name another
+	filter.userID = strconv.Itoa(id)
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+	if h.findUser(w, r, id) == nil {
+		return
+	}
 
-	h.writeJSON(w, http.StatusCreated, user)
+	cached, err := h.taskList(w, r, filter, opts.Location)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tasks", "INTERNAL_ERROR")
+		return
+	}
+	var list model.TasksResponse
+	if !fromCached(cached, &list) {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tasks", "INTERNAL_ERROR")
+		return
+	}
+	owned, err := h.store.GetTasks(r.Context(), "", filter.userID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tasks", "INTERNAL_ERROR")
+		return
+	}
+	summary := store.CountStats(nil, owned).Tasks
+	if opts.View == viewCompact {
+		compact, _ := tasksView(list, viewCompact).(model.CompactTasksResponse)
+		h.writeJSON(w, http.StatusOK, model.CompactUserTasksResponse{UserID: id, CompactTasksResponse: compact, Summary: summary})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, model.UserTasksResponse{UserID: id, TasksResponse: list, Summary: summary})
+}
+
+// handleUserPassword serves POST /api/users/{id}/password, under the
+// per-account attempt limit.
+func (h *Handler) handleUserPassword(w http.ResponseWriter, r *http.Request, id int) {
+	h.authRateLimit(strconv.Itoa(id), func(w http.ResponseWriter, r *http.Request) {
+		h.changePassword(w, r, id)
+	}).ServeHTTP(w, r)
 }
 
-func (h *Handler) handleUserByID(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (h *Handler) changePassword(w http.ResponseWriter, r *http.Request, id int) {
+	user := h.findUser(w, r, id)
+	if user == nil {
+		return
+	}
+
+	var req model.ChangePasswordRequest
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate new password
+	if !password.Valid(req.NewPassword) {
+		h.writeError(w, http.StatusBadRequest, password.ErrInvalidLength.Error(), "INVALID_PASSWORD")
+		return
+	}
+
+	// Require the current password when one is already set. Without one,
+	// nothing proves the caller is the user, so only an admin may set it.
+	if user.PasswordHash == "" && !h.isAdmin(r) {
+		h.writeError(w, http.StatusForbidden, "Only an admin may set the first password of a user", "FORBIDDEN")
+		return
+	}
+	if user.PasswordHash != "" && !password.Verify(user.PasswordHash, req.CurrentPassword) {
+		h.writeError(w, http.StatusUnauthorized, "Current password is incorrect", "INVALID_CREDENTIALS")
+		return
+	}
+
+	hash, err := password.Hash(req.NewPassword)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to process password", "INTERNAL_ERROR")
 		return
 	}
 
-	// Extract ID from path
-	path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-	id, err := strconv.Atoi(path)
+	updated, err := h.store.SetPasswordHash(r.Context(), id, hash)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		h.writeError(w, http.StatusInternalServerError, "Failed to update password", "INTERNAL_ERROR")
+		return
+	}
+	if !updated {
+		h.writeError(w, http.StatusNotFound, "User not found", "USER_NOT_FOUND")
 		return
 	}
+	// Password hashes are never recorded, only that the password changed
+	h.emit(r, events.Change{
+		Entity: events.EntityUser,
+		Action: events.ActionUpdate,
+		ID:     strconv.Itoa(id),
+		Fields: []model.FieldChange{{Field: "password"}},
+	})
 
-	user := h.store.GetUserByID(id)
+	h.writeJSON(w, http.StatusOK, model.SuccessResponse{
+		Success: true,
+		Message: "Password updated",
+	})
+}
+
+func (h *Handler) getPreferences(w http.ResponseWriter, r *http.Request, id int) {
+	user := h.findUser(w, r, id)
 	if user == nil {
-		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
+	h.writeJSON(w, http.StatusOK, user.Preferences)
+}
+
+func (h *Handler) updatePreferences(w http.ResponseWriter, r *http.Request, id int) {
+	user := h.findUser(w, r, id)
+	if user == nil {
+		return
+	}
+
+	var req model.UpdatePreferencesRequest
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	prefs := user.Preferences
+	if req.DigestOptOut != nil {
+		prefs.DigestOptOut = *req.DigestOptOut
+	}
+
+	// Read before updating: the store may return its own record
+	before := *user
+	updated, err := h.store.SetPreferences(r.Context(), id, prefs)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update preferences", "INTERNAL_ERROR")
+		return
+	}
+	if !updated {
+		h.writeError(w, http.StatusNotFound, "User not found", "USER_NOT_FOUND")
+		return
+	}
+	after := before
+	after.Preferences = prefs
+	h.emit(r, events.Change{Entity: events.EntityUser, Action: events.ActionUpdate, ID: strconv.Itoa(id), Before: before, After: after})
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	json.NewEncoder(w).Encode(user)
+	h.writeJSON(w, http.StatusOK, prefs)
 }
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-backend/internal/model"
+	"go-backend/internal/validator"
+)
+
+// fieldError describes why an input field was rejected.
+type fieldError validator.FieldError
+
+// fieldErrors converts errors from the validator package.
+func fieldErrors(errs []validator.FieldError) []*fieldError {
+	converted := make([]*fieldError, len(errs))
+	for i := range errs {
+		converted[i] = (*fieldError)(&errs[i])
+	}
+	return converted
+}
+
+// validateNewUser checks a user about to be created, including that the
+// email is not taken. Returns every failure, or nil if it is valid, and an
+// error if the store could not be checked.
+func (h *Handler) validateNewUser(ctx context.Context, req model.CreateUserRequest) ([]*fieldError, error) {
+	errs := fieldErrors(validator.Validate(req))
+	if validator.Email(req.Email) {
+		exists, err := h.store.UserExistsByEmail(ctx, req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			errs = append(errs, &fieldError{"email", "EMAIL_EXISTS", "Email already exists"})
+		}
+	}
+	return errs, nil
+}
+
+// validateNewTask checks a task about to be created, including that the
+// user and any project exist and, if reqLoc or the user's zone allows
+// reading it, the due date. Returns every failure, or nil if it is valid,
+// and an error if the store could not be checked.
+func (h *Handler) validateNewTask(ctx context.Context, req model.CreateTaskRequest, reqLoc *time.Location) ([]*fieldError, error) {
+	errs := fieldErrors(validator.Validate(req))
+	user, err := h.store.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		errs = append(errs, &fieldError{"userId", "INVALID_USER_ID", "User ID does not exist"})
+	}
+	if req.ProjectID != 0 && h.store.GetProjectByID(ctx, req.ProjectID) == nil {
+		errs = append(errs, &fieldError{"projectId", "INVALID_PROJECT_ID", "Project does not exist"})
+	}
+	if req.DueAt != "" {
+		if _, ferr := parseDueAt(req.DueAt, h.taskLocation(ctx, reqLoc, req.UserID)); ferr != nil {
+			errs = append(errs, ferr)
+		}
+	}
+	return errs, nil
+}
+
+// toModel converts the error for a response.
+func (e *fieldError) toModel() model.FieldError {
+	return model.FieldError{Field: e.Field, Code: e.Code, Message: e.Message}
+}
+
+func (h *Handler) handleUsersValidate(w http.ResponseWriter, r *http.Request) {
+	var items []model.CreateUserRequest
+	if !h.decodeBatch(w, r, &items) {
+		return
+	}
+
+	seen := make(map[string]int)
+	h.writeValidationReport(w, len(items), func(i int) ([]*fieldError, error) {
+		item := items[i]
+		errs, err := h.validateNewUser(r.Context(), item)
+		if err != nil {
+			return nil, err
+		}
+		for _, ferr := range errs {
+			if ferr.Field == "email" {
+				return errs, nil
+			}
+		}
+		email := validator.NormalizeEmail(item.Email)
+		if first, dup := seen[email]; dup {
+			errs = append(errs, &fieldError{"email", "EMAIL_EXISTS", fmt.Sprintf("Email duplicates item %d", first)})
+		} else {
+			seen[email] = i
+		}
+		return errs, nil
+	})
+}
+
+func (h *Handler) handleTasksValidate(w http.ResponseWriter, r *http.Request) {
+	var items []model.CreateTaskRequest
+	if !h.decodeBatch(w, r, &items) {
+		return
+	}
+
+	opts, ferr := requestOptions(r)
+	if ferr != nil {
+		h.writeError(w, http.StatusBadRequest, ferr.Message, ferr.Code)
+		return
+	}
+
+	h.writeValidationReport(w, len(items), func(i int) ([]*fieldError, error) {
+		return h.validateNewTask(r.Context(), items[i], opts.Location)
+	})
+}
+
+// decodeBatch decodes a POSTed JSON array into items, writing an error and
+// returning false if the request is not one.
+func (h *Handler) decodeBatch(w http.ResponseWriter, r *http.Request, items interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(items); err != nil {
+		if !writeBodyTooLarge(w, err) {
+			h.writeError(w, http.StatusBadRequest, "Request body must be a JSON array", "INVALID_JSON")
+		}
+		return false
+	}
+	return true
+}
+
+// writeValidationReport validates n items with validate, in order, and
+// writes the report, or a 500 if the store could not be checked. Nothing
+// is persisted: the report says what creating each item would do right
+// now.
+func (h *Handler) writeValidationReport(w http.ResponseWriter, n int, validate func(i int) ([]*fieldError, error)) {
+	report := model.ValidationReport{
+		Total: n,
+		Items: make([]model.ItemValidation, n),
+	}
+	for i := 0; i < n; i++ {
+		item := model.ItemValidation{Index: i, Valid: true}
+		ferrs, err := validate(i)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to validate items", "INTERNAL_ERROR")
+			return
+		}
+		for _, ferr := range ferrs {
+			item.Valid = false
+			item.Errors = append(item.Errors, ferr.toModel())
+		}
+		if !item.Valid {
+			report.Invalid++
+		}
+		report.Items[i] = item
+	}
+	report.Valid = report.Invalid == 0
+
+	h.writeJSON(w, http.StatusOK, report)
+}
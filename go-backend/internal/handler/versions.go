@@ -0,0 +1,28 @@
+package handler
+
+import "go-backend/internal/middleware"
+
+// defaultAPIVersion is served to clients without a pinned or requested version.
+const defaultAPIVersion = "1"
+
+// apiVersions maps each supported API version to the adapter that turns
+// the canonical (version 1) response into that version's shape.
+var apiVersions = map[string]middleware.VersionAdapter{
+	"1": nil,
+	"2": envelopeV2,
+}
+
+// ValidateVersionPins reports pins, as for Config.VersionPins, to API
+// versions that are not supported.
+func ValidateVersionPins(pins map[string]string) error {
+	return middleware.VersionConfig{Adapters: apiVersions, Pins: pins}.Validate()
+}
+
+// envelopeV2 wraps successful responses in a "data" envelope.
+// Error responses keep the canonical error format.
+func envelopeV2(status int, body interface{}) interface{} {
+	if status >= 400 {
+		return body
+	}
+	return map[string]interface{}{"data": body}
+}
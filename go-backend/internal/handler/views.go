@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"go-backend/internal/model"
+)
+
+// Response views selected with ?view=. The compact view of each model is
+// its Summary, so payloads shrink without endpoints of their own.
+const (
+	viewFull    = "full"
+	viewCompact = "compact"
+)
+
+// fromCached converts a cached response into v. Shared caches return
+// responses as JSON rather than the value that was stored.
+func fromCached(cached, v interface{}) bool {
+	data, err := json.Marshal(cached)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
+
+// tasksView returns a tasks list response, possibly cached, in view.
+func tasksView(response interface{}, view string) interface{} {
+	if view != viewCompact {
+		return response
+	}
+	var full model.TasksResponse
+	if !fromCached(response, &full) {
+		return response
+	}
+	compact := model.CompactTasksResponse{Tasks: make([]model.TaskSummary, len(full.Tasks)), Count: full.Count, Revision: full.Revision}
+	for i, task := range full.Tasks {
+		compact.Tasks[i] = task.Summary()
+	}
+	return compact
+}
+
+// usersView returns a users list response, possibly cached, in view.
+func usersView(response interface{}, view string) interface{} {
+	if view != viewCompact {
+		return response
+	}
+	var full model.UsersResponse
+	if !fromCached(response, &full) {
+		return response
+	}
+	compact := model.CompactUsersResponse{Users: make([]model.UserSummary, len(full.Users)), Count: full.Count}
+	for i, user := range full.Users {
+		compact.Users[i] = user.Summary()
+	}
+	return compact
+}
+
+// projectsView returns a projects list response in view.
+func projectsView(response model.ProjectsResponse, view string) interface{} {
+	if view != viewCompact {
+		return response
+	}
+	compact := model.CompactProjectsResponse{Projects: make([]model.ProjectSummary, len(response.Projects)), Count: response.Count}
+	for i, project := range response.Projects {
+		compact.Projects[i] = project.Summary()
+	}
+	return compact
+}
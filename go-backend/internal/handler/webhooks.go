@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-backend/internal/events"
+	"go-backend/internal/model"
+	"go-backend/internal/validator"
+	"go-backend/internal/webhook"
+)
+
+func (h *Handler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks := h.store.GetWebhooks(r.Context())
+	h.writeJSON(w, http.StatusOK, model.WebhooksResponse{Webhooks: webhooks, Count: len(webhooks)})
+}
+
+func (h *Handler) getWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	found := h.store.GetWebhookByID(r.Context(), id)
+	if found == nil {
+		h.writeError(w, http.StatusNotFound, "Webhook not found", "WEBHOOK_NOT_FOUND")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, found)
+}
+
+func (h *Handler) deleteWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	existing := h.store.GetWebhookByID(r.Context(), id)
+	if existing == nil {
+		h.writeError(w, http.StatusNotFound, "Webhook not found", "WEBHOOK_NOT_FOUND")
+		return
+	}
+	deleted, err := h.store.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete webhook", "INTERNAL_ERROR")
+		return
+	}
+	if !deleted {
+		h.writeError(w, http.StatusNotFound, "Webhook not found", "WEBHOOK_NOT_FOUND")
+		return
+	}
+	h.webhooks.Forget(id)
+	h.emit(r, events.Change{Entity: events.EntityWebhook, Action: events.ActionDelete, ID: strconv.Itoa(id), Before: *existing})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries serves GET /api/admin/webhooks/{id}/deliveries:
+// the webhook's recent deliveries.
+func (h *Handler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, id int) {
+	if h.store.GetWebhookByID(r.Context(), id) == nil {
+		h.writeError(w, http.StatusNotFound, "Webhook not found", "WEBHOOK_NOT_FOUND")
+		return
+	}
+	deliveries := h.webhooks.Deliveries(id)
+	h.writeJSON(w, http.StatusOK, model.WebhookDeliveriesResponse{Deliveries: deliveries, Count: len(deliveries)})
+}
+
+func (h *Handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateWebhookRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	ferrs := fieldErrors(validator.Validate(req))
+	if req.URL != "" && !webhookURL(req.URL) {
+		ferrs = append(ferrs, &fieldError{"url", "INVALID_URL", "Invalid url. Must be an absolute http or https URL"})
+	}
+	types := slices.Clone(req.Events)
+	slices.Sort(types)
+	types = slices.Compact(types)
+	if len(types) == 0 || !allValidEvents(types) {
+		ferrs = append(ferrs, &fieldError{"events", "INVALID_EVENTS", "Invalid events. Must be one or more of: " + strings.Join(webhook.Events(), ", ")})
+	}
+	if len(ferrs) > 0 {
+		h.writeFieldErrors(w, ferrs)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+	created, err := h.store.CreateWebhook(r.Context(), model.Webhook{URL: req.URL, Events: types, Secret: secret})
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create webhook", "INTERNAL_ERROR")
+		return
+	}
+	h.emit(r, events.Change{Entity: events.EntityWebhook, Action: events.ActionCreate, ID: strconv.Itoa(created.ID), After: created})
+	h.writeJSON(w, http.StatusCreated, model.WebhookResponse{Webhook: created, Secret: secret})
+}
+
+// webhookURL reports whether s is an absolute http or https URL.
+func webhookURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// allValidEvents reports whether webhooks can subscribe to all of events.
+func allValidEvents(events []string) bool {
+	for _, event := range events {
+		if !webhook.ValidEvent(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// newWebhookSecret returns a random secret for signing deliveries.
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,101 @@
+// Package hashid encodes integer IDs as short opaque strings, so the IDs
+// clients see do not reveal how many records there are or let clients
+// find others by counting.
+package hashid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+)
+
+// Length is the length of every encoded ID.
+const Length = 11
+
+// alphabet is the base62 digits encoded IDs are written in.
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// rounds is the number of Feistel rounds IDs are mixed with.
+const rounds = 4
+
+// maxID bounds the IDs that can be encoded: those JSON clients can hold
+// exactly. A decoded value above it is not an encoded ID, which catches
+// most mistyped or made-up strings.
+const maxID = 1<<53 - 1
+
+// Codec encodes IDs with a secret key: IDs encoded with one key do not
+// decode with another. Every replica of a deployment needs the same key,
+// and changing it breaks the IDs clients have kept.
+type Codec struct {
+	key []byte
+}
+
+// New returns a codec keyed with secret.
+func New(secret string) *Codec {
+	return &Codec{key: []byte(secret)}
+}
+
+// Encode returns the encoded form of id, which must be between 0 and
+// 2^53-1.
+func (c *Codec) Encode(id int) string {
+	v := c.permute(uint64(id), false)
+	var b [Length]byte
+	for i := Length - 1; i >= 0; i-- {
+		b[i] = alphabet[v%62]
+		v /= 62
+	}
+	return string(b[:])
+}
+
+// Decode returns the ID encoded as s, or false if s is not an ID encoded
+// with this codec's key.
+func (c *Codec) Decode(s string) (int, bool) {
+	if len(s) != Length {
+		return 0, false
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(alphabet, s[i])
+		if digit < 0 {
+			return 0, false
+		}
+		// 62^11 exceeds 2^64, so the largest strings overflow
+		next := v*62 + uint64(digit)
+		if next/62 != v {
+			return 0, false
+		}
+		v = next
+	}
+	id := c.permute(v, true)
+	if id > maxID {
+		return 0, false
+	}
+	return int(id), true
+}
+
+// permute mixes v with a Feistel network over its two 32-bit halves, or
+// undoes the mixing if inverse is set.
+func (c *Codec) permute(v uint64, inverse bool) uint64 {
+	left, right := uint32(v>>32), uint32(v)
+	for i := 0; i < rounds; i++ {
+		round := i
+		if inverse {
+			round = rounds - 1 - i
+			left, right = right^c.round(round, left), left
+			continue
+		}
+		left, right = right, left^c.round(round, right)
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+// round is the keyed round function of the Feistel network.
+func (c *Codec) round(round int, half uint32) uint32 {
+	mac := hmac.New(sha256.New, c.key)
+	var in [5]byte
+	in[0] = byte(round)
+	binary.BigEndian.PutUint32(in[1:], half)
+	mac.Write(in[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package hashid
+
+import "testing"
+
+func TestCodec_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	c := New("secret")
+	seen := make(map[string]bool)
+	for _, id := range []int{0, 1, 2, 3, 100, 12345, 1<<31 + 7, maxID} {
+		encoded := c.Encode(id)
+		if len(encoded) != Length {
+			t.Errorf("expected %d encoded as %d characters, got %q", id, Length, encoded)
+		}
+		if seen[encoded] {
+			t.Errorf("expected %d encoded uniquely, got %q again", id, encoded)
+		}
+		seen[encoded] = true
+		if decoded, ok := c.Decode(encoded); !ok || decoded != id {
+			t.Errorf("expected %q to decode to %d, got %d, %v", encoded, id, decoded, ok)
+		}
+	}
+
+	// Neighbouring IDs look unrelated
+	if a, b := c.Encode(1), c.Encode(2); a[:4] == b[:4] {
+		t.Errorf("expected IDs 1 and 2 encoded unlike each other, got %q and %q", a, b)
+	}
+}
+
+func TestCodec_Decode_Rejects(t *testing.T) {
+	t.Parallel()
+
+	c := New("secret")
+	encoded := c.Encode(42)
+	for _, s := range []string{
+		"",
+		"42",
+		"export",
+		encoded[:Length-1],
+		encoded + "0",
+		encoded[:Length-1] + "-",
+		"zzzzzzzzzzz", // above 2^64
+	} {
+		if id, ok := c.Decode(s); ok {
+			t.Errorf("expected %q rejected, got %d", s, id)
+		}
+	}
+
+	// Another key's IDs decode to other values, mostly out of range
+	other := New("other")
+	if id, ok := other.Decode(encoded); ok && id == 42 {
+		t.Errorf("expected an ID encoded with another key not to decode the same")
+	}
+	rejected := 0
+	for id := 1; id <= 100; id++ {
+		if _, ok := other.Decode(c.Encode(id)); !ok {
+			rejected++
+		}
+	}
+	if rejected < 95 {
+		t.Errorf("expected almost every ID encoded with another key rejected, got %d of 100", rejected)
+	}
+}
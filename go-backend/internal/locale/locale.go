@@ -0,0 +1,162 @@
+// Package locale provides display strings and formatting hints for the
+// languages the API supports, negotiated from the Accept-Language header.
+// Locales only add presentation: canonical values such as task statuses
+// are the same in every language.
+package locale
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the tag of the locale used when none of the requested
+// languages is supported.
+const Default = "en"
+
+// Locale holds the display strings and formatting hints for a language.
+// DateFormat and TimeFormat are Unicode (CLDR) patterns, as understood by
+// Intl and most date libraries. ListSeparator separates the fields of CSV
+// files, and DecimalSeparator the fractions of numbers, as spreadsheets
+// set to the language expect.
+type Locale struct {
+	Tag              string
+	DateFormat       string
+	TimeFormat       string
+	ListSeparator    rune
+	DecimalSeparator string
+	statusLabels     map[string]string
+}
+
+var locales = map[string]Locale{
+	"en": {
+		Tag:              "en",
+		DateFormat:       "MM/dd/yyyy",
+		TimeFormat:       "h:mm a",
+		ListSeparator:    ',',
+		DecimalSeparator: ".",
+		statusLabels: map[string]string{
+			"pending":     "Pending",
+			"in-progress": "In progress",
+			"completed":   "Completed",
+		},
+	},
+	"es": {
+		Tag:              "es",
+		DateFormat:       "dd/MM/yyyy",
+		TimeFormat:       "H:mm",
+		ListSeparator:    ';',
+		DecimalSeparator: ",",
+		statusLabels: map[string]string{
+			"pending":     "Pendiente",
+			"in-progress": "En curso",
+			"completed":   "Completada",
+		},
+	},
+	"de": {
+		Tag:              "de",
+		DateFormat:       "dd.MM.yyyy",
+		TimeFormat:       "HH:mm",
+		ListSeparator:    ';',
+		DecimalSeparator: ",",
+		statusLabels: map[string]string{
+			"pending":     "Ausstehend",
+			"in-progress": "In Bearbeitung",
+			"completed":   "Abgeschlossen",
+		},
+	},
+	"fr": {
+		Tag:              "fr",
+		DateFormat:       "dd/MM/yyyy",
+		TimeFormat:       "HH:mm",
+		ListSeparator:    ';',
+		DecimalSeparator: ",",
+		statusLabels: map[string]string{
+			"pending":     "En attente",
+			"in-progress": "En cours",
+			"completed":   "Terminée",
+		},
+	},
+}
+
+// Supported returns the tags of the supported locales, sorted.
+func Supported() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Get returns the locale for tag, or the default locale if tag is not
+// supported.
+func Get(tag string) Locale {
+	if l, ok := locales[tag]; ok {
+		return l
+	}
+	return locales[Default]
+}
+
+// StatusLabel returns the display name of a task status, or the status
+// itself if it has none.
+func (l Locale) StatusLabel(status string) string {
+	if label, ok := l.statusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// DateTimeFormat returns the pattern of a date with its time of day.
+func (l Locale) DateTimeFormat() string {
+	return l.DateFormat + " " + l.TimeFormat
+}
+
+// StatusLabels returns the display name of each of statuses.
+func (l Locale) StatusLabels(statuses []string) map[string]string {
+	labels := make(map[string]string, len(statuses))
+	for _, status := range statuses {
+		labels[status] = l.StatusLabel(status)
+	}
+	return labels
+}
+
+// Negotiate picks the best supported locale for an Accept-Language header
+// value. Languages are tried in order of preference; a regional tag such
+// as "de-AT" matches its language. Returns the default locale if nothing
+// matches.
+func Negotiate(header string) Locale {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{strings.ToLower(tag), q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.tag == "*" {
+			break
+		}
+		lang, _, _ := strings.Cut(c.tag, "-")
+		if l, ok := locales[lang]; ok {
+			return l
+		}
+	}
+	return locales[Default]
+}
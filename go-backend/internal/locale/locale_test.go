@@ -0,0 +1,93 @@
+package locale
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/internal/validator"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"empty", "", "en"},
+		{"exact", "de", "de"},
+		{"regional", "fr-CA", "fr"},
+		{"case insensitive", "ES-mx", "es"},
+		{"first supported", "ja, es;q=0.8, de;q=0.5", "es"},
+		{"ordered by quality", "de;q=0.5, fr;q=0.9", "fr"},
+		{"zero quality excluded", "de;q=0, fr;q=0.1", "fr"},
+		{"wildcard", "ja, *", "en"},
+		{"unsupported", "ja, zh-CN", "en"},
+		{"malformed quality skipped", "de;q=high, es", "es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.header).Tag; got != tt.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocale_StatusLabel(t *testing.T) {
+	de := Get("de")
+	if got := de.StatusLabel("in-progress"); got != "In Bearbeitung" {
+		t.Errorf("expected German label, got %q", got)
+	}
+	if got := de.StatusLabel("archived"); got != "archived" {
+		t.Errorf("expected unknown status to fall back to itself, got %q", got)
+	}
+}
+
+func TestLocale_LabelsCoverEveryStatus(t *testing.T) {
+	for _, tag := range Supported() {
+		l := Get(tag)
+		for _, status := range validator.Statuses() {
+			if _, ok := l.statusLabels[status]; !ok {
+				t.Errorf("locale %q has no label for %q", tag, status)
+			}
+		}
+		if l.DateFormat == "" || l.TimeFormat == "" || l.ListSeparator == 0 || l.DecimalSeparator == "" {
+			t.Errorf("locale %q is missing format hints", tag)
+		}
+		if _, err := ParsePattern(l.DateTimeFormat()); err != nil {
+			t.Errorf("locale %q has an unsupported pattern: %v", tag, err)
+		}
+	}
+}
+
+func TestPattern_Format(t *testing.T) {
+	at := time.Date(2026, 3, 9, 7, 5, 3, 0, time.UTC)
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"MM/dd/yyyy h:mm a", "03/09/2026 7:05 AM"},
+		{"dd.MM.yyyy HH:mm", "09.03.2026 07:05"},
+		{"d/M/yy H:mm:ss", "9/3/26 7:05:03"},
+		{"yyyy-MM-dd'T'HH:mm", "2026-03-09T07:05"},
+		{"hh 'o''clock'", "07 o'clock"},
+		{"h''mm", "7'05"},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q): %v", tt.pattern, err)
+		}
+		if got := p.Format(at); got != tt.want {
+			t.Errorf("Format with %q = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"EEEE", "MMMM", "yyy", "dd 'open"} {
+		if _, err := ParsePattern(pattern); err == nil {
+			t.Errorf("ParsePattern(%q): expected an error", pattern)
+		}
+	}
+}
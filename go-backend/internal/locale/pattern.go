@@ -0,0 +1,121 @@
+package locale
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pattern is a parsed Unicode (CLDR) date pattern, such as "dd.MM.yyyy
+// HH:mm". It supports the fields the locales use: y, M, d, H, h, m, s
+// and a. Text in single quotes, and anything that is not a letter, is
+// written as it is; ” is a single quote.
+type Pattern struct {
+	fields []patternField
+}
+
+// patternField is a run of one pattern letter, or literal text.
+type patternField struct {
+	letter  byte
+	width   int
+	literal string
+}
+
+// maxWidth is the longest run of each supported pattern letter.
+var maxWidth = map[byte]int{'y': 4, 'M': 2, 'd': 2, 'H': 2, 'h': 2, 'm': 2, 's': 2, 'a': 1}
+
+// ParsePattern parses a date pattern, rejecting unsupported fields.
+func ParsePattern(pattern string) (Pattern, error) {
+	var p Pattern
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "''"):
+			p.fields = append(p.fields, patternField{literal: "'"})
+			i += 2
+		case c == '\'':
+			// Quoted text runs to the next single quote; '' in it is a quote
+			var literal strings.Builder
+			j := i + 1
+			for ; j < len(pattern); j++ {
+				if pattern[j] != '\'' {
+					literal.WriteByte(pattern[j])
+				} else if strings.HasPrefix(pattern[j:], "''") {
+					literal.WriteByte('\'')
+					j++
+				} else {
+					break
+				}
+			}
+			if j == len(pattern) {
+				return Pattern{}, fmt.Errorf("unterminated quote in %q", pattern)
+			}
+			p.fields = append(p.fields, patternField{literal: literal.String()})
+			i = j + 1
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			n := 1
+			for i+n < len(pattern) && pattern[i+n] == c {
+				n++
+			}
+			if n > maxWidth[c] || c == 'y' && n == 3 {
+				return Pattern{}, fmt.Errorf("unsupported field %q in %q", pattern[i:i+n], pattern)
+			}
+			p.fields = append(p.fields, patternField{letter: c, width: n})
+			i += n
+		default:
+			p.fields = append(p.fields, patternField{literal: string(c)})
+			i++
+		}
+	}
+	return p, nil
+}
+
+// Format returns t written in the pattern.
+func (p Pattern) Format(t time.Time) string {
+	var b strings.Builder
+	for _, f := range p.fields {
+		switch f.letter {
+		case 0:
+			b.WriteString(f.literal)
+		case 'y':
+			if f.width == 2 {
+				pad(&b, t.Year()%100, 2)
+			} else {
+				pad(&b, t.Year(), f.width)
+			}
+		case 'M':
+			pad(&b, int(t.Month()), f.width)
+		case 'd':
+			pad(&b, t.Day(), f.width)
+		case 'H':
+			pad(&b, t.Hour(), f.width)
+		case 'h':
+			hour := t.Hour() % 12
+			if hour == 0 {
+				hour = 12
+			}
+			pad(&b, hour, f.width)
+		case 'm':
+			pad(&b, t.Minute(), f.width)
+		case 's':
+			pad(&b, t.Second(), f.width)
+		case 'a':
+			if t.Hour() < 12 {
+				b.WriteString("AM")
+			} else {
+				b.WriteString("PM")
+			}
+		}
+	}
+	return b.String()
+}
+
+// pad writes n with leading zeros to at least width digits.
+func pad(b *strings.Builder, n, width int) {
+	s := strconv.Itoa(n)
+	for i := len(s); i < width; i++ {
+		b.WriteByte('0')
+	}
+	b.WriteString(s)
+}
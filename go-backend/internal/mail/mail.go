@@ -0,0 +1,149 @@
+// Package mail sends plain-text email through an SMTP relay, or logs it
+// when no relay is configured.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidHeader is returned for a message whose address or subject
+// contains a line break, which could inject extra headers.
+var ErrInvalidHeader = errors.New("mail header contains a line break")
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer logs messages instead of sending them, for development and
+// deployments without a relay.
+type LogMailer struct{}
+
+// Send logs the recipient and subject of msg.
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s: %s (%d bytes, not sent: no SMTP relay configured)", msg.To, msg.Subject, len(msg.Body))
+	return nil
+}
+
+// SMTPConfig configures an SMTPMailer.
+type SMTPConfig struct {
+	// Addr is the relay's host:port.
+	Addr string
+	// From is the sender address.
+	From string
+	// Username and Password authenticate with PLAIN auth when Username is
+	// set. net/smtp only sends them over TLS or to localhost.
+	Username string
+	Password string
+}
+
+// SMTPMailer sends messages through an SMTP relay, upgrading to TLS when
+// the relay offers STARTTLS.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP returns a mailer for the relay in cfg.
+func NewSMTP(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send delivers msg, giving up when ctx is done.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(m.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", m.cfg.Addr, err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP relay: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("SMTP relay rejected sender: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("SMTP relay rejected recipient: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(msg.format(m.cfg.From, time.Now())); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return c.Quit()
+}
+
+// validate rejects messages that could inject headers.
+func (msg Message) validate() error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	return nil
+}
+
+// format renders msg as an RFC 5322 message from the sender, with CRLF
+// line endings and a UTF-8 subject.
+func (msg Message) format(from string, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessage_Format(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{To: "jane@example.com", Subject: "Résumé", Body: "one\ntwo\r\nthree"}
+	got := string(msg.format("tasks@example.com", time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)))
+
+	for _, want := range []string{
+		"From: tasks@example.com\r\n",
+		"To: jane@example.com\r\n",
+		"Subject: =?utf-8?q?R=C3=A9sum=C3=A9?=\r\n",
+		"Date: Mon, 09 Mar 2026 08:00:00 +0000\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n\r\none\r\ntwo\r\nthree",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected message to contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestSend_RejectsHeaderInjection(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{To: "jane@example.com\r\nBcc: all@example.com", Subject: "Hi", Body: "Hello"}
+	mailers := map[string]Mailer{
+		"log":  LogMailer{},
+		"smtp": NewSMTP(SMTPConfig{Addr: "localhost:25", From: "tasks@example.com"}),
+	}
+	for name, m := range mailers {
+		if err := m.Send(context.Background(), msg); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: expected ErrInvalidHeader, got %v", name, err)
+		}
+	}
+}
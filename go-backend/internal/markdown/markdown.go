@@ -0,0 +1,280 @@
+// Package markdown renders the Markdown of task descriptions to HTML that
+// is safe to insert into a page.
+//
+// It supports a common subset of Markdown: paragraphs, ATX headings (#),
+// block quotes, flat bulleted and numbered lists, fenced code blocks,
+// horizontal rules, emphasis, strong emphasis, code spans, links and
+// backslash escapes. Output is safe by construction rather than by
+// filtering: all text is escaped, raw HTML in the source is shown as text,
+// only the elements above are emitted, and links are kept only for http,
+// https and mailto URLs.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingLine  = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleLine     = regexp.MustCompile(`^ {0,3}([-*_])(?:[ \t]*[-*_]){2,}[ \t]*$`)
+	bulletItem   = regexp.MustCompile(`^ {0,3}[-*+][ \t]+(.*)$`)
+	numberedItem = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	fenceLine    = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	quoteLine    = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+)
+
+// ToHTML renders Markdown source to sanitized HTML.
+func ToHTML(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(source, "\n"))
+	return b.String()
+}
+
+// renderBlocks renders lines as a sequence of blocks.
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fenceLine.MatchString(line):
+			i = renderFence(b, lines, i)
+		case headingLine.MatchString(strings.TrimLeft(line, " ")):
+			m := headingLine.FindStringSubmatch(strings.TrimLeft(line, " "))
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+		case ruleLine.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case quoteLine.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case bulletItem.MatchString(line):
+			i = renderList(b, lines, i, bulletItem, "ul")
+		case numberedItem.MatchString(line):
+			i = renderList(b, lines, i, numberedItem, "ol")
+		default:
+			i = renderParagraph(b, lines, i)
+		}
+	}
+}
+
+// startsBlock reports whether line starts a block other than a paragraph,
+// which ends a paragraph or list item before it.
+func startsBlock(line string) bool {
+	return strings.TrimSpace(line) == "" || fenceLine.MatchString(line) ||
+		headingLine.MatchString(strings.TrimLeft(line, " ")) || ruleLine.MatchString(line) ||
+		quoteLine.MatchString(line) || bulletItem.MatchString(line) || numberedItem.MatchString(line)
+}
+
+// renderFence renders the fenced code block starting at lines[i] and
+// returns the index of the line after it. An unclosed fence runs to the
+// end of the source.
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	fence := fenceLine.FindStringSubmatch(lines[i])[1]
+	var code []string
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimLeft(lines[i], " "), fence) {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+	b.WriteString("<pre><code>")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderList renders the list of items matching item starting at lines[i]
+// and returns the index of the line after it. Lines that start no block
+// continue the item before them.
+func renderList(b *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	open := "<" + tag + ">"
+	if tag == "ol" {
+		if start := numberedItem.FindStringSubmatch(lines[i])[1]; strings.TrimLeft(start, "0") != "1" {
+			n, _ := strconv.Atoi(start)
+			open = `<ol start="` + strconv.Itoa(n) + `">`
+		}
+	}
+	b.WriteString(open + "\n")
+	for i < len(lines) && item.MatchString(lines[i]) {
+		m := item.FindStringSubmatch(lines[i])
+		text := []string{m[len(m)-1]}
+		for i++; i < len(lines) && !startsBlock(lines[i]); i++ {
+			text = append(text, strings.TrimSpace(lines[i]))
+		}
+		b.WriteString("<li>" + renderInline(strings.Join(text, "\n")) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderParagraph renders the paragraph starting at lines[i] and returns
+// the index of the line after it.
+func renderParagraph(b *strings.Builder, lines []string, i int) int {
+	text := []string{strings.TrimSpace(lines[i])}
+	for i++; i < len(lines) && !startsBlock(lines[i]); i++ {
+		text = append(text, strings.TrimSpace(lines[i]))
+	}
+	b.WriteString("<p>" + renderInline(strings.Join(text, "\n")) + "</p>\n")
+	return i
+}
+
+// renderInline renders the inline Markdown of text: escapes, code spans,
+// links and emphasis. Everything else is escaped text.
+func renderInline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()#+-.!>~", text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if n, out := codeSpan(text[i:]); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		case c == '[':
+			if n, out := link(text[i:]); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		case c == '*' || c == '_' && (i == 0 || !isWordByte(text[i-1])):
+			if n, out := emphasis(text[i:]); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		}
+		if c == '\n' {
+			b.WriteByte('\n')
+		} else {
+			b.WriteString(html.EscapeString(text[i : i+1]))
+		}
+		i++
+	}
+	return b.String()
+}
+
+// codeSpan renders the code span at the start of text, returning how much
+// of text it used, or 0 if text does not start one.
+func codeSpan(text string) (int, string) {
+	ticks := len(text) - len(strings.TrimLeft(text, "`"))
+	delim := text[:ticks]
+	end := strings.Index(text[ticks:], delim)
+	if end < 0 {
+		return 0, ""
+	}
+	code := strings.TrimSpace(strings.ReplaceAll(text[ticks:ticks+end], "\n", " "))
+	return ticks + end + ticks, "<code>" + html.EscapeString(code) + "</code>"
+}
+
+// link renders the [text](url) link at the start of text, returning how
+// much of text it used, or 0 if text does not start one. Links to URLs
+// that are not safe keep their text only.
+func link(text string) (int, string) {
+	closeText := matching(text, '[', ']')
+	if closeText < 0 || closeText+1 >= len(text) || text[closeText+1] != '(' {
+		return 0, ""
+	}
+	closeURL := strings.IndexByte(text[closeText+2:], ')')
+	if closeURL < 0 {
+		return 0, ""
+	}
+	label := renderInline(text[1:closeText])
+	target := strings.TrimSpace(text[closeText+2 : closeText+2+closeURL])
+	n := closeText + 2 + closeURL + 1
+	if !safeURL(target) {
+		return n, label
+	}
+	return n, `<a href="` + html.EscapeString(target) + `" rel="nofollow noopener noreferrer">` + label + "</a>"
+}
+
+// matching returns the index of the bracket closing the one text starts
+// with, allowing nested pairs, or -1.
+func matching(text string, open, close byte) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// safeURL reports whether a link may point to target: an absolute http,
+// https or mailto URL.
+func safeURL(target string) bool {
+	if strings.ContainsAny(target, " \t\n") {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
+
+// isWordByte reports whether c is an ASCII letter or digit, or part of a
+// multibyte character. Underscores inside words, as in snake_case, are
+// not emphasis.
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// emphasis renders the emphasis or strong emphasis at the start of text,
+// returning how much of text it used, or 0 if text does not start one.
+// The delimiter must be followed by, and its closer preceded by, a
+// non-space.
+func emphasis(text string) (int, string) {
+	for _, delim := range []string{text[:1] + text[:1], text[:1]} {
+		if !strings.HasPrefix(text, delim) || len(text) <= len(delim) || text[len(delim)] == ' ' {
+			continue
+		}
+		end := strings.Index(text[len(delim)+1:], delim)
+		if end < 0 {
+			continue
+		}
+		end += len(delim) + 1
+		if text[end-1] == ' ' {
+			continue
+		}
+		tag := "em"
+		if len(delim) == 2 {
+			tag = "strong"
+		}
+		return end + len(delim), "<" + tag + ">" + renderInline(text[len(delim):end]) + "</" + tag + ">"
+	}
+	return 0, ""
+}
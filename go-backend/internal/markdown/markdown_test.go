@@ -0,0 +1,39 @@
+package markdown
+
+import "testing"
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"headings", "# Title\n### Sub ###\n#nospace", "<h1>Title</h1>\n<h3>Sub</h3>\n<p>#nospace</p>\n"},
+		{"emphasis", "*em* **strong** __also__ snake_case_name", "<p><em>em</em> <strong>strong</strong> <strong>also</strong> snake_case_name</p>\n"},
+		{"code span", "run `a < b` now", "<p>run <code>a &lt; b</code> now</p>\n"},
+		{"fenced code", "```go\nif a < b {}\n```\nafter", "<pre><code>if a &lt; b {}\n</code></pre>\n<p>after</p>\n"},
+		{"bullets", "- one\n- two\n  more", "<ul>\n<li>one</li>\n<li>two\nmore</li>\n</ul>\n"},
+		{"numbered", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"quote", "> quoted *text*\n> - item", "<blockquote>\n<p>quoted <em>text</em></p>\n<ul>\n<li>item</li>\n</ul>\n</blockquote>\n"},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>\n"},
+		{"link", "see [the docs](https://example.com/a?b=1&c=2)", `<p>see <a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">the docs</a></p>` + "\n"},
+		{"mailto", "[mail](mailto:a@example.com)", `<p><a href="mailto:a@example.com" rel="nofollow noopener noreferrer">mail</a></p>` + "\n"},
+		{"escapes", `\*not em\* 1\. no list`, "<p>*not em* 1. no list</p>\n"},
+		{"unclosed", "*open [text `tick", "<p>*open [text `tick</p>\n"},
+
+		{"raw HTML", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click)</p>\n"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>\n"},
+		{"relative link", "[x](/admin)", "<p>x</p>\n"},
+		{"quote in href", `[x](https://example.com/"onmouseover="alert(1))`, `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1" rel="nofollow noopener noreferrer">x</a>)</p>` + "\n"},
+		{"HTML in code", "`<img src=x onerror=alert(1)>`", "<p><code>&lt;img src=x onerror=alert(1)&gt;</code></p>\n"},
+		{"HTML in heading", "# <b>bold</b>", "<h1>&lt;b&gt;bold&lt;/b&gt;</h1>\n"},
+	}
+
+	for _, tt := range tests {
+		if got := ToHTML(tt.source); got != tt.want {
+			t.Errorf("%s: ToHTML(%q) =\n%q\nwant\n%q", tt.name, tt.source, got, tt.want)
+		}
+	}
+}
@@ -20,10 +20,7 @@ func Auth(validKeys []string) func(http.Handler) http.Handler {
 			apiKey := strings.TrimSpace(r.Header.Get(apiKeyHeader))
 
 			if apiKey == "" || !keyMap[apiKey] {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(`{"success":false,"error":"Invalid or missing API key","code":"UNAUTHORIZED"}`))
+				writeError(w, http.StatusUnauthorized, "Invalid or missing API key", "UNAUTHORIZED")
 				return
 			}
 
@@ -31,3 +28,34 @@ func Auth(validKeys []string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// AdminAuth lets through only requests carrying one of adminKeys in
+// X-API-Key. Requests with one of apiKeys, which may call the rest of the
+// API, get 403 with code FORBIDDEN; those with no key or an unknown one
+// get 401 with code UNAUTHORIZED. With no adminKeys, every request is
+// refused.
+func AdminAuth(adminKeys, apiKeys []string) func(http.Handler) http.Handler {
+	admins := make(map[string]bool)
+	for _, key := range adminKeys {
+		admins[key] = true
+	}
+	others := make(map[string]bool)
+	for _, key := range apiKeys {
+		others[key] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := strings.TrimSpace(r.Header.Get(apiKeyHeader))
+
+			switch {
+			case apiKey != "" && admins[apiKey]:
+				next.ServeHTTP(w, r)
+			case apiKey != "" && others[apiKey]:
+				writeError(w, http.StatusForbidden, "This API key may not call admin endpoints", "FORBIDDEN")
+			default:
+				writeError(w, http.StatusUnauthorized, "Invalid or missing admin API key", "UNAUTHORIZED")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	middlewaretest.Run(t, Auth([]string{"key-1", "key-2"}), []middlewaretest.Case{
+		{
+			Name:           "valid key",
+			Headers:        map[string]string{"X-API-Key": "key-1"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+		{
+			Name:           "valid key with whitespace",
+			Headers:        map[string]string{"X-API-Key": "  key-2 "},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+		{
+			Name:           "missing key",
+			WantStatus:     http.StatusUnauthorized,
+			WantNextCalled: false,
+			WantHeaders:    map[string]string{"Content-Type": "application/json"},
+			CheckResponse:  expectErrorCode("UNAUTHORIZED"),
+		},
+		{
+			Name:           "unknown key",
+			Headers:        map[string]string{"X-API-Key": "nope"},
+			WantStatus:     http.StatusUnauthorized,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("UNAUTHORIZED"),
+		},
+	})
+}
+
+func TestAdminAuth(t *testing.T) {
+	t.Parallel()
+
+	middlewaretest.Run(t, AdminAuth([]string{"admin-key"}, []string{"key-1"}), []middlewaretest.Case{
+		{
+			Name:           "admin key",
+			Headers:        map[string]string{"X-API-Key": "admin-key"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+		{
+			Name:           "ordinary key",
+			Headers:        map[string]string{"X-API-Key": "key-1"},
+			WantStatus:     http.StatusForbidden,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("FORBIDDEN"),
+		},
+		{
+			Name:           "missing key",
+			WantStatus:     http.StatusUnauthorized,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("UNAUTHORIZED"),
+		},
+		{
+			Name:           "unknown key",
+			Headers:        map[string]string{"X-API-Key": "nope"},
+			WantStatus:     http.StatusUnauthorized,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("UNAUTHORIZED"),
+		},
+	})
+
+	middlewaretest.Run(t, AdminAuth(nil, nil), []middlewaretest.Case{
+		{
+			Name:           "no admin keys",
+			Headers:        map[string]string{"X-API-Key": ""},
+			WantStatus:     http.StatusUnauthorized,
+			WantNextCalled: false,
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// LimitBody rejects request bodies larger than limit(r) bytes with 413
+// Request Entity Too Large. A body that declares its size in
+// Content-Length is rejected before it is read. Any other body is cut off
+// by http.MaxBytesReader once it passes the limit, so reads fail with
+// *http.MaxBytesError, which handlers should report with BodyTooLarge.
+func LimitBody(limit func(*http.Request) int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := limit(r)
+			if r.ContentLength > n {
+				// The body is not read, so the connection cannot be reused
+				w.Header().Set("Connection", "close")
+				BodyTooLarge(w, n)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BodyTooLarge writes the 413 response for a body over limit bytes.
+func BodyTooLarge(w http.ResponseWriter, limit int64) {
+	writeError(w, http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Request body exceeds the limit of %d bytes", limit), "REQUEST_TOO_LARGE")
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	t.Parallel()
+
+	limit := func(r *http.Request) int64 {
+		if r.URL.Path == "/upload" {
+			return 100
+		}
+		return 10
+	}
+	mw := LimitBody(limit)
+
+	tests := []struct {
+		name       string
+		path       string
+		size       int
+		wantStatus int
+	}{
+		{"within limit", "/", 10, http.StatusOK},
+		{"over limit", "/", 11, http.StatusRequestEntityTooLarge},
+		{"route with a larger limit", "/upload", 50, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true })
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(strings.Repeat("x", tt.size)))
+			rr := httptest.NewRecorder()
+			mw(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("expected next handler called=%v, got %v", tt.wantStatus == http.StatusOK, reached)
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge {
+				expectErrorCode("REQUEST_TOO_LARGE")(t, rr)
+			}
+		})
+	}
+}
+
+func TestLimitBody_UndeclaredSize(t *testing.T) {
+	t.Parallel()
+
+	var readErr error
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	})
+
+	// A chunked body has no Content-Length, so it is only stopped as it is read
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 11)))
+	req.ContentLength = -1
+	LimitBody(func(*http.Request) int64 { return 10 })(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	var tooLarge *http.MaxBytesError
+	if !errors.As(readErr, &tooLarge) || tooLarge.Limit != 10 {
+		t.Errorf("expected reading past the limit to fail with MaxBytesError, got %v", readErr)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler, adding behavior before or after it.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is a list of middleware, outermost first: the first one sees a
+// request before the others and its response after them. Middleware
+// that is configured off is simply left out of the chain.
+type Chain []Middleware
+
+// Append returns the chain with mw added inside the middleware already in
+// it. c itself is not changed, so a chain can be extended in different
+// ways.
+func (c Chain) Append(mw ...Middleware) Chain {
+	chain := make(Chain, 0, len(c)+len(mw))
+	chain = append(chain, c...)
+	return append(chain, mw...)
+}
+
+// Then returns h wrapped in the middleware of the chain.
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+// Unless applies mw to requests for which skip returns false, and passes
+// the others straight on, e.g. to exempt health checks from Auth.
+func Unless(skip func(r *http.Request) bool, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
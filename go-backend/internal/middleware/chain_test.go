@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	named := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+
+	base := Chain{named("outer")}
+	a := base.Append(named("a"))
+	b := base.Append(named("b"), Unless(func(r *http.Request) bool { return r.URL.Path == "/health" }, named("auth")))
+
+	a.Then(final).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(calls, ","); got != "outer,a,handler" {
+		t.Errorf("expected outer,a,handler, got %s", got)
+	}
+
+	calls = nil
+	b.Then(final).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(calls, ","); got != "outer,b,auth,handler" {
+		t.Errorf("expected outer,b,auth,handler, got %s", got)
+	}
+
+	calls = nil
+	b.Then(final).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if got := strings.Join(calls, ","); got != "outer,b,handler" {
+		t.Errorf("expected skipped middleware to be passed by, got %s", got)
+	}
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the media types of responses worth compressing.
+// Event streams are left alone, as some proxies hold compressed events
+// back, and so are files, which are often compressed already.
+var compressibleTypes = map[string]bool{
+	"application/json":      true,
+	JSONAPIContentType:      true,
+	"application/xml":       true,
+	"text/xml":              true,
+	"application/msgpack":   true,
+	"application/x-msgpack": true,
+	"application/x-ndjson":  true,
+	"text/csv":              true,
+}
+
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+// Compress gzips responses of at least minSize bytes, such as long task
+// lists, for clients that accept gzip in Accept-Encoding. Smaller
+// responses are sent as they are, as compressing them saves little. Only
+// text-like media types are compressed; see compressibleTypes. A
+// compressed response has Content-Encoding: gzip and no Content-Length,
+// and every response of a compressible type has Vary: Accept-Encoding, so
+// caches keep both forms apart.
+//
+// The size is known from Content-Length when the handler sets it, and
+// otherwise from buffering up to minSize bytes. Streamed responses that
+// flush before then are compressed, flushing as they go.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				minSize:        minSize,
+				acceptsGzip:    acceptsGzip(r.Header.Get("Accept-Encoding")),
+			}
+			next.ServeHTTP(cw, r)
+			cw.finish()
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip,
+// by name or as *.
+func acceptsGzip(acceptEncoding string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, accepted := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(accepted, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// compressWriter holds a response back until it knows whether to gzip it:
+// when the handler sets a Content-Length, writes minSize bytes, flushes or
+// returns.
+type compressWriter struct {
+	http.ResponseWriter
+	minSize     int
+	acceptsGzip bool
+	status      int
+	wroteHeader bool
+	// decided is set once the headers have been sent, with gz set if the
+	// body is gzipped
+	decided bool
+	gz      *gzip.Writer
+	buf     []byte
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+
+	compressible := cw.compressible()
+	if compressible {
+		addVary(cw.Header(), "Accept-Encoding")
+	}
+	switch {
+	case !compressible || !cw.acceptsGzip:
+		cw.start(false)
+	case cw.Header().Get("Content-Length") != "":
+		n, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+		cw.start(err == nil && n >= cw.minSize)
+	}
+}
+
+// compressible reports whether the response may be compressed, by its
+// status and headers. Partial content and responses already encoded are
+// not.
+func (cw *compressWriter) compressible() bool {
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent ||
+		cw.status == http.StatusNotModified || cw.status == http.StatusPartialContent {
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && compressibleTypes[mediaType]
+}
+
+// start sends the headers and whatever is buffered, gzipped if compress
+// is set.
+func (cw *compressWriter) start(compress bool) {
+	cw.decided = true
+	if compress {
+		cw.Header().Del("Content-Length")
+		cw.Header().Set("Content-Encoding", "gzip")
+		cw.gz = gzipWriters.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) > 0 {
+		cw.write(cw.buf)
+		cw.buf = nil
+	}
+}
+
+func (cw *compressWriter) write(b []byte) (int, error) {
+	if cw.gz != nil {
+		return cw.gz.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		return cw.write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		cw.start(true)
+	}
+	return len(b), nil
+}
+
+// Flush sends what has been written so far. A response still held back
+// is taken to be a stream and compressed.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.start(true)
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g.
+// to lift the write deadline.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Hijack hands the connection over to the handler, e.g. to upgrade it to
+// a WebSocket.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.decided = true
+	}
+	return conn, rw, err
+}
+
+// finish sends a response held back because it was smaller than minSize,
+// and ends a gzipped one.
+func (cw *compressWriter) finish() {
+	if cw.wroteHeader && !cw.decided {
+		cw.start(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+		cw.gz.Reset(nil)
+		gzipWriters.Put(cw.gz)
+		cw.gz = nil
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	large := `{"tasks":[` + strings.Repeat(`{"title":"Write docs"},`, 100) + `{}]}`
+	mw := Compress(1024)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":7}`))
+		case "/sized":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", strconv.Itoa(len(large)))
+			w.Write([]byte(large))
+		case "/backup":
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write([]byte(large))
+		case "/stream":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Write([]byte("{\"id\":1}\n"))
+			http.NewResponseController(w).Flush()
+			w.Write([]byte("{\"id\":2}\n"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(large[:500]))
+			w.Write([]byte(large[500:]))
+		}
+	})
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rr := httptest.NewRecorder()
+		mw(next).ServeHTTP(rr, req)
+		return rr
+	}
+	gunzip := func(rr *httptest.ResponseRecorder) string {
+		t.Helper()
+		zr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("expected a gzipped body: %v", err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("expected a gzipped body: %v", err)
+		}
+		return string(body)
+	}
+
+	for _, path := range []string{"/tasks", "/sized"} {
+		rr := serve(path, "br;q=1.0, gzip;q=0.8")
+		if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Content-Length") != "" || rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: expected a gzipped response, got headers %v", path, rr.Header())
+		}
+		if body := gunzip(rr); body != large {
+			t.Errorf("%s: expected the body back, got %q", path, body)
+		}
+	}
+	if rr := serve("/tasks", ""); rr.Code != http.StatusCreated || rr.Body.String() != large || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected an uncompressed response without Accept-Encoding, got %d %v", rr.Code, rr.Header())
+	}
+
+	// Small responses, refused gzip and files are sent as they are
+	for _, c := range []struct{ path, acceptEncoding string }{
+		{"/small", "gzip"},
+		{"/tasks", "gzip;q=0, *"},
+		{"/tasks", "identity"},
+		{"/backup", "gzip"},
+	} {
+		if rr := serve(c.path, c.acceptEncoding); rr.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s with %q: expected no compression, got %v", c.path, c.acceptEncoding, rr.Header())
+		}
+	}
+
+	rr := serve("/stream", "gzip")
+	if !rr.Flushed || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a flushed, gzipped stream, got %v", rr.Header())
+	}
+	if body := gunzip(rr); body != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("expected both lines, got %q", body)
+	}
+}
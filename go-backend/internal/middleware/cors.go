@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CORSOrigins is the set of origins whose browser scripts may read
+// responses, such as https://app.example.com. It can be replaced while
+// the server runs. Safe for concurrent use.
+type CORSOrigins struct {
+	mu      sync.RWMutex
+	allowed map[string]bool
+}
+
+// NewCORSOrigins returns the set of origins. With no origins, or "*"
+// among them, any origin is allowed.
+func NewCORSOrigins(origins []string) *CORSOrigins {
+	o := &CORSOrigins{}
+	o.Set(origins)
+	return o
+}
+
+// Set replaces the allowed origins.
+func (o *CORSOrigins) Set(origins []string) {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(strings.TrimSpace(origin), "/")] = true
+	}
+	if len(allowed) == 0 {
+		allowed["*"] = true
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.allowed = allowed
+}
+
+// allows reports whether any origin is allowed, and whether origin is.
+func (o *CORSOrigins) allows(origin string) (anyOrigin, ok bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.allowed["*"], o.allowed[origin]
+}
+
+// Allows reports whether browser scripts from origin may call the API:
+// whether any origin is allowed or origin is listed.
+func (o *CORSOrigins) Allows(origin string) bool {
+	anyOrigin, ok := o.allows(origin)
+	return anyOrigin || ok
+}
+
+// CORS restricts the origins whose browser scripts may read responses to
+// origins. Handlers allow any origin with Access-Control-Allow-Origin: *;
+// this replaces it with the request's Origin if that is allowed, and
+// removes it otherwise. Responses vary by Origin. While any origin is
+// allowed, responses are left as they are.
+func CORS(origins *CORSOrigins) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			anyOrigin, allowed := origins.allows(origin)
+			if anyOrigin {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&corsWriter{ResponseWriter: w, origin: origin, allowed: allowed}, r)
+		})
+	}
+}
+
+// corsWriter sets the allowed origin of a response as its headers are sent.
+type corsWriter struct {
+	http.ResponseWriter
+	origin      string
+	allowed     bool
+	wroteHeader bool
+}
+
+func (cw *corsWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		h := cw.Header()
+		addVary(h, "Origin")
+		if h.Get("Access-Control-Allow-Origin") != "" {
+			if cw.allowed {
+				h.Set("Access-Control-Allow-Origin", cw.origin)
+			} else {
+				h.Del("Access-Control-Allow-Origin")
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *corsWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends the headers, if they have not been, and what has been
+// written so far.
+func (cw *corsWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g.
+// to hijack the connection.
+func (cw *corsWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	mw := func(next http.Handler) http.Handler {
+		return CORS(NewCORSOrigins([]string{"https://app.example.com/"}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			next.ServeHTTP(w, r)
+		}))
+	}
+	middlewaretest.Run(t, mw, []middlewaretest.Case{
+		{
+			Name:           "allowed origin",
+			Headers:        map[string]string{"Origin": "https://app.example.com"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Vary": "Origin"},
+		},
+		{
+			Name:              "other origin",
+			Headers:           map[string]string{"Origin": "https://evil.example.com"},
+			WantNextCalled:    true,
+			WantHeadersAbsent: []string{"Access-Control-Allow-Origin"},
+		},
+		{
+			Name:              "no origin",
+			WantNextCalled:    true,
+			WantHeadersAbsent: []string{"Access-Control-Allow-Origin"},
+		},
+	})
+
+	anyOrigin := func(next http.Handler) http.Handler {
+		return CORS(NewCORSOrigins([]string{"*"}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			next.ServeHTTP(w, r)
+		}))
+	}
+	middlewaretest.Run(t, anyOrigin, []middlewaretest.Case{
+		{
+			Name:              "any origin",
+			Headers:           map[string]string{"Origin": "https://evil.example.com"},
+			WantNextCalled:    true,
+			WantHeaders:       map[string]string{"Access-Control-Allow-Origin": "*"},
+			WantHeadersAbsent: []string{"Vary"},
+		},
+	})
+}
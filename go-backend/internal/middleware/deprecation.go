@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Deprecation describes a deprecated route or response field.
+type Deprecation struct {
+	// Since is when the route was deprecated. Zero means "deprecated, date unknown".
+	Since time.Time
+	// Sunset is when the route or field will be removed. Zero if not scheduled.
+	Sunset time.Time
+	// Link points to migration documentation.
+	Link string
+	// Fields lists deprecated response fields. When empty, the whole route is deprecated.
+	Fields []string
+	// Message is a human-readable explanation sent in a Warning header.
+	Message string
+}
+
+// DeprecationTable maps route patterns to their deprecation metadata.
+// Keys are either a path ("/api/stats") or a method and path ("GET /api/stats").
+// A path ending in "/" matches every path under it.
+type DeprecationTable map[string]Deprecation
+
+// lookup returns the deprecation entry for the request, preferring
+// method-specific and longer patterns.
+func (t DeprecationTable) lookup(r *http.Request) (Deprecation, bool) {
+	var (
+		best    Deprecation
+		bestLen = -1
+	)
+
+	for pattern, dep := range t {
+		path := pattern
+		score := 0
+		if method, rest, ok := strings.Cut(pattern, " "); ok {
+			if method != r.Method {
+				continue
+			}
+			path = rest
+			score = 1
+		}
+
+		if path != r.URL.Path && !(strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+			continue
+		}
+
+		if l := len(path)*2 + score; l > bestLen {
+			best, bestLen = dep, l
+		}
+	}
+
+	return best, bestLen >= 0
+}
+
+// Deprecations adds Deprecation, Sunset, Link and Warning headers to
+// responses for routes listed in the table.
+func Deprecations(table DeprecationTable) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if dep, ok := table.lookup(r); ok {
+				setDeprecationHeaders(w.Header(), dep)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setDeprecationHeaders(h http.Header, dep Deprecation) {
+	// Field-level deprecations only produce warnings; the route itself is still supported
+	if len(dep.Fields) == 0 {
+		if dep.Since.IsZero() {
+			h.Set("Deprecation", "true")
+		} else {
+			h.Set("Deprecation", fmt.Sprintf("@%d", dep.Since.Unix()))
+		}
+		if !dep.Sunset.IsZero() {
+			h.Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	if dep.Link != "" {
+		h.Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, dep.Link))
+	}
+
+	for _, warning := range deprecationWarnings(dep) {
+		h.Add("Warning", fmt.Sprintf(`299 - %q`, warning))
+	}
+}
+
+func deprecationWarnings(dep Deprecation) []string {
+	var warnings []string
+
+	if len(dep.Fields) == 0 {
+		msg := "This endpoint is deprecated"
+		if !dep.Sunset.IsZero() {
+			msg += " and will be removed on " + dep.Sunset.UTC().Format("2006-01-02")
+		}
+		warnings = append(warnings, msg)
+	}
+
+	for _, field := range dep.Fields {
+		msg := fmt.Sprintf("Field '%s' is deprecated", field)
+		if !dep.Sunset.IsZero() {
+			msg += " and will be removed on " + dep.Sunset.UTC().Format("2006-01-02")
+		}
+		warnings = append(warnings, msg)
+	}
+
+	if dep.Message != "" {
+		warnings = append(warnings, dep.Message)
+	}
+
+	return warnings
+}
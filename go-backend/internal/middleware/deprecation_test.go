@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestDeprecations(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	table := DeprecationTable{
+		"GET /api/stats": {Since: since, Sunset: sunset, Link: "https://example.com/stats-v2"},
+		"/api/legacy/":   {},
+		"/api/tasks":     {Fields: []string{"userId"}, Message: "Use assignee instead"},
+		"/api/projects":  {Sunset: sunset, Fields: []string{"owner"}},
+		"/api/reports":   {Sunset: sunset},
+	}
+
+	middlewaretest.Run(t, Deprecations(table), []middlewaretest.Case{
+		{
+			Name:           "deprecated route with sunset",
+			Path:           "/api/stats",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders: map[string]string{
+				"Deprecation": "@1767225600",
+				"Sunset":      "Mon, 01 Jun 2026 00:00:00 GMT",
+				"Link":        `<https://example.com/stats-v2>; rel="deprecation"`,
+				"Warning":     `299 - "This endpoint is deprecated and will be removed on 2026-06-01"`,
+			},
+		},
+		{
+			Name:              "method mismatch",
+			Method:            http.MethodPost,
+			Path:              "/api/stats",
+			WantNextCalled:    true,
+			WantHeadersAbsent: []string{"Deprecation", "Sunset"},
+		},
+		{
+			Name:           "prefix match without date",
+			Path:           "/api/legacy/thing",
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"Deprecation": "true"},
+		},
+		{
+			Name:              "field deprecation only warns",
+			Path:              "/api/tasks",
+			WantNextCalled:    true,
+			WantHeadersAbsent: []string{"Deprecation"},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				warnings := rr.Header().Values("Warning")
+				if len(warnings) != 2 {
+					t.Fatalf("expected 2 warnings, got %v", warnings)
+				}
+				if warnings[0] != `299 - "Field 'userId' is deprecated"` {
+					t.Errorf("unexpected field warning: %s", warnings[0])
+				}
+				if warnings[1] != `299 - "Use assignee instead"` {
+					t.Errorf("unexpected message warning: %s", warnings[1])
+				}
+			},
+		},
+		{
+			Name:              "field deprecation with sunset",
+			Path:              "/api/projects",
+			WantNextCalled:    true,
+			WantHeaders:       map[string]string{"Warning": `299 - "Field 'owner' is deprecated and will be removed on 2026-06-01"`},
+			WantHeadersAbsent: []string{"Deprecation", "Sunset", "Link"},
+		},
+		{
+			Name:           "sunset without since",
+			Path:           "/api/reports",
+			WantNextCalled: true,
+			WantHeaders: map[string]string{
+				"Deprecation": "true",
+				"Sunset":      "Mon, 01 Jun 2026 00:00:00 GMT",
+			},
+		},
+		{
+			Name:              "prefix matches only under it",
+			Path:              "/api/legacy",
+			WantNextCalled:    true,
+			WantHeadersAbsent: []string{"Deprecation"},
+		},
+		{
+			Name:              "not deprecated",
+			Path:              "/api/users",
+			WantNextCalled:    true,
+			WantHeadersAbsent: []string{"Deprecation", "Warning"},
+		},
+	})
+}
+
+func TestDeprecationTable_Lookup(t *testing.T) {
+	t.Parallel()
+
+	table := DeprecationTable{
+		"/api/":                {Message: "prefix"},
+		"/api/stats/":          {Message: "longer prefix"},
+		"/api/stats/daily":     {Message: "path"},
+		"GET /api/stats/daily": {Message: "method and path"},
+	}
+
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodGet, "/api/stats/daily", "method and path"},
+		{http.MethodPost, "/api/stats/daily", "path"},
+		{http.MethodGet, "/api/stats/weekly", "longer prefix"},
+		{http.MethodGet, "/api/tasks", "prefix"},
+		{http.MethodGet, "/health", ""},
+	}
+
+	for _, tt := range tests {
+		dep, ok := table.lookup(httptest.NewRequest(tt.method, tt.path, nil))
+		if ok != (tt.want != "") || dep.Message != tt.want {
+			t.Errorf("%s %s: expected %q, got %q (found=%v)", tt.method, tt.path, tt.want, dep.Message, ok)
+		}
+	}
+}
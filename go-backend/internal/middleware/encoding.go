@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-backend/internal/codec"
+)
+
+// Encodings lets clients that cannot speak JSON use the API in the media
+// types of codecs, such as XML. A request body whose Content-Type is one
+// of theirs is decoded to JSON before it is handled, and JSON responses
+// are encoded in the media type the client prefers in Accept, if it is one
+// of theirs. Other clients, and responses that are not JSON, such as CSV
+// exports and event streams, are unaffected. A body that does not decode
+// gets 400 with code INVALID_BODY.
+func Encodings(codecs ...codec.Codec) func(http.Handler) http.Handler {
+	byType := make(map[string]codec.Codec)
+	for _, c := range codecs {
+		for _, mediaType := range c.MediaTypes() {
+			byType[mediaType] = c
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept")
+
+			var handler http.Handler = next
+			if in := requestCodec(r, byType); in != nil && !decodeRequest(r, in) {
+				handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					writeError(w, http.StatusBadRequest, "Invalid "+in.MediaTypes()[0]+" body", "INVALID_BODY")
+				})
+			}
+			out := negotiateCodec(r.Header.Get("Accept"), byType)
+			if out == nil {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			handler.ServeHTTP(bw, r)
+			if bw.passthrough {
+				return
+			}
+
+			body := bw.body.Bytes()
+			if isJSON(w.Header()) {
+				dec := json.NewDecoder(bytes.NewReader(body))
+				// Keep numbers as written, so large IDs are not rounded
+				dec.UseNumber()
+				var decoded interface{}
+				if err := dec.Decode(&decoded); err == nil {
+					if encoded, err := out.Encode(decoded); err == nil {
+						body = encoded
+						w.Header().Set("Content-Type", out.MediaTypes()[0])
+					}
+				}
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(bw.statusCode)
+			w.Write(body)
+		})
+	}
+}
+
+// negotiateCodec returns the codec of the media type accept prefers, or
+// nil if it prefers JSON, any type or none of the codecs'. Of types
+// preferred equally, the first listed wins. Browsers, which accept
+// text/html, get JSON whatever else they accept, as they ask for XML.
+func negotiateCodec(accept string, byType map[string]codec.Codec) codec.Codec {
+	if strings.Contains(strings.ToLower(accept), "text/html") {
+		return nil
+	}
+	var best codec.Codec
+	bestQ := 0.0
+	for _, accepted := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(accepted, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		switch c, ok := byType[mediaType]; {
+		case ok:
+			best, bestQ = c, q
+		case mediaType == "application/json", mediaType == "*/*", mediaType == "application/*":
+			best, bestQ = nil, q
+		}
+	}
+	return best
+}
+
+// requestCodec returns the codec of the Content-Type of r's body, or nil
+// if it has none.
+func requestCodec(r *http.Request, byType map[string]codec.Codec) codec.Codec {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	return byType[mediaType]
+}
+
+// decodeRequest replaces the body of r, in the media type of c, with its
+// JSON encoding. It returns false if the body does not decode. A body
+// that cannot be read, e.g. because it is too large, is left for the
+// handler to report.
+func decodeRequest(r *http.Request, c codec.Codec) bool {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return true
+	}
+	decoded, err := c.Decode(body)
+	if err != nil {
+		return false
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(encoded))
+	r.ContentLength = int64(len(encoded))
+	r.Header.Set("Content-Type", "application/json")
+	return true
+}
+
+// addVary adds name to the Vary header of h, unless it is already there.
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
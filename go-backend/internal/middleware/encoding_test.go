@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-backend/internal/codec"
+)
+
+func TestEncodings(t *testing.T) {
+	t.Parallel()
+
+	mw := Encodings(codec.Defaults()...)
+	var gotBody, gotType string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody, gotType = string(body), r.Header.Get("Content-Type")
+		if r.URL.Path == "/export" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("id\n1\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7,"title":"Docs"}`))
+	})
+	serve := func(path, accept, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		mw(next).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/api/tasks", "application/xml", "application/xml; charset=utf-8", `<task><title>Docs</title><userId type="number">2</userId></task>`)
+	if gotBody != `{"title":"Docs","userId":2}` || gotType != "application/json" {
+		t.Errorf("expected the XML body decoded to JSON, got %q (%s)", gotBody, gotType)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><id type="number">7</id><title>Docs</title></response>` + "\n"
+	if rr.Code != http.StatusCreated || rr.Body.String() != want || rr.Header().Get("Content-Type") != "application/xml" {
+		t.Errorf("expected an XML response, got %d %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body)
+	}
+
+	rr = serve("/api/tasks", "application/json;q=0.5, application/msgpack", "application/json", `{"title":"Docs"}`)
+	if gotBody != `{"title":"Docs"}` || rr.Header().Get("Content-Type") != "application/msgpack" || rr.Body.Len() != 16 {
+		t.Errorf("expected JSON passed on and a MessagePack response, got %q: % x", rr.Header().Get("Content-Type"), rr.Body.Bytes())
+	}
+
+	// JSON clients, browsers and responses that are not JSON are unaffected
+	for _, accept := range []string{"", "*/*", "application/json, application/xml", "text/html,application/xml;q=0.9,*/*;q=0.8"} {
+		rr = serve("/api/tasks", accept, "application/json", `{}`)
+		if rr.Body.String() != `{"id":7,"title":"Docs"}` || rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: expected JSON, got %s", accept, rr.Body)
+		}
+	}
+	if rr = serve("/export", "application/xml", "", ""); rr.Body.String() != "id\n1\n" {
+		t.Errorf("expected the CSV export unchanged, got %s", rr.Body)
+	}
+
+	gotBody = ""
+	rr = serve("/api/tasks", "application/xml", "application/msgpack", "\xc1")
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "<code>INVALID_BODY</code>") || gotBody != "" {
+		t.Errorf("expected an invalid body rejected in XML before the handler, got %d: %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONAPIContentType is the media type of JSON:API documents.
+const JSONAPIContentType = "application/vnd.api+json"
+
+// Relationship is a JSON field holding the ID, or a list of IDs, of
+// related records.
+type Relationship struct {
+	// Name is the name of the relationship, such as "user" for userId.
+	Name string
+	// Type is the resource type of the related records, such as "users".
+	Type string
+}
+
+// HypermediaConfig configures JSON:API responses.
+type HypermediaConfig struct {
+	// Resources maps the routes of resource collections, such as
+	// /api/tasks, to their resource type, such as "tasks". A record of a
+	// type is at its route followed by its ID.
+	Resources map[string]string
+	// Relationships maps the names of JSON fields holding IDs of related
+	// records to the relationship they stand for.
+	Relationships map[string]Relationship
+}
+
+// routeOf returns the route of the resource type typ, or "".
+func (c HypermediaConfig) routeOf(typ string) string {
+	for route, t := range c.Resources {
+		if t == typ {
+			return route
+		}
+	}
+	return ""
+}
+
+// wantsJSONAPI reports whether r accepts JSON:API documents. Clients must
+// ask for them by name; */* and application/json get plain JSON.
+func wantsJSONAPI(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(accepted, ";")
+		if !strings.EqualFold(strings.TrimSpace(mediaType), JSONAPIContentType) {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Hypermedia serves JSON responses as JSON:API documents to clients that
+// accept application/vnd.api+json, and as they are to everyone else.
+// Records become resources with a type, a string ID, attributes, links to
+// themselves and relationships linking to the records they refer to; the
+// other fields of a response become its meta. Error responses become
+// JSON:API error objects.
+func Hypermedia(cfg HypermediaConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept")
+			if !wantsJSONAPI(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(bw, r)
+			if bw.passthrough {
+				return
+			}
+
+			body := bw.body.Bytes()
+			if isJSON(w.Header()) {
+				dec := json.NewDecoder(bytes.NewReader(body))
+				// Keep numbers as written, so IDs are not read as floats
+				dec.UseNumber()
+				var decoded interface{}
+				if err := dec.Decode(&decoded); err == nil {
+					if document, err := json.Marshal(toJSONAPI(cfg, r, bw.statusCode, decoded)); err == nil {
+						body = append(document, '\n')
+						w.Header().Set("Content-Type", JSONAPIContentType)
+					}
+				}
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(bw.statusCode)
+			w.Write(body)
+		})
+	}
+}
+
+// toJSONAPI returns the JSON:API document for a response to r.
+func toJSONAPI(cfg HypermediaConfig, r *http.Request, status int, body interface{}) map[string]interface{} {
+	fields, _ := body.(map[string]interface{})
+	if status >= http.StatusBadRequest && fields != nil {
+		return map[string]interface{}{"errors": jsonAPIErrors(cfg, status, fields)}
+	}
+
+	document := map[string]interface{}{"links": map[string]interface{}{"self": r.URL.RequestURI()}}
+	if fields == nil {
+		document["meta"] = map[string]interface{}{"value": body}
+		return document
+	}
+
+	// A record at its own route, or created at its collection's
+	if typ := routeType(cfg, r.URL.Path); typ != "" && fields["id"] != nil {
+		document["data"] = resource(cfg, typ, fields)
+		return document
+	}
+
+	// A list of records under the name of their type, with the other
+	// fields, such as count, as meta
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		records, isList := fields[key].([]interface{})
+		if !isList || cfg.routeOf(key) == "" {
+			continue
+		}
+		data := make([]interface{}, 0, len(records))
+		for _, record := range records {
+			if object, ok := record.(map[string]interface{}); ok && object["id"] != nil {
+				data = append(data, resource(cfg, key, object))
+			}
+		}
+		if len(data) != len(records) {
+			continue
+		}
+		document["data"] = data
+		delete(fields, key)
+		break
+	}
+	if len(fields) > 0 || document["data"] == nil {
+		document["meta"] = fields
+	}
+	return document
+}
+
+// routeType returns the type of the records served at path: records of
+// the type at its route, or one of them at the route followed by an ID.
+func routeType(cfg HypermediaConfig, path string) string {
+	if typ, ok := cfg.Resources[path]; ok {
+		return typ
+	}
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return ""
+	}
+	return cfg.Resources[path[:i]]
+}
+
+// resource returns the JSON:API resource object of a record of type typ.
+func resource(cfg HypermediaConfig, typ string, record map[string]interface{}) map[string]interface{} {
+	id := idString(record["id"])
+	attributes := make(map[string]interface{}, len(record))
+	relationships := make(map[string]interface{})
+	for key, value := range record {
+		rel, isRel := cfg.Relationships[key]
+		switch {
+		case key == "id":
+		case isRel:
+			relationships[rel.Name] = relationship(cfg, rel, value)
+		default:
+			attributes[key] = value
+		}
+	}
+
+	object := map[string]interface{}{
+		"type":       typ,
+		"id":         id,
+		"attributes": attributes,
+		"links":      map[string]interface{}{"self": cfg.routeOf(typ) + "/" + id},
+	}
+	if len(relationships) > 0 {
+		object["relationships"] = relationships
+	}
+	return object
+}
+
+// relationship returns the relationship object for the related IDs in
+// value, a single ID or a list of them.
+func relationship(cfg HypermediaConfig, rel Relationship, value interface{}) map[string]interface{} {
+	route := cfg.routeOf(rel.Type)
+	identifier := func(v interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": rel.Type, "id": idString(v)}
+	}
+
+	if value == nil {
+		return map[string]interface{}{"data": nil}
+	}
+	list, isList := value.([]interface{})
+	if !isList {
+		id := idString(value)
+		return map[string]interface{}{
+			"data":  identifier(value),
+			"links": map[string]interface{}{"related": route + "/" + id},
+		}
+	}
+	data := make([]interface{}, len(list))
+	for i, v := range list {
+		data[i] = identifier(v)
+	}
+	return map[string]interface{}{"data": data}
+}
+
+// idString returns an ID, encoded or not, as the string JSON:API wants.
+func idString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// jsonAPIErrors returns the JSON:API error objects of an error response:
+// one per field error, pointing at the attribute or relationship, or one
+// for the error as a whole.
+func jsonAPIErrors(cfg HypermediaConfig, status int, fields map[string]interface{}) []interface{} {
+	object := func(code, detail interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"status": strconv.Itoa(status),
+			"code":   code,
+			"title":  http.StatusText(status),
+			"detail": detail,
+		}
+	}
+
+	fieldErrors, _ := fields["errors"].([]interface{})
+	var errs []interface{}
+	for _, fe := range fieldErrors {
+		fe, ok := fe.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		e := object(fe["code"], fe["message"])
+		field, _ := fe["field"].(string)
+		if rel, ok := cfg.Relationships[field]; ok {
+			e["source"] = map[string]interface{}{"pointer": "/data/relationships/" + rel.Name}
+		} else if field != "" {
+			e["source"] = map[string]interface{}{"pointer": "/data/attributes/" + strings.ReplaceAll(field, ".", "/")}
+		}
+		errs = append(errs, e)
+	}
+	if len(errs) == 0 {
+		errs = append(errs, object(fields["code"], fields["error"]))
+	}
+
+	// Keep other details of the error, such as a retry time, as meta
+	meta := make(map[string]interface{})
+	for key, value := range fields {
+		switch key {
+		case "success", "error", "code", "errors":
+		default:
+			meta[key] = value
+		}
+	}
+	if len(meta) > 0 {
+		errs[0].(map[string]interface{})["meta"] = meta
+	}
+	return errs
+}
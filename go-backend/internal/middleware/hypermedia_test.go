@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var testHypermediaConfig = HypermediaConfig{
+	Resources: map[string]string{"/api/tasks": "tasks", "/api/users": "users"},
+	Relationships: map[string]Relationship{
+		"userId":    {Name: "user", Type: "users"},
+		"blockedBy": {Name: "blockedBy", Type: "tasks"},
+	},
+}
+
+func TestHypermedia(t *testing.T) {
+	t.Parallel()
+
+	mw := Hypermedia(testHypermediaConfig)
+	serve := func(path, accept string, status int, body string) *httptest.ResponseRecorder {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		})
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		mw(next).ServeHTTP(rr, req)
+		return rr
+	}
+	document := func(rr *httptest.ResponseRecorder) map[string]interface{} {
+		t.Helper()
+		if got := rr.Header().Get("Content-Type"); got != JSONAPIContentType {
+			t.Errorf("expected a JSON:API content type, got %q", got)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("expected a JSON document, got %s", rr.Body)
+		}
+		return doc
+	}
+	task := `{"id":7,"title":"Write docs","userId":2,"blockedBy":[3]}`
+
+	// Plain JSON clients are unaffected
+	for _, accept := range []string{"", "application/json", "*/*", JSONAPIContentType + ";q=0"} {
+		rr := serve("/api/tasks/7", accept, http.StatusOK, task)
+		if rr.Body.String() != task || rr.Header().Get("Content-Type") != "application/json" || rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: expected the response unchanged, got %s", accept, rr.Body)
+		}
+	}
+
+	doc := document(serve("/api/tasks/7", JSONAPIContentType, http.StatusOK, task))
+	want := map[string]interface{}{
+		"type":       "tasks",
+		"id":         "7",
+		"attributes": map[string]interface{}{"title": "Write docs"},
+		"links":      map[string]interface{}{"self": "/api/tasks/7"},
+		"relationships": map[string]interface{}{
+			"user":      map[string]interface{}{"data": map[string]interface{}{"type": "users", "id": "2"}, "links": map[string]interface{}{"related": "/api/users/2"}},
+			"blockedBy": map[string]interface{}{"data": []interface{}{map[string]interface{}{"type": "tasks", "id": "3"}}},
+		},
+	}
+	if !reflect.DeepEqual(doc["data"], want) {
+		t.Errorf("expected the task as a resource %v, got %v", want, doc["data"])
+	}
+
+	doc = document(serve("/api/tasks?status=pending", "application/json, "+JSONAPIContentType, http.StatusOK, `{"tasks":[`+task+`],"count":1,"revision":4}`))
+	if data, ok := doc["data"].([]interface{}); !ok || len(data) != 1 {
+		t.Errorf("expected the tasks as data, got %v", doc["data"])
+	}
+	if meta := doc["meta"]; !reflect.DeepEqual(meta, map[string]interface{}{"count": 1.0, "revision": 4.0}) {
+		t.Errorf("expected the count and revision as meta, got %v", meta)
+	}
+	if links := doc["links"]; !reflect.DeepEqual(links, map[string]interface{}{"self": "/api/tasks?status=pending"}) {
+		t.Errorf("expected a link to the request, got %v", links)
+	}
+
+	// Responses that are not records are meta
+	doc = document(serve("/api/stats", JSONAPIContentType, http.StatusOK, `{"users":{"total":2}}`))
+	if doc["data"] != nil || !reflect.DeepEqual(doc["meta"], map[string]interface{}{"users": map[string]interface{}{"total": 2.0}}) {
+		t.Errorf("expected the stats as meta, got %v", doc)
+	}
+}
+
+func TestHypermedia_Errors(t *testing.T) {
+	t.Parallel()
+
+	mw := Hypermedia(testHypermediaConfig)
+	serve := func(body string) []interface{} {
+		t.Helper()
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(body))
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", nil)
+		req.Header.Set("Accept", JSONAPIContentType)
+		rr := httptest.NewRecorder()
+		mw(next).ServeHTTP(rr, req)
+
+		var doc struct {
+			Errors []interface{} `json:"errors"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil || rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected a 400 error document, got %d: %s", rr.Code, rr.Body)
+		}
+		return doc.Errors
+	}
+
+	errs := serve(`{"success":false,"error":"Invalid JSON format","code":"INVALID_JSON"}`)
+	want := []interface{}{map[string]interface{}{"status": "400", "code": "INVALID_JSON", "title": "Bad Request", "detail": "Invalid JSON format"}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("expected %v, got %v", want, errs)
+	}
+
+	errs = serve(`{"success":false,"error":"Title is required","code":"REQUIRED","errors":[
+		{"field":"title","code":"REQUIRED","message":"Title is required"},
+		{"field":"tags.0","code":"INVALID_TAG","message":"Invalid tag"},
+		{"field":"userId","code":"INVALID_USER_ID","message":"User ID does not exist"}]}`)
+	if len(errs) != 3 {
+		t.Fatalf("expected an error per field, got %v", errs)
+	}
+	if source := errs[1].(map[string]interface{})["source"]; !reflect.DeepEqual(source, map[string]interface{}{"pointer": "/data/attributes/tags/0"}) {
+		t.Errorf("expected a pointer to the field, got %v", source)
+	}
+	if source := errs[2].(map[string]interface{})["source"]; !reflect.DeepEqual(source, map[string]interface{}{"pointer": "/data/relationships/user"}) {
+		t.Errorf("expected a pointer to the relationship, got %v", source)
+	}
+}
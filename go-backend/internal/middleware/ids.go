@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// IDCodec encodes the integer IDs records are stored under as the opaque
+// strings clients see, and back.
+type IDCodec interface {
+	Encode(id int) string
+	// Decode returns the ID encoded as s, or false if s is not one.
+	Decode(s string) (int, bool)
+}
+
+// IDConfig configures ID obfuscation.
+type IDConfig struct {
+	Codec IDCodec
+	// Fields are the names of JSON fields and query parameters holding an
+	// ID, and Lists those of JSON fields holding a list of IDs. A field
+	// named in Lists may also hold one ID, as blockedBy does when adding a
+	// dependency.
+	Fields map[string]bool
+	Lists  map[string]bool
+	// Routes are the paths with IDs in them, with {id} in place of each,
+	// such as /api/tasks/{id}. A route also matches the paths below it.
+	Routes []string
+}
+
+// errRawID rejects an ID given as a number rather than encoded.
+var errRawID = errors.New("raw ID")
+
+// ObfuscateIDs shows clients encoded IDs instead of the integers records
+// are stored under, so IDs cannot be enumerated. The ID fields of JSON
+// responses are encoded, and IDs in the routes' paths, the query and JSON
+// request bodies are decoded before the request is handled. IDs given as
+// plain numbers are rejected: with 404 in a path and 400 elsewhere.
+func ObfuscateIDs(cfg IDConfig) func(http.Handler) http.Handler {
+	routes := make([][]string, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		routes[i] = strings.Split(route, "/")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !decodePath(r, routes, cfg.Codec) {
+				writeError(w, http.StatusNotFound, "Not found", "NOT_FOUND")
+				return
+			}
+			if !decodeQuery(r, cfg) || !decodeBody(r, cfg) {
+				writeError(w, http.StatusBadRequest, "Invalid ID. IDs must be given as they are shown", "INVALID_ID")
+				return
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(bw, r)
+			if bw.passthrough {
+				return
+			}
+
+			body := bw.body.Bytes()
+			if isJSON(w.Header()) {
+				if encoded, err := rewriteIDs(body, cfg, encodeID(cfg.Codec)); err == nil {
+					body = encoded
+				}
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(bw.statusCode)
+			w.Write(body)
+		})
+	}
+}
+
+// decodePath decodes the IDs in the path of r where a route has {id}.
+// Segments there that are not encoded IDs, such as /api/tasks/export, are
+// left alone. It returns false if one is a plain number.
+func decodePath(r *http.Request, routes [][]string, codec IDCodec) bool {
+	segments := strings.Split(r.URL.Path, "/")
+	ids := make(map[int]bool)
+	for _, route := range routes {
+		if len(segments) < len(route) {
+			continue
+		}
+		matches := true
+		for i, part := range route {
+			if part != "{id}" && part != segments[i] {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		for i, part := range route {
+			if part == "{id}" {
+				ids[i] = true
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return true
+	}
+
+	for i := range ids {
+		if id, ok := codec.Decode(segments[i]); ok {
+			segments[i] = strconv.Itoa(id)
+		} else if _, err := strconv.Atoi(segments[i]); err == nil {
+			return false
+		}
+	}
+	r.URL.Path = strings.Join(segments, "/")
+	r.URL.RawPath = ""
+	return true
+}
+
+// decodeQuery decodes the ID query parameters of r. Values that are not
+// encoded IDs are left for the handler to reject. It returns false if one
+// is a plain number.
+func decodeQuery(r *http.Request, cfg IDConfig) bool {
+	query := r.URL.Query()
+	decoded := false
+	for key, values := range query {
+		if !cfg.Fields[key] {
+			continue
+		}
+		for i, value := range values {
+			if id, ok := cfg.Codec.Decode(value); ok {
+				values[i] = strconv.Itoa(id)
+				decoded = true
+			} else if _, err := strconv.Atoi(value); err == nil {
+				return false
+			}
+		}
+	}
+	if decoded {
+		r.URL.RawQuery = query.Encode()
+	}
+	return true
+}
+
+// decodeBody decodes the IDs in a JSON request body, whatever its declared
+// content type, as handlers read it. Other bodies, such as CSV imports,
+// are left as they are, and uploads are not read. It returns false if an
+// ID is a plain number.
+func decodeBody(r *http.Request, cfg IDConfig) bool {
+	if r.Body == nil || r.Body == http.NoBody || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return true
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		// Let the handler see the error, e.g. that the body is too large
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return true
+	}
+	decoded, err := rewriteIDs(body, cfg, decodeID(cfg.Codec))
+	if errors.Is(err, errRawID) {
+		return false
+	}
+	if err == nil {
+		body = decoded
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return true
+}
+
+// encodeID returns a function encoding the integer IDs of a response.
+func encodeID(codec IDCodec) func(interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		if n, ok := v.(json.Number); ok {
+			if id, err := strconv.Atoi(n.String()); err == nil {
+				return codec.Encode(id), nil
+			}
+		}
+		return v, nil
+	}
+}
+
+// decodeID returns a function decoding the encoded IDs of a request.
+// Strings that are not encoded IDs are left for the handler to reject.
+func decodeID(codec IDCodec) func(interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case string:
+			if id, ok := codec.Decode(v); ok {
+				return json.Number(strconv.Itoa(id)), nil
+			}
+		case json.Number:
+			return nil, errRawID
+		}
+		return v, nil
+	}
+}
+
+// rewriteIDs returns the JSON document data with the values of its ID
+// fields, and of the elements of its ID list fields, rewritten by fn.
+func rewriteIDs(data []byte, cfg IDConfig, fn func(interface{}) (interface{}, error)) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as written, so IDs are not read as floats
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if err := rewriteValues(decoded, cfg, fn); err != nil {
+		return nil, err
+	}
+	rewritten, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, err
+	}
+	return append(rewritten, '\n'), nil
+}
+
+// rewriteValues rewrites the ID fields of every object in v in place.
+func rewriteValues(v interface{}, cfg IDConfig, fn func(interface{}) (interface{}, error)) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			var err error
+			switch list, isList := value.([]interface{}); {
+			case !isList && (cfg.Fields[key] || cfg.Lists[key]):
+				v[key], err = fn(value)
+			case isList && cfg.Lists[key]:
+				for i := range list {
+					if list[i], err = fn(list[i]); err != nil {
+						break
+					}
+				}
+			default:
+				err = rewriteValues(value, cfg, fn)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if err := rewriteValues(value, cfg, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+// prefixCodec encodes ID n as "id-n".
+type prefixCodec struct{}
+
+func (prefixCodec) Encode(id int) string { return "id-" + strconv.Itoa(id) }
+
+func (prefixCodec) Decode(s string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "id-"))
+	return n, err == nil && strings.HasPrefix(s, "id-")
+}
+
+var testIDConfig = IDConfig{
+	Codec:  prefixCodec{},
+	Fields: map[string]bool{"id": true, "userId": true},
+	Lists:  map[string]bool{"blockedBy": true},
+	Routes: []string{"/api/tasks/{id}", "/api/tasks/{id}/dependencies/{id}"},
+}
+
+func TestObfuscateIDs(t *testing.T) {
+	t.Parallel()
+
+	mw := ObfuscateIDs(testIDConfig)
+
+	middlewaretest.Run(t, mw, []middlewaretest.Case{
+		{
+			Name:           "encoded IDs in the path",
+			Path:           "/api/tasks/id-3/dependencies/id-4",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			CheckRequest: func(t *testing.T, r *http.Request) {
+				if r.URL.Path != "/api/tasks/3/dependencies/4" {
+					t.Errorf("expected the IDs decoded, got %q", r.URL.Path)
+				}
+			},
+		},
+		{
+			Name:           "routes without an ID",
+			Path:           "/api/tasks/export",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			CheckRequest: func(t *testing.T, r *http.Request) {
+				if r.URL.Path != "/api/tasks/export" {
+					t.Errorf("expected the path unchanged, got %q", r.URL.Path)
+				}
+			},
+		},
+		{
+			Name:           "plain ID in the path",
+			Path:           "/api/tasks/3",
+			WantStatus:     http.StatusNotFound,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("NOT_FOUND"),
+		},
+		{
+			Name:           "encoded ID in the query",
+			Path:           "/api/tasks?userId=id-2&status=pending",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			CheckRequest: func(t *testing.T, r *http.Request) {
+				if got := r.URL.Query().Get("userId"); got != "2" {
+					t.Errorf("expected the user ID decoded, got %q", got)
+				}
+			},
+		},
+		{
+			Name:           "plain ID in the query",
+			Path:           "/api/tasks?userId=2",
+			WantStatus:     http.StatusBadRequest,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("INVALID_ID"),
+		},
+	})
+}
+
+func TestObfuscateIDs_RewritesBodies(t *testing.T) {
+	t.Parallel()
+
+	mw := ObfuscateIDs(testIDConfig)
+
+	var gotBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"tasks":[{"id":7,"userId":2,"blockedBy":[3,4],"count":2}]}`))
+	})
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mw(next).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(`{"title":"Hidden","userId":"id-2","blockedBy":["id-3"]}`)
+	if gotBody != `{"blockedBy":[3],"title":"Hidden","userId":2}`+"\n" {
+		t.Errorf("expected the request IDs decoded, got %q", gotBody)
+	}
+	want := `{"tasks":[{"blockedBy":["id-3","id-4"],"count":2,"id":"id-7","userId":"id-2"}]}` + "\n"
+	if rr.Code != http.StatusCreated || rr.Body.String() != want {
+		t.Errorf("expected the response IDs encoded and other numbers kept, got %d: %s", rr.Code, rr.Body)
+	}
+
+	// A list field may hold one ID
+	serve(`{"blockedBy":"id-5"}`)
+	if gotBody != `{"blockedBy":5}`+"\n" {
+		t.Errorf("expected a single ID in a list field decoded, got %q", gotBody)
+	}
+
+	// Strings that are not IDs are left for the handler to reject
+	serve(`{"userId":"two"}`)
+	if gotBody != `{"userId":"two"}`+"\n" {
+		t.Errorf("expected an invalid ID passed on, got %q", gotBody)
+	}
+
+	gotBody = ""
+	if rr = serve(`{"userId":2}`); rr.Code != http.StatusBadRequest || gotBody != "" {
+		t.Errorf("expected a plain ID rejected before the handler, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Priority ranks requests for admission when the server is under load.
+// Higher priorities may use more of the in-flight capacity.
+type Priority int
+
+// Request priorities, lowest first.
+const (
+	// PriorityExport is for expensive bulk exports, shed first.
+	PriorityExport Priority = iota
+	// PriorityWrite is for requests that modify data.
+	PriorityWrite
+	// PriorityRead is for ordinary reads.
+	PriorityRead
+	// PriorityCritical is for health probes and admin requests, which may
+	// use the full capacity.
+	PriorityCritical
+)
+
+// prioritySharePercent is the share of the in-flight capacity each priority
+// may fill. A request is shed once that many requests are already in flight,
+// keeping the remaining headroom for higher priorities.
+var prioritySharePercent = map[Priority]int{
+	PriorityExport:   50,
+	PriorityWrite:    75,
+	PriorityRead:     90,
+	PriorityCritical: 100,
+}
+
+// InFlightLimiter caps the number of requests being served at once,
+// admitting higher-priority requests ahead of lower-priority ones.
+type InFlightLimiter struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+// NewInFlightLimiter creates an InFlightLimiter allowing at most max
+// concurrent requests.
+func NewInFlightLimiter(max int) *InFlightLimiter {
+	return &InFlightLimiter{max: max}
+}
+
+// capacity returns how many requests may be in flight when admitting a
+// request of priority p. Every priority may use at least one slot.
+func (l *InFlightLimiter) capacity(p Priority) int {
+	share, ok := prioritySharePercent[p]
+	if !ok {
+		share = prioritySharePercent[PriorityRead]
+	}
+	return max(l.max*share/100, 1)
+}
+
+// acquire reserves a slot for a request of priority p.
+// Returns false if the request should be shed.
+func (l *InFlightLimiter) acquire(p Priority) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= l.capacity(p) {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// release frees a slot reserved by acquire.
+func (l *InFlightLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+}
+
+// InFlight returns the number of requests currently being served.
+func (l *InFlightLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// LimitInFlight sheds requests with 503 Service Unavailable once the
+// limiter's share for their priority is used up. classify assigns each
+// request its priority.
+func LimitInFlight(limiter *InFlightLimiter, classify func(*http.Request) Priority) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.acquire(classify(r)) {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusServiceUnavailable, "Server is overloaded, try again later", "SERVER_OVERLOADED")
+				return
+			}
+			defer limiter.release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestLimitInFlight_ShedsByPriority(t *testing.T) {
+	t.Parallel()
+
+	// Capacity 10: exports may fill 5 slots, writes 7, reads 9, critical 10
+	limiter := NewInFlightLimiter(10)
+	for i := 0; i < 7; i++ {
+		limiter.acquire(PriorityCritical)
+	}
+
+	classify := func(r *http.Request) Priority {
+		switch r.URL.Path {
+		case "/health":
+			return PriorityCritical
+		case "/export":
+			return PriorityExport
+		}
+		if r.Method == http.MethodGet {
+			return PriorityRead
+		}
+		return PriorityWrite
+	}
+
+	middlewaretest.Run(t, LimitInFlight(limiter, classify), []middlewaretest.Case{
+		{
+			Name:          "export shed",
+			Path:          "/export",
+			WantStatus:    http.StatusServiceUnavailable,
+			WantHeaders:   map[string]string{"Retry-After": "1"},
+			CheckResponse: expectErrorCode("SERVER_OVERLOADED"),
+		},
+		{
+			Name:       "write shed",
+			Method:     http.MethodPost,
+			Path:       "/api/tasks",
+			WantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			Name:           "read admitted",
+			Path:           "/api/tasks",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+		{
+			Name:           "health admitted",
+			Path:           "/health",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+	})
+
+	if got := limiter.InFlight(); got != 7 {
+		t.Errorf("expected slots to be released after each request, got %d in flight", got)
+	}
+}
+
+func TestInFlightLimiter_Capacity(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewInFlightLimiter(2)
+
+	if !limiter.acquire(PriorityExport) {
+		t.Fatal("expected the first export to be admitted")
+	}
+	if limiter.acquire(PriorityExport) {
+		t.Error("expected a second export to be shed")
+	}
+	if !limiter.acquire(PriorityCritical) {
+		t.Error("expected a critical request to use the remaining slot")
+	}
+	if limiter.acquire(PriorityCritical) {
+		t.Error("expected requests to be shed at full capacity")
+	}
+
+	limiter.release()
+	limiter.release()
+
+	if got := limiter.InFlight(); got != 0 {
+		t.Errorf("expected 0 in flight after release, got %d", got)
+	}
+}
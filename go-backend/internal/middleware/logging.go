@@ -2,7 +2,10 @@
 package middleware
 
 import (
+	"bufio"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
 )
@@ -25,15 +28,50 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack hands the connection over to the handler, logging the request as
+// 101 Switching Protocols, as taking over a connection is for upgrades.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
 // Logging logs all HTTP requests with method, path, status, and duration.
 func Logging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+	return LogRequests(slog.LevelInfo)(next)
+}
 
-		wrapped := newResponseWriter(w)
-		next.ServeHTTP(wrapped, r)
+// LogRequests logs HTTP requests at level and above: at
+// slog.LevelInfo, every request with method, path, status, and duration;
+// at slog.LevelDebug, with the query and client address too; at
+// slog.LevelWarn, only requests that failed with a 4xx or 5xx status;
+// and at slog.LevelError, only those that failed with a 5xx status.
+// The level is read for each request, so a *slog.LevelVar can change it
+// while the server runs.
+func LogRequests(leveler slog.Leveler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 
-		duration := time.Since(start)
-		log.Printf("%s %s %d %v", r.Method, r.URL.Path, wrapped.statusCode, duration)
-	})
+			wrapped := newResponseWriter(w)
+			next.ServeHTTP(wrapped, r)
+
+			duration := time.Since(start)
+			switch level := leveler.Level(); {
+			case level <= slog.LevelDebug:
+				log.Printf("%s %s %d %v (%s)", r.Method, r.URL.RequestURI(), wrapped.statusCode, duration, r.RemoteAddr)
+			case level <= slog.LevelInfo,
+				level <= slog.LevelWarn && wrapped.statusCode >= http.StatusBadRequest,
+				wrapped.statusCode >= http.StatusInternalServerError:
+				log.Printf("%s %s %d %v", r.Method, r.URL.Path, wrapped.statusCode, duration)
+			}
+		})
+	}
 }
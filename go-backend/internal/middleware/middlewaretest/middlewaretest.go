@@ -0,0 +1,125 @@
+// Package middlewaretest provides a table-driven harness for testing
+// HTTP middleware.
+package middlewaretest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Middleware is the signature shared by all middleware in this project.
+type Middleware func(http.Handler) http.Handler
+
+// Case describes a single request through a middleware and its expected outcome.
+type Case struct {
+	Name string
+
+	// Request
+	Method     string            // defaults to GET
+	Path       string            // defaults to "/"
+	Headers    map[string]string // request headers
+	RemoteAddr string            // defaults to httptest's 192.0.2.1:1234
+
+	// NextStatus is the status the wrapped handler responds with (default 200).
+	NextStatus int
+
+	// Expectations
+	WantStatus int // expected response status; 0 skips the check
+	// WantNextCalled reports whether the request must reach the wrapped handler.
+	WantNextCalled bool
+	// WantHeaders must be present on the response with exactly these values.
+	WantHeaders map[string]string
+	// WantHeadersPresent must be present on the response with any value.
+	WantHeadersPresent []string
+	// WantHeadersAbsent must not be present on the response.
+	WantHeadersAbsent []string
+	// CheckRequest inspects the request as seen by the wrapped handler,
+	// e.g. for context values. Only called when the handler is reached.
+	CheckRequest func(t *testing.T, r *http.Request)
+	// CheckResponse inspects the recorded response.
+	CheckResponse func(t *testing.T, rr *httptest.ResponseRecorder)
+}
+
+// Run executes cases in order against mw as subtests. The same middleware
+// instance is reused across cases, so stateful middleware (like rate
+// limiters) see the cases as a sequence.
+func Run(t *testing.T, mw Middleware, cases []Case) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rr, reached, seen := Do(mw, tc)
+
+			if tc.WantStatus != 0 && rr.Code != tc.WantStatus {
+				t.Errorf("expected status %d, got %d (body: %s)", tc.WantStatus, rr.Code, rr.Body.String())
+			}
+			if reached != tc.WantNextCalled {
+				t.Errorf("expected next handler called=%v, got %v", tc.WantNextCalled, reached)
+			}
+			for name, want := range tc.WantHeaders {
+				if got := rr.Header().Get(name); got != want {
+					t.Errorf("expected header %s=%q, got %q", name, want, got)
+				}
+			}
+			for _, name := range tc.WantHeadersPresent {
+				if rr.Header().Get(name) == "" {
+					t.Errorf("expected header %s to be set", name)
+				}
+			}
+			for _, name := range tc.WantHeadersAbsent {
+				if got := rr.Header().Get(name); got != "" {
+					t.Errorf("expected header %s to be absent, got %q", name, got)
+				}
+			}
+			if tc.CheckRequest != nil && seen != nil {
+				tc.CheckRequest(t, seen)
+			}
+			if tc.CheckResponse != nil {
+				tc.CheckResponse(t, rr)
+			}
+		})
+	}
+}
+
+// Do sends the case's request through mw and returns the recorded response,
+// whether the wrapped handler was reached, and the request it received.
+func Do(mw Middleware, tc Case) (*httptest.ResponseRecorder, bool, *http.Request) {
+	method := tc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := tc.Path
+	if path == "" {
+		path = "/"
+	}
+	nextStatus := tc.NextStatus
+	if nextStatus == 0 {
+		nextStatus = http.StatusOK
+	}
+
+	var (
+		reached bool
+		seen    *http.Request
+	)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		seen = r
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(nextStatus)
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	req := httptest.NewRequest(method, path, nil)
+	for name, value := range tc.Headers {
+		req.Header.Set(name, value)
+	}
+	if tc.RemoteAddr != "" {
+		req.RemoteAddr = tc.RemoteAddr
+	}
+
+	rr := httptest.NewRecorder()
+	mw(next).ServeHTTP(rr, req)
+
+	return rr, reached, seen
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const fieldNamingHeader = "X-Field-Naming"
+
+// Field naming conventions. NamingCamelCase is the canonical one;
+// NamingSnakeCase serves the legacy names of clients written against it.
+const (
+	NamingCamelCase = "camelCase"
+	NamingSnakeCase = "snake_case"
+)
+
+// NamingConfig configures field naming compatibility.
+type NamingConfig struct {
+	// Legacy maps canonical field names to their snake_case names. Only
+	// the names listed are renamed, so keys that are data, such as
+	// statuses or check names, are left alone.
+	Legacy map[string]string
+	// Pins maps API keys to the naming their clients expect.
+	Pins map[string]string
+}
+
+// resolve returns the naming for the request, or false if the client
+// explicitly asked for an unsupported one.
+func (c NamingConfig) resolve(r *http.Request) (string, bool) {
+	if requested := strings.TrimSpace(r.Header.Get(fieldNamingHeader)); requested != "" {
+		return requested, requested == NamingCamelCase || requested == NamingSnakeCase
+	}
+
+	if pinned, ok := c.Pins[strings.TrimSpace(r.Header.Get(apiKeyHeader))]; ok {
+		return pinned, true
+	}
+
+	return NamingCamelCase, true
+}
+
+// FieldNaming lets clients use legacy snake_case field names, chosen by
+// the X-Field-Naming header or the API key's pinned naming. For those
+// clients, the fields of JSON request bodies and the query parameters are
+// renamed to their canonical names before the request is handled, and the
+// fields of JSON responses back to the legacy ones.
+func FieldNaming(cfg NamingConfig) func(http.Handler) http.Handler {
+	canonical := make(map[string]string, len(cfg.Legacy))
+	for name, legacy := range cfg.Legacy {
+		canonical[legacy] = name
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			naming, ok := cfg.resolve(r)
+			if !ok {
+				writeError(w, http.StatusBadRequest, "Unsupported field naming", "UNSUPPORTED_FIELD_NAMING")
+				return
+			}
+
+			w.Header().Set(fieldNamingHeader, naming)
+			if naming == NamingCamelCase {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			renameQuery(r, canonical)
+			renameBody(r, canonical)
+
+			bw := &bufferedWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(bw, r)
+			if bw.passthrough {
+				return
+			}
+
+			body := bw.body.Bytes()
+			if isJSON(w.Header()) {
+				if renamed, ok := renameJSON(body, cfg.Legacy); ok {
+					body = renamed
+				}
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(bw.statusCode)
+			w.Write(body)
+		})
+	}
+}
+
+// renameQuery renames the query parameters of r found in names.
+func renameQuery(r *http.Request, names map[string]string) {
+	query := r.URL.Query()
+	renamed := false
+	for key, values := range query {
+		if name, ok := names[key]; ok {
+			delete(query, key)
+			query[name] = append(query[name], values...)
+			renamed = true
+		}
+	}
+	if renamed {
+		r.URL.RawQuery = query.Encode()
+	}
+}
+
+// renameBody renames the fields of a JSON request body found in names.
+// Bodies that are not JSON, such as CSV imports, are left as they are.
+func renameBody(r *http.Request, names map[string]string) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		// Let the handler see the error, e.g. that the body is too large
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return
+	}
+	if renamed, ok := renameJSON(body, names); ok {
+		body = renamed
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+}
+
+// errReader is a reader that always fails with err.
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+// renameJSON returns the JSON document data with the object keys found in
+// names renamed, or false if data is not JSON.
+func renameJSON(data []byte, names map[string]string) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as written, so large IDs do not lose precision
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, false
+	}
+	renamed, err := json.Marshal(renameKeys(decoded, names))
+	if err != nil {
+		return nil, false
+	}
+	return append(renamed, '\n'), true
+}
+
+// renameKeys renames the keys found in names of every object in v.
+func renameKeys(v interface{}, names map[string]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if name, ok := names[key]; ok {
+				key = name
+			}
+			out[key] = renameKeys(value, names)
+		}
+		return out
+	case []interface{}:
+		for i, value := range v {
+			v[i] = renameKeys(value, names)
+		}
+		return v
+	}
+	return v
+}
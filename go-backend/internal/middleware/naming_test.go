@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestFieldNaming(t *testing.T) {
+	t.Parallel()
+
+	mw := FieldNaming(NamingConfig{Pins: map[string]string{"legacy-key": NamingSnakeCase}})
+
+	middlewaretest.Run(t, mw, []middlewaretest.Case{
+		{
+			Name:           "default naming",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-Field-Naming": NamingCamelCase},
+		},
+		{
+			Name:           "pinned key",
+			Headers:        map[string]string{"X-API-Key": "legacy-key"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-Field-Naming": NamingSnakeCase},
+		},
+		{
+			Name:           "explicit header overrides the pin",
+			Headers:        map[string]string{"X-API-Key": "legacy-key", "X-Field-Naming": NamingCamelCase},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-Field-Naming": NamingCamelCase},
+		},
+		{
+			Name:           "unsupported naming",
+			Headers:        map[string]string{"X-Field-Naming": "kebab-case"},
+			WantStatus:     http.StatusBadRequest,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("UNSUPPORTED_FIELD_NAMING"),
+		},
+	})
+}
+
+func TestFieldNaming_RenamesFields(t *testing.T) {
+	t.Parallel()
+
+	mw := FieldNaming(NamingConfig{Legacy: map[string]string{"userId": "user_id", "createdAt": "created_at", "inProgress": "in_progress"}})
+
+	var gotBody, gotQuery string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody, gotQuery = string(body), r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"tasks":[{"id":12345678901234567,"userId":2}],"counts":{"in-progress":1,"inProgress":1}}`))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/?user_id=2&status=pending", strings.NewReader(`{"title":"Legacy","user_id":2}`))
+	req.Header.Set("X-Field-Naming", NamingSnakeCase)
+	rr := httptest.NewRecorder()
+	mw(next).ServeHTTP(rr, req)
+
+	if gotBody != `{"title":"Legacy","userId":2}`+"\n" || gotQuery != "status=pending&userId=2" {
+		t.Errorf("expected the request renamed to canonical names, got body %q and query %q", gotBody, gotQuery)
+	}
+	want := `{"counts":{"in-progress":1,"in_progress":1},"tasks":[{"id":12345678901234567,"user_id":2}]}` + "\n"
+	if rr.Code != http.StatusCreated || rr.Body.String() != want {
+		t.Errorf("expected the response renamed to legacy names with data keys and numbers kept, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestFieldNaming_KeepsNonJSON(t *testing.T) {
+	t.Parallel()
+
+	mw := FieldNaming(NamingConfig{Legacy: map[string]string{"userId": "user_id"}})
+	tooLarge := errors.New("body too large")
+
+	var gotBody string
+	var gotErr error
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		gotBody, gotErr = string(body), err
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("userId\n2\n"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("user_id\n2\n"))
+	req.Header.Set("X-Field-Naming", NamingSnakeCase)
+	rr := httptest.NewRecorder()
+	mw(next).ServeHTTP(rr, req)
+	if gotBody != "user_id\n2\n" || rr.Body.String() != "userId\n2\n" {
+		t.Errorf("expected CSV to pass through unchanged, got request %q and response %q", gotBody, rr.Body)
+	}
+
+	// Errors reading the body reach the handler
+	req = httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader(`{"user_id"`), errReader{tooLarge}))
+	req.Header.Set("X-Field-Naming", NamingSnakeCase)
+	mw(next).ServeHTTP(httptest.NewRecorder(), req)
+	if !errors.Is(gotErr, tooLarge) || gotBody != `{"user_id"` {
+		t.Errorf("expected the read error after the partial body, got %q and %v", gotBody, gotErr)
+	}
+}
@@ -1,27 +1,75 @@
 package middleware
 
 import (
+	"container/list"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+// Limiter decides whether a client identified by key may make a request.
+// Implementations must be safe for concurrent use.
+type Limiter interface {
+	// Allow records a request for key and reports whether it is within the
+	// limit, along with the number of requests remaining in the window.
+	Allow(key string) (bool, int)
+	// Limit returns the maximum number of requests per window.
+	Limit() int
+	// Window returns the rate limiting window.
+	Window() time.Duration
+	// SetLimit changes the limit and window, e.g. when the configuration
+	// is reloaded. Requests already made count toward the new limit.
+	SetLimit(limit int, window time.Duration)
+	// RetryAfter returns how long until key may make another request,
+	// based on when the oldest request in the window expires.
+	RetryAfter(key string) time.Duration
+	// Close stops background work and releases resources.
+	Close()
+}
+
+// clientRequests holds the request timestamps of one client, oldest first.
+type clientRequests struct {
+	ip    string
+	times []time.Time
+}
+
 // RateLimiter tracks request counts per IP within a time window.
+// It keeps state in memory, so each replica enforces its own limit.
+// When maxClients is set, the least recently seen clients are evicted
+// once that many are tracked, bounding memory under spoofed-IP floods.
 type RateLimiter struct {
-	requests map[string][]time.Time
-	limit    int
-	window   time.Duration
-	mu       sync.Mutex
+	clients    map[string]*list.Element
+	recency    *list.List // front is most recently seen
+	limit      int
+	window     time.Duration
+	maxClients int
+	evictions  int64
+	mu         sync.Mutex
+	done       chan struct{}
+	closeOnce  sync.Once
 }
 
-// NewRateLimiter creates a RateLimiter with the specified limit and window.
+// NewRateLimiter creates an unbounded RateLimiter with the specified limit and window.
 func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return NewBoundedRateLimiter(limit, window, 0)
+}
+
+// NewBoundedRateLimiter creates a RateLimiter that tracks at most maxClients
+// IPs, evicting the least recently seen IP when full. An evicted client
+// starts with a fresh window. A maxClients of zero or less means unbounded.
+// Expired clients are cleaned up in the background until Close is called.
+func NewBoundedRateLimiter(limit int, window time.Duration, maxClients int) *RateLimiter {
 	rl := &RateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    limit,
-		window:   window,
+		clients:    make(map[string]*list.Element),
+		recency:    list.New(),
+		limit:      limit,
+		window:     window,
+		maxClients: maxClients,
+		done:       make(chan struct{}),
 	}
 
 	go rl.cleanup()
@@ -36,79 +84,160 @@ func (rl *RateLimiter) Allow(ip string) (bool, int) {
 	defer rl.mu.Unlock()
 
 	now := time.Now()
-	windowStart := now.Add(-rl.window)
 
-	requests, exists := rl.requests[ip]
+	elem, exists := rl.clients[ip]
+	if exists {
+		rl.recency.MoveToFront(elem)
+	} else {
+		elem = rl.recency.PushFront(&clientRequests{ip: ip})
+		rl.clients[ip] = elem
+		rl.evictOverflow()
+	}
+
+	client := elem.Value.(*clientRequests)
+	client.times = pruneBefore(client.times, now.Add(-rl.window))
+
+	if len(client.times) >= rl.limit {
+		return false, 0
+	}
+
+	client.times = append(client.times, now)
+
+	return true, rl.limit - len(client.times)
+}
+
+// RetryAfter returns how long until the IP may make another request.
+// Returns zero if the IP is currently within the limit.
+func (rl *RateLimiter) RetryAfter(ip string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	elem, exists := rl.clients[ip]
 	if !exists {
-		requests = []time.Time{}
+		return 0
 	}
 
-	// Filter out requests outside the window
-	validRequests := []time.Time{}
-	for _, reqTime := range requests {
-		if reqTime.After(windowStart) {
-			validRequests = append(validRequests, reqTime)
-		}
+	now := time.Now()
+	client := elem.Value.(*clientRequests)
+	client.times = pruneBefore(client.times, now.Add(-rl.window))
+
+	if len(client.times) < rl.limit {
+		return 0
 	}
 
-	if len(validRequests) >= rl.limit {
-		rl.requests[ip] = validRequests
-		return false, 0
+	return client.times[0].Add(rl.window).Sub(now)
+}
+
+// Limit returns the maximum number of requests per window.
+func (rl *RateLimiter) Limit() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limit
+}
+
+// Window returns the rate limiting window.
+func (rl *RateLimiter) Window() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.window
+}
+
+// SetLimit changes the limit and window.
+func (rl *RateLimiter) SetLimit(limit int, window time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit, rl.window = limit, window
+}
+
+// Stats returns the number of tracked clients and evictions so far.
+func (rl *RateLimiter) Stats() (tracked int, evictions int64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.clients), rl.evictions
+}
+
+// evictOverflow drops the least recently seen clients until at most
+// maxClients are tracked. Must be called with mu held.
+func (rl *RateLimiter) evictOverflow() {
+	if rl.maxClients <= 0 {
+		return
 	}
 
-	validRequests = append(validRequests, now)
-	rl.requests[ip] = validRequests
+	for rl.recency.Len() > rl.maxClients {
+		rl.removeElement(rl.recency.Back())
+		rl.evictions++
+	}
+}
+
+// removeElement removes a client from both the map and the recency list.
+// Must be called with mu held.
+func (rl *RateLimiter) removeElement(elem *list.Element) {
+	rl.recency.Remove(elem)
+	delete(rl.clients, elem.Value.(*clientRequests).ip)
+}
+
+// pruneBefore drops timestamps at or before windowStart, reusing the
+// slice's backing array. Timestamps are kept oldest first.
+func pruneBefore(times []time.Time, windowStart time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(windowStart) {
+		i++
+	}
+	if i == 0 {
+		return times
+	}
+	return append(times[:0], times[i:]...)
+}
 
-	return true, rl.limit - len(validRequests)
+// Close stops the background cleanup goroutine. It is safe to call more than once.
+func (rl *RateLimiter) Close() {
+	rl.closeOnce.Do(func() { close(rl.done) })
 }
 
 func (rl *RateLimiter) cleanup() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-rl.done:
+			return
+		case <-ticker.C:
+		}
+
 		rl.mu.Lock()
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
-
-		for ip, requests := range rl.requests {
-			validRequests := []time.Time{}
-			for _, reqTime := range requests {
-				if reqTime.After(windowStart) {
-					validRequests = append(validRequests, reqTime)
-				}
-			}
+		windowStart := time.Now().Add(-rl.window)
+
+		for _, elem := range rl.clients {
+			client := elem.Value.(*clientRequests)
+			client.times = pruneBefore(client.times, windowStart)
 
-			if len(validRequests) == 0 {
-				delete(rl.requests, ip)
-			} else {
-				rl.requests[ip] = validRequests
+			if len(client.times) == 0 {
+				rl.removeElement(elem)
 			}
 		}
 		rl.mu.Unlock()
 	}
 }
 
-// RateLimit applies rate limiting using the provided RateLimiter.
-func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
+// RateLimit applies rate limiting using the provided Limiter.
+func RateLimit(limiter Limiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ip := getClientIP(r)
 
 			allowed, remaining := limiter.Allow(ip)
 
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.limit))
+			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.Limit()))
 			w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-			w.Header().Set("X-RateLimit-Window", limiter.window.String())
+			w.Header().Set("X-RateLimit-Window", limiter.Window().String())
 
 			if !allowed {
-				resetTime := time.Now().Add(limiter.window)
-				w.Header().Set("X-RateLimit-Reset", resetTime.Format(time.RFC3339))
+				retryAfter := limiter.RetryAfter(ip)
+				w.Header().Set("X-RateLimit-Reset", time.Now().Add(retryAfter).Format(time.RFC3339))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
 
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte(`{"success":false,"error":"Rate limit exceeded","code":"RATE_LIMIT_EXCEEDED"}`))
+				writeError(w, http.StatusTooManyRequests, "Rate limit exceeded", "RATE_LIMIT_EXCEEDED")
 				return
 			}
 
@@ -117,6 +246,50 @@ func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
 	}
 }
 
+// AuthRateLimit limits authentication attempts both per client IP and per
+// target account, so password guessing spread across many IPs is still
+// stopped. account returns the account a request targets, or "" if none,
+// in which case only the IP limit applies. Both limits share limiter,
+// keyed as "ip:<addr>" and "account:<id>".
+func AuthRateLimit(limiter Limiter, account func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []string{"ip:" + getClientIP(r)}
+			if acct := account(r); acct != "" {
+				keys = append(keys, "account:"+acct)
+			}
+
+			remaining := limiter.Limit()
+			for _, key := range keys {
+				allowed, left := limiter.Allow(key)
+				if !allowed {
+					retryAfter := limiter.RetryAfter(key)
+					w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+
+					writeError(w, http.StatusTooManyRequests, "Too many authentication attempts", "AUTH_RATE_LIMIT_EXCEEDED")
+					return
+				}
+				remaining = min(remaining, left)
+			}
+
+			w.Header().Set("X-Auth-RateLimit-Limit", strconv.Itoa(limiter.Limit()))
+			w.Header().Set("X-Auth-RateLimit-Remaining", strconv.Itoa(remaining))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// retryAfterSeconds rounds d up to whole seconds, with a minimum of one,
+// as required by the Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
 func getClientIP(r *http.Request) string {
 	// Try X-Forwarded-For header (when behind a proxy)
 	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each Redis round trip so a slow Redis cannot stall requests.
+const redisTimeout = 500 * time.Millisecond
+
+// slidingWindowScript implements a sliding window log in a sorted set.
+// It drops entries older than the window, then records the request if
+// the limit has not been reached. Returns {allowed, remaining}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+local count = redis.call('ZCARD', key)
+if count >= limit then
+	return {0, 0}
+end
+
+redis.call('ZADD', key, now, member)
+redis.call('PEXPIRE', key, window)
+
+return {1, limit - count - 1}
+`)
+
+// oldestScript drops expired entries and returns the score (in
+// milliseconds) of the oldest request still in the window, or -1 if the
+// key is under the limit.
+var oldestScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+if redis.call('ZCARD', key) < limit then
+	return -1
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return tonumber(oldest[2])
+`)
+
+// RedisRateLimiter tracks request counts per IP in Redis so the limit
+// is shared across all replicas.
+type RedisRateLimiter struct {
+	client *redis.Client
+	prefix string
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	seq    atomic.Uint64
+}
+
+// NewRedisRateLimiter creates a RedisRateLimiter with the specified limit and window.
+func NewRedisRateLimiter(client *redis.Client, limit int, window time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		prefix: "ratelimit:",
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow checks if the IP is within the rate limit.
+// Returns whether the request is allowed and the remaining requests.
+// If Redis is unavailable the request is allowed, so an outage of the
+// limiter does not take the API down with it.
+func (rl *RedisRateLimiter) Allow(ip string) (bool, int) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	limit, window := rl.limits()
+	now := time.Now()
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), rl.seq.Add(1))
+
+	result, err := slidingWindowScript.Run(ctx, rl.client,
+		[]string{rl.prefix + ip},
+		now.UnixMilli(), window.Milliseconds(), limit, member,
+	).Int64Slice()
+	if err != nil || len(result) != 2 {
+		log.Printf("Warning: Redis rate limiter unavailable: %v", err)
+		return true, limit
+	}
+
+	return result[0] == 1, int(result[1])
+}
+
+// RetryAfter returns how long until the IP may make another request.
+// Returns the full window if Redis is unavailable.
+func (rl *RedisRateLimiter) RetryAfter(ip string) time.Duration {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	limit, window := rl.limits()
+	now := time.Now()
+	oldest, err := oldestScript.Run(ctx, rl.client,
+		[]string{rl.prefix + ip},
+		now.UnixMilli(), window.Milliseconds(), limit,
+	).Int64()
+	if err != nil {
+		log.Printf("Warning: Redis rate limiter unavailable: %v", err)
+		return window
+	}
+	if oldest < 0 {
+		return 0
+	}
+
+	return time.UnixMilli(oldest).Add(window).Sub(now)
+}
+
+// Limit returns the maximum number of requests per window.
+func (rl *RedisRateLimiter) Limit() int {
+	limit, _ := rl.limits()
+	return limit
+}
+
+// Window returns the rate limiting window.
+func (rl *RedisRateLimiter) Window() time.Duration {
+	_, window := rl.limits()
+	return window
+}
+
+// SetLimit changes the limit and window. Every replica sets its own, so
+// they enforce different limits until all are reloaded.
+func (rl *RedisRateLimiter) SetLimit(limit int, window time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit, rl.window = limit, window
+}
+
+// limits returns the limit and window.
+func (rl *RedisRateLimiter) limits() (int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limit, rl.window
+}
+
+// Close closes the Redis client.
+func (rl *RedisRateLimiter) Close() {
+	if err := rl.client.Close(); err != nil {
+		log.Printf("Warning: Redis rate limiter close failed: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(2, time.Minute)
+	t.Cleanup(limiter.Close)
+	client := map[string]string{"X-Forwarded-For": "203.0.113.7"}
+
+	middlewaretest.Run(t, RateLimit(limiter), []middlewaretest.Case{
+		{
+			Name:           "first request",
+			Headers:        client,
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders: map[string]string{
+				"X-RateLimit-Limit":     "2",
+				"X-RateLimit-Remaining": "1",
+				"X-RateLimit-Window":    "1m0s",
+			},
+			WantHeadersAbsent: []string{"Retry-After"},
+		},
+		{
+			Name:           "second request",
+			Headers:        client,
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-RateLimit-Remaining": "0"},
+		},
+		{
+			Name:               "over the limit",
+			Headers:            client,
+			WantStatus:         http.StatusTooManyRequests,
+			WantNextCalled:     false,
+			WantHeaders:        map[string]string{"X-RateLimit-Remaining": "0"},
+			WantHeadersPresent: []string{"Retry-After", "X-RateLimit-Reset"},
+			CheckResponse:      expectErrorCode("RATE_LIMIT_EXCEEDED"),
+		},
+		{
+			Name:           "other client unaffected",
+			Headers:        map[string]string{"X-Forwarded-For": "203.0.113.8"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+	})
+}
+
+func TestRateLimit_RetryAfterFromOldestRequest(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(1, time.Minute)
+	t.Cleanup(limiter.Close)
+	tc := middlewaretest.Case{RemoteAddr: "198.51.100.1:5555"}
+
+	middlewaretest.Do(RateLimit(limiter), tc)
+	rr, _, _ := middlewaretest.Do(RateLimit(limiter), tc)
+
+	retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("expected numeric Retry-After, got %q", rr.Header().Get("Retry-After"))
+	}
+	if retryAfter < 59 || retryAfter > 60 {
+		t.Errorf("expected Retry-After close to 60s, got %d", retryAfter)
+	}
+}
+
+func TestAuthRateLimit(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(1, time.Minute)
+	t.Cleanup(limiter.Close)
+	account := func(r *http.Request) string { return r.Header.Get("X-Account") }
+
+	middlewaretest.Run(t, AuthRateLimit(limiter, account), []middlewaretest.Case{
+		{
+			Name:           "first attempt",
+			Headers:        map[string]string{"X-Forwarded-For": "203.0.113.1", "X-Account": "alice"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-Auth-RateLimit-Limit": "1", "X-Auth-RateLimit-Remaining": "0"},
+		},
+		{
+			Name:               "same account from another IP",
+			Headers:            map[string]string{"X-Forwarded-For": "203.0.113.2", "X-Account": "alice"},
+			WantStatus:         http.StatusTooManyRequests,
+			WantHeadersPresent: []string{"Retry-After"},
+			CheckResponse:      expectErrorCode("AUTH_RATE_LIMIT_EXCEEDED"),
+		},
+		{
+			Name:       "same IP against another account",
+			Headers:    map[string]string{"X-Forwarded-For": "203.0.113.1", "X-Account": "bob"},
+			WantStatus: http.StatusTooManyRequests,
+		},
+		{
+			Name:           "new IP and account",
+			Headers:        map[string]string{"X-Forwarded-For": "203.0.113.3", "X-Account": "carol"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+		},
+	})
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(3, 50*time.Millisecond)
+	t.Cleanup(limiter.Close)
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("ip"); !allowed {
+			t.Fatalf("request %d: expected to be allowed", i+1)
+		}
+	}
+	if allowed, remaining := limiter.Allow("ip"); allowed || remaining != 0 {
+		t.Errorf("expected fourth request to be denied with 0 remaining, got allowed=%v remaining=%d", allowed, remaining)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if allowed, _ := limiter.Allow("ip"); !allowed {
+		t.Error("expected request to be allowed after window elapsed")
+	}
+}
+
+func TestRateLimiter_SetLimit(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(3, time.Minute)
+	t.Cleanup(limiter.Close)
+
+	limiter.Allow("ip")
+	limiter.Allow("ip")
+	limiter.SetLimit(2, time.Hour)
+	if allowed, _ := limiter.Allow("ip"); allowed {
+		t.Error("expected requests already made to count toward the lower limit")
+	}
+	if limiter.Limit() != 2 || limiter.Window() != time.Hour {
+		t.Errorf("expected the new limit and window, got %d per %v", limiter.Limit(), limiter.Window())
+	}
+
+	limiter.SetLimit(5, time.Hour)
+	if allowed, remaining := limiter.Allow("ip"); !allowed || remaining != 2 {
+		t.Errorf("expected a request under the higher limit, got allowed=%v remaining=%d", allowed, remaining)
+	}
+}
+
+func TestRateLimiter_BoundedEvictsLeastRecentlySeen(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewBoundedRateLimiter(1, time.Minute, 2)
+	t.Cleanup(limiter.Close)
+
+	limiter.Allow("a")
+	limiter.Allow("b")
+	limiter.Allow("a") // a is now most recently seen
+	limiter.Allow("c") // evicts b
+
+	if tracked, evictions := limiter.Stats(); tracked != 2 || evictions != 1 {
+		t.Errorf("expected 2 tracked clients and 1 eviction, got %d and %d", tracked, evictions)
+	}
+	if allowed, _ := limiter.Allow("a"); allowed {
+		t.Error("expected a to still be limited")
+	}
+	if allowed, _ := limiter.Allow("b"); !allowed {
+		t.Error("expected evicted client b to start a fresh window")
+	}
+}
+
+func TestRateLimiter_BoundedMemoryUnderFlood(t *testing.T) {
+	t.Parallel()
+
+	const maxClients = 1000
+	limiter := NewBoundedRateLimiter(10, time.Minute, maxClients)
+	t.Cleanup(limiter.Close)
+
+	for i := 0; i < 100*maxClients; i++ {
+		limiter.Allow(floodIP(i))
+	}
+
+	if tracked, _ := limiter.Stats(); tracked != maxClients {
+		t.Errorf("expected %d tracked clients, got %d", maxClients, tracked)
+	}
+}
+
+// floodIP returns a distinct IPv4 address for i, as a spoofed-IP flood would.
+func floodIP(i int) string {
+	return "10." + strconv.Itoa(i>>16&0xff) + "." + strconv.Itoa(i>>8&0xff) + "." + strconv.Itoa(i&0xff)
+}
+
+// benchmarkUniqueIPs records one request from each of n unique IPs and
+// reports the heap retained by the limiter.
+func benchmarkUniqueIPs(b *testing.B, n, maxClients int) {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = floodIP(i)
+	}
+
+	var retained uint64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		limiter := NewBoundedRateLimiter(100, time.Minute, maxClients)
+		limiter.Close()
+		for _, ip := range ips {
+			limiter.Allow(ip)
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if after.HeapAlloc > before.HeapAlloc {
+			retained += after.HeapAlloc - before.HeapAlloc
+		}
+		runtime.KeepAlive(limiter)
+	}
+
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+	b.ReportMetric(float64(retained)/float64(b.N)/float64(n), "retained-B/ip")
+}
+
+func BenchmarkRateLimiter_100kUniqueIPs(b *testing.B) {
+	benchmarkUniqueIPs(b, 100_000, 0)
+}
+
+func BenchmarkRateLimiter_100kUniqueIPsBounded(b *testing.B) {
+	benchmarkUniqueIPs(b, 100_000, 10_000)
+}
+
+func TestRateLimiter_Close(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(1, time.Minute)
+	limiter.Close()
+	limiter.Close() // safe to call twice
+
+	if allowed, _ := limiter.Allow("ip"); !allowed {
+		t.Error("expected Allow to keep working after Close")
+	}
+}
+
+func TestGetClientIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		want       string
+	}{
+		{"forwarded for", map[string]string{"X-Forwarded-For": "203.0.113.1, 10.0.0.1"}, "10.0.0.2:80", "203.0.113.1"},
+		{"real ip", map[string]string{"X-Real-IP": "203.0.113.2"}, "10.0.0.2:80", "203.0.113.2"},
+		{"remote addr", nil, "198.51.100.3:4321", "198.51.100.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			req.RemoteAddr = tt.remoteAddr
+
+			if got := getClientIP(req); got != tt.want {
+				t.Errorf("getClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
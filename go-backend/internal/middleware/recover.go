@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+)
+
+// stackPanic is a panic raised again away from the goroutine it happened
+// in, as Timeout does, with the stack it happened with: the stack where
+// it is raised again no longer shows the handler that panicked.
+type stackPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// String describes the panic with its stack, for when the server logs it.
+func (p stackPanic) String() string {
+	return fmt.Sprintf("%v\n%s", p.value, p.stack)
+}
+
+// withStack wraps p, recovered by the caller, with the current stack to be
+// raised again elsewhere. http.ErrAbortHandler and panics already wrapped
+// are left as they are.
+func withStack(p interface{}) interface{} {
+	if _, ok := p.(stackPanic); ok {
+		return p
+	}
+	if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+		return p
+	}
+	return stackPanic{value: p, stack: debug.Stack()}
+}
+
+// recoverWriter records whether the response has been started, after
+// which an error response can no longer be sent.
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (rw *recoverWriter) WriteHeader(code int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recoverWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (rw *recoverWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack hands the connection over to the handler, after which no
+// error response can be sent either.
+func (rw *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Recover turns a panic in a handler into 500 Internal Server Error with
+// a JSON error, logging the panic and its stack with the request ID, so
+// one faulty handler neither leaves the client with an empty reply nor
+// takes down the connection. A response that had already been started
+// cannot be replaced, so its connection is aborted instead. A panic that
+// Timeout raised again is logged with the stack of the handler's goroutine.
+// http.ErrAbortHandler, which handlers panic with to abort a response
+// on purpose, is passed on as it is.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+			stack := debug.Stack()
+			if sp, ok := p.(stackPanic); ok {
+				p, stack = sp.value, sp.stack
+			}
+
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, GetRequestID(r.Context()), p, stack)
+			if rw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			// Headers describing the response the handler meant to send
+			for _, name := range []string{"Content-Disposition", "Content-Encoding", "Content-Length", "ETag", "Last-Modified"} {
+				w.Header().Del(name)
+			}
+			writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	var user *struct{ Name string }
+	panics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "attachment")
+		w.Write([]byte(user.Name))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+	req.Header.Set(requestIDHeader, "req-42")
+	rr := httptest.NewRecorder()
+	RequestID(Recover(panics)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rr.Code)
+	}
+	expectErrorCode("INTERNAL_ERROR")(t, rr)
+	if rr.Header().Get("Content-Disposition") != "" || rr.Header().Get(requestIDHeader) != "req-42" {
+		t.Errorf("expected the handler's headers dropped and the request ID kept, got %v", rr.Header())
+	}
+	if got := logs.String(); !strings.Contains(got, "req-42") || !strings.Contains(got, "nil pointer") ||
+		!strings.Contains(got, "recover_test.go") {
+		t.Errorf("expected the panic logged with the request ID and stack, got %q", got)
+	}
+}
+
+func TestRecover_Started(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"response started", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic("halfway")
+		}},
+		{"aborted on purpose", func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if p := recover(); p != http.ErrAbortHandler {
+					t.Errorf("expected the connection aborted, got %v", p)
+				}
+			}()
+			Recover(tt.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(r.Context())
+	}))
+
+	for _, id := range []string{"", "has spaces", strings.Repeat("x", maxRequestIDLen+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, id)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if len(seen) != 16 || rr.Header().Get(requestIDHeader) != seen {
+			t.Errorf("expected a new ID for %q, got %q, echoed %q", id, seen, rr.Header().Get(requestIDHeader))
+		}
+	}
+}
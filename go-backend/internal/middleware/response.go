@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-backend/internal/model"
+)
+
+// writeError writes a standardized error response, matching the format
+// used by the handlers.
+func writeError(w http.ResponseWriter, status int, message, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.ErrorResponse{
+		Success: false,
+		Error:   message,
+		Code:    code,
+	})
+}
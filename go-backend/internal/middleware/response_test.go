@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"go-backend/internal/model"
+)
+
+// expectErrorCode asserts that the response is a standard error with the given code.
+func expectErrorCode(code string) func(t *testing.T, rr *httptest.ResponseRecorder) {
+	return func(t *testing.T, rr *httptest.ResponseRecorder) {
+		t.Helper()
+
+		var response model.ErrorResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode error response: %v", err)
+		}
+		if response.Success {
+			t.Error("expected success false")
+		}
+		if response.Code != code {
+			t.Errorf("expected code '%s', got '%s'", code, response.Code)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout bounds how long a request may take. The request's context gets
+// a deadline d away, and if the handler has not returned by then the
+// client gets 503 Service Unavailable with a JSON error instead. Handlers
+// keep running until they notice the deadline; anything they write after
+// it is discarded. A handler's panic is raised again in the request's
+// goroutine, carrying the stack it happened with for Recover to log.
+//
+// The response is buffered until the handler returns, so requests that
+// stream their response, for which stream returns true, are passed through
+// without a deadline, and the server's write timeout is lifted for them.
+func Timeout(d time.Duration, stream func(*http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if stream != nil && stream(r) {
+				http.NewResponseController(w).SetWriteDeadline(time.Time{})
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- withStack(p)
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.flushTo(w)
+			case <-ctx.Done():
+				tw.mu.Lock()
+				tw.timedOut = true
+				tw.mu.Unlock()
+
+				// If the client went away there is no one to tell
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					writeError(w, http.StatusServiceUnavailable, "Request timed out", "REQUEST_TIMEOUT")
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers a response until the handler returns, so a
+// timed-out handler cannot write half a response.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
+
+// flushTo writes the buffered response to w.
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	dst := w.Header()
+	for name, values := range tw.header {
+		dst[name] = values
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	w.WriteHeader(tw.status)
+	w.Write(tw.buf.Bytes())
+}
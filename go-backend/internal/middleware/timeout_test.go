@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	middlewaretest.Run(t, Timeout(time.Second, nil), []middlewaretest.Case{
+		{
+			Name:           "fast request passes through",
+			NextStatus:     http.StatusCreated,
+			WantStatus:     http.StatusCreated,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"Content-Type": "application/json"},
+			CheckRequest: func(t *testing.T, r *http.Request) {
+				if _, ok := r.Context().Deadline(); !ok {
+					t.Error("expected the request context to have a deadline")
+				}
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				if rr.Body.String() != `{"ok":true}` {
+					t.Errorf("expected the handler's body, got %q", rr.Body.String())
+				}
+			},
+		},
+	})
+}
+
+func TestTimeout_Deadline(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("too late"))
+		finished <- err
+	})
+
+	rr := httptest.NewRecorder()
+	Timeout(20*time.Millisecond, nil)(slow).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	close(release)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rr.Code)
+	}
+	expectErrorCode("REQUEST_TIMEOUT")(t, rr)
+
+	if err := <-finished; err != http.ErrHandlerTimeout {
+		t.Errorf("expected late writes to fail with ErrHandlerTimeout, got %v", err)
+	}
+	if strings.Contains(rr.Body.String(), "too late") {
+		t.Error("expected the late write to be discarded")
+	}
+}
+
+func TestTimeout_StreamsUnbuffered(t *testing.T) {
+	t.Parallel()
+
+	stream := func(r *http.Request) bool { return strings.HasSuffix(r.URL.Path, "/export") }
+	mw := Timeout(time.Nanosecond, stream)
+
+	middlewaretest.Run(t, mw, []middlewaretest.Case{
+		{
+			Name:           "streamed request has no deadline",
+			Path:           "/api/users/export",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			CheckRequest: func(t *testing.T, r *http.Request) {
+				if _, ok := r.Context().Deadline(); ok {
+					t.Error("expected no deadline on a streamed request")
+				}
+			},
+		},
+	})
+}
+
+// panickingHandler is a handler that panics, for its name in stacks.
+func panickingHandler(http.ResponseWriter, *http.Request) {
+	panic("boom")
+}
+
+func TestTimeout_PropagatesPanic(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	rr := httptest.NewRecorder()
+	Recover(Timeout(time.Second, nil)(http.HandlerFunc(panickingHandler))).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rr.Code)
+	}
+	if got := logs.String(); !strings.Contains(got, "): boom\n") || !strings.Contains(got, "panickingHandler") {
+		t.Errorf("expected the panic logged with the stack of the handler, got %q", got)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestTiming(t *testing.T) {
+	t.Parallel()
+
+	middlewaretest.Run(t, Timing, []middlewaretest.Case{
+		{
+			Name:               "sets response time",
+			WantStatus:         http.StatusOK,
+			WantNextCalled:     true,
+			WantHeadersPresent: []string{"X-Response-Time-ms"},
+		},
+		{
+			Name:               "preserves handler status",
+			NextStatus:         http.StatusNotFound,
+			WantStatus:         http.StatusNotFound,
+			WantNextCalled:     true,
+			WantHeadersPresent: []string{"X-Response-Time-ms"},
+		},
+	})
+}
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	middlewaretest.Run(t, Logging, []middlewaretest.Case{
+		{
+			Name:           "passes through status",
+			NextStatus:     http.StatusCreated,
+			WantStatus:     http.StatusCreated,
+			WantNextCalled: true,
+		},
+	})
+}
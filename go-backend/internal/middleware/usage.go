@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"container/list"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// DefaultMaxUsageEntries is how many route and client combinations a
+// UsageTracker made by NewUsageTracker keeps.
+const DefaultMaxUsageEntries = 10000
+
+// usageKey identifies a route and client combination.
+type usageKey struct {
+	method string
+	route  string
+	client string
+}
+
+// usageEntry holds the counters for a usageKey.
+type usageEntry struct {
+	key      usageKey
+	count    int64
+	lastSeen time.Time
+}
+
+// UsageTracker records hit counts and last-seen timestamps per route and client,
+// so we know whether an endpoint is still in use before changing it.
+// Routes are the patterns requests matched, so paths cannot add routes,
+// and at most maxEntries combinations are kept, the least recently seen
+// evicted first, as clients named by unchecked API keys are unbounded.
+type UsageTracker struct {
+	mu         sync.Mutex
+	entries    map[usageKey]*list.Element
+	recency    *list.List // front is most recently seen
+	maxEntries int
+}
+
+// NewUsageTracker creates an empty UsageTracker keeping at most
+// DefaultMaxUsageEntries route and client combinations.
+func NewUsageTracker() *UsageTracker {
+	return NewBoundedUsageTracker(DefaultMaxUsageEntries)
+}
+
+// NewBoundedUsageTracker creates an empty UsageTracker keeping at most
+// maxEntries route and client combinations. A maxEntries of zero or less
+// means unbounded.
+func NewBoundedUsageTracker(maxEntries int) *UsageTracker {
+	return &UsageTracker{
+		entries:    make(map[usageKey]*list.Element),
+		recency:    list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// Record records a hit for the given method, route and client.
+func (ut *UsageTracker) Record(method, route, client string) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	key := usageKey{method: method, route: route, client: client}
+	elem, exists := ut.entries[key]
+	if exists {
+		ut.recency.MoveToFront(elem)
+	} else {
+		elem = ut.recency.PushFront(&usageEntry{key: key})
+		ut.entries[key] = elem
+		for ut.maxEntries > 0 && ut.recency.Len() > ut.maxEntries {
+			oldest := ut.recency.Back()
+			ut.recency.Remove(oldest)
+			delete(ut.entries, oldest.Value.(*usageEntry).key)
+		}
+	}
+
+	entry := elem.Value.(*usageEntry)
+	entry.count++
+	entry.lastSeen = time.Now()
+}
+
+// Snapshot returns usage for all routes, sorted by route, method and client.
+func (ut *UsageTracker) Snapshot() []model.RouteUsage {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	usage := make([]model.RouteUsage, 0, len(ut.entries))
+	for key, elem := range ut.entries {
+		entry := elem.Value.(*usageEntry)
+		usage = append(usage, model.RouteUsage{
+			Method:   key.method,
+			Route:    key.route,
+			Client:   key.client,
+			Count:    entry.count,
+			LastSeen: entry.lastSeen.Format(time.RFC3339),
+		})
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Route != usage[j].Route {
+			return usage[i].Route < usage[j].Route
+		}
+		if usage[i].Method != usage[j].Method {
+			return usage[i].Method < usage[j].Method
+		}
+		return usage[i].Client < usage[j].Client
+	})
+
+	return usage
+}
+
+// Track records usage of route, the path pattern next is registered
+// for, such as /api/tasks/{id}, by every request it serves.
+func (ut *UsageTracker) Track(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ut.Record(r.Method, route, ClientID(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientID identifies the caller by API key when present, otherwise by IP.
+// API keys are masked so usage reports and audit trails do not leak
+// credentials.
+func ClientID(r *http.Request) string {
+	if apiKey := strings.TrimSpace(r.Header.Get(apiKeyHeader)); apiKey != "" {
+		if len(apiKey) > 4 {
+			apiKey = apiKey[:4] + "****"
+		}
+		return "key:" + apiKey
+	}
+	return "ip:" + getClientIP(r)
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsageTracker_Track(t *testing.T) {
+	t.Parallel()
+
+	tracker := NewUsageTracker()
+	mux := http.NewServeMux()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("GET /api/tasks/{id}", tracker.Track("/api/tasks/{id}", next))
+	mux.Handle("POST /api/users", tracker.Track("/api/users", next))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/tasks/1", nil),
+		httptest.NewRequest(http.MethodGet, "/api/tasks/2", nil),
+		httptest.NewRequest(http.MethodGet, "/api/tasks/1/anything", nil),
+		httptest.NewRequest(http.MethodPost, "/api/users", nil),
+	} {
+		if req.Method == http.MethodGet {
+			req.Header.Set("X-API-Key", "abcdefgh")
+		} else {
+			req.RemoteAddr = "198.51.100.9:1000"
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	usage := tracker.Snapshot()
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 usage entries, got %d: %+v", len(usage), usage)
+	}
+
+	if usage[0].Route != "/api/tasks/{id}" || usage[0].Count != 2 || usage[0].Client != "key:abcd****" {
+		t.Errorf("unexpected task usage: %+v", usage[0])
+	}
+	if usage[1].Route != "/api/users" || usage[1].Method != http.MethodPost || usage[1].Client != "ip:198.51.100.9" {
+		t.Errorf("unexpected user usage: %+v", usage[1])
+	}
+}
+
+func TestUsageTracker_EvictsLeastRecentlySeen(t *testing.T) {
+	t.Parallel()
+
+	tracker := NewBoundedUsageTracker(2)
+	tracker.Record(http.MethodGet, "/api/tasks", "key:aaaa****")
+	tracker.Record(http.MethodGet, "/api/tasks", "key:bbbb****")
+	tracker.Record(http.MethodGet, "/api/tasks", "key:aaaa****")
+	tracker.Record(http.MethodGet, "/api/tasks", "key:cccc****")
+
+	usage := tracker.Snapshot()
+	if len(usage) != 2 || usage[0].Client != "key:aaaa****" || usage[0].Count != 2 || usage[1].Client != "key:cccc****" {
+		t.Errorf("expected the least recently seen client evicted, got %+v", usage)
+	}
+}
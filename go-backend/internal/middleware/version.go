@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const apiVersionHeader = "X-API-Version"
+
+type versionContextKey struct{}
+
+// VersionAdapter transforms a canonical JSON response body into the shape
+// of a specific API version. A nil adapter serves the canonical shape.
+type VersionAdapter func(status int, body interface{}) interface{}
+
+// VersionConfig configures API version resolution.
+type VersionConfig struct {
+	// Default is the version served when a client has no pin and sends no header.
+	Default string
+	// Adapters maps each supported version to its response adapter.
+	Adapters map[string]VersionAdapter
+	// Pins maps API keys to their preferred version.
+	Pins map[string]string
+}
+
+// Validate reports pins to versions without an adapter. The keys pinned
+// are secrets, so the error names only the versions.
+func (c VersionConfig) Validate() error {
+	var unsupported []string
+	for _, version := range c.Pins {
+		if _, ok := c.Adapters[version]; !ok {
+			unsupported = append(unsupported, strconv.Quote(version))
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("keys pinned to unsupported versions %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// resolve returns the version for the request, or false if the client
+// explicitly asked for an unsupported version. A pin to an unsupported
+// version, which Validate reports, is ignored.
+func (c VersionConfig) resolve(r *http.Request) (string, bool) {
+	if requested := strings.TrimSpace(r.Header.Get(apiVersionHeader)); requested != "" {
+		_, ok := c.Adapters[requested]
+		return requested, ok
+	}
+
+	if pinned, ok := c.Pins[strings.TrimSpace(r.Header.Get(apiKeyHeader))]; ok {
+		if _, supported := c.Adapters[pinned]; supported {
+			return pinned, true
+		}
+	}
+
+	return c.Default, true
+}
+
+// VersionFromContext returns the API version resolved for the request.
+func VersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(versionContextKey{}).(string)
+	return version
+}
+
+// bufferedWriter captures JSON responses so they can be rewritten before
+// sending. Other content types, such as streams, pass straight through.
+type bufferedWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	body        bytes.Buffer
+	wroteHeader bool
+	passthrough bool
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	bw.statusCode = code
+
+	if !isJSON(bw.Header()) {
+		bw.passthrough = true
+		bw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	if bw.passthrough {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.body.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+// Flushing has no effect on buffered JSON responses.
+func (bw *bufferedWriter) Unwrap() http.ResponseWriter {
+	if bw.passthrough {
+		return bw.ResponseWriter
+	}
+	return nil
+}
+
+// Hijack hands the connection over to the handler, e.g. to upgrade it to a
+// WebSocket, after which nothing is buffered or rewritten.
+func (bw *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(bw.ResponseWriter).Hijack()
+	if err == nil {
+		bw.passthrough = true
+	}
+	return conn, rw, err
+}
+
+// isJSON reports whether the response headers declare a JSON body.
+func isJSON(h http.Header) bool {
+	return strings.HasPrefix(h.Get("Content-Type"), "application/json")
+}
+
+// Versioning resolves the API version for each request from the
+// X-API-Version header, the API key's pinned version, or the default,
+// and rewrites JSON responses through that version's adapter.
+func Versioning(cfg VersionConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version, ok := cfg.resolve(r)
+			if !ok {
+				writeError(w, http.StatusBadRequest, "Unsupported API version", "UNSUPPORTED_VERSION")
+				return
+			}
+
+			w.Header().Set(apiVersionHeader, version)
+			r = r.WithContext(context.WithValue(r.Context(), versionContextKey{}, version))
+
+			adapter := cfg.Adapters[version]
+			if adapter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(bw, r)
+			if bw.passthrough {
+				return
+			}
+
+			body := bw.body.Bytes()
+			if isJSON(w.Header()) {
+				var decoded interface{}
+				if err := json.Unmarshal(body, &decoded); err == nil {
+					if adapted, err := json.Marshal(adapter(bw.statusCode, decoded)); err == nil {
+						body = append(adapted, '\n')
+					}
+				}
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(bw.statusCode)
+			w.Write(body)
+		})
+	}
+}
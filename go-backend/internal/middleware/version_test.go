@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-backend/internal/middleware/middlewaretest"
+)
+
+func TestVersioning(t *testing.T) {
+	t.Parallel()
+
+	wrap := func(status int, body interface{}) interface{} {
+		return map[string]interface{}{"wrapped": body}
+	}
+
+	mw := Versioning(VersionConfig{
+		Default:  "1",
+		Adapters: map[string]VersionAdapter{"1": nil, "2": wrap},
+		Pins:     map[string]string{"pinned-key": "2", "stale-key": "9"},
+	})
+
+	expectVersion := func(want string) func(t *testing.T, r *http.Request) {
+		return func(t *testing.T, r *http.Request) {
+			if got := VersionFromContext(r.Context()); got != want {
+				t.Errorf("expected version %q in context, got %q", want, got)
+			}
+		}
+	}
+	expectWrapped := func(want bool) func(t *testing.T, rr *httptest.ResponseRecorder) {
+		return func(t *testing.T, rr *httptest.ResponseRecorder) {
+			var body map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if _, wrapped := body["wrapped"]; wrapped != want {
+				t.Errorf("expected wrapped=%v, got body %v", want, body)
+			}
+		}
+	}
+
+	middlewaretest.Run(t, mw, []middlewaretest.Case{
+		{
+			Name:           "default version",
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-API-Version": "1"},
+			CheckRequest:   expectVersion("1"),
+			CheckResponse:  expectWrapped(false),
+		},
+		{
+			Name:           "pinned key",
+			Headers:        map[string]string{"X-API-Key": "pinned-key"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-API-Version": "2"},
+			CheckRequest:   expectVersion("2"),
+			CheckResponse:  expectWrapped(true),
+		},
+		{
+			Name:           "pin to an unsupported version",
+			Headers:        map[string]string{"X-API-Key": "stale-key"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			WantHeaders:    map[string]string{"X-API-Version": "1"},
+			CheckRequest:   expectVersion("1"),
+		},
+		{
+			Name:           "explicit header",
+			Headers:        map[string]string{"X-API-Version": "2"},
+			WantStatus:     http.StatusOK,
+			WantNextCalled: true,
+			CheckResponse:  expectWrapped(true),
+		},
+		{
+			Name:           "unsupported version",
+			Headers:        map[string]string{"X-API-Version": "9"},
+			WantStatus:     http.StatusBadRequest,
+			WantNextCalled: false,
+			CheckResponse:  expectErrorCode("UNSUPPORTED_VERSION"),
+		},
+	})
+}
+
+func TestVersionConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	adapters := map[string]VersionAdapter{"1": nil, "2": nil}
+	if err := (VersionConfig{Adapters: adapters, Pins: map[string]string{"a-key": "2"}}).Validate(); err != nil {
+		t.Errorf("expected supported pins to be valid, got %v", err)
+	}
+	err := VersionConfig{Adapters: adapters, Pins: map[string]string{"a-key": "2", "secret-key": "3"}}.Validate()
+	if err == nil || !strings.Contains(err.Error(), `"3"`) || strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("expected the unsupported version named without its key, got %v", err)
+	}
+}
+
+func TestVersioning_StreamsNonJSON(t *testing.T) {
+	t.Parallel()
+
+	wrap := func(status int, body interface{}) interface{} {
+		return map[string]interface{}{"wrapped": body}
+	}
+	mw := Versioning(VersionConfig{Default: "2", Adapters: map[string]VersionAdapter{"2": wrap}})
+
+	flushed := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"n\":1}\n"))
+		flushed = http.NewResponseController(w).Flush() == nil
+		w.Write([]byte("{\"n\":2}\n"))
+	})
+
+	rr := httptest.NewRecorder()
+	mw(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !flushed {
+		t.Error("expected the stream to be flushable through the middleware")
+	}
+	if got, want := rr.Body.String(), "{\"n\":1}\n{\"n\":2}\n"; got != want {
+		t.Errorf("expected stream to pass through unchanged, got %q", got)
+	}
+}
@@ -1,20 +1,83 @@
 // Package model defines the domain models and API request/response types.
 package model
 
+import "time"
+
 // User represents a user in the system.
+// PasswordHash is never serialized in API responses. Timezone is the
+// IANA time zone the user's due dates are shown in, if they set one.
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	ID           int             `json:"id"`
+	Name         string          `json:"name"`
+	Email        string          `json:"email"`
+	Role         string          `json:"role"`
+	Timezone     string          `json:"timezone,omitempty"`
+	Preferences  UserPreferences `json:"preferences"`
+	PasswordHash string          `json:"-"`
+}
+
+// UserPreferences are settings users choose for themselves.
+type UserPreferences struct {
+	// DigestOptOut stops the weekly digest email.
+	DigestOptOut bool `json:"digestOptOut"`
 }
 
-// Task represents a task assigned to a user.
+// Task represents a task assigned to a user. Description is Markdown, and
+// Unfurls are previews of the links in it. Priority is one of low, medium,
+// high and urgent; Tags are normalized labels. BlockedBy lists the IDs of
+// the tasks that must be completed before this one can be. CreatedAt,
+// AssignedAt and CompletedAt are set by the store, in UTC: AssignedAt when
+// the task is given to its current user, CompletedAt when it was last
+// marked completed. DueAt is stored in UTC and rendered in the caller's
+// time zone.
 type Task struct {
-	ID     int    `json:"id"`
-	Title  string `json:"title"`
-	Status string `json:"status"`
-	UserID int    `json:"userId"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	Tags        []string   `json:"tags,omitempty"`
+	Unfurls     []Unfurl   `json:"unfurls,omitempty"`
+	BlockedBy   []int      `json:"blockedBy,omitempty"`
+	ProjectID   int        `json:"projectId,omitempty"`
+	UserID      int        `json:"userId"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	AssignedAt  time.Time  `json:"assignedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+}
+
+// Overdue reports whether the task is past its due date at now. Completed
+// tasks are never overdue. Due dates are instants, so this does not
+// depend on any time zone.
+func (t Task) Overdue(now time.Time) bool {
+	return t.DueAt != nil && t.Status != "completed" && !now.Before(*t.DueAt)
+}
+
+// TaskUpdate lists the changes to make to a task. Nil fields are left
+// unchanged; a zero DueAt clears the due date and a zero ProjectID removes
+// the task from its project.
+type TaskUpdate struct {
+	Title       *string
+	Description *string
+	Status      *string
+	Priority    *string
+	Tags        *[]string
+	Unfurls     *[]Unfurl
+	ProjectID   *int
+	UserID      *int
+	DueAt       *time.Time
+}
+
+// Unfurl is a preview of a link in a task description: the title and
+// favicon of the page it points to, as of FetchedAt. Error says why a page
+// could not be fetched.
+type Unfurl struct {
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	FaviconURL string    `json:"faviconUrl,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	FetchedAt  time.Time `json:"fetchedAt"`
 }
 
 // UsersResponse is the response format for listing users.
@@ -23,10 +86,231 @@ type UsersResponse struct {
 	Count int    `json:"count"`
 }
 
+// AddDependencyRequest is the request body for declaring that the task
+// BlockedBy blocks another.
+type AddDependencyRequest struct {
+	BlockedBy *int `json:"blockedBy" validate:"required"`
+}
+
+// DependenciesResponse lists the tasks blocking a task and the tasks it
+// blocks.
+type DependenciesResponse struct {
+	BlockedBy []Task `json:"blockedBy"`
+	Blocks    []Task `json:"blocks"`
+}
+
+// Share is a link giving anyone who has its token a read-only view of a
+// task until ExpiresAt. Only a hash of the token is kept: the token is
+// returned once, when the share is created.
+type Share struct {
+	ID        string    `json:"id"`
+	TaskID    int       `json:"taskId"`
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CreateShareRequest is the request body for sharing a task. Shares expire
+// after ExpiresInDays, 7 if unset.
+type CreateShareRequest struct {
+	ExpiresInDays *int `json:"expiresInDays,omitempty" validate:"min=1,max=90"`
+}
+
+// ShareResponse is a newly created share with its token and the path of
+// the shared view.
+type ShareResponse struct {
+	Share
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// SharesResponse lists the shares of a task.
+type SharesResponse struct {
+	Shares []Share `json:"shares"`
+	Count  int     `json:"count"`
+}
+
+// SharedTask is the read-only view of a task behind a share link. It
+// leaves out who the task is assigned to.
+type SharedTask struct {
+	Title           string     `json:"title"`
+	Description     string     `json:"description,omitempty"`
+	DescriptionHTML string     `json:"descriptionHtml"`
+	Status          string     `json:"status"`
+	Priority        string     `json:"priority"`
+	Tags            []string   `json:"tags,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	DueAt           *time.Time `json:"dueAt,omitempty"`
+	ExpiresAt       time.Time  `json:"expiresAt"`
+}
+
+// Announcement is an organization-wide notice, such as planned
+// maintenance, shown by clients while it is active: from StartsAt, or
+// creation if unset, until EndsAt, if set.
+type Announcement struct {
+	ID        int        `json:"id"`
+	Message   string     `json:"message"`
+	Level     string     `json:"level"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Active reports whether the announcement is shown at now.
+func (a Announcement) Active(now time.Time) bool {
+	return (a.StartsAt == nil || !now.Before(*a.StartsAt)) && (a.EndsAt == nil || now.Before(*a.EndsAt))
+}
+
+// AnnouncementUpdate holds the fields to change in an announcement; nil
+// fields are left as they are. A pointer to nil clears StartsAt or EndsAt.
+type AnnouncementUpdate struct {
+	Message  *string
+	Level    *string
+	StartsAt **time.Time
+	EndsAt   **time.Time
+}
+
+// CreateAnnouncementRequest is the request body for creating an
+// announcement. Level defaults to "info". StartsAt and EndsAt are RFC 3339
+// times.
+type CreateAnnouncementRequest struct {
+	Message  string `json:"message" validate:"required,max=500"`
+	Level    string `json:"level,omitempty" validate:"omitempty,level"`
+	StartsAt string `json:"startsAt,omitempty"`
+	EndsAt   string `json:"endsAt,omitempty"`
+}
+
+// UpdateAnnouncementRequest is the request body for updating an
+// announcement. Only provided fields are updated; an empty startsAt or
+// endsAt clears it.
+type UpdateAnnouncementRequest struct {
+	Message  *string `json:"message,omitempty" validate:"nonempty,max=500"`
+	Level    *string `json:"level,omitempty" validate:"level"`
+	StartsAt *string `json:"startsAt,omitempty"`
+	EndsAt   *string `json:"endsAt,omitempty"`
+}
+
+// AnnouncementsResponse lists announcements.
+type AnnouncementsResponse struct {
+	Announcements []Announcement `json:"announcements"`
+	Count         int            `json:"count"`
+}
+
+// MeResponse describes the caller of GET /api/me: the client it is
+// identified as, with its API key masked, whether it may call the admin
+// endpoints, and the announcements to show it.
+type MeResponse struct {
+	Client        string         `json:"client"`
+	Admin         bool           `json:"admin"`
+	Announcements []Announcement `json:"announcements"`
+}
+
+// TaskHTMLResponse is a task with its Markdown description rendered to
+// sanitized HTML.
+type TaskHTMLResponse struct {
+	Task
+	DescriptionHTML string `json:"descriptionHtml"`
+}
+
+// MarkdownRequest is Markdown to preview, such as a task description
+// being edited.
+type MarkdownRequest struct {
+	Markdown string `json:"markdown" validate:"max=10000"`
+}
+
+// MarkdownResponse is Markdown rendered to sanitized HTML.
+type MarkdownResponse struct {
+	HTML string `json:"html"`
+}
+
 // TasksResponse is the response format for listing tasks.
+// Revision is the store revision the list is as of, to pass as ?since=
+// for the changes made after it.
 type TasksResponse struct {
-	Tasks []Task `json:"tasks"`
-	Count int    `json:"count"`
+	Tasks    []Task `json:"tasks"`
+	Count    int    `json:"count"`
+	Revision int64  `json:"revision"`
+}
+
+// TaskChanges lists the IDs of the tasks created, updated and deleted
+// since a store revision, up to Revision.
+type TaskChanges struct {
+	Revision int64 `json:"revision"`
+	Created  []int `json:"created"`
+	Updated  []int `json:"updated"`
+	Deleted  []int `json:"deleted"`
+}
+
+// TaskSummary is the compact view of a Task, for clients such as the
+// mobile app that only show lists.
+type TaskSummary struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// Summary returns the compact view of the task.
+func (t Task) Summary() TaskSummary {
+	return TaskSummary{ID: t.ID, Title: t.Title, Status: t.Status}
+}
+
+// CompactTasksResponse lists tasks in their compact view.
+type CompactTasksResponse struct {
+	Tasks    []TaskSummary `json:"tasks"`
+	Count    int           `json:"count"`
+	Revision int64         `json:"revision"`
+}
+
+// UserTasksResponse lists the tasks assigned to a user that match a
+// filter. Summary counts all of the user's tasks by status, whatever the
+// filter.
+type UserTasksResponse struct {
+	UserID int `json:"userId"`
+	TasksResponse
+	Summary TaskCounts `json:"summary"`
+}
+
+// CompactUserTasksResponse is UserTasksResponse in the compact view.
+type CompactUserTasksResponse struct {
+	UserID int `json:"userId"`
+	CompactTasksResponse
+	Summary TaskCounts `json:"summary"`
+}
+
+// UserSummary is the compact view of a User.
+type UserSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Summary returns the compact view of the user.
+func (u User) Summary() UserSummary {
+	return UserSummary{ID: u.ID, Name: u.Name}
+}
+
+// CompactUsersResponse lists users in their compact view.
+type CompactUsersResponse struct {
+	Users []UserSummary `json:"users"`
+	Count int           `json:"count"`
+}
+
+// ProjectSummary is the compact view of a Project.
+type ProjectSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Summary returns the compact view of the project.
+func (p Project) Summary() ProjectSummary {
+	return ProjectSummary{ID: p.ID, Name: p.Name}
+}
+
+// CompactProjectsResponse lists projects in their compact view.
+type CompactProjectsResponse struct {
+	Projects []ProjectSummary `json:"projects"`
+	Count    int              `json:"count"`
 }
 
 // StatsResponse provides statistics about users and tasks.
@@ -34,12 +318,261 @@ type StatsResponse struct {
 	Users struct {
 		Total int `json:"total"`
 	} `json:"users"`
-	Tasks struct {
-		Total      int `json:"total"`
-		Pending    int `json:"pending"`
-		InProgress int `json:"inProgress"`
-		Completed  int `json:"completed"`
-	} `json:"tasks"`
+	Tasks      TaskCounts     `json:"tasks"`
+	Priorities PriorityCounts `json:"priorities"`
+}
+
+// TagsResponse lists the distinct task tags, most used first.
+type TagsResponse struct {
+	Tags  []TagCount `json:"tags"`
+	Count int        `json:"count"`
+}
+
+// TagCount is a tag and the number of tasks that have it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// PriorityCounts counts tasks by priority.
+type PriorityCounts struct {
+	Low    int `json:"low"`
+	Medium int `json:"medium"`
+	High   int `json:"high"`
+	Urgent int `json:"urgent"`
+}
+
+// TaskCounts counts tasks by status.
+type TaskCounts struct {
+	Total      int `json:"total"`
+	Pending    int `json:"pending"`
+	InProgress int `json:"inProgress"`
+	Completed  int `json:"completed"`
+}
+
+// Project groups the tasks of a team or piece of work.
+type Project struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ProjectUpdate lists the changes to make to a project. Nil fields are
+// left unchanged.
+type ProjectUpdate struct {
+	Name        *string
+	Description *string
+}
+
+// CreateProjectRequest is the request body for creating a project.
+type CreateProjectRequest struct {
+	Name        string `json:"name" validate:"required,max=120"`
+	Description string `json:"description,omitempty" validate:"max=1000"`
+}
+
+// UpdateProjectRequest is the request body for updating a project. Only
+// provided fields are updated.
+type UpdateProjectRequest struct {
+	Name        *string `json:"name,omitempty" validate:"nonempty,max=120"`
+	Description *string `json:"description,omitempty" validate:"max=1000"`
+}
+
+// ProjectsResponse lists projects.
+type ProjectsResponse struct {
+	Projects []Project `json:"projects"`
+	Count    int       `json:"count"`
+}
+
+// ProjectStats provides task statistics for a single project.
+type ProjectStats struct {
+	ProjectID  int            `json:"projectId"`
+	Name       string         `json:"name"`
+	Tasks      TaskCounts     `json:"tasks"`
+	Priorities PriorityCounts `json:"priorities"`
+	Overdue    int            `json:"overdue"`
+}
+
+// AuditEvent records a change made through the API: who made it, when,
+// and the old and new value of every field that changed. Actor identifies
+// the client as the route usage report does, by masked API key or IP.
+type AuditEvent struct {
+	ID       int           `json:"id"`
+	Actor    string        `json:"actor"`
+	Action   string        `json:"action"`
+	Entity   string        `json:"entity"`
+	EntityID string        `json:"entityId"`
+	Changes  []FieldChange `json:"changes"`
+	At       time.Time     `json:"at"`
+}
+
+// FieldChange is the value of a field before and after a change. Old is
+// null for created records and New for deleted ones.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// AuditFilter selects audit events. Empty fields match every event, and
+// a zero Limit returns all of them.
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// Matches reports whether the filter selects e, ignoring Limit.
+func (f AuditFilter) Matches(e AuditEvent) bool {
+	return (f.Entity == "" || e.Entity == f.Entity) &&
+		(f.EntityID == "" || e.EntityID == f.EntityID) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Since.IsZero() || !e.At.Before(f.Since)) &&
+		(f.Until.IsZero() || e.At.Before(f.Until))
+}
+
+// AuditResponse lists audit events, newest first.
+type AuditResponse struct {
+	Events []AuditEvent `json:"events"`
+	Count  int          `json:"count"`
+}
+
+// Webhook is a URL that is sent a POST for each of Events, signed with
+// Secret. The secret is only returned when the webhook is registered.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateWebhookRequest is the request body for registering a webhook. A
+// secret is generated if none is given.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,max=2000"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty" validate:"omitempty,min=16,max=256"`
+}
+
+// WebhookResponse is a newly registered webhook with its secret.
+type WebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhooksResponse lists the registered webhooks.
+type WebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+	Count    int       `json:"count"`
+}
+
+// WebhookPayload is the body POSTed to a webhook. Text summarizes the
+// event for chat tools such as Slack, which show it as the message.
+type WebhookPayload struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Text       string      `json:"text"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookDelivery records the delivery of one event to a webhook: its
+// Status is "pending" while attempts remain, then "delivered" or
+// "failed". ResponseStatus and Error describe the last attempt.
+type WebhookDelivery struct {
+	ID             int        `json:"id"`
+	WebhookID      int        `json:"webhookId"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+}
+
+// WebhookDeliveriesResponse lists the recent deliveries to a webhook,
+// newest first.
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Count      int               `json:"count"`
+}
+
+// TaskFilter selects the tasks of a /ws subscription, as the query
+// parameters of GET /api/tasks do. Empty fields match every task.
+type TaskFilter struct {
+	Status    string   `json:"status,omitempty"`
+	UserID    int      `json:"userId,omitempty"`
+	ProjectID int      `json:"projectId,omitempty"`
+	Priority  string   `json:"priority,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	TagMode   string   `json:"tagMode,omitempty"`
+}
+
+// SocketRequest is a message from a /ws client: "subscribe" to the tasks
+// matching Filter under the client's chosen ID, "unsubscribe" from ID, or
+// "ping".
+type SocketRequest struct {
+	Type   string      `json:"type"`
+	ID     string      `json:"id,omitempty"`
+	Filter *TaskFilter `json:"filter,omitempty"`
+}
+
+// SocketSnapshot is sent over /ws with every task matching a subscription,
+// when it is made and again whenever the client must reload it.
+type SocketSnapshot struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Tasks    []Task `json:"tasks"`
+	Count    int    `json:"count"`
+	Revision int64  `json:"revision"`
+}
+
+// SocketChange is sent over /ws when a task of a subscription changes:
+// "upsert" with the task when it is created, updated or starts matching,
+// and "remove" with only TaskID when it stops matching.
+type SocketChange struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	TaskID int    `json:"taskId"`
+	Task   *Task  `json:"task,omitempty"`
+}
+
+// SocketNotice is any other message sent over /ws: "unsubscribed",
+// "pong", or "error" with a code and message, and the ID of the
+// subscription it concerns if any.
+type SocketNotice struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// UserStats provides task statistics for a single user.
+type UserStats struct {
+	UserID int        `json:"userId"`
+	Name   string     `json:"name"`
+	Tasks  TaskCounts `json:"tasks"`
+}
+
+// DetailedStatsResponse adds per-user rows to StatsResponse.
+type DetailedStatsResponse struct {
+	StatsResponse
+	PerUser []UserStats `json:"perUser"`
+}
+
+// StatsStreamEvent is one line of a streamed detailed stats response:
+// a "user" row for each user, then a final "summary".
+type StatsStreamEvent struct {
+	Type    string         `json:"type"`
+	User    *UserStats     `json:"user,omitempty"`
+	Summary *StatsResponse `json:"summary,omitempty"`
 }
 
 // HealthResponse is a simple health check response.
@@ -49,40 +582,531 @@ type HealthResponse struct {
 }
 
 // DetailedHealthResponse provides detailed health status with checks.
+// LastPersistedAt is when the data file was last written, if it has been
+// since the server started.
 type DetailedHealthResponse struct {
-	Status    string            `json:"status"`
-	Message   string            `json:"message"`
-	Version   string            `json:"version"`
-	Uptime    string            `json:"uptime"`
-	Checks    map[string]string `json:"checks"`
-	Timestamp string            `json:"timestamp"`
+	Status          string            `json:"status"`
+	Message         string            `json:"message"`
+	Version         string            `json:"version"`
+	Uptime          string            `json:"uptime"`
+	Checks          map[string]string `json:"checks"`
+	LastPersistedAt string            `json:"lastPersistedAt,omitempty"`
+	Timestamp       string            `json:"timestamp"`
+}
+
+// ReadinessResponse is the response format for GET /health/ready. Checks
+// maps each dependency checked to "ok" or what is wrong with it.
+type ReadinessResponse struct {
+	Status  string            `json:"status"`
+	Message string            `json:"message"`
+	Checks  map[string]string `json:"checks"`
+}
+
+// RouteUsage reports how often a client called a route and when it was last seen.
+type RouteUsage struct {
+	Method   string `json:"method"`
+	Route    string `json:"route"`
+	Client   string `json:"client"`
+	Count    int64  `json:"count"`
+	LastSeen string `json:"lastSeen"`
+}
+
+// RouteUsageResponse is the response format for route usage statistics.
+type RouteUsageResponse struct {
+	Routes []RouteUsage `json:"routes"`
+	Count  int          `json:"count"`
+}
+
+// ReloadResponse is the response format for POST /api/admin/reload.
+// RestartNeeded names the changed settings that take a restart to apply.
+type ReloadResponse struct {
+	Success       bool     `json:"success"`
+	Message       string   `json:"message"`
+	RestartNeeded []string `json:"restartNeeded"`
+}
+
+// Digest summarizes a user's tasks for the weekly digest email: those
+// they completed and were newly assigned during the period, and those
+// overdue at its end.
+type Digest struct {
+	UserID    int    `json:"userId"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Completed []Task `json:"completed"`
+	Assigned  []Task `json:"assigned"`
+	Overdue   []Task `json:"overdue"`
+}
+
+// DigestRunResponse reports a run of the digest job. Users who opted out
+// or have nothing to report are skipped. Digests is only set for dry runs.
+type DigestRunResponse struct {
+	Since    string   `json:"since"`
+	Until    string   `json:"until"`
+	DryRun   bool     `json:"dryRun"`
+	Sent     int      `json:"sent"`
+	OptedOut int      `json:"optedOut"`
+	Empty    int      `json:"empty"`
+	Failed   int      `json:"failed"`
+	Digests  []Digest `json:"digests,omitempty"`
+}
+
+// CapacityReport estimates each user's workload from their open tasks,
+// most loaded first. OverAllocated counts users whose load exceeds the
+// capacity in Weights.
+type CapacityReport struct {
+	GeneratedAt   string          `json:"generatedAt"`
+	Weights       CapacityWeights `json:"weights"`
+	Users         []UserCapacity  `json:"users"`
+	OverAllocated int             `json:"overAllocated"`
+}
+
+// CapacityWeights are the weights a CapacityReport was computed with.
+type CapacityWeights struct {
+	Open          float64 `json:"open"`
+	InProgress    float64 `json:"inProgress"`
+	DueSoon       float64 `json:"dueSoon"`
+	Overdue       float64 `json:"overdue"`
+	DueSoonWindow string  `json:"dueSoonWindow"`
+	Capacity      float64 `json:"capacity"`
+}
+
+// UserCapacity is one user's row of a CapacityReport. Utilization is Load
+// as a fraction of capacity.
+type UserCapacity struct {
+	UserID        int     `json:"userId"`
+	Name          string  `json:"name"`
+	OpenTasks     int     `json:"openTasks"`
+	DueSoon       int     `json:"dueSoon"`
+	Overdue       int     `json:"overdue"`
+	Load          float64 `json:"load"`
+	Utilization   float64 `json:"utilization"`
+	OverAllocated bool    `json:"overAllocated"`
+}
+
+// WorkloadReport gives each user's tasks by status, how long their open
+// tasks have been open, and how many tasks they completed in the Window
+// up to GeneratedAt.
+type WorkloadReport struct {
+	GeneratedAt string         `json:"generatedAt"`
+	Window      string         `json:"window"`
+	Users       []UserWorkload `json:"users"`
+}
+
+// UserWorkload is one user's row of a WorkloadReport. CompletionRate is
+// Completed as a share of the tasks the user had in the window: those
+// completed in it and those still open, or 0 if there were none.
+type UserWorkload struct {
+	UserID              int        `json:"userId"`
+	Name                string     `json:"name"`
+	Tasks               TaskCounts `json:"tasks"`
+	AverageOpenAgeHours float64    `json:"averageOpenAgeHours"`
+	Completed           int        `json:"completed"`
+	CompletionRate      float64    `json:"completionRate"`
+}
+
+// StatsSnapshot is the stats as they were At, recorded periodically so
+// they can be charted over time.
+type StatsSnapshot struct {
+	At         time.Time      `json:"at"`
+	Users      int            `json:"users"`
+	Tasks      TaskCounts     `json:"tasks"`
+	Priorities PriorityCounts `json:"priorities"`
+}
+
+// StatsHistoryResponse is the stats recorded from From up to To, with the
+// latest snapshot in each Interval.
+type StatsHistoryResponse struct {
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Interval  string          `json:"interval"`
+	Snapshots []StatsSnapshot `json:"snapshots"`
+	Count     int             `json:"count"`
+}
+
+// StoreOperationStats counts the calls of one store operation since the
+// server started, with the records they returned or wrote and the time
+// they took. Kind is "read" or "write".
+type StoreOperationStats struct {
+	Op        string  `json:"op"`
+	Kind      string  `json:"kind"`
+	Count     int64   `json:"count"`
+	Records   int64   `json:"records"`
+	TotalMs   float64 `json:"totalMs"`
+	AverageMs float64 `json:"averageMs"`
+}
+
+// StoreStatsResponse lists the store operations made since the server
+// started, by name, with the totals of reads and writes.
+type StoreStatsResponse struct {
+	Operations []StoreOperationStats `json:"operations"`
+	Reads      int64                 `json:"reads"`
+	Writes     int64                 `json:"writes"`
+}
+
+// ReportResult is the output of a custom report: one row per group, with
+// the group's key columns followed by its metrics.
+type ReportResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	Count   int             `json:"count"`
+}
+
+// ReportCostResponse is the estimated cost of a custom report and the
+// limits it is checked against. Groups is an upper bound.
+type ReportCostResponse struct {
+	Rows           int  `json:"rows"`
+	Evaluations    int  `json:"evaluations"`
+	Groups         int  `json:"groups"`
+	MaxRows        int  `json:"maxRows"`
+	MaxEvaluations int  `json:"maxEvaluations"`
+	MaxGroups      int  `json:"maxGroups"`
+	WithinLimits   bool `json:"withinLimits"`
+}
+
+// MaterializedReport describes the stored result of a report served from
+// the materialized cache.
+type MaterializedReport struct {
+	Name        string `json:"name"`
+	GeneratedAt string `json:"generatedAt"`
+}
+
+// ReportRefreshResponse lists the reports recomputed by a refresh.
+type ReportRefreshResponse struct {
+	Reports []MaterializedReport `json:"reports"`
+}
+
+// ConsistencyReport is the result of the latest run of the consistency
+// checks, which compare caches, counters and saved data with the data
+// they are derived from. Status is "ok", "inconsistent" if a check found
+// discrepancies, "error" if one could not run, or "pending" before the
+// first run.
+type ConsistencyReport struct {
+	Status    string             `json:"status"`
+	CheckedAt string             `json:"checkedAt,omitempty"`
+	Checks    []ConsistencyCheck `json:"checks"`
+	// Runs counts the runs since the server started.
+	Runs int64 `json:"runs"`
+}
+
+// ConsistencyCheck is the result of one check. Discrepancies lists at most
+// the first 20 of the Count found; the totals cover every run since the
+// server started.
+type ConsistencyCheck struct {
+	Name          string   `json:"name"`
+	Status        string   `json:"status"`
+	Count         int      `json:"count"`
+	Discrepancies []string `json:"discrepancies"`
+	Error         string   `json:"error,omitempty"`
+	// TotalDiscrepancies sums Count over every run.
+	TotalDiscrepancies int64 `json:"totalDiscrepancies"`
+	// Failures counts the runs in which the check could not run.
+	Failures int64 `json:"failures"`
+}
+
+// Diagnosis is the result of the self-diagnostics: the checks run and
+// what they found, most severe first. Status is the severity of the worst
+// finding, or "ok" if nothing needs attention.
+type Diagnosis struct {
+	Status    string              `json:"status"`
+	CheckedAt string              `json:"checkedAt"`
+	Checks    []string            `json:"checks"`
+	Findings  []DiagnosticFinding `json:"findings"`
+	Count     int                 `json:"count"`
+}
+
+// DiagnosticFinding is something a self-check found. Severity is
+// "critical", "warning" or "info", and Action suggests what to do about
+// it.
+type DiagnosticFinding struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+	Action   string `json:"action,omitempty"`
 }
 
-// ErrorResponse is the standard error response format.
+// ReportFieldsResponse lists the fields custom reports can use, by
+// entity.
+type ReportFieldsResponse struct {
+	Entities map[string][]string `json:"entities"`
+}
+
+// Attachment scan statuses. Only clean and unscanned attachments can be
+// downloaded.
+const (
+	ScanPending   = "pending"
+	ScanClean     = "clean"
+	ScanInfected  = "infected"
+	ScanFailed    = "failed"
+	ScanUnscanned = "unscanned"
+)
+
+// Attachment describes a file attached to a task. ScanStatus records the
+// antivirus verdict; ScanSignature names what was found in an infected
+// file, which is quarantined. SHA256 is the hash of the content, which is
+// stored once for all attachments that share it. Thumbnails lists the
+// sizes generated so far for an image.
+type Attachment struct {
+	ID            int        `json:"id"`
+	TaskID        int        `json:"taskId"`
+	Filename      string     `json:"filename"`
+	ContentType   string     `json:"contentType"`
+	Size          int64      `json:"size"`
+	SHA256        string     `json:"sha256"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ScanStatus    string     `json:"scanStatus"`
+	ScanSignature string     `json:"scanSignature,omitempty"`
+	ScannedAt     *time.Time `json:"scannedAt,omitempty"`
+	Thumbnails    []string   `json:"thumbnails,omitempty"`
+}
+
+// AttachFromHashRequest attaches content the server already has,
+// identified by its SHA-256 hash, instead of uploading it again.
+// ContentType defaults to that of the earlier upload.
+type AttachFromHashRequest struct {
+	Filename    string `json:"filename" validate:"required"`
+	ContentType string `json:"contentType"`
+	SHA256      string `json:"sha256" validate:"required"`
+}
+
+// AttachmentsResponse is the response for listing a task's attachments.
+type AttachmentsResponse struct {
+	Attachments []Attachment `json:"attachments"`
+	Count       int          `json:"count"`
+}
+
+// SignedURLResponse is a download URL for an attachment that needs no
+// credentials until it expires.
+type SignedURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Operation statuses.
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+)
+
+// Operation reports the state of an asynchronous operation.
+// Error is set once it has failed or been cancelled. Result is set once it
+// has finished, and may describe partial work for failed or cancelled ones.
+type Operation struct {
+	ID          string             `json:"id"`
+	Kind        string             `json:"kind"`
+	Status      string             `json:"status"`
+	CreatedAt   string             `json:"createdAt"`
+	CompletedAt string             `json:"completedAt,omitempty"`
+	Progress    *OperationProgress `json:"progress,omitempty"`
+	Result      interface{}        `json:"result,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// OperationProgress reports how far an operation has got.
+// ETA is an estimated completion time, set once progress has been made.
+type OperationProgress struct {
+	Processed int    `json:"processed"`
+	Total     int    `json:"total"`
+	Errors    int    `json:"errors"`
+	ETA       string `json:"eta,omitempty"`
+}
+
+// OperationsResponse is the response format for listing operations.
+type OperationsResponse struct {
+	Operations []Operation `json:"operations"`
+	Count      int         `json:"count"`
+}
+
+// ImportRowError describes a rejected row of a CSV import.
+// Row is the line in the file, counting the header as line 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImportReport summarizes a CSV import or dry run.
+type ImportReport struct {
+	Success  bool             `json:"success"`
+	DryRun   bool             `json:"dryRun"`
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// FieldError describes why a field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ItemValidation is the validation outcome of one item of a batch payload.
+// Index is the item's position in the payload, starting at 0.
+type ItemValidation struct {
+	Index  int          `json:"index"`
+	Valid  bool         `json:"valid"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// ValidationReport summarizes the validation of a batch payload.
+type ValidationReport struct {
+	Valid   bool             `json:"valid"`
+	Total   int              `json:"total"`
+	Invalid int              `json:"invalid"`
+	Items   []ItemValidation `json:"items"`
+}
+
+// StatusesResponse lists the valid task statuses in workflow order, with
+// their display names in the negotiated locale.
+type StatusesResponse struct {
+	Statuses []string          `json:"statuses"`
+	Count    int               `json:"count"`
+	Locale   string            `json:"locale"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// LocaleResponse describes the locale negotiated from Accept-Language.
+// DateFormat and TimeFormat are Unicode (CLDR) patterns. ListSeparator
+// and DecimalSeparator are those of CSV exports in the locale.
+type LocaleResponse struct {
+	Locale           string            `json:"locale"`
+	Supported        []string          `json:"supported"`
+	DateFormat       string            `json:"dateFormat"`
+	TimeFormat       string            `json:"timeFormat"`
+	ListSeparator    string            `json:"listSeparator"`
+	DecimalSeparator string            `json:"decimalSeparator"`
+	StatusLabels     map[string]string `json:"statusLabels"`
+}
+
+// RolesResponse lists the roles clients can offer. When AllowCustom is
+// false, only the listed roles are accepted.
+type RolesResponse struct {
+	Roles       []string `json:"roles"`
+	Count       int      `json:"count"`
+	AllowCustom bool     `json:"allowCustom"`
+}
+
+// ChangelogResponse lists the changes to API behavior in each release,
+// newest first, up to Current, the version the server runs.
+type ChangelogResponse struct {
+	Current  string             `json:"current"`
+	Releases []ChangelogRelease `json:"releases"`
+}
+
+// ChangelogRelease lists the changes to API behavior in one release.
+type ChangelogRelease struct {
+	Version string      `json:"version"`
+	Changes []APIChange `json:"changes"`
+}
+
+// APIChange is one change to API behavior. Type is "added", "changed",
+// "deprecated" or "removed", and Subject is the route, such as
+// "GET /api/tasks", or the field, such as "task.priority", it applies to.
+type APIChange struct {
+	Type        string `json:"type"`
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+}
+
+// SchemaResponse describes the API's models for clients that build forms
+// dynamically.
+type SchemaResponse struct {
+	Models map[string]ModelSchema `json:"models"`
+}
+
+// ModelSchema describes the fields of one model.
+type ModelSchema struct {
+	Fields []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes one field of a model and its constraints.
+// ReadOnly fields are assigned by the server; WriteOnly fields are
+// accepted but never returned. References names the model an ID refers to.
+// For arrays, Items names the type of the elements, and string constraints
+// apply to each element.
+type FieldSchema struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Required   bool     `json:"required"`
+	ReadOnly   bool     `json:"readOnly,omitempty"`
+	WriteOnly  bool     `json:"writeOnly,omitempty"`
+	Unique     bool     `json:"unique,omitempty"`
+	Enum       []string `json:"enum,omitempty"`
+	Default    string   `json:"default,omitempty"`
+	Format     string   `json:"format,omitempty"`
+	Pattern    string   `json:"pattern,omitempty"`
+	MinLength  int      `json:"minLength,omitempty"`
+	MaxLength  int      `json:"maxLength,omitempty"`
+	Items      string   `json:"items,omitempty"`
+	MaxItems   int      `json:"maxItems,omitempty"`
+	References string   `json:"references,omitempty"`
+}
+
+// ErrorResponse is the standard error response format. Validation failures
+// list every rejected field in Errors; Error and Code repeat the first.
 type ErrorResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
+	Success bool         `json:"success"`
+	Error   string       `json:"error"`
+	Code    string       `json:"code,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
 }
 
 // CreateUserRequest is the request body for creating a user.
+// Password is optional; when set it is hashed before storage.
 type CreateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Name     string `json:"name" validate:"required,max=120"`
+	Email    string `json:"email" validate:"required,email"`
+	Role     string `json:"role" validate:"required,role"`
+	Password string `json:"password,omitempty" validate:"omitempty,password"`
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+}
+
+// ChangePasswordRequest is the request body for changing a user's password.
+// CurrentPassword is required when the user already has a password set.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// UpdatePreferencesRequest is the request body for changing a user's
+// preferences. Nil fields are left unchanged.
+type UpdatePreferencesRequest struct {
+	DigestOptOut *bool `json:"digestOptOut"`
+}
+
+// SuccessResponse is a generic response for operations without a payload.
+type SuccessResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
 }
 
 // CreateTaskRequest is the request body for creating a task.
+// DueAt is a date or a timestamp, read in the caller's time zone.
 type CreateTaskRequest struct {
-	Title  string `json:"title"`
-	Status string `json:"status"`
-	UserID int    `json:"userId"`
+	Title       string   `json:"title" validate:"required,max=200"`
+	Description string   `json:"description,omitempty" validate:"max=10000"`
+	Status      string   `json:"status" validate:"status"`
+	Priority    string   `json:"priority,omitempty" validate:"omitempty,priority"`
+	Tags        []string `json:"tags,omitempty" validate:"omitempty,tags"`
+	ProjectID   int      `json:"projectId,omitempty"`
+	UserID      int      `json:"userId"`
+	DueAt       string   `json:"dueAt,omitempty"`
 }
 
 // UpdateTaskRequest is the request body for updating a task.
 // Pointer types allow distinguishing between "not set" and "set to zero value".
+// An empty DueAt clears the due date, empty Tags remove all tags, and a
+// zero ProjectID removes the task from its project.
 type UpdateTaskRequest struct {
-	Title  *string `json:"title,omitempty"`
-	Status *string `json:"status,omitempty"`
-	UserID *int    `json:"userId,omitempty"`
+	Title       *string   `json:"title,omitempty" validate:"nonempty,max=200"`
+	Description *string   `json:"description,omitempty" validate:"max=10000"`
+	Status      *string   `json:"status,omitempty" validate:"status"`
+	Priority    *string   `json:"priority,omitempty" validate:"priority"`
+	Tags        *[]string `json:"tags,omitempty" validate:"tags"`
+	ProjectID   *int      `json:"projectId,omitempty"`
+	UserID      *int      `json:"userId,omitempty"`
+	DueAt       *string   `json:"dueAt,omitempty"`
 }
@@ -0,0 +1,281 @@
+// Package operation runs expensive requests asynchronously and keeps their
+// outcome for polling.
+package operation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Defaults of a Manager's retention and Limits.
+const (
+	// DefaultRetention is how long finished operations are kept by default.
+	DefaultRetention = 1 * time.Hour
+	// DefaultMaxRunning is how many operations may run at once by default.
+	DefaultMaxRunning = 8
+	// DefaultMaxRetained is how many operations are kept by default.
+	DefaultMaxRetained = 100
+	// DefaultTimeout is how long an operation may run by default.
+	DefaultTimeout = 10 * time.Minute
+)
+
+// ErrTooManyRunning is returned by Start when as many operations as the
+// Manager may run are already running.
+var ErrTooManyRunning = errors.New("too many operations running")
+
+// Limits bound the work a Manager takes on. Zero fields use the defaults.
+type Limits struct {
+	// MaxRunning is how many operations may run at once; Start refuses
+	// more with ErrTooManyRunning.
+	MaxRunning int
+	// MaxRetained is how many operations, running or finished, are kept,
+	// at least MaxRunning. Starting another drops the oldest finished one,
+	// with its result, before its retention is up.
+	MaxRetained int
+	// Timeout bounds each operation: one still running after it is
+	// cancelled and fails.
+	Timeout time.Duration
+}
+
+// withDefaults returns l with zero fields set to the defaults.
+func (l Limits) withDefaults() Limits {
+	if l.MaxRunning <= 0 {
+		l.MaxRunning = DefaultMaxRunning
+	}
+	if l.MaxRetained <= 0 {
+		l.MaxRetained = DefaultMaxRetained
+	}
+	if l.MaxRetained < l.MaxRunning {
+		l.MaxRetained = l.MaxRunning
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	return l
+}
+
+// Func performs the work of an operation and returns its result.
+// It should report progress through p and stop early once ctx is
+// cancelled, returning ctx.Err() along with any partial result.
+type Func func(ctx context.Context, p *Progress) (interface{}, error)
+
+// operation is the mutable state of one operation.
+type operation struct {
+	id          string
+	kind        string
+	status      string
+	createdAt   time.Time
+	completedAt time.Time
+	progress    *Progress
+	cancel      context.CancelFunc
+	result      interface{}
+	err         error
+}
+
+// Manager runs operations in the background, within its Limits, and
+// retains finished ones for a configurable period. Expired operations are
+// dropped lazily, so a Manager needs no background goroutine of its own.
+type Manager struct {
+	mu        sync.Mutex
+	ops       map[string]*operation
+	running   int
+	retention time.Duration
+	limits    Limits
+}
+
+// NewManager creates a Manager that keeps finished operations for retention,
+// within the default Limits. A retention of zero or less uses
+// DefaultRetention.
+func NewManager(retention time.Duration) *Manager {
+	return NewManagerWithLimits(retention, Limits{})
+}
+
+// NewManagerWithLimits creates a Manager that keeps finished operations for
+// retention, within limits. A retention of zero or less uses
+// DefaultRetention.
+func NewManagerWithLimits(retention time.Duration, limits Limits) *Manager {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Manager{
+		ops:       make(map[string]*operation),
+		retention: retention,
+		limits:    limits.withDefaults(),
+	}
+}
+
+// Start runs fn in a new goroutine and returns the operation's initial state.
+// kind names the type of work, e.g. "restore". Returns ErrTooManyRunning,
+// running nothing, if Limits.MaxRunning operations are running.
+func (m *Manager) Start(kind string, fn Func) (model.Operation, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	m.expire(now)
+	if m.running >= m.limits.MaxRunning {
+		m.mu.Unlock()
+		return model.Operation{}, ErrTooManyRunning
+	}
+	for len(m.ops) >= m.limits.MaxRetained {
+		m.dropOldestFinished()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.limits.Timeout)
+	op := &operation{
+		id:        newID(),
+		kind:      kind,
+		status:    model.OperationRunning,
+		createdAt: now,
+		progress:  newProgress(now),
+		cancel:    cancel,
+	}
+	m.ops[op.id] = op
+	m.running++
+	snapshot := op.snapshot()
+	m.mu.Unlock()
+
+	go m.run(ctx, op, fn)
+
+	return snapshot, nil
+}
+
+// Get returns the current state of the operation with the given ID.
+// Returns false if it does not exist or has expired.
+func (m *Manager) Get(id string) (model.Operation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire(time.Now())
+
+	op, exists := m.ops[id]
+	if !exists {
+		return model.Operation{}, false
+	}
+	return op.snapshot(), true
+}
+
+// Cancel asks a running operation to stop and returns its current state.
+// The operation becomes cancelled once its work notices; how much of it
+// is kept depends on the kind of operation. Returns false if it does not
+// exist or has expired; a finished operation is returned unchanged.
+func (m *Manager) Cancel(id string) (model.Operation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire(time.Now())
+
+	op, exists := m.ops[id]
+	if !exists {
+		return model.Operation{}, false
+	}
+	if op.status == model.OperationRunning {
+		op.cancel()
+	}
+	return op.snapshot(), true
+}
+
+// List returns all retained operations, newest first.
+func (m *Manager) List() []model.Operation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire(time.Now())
+
+	ops := make([]*operation, 0, len(m.ops))
+	for _, op := range m.ops {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].createdAt.After(ops[j].createdAt)
+	})
+
+	result := make([]model.Operation, len(ops))
+	for i, op := range ops {
+		result[i] = op.snapshot()
+	}
+	return result
+}
+
+// run executes fn and records its outcome.
+func (m *Manager) run(ctx context.Context, op *operation, fn Func) {
+	result, err := fn(ctx, op.progress)
+	op.cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.running--
+	op.completedAt = time.Now()
+	op.result = result
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		op.status = model.OperationFailed
+		op.err = fmt.Errorf("timed out after %v", m.limits.Timeout)
+	case errors.Is(err, context.Canceled):
+		op.status = model.OperationCancelled
+		op.err = err
+	case err != nil:
+		op.status = model.OperationFailed
+		op.err = err
+	default:
+		op.status = model.OperationSucceeded
+	}
+}
+
+// expire drops finished operations older than the retention period.
+// Must be called with mu held.
+func (m *Manager) expire(now time.Time) {
+	for id, op := range m.ops {
+		if op.status != model.OperationRunning && now.Sub(op.completedAt) > m.retention {
+			delete(m.ops, id)
+		}
+	}
+}
+
+// dropOldestFinished drops the finished operation that was started first.
+// There is one whenever MaxRetained operations are kept, as at most
+// MaxRunning of them are running. Must be called with mu held.
+func (m *Manager) dropOldestFinished() {
+	var oldest *operation
+	for _, op := range m.ops {
+		if op.status != model.OperationRunning && (oldest == nil || op.createdAt.Before(oldest.createdAt)) {
+			oldest = op
+		}
+	}
+	delete(m.ops, oldest.id)
+}
+
+// snapshot returns the operation's state for clients. Must be called with
+// the Manager's mu held.
+func (op *operation) snapshot() model.Operation {
+	result := model.Operation{
+		ID:        op.id,
+		Kind:      op.kind,
+		Status:    op.status,
+		CreatedAt: op.createdAt.UTC().Format(time.RFC3339),
+		Progress:  op.progress.snapshot(time.Now()),
+		Result:    op.result,
+	}
+	if !op.completedAt.IsZero() {
+		result.CompletedAt = op.completedAt.UTC().Format(time.RFC3339)
+	}
+	if op.err != nil {
+		result.Error = op.err.Error()
+	}
+	return result
+}
+
+// newID returns a random operation ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,203 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// waitFor polls the operation until it is no longer running.
+func waitFor(t *testing.T, m *Manager, id string) model.Operation {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		op, found := m.Get(id)
+		if !found {
+			t.Fatalf("operation %s not found", id)
+		}
+		if op.Status != model.OperationRunning {
+			return op
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("operation %s did not finish", id)
+	return model.Operation{}
+}
+
+func TestManager_Succeeded(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(time.Minute)
+	release := make(chan struct{})
+
+	op, _ := m.Start("test", func(context.Context, *Progress) (interface{}, error) {
+		<-release
+		return "done", nil
+	})
+
+	if op.Status != model.OperationRunning || op.Kind != "test" {
+		t.Errorf("expected running test operation, got %+v", op)
+	}
+
+	close(release)
+	op = waitFor(t, m, op.ID)
+
+	if op.Status != model.OperationSucceeded || op.Result != "done" || op.CompletedAt == "" {
+		t.Errorf("expected succeeded operation with result, got %+v", op)
+	}
+}
+
+func TestManager_Failed(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(time.Minute)
+	op, _ := m.Start("test", func(context.Context, *Progress) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+
+	op = waitFor(t, m, op.ID)
+
+	if op.Status != model.OperationFailed || op.Error != "boom" {
+		t.Errorf("expected failed operation with error, got %+v", op)
+	}
+}
+
+func TestManager_Retention(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(10 * time.Millisecond)
+	op, _ := m.Start("test", func(context.Context, *Progress) (interface{}, error) { return nil, nil })
+	waitFor(t, m, op.ID)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, found := m.Get(op.ID); found {
+		t.Error("expected finished operation to expire after the retention period")
+	}
+}
+
+func TestManager_List(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(time.Minute)
+	first, _ := m.Start("first", func(context.Context, *Progress) (interface{}, error) { return nil, nil })
+	time.Sleep(time.Millisecond)
+	second, _ := m.Start("second", func(context.Context, *Progress) (interface{}, error) { return nil, nil })
+
+	ops := m.List()
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %d", len(ops))
+	}
+	if ops[0].ID != second.ID || ops[1].ID != first.ID {
+		t.Errorf("expected newest operation first, got %s then %s", ops[0].Kind, ops[1].Kind)
+	}
+}
+
+func TestManager_Progress(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(time.Minute)
+	reported := make(chan struct{})
+	release := make(chan struct{})
+
+	op, _ := m.Start("test", func(_ context.Context, p *Progress) (interface{}, error) {
+		p.SetTotal(4)
+		p.Add(1)
+		p.AddErrors(1)
+		close(reported)
+		<-release
+		p.Add(3)
+		return nil, nil
+	})
+
+	<-reported
+	op, _ = m.Get(op.ID)
+	if op.Progress == nil || op.Progress.Processed != 1 || op.Progress.Total != 4 || op.Progress.Errors != 1 {
+		t.Fatalf("expected 1 of 4 processed with 1 error, got %+v", op.Progress)
+	}
+	if op.Progress.ETA == "" {
+		t.Error("expected an ETA while work remains")
+	}
+
+	close(release)
+	op = waitFor(t, m, op.ID)
+
+	if op.Progress.Processed != 4 || op.Progress.ETA != "" {
+		t.Errorf("expected 4 processed and no ETA once finished, got %+v", op.Progress)
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(time.Minute)
+	op, _ := m.Start("test", func(ctx context.Context, _ *Progress) (interface{}, error) {
+		<-ctx.Done()
+		return "partial", ctx.Err()
+	})
+
+	if _, found := m.Cancel(op.ID); !found {
+		t.Fatal("expected running operation to be found")
+	}
+	op = waitFor(t, m, op.ID)
+
+	if op.Status != model.OperationCancelled || op.Result != "partial" || op.Error == "" {
+		t.Errorf("expected cancelled operation with partial result, got %+v", op)
+	}
+
+	if _, found := m.Cancel("missing"); found {
+		t.Error("expected unknown operation not to be found")
+	}
+}
+
+func TestManager_Limits(t *testing.T) {
+	t.Parallel()
+
+	m := NewManagerWithLimits(time.Minute, Limits{MaxRunning: 1, MaxRetained: 2})
+	release := make(chan struct{})
+	first, _ := m.Start("first", func(context.Context, *Progress) (interface{}, error) { return nil, nil })
+	waitFor(t, m, first.ID)
+	running, err := m.Start("running", func(context.Context, *Progress) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("expected the operation to start, got %v", err)
+	}
+
+	if _, err := m.Start("refused", func(context.Context, *Progress) (interface{}, error) { return nil, nil }); !errors.Is(err, ErrTooManyRunning) {
+		t.Errorf("expected ErrTooManyRunning past MaxRunning, got %v", err)
+	}
+	close(release)
+	waitFor(t, m, running.ID)
+
+	// Starting a third drops the oldest finished operation
+	third, err := m.Start("third", func(context.Context, *Progress) (interface{}, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("expected an operation to start once one finished, got %v", err)
+	}
+	waitFor(t, m, third.ID)
+	if ops := m.List(); len(ops) != 2 || ops[0].ID != third.ID || ops[1].ID != running.ID {
+		t.Errorf("expected the 2 newest operations kept, got %+v", ops)
+	}
+}
+
+func TestManager_Timeout(t *testing.T) {
+	t.Parallel()
+
+	m := NewManagerWithLimits(time.Minute, Limits{Timeout: 10 * time.Millisecond})
+	op, _ := m.Start("test", func(ctx context.Context, _ *Progress) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	op = waitFor(t, m, op.ID)
+
+	if op.Status != model.OperationFailed || op.Error != "timed out after 10ms" {
+		t.Errorf("expected the operation to fail once timed out, got %+v", op)
+	}
+}
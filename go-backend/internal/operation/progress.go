@@ -0,0 +1,77 @@
+package operation
+
+import (
+	"sync"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Progress records how far an operation has got. Operations report through
+// it as they work; clients see it when polling. A nil *Progress discards
+// reports, so work can be shared between synchronous and asynchronous paths.
+type Progress struct {
+	mu        sync.Mutex
+	started   time.Time
+	total     int
+	processed int
+	errors    int
+}
+
+// newProgress creates a Progress for an operation started at started.
+func newProgress(started time.Time) *Progress {
+	return &Progress{started: started}
+}
+
+// SetTotal sets the number of records the operation will process.
+func (p *Progress) SetTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// Add records that n more records have been processed.
+func (p *Progress) Add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed += n
+}
+
+// AddErrors records that n more records have failed.
+func (p *Progress) AddErrors(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors += n
+}
+
+// snapshot returns the progress for clients, or nil if none was reported.
+// The ETA extrapolates the rate so far and is only set while work remains.
+func (p *Progress) snapshot(now time.Time) *model.OperationProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.total == 0 && p.processed == 0 && p.errors == 0 {
+		return nil
+	}
+
+	result := &model.OperationProgress{
+		Processed: p.processed,
+		Total:     p.total,
+		Errors:    p.errors,
+	}
+	if p.processed > 0 && p.processed < p.total {
+		elapsed := now.Sub(p.started)
+		remaining := time.Duration(float64(elapsed) / float64(p.processed) * float64(p.total-p.processed))
+		result.ETA = now.Add(remaining).UTC().Format(time.RFC3339)
+	}
+	return result
+}
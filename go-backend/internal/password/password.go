@@ -0,0 +1,47 @@
+// Package password provides password hashing and verification.
+package password
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinLength is the minimum accepted password length.
+const MinLength = 8
+
+// MaxLength is the bcrypt input limit; longer passwords are rejected
+// rather than silently truncated.
+const MaxLength = 72
+
+// ErrInvalidLength is returned when a password is too short or too long.
+var ErrInvalidLength = fmt.Errorf("password must be between %d and %d characters", MinLength, MaxLength)
+
+// Hash returns a bcrypt hash of the given password.
+func Hash(plain string) (string, error) {
+	if !Valid(plain) {
+		return "", ErrInvalidLength
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// Verify reports whether plain matches the given hash.
+// An empty hash never matches.
+func Verify(hash, plain string) bool {
+	if hash == "" {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
+}
+
+// Valid checks if the password satisfies the length requirements.
+func Valid(plain string) bool {
+	return len(plain) >= MinLength && len(plain) <= MaxLength
+}
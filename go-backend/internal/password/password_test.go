@@ -0,0 +1,32 @@
+package password
+
+import "testing"
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	if hash == "correct horse" {
+		t.Error("expected hash to differ from plaintext")
+	}
+	if !Verify(hash, "correct horse") {
+		t.Error("expected matching password to verify")
+	}
+	if Verify(hash, "wrong horse") {
+		t.Error("expected mismatched password to fail verification")
+	}
+}
+
+func TestHash_InvalidLength(t *testing.T) {
+	if _, err := Hash("short"); err != ErrInvalidLength {
+		t.Errorf("expected ErrInvalidLength, got %v", err)
+	}
+}
+
+func TestVerify_EmptyHash(t *testing.T) {
+	if Verify("", "anything") {
+		t.Error("expected empty hash to never verify")
+	}
+}
@@ -0,0 +1,606 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Formats a Spec can ask for.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Spec describes a custom report: which records to read, which to keep,
+// how to group them and what to compute for each group.
+type Spec struct {
+	// Entities is the record set to report on: "tasks" or "users".
+	Entities string `json:"entities"`
+	// Filters keep records matching all of them.
+	Filters []Filter `json:"filters"`
+	// GroupBy lists the fields to group by. Time fields can be truncated
+	// with a ":day", ":week", ":month" or ":year" suffix. Without any,
+	// the report has a single row.
+	GroupBy []string `json:"groupBy"`
+	// Metrics are computed for each group; a count if empty.
+	Metrics []Metric `json:"metrics"`
+	// Format is "json" (the default) or "csv".
+	Format string `json:"format"`
+}
+
+// Filter compares a field with a value. Op is one of eq, ne, lt, lte,
+// gt, gte, in (Value is a list) or contains (case-insensitive, strings
+// only). Times are compared with RFC 3339 timestamps or dates.
+type Filter struct {
+	Field string          `json:"field"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Metric aggregates a field over a group. Op is count, sum, avg, min or
+// max; count needs no field, and counts records where the field is set
+// if one is given. As names the column, defaulting to "op" or
+// "op(field)".
+type Metric struct {
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+	As    string `json:"as,omitempty"`
+}
+
+// SpecError reports an invalid Spec.
+type SpecError struct {
+	Field   string
+	Message string
+}
+
+func (e *SpecError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func specError(field, format string, args ...interface{}) *SpecError {
+	return &SpecError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// kind is the type of a field's values.
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindBool
+	kindTime
+)
+
+// record is one user or task, with the task's user when reporting on
+// tasks.
+type record struct {
+	user *model.User
+	task *model.Task
+	now  time.Time
+}
+
+// field reads a value from a record: a string, float64, bool or
+// time.Time, or nil if it is not set.
+type field struct {
+	kind kind
+	get  func(rec record) interface{}
+}
+
+// timeValue returns *t, or nil if t is nil.
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// userFields returns the fields of a record's user, which are unset for
+// tasks whose user does not exist.
+func userFields() map[string]field {
+	str := func(get func(u *model.User) string) field {
+		return field{kindString, func(rec record) interface{} {
+			if u := rec.user; u != nil {
+				return get(u)
+			}
+			return nil
+		}}
+	}
+	return map[string]field{
+		"name":     str(func(u *model.User) string { return u.Name }),
+		"email":    str(func(u *model.User) string { return u.Email }),
+		"role":     str(func(u *model.User) string { return u.Role }),
+		"timezone": str(func(u *model.User) string { return u.Timezone }),
+		"digestOptOut": {kindBool, func(rec record) interface{} {
+			if u := rec.user; u != nil {
+				return u.Preferences.DigestOptOut
+			}
+			return nil
+		}},
+	}
+}
+
+var (
+	userReportFields = func() map[string]field {
+		fields := userFields()
+		fields["id"] = field{kindNumber, func(rec record) interface{} { return float64(rec.user.ID) }}
+		return fields
+	}()
+
+	taskReportFields = func() map[string]field {
+		fields := map[string]field{
+			"id":          {kindNumber, func(rec record) interface{} { return float64(rec.task.ID) }},
+			"title":       {kindString, func(rec record) interface{} { return rec.task.Title }},
+			"status":      {kindString, func(rec record) interface{} { return rec.task.Status }},
+			"priority":    {kindString, func(rec record) interface{} { return rec.task.Priority }},
+			"userId":      {kindNumber, func(rec record) interface{} { return float64(rec.task.UserID) }},
+			"createdAt":   {kindTime, func(rec record) interface{} { return rec.task.CreatedAt }},
+			"assignedAt":  {kindTime, func(rec record) interface{} { return rec.task.AssignedAt }},
+			"completedAt": {kindTime, func(rec record) interface{} { return timeValue(rec.task.CompletedAt) }},
+			"dueAt":       {kindTime, func(rec record) interface{} { return timeValue(rec.task.DueAt) }},
+			"overdue":     {kindBool, func(rec record) interface{} { return rec.task.Overdue(rec.now) }},
+			// ageDays is how long ago the task was created; cycleDays how
+			// long it took to complete
+			"ageDays": {kindNumber, func(rec record) interface{} {
+				return rec.now.Sub(rec.task.CreatedAt).Hours() / 24
+			}},
+			"cycleDays": {kindNumber, func(rec record) interface{} {
+				if rec.task.CompletedAt == nil {
+					return nil
+				}
+				return rec.task.CompletedAt.Sub(rec.task.CreatedAt).Hours() / 24
+			}},
+		}
+		for name, f := range userFields() {
+			fields["user."+name] = f
+		}
+		return fields
+	}()
+)
+
+// Fields returns the names of the fields a report on entities can use,
+// sorted, or nil if entities is unknown.
+func Fields(entities string) []string {
+	fields := fieldsOf(entities)
+	if fields == nil {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fieldsOf(entities string) map[string]field {
+	switch entities {
+	case "tasks":
+		return taskReportFields
+	case "users":
+		return userReportFields
+	}
+	return nil
+}
+
+// Build runs spec over users and tasks at now, with groups ordered by
+// their keys. An invalid spec returns a *SpecError.
+func Build(spec Spec, users []model.User, tasks []model.Task, now time.Time) (model.ReportResult, error) {
+	q, err := compile(spec)
+	if err != nil {
+		return model.ReportResult{}, err
+	}
+	return q.run(records(spec.Entities, users, tasks, now)), nil
+}
+
+// records returns a record for each of the entities.
+func records(entities string, users []model.User, tasks []model.Task, now time.Time) []record {
+	if entities == "users" {
+		recs := make([]record, len(users))
+		for i := range users {
+			recs[i] = record{user: &users[i], now: now}
+		}
+		return recs
+	}
+
+	byID := make(map[int]*model.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	recs := make([]record, len(tasks))
+	for i := range tasks {
+		recs[i] = record{task: &tasks[i], user: byID[tasks[i].UserID], now: now}
+	}
+	return recs
+}
+
+// query is a validated Spec.
+type query struct {
+	filters []func(rec record) bool
+	// filterCost is how many values the filters compare per record
+	filterCost int
+	groups     []groupKey
+	metrics    []metric
+}
+
+type groupKey struct {
+	name  string
+	field field
+	trunc string
+}
+
+type metric struct {
+	name  string
+	op    string
+	field *field
+}
+
+// compile validates spec against the fields of its entities.
+func compile(spec Spec) (*query, error) {
+	fields := fieldsOf(spec.Entities)
+	if fields == nil {
+		return nil, specError("entities", "must be one of: tasks, users")
+	}
+	switch spec.Format {
+	case "", FormatJSON, FormatCSV:
+	default:
+		return nil, specError("format", "must be one of: json, csv")
+	}
+
+	q := &query{}
+	for i, f := range spec.Filters {
+		match, cost, err := compileFilter(fields, f)
+		if err != nil {
+			err.Field = fmt.Sprintf("filters[%d].%s", i, err.Field)
+			return nil, err
+		}
+		q.filters = append(q.filters, match)
+		q.filterCost += cost
+	}
+
+	seen := make(map[string]bool)
+	for i, name := range spec.GroupBy {
+		fieldName, trunc, _ := strings.Cut(name, ":")
+		f, ok := fields[fieldName]
+		if !ok {
+			return nil, specError(fmt.Sprintf("groupBy[%d]", i), "unknown field %q", fieldName)
+		}
+		if trunc != "" && (f.kind != kindTime || truncate(time.Time{}, trunc) == "") {
+			return nil, specError(fmt.Sprintf("groupBy[%d]", i), "%q cannot be truncated to %q", fieldName, trunc)
+		}
+		if seen[name] {
+			return nil, specError(fmt.Sprintf("groupBy[%d]", i), "duplicate column %q", name)
+		}
+		seen[name] = true
+		q.groups = append(q.groups, groupKey{name: name, field: f, trunc: trunc})
+	}
+
+	metrics := spec.Metrics
+	if len(metrics) == 0 {
+		metrics = []Metric{{Op: "count"}}
+	}
+	for i, m := range metrics {
+		path := fmt.Sprintf("metrics[%d]", i)
+		compiled := metric{op: m.Op, name: m.As}
+		switch m.Op {
+		case "count", "sum", "avg", "min", "max":
+		default:
+			return nil, specError(path+".op", "must be one of: count, sum, avg, min, max")
+		}
+		if m.Field != "" {
+			f, ok := fields[m.Field]
+			if !ok {
+				return nil, specError(path+".field", "unknown field %q", m.Field)
+			}
+			if m.Op != "count" && f.kind != kindNumber {
+				return nil, specError(path+".field", "%s needs a numeric field", m.Op)
+			}
+			compiled.field = &f
+		} else if m.Op != "count" {
+			return nil, specError(path+".field", "%s needs a field", m.Op)
+		}
+		if compiled.name == "" {
+			compiled.name = m.Op
+			if m.Field != "" {
+				compiled.name += "(" + m.Field + ")"
+			}
+		}
+		if seen[compiled.name] {
+			return nil, specError(path, "duplicate column %q", compiled.name)
+		}
+		seen[compiled.name] = true
+		q.metrics = append(q.metrics, compiled)
+	}
+	return q, nil
+}
+
+// compileFilter returns a matcher for f and how many values it compares
+// per record.
+func compileFilter(fields map[string]field, f Filter) (func(rec record) bool, int, *SpecError) {
+	fd, ok := fields[f.Field]
+	if !ok {
+		return nil, 0, specError("field", "unknown field %q", f.Field)
+	}
+
+	if f.Op == "in" {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(f.Value, &raw); err != nil {
+			return nil, 0, specError("value", "in needs a list")
+		}
+		values := make([]interface{}, len(raw))
+		for i, r := range raw {
+			v, err := parseValue(fd.kind, r)
+			if err != nil {
+				return nil, 0, specError("value", "%v", err)
+			}
+			values[i] = v
+		}
+		return func(rec record) bool {
+			got := fd.get(rec)
+			for _, v := range values {
+				if got != nil && compare(got, v) == 0 {
+					return true
+				}
+			}
+			return false
+		}, len(values), nil
+	}
+
+	want, err := parseValue(fd.kind, f.Value)
+	if err != nil {
+		return nil, 0, specError("value", "%v", err)
+	}
+	var test func(c int) bool
+	switch f.Op {
+	case "eq":
+		test = func(c int) bool { return c == 0 }
+	case "ne":
+		test = func(c int) bool { return c != 0 }
+	case "lt":
+		test = func(c int) bool { return c < 0 }
+	case "lte":
+		test = func(c int) bool { return c <= 0 }
+	case "gt":
+		test = func(c int) bool { return c > 0 }
+	case "gte":
+		test = func(c int) bool { return c >= 0 }
+	case "contains":
+		if fd.kind != kindString {
+			return nil, 0, specError("op", "contains needs a string field")
+		}
+		needle := strings.ToLower(want.(string))
+		return func(rec record) bool {
+			got, ok := fd.get(rec).(string)
+			return ok && strings.Contains(strings.ToLower(got), needle)
+		}, 1, nil
+	default:
+		return nil, 0, specError("op", "must be one of: eq, ne, lt, lte, gt, gte, in, contains")
+	}
+	return func(rec record) bool {
+		got := fd.get(rec)
+		if got == nil {
+			// Unset values only match "ne"
+			return f.Op == "ne"
+		}
+		return test(compare(got, want))
+	}, 1, nil
+}
+
+// parseValue parses a filter value for a field of kind k.
+func parseValue(k kind, raw json.RawMessage) (interface{}, error) {
+	switch k {
+	case kindNumber:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return n, nil
+	case kindBool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return b, nil
+	case kindTime:
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t, nil
+			}
+			if t, err := time.Parse(time.DateOnly, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("expected an RFC 3339 timestamp or a date")
+	default:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("expected a string")
+		}
+		return s, nil
+	}
+}
+
+// compare orders two non-nil values of the same kind.
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
+
+// truncate formats t truncated to unit, in UTC, or returns "" for an
+// unknown unit. Weeks start on Monday.
+func truncate(t time.Time, unit string) string {
+	t = t.UTC()
+	switch unit {
+	case "day":
+		return t.Format(time.DateOnly)
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -offset).Format(time.DateOnly)
+	case "month":
+		return t.Format("2006-01")
+	case "year":
+		return t.Format("2006")
+	}
+	return ""
+}
+
+// group accumulates the records sharing a key.
+type group struct {
+	keys   []interface{}
+	counts []int
+	sums   []float64
+	mins   []float64
+	maxes  []float64
+}
+
+func (q *query) run(recs []record) model.ReportResult {
+	groups := make(map[string]*group)
+	var order []*group
+
+	for _, rec := range recs {
+		if !q.matches(rec) {
+			continue
+		}
+
+		keys := make([]interface{}, len(q.groups))
+		var id strings.Builder
+		for i, g := range q.groups {
+			v := g.field.get(rec)
+			if t, ok := v.(time.Time); ok {
+				if g.trunc != "" {
+					v = truncate(t, g.trunc)
+				} else {
+					v = t.UTC().Format(time.RFC3339)
+				}
+			}
+			keys[i] = v
+			fmt.Fprintf(&id, "%T:%v\x00", v, v)
+		}
+
+		grp := groups[id.String()]
+		if grp == nil {
+			n := len(q.metrics)
+			grp = &group{keys: keys, counts: make([]int, n), sums: make([]float64, n), mins: make([]float64, n), maxes: make([]float64, n)}
+			for i := range grp.mins {
+				grp.mins[i] = math.Inf(1)
+				grp.maxes[i] = math.Inf(-1)
+			}
+			groups[id.String()] = grp
+			order = append(order, grp)
+		}
+		for i, m := range q.metrics {
+			if m.field == nil {
+				grp.counts[i]++
+				continue
+			}
+			v := m.field.get(rec)
+			if v == nil {
+				continue
+			}
+			grp.counts[i]++
+			if n, ok := v.(float64); ok {
+				grp.sums[i] += n
+				grp.mins[i] = math.Min(grp.mins[i], n)
+				grp.maxes[i] = math.Max(grp.maxes[i], n)
+			}
+		}
+	}
+
+	// A report without grouping always has its one row, even if empty
+	if len(q.groups) == 0 && len(order) == 0 {
+		n := len(q.metrics)
+		order = append(order, &group{counts: make([]int, n), sums: make([]float64, n), mins: make([]float64, n), maxes: make([]float64, n)})
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		for k := range q.groups {
+			a, b := order[i].keys[k], order[j].keys[k]
+			switch {
+			case a == nil && b == nil:
+				continue
+			case a == nil:
+				return false
+			case b == nil:
+				return true
+			}
+			if c := compare(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	result := model.ReportResult{Columns: make([]string, 0, len(q.groups)+len(q.metrics)), Rows: make([][]interface{}, len(order))}
+	for _, g := range q.groups {
+		result.Columns = append(result.Columns, g.name)
+	}
+	for _, m := range q.metrics {
+		result.Columns = append(result.Columns, m.name)
+	}
+	for i, grp := range order {
+		row := append([]interface{}{}, grp.keys...)
+		for j, m := range q.metrics {
+			row = append(row, grp.value(j, m.op))
+		}
+		result.Rows[i] = row
+	}
+	result.Count = len(result.Rows)
+	return result
+}
+
+func (q *query) matches(rec record) bool {
+	for _, match := range q.filters {
+		if !match(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns metric i of the group, or nil if it has no values.
+func (grp *group) value(i int, op string) interface{} {
+	if op == "count" {
+		return grp.counts[i]
+	}
+	if grp.counts[i] == 0 {
+		return nil
+	}
+	switch op {
+	case "sum":
+		return grp.sums[i]
+	case "avg":
+		return grp.sums[i] / float64(grp.counts[i])
+	case "min":
+		return grp.mins[i]
+	default:
+		return grp.maxes[i]
+	}
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+var (
+	builderNow   = time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)
+	builderUsers = []model.User{
+		{ID: 1, Name: "Ann", Email: "ann@example.com", Role: "developer"},
+		{ID: 2, Name: "Ben", Email: "ben@example.com", Role: "designer"},
+	}
+	builderTasks = func() []model.Task {
+		day := func(d int) time.Time { return builderNow.AddDate(0, 0, d) }
+		done := day(-1)
+		due := day(-2)
+		return []model.Task{
+			{ID: 1, Title: "Write spec", Status: "completed", UserID: 1, CreatedAt: day(-5), CompletedAt: &done},
+			{ID: 2, Title: "Write code", Status: "in-progress", UserID: 1, CreatedAt: day(-40), DueAt: &due},
+			{ID: 3, Title: "Draw mockups", Status: "pending", UserID: 2, CreatedAt: day(-3)},
+			{ID: 4, Title: "Orphan", Status: "pending", UserID: 9, CreatedAt: day(-3)},
+		}
+	}()
+)
+
+func build(t *testing.T, raw string) model.ReportResult {
+	t.Helper()
+	var spec Spec
+	if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	result, err := Build(spec, builderUsers, builderTasks, builderNow)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return result
+}
+
+func TestBuild_GroupAndMetrics(t *testing.T) {
+	t.Parallel()
+
+	result := build(t, `{
+		"entities": "tasks",
+		"groupBy": ["user.role"],
+		"metrics": [{"op": "count"}, {"op": "max", "field": "ageDays", "as": "oldest"}, {"op": "count", "field": "completedAt"}]
+	}`)
+
+	want := model.ReportResult{
+		Columns: []string{"user.role", "count", "oldest", "count(completedAt)"},
+		Rows: [][]interface{}{
+			{"designer", 1, 3.0, 0},
+			{"developer", 2, 40.0, 1},
+			{nil, 1, 3.0, 0},
+		},
+		Count: 3,
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("expected %+v, got %+v", want, result)
+	}
+}
+
+func TestBuild_Filters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		filters string
+		want    int
+	}{
+		{"eq", `[{"field": "status", "op": "eq", "value": "pending"}]`, 2},
+		{"in", `[{"field": "userId", "op": "in", "value": [1, 2]}]`, 3},
+		{"contains", `[{"field": "title", "op": "contains", "value": "WRITE"}]`, 2},
+		{"bool", `[{"field": "overdue", "op": "eq", "value": true}]`, 1},
+		{"date", `[{"field": "createdAt", "op": "gte", "value": "2026-03-01"}]`, 3},
+		{"unset only matches ne", `[{"field": "dueAt", "op": "ne", "value": "2026-01-01"}]`, 4},
+		{"all must match", `[{"field": "userId", "op": "eq", "value": 1}, {"field": "status", "op": "ne", "value": "completed"}]`, 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := build(t, `{"entities": "tasks", "filters": `+tt.filters+`}`)
+			if got := result.Rows[0][0]; got != tt.want {
+				t.Errorf("expected %d matching tasks, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuild_TimeBuckets(t *testing.T) {
+	t.Parallel()
+
+	result := build(t, `{"entities": "tasks", "groupBy": ["createdAt:week"]}`)
+
+	// 2026-03-09 is a Monday
+	want := [][]interface{}{{"2026-01-26", 1}, {"2026-03-02", 3}}
+	if !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("expected %v, got %v", want, result.Rows)
+	}
+}
+
+func TestBuild_InvalidSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec  string
+		field string
+	}{
+		{`{"entities": "projects"}`, "entities"},
+		{`{"entities": "tasks", "format": "xml"}`, "format"},
+		{`{"entities": "tasks", "filters": [{"field": "secret", "op": "eq", "value": 1}]}`, "filters[0].field"},
+		{`{"entities": "tasks", "filters": [{"field": "id", "op": "eq", "value": "one"}]}`, "filters[0].value"},
+		{`{"entities": "tasks", "filters": [{"field": "id", "op": "like", "value": 1}]}`, "filters[0].op"},
+		{`{"entities": "tasks", "groupBy": ["status:week"]}`, "groupBy[0]"},
+		{`{"entities": "users", "metrics": [{"op": "sum", "field": "name"}]}`, "metrics[0].field"},
+		{`{"entities": "users", "metrics": [{"op": "median", "field": "id"}]}`, "metrics[0].op"},
+	}
+	for _, tt := range tests {
+		var spec Spec
+		if err := json.Unmarshal([]byte(tt.spec), &spec); err != nil {
+			t.Fatalf("invalid spec: %v", err)
+		}
+		_, err := Build(spec, builderUsers, builderTasks, builderNow)
+		var specErr *SpecError
+		if !errors.As(err, &specErr) || specErr.Field != tt.field {
+			t.Errorf("%s: expected an error for %s, got %v", tt.spec, tt.field, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package report computes managerial reports from users and tasks.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Defaults for CapacityWeights.
+const (
+	DefaultOpenWeight       = 1.0
+	DefaultInProgressWeight = 1.5
+	DefaultDueSoonWeight    = 2.0
+	DefaultOverdueWeight    = 3.0
+	DefaultDueSoonWindow    = 72 * time.Hour
+	DefaultCapacity         = 10.0
+)
+
+// CapacityWeights configure how open tasks add to a user's load. A task
+// weighs Open, or InProgress once started, multiplied by DueSoon if it is
+// due within DueSoonWindow or by Overdue if it is past due. Users whose
+// load exceeds Capacity are over-allocated. Zero fields use the defaults.
+//
+// Tasks have no priority yet, so every task counts as the same priority.
+type CapacityWeights struct {
+	Open          float64
+	InProgress    float64
+	DueSoon       float64
+	Overdue       float64
+	DueSoonWindow time.Duration
+	Capacity      float64
+}
+
+// WithDefaults returns w with zero fields set to their defaults.
+func (w CapacityWeights) WithDefaults() CapacityWeights {
+	if w.Open <= 0 {
+		w.Open = DefaultOpenWeight
+	}
+	if w.InProgress <= 0 {
+		w.InProgress = DefaultInProgressWeight
+	}
+	if w.DueSoon <= 0 {
+		w.DueSoon = DefaultDueSoonWeight
+	}
+	if w.Overdue <= 0 {
+		w.Overdue = DefaultOverdueWeight
+	}
+	if w.DueSoonWindow <= 0 {
+		w.DueSoonWindow = DefaultDueSoonWindow
+	}
+	if w.Capacity <= 0 {
+		w.Capacity = DefaultCapacity
+	}
+	return w
+}
+
+// ParseCapacityWeights parses comma-separated name=value pairs, e.g.
+// "overdue=4,capacity=12,dueSoonWindow=48h". Names are those of the
+// weights in the report; values must be positive.
+func ParseCapacityWeights(raw string) (CapacityWeights, error) {
+	var w CapacityWeights
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return CapacityWeights{}, fmt.Errorf("invalid weight %q: want name=value", pair)
+		}
+
+		if name == "dueSoonWindow" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return CapacityWeights{}, fmt.Errorf("invalid dueSoonWindow %q", value)
+			}
+			w.DueSoonWindow = d
+			continue
+		}
+
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f <= 0 {
+			return CapacityWeights{}, fmt.Errorf("invalid %s weight %q: want a positive number", name, value)
+		}
+		switch name {
+		case "open":
+			w.Open = f
+		case "inProgress":
+			w.InProgress = f
+		case "dueSoon":
+			w.DueSoon = f
+		case "overdue":
+			w.Overdue = f
+		case "capacity":
+			w.Capacity = f
+		default:
+			return CapacityWeights{}, fmt.Errorf("unknown weight %q", name)
+		}
+	}
+	return w, nil
+}
+
+// Capacity estimates each user's workload at now from their open tasks,
+// ordered from most to least loaded.
+func Capacity(users []model.User, tasks []model.Task, now time.Time, weights CapacityWeights) model.CapacityReport {
+	weights = weights.WithDefaults()
+
+	rows := make(map[int]*model.UserCapacity, len(users))
+	for _, user := range users {
+		rows[user.ID] = &model.UserCapacity{UserID: user.ID, Name: user.Name}
+	}
+
+	for _, task := range tasks {
+		row := rows[task.UserID]
+		if row == nil || task.Status == "completed" {
+			continue
+		}
+
+		weight := weights.Open
+		if task.Status == "in-progress" {
+			weight = weights.InProgress
+		}
+		switch {
+		case task.Overdue(now):
+			weight *= weights.Overdue
+			row.Overdue++
+		case task.DueAt != nil && task.DueAt.Sub(now) <= weights.DueSoonWindow:
+			weight *= weights.DueSoon
+			row.DueSoon++
+		}
+		row.OpenTasks++
+		row.Load += weight
+	}
+
+	report := model.CapacityReport{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		Weights: model.CapacityWeights{
+			Open:          weights.Open,
+			InProgress:    weights.InProgress,
+			DueSoon:       weights.DueSoon,
+			Overdue:       weights.Overdue,
+			DueSoonWindow: weights.DueSoonWindow.String(),
+			Capacity:      weights.Capacity,
+		},
+		Users: make([]model.UserCapacity, 0, len(rows)),
+	}
+	for _, user := range users {
+		row := rows[user.ID]
+		row.Utilization = row.Load / weights.Capacity
+		row.OverAllocated = row.Load > weights.Capacity
+		if row.OverAllocated {
+			report.OverAllocated++
+		}
+		report.Users = append(report.Users, *row)
+	}
+	sort.SliceStable(report.Users, func(i, j int) bool {
+		return report.Users[i].Load > report.Users[j].Load
+	})
+	return report
+}
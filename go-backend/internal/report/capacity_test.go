@@ -0,0 +1,66 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+func TestCapacity(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		due := now.Add(d)
+		return &due
+	}
+	users := []model.User{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Ben"}, {ID: 3, Name: "Cy"}}
+	tasks := []model.Task{
+		{ID: 1, Status: "pending", UserID: 1},                                 // 1
+		{ID: 2, Status: "in-progress", UserID: 1},                             // 1.5
+		{ID: 3, Status: "pending", UserID: 1, DueAt: at(-time.Hour)},          // 1 * 3
+		{ID: 4, Status: "pending", UserID: 1, DueAt: at(24 * time.Hour)},      // 1 * 2
+		{ID: 5, Status: "pending", UserID: 1, DueAt: at(30 * 24 * time.Hour)}, // 1
+		{ID: 6, Status: "completed", UserID: 1, DueAt: at(-time.Hour)},        // done
+		{ID: 7, Status: "pending", UserID: 2},
+		{ID: 8, Status: "pending", UserID: 99},
+	}
+
+	rep := Capacity(users, tasks, now, CapacityWeights{Capacity: 8})
+
+	if len(rep.Users) != 3 {
+		t.Fatalf("expected 3 users, got %d", len(rep.Users))
+	}
+	ann := rep.Users[0]
+	if ann.UserID != 1 || ann.Load != 8.5 || ann.OpenTasks != 5 || ann.Overdue != 1 || ann.DueSoon != 1 {
+		t.Errorf("expected Ann first with load 8.5 from 5 open tasks, got %+v", ann)
+	}
+	if !ann.OverAllocated || rep.OverAllocated != 1 {
+		t.Errorf("expected only Ann over-allocated, got %+v", rep)
+	}
+	if last := rep.Users[2]; last.UserID != 3 || last.Load != 0 {
+		t.Errorf("expected Cy last with no load, got %+v", last)
+	}
+	if rep.Weights.Overdue != DefaultOverdueWeight || rep.Weights.Capacity != 8 {
+		t.Errorf("expected the report to list the weights used, got %+v", rep.Weights)
+	}
+}
+
+func TestParseCapacityWeights(t *testing.T) {
+	t.Parallel()
+
+	w, err := ParseCapacityWeights("overdue=4, capacity=12.5,dueSoonWindow=48h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Overdue != 4 || w.Capacity != 12.5 || w.DueSoonWindow != 48*time.Hour || w.Open != 0 {
+		t.Errorf("unexpected weights %+v", w)
+	}
+
+	for _, raw := range []string{"overdue", "overdue=-1", "priority=2", "dueSoonWindow=soon"} {
+		if _, err := ParseCapacityWeights(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
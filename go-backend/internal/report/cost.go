@@ -0,0 +1,118 @@
+package report
+
+import (
+	"fmt"
+	"math"
+)
+
+// Defaults for Limits.
+const (
+	DefaultMaxRows        = 100000
+	DefaultMaxEvaluations = 10000000
+	DefaultMaxGroups      = 10000
+)
+
+// Cost estimates the work a report does before it is run.
+type Cost struct {
+	// Rows is how many records are scanned.
+	Rows int
+	// Evaluations is how many values are read or compared: each filter,
+	// grouping and metric per record, and each value of an "in" filter.
+	Evaluations int
+	// Groups is an upper bound on the rows the report produces.
+	Groups int
+}
+
+// Limits cap the cost of reports run without being forced. Zero fields
+// use the defaults.
+type Limits struct {
+	MaxRows        int
+	MaxEvaluations int
+	MaxGroups      int
+}
+
+// WithDefaults returns l with zero fields set to their defaults.
+func (l Limits) WithDefaults() Limits {
+	if l.MaxRows <= 0 {
+		l.MaxRows = DefaultMaxRows
+	}
+	if l.MaxEvaluations <= 0 {
+		l.MaxEvaluations = DefaultMaxEvaluations
+	}
+	if l.MaxGroups <= 0 {
+		l.MaxGroups = DefaultMaxGroups
+	}
+	return l
+}
+
+// Check returns a *CostError if c exceeds any of the limits.
+func (l Limits) Check(c Cost) error {
+	l = l.WithDefaults()
+	switch {
+	case c.Rows > l.MaxRows:
+		return &CostError{"rows scanned", c.Rows, l.MaxRows}
+	case c.Evaluations > l.MaxEvaluations:
+		return &CostError{"evaluations", c.Evaluations, l.MaxEvaluations}
+	case c.Groups > l.MaxGroups:
+		return &CostError{"groups", c.Groups, l.MaxGroups}
+	}
+	return nil
+}
+
+// CostError reports a report estimated to cost more than a limit allows.
+type CostError struct {
+	Measure  string
+	Estimate int
+	Limit    int
+}
+
+func (e *CostError) Error() string {
+	return fmt.Sprintf("estimated %d %s, over the limit of %d", e.Estimate, e.Measure, e.Limit)
+}
+
+// Estimate returns the cost of running spec over the given number of
+// users and tasks, without reading them. An invalid spec returns a
+// *SpecError.
+func Estimate(spec Spec, users, tasks int) (Cost, error) {
+	q, err := compile(spec)
+	if err != nil {
+		return Cost{}, err
+	}
+
+	rows := tasks
+	if spec.Entities == "users" {
+		rows = users
+	}
+	return Cost{
+		Rows:        rows,
+		Evaluations: saturatingMul(rows, q.filterCost+len(q.groups)+len(q.metrics)),
+		Groups:      q.maxGroups(rows),
+	}, nil
+}
+
+// maxGroups bounds the groups produced from rows records: at most one per
+// record, and at most three per boolean key (true, false and unset).
+func (q *query) maxGroups(rows int) int {
+	if len(q.groups) == 0 {
+		return 1
+	}
+	bound := 1
+	for _, g := range q.groups {
+		if g.field.kind != kindBool {
+			return rows
+		}
+		bound *= 3
+		if bound >= rows {
+			return rows
+		}
+	}
+	return bound
+}
+
+// saturatingMul returns a*b, or the largest int if that overflows.
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
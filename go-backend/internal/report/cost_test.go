@@ -0,0 +1,58 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		spec string
+		want Cost
+	}{
+		{"count", `{"entities":"tasks"}`, Cost{Rows: 1000, Evaluations: 1000, Groups: 1}},
+		{"users", `{"entities":"users","groupBy":["role"]}`, Cost{Rows: 10, Evaluations: 20, Groups: 10}},
+		{"boolean groups", `{"entities":"tasks","groupBy":["overdue","user.digestOptOut"]}`, Cost{Rows: 1000, Evaluations: 3000, Groups: 9}},
+		{"in filter", `{"entities":"tasks","filters":[{"field":"id","op":"in","value":[1,2,3,4]}],"groupBy":["id"]}`,
+			Cost{Rows: 1000, Evaluations: 6000, Groups: 1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var spec Spec
+			if err := json.Unmarshal([]byte(tt.spec), &spec); err != nil {
+				t.Fatalf("invalid spec: %v", err)
+			}
+			got, err := Estimate(spec, 10, 1000)
+			if err != nil {
+				t.Fatalf("Estimate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Estimate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits_Check(t *testing.T) {
+	t.Parallel()
+
+	limits := Limits{MaxGroups: 100}
+	if err := limits.Check(Cost{Rows: 1000, Evaluations: 1000, Groups: 100}); err != nil {
+		t.Errorf("expected a cost at the limits to pass, got %v", err)
+	}
+
+	err := limits.Check(Cost{Rows: 1000, Evaluations: 1000, Groups: 101})
+	var costErr *CostError
+	if !errors.As(err, &costErr) || costErr.Measure != "groups" || costErr.Limit != 100 {
+		t.Errorf("expected a groups CostError, got %v", err)
+	}
+
+	if err := limits.Check(Cost{Rows: DefaultMaxRows + 1}); err == nil {
+		t.Error("expected unset limits to use the defaults")
+	}
+}
@@ -0,0 +1,142 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshInterval is how often materialized reports are recomputed.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// ErrUnknownReport is returned for a report that was not registered.
+var ErrUnknownReport = errors.New("unknown report")
+
+// Func computes a report as of now.
+type Func func(ctx context.Context, now time.Time) (interface{}, error)
+
+// Materialized is a computed report and when it was computed.
+type Materialized struct {
+	Name        string
+	GeneratedAt time.Time
+	Report      interface{}
+}
+
+// Materializer keeps the latest result of each registered report, so
+// requests are served without recomputing it. Results are refreshed on a
+// schedule and on demand, and are as stale as the last refresh.
+type Materializer struct {
+	interval time.Duration
+
+	mu      sync.RWMutex
+	funcs   map[string]Func
+	results map[string]Materialized
+
+	// refreshMu serializes refreshes so two cannot compute the same report
+	// at once and store their results out of order.
+	refreshMu sync.Mutex
+}
+
+// NewMaterializer returns a materializer refreshing every interval; zero
+// uses DefaultRefreshInterval.
+func NewMaterializer(interval time.Duration) *Materializer {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	return &Materializer{
+		interval: interval,
+		funcs:    make(map[string]Func),
+		results:  make(map[string]Materialized),
+	}
+}
+
+// Register adds a report computed by fn under name.
+func (m *Materializer) Register(name string, fn Func) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcs[name] = fn
+}
+
+// Names returns the registered reports in name order.
+func (m *Materializer) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.funcs))
+	for name := range m.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the latest result of the named report, computing it first
+// if it has never been.
+func (m *Materializer) Get(ctx context.Context, name string) (Materialized, error) {
+	m.mu.RLock()
+	result, ok := m.results[name]
+	m.mu.RUnlock()
+	if ok {
+		return result, nil
+	}
+	return m.Refresh(ctx, name)
+}
+
+// Refresh recomputes the named report and returns the new result. On
+// failure the previous result is kept.
+func (m *Materializer) Refresh(ctx context.Context, name string) (Materialized, error) {
+	m.mu.RLock()
+	fn, ok := m.funcs[name]
+	m.mu.RUnlock()
+	if !ok {
+		return Materialized{}, ErrUnknownReport
+	}
+
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	now := time.Now().UTC()
+	rep, err := fn(ctx, now)
+	if err != nil {
+		return Materialized{}, err
+	}
+	result := Materialized{Name: name, GeneratedAt: now, Report: rep}
+
+	m.mu.Lock()
+	m.results[name] = result
+	m.mu.Unlock()
+	return result, nil
+}
+
+// RefreshAll recomputes every registered report. Failures are logged and
+// leave that report's previous result in place.
+func (m *Materializer) RefreshAll(ctx context.Context) []Materialized {
+	var results []Materialized
+	for _, name := range m.Names() {
+		result, err := m.Refresh(ctx, name)
+		if err != nil {
+			log.Printf("Warning: Failed to refresh report %s: %v", name, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Schedule refreshes every report now and then every interval until ctx
+// is done.
+func (m *Materializer) Schedule(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.RefreshAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
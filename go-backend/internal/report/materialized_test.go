@@ -0,0 +1,48 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaterializer(t *testing.T) {
+	t.Parallel()
+
+	runs := 0
+	fail := false
+	m := NewMaterializer(0)
+	m.Register("count", func(ctx context.Context, now time.Time) (interface{}, error) {
+		if fail {
+			return nil, errors.New("store unavailable")
+		}
+		runs++
+		return runs, nil
+	})
+	ctx := context.Background()
+
+	first, err := m.Get(ctx, "count")
+	if err != nil || first.Report != 1 || first.GeneratedAt.IsZero() {
+		t.Fatalf("expected the first Get to compute the report, got %+v, %v", first, err)
+	}
+	if again, _ := m.Get(ctx, "count"); again.Report != 1 || runs != 1 {
+		t.Errorf("expected Get to serve the stored result, got %+v after %d runs", again, runs)
+	}
+
+	if refreshed, _ := m.Refresh(ctx, "count"); refreshed.Report != 2 {
+		t.Errorf("expected Refresh to recompute the report, got %+v", refreshed)
+	}
+
+	fail = true
+	if _, err := m.Refresh(ctx, "count"); err == nil {
+		t.Error("expected a failed refresh to return its error")
+	}
+	if kept, _ := m.Get(ctx, "count"); kept.Report != 2 {
+		t.Errorf("expected a failed refresh to keep the previous result, got %+v", kept)
+	}
+
+	if _, err := m.Get(ctx, "missing"); !errors.Is(err, ErrUnknownReport) {
+		t.Errorf("expected ErrUnknownReport, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Bounds of the window of a workload report.
+const (
+	DefaultWorkloadWindow = 30 * 24 * time.Hour
+	MaxWorkloadWindow     = 365 * 24 * time.Hour
+)
+
+// ParseWindow parses a workload window: a number of days such as "30d",
+// or a duration such as "72h", up to MaxWorkloadWindow.
+func ParseWindow(raw string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", raw)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", raw)
+		}
+		window = d
+	}
+	if window <= 0 || window > MaxWorkloadWindow {
+		return 0, fmt.Errorf("window %q must be positive and at most %dd", raw, MaxWorkloadWindow/(24*time.Hour))
+	}
+	return window, nil
+}
+
+// Workload reports each user's tasks at now, in user order: their counts
+// by status, the average age of their open tasks, and how many they
+// completed in the window up to now.
+func Workload(users []model.User, tasks []model.Task, now time.Time, window time.Duration) model.WorkloadReport {
+	type totals struct {
+		row     model.UserWorkload
+		openAge time.Duration
+	}
+	rows := make(map[int]*totals, len(users))
+	for _, user := range users {
+		rows[user.ID] = &totals{row: model.UserWorkload{UserID: user.ID, Name: user.Name}}
+	}
+
+	since := now.Add(-window)
+	for _, task := range tasks {
+		t := rows[task.UserID]
+		if t == nil {
+			continue
+		}
+		t.row.Tasks.Total++
+		switch task.Status {
+		case "pending":
+			t.row.Tasks.Pending++
+		case "in-progress":
+			t.row.Tasks.InProgress++
+		case "completed":
+			t.row.Tasks.Completed++
+			if task.CompletedAt != nil && task.CompletedAt.After(since) && !task.CompletedAt.After(now) {
+				t.row.Completed++
+			}
+			continue
+		}
+		t.openAge += now.Sub(task.CreatedAt)
+	}
+
+	report := model.WorkloadReport{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		Window:      window.String(),
+		Users:       make([]model.UserWorkload, 0, len(users)),
+	}
+	for _, user := range users {
+		t := rows[user.ID]
+		if open := t.row.Tasks.Total - t.row.Tasks.Completed; open > 0 {
+			t.row.AverageOpenAgeHours = t.openAge.Hours() / float64(open)
+			t.row.CompletionRate = float64(t.row.Completed) / float64(t.row.Completed+open)
+		} else if t.row.Completed > 0 {
+			t.row.CompletionRate = 1
+		}
+		report.Users = append(report.Users, t.row)
+	}
+	return report
+}
@@ -0,0 +1,63 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+func TestWorkload(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	completed := func(d time.Duration) *time.Time {
+		at := ago(d)
+		return &at
+	}
+	day := 24 * time.Hour
+	users := []model.User{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Ben"}, {ID: 3, Name: "Cy"}}
+	tasks := []model.Task{
+		{ID: 1, Status: "pending", UserID: 1, CreatedAt: ago(10 * time.Hour)},
+		{ID: 2, Status: "in-progress", UserID: 1, CreatedAt: ago(30 * time.Hour)},
+		{ID: 3, Status: "completed", UserID: 1, CreatedAt: ago(20 * day), CompletedAt: completed(2 * day)},
+		{ID: 4, Status: "completed", UserID: 1, CreatedAt: ago(60 * day), CompletedAt: completed(40 * day)}, // before the window
+		{ID: 5, Status: "completed", UserID: 2, CreatedAt: ago(5 * day), CompletedAt: completed(day)},
+		{ID: 6, Status: "pending", UserID: 99, CreatedAt: ago(time.Hour)},
+	}
+
+	rep := Workload(users, tasks, now, DefaultWorkloadWindow)
+
+	if len(rep.Users) != 3 || rep.Window != "720h0m0s" || rep.GeneratedAt != "2026-03-09T12:00:00Z" {
+		t.Fatalf("expected a row for each of 3 users over 30 days, got %+v", rep)
+	}
+	ann := rep.Users[0]
+	if want := (model.TaskCounts{Total: 4, Pending: 1, InProgress: 1, Completed: 2}); ann.Tasks != want {
+		t.Errorf("expected Ann's tasks counted %+v, got %+v", want, ann.Tasks)
+	}
+	if ann.AverageOpenAgeHours != 20 || ann.Completed != 1 || ann.CompletionRate != 1.0/3 {
+		t.Errorf("expected Ann's open tasks 20h old and 1 of 3 completed in the window, got %+v", ann)
+	}
+	if ben := rep.Users[1]; ben.CompletionRate != 1 || ben.AverageOpenAgeHours != 0 {
+		t.Errorf("expected Ben to have completed everything, got %+v", ben)
+	}
+	if cy := rep.Users[2]; cy.Tasks.Total != 0 || cy.CompletionRate != 0 {
+		t.Errorf("expected Cy to have no tasks, got %+v", cy)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	t.Parallel()
+
+	for raw, want := range map[string]time.Duration{"7d": 7 * 24 * time.Hour, "36h": 36 * time.Hour, "365d": MaxWorkloadWindow} {
+		if got, err := ParseWindow(raw); err != nil || got != want {
+			t.Errorf("ParseWindow(%q): expected %v, got %v, %v", raw, want, got, err)
+		}
+	}
+	for _, raw := range []string{"", "0d", "-1h", "366d", "week", "1.5d"} {
+		if _, err := ParseWindow(raw); err == nil {
+			t.Errorf("ParseWindow(%q): expected an error", raw)
+		}
+	}
+}
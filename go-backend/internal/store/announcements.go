@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go-backend/internal/model"
+	"go-backend/internal/validator"
+)
+
+// GetAnnouncements returns all announcements, active or not, in ID order.
+func (s *Store) GetAnnouncements(ctx context.Context) []model.Announcement {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	announcements := make([]model.Announcement, len(s.announcements))
+	for i, a := range s.announcements {
+		announcements[i] = copyAnnouncement(a)
+	}
+	return announcements
+}
+
+// GetAnnouncementByID returns an announcement by ID or nil if not found.
+func (s *Store) GetAnnouncementByID(ctx context.Context, id int) *model.Announcement {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.announcements {
+		if s.announcements[i].ID == id {
+			a := copyAnnouncement(s.announcements[i])
+			return &a
+		}
+	}
+	return nil
+}
+
+// CreateAnnouncement adds an announcement and returns it with a generated
+// ID, its creation time, and the default level if it has none.
+func (s *Store) CreateAnnouncement(ctx context.Context, a model.Announcement) (model.Announcement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxID := 0
+	for _, existing := range s.announcements {
+		if existing.ID > maxID {
+			maxID = existing.ID
+		}
+	}
+
+	a.ID = s.ids.NextID(KindAnnouncement, maxID)
+	a.CreatedAt = time.Now().UTC()
+	a.UpdatedAt = a.CreatedAt
+	a.StartsAt = utc(a.StartsAt)
+	a.EndsAt = utc(a.EndsAt)
+	if a.Level == "" {
+		a.Level = validator.DefaultLevel
+	}
+
+	s.announcements = append(s.announcements, a)
+	s.journalAnnouncement(a)
+
+	return copyAnnouncement(a), nil
+}
+
+// UpdateAnnouncement updates an announcement and returns it, or nil if not
+// found. Only non-nil fields are updated.
+func (s *Store) UpdateAnnouncement(ctx context.Context, id int, update model.AnnouncementUpdate) (*model.Announcement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.announcements {
+		a := &s.announcements[i]
+		if a.ID != id {
+			continue
+		}
+		if update.Message != nil {
+			a.Message = *update.Message
+		}
+		if update.Level != nil {
+			a.Level = *update.Level
+		}
+		if update.StartsAt != nil {
+			a.StartsAt = utc(*update.StartsAt)
+		}
+		if update.EndsAt != nil {
+			a.EndsAt = utc(*update.EndsAt)
+		}
+		a.UpdatedAt = time.Now().UTC()
+		s.journalAnnouncement(*a)
+
+		updated := copyAnnouncement(*a)
+		return &updated, nil
+	}
+	return nil, nil
+}
+
+// DeleteAnnouncement removes an announcement and reports whether it
+// existed.
+func (s *Store) DeleteAnnouncement(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, a := range s.announcements {
+		if a.ID == id {
+			s.announcements = append(s.announcements[:i:i], s.announcements[i+1:]...)
+			s.journalDeleteAnnouncement(id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// RecordAudit appends an event to the audit trail and returns it with a
+// generated ID and, if it has none, the current time.
+func (s *Store) RecordAudit(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Events are appended in ID order, so the last has the highest ID
+	maxID := 0
+	if n := len(s.audit); n > 0 {
+		maxID = s.audit[n-1].ID
+	}
+
+	event.ID = s.ids.NextID(KindAuditEvent, maxID)
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	event.At = event.At.UTC()
+	event.Changes = slices.Clone(event.Changes)
+	if event.Changes == nil {
+		event.Changes = []model.FieldChange{}
+	}
+
+	s.audit = append(s.audit, event)
+	s.journalAuditEvent(event)
+
+	return copyAuditEvent(event), nil
+}
+
+// GetAuditEvents returns the audit events matching filter, newest first.
+func (s *Store) GetAuditEvents(ctx context.Context, filter model.AuditFilter) []model.AuditEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []model.AuditEvent{}
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+		if filter.Matches(s.audit[i]) {
+			events = append(events, copyAuditEvent(s.audit[i]))
+		}
+	}
+	return events
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Backend is the storage the API serves from. Store keeps data in memory
+// and persists it to a JSON file; PostgresStore keeps it in PostgreSQL.
+// Methods take the caller's context so database-backed implementations can
+// honor its cancellation and deadline; the in-memory Store ignores it.
+// Records returned belong to the caller, and records passed in are not
+// kept: changing either never changes what is stored.
+//
+// Writes, Restore and the GetUsers and GetTasks lists return an error
+// when the storage fails, e.g. when the database cannot be reached, and
+// then change nothing; the in-memory Store never fails. The other reads
+// report a failure as a missing or empty result.
+type Backend interface {
+	GetUsers(ctx context.Context) ([]model.User, error)
+	GetUserByID(ctx context.Context, id int) (*model.User, error)
+	UserExistsByEmail(ctx context.Context, email string) (bool, error)
+	CreateUser(ctx context.Context, user model.User) (model.User, error)
+	SetPasswordHash(ctx context.Context, id int, passwordHash string) (bool, error)
+	SetPreferences(ctx context.Context, id int, prefs model.UserPreferences) (bool, error)
+
+	GetTasks(ctx context.Context, status, userID string) ([]model.Task, error)
+	GetTaskByID(ctx context.Context, id int) (*model.Task, error)
+	CreateTask(ctx context.Context, task model.Task) (model.Task, error)
+	UpdateTask(ctx context.Context, id int, update model.TaskUpdate) (*model.Task, error)
+	AddBlocker(ctx context.Context, id, blockerID int) (*model.Task, error)
+	RemoveBlocker(ctx context.Context, id, blockerID int) (*model.Task, error)
+	// Revision grows with every change to tasks. TaskChanges returns
+	// ErrUnknownRevision for a revision it cannot list changes since.
+	Revision(ctx context.Context) int64
+	TaskChanges(ctx context.Context, since int64) (model.TaskChanges, error)
+
+	CreateShare(ctx context.Context, share model.Share) (model.Share, error)
+	GetShares(ctx context.Context, taskID int) []model.Share
+	GetShareByTokenHash(ctx context.Context, tokenHash string) *model.Share
+	DeleteShare(ctx context.Context, taskID int, id string) (bool, error)
+
+	GetAnnouncements(ctx context.Context) []model.Announcement
+	GetAnnouncementByID(ctx context.Context, id int) *model.Announcement
+	CreateAnnouncement(ctx context.Context, a model.Announcement) (model.Announcement, error)
+	UpdateAnnouncement(ctx context.Context, id int, update model.AnnouncementUpdate) (*model.Announcement, error)
+	DeleteAnnouncement(ctx context.Context, id int) (bool, error)
+
+	GetProjects(ctx context.Context) []model.Project
+	GetProjectByID(ctx context.Context, id int) *model.Project
+	CreateProject(ctx context.Context, project model.Project) (model.Project, error)
+	UpdateProject(ctx context.Context, id int, update model.ProjectUpdate) (*model.Project, error)
+	DeleteProject(ctx context.Context, id int) error
+
+	RecordAudit(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error)
+	GetAuditEvents(ctx context.Context, filter model.AuditFilter) []model.AuditEvent
+
+	GetWebhooks(ctx context.Context) []model.Webhook
+	GetWebhookByID(ctx context.Context, id int) *model.Webhook
+	CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) (bool, error)
+
+	GetStats(ctx context.Context) model.StatsResponse
+	EachUserStats(ctx context.Context, fn func(model.UserStats) bool)
+	// RecordStatsSnapshot keeps one snapshot per time, replacing any
+	// already recorded at snap.At. GetStatsSnapshots returns those from
+	// from up to to, oldest first.
+	RecordStatsSnapshot(ctx context.Context, snap model.StatsSnapshot) (model.StatsSnapshot, error)
+	GetStatsSnapshots(ctx context.Context, from, to time.Time) []model.StatsSnapshot
+
+	// Snapshot and Restore cover everything but the audit trail and
+	// stats history, which a restore must not rewrite, and webhooks,
+	// whose secrets backups must not carry.
+	Snapshot(ctx context.Context) (Snapshot, error)
+	Restore(ctx context.Context, snap Snapshot) error
+
+	// Persist checks that data can be saved, saving it if the backend
+	// does not already save every change as it is made.
+	Persist(ctx context.Context) error
+	// Close finishes pending writes and releases resources.
+	Close()
+}
+
+var (
+	_ Backend = (*Store)(nil)
+	_ Backend = (*PostgresStore)(nil)
+)
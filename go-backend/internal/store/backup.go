@@ -0,0 +1,60 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go-backend/internal/model"
+)
+
+// WriteBackup encodes snap to w in the data file format, including any
+// password hashes and the revision it was taken at, so it can be restored
+// with ReadBackup.
+func WriteBackup(w io.Writer, snap Snapshot) error {
+	stored := fileData{
+		SchemaVersion: LatestSchemaVersion(),
+		Users:         make([]storedUser, len(snap.users)),
+		Tasks:         snap.tasks,
+		Shares:        sharesToFile(snap.shares),
+		Announcements: snap.announcements,
+		Projects:      snap.projects,
+		Revision:      snap.revision,
+	}
+	for i, user := range snap.users {
+		stored.Users[i] = storedUser{User: user, PasswordHash: user.PasswordHash}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(stored); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// ReadBackup decodes a backup written by WriteBackup or a data file,
+// migrating backups taken by older versions. It does not validate the
+// contents.
+func ReadBackup(r io.Reader) (Snapshot, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read backup: %w", err)
+	}
+	stored, err := decodeFileData(data)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to parse backup: %w", err)
+	}
+
+	users := make([]model.User, len(stored.Users))
+	for i, su := range stored.Users {
+		users[i] = su.User
+		users[i].PasswordHash = su.PasswordHash
+	}
+	tasks := stored.Tasks
+	if tasks == nil {
+		tasks = []model.Task{}
+	}
+
+	return Snapshot{users: users, tasks: tasks, shares: sharesFromFile(stored.Shares), announcements: stored.Announcements, projects: stored.Projects, revision: stored.Revision}, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Records are copied as the Store returns them and as it takes them in,
+// so callers never share slices or pointers with the records it holds:
+// a caller changing a record it got, or gave, cannot change the Store's
+// without its lock.
+
+// copyTask returns a copy of task sharing nothing with it.
+func copyTask(task model.Task) model.Task {
+	task.Tags = slices.Clone(task.Tags)
+	task.Unfurls = slices.Clone(task.Unfurls)
+	task.BlockedBy = slices.Clone(task.BlockedBy)
+	task.CompletedAt = copyTime(task.CompletedAt)
+	task.DueAt = copyTime(task.DueAt)
+	return task
+}
+
+// copyTasks returns a copy of tasks sharing nothing with it.
+func copyTasks(tasks []model.Task) []model.Task {
+	copied := make([]model.Task, len(tasks))
+	for i, task := range tasks {
+		copied[i] = copyTask(task)
+	}
+	return copied
+}
+
+// copyAnnouncement returns a copy of a sharing nothing with it.
+func copyAnnouncement(a model.Announcement) model.Announcement {
+	a.StartsAt = copyTime(a.StartsAt)
+	a.EndsAt = copyTime(a.EndsAt)
+	return a
+}
+
+// copyWebhook returns a copy of webhook sharing nothing with it.
+func copyWebhook(webhook model.Webhook) model.Webhook {
+	webhook.Events = slices.Clone(webhook.Events)
+	return webhook
+}
+
+// copyAuditEvent returns a copy of event with its own list of changes.
+func copyAuditEvent(event model.AuditEvent) model.AuditEvent {
+	event.Changes = slices.Clone(event.Changes)
+	return event
+}
+
+// copyTime returns a pointer to a copy of *t, or nil if t is nil.
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
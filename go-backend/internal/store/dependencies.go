@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go-backend/internal/model"
+)
+
+var (
+	// ErrTaskNotFound is returned when a task a dependency names does not
+	// exist.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrDependencyCycle is returned for a dependency that would make a task
+	// block itself, directly or through other tasks.
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+)
+
+// AddBlocker records that task blockerID blocks task id and returns the
+// updated task. Adding a blocker twice is harmless.
+func (s *Store) AddBlocker(ctx context.Context, id, blockerID int) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, taskOK := s.taskAt(id)
+	_, blockerOK := s.taskAt(blockerID)
+	switch {
+	case id == blockerID:
+		return nil, ErrDependencyCycle
+	case !taskOK || !blockerOK:
+		return nil, ErrTaskNotFound
+	case blocks(s.blockersOf, id, blockerID):
+		return nil, ErrDependencyCycle
+	}
+
+	if !slices.Contains(s.tasks[task].BlockedBy, blockerID) {
+		s.tasks[task].BlockedBy = append(slices.Clip(s.tasks[task].BlockedBy), blockerID)
+		s.journalTask(s.tasks[task])
+	}
+	copied := copyTask(s.tasks[task])
+	return &copied, nil
+}
+
+// RemoveBlocker records that task blockerID no longer blocks task id and
+// returns the updated task, or nil if it did not.
+func (s *Store) RemoveBlocker(ctx context.Context, id, blockerID int) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.taskAt(id)
+	if !ok {
+		return nil, nil
+	}
+	at := slices.Index(s.tasks[i].BlockedBy, blockerID)
+	if at < 0 {
+		return nil, nil
+	}
+	s.tasks[i].BlockedBy = slices.Delete(slices.Clone(s.tasks[i].BlockedBy), at, at+1)
+	if len(s.tasks[i].BlockedBy) == 0 {
+		s.tasks[i].BlockedBy = nil
+	}
+	s.journalTask(s.tasks[i])
+	task := copyTask(s.tasks[i])
+	return &task, nil
+}
+
+// blockersOf returns the blockers of the task with the given ID. Must be
+// called with mu held.
+func (s *Store) blockersOf(id int) []int {
+	if i, ok := s.taskAt(id); ok {
+		return s.tasks[i].BlockedBy
+	}
+	return nil
+}
+
+// blocks reports whether task blocker blocks task, directly or through
+// other tasks, given the blockers of each task.
+func blocks(blockedBy func(id int) []int, blocker, task int) bool {
+	seen := make(map[int]bool)
+	pending := []int{task}
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, b := range blockedBy(id) {
+			if b == blocker {
+				return true
+			}
+			if !seen[b] {
+				seen[b] = true
+				pending = append(pending, b)
+			}
+		}
+	}
+	return false
+}
+
+// HasDependencyCycle reports whether any of tasks blocks itself, directly
+// or through other tasks.
+func HasDependencyCycle(tasks []model.Task) bool {
+	blockedBy := make(map[int][]int, len(tasks))
+	for _, t := range tasks {
+		blockedBy[t.ID] = t.BlockedBy
+	}
+	lookup := func(id int) []int { return blockedBy[id] }
+	for _, t := range tasks {
+		if blocks(lookup, t.ID, t.ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// FileBacked is implemented by backends that save data to files, whose
+// disk must have room and be writable.
+type FileBacked interface {
+	// DataPath returns the path of the data file, or "" if nothing is
+	// saved.
+	DataPath() string
+	// PersistError returns the error of the latest write of the data, or
+	// nil if it succeeded or nothing has been written yet.
+	PersistError() error
+	// LastPersisted returns when the data was last written, or the zero
+	// time if it has not been since the backend was created.
+	LastPersisted() time.Time
+}
+
+// Pinger is implemented by backends that reach a server over the
+// network, which may be unreachable.
+type Pinger interface {
+	// Ping checks that the server can be reached.
+	Ping(ctx context.Context) error
+}
+
+// Clock is implemented by backends with a clock of their own, such as a
+// database server, which can drift from the server's.
+type Clock interface {
+	// Now returns the backend's current time.
+	Now(ctx context.Context) (time.Time, error)
+}
+
+var (
+	_ FileBacked = (*Store)(nil)
+	_ Clock      = (*PostgresStore)(nil)
+	_ Pinger     = (*PostgresStore)(nil)
+)
+
+// DataPath returns the file the Store persists to, or "" if the Store is
+// ephemeral.
+func (s *Store) DataPath() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ephemeral {
+		return ""
+	}
+	return s.dataPath
+}
+
+// PersistError returns the error of the latest write of the data file or
+// its journal, or nil if it succeeded or nothing has been written yet.
+func (s *Store) PersistError() error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	return s.persistErr
+}
+
+// LastPersisted returns when the data file or its journal was last
+// written, or the zero time if neither has been since the Store was
+// created.
+func (s *Store) LastPersisted() time.Time {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	return s.persistedAt
+}
+
+// Ping checks that the database can be reached.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	ctx, cancel := s.query(ctx)
+	defer cancel()
+	return s.pool.Ping(ctx)
+}
+
+// Now returns the time of the database server.
+func (s *PostgresStore) Now(ctx context.Context) (time.Time, error) {
+	ctx, cancel := s.query(ctx)
+	defer cancel()
+
+	var now time.Time
+	err := s.pool.QueryRow(ctx, `SELECT now()`).Scan(&now)
+	return now, err
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// RecordStatsSnapshot adds a snapshot to the stats history, replacing any
+// recorded at the same time, and returns it with its time in UTC.
+func (s *Store) RecordStatsSnapshot(ctx context.Context, snap model.StatsSnapshot) (model.StatsSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap.At = snap.At.UTC()
+	s.statsHistory = putStatsSnapshot(s.statsHistory, snap)
+	s.journalStatsSnapshot(snap)
+
+	return snap, nil
+}
+
+// GetStatsSnapshots returns the snapshots recorded from from up to to,
+// oldest first.
+func (s *Store) GetStatsSnapshots(ctx context.Context, from, to time.Time) []model.StatsSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := sort.Search(len(s.statsHistory), func(i int) bool { return !s.statsHistory[i].At.Before(from) })
+	end := sort.Search(len(s.statsHistory), func(i int) bool { return !s.statsHistory[i].At.Before(to) })
+	if end < start {
+		end = start
+	}
+	return append([]model.StatsSnapshot{}, s.statsHistory[start:end]...)
+}
+
+// putStatsSnapshot inserts snap into history, which is in time order, or
+// replaces the snapshot recorded at the same time.
+func putStatsSnapshot(history []model.StatsSnapshot, snap model.StatsSnapshot) []model.StatsSnapshot {
+	i := sort.Search(len(history), func(i int) bool { return !history[i].At.Before(snap.At) })
+	if i < len(history) && history[i].At.Equal(snap.At) {
+		history[i] = snap
+		return history
+	}
+	history = append(history, model.StatsSnapshot{})
+	copy(history[i+1:], history[i:])
+	history[i] = snap
+	return history
+}
@@ -0,0 +1,65 @@
+package store
+
+import "sync"
+
+// Entity kinds passed to IDGenerator.
+const (
+	KindUser = "user"
+	KindTask = "task"
+	// KindAnnouncement is the kind of announcements.
+	KindAnnouncement = "announcement"
+	// KindProject is the kind of projects.
+	KindProject = "project"
+	// KindAuditEvent is the kind of audit events.
+	KindAuditEvent = "auditEvent"
+	// KindWebhook is the kind of webhooks.
+	KindWebhook = "webhook"
+)
+
+// IDGenerator produces IDs for newly created entities.
+// Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	// NextID returns the ID for a new entity of the given kind.
+	// maxID is the highest ID currently in use for that kind.
+	NextID(kind string, maxID int) int
+}
+
+// MaxPlusOne generates IDs one above the highest existing ID.
+// It is the default generator.
+type MaxPlusOne struct{}
+
+// NextID returns maxID + 1.
+func (MaxPlusOne) NextID(_ string, maxID int) int {
+	return maxID + 1
+}
+
+// Sequence generates a deterministic, per-kind sequence of IDs starting at
+// a fixed value, independent of existing data. Tests use it so assertions
+// on IDs do not change when fixtures grow. Choose a start above any
+// fixture ID to avoid collisions.
+type Sequence struct {
+	mu    sync.Mutex
+	start int
+	next  map[string]int
+}
+
+// NewSequence creates a Sequence whose first ID for every kind is start.
+func NewSequence(start int) *Sequence {
+	return &Sequence{
+		start: start,
+		next:  make(map[string]int),
+	}
+}
+
+// NextID returns the next ID in the sequence for kind.
+func (s *Sequence) NextID(kind string, _ int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.next[kind]
+	if !ok {
+		id = s.start
+	}
+	s.next[kind] = id + 1
+	return id
+}
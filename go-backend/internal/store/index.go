@@ -0,0 +1,91 @@
+package store
+
+import "slices"
+
+// index locates users and tasks in a Store's slices without scanning
+// them: users by ID and email, and tasks by ID and by the user they are
+// assigned to. Positions are indexes into Store.users and Store.tasks,
+// which records are appended to but never removed from, except by
+// replacing them all. Where IDs or emails repeat, as they can in data
+// loaded from files, the first record is found.
+type index struct {
+	users   map[int]int
+	emails  map[string]int
+	tasks   map[int]int
+	byOwner map[int][]int // positions of each user's tasks, ascending
+}
+
+// reindexUsers rebuilds the user indexes. Must be called with mu held
+// whenever s.users is replaced.
+func (s *Store) reindexUsers() {
+	s.index.users = make(map[int]int, len(s.users))
+	s.index.emails = make(map[string]int, len(s.users))
+	for i := range s.users {
+		s.indexUser(i)
+	}
+}
+
+// reindexTasks rebuilds the task indexes. Must be called with mu held
+// whenever s.tasks is replaced.
+func (s *Store) reindexTasks() {
+	s.index.tasks = make(map[int]int, len(s.tasks))
+	s.index.byOwner = make(map[int][]int)
+	for i := range s.tasks {
+		s.indexTask(i)
+	}
+}
+
+// indexUser adds the user at position i. Must be called with mu held.
+func (s *Store) indexUser(i int) {
+	user := s.users[i]
+	if _, ok := s.index.users[user.ID]; !ok {
+		s.index.users[user.ID] = i
+	}
+	if _, ok := s.index.emails[user.Email]; !ok {
+		s.index.emails[user.Email] = i
+	}
+}
+
+// indexTask adds the task at position i, which must be after those of
+// the tasks already indexed. Must be called with mu held.
+func (s *Store) indexTask(i int) {
+	task := s.tasks[i]
+	if _, ok := s.index.tasks[task.ID]; !ok {
+		s.index.tasks[task.ID] = i
+	}
+	s.index.byOwner[task.UserID] = append(s.index.byOwner[task.UserID], i)
+}
+
+// reassignTask moves the task at position i from the tasks of user from
+// to those of the user it is now assigned to. Must be called with mu
+// held.
+func (s *Store) reassignTask(i, from int) {
+	owned := s.index.byOwner[from]
+	if at, ok := slices.BinarySearch(owned, i); ok {
+		owned = slices.Delete(owned, at, at+1)
+	}
+	if len(owned) == 0 {
+		delete(s.index.byOwner, from)
+	} else {
+		s.index.byOwner[from] = owned
+	}
+
+	to := s.tasks[i].UserID
+	owned = s.index.byOwner[to]
+	at, _ := slices.BinarySearch(owned, i)
+	s.index.byOwner[to] = slices.Insert(owned, at, i)
+}
+
+// userAt returns the position of the user with the given ID. Must be
+// called with mu held.
+func (s *Store) userAt(id int) (int, bool) {
+	i, ok := s.index.users[id]
+	return i, ok
+}
+
+// taskAt returns the position of the task with the given ID. Must be
+// called with mu held.
+func (s *Store) taskAt(id int) (int, bool) {
+	i, ok := s.index.tasks[id]
+	return i, ok
+}
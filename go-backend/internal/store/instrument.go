@@ -0,0 +1,370 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Instrumentation observes the operations of a Backend wrapped by
+// Instrument, e.g. to count them for metrics or to trace them. op is the
+// name of the Backend method, d how long it took and records how many
+// records it returned or wrote. Methods are called after each operation
+// returns, from the goroutine that made it, so they must be fast and
+// safe for concurrent use.
+type Instrumentation interface {
+	OnRead(ctx context.Context, op string, d time.Duration, records int)
+	OnWrite(ctx context.Context, op string, d time.Duration, records int)
+}
+
+// Instrumented is a Backend that reports each operation of the Backend
+// it wraps to an Instrumentation.
+type Instrumented struct {
+	backend Backend
+	in      Instrumentation
+}
+
+var _ Backend = (*Instrumented)(nil)
+
+// Instrument returns b reporting its operations to in. Close is not
+// reported.
+func Instrument(b Backend, in Instrumentation) *Instrumented {
+	return &Instrumented{backend: b, in: in}
+}
+
+// Unwrap returns the Backend s wraps.
+func (s *Instrumented) Unwrap() Backend {
+	return s.backend
+}
+
+// Unwrap returns the Backend b wraps, if it is Instrumented, or b. Check
+// for optional interfaces such as FileBacked on the Backend it returns.
+func Unwrap(b Backend) Backend {
+	for {
+		wrapper, ok := b.(interface{ Unwrap() Backend })
+		if !ok {
+			return b
+		}
+		b = wrapper.Unwrap()
+	}
+}
+
+func (s *Instrumented) read(ctx context.Context, op string, start time.Time, records int) {
+	s.in.OnRead(ctx, op, time.Since(start), records)
+}
+
+func (s *Instrumented) write(ctx context.Context, op string, start time.Time, records int) {
+	s.in.OnWrite(ctx, op, time.Since(start), records)
+}
+
+// count returns 1 if a record was found or changed, or 0.
+func count(ok bool) int {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+func (s *Instrumented) GetUsers(ctx context.Context) ([]model.User, error) {
+	start := time.Now()
+	users, err := s.backend.GetUsers(ctx)
+	s.read(ctx, "GetUsers", start, len(users))
+	return users, err
+}
+
+func (s *Instrumented) GetUserByID(ctx context.Context, id int) (*model.User, error) {
+	start := time.Now()
+	user, err := s.backend.GetUserByID(ctx, id)
+	s.read(ctx, "GetUserByID", start, count(user != nil))
+	return user, err
+}
+
+func (s *Instrumented) UserExistsByEmail(ctx context.Context, email string) (bool, error) {
+	start := time.Now()
+	exists, err := s.backend.UserExistsByEmail(ctx, email)
+	s.read(ctx, "UserExistsByEmail", start, count(exists))
+	return exists, err
+}
+
+func (s *Instrumented) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	start := time.Now()
+	user, err := s.backend.CreateUser(ctx, user)
+	s.write(ctx, "CreateUser", start, count(err == nil))
+	return user, err
+}
+
+func (s *Instrumented) SetPasswordHash(ctx context.Context, id int, passwordHash string) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.SetPasswordHash(ctx, id, passwordHash)
+	s.write(ctx, "SetPasswordHash", start, count(ok))
+	return ok, err
+}
+
+func (s *Instrumented) SetPreferences(ctx context.Context, id int, prefs model.UserPreferences) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.SetPreferences(ctx, id, prefs)
+	s.write(ctx, "SetPreferences", start, count(ok))
+	return ok, err
+}
+
+func (s *Instrumented) GetTasks(ctx context.Context, status, userID string) ([]model.Task, error) {
+	start := time.Now()
+	tasks, err := s.backend.GetTasks(ctx, status, userID)
+	s.read(ctx, "GetTasks", start, len(tasks))
+	return tasks, err
+}
+
+func (s *Instrumented) GetTaskByID(ctx context.Context, id int) (*model.Task, error) {
+	start := time.Now()
+	task, err := s.backend.GetTaskByID(ctx, id)
+	s.read(ctx, "GetTaskByID", start, count(task != nil))
+	return task, err
+}
+
+func (s *Instrumented) CreateTask(ctx context.Context, task model.Task) (model.Task, error) {
+	start := time.Now()
+	task, err := s.backend.CreateTask(ctx, task)
+	s.write(ctx, "CreateTask", start, count(err == nil))
+	return task, err
+}
+
+func (s *Instrumented) UpdateTask(ctx context.Context, id int, update model.TaskUpdate) (*model.Task, error) {
+	start := time.Now()
+	task, err := s.backend.UpdateTask(ctx, id, update)
+	s.write(ctx, "UpdateTask", start, count(task != nil))
+	return task, err
+}
+
+func (s *Instrumented) AddBlocker(ctx context.Context, id, blockerID int) (*model.Task, error) {
+	start := time.Now()
+	task, err := s.backend.AddBlocker(ctx, id, blockerID)
+	s.write(ctx, "AddBlocker", start, count(task != nil))
+	return task, err
+}
+
+func (s *Instrumented) RemoveBlocker(ctx context.Context, id, blockerID int) (*model.Task, error) {
+	start := time.Now()
+	task, err := s.backend.RemoveBlocker(ctx, id, blockerID)
+	s.write(ctx, "RemoveBlocker", start, count(task != nil))
+	return task, err
+}
+
+func (s *Instrumented) Revision(ctx context.Context) int64 {
+	start := time.Now()
+	revision := s.backend.Revision(ctx)
+	s.read(ctx, "Revision", start, 0)
+	return revision
+}
+
+func (s *Instrumented) TaskChanges(ctx context.Context, since int64) (model.TaskChanges, error) {
+	start := time.Now()
+	changes, err := s.backend.TaskChanges(ctx, since)
+	s.read(ctx, "TaskChanges", start, len(changes.Created)+len(changes.Updated)+len(changes.Deleted))
+	return changes, err
+}
+
+func (s *Instrumented) CreateShare(ctx context.Context, share model.Share) (model.Share, error) {
+	start := time.Now()
+	share, err := s.backend.CreateShare(ctx, share)
+	s.write(ctx, "CreateShare", start, count(err == nil))
+	return share, err
+}
+
+func (s *Instrumented) GetShares(ctx context.Context, taskID int) []model.Share {
+	start := time.Now()
+	shares := s.backend.GetShares(ctx, taskID)
+	s.read(ctx, "GetShares", start, len(shares))
+	return shares
+}
+
+func (s *Instrumented) GetShareByTokenHash(ctx context.Context, tokenHash string) *model.Share {
+	start := time.Now()
+	share := s.backend.GetShareByTokenHash(ctx, tokenHash)
+	s.read(ctx, "GetShareByTokenHash", start, count(share != nil))
+	return share
+}
+
+func (s *Instrumented) DeleteShare(ctx context.Context, taskID int, id string) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.DeleteShare(ctx, taskID, id)
+	s.write(ctx, "DeleteShare", start, count(ok))
+	return ok, err
+}
+
+func (s *Instrumented) GetAnnouncements(ctx context.Context) []model.Announcement {
+	start := time.Now()
+	announcements := s.backend.GetAnnouncements(ctx)
+	s.read(ctx, "GetAnnouncements", start, len(announcements))
+	return announcements
+}
+
+func (s *Instrumented) GetAnnouncementByID(ctx context.Context, id int) *model.Announcement {
+	start := time.Now()
+	a := s.backend.GetAnnouncementByID(ctx, id)
+	s.read(ctx, "GetAnnouncementByID", start, count(a != nil))
+	return a
+}
+
+func (s *Instrumented) CreateAnnouncement(ctx context.Context, a model.Announcement) (model.Announcement, error) {
+	start := time.Now()
+	a, err := s.backend.CreateAnnouncement(ctx, a)
+	s.write(ctx, "CreateAnnouncement", start, count(err == nil))
+	return a, err
+}
+
+func (s *Instrumented) UpdateAnnouncement(ctx context.Context, id int, update model.AnnouncementUpdate) (*model.Announcement, error) {
+	start := time.Now()
+	a, err := s.backend.UpdateAnnouncement(ctx, id, update)
+	s.write(ctx, "UpdateAnnouncement", start, count(a != nil))
+	return a, err
+}
+
+func (s *Instrumented) DeleteAnnouncement(ctx context.Context, id int) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.DeleteAnnouncement(ctx, id)
+	s.write(ctx, "DeleteAnnouncement", start, count(ok))
+	return ok, err
+}
+
+func (s *Instrumented) GetProjects(ctx context.Context) []model.Project {
+	start := time.Now()
+	projects := s.backend.GetProjects(ctx)
+	s.read(ctx, "GetProjects", start, len(projects))
+	return projects
+}
+
+func (s *Instrumented) GetProjectByID(ctx context.Context, id int) *model.Project {
+	start := time.Now()
+	project := s.backend.GetProjectByID(ctx, id)
+	s.read(ctx, "GetProjectByID", start, count(project != nil))
+	return project
+}
+
+func (s *Instrumented) CreateProject(ctx context.Context, project model.Project) (model.Project, error) {
+	start := time.Now()
+	project, err := s.backend.CreateProject(ctx, project)
+	s.write(ctx, "CreateProject", start, count(err == nil))
+	return project, err
+}
+
+func (s *Instrumented) UpdateProject(ctx context.Context, id int, update model.ProjectUpdate) (*model.Project, error) {
+	start := time.Now()
+	project, err := s.backend.UpdateProject(ctx, id, update)
+	s.write(ctx, "UpdateProject", start, count(project != nil))
+	return project, err
+}
+
+func (s *Instrumented) DeleteProject(ctx context.Context, id int) error {
+	start := time.Now()
+	err := s.backend.DeleteProject(ctx, id)
+	s.write(ctx, "DeleteProject", start, count(err == nil))
+	return err
+}
+
+func (s *Instrumented) RecordAudit(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error) {
+	start := time.Now()
+	event, err := s.backend.RecordAudit(ctx, event)
+	s.write(ctx, "RecordAudit", start, count(err == nil))
+	return event, err
+}
+
+func (s *Instrumented) GetAuditEvents(ctx context.Context, filter model.AuditFilter) []model.AuditEvent {
+	start := time.Now()
+	events := s.backend.GetAuditEvents(ctx, filter)
+	s.read(ctx, "GetAuditEvents", start, len(events))
+	return events
+}
+
+func (s *Instrumented) GetWebhooks(ctx context.Context) []model.Webhook {
+	start := time.Now()
+	webhooks := s.backend.GetWebhooks(ctx)
+	s.read(ctx, "GetWebhooks", start, len(webhooks))
+	return webhooks
+}
+
+func (s *Instrumented) GetWebhookByID(ctx context.Context, id int) *model.Webhook {
+	start := time.Now()
+	webhook := s.backend.GetWebhookByID(ctx, id)
+	s.read(ctx, "GetWebhookByID", start, count(webhook != nil))
+	return webhook
+}
+
+func (s *Instrumented) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	start := time.Now()
+	webhook, err := s.backend.CreateWebhook(ctx, webhook)
+	s.write(ctx, "CreateWebhook", start, count(err == nil))
+	return webhook, err
+}
+
+func (s *Instrumented) DeleteWebhook(ctx context.Context, id int) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.DeleteWebhook(ctx, id)
+	s.write(ctx, "DeleteWebhook", start, count(ok))
+	return ok, err
+}
+
+func (s *Instrumented) GetStats(ctx context.Context) model.StatsResponse {
+	start := time.Now()
+	stats := s.backend.GetStats(ctx)
+	s.read(ctx, "GetStats", start, 0)
+	return stats
+}
+
+func (s *Instrumented) EachUserStats(ctx context.Context, fn func(model.UserStats) bool) {
+	start := time.Now()
+	records := 0
+	s.backend.EachUserStats(ctx, func(stats model.UserStats) bool {
+		records++
+		return fn(stats)
+	})
+	s.read(ctx, "EachUserStats", start, records)
+}
+
+func (s *Instrumented) RecordStatsSnapshot(ctx context.Context, snap model.StatsSnapshot) (model.StatsSnapshot, error) {
+	start := time.Now()
+	snap, err := s.backend.RecordStatsSnapshot(ctx, snap)
+	s.write(ctx, "RecordStatsSnapshot", start, count(err == nil))
+	return snap, err
+}
+
+func (s *Instrumented) GetStatsSnapshots(ctx context.Context, from, to time.Time) []model.StatsSnapshot {
+	start := time.Now()
+	snaps := s.backend.GetStatsSnapshots(ctx, from, to)
+	s.read(ctx, "GetStatsSnapshots", start, len(snaps))
+	return snaps
+}
+
+func (s *Instrumented) Snapshot(ctx context.Context) (Snapshot, error) {
+	start := time.Now()
+	snap, err := s.backend.Snapshot(ctx)
+	s.read(ctx, "Snapshot", start, snap.records())
+	return snap, err
+}
+
+func (s *Instrumented) Restore(ctx context.Context, snap Snapshot) error {
+	start := time.Now()
+	err := s.backend.Restore(ctx, snap)
+	records := 0
+	if err == nil {
+		records = snap.records()
+	}
+	s.write(ctx, "Restore", start, records)
+	return err
+}
+
+func (s *Instrumented) Persist(ctx context.Context) error {
+	start := time.Now()
+	err := s.backend.Persist(ctx)
+	s.write(ctx, "Persist", start, 0)
+	return err
+}
+
+func (s *Instrumented) Close() {
+	s.backend.Close()
+}
+
+// records returns the number of records in snap.
+func (snap Snapshot) records() int {
+	return len(snap.users) + len(snap.tasks) + len(snap.shares) + len(snap.announcements) + len(snap.projects)
+}
@@ -0,0 +1,452 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// JournalSuffix is appended to the data file path to name its journal.
+const JournalSuffix = ".journal"
+
+// ErrCorruptJournal is returned when a journal entry other than the last
+// cannot be read, so the entries after it cannot be replayed either.
+var ErrCorruptJournal = errors.New("corrupt journal")
+
+// DefaultCompactAfter is how many journal entries accumulate before the
+// journal is compacted into the JSON data file.
+const DefaultCompactAfter = 1000
+
+// Journal operations.
+const (
+	opPutUser            = "putUser"
+	opPutTask            = "putTask"
+	opPutShare           = "putShare"
+	opDeleteShare        = "deleteShare"
+	opPutAnnouncement    = "putAnnouncement"
+	opDeleteAnnouncement = "deleteAnnouncement"
+	opPutProject         = "putProject"
+	opDeleteProject      = "deleteProject"
+	opPutAuditEvent      = "putAuditEvent"
+	opPutWebhook         = "putWebhook"
+	opDeleteWebhook      = "deleteWebhook"
+	opPutStatsSnapshot   = "putStatsSnapshot"
+)
+
+// journalEntry is one line of the append-only journal.
+// Entries carry the full record, or the ID of the record deleted, so
+// replaying an entry twice is harmless.
+type journalEntry struct {
+	Op             string               `json:"op"`
+	User           *storedUser          `json:"user,omitempty"`
+	Task           *model.Task          `json:"task,omitempty"`
+	Share          *storedShare         `json:"share,omitempty"`
+	ShareID        string               `json:"shareId,omitempty"`
+	Announcement   *model.Announcement  `json:"announcement,omitempty"`
+	AnnouncementID int                  `json:"announcementId,omitempty"`
+	Project        *model.Project       `json:"project,omitempty"`
+	ProjectID      int                  `json:"projectId,omitempty"`
+	AuditEvent     *model.AuditEvent    `json:"auditEvent,omitempty"`
+	Webhook        *storedWebhook       `json:"webhook,omitempty"`
+	WebhookID      int                  `json:"webhookId,omitempty"`
+	StatsSnapshot  *model.StatsSnapshot `json:"statsSnapshot,omitempty"`
+}
+
+// record bumps the store revision and queues entry for the journal file.
+// Must be called with mu held.
+func (s *Store) record(entry journalEntry) {
+	s.revision++
+	if s.ephemeral {
+		return
+	}
+	s.journal = append(s.journal, entry)
+	s.persistAsync()
+}
+
+// journalUser records a created or updated user. Must be called with mu held.
+func (s *Store) journalUser(user model.User) {
+	s.record(journalEntry{
+		Op:   opPutUser,
+		User: &storedUser{User: user, PasswordHash: user.PasswordHash},
+	})
+}
+
+// journalTask records an updated task. Must be called with mu held.
+func (s *Store) journalTask(task model.Task) {
+	s.record(journalEntry{Op: opPutTask, Task: &task})
+	s.taskChanged(task.ID, false)
+}
+
+// journalNewTask records a created task. Must be called with mu held.
+func (s *Store) journalNewTask(task model.Task) {
+	s.record(journalEntry{Op: opPutTask, Task: &task})
+	s.taskChanged(task.ID, true)
+}
+
+// journalShare records a created share. Must be called with mu held.
+func (s *Store) journalShare(share model.Share) {
+	s.record(journalEntry{
+		Op:    opPutShare,
+		Share: &storedShare{Share: share, TokenHash: share.TokenHash},
+	})
+}
+
+// journalDeleteShare records a deleted share. Must be called with mu held.
+func (s *Store) journalDeleteShare(id string) {
+	s.record(journalEntry{Op: opDeleteShare, ShareID: id})
+}
+
+// journalAnnouncement records a created or updated announcement. Must be
+// called with mu held.
+func (s *Store) journalAnnouncement(a model.Announcement) {
+	s.record(journalEntry{Op: opPutAnnouncement, Announcement: &a})
+}
+
+// journalDeleteAnnouncement records a deleted announcement. Must be called
+// with mu held.
+func (s *Store) journalDeleteAnnouncement(id int) {
+	s.record(journalEntry{Op: opDeleteAnnouncement, AnnouncementID: id})
+}
+
+// journalProject records a created or updated project. Must be called
+// with mu held.
+func (s *Store) journalProject(project model.Project) {
+	s.record(journalEntry{Op: opPutProject, Project: &project})
+}
+
+// journalDeleteProject records a deleted project. Must be called with mu
+// held.
+func (s *Store) journalDeleteProject(id int) {
+	s.record(journalEntry{Op: opDeleteProject, ProjectID: id})
+}
+
+// journalAuditEvent records an audit event. Must be called with mu held.
+func (s *Store) journalAuditEvent(event model.AuditEvent) {
+	s.record(journalEntry{Op: opPutAuditEvent, AuditEvent: &event})
+}
+
+// journalWebhook records a created webhook. Must be called with mu held.
+func (s *Store) journalWebhook(webhook model.Webhook) {
+	s.record(journalEntry{
+		Op:      opPutWebhook,
+		Webhook: &storedWebhook{Webhook: webhook, Secret: webhook.Secret},
+	})
+}
+
+// journalDeleteWebhook records a deleted webhook. Must be called with mu
+// held.
+func (s *Store) journalDeleteWebhook(id int) {
+	s.record(journalEntry{Op: opDeleteWebhook, WebhookID: id})
+}
+
+// journalStatsSnapshot records a stats snapshot. Must be called with mu
+// held.
+func (s *Store) journalStatsSnapshot(snap model.StatsSnapshot) {
+	s.record(journalEntry{Op: opPutStatsSnapshot, StatsSnapshot: &snap})
+}
+
+// journalReplaceAll records that all data was replaced, which is saved by
+// compacting rather than journaling every record. Must be called with mu held.
+func (s *Store) journalReplaceAll() {
+	s.revision++
+	if s.ephemeral {
+		return
+	}
+	s.journal = nil
+	s.compactPending = true
+	s.persistAsync()
+}
+
+// flush appends pending journal entries to the journal file, or compacts
+// the journal into the data file once it has grown past compactAfter.
+// Entries that fail to be written are queued again, ahead of any recorded
+// since, for the next flush to retry.
+func (s *Store) flush() error {
+	if s.ephemeral {
+		return nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	entries := s.journal
+	s.journal = nil
+	compact := s.compactPending || s.journalLen+len(entries) > s.compactAfter
+	var data *PersistentData
+	if compact {
+		s.compactPending = false
+		data = s.copyData()
+	}
+	path := s.dataPath
+	s.mu.Unlock()
+
+	if compact {
+		return s.compact(path, data)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	err := appendJournal(path+JournalSuffix, entries)
+	s.setPersistErr(err)
+	if err != nil {
+		s.mu.Lock()
+		s.journal = append(entries, s.journal...)
+		s.mu.Unlock()
+		return err
+	}
+	s.journalLen += len(entries)
+	s.writes.Add(1)
+	return nil
+}
+
+// compact writes data as the new JSON data file and, once that is synced to
+// disk, truncates the journal. A crash in between leaves a journal that
+// replays cleanly on top of the new data file. If either fails, the next
+// flush compacts again.
+// Must be called with writeMu held.
+func (s *Store) compact(path string, data *PersistentData) error {
+	err := SaveDataTo(path, data)
+	if err == nil {
+		if err = os.Remove(path + JournalSuffix); os.IsNotExist(err) {
+			err = nil
+		} else if err != nil {
+			err = fmt.Errorf("failed to truncate journal: %w", err)
+		}
+	}
+	s.setPersistErr(err)
+	if err != nil {
+		s.mu.Lock()
+		s.compactPending = true
+		s.mu.Unlock()
+		return err
+	}
+	s.journalLen = 0
+	s.writes.Add(1)
+	return nil
+}
+
+// setPersistErr records the result of a write for PersistError and
+// LastPersisted.
+func (s *Store) setPersistErr(err error) {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	s.persistErr = err
+	if err == nil {
+		s.persistedAt = time.Now()
+	}
+}
+
+// copyData returns a copy of the current users, tasks, shares,
+// announcements, projects, audit events and webhooks, and the store
+// revision. Must be called with mu held.
+func (s *Store) copyData() *PersistentData {
+	return &PersistentData{
+		Users:         append([]model.User{}, s.users...),
+		Tasks:         append([]model.Task{}, s.tasks...),
+		Shares:        append([]model.Share{}, s.shares...),
+		Announcements: append([]model.Announcement{}, s.announcements...),
+		Projects:      append([]model.Project{}, s.projects...),
+		Audit:         append([]model.AuditEvent{}, s.audit...),
+		Webhooks:      append([]model.Webhook{}, s.webhooks...),
+		StatsHistory:  append([]model.StatsSnapshot{}, s.statsHistory...),
+		Revision:      s.revision,
+	}
+}
+
+// appendJournal appends entries to the journal at path and syncs it to disk,
+// along with its directory if the journal is new.
+func appendJournal(path string, entries []journalEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to marshal journal entry: %w", err)
+		}
+	}
+
+	_, err := os.Stat(path)
+	created := os.IsNotExist(err)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close journal: %w", err)
+	}
+	if created {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to sync data directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// replayJournal applies the journal at path on top of data and returns the
+// number of entries applied. A torn final line, left by a crash mid-write,
+// is cut off the journal so that later entries are not appended to it;
+// everything before it is kept. Any other unreadable entry stops the replay
+// with ErrCorruptJournal.
+func replayJournal(path string, data *PersistentData) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	applied := 0
+	// replayed is the length of the journal up to the last entry applied
+	var replayed int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// Entries are written with their newline, so a line without
+				// one is a write that never completed
+				log.Printf("Warning: Dropping torn journal entry after %d entries", applied)
+				if err := os.Truncate(path, replayed); err != nil {
+					return applied, fmt.Errorf("failed to truncate journal: %w", err)
+				}
+			}
+			return applied, nil
+		}
+		if err != nil {
+			return applied, fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return applied, fmt.Errorf("%w: entry %d: %v", ErrCorruptJournal, applied+1, err)
+		}
+		entry.apply(data)
+		applied++
+		replayed += int64(len(line))
+	}
+}
+
+// apply upserts the entry's record into data, or deletes it.
+func (e journalEntry) apply(data *PersistentData) {
+	switch {
+	case e.Op == opPutUser && e.User != nil:
+		user := e.User.User
+		user.PasswordHash = e.User.PasswordHash
+		for i := range data.Users {
+			if data.Users[i].ID == user.ID {
+				data.Users[i] = user
+				return
+			}
+		}
+		data.Users = append(data.Users, user)
+	case e.Op == opPutTask && e.Task != nil:
+		for i := range data.Tasks {
+			if data.Tasks[i].ID == e.Task.ID {
+				data.Tasks[i] = *e.Task
+				return
+			}
+		}
+		data.Tasks = append(data.Tasks, *e.Task)
+	case e.Op == opPutShare && e.Share != nil:
+		share := e.Share.Share
+		share.TokenHash = e.Share.TokenHash
+		for i := range data.Shares {
+			if data.Shares[i].ID == share.ID {
+				data.Shares[i] = share
+				return
+			}
+		}
+		data.Shares = append(data.Shares, share)
+	case e.Op == opDeleteShare:
+		for i := range data.Shares {
+			if data.Shares[i].ID == e.ShareID {
+				data.Shares = append(data.Shares[:i], data.Shares[i+1:]...)
+				return
+			}
+		}
+	case e.Op == opPutAnnouncement && e.Announcement != nil:
+		for i := range data.Announcements {
+			if data.Announcements[i].ID == e.Announcement.ID {
+				data.Announcements[i] = *e.Announcement
+				return
+			}
+		}
+		data.Announcements = append(data.Announcements, *e.Announcement)
+	case e.Op == opDeleteAnnouncement:
+		for i := range data.Announcements {
+			if data.Announcements[i].ID == e.AnnouncementID {
+				data.Announcements = append(data.Announcements[:i], data.Announcements[i+1:]...)
+				return
+			}
+		}
+	case e.Op == opPutProject && e.Project != nil:
+		for i := range data.Projects {
+			if data.Projects[i].ID == e.Project.ID {
+				data.Projects[i] = *e.Project
+				return
+			}
+		}
+		data.Projects = append(data.Projects, *e.Project)
+	case e.Op == opDeleteProject:
+		for i := range data.Projects {
+			if data.Projects[i].ID == e.ProjectID {
+				data.Projects = append(data.Projects[:i], data.Projects[i+1:]...)
+				return
+			}
+		}
+	case e.Op == opPutAuditEvent && e.AuditEvent != nil:
+		// A crash between compaction and truncating the journal replays
+		// events already in the data file
+		for i := range data.Audit {
+			if data.Audit[i].ID == e.AuditEvent.ID {
+				return
+			}
+		}
+		data.Audit = append(data.Audit, *e.AuditEvent)
+	case e.Op == opPutWebhook && e.Webhook != nil:
+		webhook := e.Webhook.Webhook
+		webhook.Secret = e.Webhook.Secret
+		for i := range data.Webhooks {
+			if data.Webhooks[i].ID == webhook.ID {
+				data.Webhooks[i] = webhook
+				return
+			}
+		}
+		data.Webhooks = append(data.Webhooks, webhook)
+	case e.Op == opDeleteWebhook:
+		for i := range data.Webhooks {
+			if data.Webhooks[i].ID == e.WebhookID {
+				data.Webhooks = append(data.Webhooks[:i], data.Webhooks[i+1:]...)
+				return
+			}
+		}
+	case e.Op == opPutStatsSnapshot && e.StatsSnapshot != nil:
+		data.StatsHistory = putStatsSnapshot(data.StatsHistory, *e.StatsSnapshot)
+	}
+}
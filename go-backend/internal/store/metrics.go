@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// Kinds of store operations.
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+)
+
+// Metrics is an Instrumentation counting the calls of each operation,
+// the records they returned or wrote and the time they took.
+type Metrics struct {
+	mu  sync.RWMutex
+	ops map[string]*opMetrics
+}
+
+// opMetrics are the counters of one operation.
+type opMetrics struct {
+	kind    string
+	count   atomic.Int64
+	records atomic.Int64
+	nanos   atomic.Int64
+}
+
+var _ Instrumentation = (*Metrics)(nil)
+
+// NewMetrics creates a Metrics with no operations counted.
+func NewMetrics() *Metrics {
+	return &Metrics{ops: make(map[string]*opMetrics)}
+}
+
+// OnRead counts a read.
+func (m *Metrics) OnRead(ctx context.Context, op string, d time.Duration, records int) {
+	m.add(op, OpRead, d, records)
+}
+
+// OnWrite counts a write.
+func (m *Metrics) OnWrite(ctx context.Context, op string, d time.Duration, records int) {
+	m.add(op, OpWrite, d, records)
+}
+
+func (m *Metrics) add(op, kind string, d time.Duration, records int) {
+	m.mu.RLock()
+	counters, ok := m.ops[op]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		if counters, ok = m.ops[op]; !ok {
+			counters = &opMetrics{kind: kind}
+			m.ops[op] = counters
+		}
+		m.mu.Unlock()
+	}
+
+	counters.count.Add(1)
+	counters.records.Add(int64(records))
+	counters.nanos.Add(int64(d))
+}
+
+// Stats returns the counters of each operation called so far, by name.
+func (m *Metrics) Stats() model.StoreStatsResponse {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := model.StoreStatsResponse{Operations: make([]model.StoreOperationStats, 0, len(m.ops))}
+	for op, counters := range m.ops {
+		count := counters.count.Load()
+		total := time.Duration(counters.nanos.Load())
+		stat := model.StoreOperationStats{
+			Op:      op,
+			Kind:    counters.kind,
+			Count:   count,
+			Records: counters.records.Load(),
+			TotalMs: float64(total) / float64(time.Millisecond),
+		}
+		if count > 0 {
+			stat.AverageMs = stat.TotalMs / float64(count)
+		}
+		stats.Operations = append(stats.Operations, stat)
+
+		if stat.Kind == OpRead {
+			stats.Reads += count
+		} else {
+			stats.Writes += count
+		}
+	}
+	sort.Slice(stats.Operations, func(i, j int) bool {
+		return stats.Operations[i].Op < stats.Operations[j].Op
+	})
+	return stats
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// migration transforms the data file between two schema versions: up from
+// version-1 to version, down back again. Migrations work on the raw JSON
+// document, so they can read fields the model no longer has.
+type migration struct {
+	version int
+	name    string
+	up      func(doc document) error
+	down    func(doc document) error
+}
+
+// LatestSchemaVersion returns the data file schema version this build
+// writes, that of its newest migration.
+func LatestSchemaVersion() int {
+	return dataMigrations[len(dataMigrations)-1].version
+}
+
+// document is the data file decoded as plain JSON values.
+type document map[string]interface{}
+
+// schemaVersion returns the document's schema version. Files written
+// before versioning have none and are version 0.
+func (doc document) schemaVersion() int {
+	version, _ := doc["schemaVersion"].(float64)
+	return int(version)
+}
+
+// records returns the objects in the array under key, e.g. "tasks".
+// Changes to the returned objects change the document.
+func (doc document) records(key string) []map[string]interface{} {
+	items, _ := doc[key].([]interface{})
+	records := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if record, ok := item.(map[string]interface{}); ok {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// upsert replaces the object under key with the same "id" as record, or
+// appends record if there is none.
+func (doc document) upsert(key string, record map[string]interface{}) {
+	items, _ := doc[key].([]interface{})
+	for i, item := range items {
+		if existing, ok := item.(map[string]interface{}); ok && existing["id"] == record["id"] {
+			items[i] = record
+			return
+		}
+	}
+	doc[key] = append(items, record)
+}
+
+// migrate applies migrations to doc, up or down, from its current schema
+// version to version to.
+func (doc document) migrate(to int) error {
+	from := doc.schemaVersion()
+	if from > LatestSchemaVersion() {
+		return fmt.Errorf("schema version %d is newer than this server supports (%d)", from, LatestSchemaVersion())
+	}
+	if to < 0 || to > LatestSchemaVersion() {
+		return fmt.Errorf("unknown schema version %d; latest is %d", to, LatestSchemaVersion())
+	}
+
+	if to > from {
+		for _, m := range dataMigrations {
+			if m.version <= from || m.version > to {
+				continue
+			}
+			if err := m.up(doc); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+			}
+			doc["schemaVersion"] = m.version
+		}
+		return nil
+	}
+
+	for i := len(dataMigrations) - 1; i >= 0; i-- {
+		m := dataMigrations[i]
+		if m.version > from || m.version <= to {
+			continue
+		}
+		if err := m.down(doc); err != nil {
+			return fmt.Errorf("reverting migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		doc["schemaVersion"] = m.version - 1
+	}
+	return nil
+}
+
+// decodeFileData decodes a data file or backup, migrating it to the
+// latest schema version first if it is older.
+func decodeFileData(data []byte) (fileData, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fileData{}, err
+	}
+	if doc == nil {
+		doc = document{}
+	}
+
+	if doc.schemaVersion() != LatestSchemaVersion() {
+		if err := doc.migrate(LatestSchemaVersion()); err != nil {
+			return fileData{}, err
+		}
+		migrated, err := json.Marshal(doc)
+		if err != nil {
+			return fileData{}, err
+		}
+		data = migrated
+	}
+
+	var stored fileData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fileData{}, err
+	}
+	return stored, nil
+}
+
+// MigrateDataFile migrates the data file at path to schema version to,
+// up or down, and rewrites it. Its journal was written at the file's old
+// version, so it is applied first and then removed. Returns the version
+// the file was at; a missing file is left alone.
+func MigrateDataFile(path string, to int) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return to, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil || doc == nil {
+		return 0, fmt.Errorf("failed to parse data file: %v", err)
+	}
+	from := doc.schemaVersion()
+	if from == to {
+		return from, nil
+	}
+
+	if err := doc.replayJournal(path + JournalSuffix); err != nil {
+		return from, err
+	}
+	if err := doc.migrate(to); err != nil {
+		return from, err
+	}
+
+	migrated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return from, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	if err := writeFileAtomic(path, migrated); err != nil {
+		return from, err
+	}
+	if err := os.Remove(path + JournalSuffix); err != nil && !os.IsNotExist(err) {
+		return from, fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	return from, nil
+}
+
+// replayJournal applies the journal at path to doc, like replayJournal
+// does for loaded data, keeping fields the model may not know.
+func (doc document) replayJournal(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	applied := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Op   string                 `json:"op"`
+			User map[string]interface{} `json:"user"`
+			Task map[string]interface{} `json:"task"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Warning: Ignoring journal after %d entries: %v", applied, err)
+			break
+		}
+		switch {
+		case entry.Op == opPutUser && entry.User != nil:
+			doc.upsert("users", entry.User)
+		case entry.Op == opPutTask && entry.Task != nil:
+			doc.upsert("tasks", entry.Task)
+		}
+		applied++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"go-backend/internal/validator"
+)
+
+// dataMigrations transform the data file, oldest first. Versions must
+// increase by one. Append a migration whenever a model change would
+// otherwise leave existing records with zero values; never edit one that
+// has shipped.
+var dataMigrations = []migration{
+	{version: 1, name: "task_created_at", up: addTaskCreatedAt, down: dropTaskCreatedAt},
+	{version: 2, name: "due_dates", up: addDueDates, down: dropDueDates},
+	{version: 3, name: "digest", up: addDigestFields, down: dropDigestFields},
+	{version: 4, name: "normalize_emails", up: normalizeEmails, down: keepEmails},
+	{version: 5, name: "task_priority", up: addTaskPriority, down: dropTaskPriority},
+	{version: 6, name: "task_tags", up: addTaskTags, down: dropTaskTags},
+	{version: 7, name: "task_description", up: addTaskDescription, down: dropTaskDescription},
+	{version: 8, name: "task_unfurls", up: addTaskUnfurls, down: dropTaskUnfurls},
+	{version: 9, name: "task_shares", up: addTaskShares, down: dropTaskShares},
+	{version: 10, name: "task_dependencies", up: addTaskDependencies, down: dropTaskDependencies},
+	{version: 11, name: "announcements", up: addAnnouncements, down: dropAnnouncements},
+	{version: 12, name: "projects", up: addProjects, down: dropProjects},
+	{version: 13, name: "audit_events", up: addAuditEvents, down: dropAuditEvents},
+	{version: 14, name: "webhooks", up: addWebhooks, down: dropWebhooks},
+}
+
+// addTaskCreatedAt gives existing tasks a creation time. The real one is
+// unknown, so tasks are dated to the upgrade.
+func addTaskCreatedAt(doc document) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, task := range doc.records("tasks") {
+		if _, ok := task["createdAt"]; !ok {
+			task["createdAt"] = now
+		}
+	}
+	return nil
+}
+
+// dropTaskCreatedAt removes task creation times.
+func dropTaskCreatedAt(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "createdAt")
+	}
+	return nil
+}
+
+// addDueDates does nothing: due dates and time zones are optional. The
+// version stops older releases from loading, and silently dropping, them.
+func addDueDates(doc document) error {
+	return nil
+}
+
+// dropDueDates removes task due dates and user time zones.
+func dropDueDates(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "dueAt")
+	}
+	for _, user := range doc.records("users") {
+		delete(user, "timezone")
+	}
+	return nil
+}
+
+// addDigestFields dates existing assignments to task creation, which is
+// as far back as any can be known. Completion times stay unknown.
+func addDigestFields(doc document) error {
+	for _, task := range doc.records("tasks") {
+		if _, ok := task["assignedAt"]; !ok {
+			task["assignedAt"] = task["createdAt"]
+		}
+	}
+	return nil
+}
+
+// dropDigestFields removes assignment and completion times and user
+// preferences.
+func dropDigestFields(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "assignedAt")
+		delete(task, "completedAt")
+	}
+	for _, user := range doc.records("users") {
+		delete(user, "preferences")
+	}
+	return nil
+}
+
+// normalizeEmails trims and lowercases user emails. Addresses that only
+// differ in case would become duplicates, so the migration fails, naming
+// them, until all but one are changed by hand.
+func normalizeEmails(doc document) error {
+	owners := make(map[string]interface{})
+	for _, user := range doc.records("users") {
+		email, _ := user["email"].(string)
+		email = validator.NormalizeEmail(email)
+		if owner, dup := owners[email]; dup {
+			return fmt.Errorf("users %v and %v both have email %q", owner, user["id"], email)
+		}
+		owners[email] = user["id"]
+		user["email"] = email
+	}
+	return nil
+}
+
+// keepEmails does nothing: the original case is gone, and normalized
+// emails are valid in older versions too.
+func keepEmails(doc document) error {
+	return nil
+}
+
+// addTaskPriority gives existing tasks the default priority.
+func addTaskPriority(doc document) error {
+	for _, task := range doc.records("tasks") {
+		if _, ok := task["priority"]; !ok {
+			task["priority"] = validator.DefaultPriority
+		}
+	}
+	return nil
+}
+
+// dropTaskPriority removes task priorities.
+func dropTaskPriority(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "priority")
+	}
+	return nil
+}
+
+// addTaskTags does nothing: tags are optional. The version stops older
+// releases from loading, and silently dropping, them.
+func addTaskTags(doc document) error {
+	return nil
+}
+
+// dropTaskTags removes task tags.
+func dropTaskTags(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "tags")
+	}
+	return nil
+}
+
+// addTaskDescription does nothing: descriptions are optional. The version
+// stops older releases from loading, and silently dropping, them.
+func addTaskDescription(doc document) error {
+	return nil
+}
+
+// dropTaskDescription removes task descriptions.
+func dropTaskDescription(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "description")
+	}
+	return nil
+}
+
+// addTaskUnfurls does nothing: link previews are optional. The version
+// stops older releases from loading, and silently dropping, them.
+func addTaskUnfurls(doc document) error {
+	return nil
+}
+
+// dropTaskUnfurls removes link previews.
+func dropTaskUnfurls(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "unfurls")
+	}
+	return nil
+}
+
+// addTaskShares does nothing: there are no shares yet. The version stops
+// older releases from loading, and silently dropping, them.
+func addTaskShares(doc document) error {
+	return nil
+}
+
+// dropTaskShares removes task shares, revoking their links.
+func dropTaskShares(doc document) error {
+	delete(doc, "shares")
+	return nil
+}
+
+// addTaskDependencies does nothing: tasks have no blockers yet. The
+// version stops older releases from loading, and silently dropping, them.
+func addTaskDependencies(doc document) error {
+	return nil
+}
+
+// dropTaskDependencies removes task blockers.
+func dropTaskDependencies(doc document) error {
+	for _, task := range doc.records("tasks") {
+		delete(task, "blockedBy")
+	}
+	return nil
+}
+
+// addAnnouncements does nothing: there are no announcements yet. The
+// version stops older releases from loading, and silently dropping, them.
+func addAnnouncements(doc document) error {
+	return nil
+}
+
+// dropAnnouncements removes announcements.
+func dropAnnouncements(doc document) error {
+	delete(doc, "announcements")
+	return nil
+}
+
+// addProjects does nothing: there are no projects yet and tasks belong to
+// none. The version stops older releases from loading, and silently
+// dropping, them.
+func addProjects(doc document) error {
+	return nil
+}
+
+// dropProjects removes projects and takes tasks out of them.
+func dropProjects(doc document) error {
+	delete(doc, "projects")
+	for _, task := range doc.records("tasks") {
+		delete(task, "projectId")
+	}
+	return nil
+}
+
+// addAuditEvents does nothing: changes made before the upgrade were not
+// recorded.
+func addAuditEvents(doc document) error {
+	return nil
+}
+
+// dropAuditEvents removes the audit trail.
+func dropAuditEvents(doc document) error {
+	delete(doc, "audit")
+	return nil
+}
+
+// addWebhooks does nothing: there are no webhooks yet. The version stops
+// older releases from loading, and silently dropping, them.
+func addWebhooks(doc document) error {
+	return nil
+}
+
+// dropWebhooks removes webhooks.
+func dropWebhooks(doc document) error {
+	delete(doc, "webhooks")
+	return nil
+}
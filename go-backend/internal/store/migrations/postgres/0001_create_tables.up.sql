@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users (
+	id            INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name          TEXT NOT NULL,
+	email         TEXT NOT NULL UNIQUE,
+	role          TEXT NOT NULL,
+	password_hash TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+	id      INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	title   TEXT NOT NULL,
+	status  TEXT NOT NULL,
+	user_id INTEGER NOT NULL REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS tasks_user_id_idx ON tasks (user_id);
@@ -0,0 +1,2 @@
+-- Existing tasks are dated to the upgrade; their real creation time is unknown.
+ALTER TABLE tasks ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,2 @@
+ALTER TABLE tasks ADD COLUMN due_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN digest_opt_out;
+ALTER TABLE tasks DROP COLUMN completed_at;
+ALTER TABLE tasks DROP COLUMN assigned_at;
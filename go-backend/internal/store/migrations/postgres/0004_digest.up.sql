@@ -0,0 +1,5 @@
+-- Existing assignments are dated to task creation; completion times are unknown.
+ALTER TABLE tasks ADD COLUMN assigned_at TIMESTAMPTZ NOT NULL DEFAULT now();
+UPDATE tasks SET assigned_at = created_at;
+ALTER TABLE tasks ADD COLUMN completed_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN digest_opt_out BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+-- Fails on the unique constraint if two emails only differ in case; change
+-- all but one of them by hand first.
+UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+ALTER TABLE users ADD CONSTRAINT users_email_normalized CHECK (email = lower(btrim(email)));
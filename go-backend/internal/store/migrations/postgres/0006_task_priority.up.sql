@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT 'medium';
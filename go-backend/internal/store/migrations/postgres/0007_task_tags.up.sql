@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
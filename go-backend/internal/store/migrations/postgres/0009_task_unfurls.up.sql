@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN unfurls JSONB NOT NULL DEFAULT '[]';
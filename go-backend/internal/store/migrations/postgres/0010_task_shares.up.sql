@@ -0,0 +1,9 @@
+CREATE TABLE shares (
+	id         TEXT PRIMARY KEY,
+	task_id    INTEGER NOT NULL REFERENCES tasks (id),
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX shares_task_id_idx ON shares (task_id);
@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN blocked_by INTEGER[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,9 @@
+CREATE TABLE announcements (
+	id         INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	message    TEXT NOT NULL,
+	level      TEXT NOT NULL,
+	starts_at  TIMESTAMPTZ,
+	ends_at    TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP COLUMN project_id;
+
+DROP TABLE projects;
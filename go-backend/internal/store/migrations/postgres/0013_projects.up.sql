@@ -0,0 +1,10 @@
+CREATE TABLE projects (
+	id          INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE tasks ADD COLUMN project_id INTEGER REFERENCES projects (id);
+
+CREATE INDEX tasks_project_id_idx ON tasks (project_id);
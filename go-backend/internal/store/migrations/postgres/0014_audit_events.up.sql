@@ -0,0 +1,11 @@
+CREATE TABLE audit_events (
+	id        INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	actor     TEXT NOT NULL,
+	action    TEXT NOT NULL,
+	entity    TEXT NOT NULL,
+	entity_id TEXT NOT NULL,
+	changes   JSONB NOT NULL DEFAULT '[]',
+	at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_entity_idx ON audit_events (entity, entity_id);
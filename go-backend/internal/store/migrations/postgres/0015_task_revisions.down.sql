@@ -0,0 +1,10 @@
+DROP TRIGGER tasks_deleted ON tasks;
+DROP FUNCTION record_deleted_task();
+DROP TRIGGER tasks_revision ON tasks;
+DROP FUNCTION set_task_revision();
+
+DROP TABLE deleted_tasks;
+
+ALTER TABLE tasks DROP COLUMN revision, DROP COLUMN created_revision;
+
+DROP SEQUENCE store_revision;
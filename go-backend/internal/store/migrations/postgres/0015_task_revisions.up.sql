@@ -0,0 +1,37 @@
+CREATE SEQUENCE store_revision;
+
+ALTER TABLE tasks
+	ADD COLUMN revision         BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN created_revision BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX tasks_revision_idx ON tasks (revision);
+
+CREATE TABLE deleted_tasks (
+	id       INTEGER NOT NULL,
+	revision BIGINT NOT NULL
+);
+
+CREATE INDEX deleted_tasks_revision_idx ON deleted_tasks (revision);
+
+CREATE FUNCTION set_task_revision() RETURNS trigger AS $$
+BEGIN
+	NEW.revision := nextval('store_revision');
+	IF TG_OP = 'INSERT' THEN
+		NEW.created_revision := NEW.revision;
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_revision BEFORE INSERT OR UPDATE ON tasks
+	FOR EACH ROW EXECUTE FUNCTION set_task_revision();
+
+CREATE FUNCTION record_deleted_task() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO deleted_tasks (id, revision) VALUES (OLD.id, nextval('store_revision'));
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_deleted AFTER DELETE ON tasks
+	FOR EACH ROW EXECUTE FUNCTION record_deleted_task();
@@ -0,0 +1,7 @@
+CREATE TABLE webhooks (
+	id         INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	url        TEXT NOT NULL,
+	events     TEXT[] NOT NULL DEFAULT '{}',
+	secret     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
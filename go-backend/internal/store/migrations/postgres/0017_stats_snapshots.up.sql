@@ -0,0 +1,12 @@
+CREATE TABLE stats_snapshots (
+	at          TIMESTAMPTZ PRIMARY KEY,
+	users       INTEGER NOT NULL,
+	total       INTEGER NOT NULL,
+	pending     INTEGER NOT NULL,
+	in_progress INTEGER NOT NULL,
+	completed   INTEGER NOT NULL,
+	low         INTEGER NOT NULL,
+	medium      INTEGER NOT NULL,
+	high        INTEGER NOT NULL,
+	urgent      INTEGER NOT NULL
+);
@@ -1,90 +1,303 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"go-backend/internal/model"
 )
 
-const dataFilePath = "data/data.json"
+// DefaultDataPath is the file data is persisted to unless configured otherwise.
+const DefaultDataPath = "data/data.json"
+
+// DefaultPersistDelay is how long background writes are deferred to
+// coalesce bursts of changes.
+const DefaultPersistDelay = 100 * time.Millisecond
+
+// Failed background writes are retried after persistDelay, but no sooner
+// than minPersistRetry, doubling the wait up to maxPersistRetry while they
+// keep failing.
+const (
+	minPersistRetry = 10 * time.Millisecond
+	maxPersistRetry = time.Minute
+)
 
 // PersistentData represents the data structure stored in the JSON file.
 type PersistentData struct {
-	Users []model.User `json:"users"`
-	Tasks []model.Task `json:"tasks"`
+	Users         []model.User          `json:"users"`
+	Tasks         []model.Task          `json:"tasks"`
+	Shares        []model.Share         `json:"shares,omitempty"`
+	Announcements []model.Announcement  `json:"announcements,omitempty"`
+	Projects      []model.Project       `json:"projects,omitempty"`
+	Audit         []model.AuditEvent    `json:"audit,omitempty"`
+	Webhooks      []model.Webhook       `json:"webhooks,omitempty"`
+	StatsHistory  []model.StatsSnapshot `json:"statsHistory,omitempty"`
+	// Revision is the store revision the data was saved at.
+	Revision int64 `json:"revision,omitempty"`
+}
+
+// storedUser is the on-disk representation of a user.
+// Unlike model.User it includes the password hash.
+type storedUser struct {
+	model.User
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// storedShare is the on-disk representation of a share.
+// Unlike model.Share it includes the token hash.
+type storedShare struct {
+	model.Share
+	TokenHash string `json:"tokenHash"`
 }
 
-// LoadData loads data from the JSON file.
+// storedWebhook is the on-disk representation of a webhook.
+// Unlike model.Webhook it includes the secret.
+type storedWebhook struct {
+	model.Webhook
+	Secret string `json:"secret"`
+}
+
+// fileData is the on-disk representation of PersistentData.
+// SchemaVersion records which migrations the file has been through.
+type fileData struct {
+	SchemaVersion int                   `json:"schemaVersion"`
+	Users         []storedUser          `json:"users"`
+	Tasks         []model.Task          `json:"tasks"`
+	Shares        []storedShare         `json:"shares,omitempty"`
+	Announcements []model.Announcement  `json:"announcements,omitempty"`
+	Projects      []model.Project       `json:"projects,omitempty"`
+	Audit         []model.AuditEvent    `json:"audit,omitempty"`
+	Webhooks      []storedWebhook       `json:"webhooks,omitempty"`
+	StatsHistory  []model.StatsSnapshot `json:"statsHistory,omitempty"`
+	Revision      int64                 `json:"revision,omitempty"`
+}
+
+// LoadData loads data from the default JSON file.
 // Returns empty data if the file doesn't exist.
 func LoadData() (*PersistentData, error) {
-	if _, err := os.Stat(dataFilePath); os.IsNotExist(err) {
+	return LoadDataFrom(DefaultDataPath)
+}
+
+// LoadDataFrom loads data from the JSON file at path.
+// Returns empty data if the file doesn't exist.
+func LoadDataFrom(path string) (*PersistentData, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return &PersistentData{
 			Users: []model.User{},
 			Tasks: []model.Task{},
 		}, nil
 	}
 
-	data, err := os.ReadFile(dataFilePath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read data file: %w", err)
 	}
 
-	var persistentData PersistentData
-	if err := json.Unmarshal(data, &persistentData); err != nil {
+	stored, err := decodeFileData(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse data file: %w", err)
 	}
 
-	return &persistentData, nil
+	persistentData := &PersistentData{
+		Users:         make([]model.User, len(stored.Users)),
+		Tasks:         stored.Tasks,
+		Shares:        sharesFromFile(stored.Shares),
+		Announcements: stored.Announcements,
+		Projects:      stored.Projects,
+		Audit:         stored.Audit,
+		Webhooks:      webhooksFromFile(stored.Webhooks),
+		StatsHistory:  stored.StatsHistory,
+		Revision:      stored.Revision,
+	}
+	for i, su := range stored.Users {
+		user := su.User
+		user.PasswordHash = su.PasswordHash
+		persistentData.Users[i] = user
+	}
+
+	return persistentData, nil
 }
 
-// SaveData saves data to the JSON file atomically.
+// SaveData saves data to the default JSON file atomically.
 func SaveData(data *PersistentData) error {
-	dir := filepath.Dir(dataFilePath)
+	return SaveDataTo(DefaultDataPath, data)
+}
+
+// SaveDataTo saves data to the JSON file at path atomically.
+func SaveDataTo(path string, data *PersistentData) error {
+	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	stored := fileData{
+		SchemaVersion: LatestSchemaVersion(),
+		Users:         usersToFile(data.Users),
+		Tasks:         data.Tasks,
+		Shares:        sharesToFile(data.Shares),
+		Announcements: data.Announcements,
+		Projects:      data.Projects,
+		Audit:         data.Audit,
+		Webhooks:      webhooksToFile(data.Webhooks),
+		StatsHistory:  data.StatsHistory,
+		Revision:      data.Revision,
+	}
+	jsonData, err := json.MarshalIndent(stored, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 
+	return writeFileAtomic(path, jsonData)
+}
+
+// sharesToFile returns shares in their on-disk representation.
+func sharesToFile(shares []model.Share) []storedShare {
+	var stored []storedShare
+	for _, share := range shares {
+		stored = append(stored, storedShare{Share: share, TokenHash: share.TokenHash})
+	}
+	return stored
+}
+
+// sharesFromFile returns shares read from disk.
+func sharesFromFile(stored []storedShare) []model.Share {
+	var shares []model.Share
+	for _, ss := range stored {
+		share := ss.Share
+		share.TokenHash = ss.TokenHash
+		shares = append(shares, share)
+	}
+	return shares
+}
+
+// webhooksToFile returns webhooks in their on-disk representation.
+func webhooksToFile(webhooks []model.Webhook) []storedWebhook {
+	var stored []storedWebhook
+	for _, webhook := range webhooks {
+		stored = append(stored, storedWebhook{Webhook: webhook, Secret: webhook.Secret})
+	}
+	return stored
+}
+
+// webhooksFromFile returns webhooks read from disk.
+func webhooksFromFile(stored []storedWebhook) []model.Webhook {
+	var webhooks []model.Webhook
+	for _, sw := range stored {
+		webhook := sw.Webhook
+		webhook.Secret = sw.Secret
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+// writeFileAtomic replaces the file at path with data, so readers see
+// either the old contents or the new, also after a crash: the new contents
+// are synced to disk before they replace the old, and the rename is synced
+// before it returns.
+func writeFileAtomic(path string, jsonData []byte) error {
 	// Write atomically: temp file then rename
-	tempFile := dataFilePath + ".tmp"
-	if err := os.WriteFile(tempFile, jsonData, 0644); err != nil {
+	tempFile := path + ".tmp"
+	f, err := os.OpenFile(tempFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	if _, err := f.Write(jsonData); err != nil {
+		f.Close()
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to sync data file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tempFile)
 		return fmt.Errorf("failed to write data file: %w", err)
 	}
 
-	if err := os.Rename(tempFile, dataFilePath); err != nil {
+	if err := os.Rename(tempFile, path); err != nil {
 		os.Remove(tempFile)
 		return fmt.Errorf("failed to rename data file: %w", err)
 	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to sync data directory: %w", err)
+	}
 
 	return nil
 }
 
-// Initialize loads data from file or uses defaults and returns a Store.
+// Initialize loads data from the default file or uses defaults and returns a Store.
 func Initialize() *Store {
-	persistentData, err := LoadData()
+	return InitializeFrom(DefaultDataPath)
+}
+
+// InitializeFrom loads data from the file at path, replays its journal,
+// or uses defaults and returns a Store that persists back to path.
+func InitializeFrom(path string) *Store {
+	s, journalLen, loaded := loadStore(path)
+	s.dataPath = path
+	s.journalLen = journalLen
+	// The journal can only be appended to once the data file holds the rest
+	s.compactPending = !loaded
+	return s
+}
+
+// loadStore migrates the data file at path, loads it and replays its
+// journal. It returns the Store, the number of journal entries replayed
+// and whether the data file and journal hold all of the Store's data, so
+// that the journal can be appended to.
+func loadStore(path string) (*Store, int, bool) {
+	if from, err := MigrateDataFile(path, LatestSchemaVersion()); err != nil {
+		log.Printf("Warning: Failed to migrate data file: %v", err)
+	} else if from != LatestSchemaVersion() {
+		log.Printf("Migrated data file from schema version %d to %d", from, LatestSchemaVersion())
+	}
+
+	persistentData, err := LoadDataFrom(path)
 	if err != nil {
 		log.Printf("Warning: Failed to load data from file: %v. Using default data.", err)
-		return defaultStore()
+		return defaultStore(), 0, false
+	}
+
+	journalLen, err := replayJournal(path+JournalSuffix, persistentData)
+	complete := err == nil
+	if err != nil {
+		// Compacting deletes the journal, and with it any entries that
+		// could not be replayed, so they are moved out of the way first
+		aside := fmt.Sprintf("%s%s.%d.corrupt", path, JournalSuffix, time.Now().Unix())
+		if renameErr := os.Rename(path+JournalSuffix, aside); renameErr != nil {
+			log.Fatalf("Failed to replay journal: %v; failed to move it aside: %v", err, renameErr)
+		}
+		log.Printf("Warning: Failed to replay journal: %v. Moved it to %s; changes after entry %d are not loaded.", err, aside, journalLen)
 	}
 
 	// If loaded data is empty, use defaults
 	if len(persistentData.Users) == 0 && len(persistentData.Tasks) == 0 {
-		return defaultStore()
+		return defaultStore(), journalLen, false
 	}
 
-	return NewWithData(persistentData.Users, persistentData.Tasks)
+	s := NewWithData(persistentData.Users, persistentData.Tasks)
+	s.shares = persistentData.Shares
+	s.announcements = persistentData.Announcements
+	s.projects = persistentData.Projects
+	s.audit = persistentData.Audit
+	s.webhooks = persistentData.Webhooks
+	s.statsHistory = persistentData.StatsHistory
+	// Every journal entry replayed was one change
+	s.revision = persistentData.Revision + int64(journalLen)
+	s.loadedRevision = s.revision
+	return s, journalLen, complete
 }
 
-// defaultStore returns a Store with sample data.
+// defaultStore returns a Store with sample data, created now.
 func defaultStore() *Store {
+	now := time.Now().UTC()
 	return NewWithData(
 		[]model.User{
 			{ID: 1, Name: "John Doe", Email: "john@example.com", Role: "developer"},
@@ -92,22 +305,29 @@ func defaultStore() *Store {
 			{ID: 3, Name: "Bob Johnson", Email: "bob@example.com", Role: "manager"},
 		},
 		[]model.Task{
-			{ID: 1, Title: "Implement authentication", Status: "pending", UserID: 1},
-			{ID: 2, Title: "Design user interface", Status: "in-progress", UserID: 2},
-			{ID: 3, Title: "Review code changes", Status: "completed", UserID: 3},
+			{ID: 1, Title: "Implement authentication", Status: "pending", Priority: "high", UserID: 1, CreatedAt: now, AssignedAt: now},
+			{ID: 2, Title: "Design user interface", Status: "in-progress", Priority: "medium", UserID: 2, CreatedAt: now, AssignedAt: now},
+			{ID: 3, Title: "Review code changes", Status: "completed", Priority: "low", UserID: 3, CreatedAt: now, AssignedAt: now, CompletedAt: &now},
 		},
 	)
 }
 
-// Persist saves the current state of the Store to file.
-func (s *Store) Persist() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	data := &PersistentData{
-		Users: s.users,
-		Tasks: s.tasks,
+// Persist saves the current state of the Store to the data file and
+// truncates the journal. It is a no-op for ephemeral stores.
+func (s *Store) Persist(ctx context.Context) error {
+	if s.ephemeral {
+		return nil
 	}
 
-	return SaveData(data)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	s.journal = nil
+	s.compactPending = false
+	data := s.copyData()
+	path := s.dataPath
+	s.mu.Unlock()
+
+	return s.compact(path, data)
 }
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// postgresMigrationFiles holds the PostgreSQL migrations, named
+// NNNN_name.up.sql and NNNN_name.down.sql.
+//
+//go:embed migrations/postgres/*.sql
+var postgresMigrationFiles embed.FS
+
+// migrationLockID keys the advisory lock that keeps replicas starting at
+// the same time from migrating concurrently.
+const migrationLockID = 7204001
+
+// sqlMigration is one PostgreSQL migration loaded from its files.
+type sqlMigration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+// postgresMigrations loads the PostgreSQL migrations, oldest first.
+func postgresMigrations() ([]sqlMigration, error) {
+	files, err := postgresMigrationFiles.ReadDir("migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*sqlMigration)
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file.Name(), ".sql"), ".")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", file.Name())
+		}
+
+		sql, err := postgresMigrationFiles.ReadFile(path.Join("migrations/postgres", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &sqlMigration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(sql)
+		} else {
+			m.down = string(sql)
+		}
+	}
+
+	migrations := make([]sqlMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		if m.version != i+1 || m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d (%s) is missing or incomplete", i+1, m.name)
+		}
+	}
+	return migrations, nil
+}
+
+// LatestPostgresSchemaVersion returns the schema version of the newest
+// PostgreSQL migration.
+func LatestPostgresSchemaVersion() int {
+	migrations, err := postgresMigrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// MigratePostgres migrates the database at url to schema version to, up or
+// down, in a single transaction. Returns the version it was at.
+func MigratePostgres(ctx context.Context, url string, to int) (int, error) {
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	return migratePostgres(ctx, conn, to)
+}
+
+// migratePostgres applies migrations between the version recorded in the
+// schema_version table and to.
+func migratePostgres(ctx context.Context, db interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}, to int) (int, error) {
+	migrations, err := postgresMigrations()
+	if err != nil {
+		return 0, err
+	}
+	latest := len(migrations)
+	if to < 0 || to > latest {
+		return 0, fmt.Errorf("unknown schema version %d; latest is %d", to, latest)
+	}
+
+	var from int
+	err = pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+			return err
+		}
+		err := tx.QueryRow(ctx, `SELECT version FROM schema_version`).Scan(&from)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if from > latest {
+			return fmt.Errorf("database schema version %d is newer than this server supports (%d)", from, latest)
+		}
+
+		if to > from {
+			for _, m := range migrations[from:to] {
+				if _, err := tx.Exec(ctx, m.up); err != nil {
+					return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+				}
+			}
+		}
+		for i := from - 1; i >= to; i-- {
+			m := migrations[i]
+			if _, err := tx.Exec(ctx, m.down); err != nil {
+				return fmt.Errorf("reverting migration %d (%s) failed: %w", m.version, m.name, err)
+			}
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_version`); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO schema_version (version) VALUES ($1)`, to)
+		return err
+	})
+	return from, err
+}
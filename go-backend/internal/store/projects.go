@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+var (
+	// ErrProjectNotFound is returned for a project that does not exist.
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrProjectInUse is returned deleting a project that still has tasks.
+	ErrProjectInUse = errors.New("project has tasks")
+)
+
+// GetProjects returns all projects in ID order.
+func (s *Store) GetProjects(ctx context.Context) []model.Project {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]model.Project{}, s.projects...)
+}
+
+// GetProjectByID returns a project by ID or nil if not found.
+func (s *Store) GetProjectByID(ctx context.Context, id int) *model.Project {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.projects {
+		if s.projects[i].ID == id {
+			project := s.projects[i]
+			return &project
+		}
+	}
+	return nil
+}
+
+// CreateProject adds a project and returns it with a generated ID and its
+// creation time.
+func (s *Store) CreateProject(ctx context.Context, project model.Project) (model.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxID := 0
+	for _, p := range s.projects {
+		if p.ID > maxID {
+			maxID = p.ID
+		}
+	}
+
+	project.ID = s.ids.NextID(KindProject, maxID)
+	project.CreatedAt = time.Now().UTC()
+
+	s.projects = append(s.projects, project)
+	s.journalProject(project)
+
+	return project, nil
+}
+
+// UpdateProject updates a project and returns it, or nil if not found.
+// Only non-nil fields are updated.
+func (s *Store) UpdateProject(ctx context.Context, id int, update model.ProjectUpdate) (*model.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.projects {
+		p := &s.projects[i]
+		if p.ID != id {
+			continue
+		}
+		if update.Name != nil {
+			p.Name = *update.Name
+		}
+		if update.Description != nil {
+			p.Description = *update.Description
+		}
+		s.journalProject(*p)
+
+		return p, nil
+	}
+	return nil, nil
+}
+
+// DeleteProject removes a project. Projects with tasks are kept, and
+// ErrProjectInUse returned: their tasks must be moved or removed from the
+// project first.
+func (s *Store) DeleteProject(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range s.tasks {
+		if task.ProjectID == id {
+			return ErrProjectInUse
+		}
+	}
+	for i, p := range s.projects {
+		if p.ID == id {
+			s.projects = append(s.projects[:i:i], s.projects[i+1:]...)
+			s.journalDeleteProject(id)
+			return nil
+		}
+	}
+	return ErrProjectNotFound
+}
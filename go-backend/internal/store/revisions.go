@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"go-backend/internal/model"
+)
+
+// ErrUnknownRevision is returned for changes since a revision older than
+// the store remembers, or newer than its current revision.
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// taskRevision records the store revisions at which a task was created
+// and last changed.
+type taskRevision struct {
+	created, updated int64
+}
+
+// Revision returns the store revision, which grows with every change.
+func (s *Store) Revision(ctx context.Context) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
+
+// TaskChanges returns the IDs of the tasks created, updated and deleted
+// since revision since. Only changes made since the store was loaded are
+// remembered; earlier revisions return ErrUnknownRevision.
+func (s *Store) TaskChanges(ctx context.Context, since int64) (model.TaskChanges, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if since < s.loadedRevision || since > s.revision {
+		return model.TaskChanges{}, ErrUnknownRevision
+	}
+
+	changes := model.TaskChanges{Revision: s.revision, Created: []int{}, Updated: []int{}, Deleted: []int{}}
+	present := make(map[int]bool, len(s.tasks))
+	for _, task := range s.tasks {
+		present[task.ID] = true
+		switch rev := s.taskRevisions[task.ID]; {
+		case rev.created > since:
+			changes.Created = append(changes.Created, task.ID)
+		case rev.updated > since:
+			changes.Updated = append(changes.Updated, task.ID)
+		}
+	}
+	for id, rev := range s.deletedTasks {
+		if rev > since && !present[id] {
+			changes.Deleted = append(changes.Deleted, id)
+		}
+	}
+	sort.Ints(changes.Created)
+	sort.Ints(changes.Updated)
+	sort.Ints(changes.Deleted)
+	return changes, nil
+}
+
+// taskChanged records that a task changed at the current revision, and
+// was created then if created is set. Must be called with mu held.
+func (s *Store) taskChanged(id int, created bool) {
+	if s.taskRevisions == nil {
+		s.taskRevisions = make(map[int]taskRevision)
+	}
+	rev := s.taskRevisions[id]
+	rev.updated = s.revision
+	if created {
+		rev.created = s.revision
+	}
+	s.taskRevisions[id] = rev
+}
+
+// replaceTasks replaces all tasks, recording the old ones as deleted and
+// the new ones as created at the current revision. Must be called with mu
+// held.
+func (s *Store) replaceTasks(tasks []model.Task) {
+	if s.deletedTasks == nil {
+		s.deletedTasks = make(map[int]int64)
+	}
+	for _, task := range s.tasks {
+		s.deletedTasks[task.ID] = s.revision
+	}
+	s.taskRevisions = nil
+	s.tasks = tasks
+	s.reindexTasks()
+	for _, task := range s.tasks {
+		s.taskChanged(task.ID, true)
+	}
+}
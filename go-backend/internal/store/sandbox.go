@@ -0,0 +1,30 @@
+package store
+
+// NewSandbox creates an ephemeral Store seeded with sample data.
+// Mutations are kept in memory only, so clients can experiment freely.
+func NewSandbox() *Store {
+	s := defaultStore()
+	s.ephemeral = true
+	return s
+}
+
+// ResetToSampleData replaces all users and tasks with a fresh copy of the
+// sample data, and removes all shares, announcements, projects, audit
+// events, webhooks and stats history.
+func (s *Store) ResetToSampleData() {
+	fresh := defaultStore()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = fresh.users
+	s.reindexUsers()
+	s.shares = nil
+	s.announcements = nil
+	s.projects = nil
+	s.audit = nil
+	s.webhooks = nil
+	s.statsHistory = nil
+	s.journalReplaceAll()
+	s.replaceTasks(fresh.tasks)
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+
+	"go-backend/internal/model"
+)
+
+// Snapshot is a point-in-time copy of all Store data.
+// It shares no memory with the Store it was taken from.
+type Snapshot struct {
+	users         []model.User
+	tasks         []model.Task
+	shares        []model.Share
+	announcements []model.Announcement
+	projects      []model.Project
+	revision      int64
+}
+
+// NewSnapshot creates a Snapshot holding copies of users and tasks.
+func NewSnapshot(users []model.User, tasks []model.Task) Snapshot {
+	return Snapshot{
+		users: append([]model.User{}, users...),
+		tasks: copyTasks(tasks),
+	}
+}
+
+// Users returns the users in the snapshot.
+func (snap Snapshot) Users() []model.User {
+	return snap.users
+}
+
+// Tasks returns the tasks in the snapshot.
+func (snap Snapshot) Tasks() []model.Task {
+	return snap.tasks
+}
+
+// Shares returns the task shares in the snapshot.
+func (snap Snapshot) Shares() []model.Share {
+	return snap.shares
+}
+
+// Announcements returns the announcements in the snapshot.
+func (snap Snapshot) Announcements() []model.Announcement {
+	return snap.announcements
+}
+
+// Projects returns the projects in the snapshot.
+func (snap Snapshot) Projects() []model.Project {
+	return snap.projects
+}
+
+// WithoutPasswordHashes returns snap with the users' password hashes
+// removed, e.g. for a backup that should not carry them.
+func (snap Snapshot) WithoutPasswordHashes() Snapshot {
+	users := make([]model.User, len(snap.users))
+	for i, user := range snap.users {
+		user.PasswordHash = ""
+		users[i] = user
+	}
+	snap.users = users
+	return snap
+}
+
+// WithPasswordHashesFrom returns snap with each user that has no password
+// hash given that of the user in users with the same ID and email, e.g.
+// to restore a backup taken without hashes without clearing passwords.
+func (snap Snapshot) WithPasswordHashesFrom(users []model.User) Snapshot {
+	byID := make(map[int]model.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	restored := make([]model.User, len(snap.users))
+	for i, user := range snap.users {
+		if current, ok := byID[user.ID]; ok && user.PasswordHash == "" && current.Email == user.Email {
+			user.PasswordHash = current.PasswordHash
+		}
+		restored[i] = user
+	}
+	snap.users = restored
+	return snap
+}
+
+// Revision returns the store revision the snapshot was taken at, so the
+// changes since can be listed with TaskChanges. It is zero for snapshots
+// not taken from a store, and for backups written before it was kept.
+func (snap Snapshot) Revision() int64 {
+	return snap.revision
+}
+
+// Snapshot captures a copy of the current users, tasks, shares,
+// announcements and projects, and the revision they are at.
+func (s *Store) Snapshot(ctx context.Context) (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Snapshot{
+		users:         append([]model.User{}, s.users...),
+		tasks:         copyTasks(s.tasks),
+		shares:        append([]model.Share{}, s.shares...),
+		announcements: append([]model.Announcement{}, s.announcements...),
+		projects:      append([]model.Project{}, s.projects...),
+		revision:      s.revision,
+	}, nil
+}
+
+// Restore replaces all users, tasks, shares, announcements and projects
+// with the contents of a snapshot. The snapshot is copied, so it can be
+// restored again later.
+func (s *Store) Restore(ctx context.Context, snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = append([]model.User{}, snap.users...)
+	s.reindexUsers()
+	s.shares = append([]model.Share{}, snap.shares...)
+	s.announcements = append([]model.Announcement{}, snap.announcements...)
+	s.projects = append([]model.Project{}, snap.projects...)
+	s.journalReplaceAll()
+	s.replaceTasks(copyTasks(snap.tasks))
+	return nil
+}
@@ -2,73 +2,162 @@
 package store
 
 import (
+	"context"
 	"log"
+	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go-backend/internal/model"
+	"go-backend/internal/validator"
 )
 
 // Store holds all application data with thread-safe access.
 type Store struct {
-	mu    sync.RWMutex
-	users []model.User
-	tasks []model.Task
+	mu     sync.RWMutex
+	users  []model.User
+	tasks  []model.Task
+	shares []model.Share
+	// index locates users and tasks without scanning them.
+	index index
+	// announcements, projects, audit events and webhooks are in ID order.
+	announcements []model.Announcement
+	projects      []model.Project
+	audit         []model.AuditEvent
+	webhooks      []model.Webhook
+	// statsHistory is in time order.
+	statsHistory []model.StatsSnapshot
+	// ephemeral stores never write to disk.
+	ephemeral bool
+	ids       IDGenerator
+	// revision counts changes. Tasks changed since loadedRevision have
+	// their revisions in taskRevisions, and deleted ones in deletedTasks.
+	revision       int64
+	loadedRevision int64
+	taskRevisions  map[int]taskRevision
+	deletedTasks   map[int]int64
+	dataPath       string
+	// pending tracks scheduled and in-flight background writes.
+	pending sync.WaitGroup
+	// persistDelay is how long a write is deferred so that changes made
+	// in the meantime are saved by the same write.
+	persistDelay     time.Duration
+	persistMu        sync.Mutex
+	persistScheduled bool
+	// persistErr is the error of the latest write of the data file or
+	// journal, nil if it succeeded, and persistedAt the time of the latest
+	// successful one. Guarded by persistMu.
+	persistErr  error
+	persistedAt time.Time
+	// retryTimer is the scheduled retry of a failed write, nil if none, and
+	// retryDelay how long it waited. Guarded by persistMu.
+	retryTimer *time.Timer
+	retryDelay time.Duration
+	// journal holds changes not yet appended to the journal file.
+	journal []journalEntry
+	// compactPending requests a full data file write on the next flush.
+	compactPending bool
+	// compactAfter is how many journal entries trigger compaction.
+	compactAfter int
+	// writeMu serializes file writes and guards journalLen.
+	writeMu sync.Mutex
+	// journalLen is the number of entries in the journal file.
+	journalLen int
+	// writes counts completed journal appends and data file writes.
+	writes atomic.Int64
 }
 
 // New creates a new empty Store.
 func New() *Store {
-	return &Store{
-		users: []model.User{},
-		tasks: []model.Task{},
-	}
+	return NewWithData([]model.User{}, []model.Task{})
 }
 
-// NewWithData creates a Store with initial data.
+// NewWithData creates a Store with a copy of the given data.
 func NewWithData(users []model.User, tasks []model.Task) *Store {
-	return &Store{
-		users: users,
-		tasks: tasks,
+	s := &Store{
+		users:        append([]model.User{}, users...),
+		tasks:        copyTasks(tasks),
+		ids:          MaxPlusOne{},
+		dataPath:     DefaultDataPath,
+		persistDelay: DefaultPersistDelay,
+		compactAfter: DefaultCompactAfter,
+		// The data file may not exist yet, so the first flush writes it in full
+		compactPending: true,
 	}
+	s.reindexUsers()
+	s.reindexTasks()
+	return s
+}
+
+// SetDataPath sets the file the Store persists to.
+func (s *Store) SetDataPath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dataPath = path
+}
+
+// SetPersistDelay sets how long background writes are deferred so that
+// bursts of changes are coalesced into a single write. Zero writes as soon
+// as possible, still coalescing changes made while a write is pending.
+func (s *Store) SetPersistDelay(d time.Duration) {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	s.persistDelay = d
+}
+
+// SetCompactAfter sets how many journal entries accumulate before the
+// journal is compacted into the data file.
+func (s *Store) SetCompactAfter(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compactAfter = n
+}
+
+// SetIDGenerator replaces the generator used for new entity IDs.
+func (s *Store) SetIDGenerator(ids IDGenerator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = ids
 }
 
-// GetUsers returns all users.
-func (s *Store) GetUsers() []model.User {
+// GetUsers returns a copy of all users.
+func (s *Store) GetUsers(ctx context.Context) ([]model.User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.users
+	return append([]model.User{}, s.users...), nil
 }
 
 // GetUserByID returns a user by ID or nil if not found.
-func (s *Store) GetUserByID(id int) *model.User {
+func (s *Store) GetUserByID(ctx context.Context, id int) (*model.User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	for i := range s.users {
-		if s.users[i].ID == id {
-			return &s.users[i]
-		}
+	if i, ok := s.userAt(id); ok {
+		user := s.users[i]
+		return &user, nil
 	}
-	return nil
+	return nil, nil
 }
 
-// UserExistsByEmail checks if a user with the given email exists.
-func (s *Store) UserExistsByEmail(email string) bool {
+// UserExistsByEmail checks if a user with the given email exists,
+// ignoring case and surrounding spaces.
+func (s *Store) UserExistsByEmail(ctx context.Context, email string) (bool, error) {
+	email = validator.NormalizeEmail(email)
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	for _, user := range s.users {
-		if user.Email == email {
-			return true
-		}
-	}
-	return false
+	_, ok := s.index.emails[email]
+	return ok, nil
 }
 
-// CreateUser adds a new user and returns it with a generated ID.
-func (s *Store) CreateUser(name, email, role string) model.User {
+// CreateUser adds a new user, including its password hash if set, and
+// returns it with a generated ID and normalized email. user.ID is ignored.
+func (s *Store) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	user.Email = validator.NormalizeEmail(user.Email)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Generate new ID by finding max ID + 1
 	maxID := 0
 	for _, user := range s.users {
 		if user.ID > maxID {
@@ -76,64 +165,94 @@ func (s *Store) CreateUser(name, email, role string) model.User {
 		}
 	}
 
-	newUser := model.User{
-		ID:    maxID + 1,
-		Name:  name,
-		Email: email,
-		Role:  role,
-	}
+	newUser := user
+	newUser.ID = s.ids.NextID(KindUser, maxID)
 
 	s.users = append(s.users, newUser)
+	s.indexUser(len(s.users) - 1)
+	s.journalUser(newUser)
 
-	// Persist data asynchronously
-	go s.persistAsync()
+	return newUser, nil
+}
 
-	return newUser
+// SetPasswordHash replaces the password hash of a user.
+// Returns false if the user does not exist.
+func (s *Store) SetPasswordHash(ctx context.Context, id int, passwordHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.userAt(id)
+	if !ok {
+		return false, nil
+	}
+	s.users[i].PasswordHash = passwordHash
+	s.journalUser(s.users[i])
+
+	return true, nil
+}
+
+// SetPreferences replaces the preferences of a user.
+// Returns false if the user does not exist.
+func (s *Store) SetPreferences(ctx context.Context, id int, prefs model.UserPreferences) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.userAt(id)
+	if !ok {
+		return false, nil
+	}
+	s.users[i].Preferences = prefs
+	s.journalUser(s.users[i])
+
+	return true, nil
 }
 
 // GetTasks returns tasks, optionally filtered by status and/or userID.
-func (s *Store) GetTasks(status, userID string) []model.Task {
+func (s *Store) GetTasks(ctx context.Context, status, userID string) ([]model.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var filtered []model.Task
-	for _, task := range s.tasks {
-		matchStatus := status == "" || task.Status == status
-
-		matchUserID := true
-		if userID != "" {
-			if id, err := strconv.Atoi(userID); err == nil {
-				matchUserID = task.UserID == id
-			} else {
-				matchUserID = false
-			}
+	filtered := []model.Task{}
+	add := func(task model.Task) {
+		if status == "" || task.Status == status {
+			filtered = append(filtered, copyTask(task))
 		}
-
-		if matchStatus && matchUserID {
-			filtered = append(filtered, task)
+	}
+	if userID == "" {
+		for _, task := range s.tasks {
+			add(task)
 		}
+		return filtered, nil
 	}
-	return filtered
+
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return filtered, nil
+	}
+	for _, i := range s.index.byOwner[id] {
+		add(s.tasks[i])
+	}
+	return filtered, nil
 }
 
 // GetTaskByID returns a task by ID or nil if not found.
-func (s *Store) GetTaskByID(id int) *model.Task {
+func (s *Store) GetTaskByID(ctx context.Context, id int) (*model.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	for i := range s.tasks {
-		if s.tasks[i].ID == id {
-			return &s.tasks[i]
-		}
+	if i, ok := s.taskAt(id); ok {
+		task := copyTask(s.tasks[i])
+		return &task, nil
 	}
-	return nil
+	return nil, nil
 }
 
-// CreateTask adds a new task and returns it with a generated ID.
-func (s *Store) CreateTask(title, status string, userID int) model.Task {
+// CreateTask adds a new task and returns it with a generated ID and
+// creation time, normalized tags, and the default priority if it has none.
+// task.ID and the times set by the store are ignored.
+func (s *Store) CreateTask(ctx context.Context, task model.Task) (model.Task, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Generate new ID by finding max ID + 1
 	maxID := 0
 	for _, task := range s.tasks {
 		if task.ID > maxID {
@@ -141,74 +260,305 @@ func (s *Store) CreateTask(title, status string, userID int) model.Task {
 		}
 	}
 
-	newTask := model.Task{
-		ID:     maxID + 1,
-		Title:  title,
-		Status: status,
-		UserID: userID,
+	newTask := copyTask(task)
+	newTask.ID = s.ids.NextID(KindTask, maxID)
+	newTask.CreatedAt = time.Now().UTC()
+	newTask.AssignedAt = newTask.CreatedAt
+	newTask.CompletedAt = completedAt(nil, "", task.Status, newTask.CreatedAt)
+	if task.DueAt != nil {
+		newTask.DueAt = dueAt(*task.DueAt)
 	}
+	if newTask.Priority == "" {
+		newTask.Priority = validator.DefaultPriority
+	}
+	newTask.Tags = validator.NormalizeTags(task.Tags)
 
 	s.tasks = append(s.tasks, newTask)
+	s.indexTask(len(s.tasks) - 1)
+	s.journalNewTask(newTask)
 
-	// Persist data asynchronously
-	go s.persistAsync()
-
-	return newTask
+	return copyTask(newTask), nil
 }
 
 // UpdateTask updates a task and returns the updated task or nil if not found.
 // Only non-nil fields are updated.
-func (s *Store) UpdateTask(id int, title, status *string, userID *int) *model.Task {
+func (s *Store) UpdateTask(ctx context.Context, id int, update model.TaskUpdate) (*model.Task, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for i := range s.tasks {
-		if s.tasks[i].ID == id {
-			if title != nil {
-				s.tasks[i].Title = *title
-			}
-			if status != nil {
-				s.tasks[i].Status = *status
-			}
-			if userID != nil {
-				s.tasks[i].UserID = *userID
-			}
-
-			// Persist data asynchronously
-			go s.persistAsync()
-
-			return &s.tasks[i]
+	i, ok := s.taskAt(id)
+	if !ok {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	if update.Title != nil {
+		s.tasks[i].Title = *update.Title
+	}
+	if update.Description != nil {
+		s.tasks[i].Description = *update.Description
+	}
+	if update.Status != nil {
+		s.tasks[i].CompletedAt = completedAt(s.tasks[i].CompletedAt, s.tasks[i].Status, *update.Status, now)
+		s.tasks[i].Status = *update.Status
+	}
+	if update.Priority != nil {
+		s.tasks[i].Priority = *update.Priority
+	}
+	if update.Tags != nil {
+		s.tasks[i].Tags = validator.NormalizeTags(*update.Tags)
+	}
+	if update.Unfurls != nil {
+		s.tasks[i].Unfurls = nil
+		if len(*update.Unfurls) > 0 {
+			s.tasks[i].Unfurls = slices.Clone(*update.Unfurls)
+		}
+	}
+	if update.ProjectID != nil {
+		s.tasks[i].ProjectID = *update.ProjectID
+	}
+	if update.UserID != nil && *update.UserID != s.tasks[i].UserID {
+		from := s.tasks[i].UserID
+		s.tasks[i].UserID = *update.UserID
+		s.tasks[i].AssignedAt = now
+		s.reassignTask(i, from)
+	}
+	if update.DueAt != nil {
+		s.tasks[i].DueAt = dueAt(*update.DueAt)
+	}
+	s.journalTask(s.tasks[i])
+
+	task := copyTask(s.tasks[i])
+	return &task, nil
+}
+
+// CreateShare adds a share and returns it with its creation time set.
+func (s *Store) CreateShare(ctx context.Context, share model.Share) (model.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	share.CreatedAt = time.Now().UTC()
+	share.ExpiresAt = share.ExpiresAt.UTC()
+	s.shares = append(s.shares, share)
+	s.journalShare(share)
+
+	return share, nil
+}
+
+// GetShares returns the shares of a task, oldest first.
+func (s *Store) GetShares(ctx context.Context, taskID int) []model.Share {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shares := []model.Share{}
+	for _, share := range s.shares {
+		if share.TaskID == taskID {
+			shares = append(shares, share)
+		}
+	}
+	return shares
+}
+
+// GetShareByTokenHash returns the share whose token has the given hash,
+// or nil if there is none. Expired shares are returned too.
+func (s *Store) GetShareByTokenHash(ctx context.Context, tokenHash string) *model.Share {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, share := range s.shares {
+		if share.TokenHash == tokenHash {
+			return &share
 		}
 	}
 	return nil
 }
 
+// DeleteShare removes a share of a task and reports whether it existed.
+func (s *Store) DeleteShare(ctx context.Context, taskID int, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, share := range s.shares {
+		if share.ID == id && share.TaskID == taskID {
+			s.shares = append(s.shares[:i:i], s.shares[i+1:]...)
+			s.journalDeleteShare(id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dueAt returns t as a stored due date: nil for the zero time, UTC otherwise.
+func dueAt(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// completedAt returns a task's completion time after its status changes
+// from old to status at now: kept while it stays completed, set when it
+// becomes completed and cleared otherwise.
+func completedAt(prev *time.Time, old, status string, now time.Time) *time.Time {
+	switch {
+	case status != "completed":
+		return nil
+	case old == "completed":
+		return prev
+	default:
+		return &now
+	}
+}
+
 // GetStats returns statistics about users and tasks.
-func (s *Store) GetStats() model.StatsResponse {
+func (s *Store) GetStats(ctx context.Context) model.StatsResponse {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return CountStats(s.users, s.tasks)
+}
 
+// CountStats returns the statistics of users and tasks, as GetStats
+// reports them.
+func CountStats(users []model.User, tasks []model.Task) model.StatsResponse {
 	var stats model.StatsResponse
-	stats.Users.Total = len(s.users)
-	stats.Tasks.Total = len(s.tasks)
+	stats.Users.Total = len(users)
+
+	for _, task := range tasks {
+		countTask(&stats.Tasks, task.Status)
+		countPriority(&stats.Priorities, task.Priority)
+	}
 
+	return stats
+}
+
+// EachUserStats calls fn with task statistics for each user, in user order,
+// stopping early if fn returns false. Rows are computed from a copy of the
+// data, so a slow fn does not block writers.
+func (s *Store) EachUserStats(ctx context.Context, fn func(model.UserStats) bool) {
+	s.mu.RLock()
+	users := append([]model.User{}, s.users...)
+	tasksByUser := make(map[int][]string, len(users))
 	for _, task := range s.tasks {
-		switch task.Status {
-		case "pending":
-			stats.Tasks.Pending++
-		case "in-progress":
-			stats.Tasks.InProgress++
-		case "completed":
-			stats.Tasks.Completed++
+		tasksByUser[task.UserID] = append(tasksByUser[task.UserID], task.Status)
+	}
+	s.mu.RUnlock()
+
+	for _, user := range users {
+		row := model.UserStats{UserID: user.ID, Name: user.Name}
+		for _, status := range tasksByUser[user.ID] {
+			countTask(&row.Tasks, status)
+		}
+		if !fn(row) {
+			return
 		}
 	}
+}
 
-	return stats
+// countTask adds a task with the given status to counts.
+func countTask(counts *model.TaskCounts, status string) {
+	counts.Total++
+	switch status {
+	case "pending":
+		counts.Pending++
+	case "in-progress":
+		counts.InProgress++
+	case "completed":
+		counts.Completed++
+	}
 }
 
-// persistAsync persists data asynchronously.
+// countPriority adds a task with the given priority to counts.
+func countPriority(counts *model.PriorityCounts, priority string) {
+	switch priority {
+	case "low":
+		counts.Low++
+	case "medium":
+		counts.Medium++
+	case "high":
+		counts.High++
+	case "urgent":
+		counts.Urgent++
+	}
+}
+
+// persistAsync schedules a background flush of the journal after persistDelay.
+// Changes made before the flush starts are saved by that same write,
+// so a burst of changes results in a single file write.
 func (s *Store) persistAsync() {
-	if err := s.Persist(); err != nil {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	if s.persistScheduled {
+		return
+	}
+	s.persistScheduled = true
+	s.pending.Add(1)
+
+	time.AfterFunc(s.persistDelay, func() {
+		defer s.pending.Done()
+
+		// Clear the flag before writing so changes made during the write
+		// schedule another one.
+		s.persistMu.Lock()
+		s.persistScheduled = false
+		s.persistMu.Unlock()
+
+		s.flushOrRetry()
+	})
+}
+
+// flushOrRetry flushes the journal and, if that fails, schedules a retry.
+// Without one, the entries a failed flush queued again would wait for the
+// next change. Retries start after persistDelay and back off to
+// maxPersistRetry while they keep failing.
+func (s *Store) flushOrRetry() {
+	err := s.flush()
+
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	if err == nil {
+		s.retryDelay = 0
+		return
+	}
+	if s.retryTimer != nil {
 		log.Printf("Warning: Failed to persist data: %v", err)
+		return
+	}
+	if s.retryDelay == 0 {
+		s.retryDelay = max(s.persistDelay, minPersistRetry)
+	} else {
+		s.retryDelay = min(2*s.retryDelay, maxPersistRetry)
+	}
+	log.Printf("Warning: Failed to persist data, retrying in %v: %v", s.retryDelay, err)
+	s.retryTimer = time.AfterFunc(s.retryDelay, func() {
+		s.persistMu.Lock()
+		s.retryTimer = nil
+		s.persistMu.Unlock()
+		s.flushOrRetry()
+	})
+}
+
+// WaitForPersistence blocks until all scheduled background writes have
+// finished, including writes that are still waiting out persistDelay.
+// Retries of failed writes are not waited for.
+func (s *Store) WaitForPersistence() {
+	s.pending.Wait()
+}
+
+// Close waits for pending background writes and makes a last attempt at
+// any write still waiting to be retried. The Store remains usable.
+func (s *Store) Close() {
+	s.WaitForPersistence()
+
+	s.persistMu.Lock()
+	retry := s.retryTimer != nil && s.retryTimer.Stop()
+	if retry {
+		s.retryTimer = nil
+	}
+	s.persistMu.Unlock()
+	if retry {
+		if err := s.flush(); err != nil {
+			log.Printf("Warning: Failed to persist data on close: %v", err)
+		}
 	}
 }
@@ -1,14 +1,31 @@
 package store
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"go-backend/internal/model"
 )
 
-func newTestStore() *Store {
-	return NewWithData(
+// testIDStart is the first ID assigned to entities created in tests.
+const testIDStart = 100
+
+// newTestStore returns a Store with fixture data that persists to a
+// per-test temporary directory, so tests can run in parallel.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s := NewWithData(
 		[]model.User{
 			{ID: 1, Name: "John Doe", Email: "john@example.com", Role: "developer"},
 			{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Role: "designer"},
@@ -18,11 +35,29 @@ func newTestStore() *Store {
 			{ID: 2, Title: "Test task 2", Status: "in-progress", UserID: 2},
 		},
 	)
+	s.SetIDGenerator(NewSequence(testIDStart))
+	s.SetDataPath(filepath.Join(t.TempDir(), "data.json"))
+
+	// Let background writes finish before the temp directory is removed
+	t.Cleanup(s.WaitForPersistence)
+
+	return s
+}
+
+// must returns v, failing the test run if err is set. The in-memory Store
+// never fails, so its errors need no handling in tests.
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
 }
 
 func TestStore_GetUsers(t *testing.T) {
-	s := newTestStore()
-	users := s.GetUsers()
+	t.Parallel()
+
+	s := newTestStore(t)
+	users := must(s.GetUsers(context.Background()))
 
 	if len(users) != 2 {
 		t.Errorf("expected 2 users, got %d", len(users))
@@ -30,7 +65,9 @@ func TestStore_GetUsers(t *testing.T) {
 }
 
 func TestStore_GetUserByID(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	tests := []struct {
 		name     string
@@ -45,7 +82,7 @@ func TestStore_GetUserByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user := s.GetUserByID(tt.id)
+			user := must(s.GetUserByID(context.Background(), tt.id))
 
 			if tt.wantNil {
 				if user != nil {
@@ -65,12 +102,14 @@ func TestStore_GetUserByID(t *testing.T) {
 }
 
 func TestStore_CreateUser(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
 
-	user := s.CreateUser("Alice Cooper", "alice@example.com", "manager")
+	s := newTestStore(t)
 
-	if user.ID != 3 {
-		t.Errorf("expected ID 3, got %d", user.ID)
+	user := must(s.CreateUser(context.Background(), model.User{Name: "Alice Cooper", Email: "alice@example.com", Role: "manager"}))
+
+	if user.ID != testIDStart {
+		t.Errorf("expected ID %d, got %d", testIDStart, user.ID)
 	}
 	if user.Name != "Alice Cooper" {
 		t.Errorf("expected name 'Alice Cooper', got '%s'", user.Name)
@@ -80,14 +119,81 @@ func TestStore_CreateUser(t *testing.T) {
 	}
 
 	// Verify user was added
-	users := s.GetUsers()
+	users := must(s.GetUsers(context.Background()))
 	if len(users) != 3 {
 		t.Errorf("expected 3 users after creation, got %d", len(users))
 	}
 }
 
+func TestStore_DefaultIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	s := NewWithData([]model.User{{ID: 7, Name: "Existing"}}, nil)
+	s.SetDataPath(filepath.Join(t.TempDir(), "data.json"))
+	t.Cleanup(s.WaitForPersistence)
+
+	user := must(s.CreateUser(context.Background(), model.User{Name: "Next User", Email: "next@example.com", Role: "developer"}))
+
+	if user.ID != 8 {
+		t.Errorf("expected ID 8 (max + 1), got %d", user.ID)
+	}
+}
+
+func TestSequence_NextID(t *testing.T) {
+	t.Parallel()
+
+	seq := NewSequence(10)
+
+	got := []int{
+		seq.NextID(KindUser, 500),
+		seq.NextID(KindUser, 500),
+		seq.NextID(KindTask, 0),
+	}
+	want := []int{10, 11, 10}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("call %d: expected ID %d, got %d", i, want[i], got[i])
+		}
+	}
+}
+
+func TestStore_SetPasswordHash(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+
+	if !must(s.SetPasswordHash(context.Background(), 1, "hash")) {
+		t.Fatal("expected SetPasswordHash to succeed for existing user")
+	}
+	if got := must(s.GetUserByID(context.Background(), 1)).PasswordHash; got != "hash" {
+		t.Errorf("expected password hash 'hash', got '%s'", got)
+	}
+	if must(s.SetPasswordHash(context.Background(), 999, "hash")) {
+		t.Error("expected SetPasswordHash to fail for non-existent user")
+	}
+}
+
+func TestStore_SetPreferences(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+
+	if !must(s.SetPreferences(context.Background(), 1, model.UserPreferences{DigestOptOut: true})) {
+		t.Fatal("expected SetPreferences to succeed for existing user")
+	}
+	if !must(s.GetUserByID(context.Background(), 1)).Preferences.DigestOptOut {
+		t.Error("expected the user to have opted out of the digest")
+	}
+	if must(s.SetPreferences(context.Background(), 999, model.UserPreferences{})) {
+		t.Error("expected SetPreferences to fail for non-existent user")
+	}
+}
+
 func TestStore_UserExistsByEmail(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	tests := []struct {
 		name   string
@@ -96,12 +202,14 @@ func TestStore_UserExistsByEmail(t *testing.T) {
 	}{
 		{"existing email", "john@example.com", true},
 		{"another existing email", "jane@example.com", true},
+		{"different case", "John@Example.COM", true},
+		{"surrounding spaces", " jane@example.com ", true},
 		{"non-existent email", "nobody@example.com", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := s.UserExistsByEmail(tt.email); got != tt.exists {
+			if got := must(s.UserExistsByEmail(context.Background(), tt.email)); got != tt.exists {
 				t.Errorf("UserExistsByEmail(%q) = %v, want %v", tt.email, got, tt.exists)
 			}
 		})
@@ -109,7 +217,9 @@ func TestStore_UserExistsByEmail(t *testing.T) {
 }
 
 func TestStore_GetTasks(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	tests := []struct {
 		name      string
@@ -127,7 +237,7 @@ func TestStore_GetTasks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tasks := s.GetTasks(tt.status, tt.userID)
+			tasks := must(s.GetTasks(context.Background(), tt.status, tt.userID))
 			if len(tasks) != tt.wantCount {
 				t.Errorf("expected %d tasks, got %d", tt.wantCount, len(tasks))
 			}
@@ -135,8 +245,60 @@ func TestStore_GetTasks(t *testing.T) {
 	}
 }
 
+func TestStore_Indexes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+	snap := must(s.Snapshot(ctx))
+	taskIDs := func(userID string) []int {
+		var ids []int
+		for _, task := range must(s.GetTasks(ctx, "", userID)) {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	created := must(s.CreateTask(ctx, model.Task{Title: "Indexed", Status: "pending", UserID: 2}))
+	user := must(s.CreateUser(ctx, model.User{Name: "Indexed", Email: "Indexed@Example.com", Role: "qa"}))
+	if must(s.GetTaskByID(ctx, created.ID)) == nil || must(s.GetUserByID(ctx, user.ID)) == nil || !must(s.UserExistsByEmail(ctx, " indexed@example.com")) {
+		t.Fatal("expected the created task and user to be found")
+	}
+	if got := taskIDs("2"); !slices.Equal(got, []int{2, created.ID}) {
+		t.Errorf("expected user 2's tasks in store order, got %v", got)
+	}
+
+	// Reassigning moves a task between users, keeping their tasks in order
+	two := 2
+	s.UpdateTask(ctx, 1, model.TaskUpdate{UserID: &two})
+	if got := taskIDs("2"); !slices.Equal(got, []int{1, 2, created.ID}) {
+		t.Errorf("expected task 1 among user 2's tasks, got %v", got)
+	}
+	if got := taskIDs("1"); len(got) != 0 {
+		t.Errorf("expected user 1 to have no tasks, got %v", got)
+	}
+	if got := taskIDs("x"); len(got) != 0 {
+		t.Errorf("expected no tasks for an invalid user ID, got %v", got)
+	}
+
+	// Replacing all records rebuilds the indexes
+	s.Restore(ctx, snap)
+	if must(s.GetTaskByID(ctx, created.ID)) != nil || must(s.UserExistsByEmail(ctx, "indexed@example.com")) {
+		t.Error("expected the created task and user gone after restore")
+	}
+	if got := taskIDs("1"); !slices.Equal(got, []int{1}) {
+		t.Errorf("expected task 1 assigned to user 1 again, got %v", got)
+	}
+	s.ResetToSampleData()
+	if must(s.GetUserByID(ctx, 3)) == nil || len(taskIDs("3")) != 1 {
+		t.Error("expected the sample data indexed after a reset")
+	}
+}
+
 func TestStore_GetTaskByID(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	tests := []struct {
 		name      string
@@ -150,7 +312,7 @@ func TestStore_GetTaskByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			task := s.GetTaskByID(tt.id)
+			task := must(s.GetTaskByID(context.Background(), tt.id))
 
 			if tt.wantNil {
 				if task != nil {
@@ -170,31 +332,35 @@ func TestStore_GetTaskByID(t *testing.T) {
 }
 
 func TestStore_CreateTask(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
 
-	task := s.CreateTask("New task", "pending", 1)
+	s := newTestStore(t)
 
-	if task.ID != 3 {
-		t.Errorf("expected ID 3, got %d", task.ID)
+	task := must(s.CreateTask(context.Background(), model.Task{Title: "New task", Status: "pending", UserID: 1}))
+
+	if task.ID != testIDStart {
+		t.Errorf("expected ID %d, got %d", testIDStart, task.ID)
 	}
 	if task.Title != "New task" {
 		t.Errorf("expected title 'New task', got '%s'", task.Title)
 	}
 
 	// Verify task was added
-	tasks := s.GetTasks("", "")
+	tasks := must(s.GetTasks(context.Background(), "", ""))
 	if len(tasks) != 3 {
 		t.Errorf("expected 3 tasks after creation, got %d", len(tasks))
 	}
 }
 
 func TestStore_UpdateTask(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	newTitle := "Updated task"
 	newStatus := "completed"
 
-	task := s.UpdateTask(1, &newTitle, &newStatus, nil)
+	task := must(s.UpdateTask(context.Background(), 1, model.TaskUpdate{Title: &newTitle, Status: &newStatus}))
 
 	if task == nil {
 		t.Fatal("expected task, got nil")
@@ -211,11 +377,81 @@ func TestStore_UpdateTask(t *testing.T) {
 	}
 }
 
+func TestStore_TaskDueAt(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+	due := time.Date(2026, 3, 10, 9, 0, 0, 0, tokyo)
+	task := must(s.CreateTask(context.Background(), model.Task{Title: "Due", Status: "pending", UserID: 1, DueAt: &due}))
+
+	if task.DueAt == nil || task.DueAt.Location() != time.UTC || !task.DueAt.Equal(due) {
+		t.Errorf("expected the due date stored in UTC, got %v", task.DueAt)
+	}
+
+	later := due.Add(24 * time.Hour)
+	updated := must(s.UpdateTask(context.Background(), task.ID, model.TaskUpdate{DueAt: &later}))
+	if updated.DueAt == nil || !updated.DueAt.Equal(later) {
+		t.Errorf("expected the due date to move, got %v", updated.DueAt)
+	}
+
+	title := "Still due"
+	updated = must(s.UpdateTask(context.Background(), task.ID, model.TaskUpdate{Title: &title}))
+	if updated.DueAt == nil {
+		t.Error("expected an update without a due date to keep it")
+	}
+
+	updated = must(s.UpdateTask(context.Background(), task.ID, model.TaskUpdate{DueAt: &time.Time{}}))
+	if updated.DueAt != nil {
+		t.Errorf("expected a zero due date to clear it, got %v", updated.DueAt)
+	}
+}
+
+func TestStore_TaskAssignmentAndCompletion(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	task := must(s.CreateTask(ctx, model.Task{Title: "Tracked", Status: "pending", UserID: 1}))
+	if !task.AssignedAt.Equal(task.CreatedAt) || task.CompletedAt != nil {
+		t.Fatalf("expected a new task assigned at creation and not completed, got %+v", task)
+	}
+
+	sameUser, otherUser := 1, 2
+	if updated := must(s.UpdateTask(ctx, task.ID, model.TaskUpdate{UserID: &sameUser})); !updated.AssignedAt.Equal(task.AssignedAt) {
+		t.Error("expected reassigning to the same user to keep the assignment time")
+	}
+	if updated := must(s.UpdateTask(ctx, task.ID, model.TaskUpdate{UserID: &otherUser})); !updated.AssignedAt.After(task.AssignedAt) {
+		t.Error("expected reassigning to another user to update the assignment time")
+	}
+
+	completed, pending := "completed", "pending"
+	done := must(s.UpdateTask(ctx, task.ID, model.TaskUpdate{Status: &completed}))
+	if done.CompletedAt == nil {
+		t.Fatal("expected completing a task to set its completion time")
+	}
+	first := *done.CompletedAt
+	if again := must(s.UpdateTask(ctx, task.ID, model.TaskUpdate{Status: &completed})); again.CompletedAt == nil || !again.CompletedAt.Equal(first) {
+		t.Error("expected completing a completed task to keep its completion time")
+	}
+	if reopened := must(s.UpdateTask(ctx, task.ID, model.TaskUpdate{Status: &pending})); reopened.CompletedAt != nil {
+		t.Error("expected reopening a task to clear its completion time")
+	}
+
+	if created := must(s.CreateTask(ctx, model.Task{Title: "Done", Status: "completed", UserID: 1})); created.CompletedAt == nil {
+		t.Error("expected a task created completed to have a completion time")
+	}
+}
+
 func TestStore_UpdateTask_NotFound(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	newTitle := "Updated"
-	task := s.UpdateTask(999, &newTitle, nil, nil)
+	task := must(s.UpdateTask(context.Background(), 999, model.TaskUpdate{Title: &newTitle}))
 
 	if task != nil {
 		t.Errorf("expected nil for non-existent task, got %+v", task)
@@ -223,9 +459,11 @@ func TestStore_UpdateTask_NotFound(t *testing.T) {
 }
 
 func TestStore_GetStats(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
 
-	stats := s.GetStats()
+	s := newTestStore(t)
+
+	stats := s.GetStats(context.Background())
 
 	if stats.Users.Total != 2 {
 		t.Errorf("expected 2 users, got %d", stats.Users.Total)
@@ -242,7 +480,9 @@ func TestStore_GetStats(t *testing.T) {
 }
 
 func TestStore_ConcurrentAccess(t *testing.T) {
-	s := newTestStore()
+	t.Parallel()
+
+	s := newTestStore(t)
 
 	var wg sync.WaitGroup
 	iterations := 100
@@ -252,9 +492,13 @@ func TestStore_ConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			_ = s.GetUsers()
-			_ = s.GetTasks("", "")
-			_ = s.GetStats()
+			_ = must(s.GetUsers(context.Background()))
+			_ = must(s.GetTasks(context.Background(), "", ""))
+			_ = s.GetStats(context.Background())
+			// Records returned are copies, which writes do not touch
+			if task := must(s.GetTaskByID(context.Background(), 1)); task != nil {
+				_ = task.Title
+			}
 		}()
 	}
 
@@ -263,9 +507,1057 @@ func TestStore_ConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			s.CreateUser("Test User", "test@example.com", "tester")
+			s.CreateUser(context.Background(), model.User{Name: "Test User", Email: "test@example.com", Role: "tester"})
+			title := fmt.Sprintf("Title %d", i)
+			s.UpdateTask(context.Background(), 1, model.TaskUpdate{Title: &title})
 		}(i)
 	}
 
 	wg.Wait()
 }
+
+func TestStore_CopyOnRead(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+	due := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	tags := []string{"api"}
+	created := must(s.CreateTask(ctx, model.Task{Title: "Copied", Status: "pending", UserID: 1, Tags: tags, DueAt: &due}))
+	webhook := must(s.CreateWebhook(ctx, model.Webhook{URL: "https://example.com/hook", Events: []string{"task.created"}}))
+	s.RecordAudit(ctx, model.AuditEvent{Action: "update", Entity: "task", Changes: []model.FieldChange{{Field: "title"}}})
+
+	// Change everything read and written
+	must(s.GetUsers(ctx))[0].Name = "Changed"
+	must(s.GetUserByID(ctx, 1)).Email = "changed@example.com"
+	must(s.GetTaskByID(ctx, created.ID)).Title = "Changed"
+	must(s.GetTasks(ctx, "", ""))[2].Tags[0] = "changed"
+	*must(s.GetTaskByID(ctx, created.ID)).DueAt = time.Time{}
+	created.Tags[0] = "changed"
+	tags[0] = "changed"
+	must(s.UpdateTask(ctx, created.ID, model.TaskUpdate{})).BlockedBy = []int{1}
+	webhook.Events[0] = "changed"
+	s.GetWebhooks(ctx)[0].Events[0] = "changed"
+	s.GetAuditEvents(ctx, model.AuditFilter{})[0].Changes[0].Field = "changed"
+	must(s.Snapshot(ctx)).Tasks()[2].Tags[0] = "changed"
+
+	if user := must(s.GetUserByID(ctx, 1)); user.Name != "John Doe" || user.Email != "john@example.com" || !must(s.UserExistsByEmail(ctx, "john@example.com")) {
+		t.Errorf("expected user 1 unchanged, got %+v", user)
+	}
+	task := must(s.GetTaskByID(ctx, created.ID))
+	if task.Title != "Copied" || task.Tags[0] != "api" || !task.DueAt.Equal(due) || task.BlockedBy != nil {
+		t.Errorf("expected the task unchanged, got %+v", task)
+	}
+	if events := s.GetWebhookByID(ctx, webhook.ID).Events; events[0] != "task.created" {
+		t.Errorf("expected the webhook's events unchanged, got %v", events)
+	}
+	if changes := s.GetAuditEvents(ctx, model.AuditFilter{})[0].Changes; changes[0].Field != "title" {
+		t.Errorf("expected the audit event unchanged, got %+v", changes)
+	}
+}
+
+func TestStore_Sandbox(t *testing.T) {
+	t.Parallel()
+
+	s := NewSandbox()
+
+	if !s.ephemeral {
+		t.Fatal("expected sandbox store to be ephemeral")
+	}
+
+	initialUsers := len(must(s.GetUsers(context.Background())))
+	s.CreateUser(context.Background(), model.User{Name: "Sandbox User", Email: "sandbox@example.com", Role: "tester"})
+	if len(must(s.GetUsers(context.Background()))) != initialUsers+1 {
+		t.Fatalf("expected %d users after creation, got %d", initialUsers+1, len(must(s.GetUsers(context.Background()))))
+	}
+
+	s.ResetToSampleData()
+
+	if len(must(s.GetUsers(context.Background()))) != initialUsers {
+		t.Errorf("expected %d users after reset, got %d", initialUsers, len(must(s.GetUsers(context.Background()))))
+	}
+	if must(s.UserExistsByEmail(context.Background(), "sandbox@example.com")) {
+		t.Error("expected sandbox user to be removed by reset")
+	}
+}
+
+func TestStore_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+
+	snap := must(s.Snapshot(context.Background()))
+
+	newTitle := "Changed"
+	s.UpdateTask(context.Background(), 1, model.TaskUpdate{Title: &newTitle})
+	s.CreateUser(context.Background(), model.User{Name: "Temp User", Email: "temp@example.com", Role: "tester"})
+	s.CreateTask(context.Background(), model.Task{Title: "Temp task", Status: "pending", UserID: 1})
+
+	s.Restore(context.Background(), snap)
+
+	if got := len(must(s.GetUsers(context.Background()))); got != 2 {
+		t.Errorf("expected 2 users after restore, got %d", got)
+	}
+	if got := len(must(s.GetTasks(context.Background(), "", ""))); got != 2 {
+		t.Errorf("expected 2 tasks after restore, got %d", got)
+	}
+	if got := must(s.GetTaskByID(context.Background(), 1)).Title; got != "Test task 1" {
+		t.Errorf("expected original title 'Test task 1', got '%s'", got)
+	}
+
+	// The snapshot must remain usable after further changes
+	s.UpdateTask(context.Background(), 1, model.TaskUpdate{Title: &newTitle})
+	s.Restore(context.Background(), snap)
+
+	if got := must(s.GetTaskByID(context.Background(), 1)).Title; got != "Test task 1" {
+		t.Errorf("expected original title after second restore, got '%s'", got)
+	}
+}
+
+func TestSnapshot_WithPasswordHashesFrom(t *testing.T) {
+	t.Parallel()
+
+	snap := NewSnapshot([]model.User{
+		{ID: 1, Name: "Same", Email: "same@example.com", Role: "developer"},
+		{ID: 2, Name: "Other", Email: "other@example.com", Role: "developer"},
+		{ID: 3, Name: "Own", Email: "own@example.com", Role: "developer", PasswordHash: "backup-hash"},
+		{ID: 4, Name: "New", Email: "new@example.com", Role: "developer"},
+	}, nil)
+	current := []model.User{
+		{ID: 1, Email: "same@example.com", PasswordHash: "hash-1"},
+		{ID: 2, Email: "replaced@example.com", PasswordHash: "hash-2"},
+		{ID: 3, Email: "own@example.com", PasswordHash: "hash-3"},
+	}
+
+	users := snap.WithPasswordHashesFrom(current).Users()
+	want := []string{"hash-1", "", "backup-hash", ""}
+	for i, user := range users {
+		if user.PasswordHash != want[i] {
+			t.Errorf("user %d: expected hash %q, got %q", user.ID, want[i], user.PasswordHash)
+		}
+	}
+	if snap.Users()[0].PasswordHash != "" {
+		t.Error("expected the original snapshot unchanged")
+	}
+}
+
+func TestStore_PersistRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "data.json")
+
+	s := InitializeFrom(path)
+	user := must(s.CreateUser(context.Background(), model.User{Name: "Persisted User", Email: "persisted@example.com", Role: "developer", PasswordHash: "hash"}))
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+
+	got := must(reloaded.GetUserByID(context.Background(), user.ID))
+	if got == nil {
+		t.Fatalf("expected user %d after reload, got nil", user.ID)
+	}
+	if got.Email != "persisted@example.com" {
+		t.Errorf("expected email 'persisted@example.com', got '%s'", got.Email)
+	}
+	if got.PasswordHash != "hash" {
+		t.Errorf("expected password hash to be persisted, got '%s'", got.PasswordHash)
+	}
+}
+
+func TestStore_CoalescesBurstWrites(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	s := InitializeFrom(path)
+	s.SetPersistDelay(50 * time.Millisecond)
+
+	const burst = 1000
+	for i := 0; i < burst; i++ {
+		s.CreateTask(context.Background(), model.Task{Title: "Burst task", Status: "pending", UserID: 1})
+	}
+	s.WaitForPersistence()
+
+	if writes := s.writes.Load(); writes < 1 || writes > 5 {
+		t.Errorf("expected a burst of %d creates to be coalesced into a few writes, got %d", burst, writes)
+	}
+
+	reloaded := InitializeFrom(path)
+	if got, want := len(must(reloaded.GetTasks(context.Background(), "", ""))), len(must(s.GetTasks(context.Background(), "", ""))); got != want {
+		t.Errorf("expected %d tasks after reload, got %d", want, got)
+	}
+}
+
+func TestStore_JournalAppendsChanges(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	s := InitializeFrom(path)
+	s.CreateTask(context.Background(), model.Task{Title: "Before journal", Status: "pending", UserID: 1})
+	s.WaitForPersistence() // first write creates the data file
+
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read data file: %v", err)
+	}
+
+	task := must(s.CreateTask(context.Background(), model.Task{Title: "Journaled task", Status: "pending", UserID: 1}))
+	completed := "completed"
+	s.UpdateTask(context.Background(), task.ID, model.TaskUpdate{Status: &completed})
+	s.WaitForPersistence()
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read data file: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Error("expected changes to be appended to the journal, not rewrite the data file")
+	}
+
+	got := must(InitializeFrom(path).GetTaskByID(context.Background(), task.ID))
+	if got == nil || got.Status != "completed" {
+		t.Errorf("expected journaled task with status 'completed' after reload, got %+v", got)
+	}
+}
+
+func TestStore_VerifyPersisted(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	s.SetPersistDelay(time.Hour)
+	if diffs, err := s.VerifyPersisted(ctx); err != nil || len(diffs) != 0 {
+		t.Fatalf("expected nothing to verify before the first write, got %v %v", diffs, err)
+	}
+	if err := s.Persist(ctx); err != nil {
+		t.Fatalf("persist failed: %v", err)
+	}
+
+	// Changes waiting to be written are not discrepancies
+	s.CreateTask(ctx, model.Task{Title: "Unsaved", Status: "pending", UserID: 1})
+	if diffs, err := s.VerifyPersisted(ctx); err != nil || len(diffs) != 0 {
+		t.Errorf("expected pending changes to be allowed for, got %v %v", diffs, err)
+	}
+
+	// A journal entry the store never made is
+	tampered := *must(s.GetTaskByID(ctx, 1))
+	tampered.Title = "Tampered"
+	if err := appendJournal(path+JournalSuffix, []journalEntry{{Op: opPutTask, Task: &tampered}}); err != nil {
+		t.Fatalf("failed to write journal: %v", err)
+	}
+	diffs, err := s.VerifyPersisted(ctx)
+	if want := []string{"task 1 is saved with different values"}; err != nil || !slices.Equal(diffs, want) {
+		t.Errorf("expected %v, got %v %v", want, diffs, err)
+	}
+
+	if diffs, _ := New().VerifyPersisted(ctx); diffs != nil {
+		t.Errorf("expected an ephemeral store to have nothing saved, got %v", diffs)
+	}
+}
+
+func TestStore_Shares(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	expires := time.Now().Add(time.Hour)
+	kept := must(s.CreateShare(ctx, model.Share{ID: "kept", TaskID: 1, TokenHash: "hash1", ExpiresAt: expires}))
+	s.CreateShare(ctx, model.Share{ID: "revoked", TaskID: 1, TokenHash: "hash2", ExpiresAt: expires})
+	if kept.CreatedAt.IsZero() {
+		t.Error("expected the store to set the creation time")
+	}
+	if must(s.DeleteShare(ctx, 2, "revoked")) {
+		t.Error("expected a share not to be deleted through another task")
+	}
+	if !must(s.DeleteShare(ctx, 1, "revoked")) {
+		t.Error("expected the share to be deleted")
+	}
+	s.WaitForPersistence()
+
+	checkReloaded := func(when string) {
+		t.Helper()
+		reloaded := InitializeFrom(path)
+		if got := reloaded.GetShares(ctx, 1); len(got) != 1 || got[0].ID != "kept" {
+			t.Fatalf("%s: expected only the kept share after reload, got %+v", when, got)
+		}
+		if got := reloaded.GetShareByTokenHash(ctx, "hash1"); got == nil || got.TokenHash != "hash1" {
+			t.Errorf("%s: expected to find the share by its token hash, got %+v", when, got)
+		}
+		if reloaded.GetShareByTokenHash(ctx, "hash2") != nil {
+			t.Errorf("%s: expected the revoked share to be gone", when)
+		}
+	}
+	checkReloaded("from the journal")
+	s.Persist(ctx)
+	checkReloaded("after compaction")
+}
+
+func TestStore_Blockers(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	a := must(s.CreateTask(ctx, model.Task{Title: "A", Status: "pending", UserID: 1}))
+	b := must(s.CreateTask(ctx, model.Task{Title: "B", Status: "pending", UserID: 1}))
+	c := must(s.CreateTask(ctx, model.Task{Title: "C", Status: "pending", UserID: 1}))
+
+	// A blocks B, which blocks C
+	if _, err := s.AddBlocker(ctx, b.ID, a.ID); err != nil {
+		t.Fatalf("failed to add blocker: %v", err)
+	}
+	if _, err := s.AddBlocker(ctx, c.ID, b.ID); err != nil {
+		t.Fatalf("failed to add blocker: %v", err)
+	}
+	if task, err := s.AddBlocker(ctx, c.ID, b.ID); err != nil || len(task.BlockedBy) != 1 {
+		t.Errorf("expected adding a blocker again to change nothing, got %+v, %v", task, err)
+	}
+
+	for _, tt := range []struct {
+		id, blockerID int
+		want          error
+	}{
+		{a.ID, a.ID, ErrDependencyCycle},
+		{a.ID, b.ID, ErrDependencyCycle},
+		{a.ID, c.ID, ErrDependencyCycle},
+		{a.ID, 9999, ErrTaskNotFound},
+		{9999, a.ID, ErrTaskNotFound},
+	} {
+		if _, err := s.AddBlocker(ctx, tt.id, tt.blockerID); !errors.Is(err, tt.want) {
+			t.Errorf("AddBlocker(%d, %d): expected %v, got %v", tt.id, tt.blockerID, tt.want, err)
+		}
+	}
+	if HasDependencyCycle(must(s.GetTasks(ctx, "", ""))) {
+		t.Error("expected no cycle")
+	}
+
+	if must(s.RemoveBlocker(ctx, c.ID, a.ID)) != nil {
+		t.Error("expected removing a blocker the task does not have to fail")
+	}
+	if task := must(s.RemoveBlocker(ctx, b.ID, a.ID)); task == nil || task.BlockedBy != nil {
+		t.Errorf("expected B to have no blockers, got %+v", task)
+	}
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+	if got := must(reloaded.GetTaskByID(ctx, c.ID)).BlockedBy; len(got) != 1 || got[0] != b.ID {
+		t.Errorf("expected C to still be blocked by B after reload, got %v", got)
+	}
+	if got := must(reloaded.GetTaskByID(ctx, b.ID)).BlockedBy; got != nil {
+		t.Errorf("expected B to have no blockers after reload, got %v", got)
+	}
+}
+
+func TestStore_Announcements(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	ends := time.Now().Add(time.Hour)
+	kept := must(s.CreateAnnouncement(ctx, model.Announcement{Message: "Maintenance", EndsAt: &ends}))
+	deleted := must(s.CreateAnnouncement(ctx, model.Announcement{Message: "Old news", Level: "warning"}))
+	if kept.ID == 0 || kept.Level != "info" || kept.CreatedAt.IsZero() {
+		t.Errorf("expected an ID, the default level and a creation time, got %+v", kept)
+	}
+
+	message := "Maintenance moved"
+	var noEnd *time.Time
+	if got := must(s.UpdateAnnouncement(ctx, kept.ID, model.AnnouncementUpdate{Message: &message, EndsAt: &noEnd})); got == nil || got.Message != message || got.EndsAt != nil {
+		t.Errorf("expected the message changed and the end cleared, got %+v", got)
+	}
+	if must(s.UpdateAnnouncement(ctx, 9999, model.AnnouncementUpdate{Message: &message})) != nil {
+		t.Error("expected updating an unknown announcement to fail")
+	}
+	if !must(s.DeleteAnnouncement(ctx, deleted.ID)) || must(s.DeleteAnnouncement(ctx, deleted.ID)) {
+		t.Error("expected the announcement to be deleted once")
+	}
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+	if got := reloaded.GetAnnouncements(ctx); len(got) != 1 || got[0].Message != message || got[0].EndsAt != nil {
+		t.Errorf("expected only the updated announcement after reload, got %+v", got)
+	}
+}
+
+func TestStore_Projects(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	used := must(s.CreateProject(ctx, model.Project{Name: "Backend"}))
+	unused := must(s.CreateProject(ctx, model.Project{Name: "Mobile"}))
+	task := must(s.CreateTask(ctx, model.Task{Title: "In a project", Status: "pending", UserID: 1, ProjectID: used.ID}))
+	if task.ProjectID != used.ID {
+		t.Fatalf("expected the task to be in project %d, got %d", used.ID, task.ProjectID)
+	}
+
+	name := "Platform"
+	if got := must(s.UpdateProject(ctx, used.ID, model.ProjectUpdate{Name: &name})); got == nil || got.Name != name {
+		t.Errorf("expected the project renamed, got %+v", got)
+	}
+	if err := s.DeleteProject(ctx, used.ID); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("expected ErrProjectInUse deleting a project with tasks, got %v", err)
+	}
+	if err := s.DeleteProject(ctx, unused.ID); err != nil {
+		t.Errorf("expected the empty project to be deleted, got %v", err)
+	}
+	if err := s.DeleteProject(ctx, unused.ID); !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("expected ErrProjectNotFound deleting it again, got %v", err)
+	}
+	none := 0
+	s.UpdateTask(ctx, task.ID, model.TaskUpdate{ProjectID: &none})
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+	if got := reloaded.GetProjects(ctx); len(got) != 1 || got[0].Name != name {
+		t.Errorf("expected only the renamed project after reload, got %+v", got)
+	}
+	if got := must(reloaded.GetTaskByID(ctx, task.ID)).ProjectID; got != 0 {
+		t.Errorf("expected the task to be in no project after reload, got %d", got)
+	}
+}
+
+func TestStore_AuditEvents(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	first := must(s.RecordAudit(ctx, model.AuditEvent{Actor: "ip:1.2.3.4", Action: "update", Entity: "task", EntityID: "1",
+		Changes: []model.FieldChange{{Field: "userId", Old: 1.0, New: 2.0}}}))
+	s.RecordAudit(ctx, model.AuditEvent{Actor: "ip:1.2.3.4", Action: "create", Entity: "user", EntityID: "4"})
+	third := must(s.RecordAudit(ctx, model.AuditEvent{Actor: "key:abcd****", Action: "update", Entity: "task", EntityID: "1"}))
+	if first.ID == 0 || first.At.IsZero() || third.ID <= first.ID {
+		t.Fatalf("expected increasing IDs and times to be set, got %+v and %+v", first, third)
+	}
+
+	if got := s.GetAuditEvents(ctx, model.AuditFilter{Entity: "task", EntityID: "1"}); len(got) != 2 || got[0].ID != third.ID {
+		t.Errorf("expected the task's two events, newest first, got %+v", got)
+	}
+	if got := s.GetAuditEvents(ctx, model.AuditFilter{Actor: "ip:1.2.3.4", Limit: 1}); len(got) != 1 || got[0].Entity != "user" {
+		t.Errorf("expected the actor's latest event, got %+v", got)
+	}
+	if got := s.GetAuditEvents(ctx, model.AuditFilter{Since: third.At.Add(time.Second)}); len(got) != 0 {
+		t.Errorf("expected no events after the last one, got %+v", got)
+	}
+
+	// Restoring a backup leaves the audit trail alone
+	s.Restore(ctx, must(s.Snapshot(ctx)))
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+	got := reloaded.GetAuditEvents(ctx, model.AuditFilter{})
+	if len(got) != 3 || got[2].Changes[0].Field != "userId" || got[2].Changes[0].New != 2.0 {
+		t.Errorf("expected all three events after reload, got %+v", got)
+	}
+}
+
+func TestStore_Webhooks(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	events := []string{"task.completed"}
+	kept := must(s.CreateWebhook(ctx, model.Webhook{URL: "https://hooks.example.com/a", Events: events, Secret: "secret-a"}))
+	deleted := must(s.CreateWebhook(ctx, model.Webhook{URL: "https://hooks.example.com/b", Events: events, Secret: "secret-b"}))
+	if kept.ID == 0 || kept.CreatedAt.IsZero() {
+		t.Errorf("expected an ID and a creation time, got %+v", kept)
+	}
+	events[0] = "changed"
+	if got := s.GetWebhookByID(ctx, kept.ID); got == nil || got.Events[0] != "task.completed" {
+		t.Errorf("expected the webhook not to share the caller's events, got %+v", got)
+	}
+	if !must(s.DeleteWebhook(ctx, deleted.ID)) || must(s.DeleteWebhook(ctx, deleted.ID)) {
+		t.Error("expected the webhook to be deleted once")
+	}
+
+	// Backups leave webhooks, and their secrets, out
+	s.Restore(ctx, must(s.Snapshot(ctx)))
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+	if got := reloaded.GetWebhooks(ctx); len(got) != 1 || got[0].ID != kept.ID || got[0].Secret != "secret-a" {
+		t.Errorf("expected only the kept webhook and its secret after reload, got %+v", got)
+	}
+}
+
+func TestStore_StatsSnapshots(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+	base := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	s := InitializeFrom(path)
+	// Recorded out of order, and once twice
+	s.RecordStatsSnapshot(ctx, model.StatsSnapshot{At: base.Add(2 * time.Hour), Tasks: model.TaskCounts{Total: 3}})
+	s.RecordStatsSnapshot(ctx, model.StatsSnapshot{At: base, Tasks: model.TaskCounts{Total: 1}})
+	s.RecordStatsSnapshot(ctx, model.StatsSnapshot{At: base.Add(time.Hour), Tasks: model.TaskCounts{Total: 9}})
+	s.RecordStatsSnapshot(ctx, model.StatsSnapshot{At: base.Add(time.Hour), Tasks: model.TaskCounts{Total: 2}})
+
+	if got := s.GetStatsSnapshots(ctx, base, base.Add(2*time.Hour)); len(got) != 2 || got[0].Tasks.Total != 1 || got[1].Tasks.Total != 2 {
+		t.Errorf("expected the first two snapshots, oldest first, got %+v", got)
+	}
+
+	// Restoring a backup leaves the stats history alone
+	s.Restore(ctx, must(s.Snapshot(ctx)))
+	s.WaitForPersistence()
+
+	reloaded := InitializeFrom(path)
+	got := reloaded.GetStatsSnapshots(ctx, base, base.Add(24*time.Hour))
+	if len(got) != 3 || got[1].Tasks.Total != 2 || got[2].Tasks.Total != 3 {
+		t.Errorf("expected all three snapshots after reload, got %+v", got)
+	}
+}
+
+func TestStore_TaskChanges(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	start := s.Revision(ctx)
+	created := must(s.CreateTask(ctx, model.Task{Title: "New", Status: "pending", UserID: 1}))
+	title := "Renamed"
+	s.UpdateTask(ctx, 2, model.TaskUpdate{Title: &title})
+
+	changes, err := s.TaskChanges(ctx, start)
+	if err != nil || changes.Revision != start+2 {
+		t.Fatalf("expected two changes since %d, got %+v, %v", start, changes, err)
+	}
+	if !slices.Equal(changes.Created, []int{created.ID}) || !slices.Equal(changes.Updated, []int{2}) || len(changes.Deleted) != 0 {
+		t.Errorf("expected task %d created and task 2 updated, got %+v", created.ID, changes)
+	}
+	if changes, _ := s.TaskChanges(ctx, changes.Revision); len(changes.Created)+len(changes.Updated)+len(changes.Deleted) != 0 {
+		t.Errorf("expected no changes since the current revision, got %+v", changes)
+	}
+
+	// Restoring a backup taken before the new task deletes it
+	snap := must(s.Snapshot(ctx))
+	s.CreateTask(ctx, model.Task{Title: "Gone", Status: "pending", UserID: 1})
+	before := s.Revision(ctx)
+	s.Restore(ctx, snap)
+	changes, _ = s.TaskChanges(ctx, before)
+	if len(changes.Deleted) != 1 || len(changes.Created) != len(snap.tasks) {
+		t.Errorf("expected one task deleted and the backup's tasks created, got %+v", changes)
+	}
+
+	if _, err := s.TaskChanges(ctx, s.Revision(ctx)+1); !errors.Is(err, ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision for a future revision, got %v", err)
+	}
+
+	// Revisions continue after a reload, but earlier changes are forgotten
+	s.WaitForPersistence()
+	reloaded := InitializeFrom(path)
+	if reloaded.Revision(ctx) != s.Revision(ctx) {
+		t.Errorf("expected revision %d after reload, got %d", s.Revision(ctx), reloaded.Revision(ctx))
+	}
+	if _, err := reloaded.TaskChanges(ctx, start); !errors.Is(err, ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision for a revision before the reload, got %v", err)
+	}
+}
+
+func TestStore_JournalIgnoresTornWrite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	s := InitializeFrom(path)
+	s.CreateTask(context.Background(), model.Task{Title: "First", Status: "pending", UserID: 1})
+	s.WaitForPersistence()
+	task := must(s.CreateTask(context.Background(), model.Task{Title: "Second", Status: "pending", UserID: 1}))
+	s.WaitForPersistence()
+
+	// Simulate a crash part way through appending an entry
+	f, err := os.OpenFile(path+JournalSuffix, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	f.WriteString(`{"op":"putTask","task":{"id":`)
+	f.Close()
+
+	reloaded := InitializeFrom(path)
+	if must(reloaded.GetTaskByID(context.Background(), task.ID)) == nil {
+		t.Errorf("expected task %d before the torn entry to be replayed", task.ID)
+	}
+
+	// The torn entry is cut off, so entries appended later replay too
+	later := must(reloaded.CreateTask(context.Background(), model.Task{Title: "Later", Status: "pending", UserID: 1}))
+	reloaded.WaitForPersistence()
+	if must(InitializeFrom(path).GetTaskByID(context.Background(), later.ID)) == nil {
+		t.Errorf("expected task %d appended after the torn entry to be replayed", later.ID)
+	}
+}
+
+func TestStore_JournalKeepsCorruptEntries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	s.CreateTask(ctx, model.Task{Title: "Compacted", Status: "pending", UserID: 1})
+	s.WaitForPersistence()
+	first := must(s.CreateTask(ctx, model.Task{Title: "First", Status: "pending", UserID: 1}))
+	s.WaitForPersistence()
+
+	// Corrupt an entry with another one after it
+	f, err := os.OpenFile(path+JournalSuffix, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	f.WriteString("{garbage\n")
+	f.Close()
+	lost := must(s.CreateTask(ctx, model.Task{Title: "Lost", Status: "pending", UserID: 1}))
+	s.WaitForPersistence()
+
+	_, err = replayJournal(path+JournalSuffix, &PersistentData{})
+	if !errors.Is(err, ErrCorruptJournal) {
+		t.Fatalf("expected ErrCorruptJournal, got %v", err)
+	}
+
+	reloaded := InitializeFrom(path)
+	if must(reloaded.GetTaskByID(ctx, first.ID)) == nil {
+		t.Errorf("expected task %d before the corrupt entry to be replayed", first.ID)
+	}
+	if _, err := os.Stat(path + JournalSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the corrupt journal to be moved aside, got %v", err)
+	}
+	kept, _ := filepath.Glob(path + JournalSuffix + ".*.corrupt")
+	if len(kept) != 1 {
+		t.Fatalf("expected the corrupt journal to be kept, got %v", kept)
+	}
+	data, err := os.ReadFile(kept[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"title":"Lost"`) {
+		t.Errorf("expected task %d after the corrupt entry to be kept, got %s", lost.ID, data)
+	}
+
+	// Compacting saves what was replayed without touching the kept journal
+	reloaded.CreateTask(ctx, model.Task{Title: "After", Status: "pending", UserID: 1})
+	reloaded.WaitForPersistence()
+	if must(InitializeFrom(path).GetTaskByID(ctx, first.ID)) == nil {
+		t.Errorf("expected task %d after compaction", first.ID)
+	}
+	if _, err := os.Stat(kept[0]); err != nil {
+		t.Errorf("expected the corrupt journal to survive compaction, got %v", err)
+	}
+}
+
+func TestStore_JournalCompacts(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	s := InitializeFrom(path)
+	s.SetCompactAfter(3)
+
+	var last model.Task
+	for i := 0; i < 5; i++ {
+		last = must(s.CreateTask(context.Background(), model.Task{Title: "Task", Status: "pending", UserID: 1}))
+		s.WaitForPersistence()
+	}
+
+	if _, err := os.Stat(path + JournalSuffix); err == nil {
+		data, _ := os.ReadFile(path + JournalSuffix)
+		if lines := strings.Count(string(data), "\n"); lines > 3 {
+			t.Errorf("expected journal to be compacted to at most 3 entries, got %d", lines)
+		}
+	}
+
+	if must(InitializeFrom(path).GetTaskByID(context.Background(), last.ID)) == nil {
+		t.Errorf("expected task %d after compaction and reload", last.ID)
+	}
+}
+
+func TestStore_JournalRetriesFailedWrites(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	ctx := context.Background()
+
+	s := InitializeFrom(path)
+	s.CreateTask(ctx, model.Task{Title: "First", Status: "pending", UserID: 1})
+	s.WaitForPersistence()
+
+	// A directory where the journal goes makes appending to it fail
+	os.Remove(path + JournalSuffix)
+	if err := os.Mkdir(path+JournalSuffix, 0755); err != nil {
+		t.Fatal(err)
+	}
+	failed := must(s.CreateTask(ctx, model.Task{Title: "Failed", Status: "pending", UserID: 1}))
+	s.WaitForPersistence()
+	if s.PersistError() == nil {
+		t.Fatal("expected the journal write to fail")
+	}
+
+	// The failed write is retried without waiting for another change
+	os.Remove(path + JournalSuffix)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.PersistError() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.PersistError(); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	retried := must(s.CreateTask(ctx, model.Task{Title: "Retried", Status: "pending", UserID: 1}))
+	s.WaitForPersistence()
+	reloaded := InitializeFrom(path)
+	for _, task := range []model.Task{failed, retried} {
+		if must(reloaded.GetTaskByID(ctx, task.ID)) == nil {
+			t.Errorf("expected task %d after the failed write was retried", task.ID)
+		}
+	}
+
+	// A non-empty directory where the data file goes makes compacting fail
+	if err := os.Rename(path, path+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Persist(ctx); err == nil {
+		t.Fatal("expected compacting to fail")
+	}
+
+	os.RemoveAll(path)
+	compacted := must(s.CreateTask(ctx, model.Task{Title: "Compacted", Status: "pending", UserID: 1}))
+	s.WaitForPersistence()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the failed compaction to be retried, got %v", err)
+	}
+	reloaded = InitializeFrom(path)
+	for _, task := range []model.Task{failed, retried, compacted} {
+		if must(reloaded.GetTaskByID(ctx, task.ID)) == nil {
+			t.Errorf("expected task %d after the failed compaction was retried", task.ID)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetrics()
+	s := Instrument(newTestStore(t), metrics)
+	ctx := context.Background()
+
+	s.GetUsers(ctx)
+	s.GetTasks(ctx, "pending", "")
+	s.GetTaskByID(ctx, 99)
+	s.CreateTask(ctx, model.Task{Title: "Counted", Status: "pending", UserID: 1})
+	s.EachUserStats(ctx, func(model.UserStats) bool { return true })
+
+	stats := metrics.Stats()
+	if stats.Reads != 4 || stats.Writes != 1 {
+		t.Errorf("expected 4 reads and 1 write, got %+v", stats)
+	}
+	want := map[string]string{"CreateTask": "write 1", "EachUserStats": "read 2", "GetTaskByID": "read 0", "GetTasks": "read 1", "GetUsers": "read 2"}
+	for _, op := range stats.Operations {
+		if got := fmt.Sprintf("%s %d", op.Kind, op.Records); got != want[op.Op] || op.Count != 1 {
+			t.Errorf("expected %s to count %q once, got %q %d times", op.Op, want[op.Op], got, op.Count)
+		}
+		delete(want, op.Op)
+	}
+	if len(want) > 0 {
+		t.Errorf("expected operations %v counted", want)
+	}
+
+	if _, ok := Unwrap(s).(FileBacked); !ok {
+		t.Error("expected Unwrap to return the file-backed store")
+	}
+}
+
+// newLargeStore returns an in-memory Store with users users and tasks
+// tasks spread evenly across them.
+func newLargeStore(users, tasks int) *Store {
+	userList := make([]model.User, users)
+	for i := range userList {
+		userList[i] = model.User{ID: i + 1, Name: fmt.Sprintf("User %d", i+1), Email: fmt.Sprintf("user%d@example.com", i+1), Role: "developer"}
+	}
+	taskList := make([]model.Task, tasks)
+	for i := range taskList {
+		taskList[i] = model.Task{ID: i + 1, Title: fmt.Sprintf("Task %d", i+1), Status: "pending", UserID: i%users + 1}
+	}
+	s := NewWithData(userList, taskList)
+	s.ephemeral = true
+	return s
+}
+
+func BenchmarkStore_GetTaskByID(b *testing.B) {
+	s := newLargeStore(1_000, 50_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.GetTaskByID(context.Background(), i%50_000+1)
+	}
+}
+
+func BenchmarkStore_GetUserByID(b *testing.B) {
+	s := newLargeStore(20_000, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.GetUserByID(context.Background(), i%20_000+1)
+	}
+}
+
+func BenchmarkStore_UserExistsByEmail(b *testing.B) {
+	s := newLargeStore(20_000, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.UserExistsByEmail(context.Background(), "user19999@example.com")
+	}
+}
+
+func BenchmarkStore_GetTasksByUser(b *testing.B) {
+	s := newLargeStore(1_000, 50_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.GetTasks(context.Background(), "", strconv.Itoa(i%1_000+1))
+	}
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	s.SetPasswordHash(context.Background(), 1, "hash")
+	s.CreateShare(context.Background(), model.Share{ID: "share", TaskID: 1, TokenHash: "token hash", ExpiresAt: time.Now()})
+	s.CreateAnnouncement(context.Background(), model.Announcement{Message: "Maintenance tonight"})
+
+	taken := must(s.Snapshot(context.Background()))
+	if taken.Revision() != s.Revision(context.Background()) || taken.Revision() == 0 {
+		t.Fatalf("expected the snapshot taken at revision %d, got %d", s.Revision(context.Background()), taken.Revision())
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBackup(&buf, taken); err != nil {
+		t.Fatalf("WriteBackup failed: %v", err)
+	}
+
+	snap, err := ReadBackup(&buf)
+	if err != nil {
+		t.Fatalf("ReadBackup failed: %v", err)
+	}
+
+	if len(snap.Users()) != 2 || len(snap.Tasks()) != 2 {
+		t.Fatalf("expected 2 users and 2 tasks, got %d and %d", len(snap.Users()), len(snap.Tasks()))
+	}
+	if got := snap.Users()[0].PasswordHash; got != "hash" {
+		t.Errorf("expected password hash to survive the backup, got %q", got)
+	}
+	if got := snap.Shares(); len(got) != 1 || got[0].TokenHash != "token hash" {
+		t.Errorf("expected the share and its token hash to survive the backup, got %+v", got)
+	}
+	if got := snap.Announcements(); len(got) != 1 || got[0].Message != "Maintenance tonight" {
+		t.Errorf("expected the announcement to survive the backup, got %+v", got)
+	}
+	if snap.Revision() != taken.Revision() {
+		t.Errorf("expected revision %d to survive the backup, got %d", taken.Revision(), snap.Revision())
+	}
+}
+
+func TestNewPostgres_InvalidURL(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPostgres(context.Background(), PostgresConfig{URL: "postgres://localhost:notaport/db"})
+	if err == nil {
+		t.Fatal("expected an error for an invalid database URL")
+	}
+}
+
+func TestMigrateDataFile_UpAndDown(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	// A data file and journal written before tasks had a creation time
+	v0 := `{"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev"}],"tasks":[{"id":1,"title":"Old","status":"pending","userId":1}]}`
+	if err := os.WriteFile(path, []byte(v0), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+	journal := `{"op":"putTask","task":{"id":2,"title":"Journaled","status":"pending","userId":1}}` + "\n"
+	if err := os.WriteFile(path+JournalSuffix, []byte(journal), 0644); err != nil {
+		t.Fatalf("failed to write journal: %v", err)
+	}
+
+	s := InitializeFrom(path)
+
+	for _, task := range must(s.GetTasks(context.Background(), "", "")) {
+		if task.CreatedAt.IsZero() {
+			t.Errorf("expected task %d to be given a creation time", task.ID)
+		}
+	}
+	if _, err := os.Stat(path + JournalSuffix); !os.IsNotExist(err) {
+		t.Error("expected the old journal to be folded into the migrated file")
+	}
+	data, _ := os.ReadFile(path)
+	if want := fmt.Sprintf(`"schemaVersion": %d`, LatestSchemaVersion()); !strings.Contains(string(data), want) {
+		t.Errorf("expected the data file to record the latest schema version, got %s", data)
+	}
+
+	from, err := MigrateDataFile(path, 0)
+	if err != nil || from != LatestSchemaVersion() {
+		t.Fatalf("expected to migrate down from version %d, got %d, %v", LatestSchemaVersion(), from, err)
+	}
+	data, _ = os.ReadFile(path)
+	if strings.Contains(string(data), "createdAt") || !strings.Contains(string(data), `"schemaVersion": 0`) {
+		t.Errorf("expected version 0 without creation times, got %s", data)
+	}
+}
+
+func TestMigrateDataFile_DropsDueDates(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	v2 := `{"schemaVersion":2,"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev","timezone":"Europe/Berlin"}],` +
+		`"tasks":[{"id":1,"title":"Due","status":"pending","userId":1,"createdAt":"2026-01-01T00:00:00Z","dueAt":"2026-03-09T04:00:00Z"}]}`
+	if err := os.WriteFile(path, []byte(v2), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	if _, err := MigrateDataFile(path, 1); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "dueAt") || strings.Contains(string(data), "timezone") || !strings.Contains(string(data), "createdAt") {
+		t.Errorf("expected version 1 without due dates or time zones, got %s", data)
+	}
+}
+
+func TestMigrateDataFile_DigestFields(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	v2 := `{"schemaVersion":2,"users":[{"id":1,"name":"A","email":"a@example.com","role":"dev"}],` +
+		`"tasks":[{"id":1,"title":"Old","status":"completed","userId":1,"createdAt":"2026-01-01T00:00:00Z"}]}`
+	if err := os.WriteFile(path, []byte(v2), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	s := InitializeFrom(path)
+	task := must(s.GetTaskByID(context.Background(), 1))
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !task.AssignedAt.Equal(want) || task.CompletedAt != nil {
+		t.Errorf("expected the task assigned at creation with no known completion time, got %+v", task)
+	}
+	s.SetPreferences(context.Background(), 1, model.UserPreferences{DigestOptOut: true})
+	if err := s.Persist(context.Background()); err != nil {
+		t.Fatalf("failed to persist: %v", err)
+	}
+
+	if _, err := MigrateDataFile(path, 2); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "assignedAt") || strings.Contains(string(data), "preferences") {
+		t.Errorf("expected version 2 without assignment times or preferences, got %s", data)
+	}
+}
+
+func TestMigrateDataFile_NormalizesEmails(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	v3 := `{"schemaVersion":3,"users":[{"id":1,"name":"A","email":" Ann@Example.com","role":"developer"}],"tasks":[]}`
+	if err := os.WriteFile(path, []byte(v3), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	s := InitializeFrom(path)
+	if got := must(s.GetUserByID(context.Background(), 1)).Email; got != "ann@example.com" {
+		t.Errorf("expected the email to be normalized, got %q", got)
+	}
+	if created := must(s.CreateUser(context.Background(), model.User{Name: "B", Email: "Bob@Example.com", Role: "developer"})); created.Email != "bob@example.com" {
+		t.Errorf("expected new emails to be normalized, got %q", created.Email)
+	}
+
+	clash := filepath.Join(t.TempDir(), "data.json")
+	v3 = `{"schemaVersion":3,"users":[{"id":1,"name":"A","email":"ann@example.com","role":"developer"},` +
+		`{"id":2,"name":"B","email":"ANN@example.com","role":"developer"}],"tasks":[]}`
+	if err := os.WriteFile(clash, []byte(v3), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+	if _, err := MigrateDataFile(clash, LatestSchemaVersion()); err == nil || !strings.Contains(err.Error(), "ann@example.com") {
+		t.Errorf("expected emails differing only in case to fail the migration, got %v", err)
+	}
+}
+
+func TestMigrateDataFile_TaskPriority(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	v4 := `{"schemaVersion":4,"users":[{"id":1,"name":"A","email":"a@example.com","role":"developer"}],` +
+		`"tasks":[{"id":1,"title":"Old","status":"pending","userId":1,"createdAt":"2026-01-01T00:00:00Z"}]}`
+	if err := os.WriteFile(path, []byte(v4), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	s := InitializeFrom(path)
+	if got := must(s.GetTaskByID(context.Background(), 1)).Priority; got != "medium" {
+		t.Errorf("expected existing tasks to get the default priority, got %q", got)
+	}
+	if err := s.Persist(context.Background()); err != nil {
+		t.Fatalf("failed to persist: %v", err)
+	}
+
+	if _, err := MigrateDataFile(path, 4); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "priority") {
+		t.Errorf("expected version 4 without priorities, got %s", data)
+	}
+}
+
+func TestMigrateDataFile_RejectsNewerVersion(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte(`{"schemaVersion":99,"users":[],"tasks":[]}`), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	if _, err := MigrateDataFile(path, LatestSchemaVersion()); err == nil {
+		t.Error("expected a data file from a newer version to be rejected")
+	}
+	if _, err := LoadDataFrom(path); err == nil {
+		t.Error("expected loading a data file from a newer version to fail")
+	}
+}
+
+func TestReadBackup_MigratesOldBackup(t *testing.T) {
+	t.Parallel()
+
+	snap, err := ReadBackup(strings.NewReader(`{"users":[],"tasks":[{"id":1,"title":"Old","status":"pending","userId":1}]}`))
+	if err != nil {
+		t.Fatalf("ReadBackup failed: %v", err)
+	}
+	if snap.Tasks()[0].CreatedAt.IsZero() {
+		t.Error("expected tasks in an old backup to be given a creation time")
+	}
+}
+
+func TestPostgresMigrations(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := postgresMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if len(migrations) == 0 || LatestPostgresSchemaVersion() != len(migrations) {
+		t.Errorf("expected latest version %d, got %d", len(migrations), LatestPostgresSchemaVersion())
+	}
+}
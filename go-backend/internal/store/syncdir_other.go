@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package store
+
+// syncDir is not supported on this platform, where directories cannot be
+// opened for syncing.
+func syncDir(path string) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package store
+
+import "os"
+
+// syncDir syncs the directory at path, making files created, renamed or
+// removed in it durable.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := dir.Sync(); err != nil {
+		dir.Close()
+		return err
+	}
+	return dir.Close()
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// PersistenceVerifier is implemented by backends that serve data from
+// memory and save it separately, so what they saved can drift from what
+// they serve.
+type PersistenceVerifier interface {
+	// VerifyPersisted describes each record that a restart would load
+	// differently from how it is served now.
+	VerifyPersisted(ctx context.Context) ([]string, error)
+}
+
+var _ PersistenceVerifier = (*Store)(nil)
+
+// VerifyPersisted loads the data file and replays the journal, as a
+// restart would, and compares the result with the data in memory. Changes
+// not yet written are applied on top first, so only writes that went
+// wrong are reported. Ephemeral stores save nothing to compare.
+func (s *Store) VerifyPersisted(ctx context.Context) ([]string, error) {
+	if s.ephemeral {
+		return nil, nil
+	}
+
+	// Hold off writes so the files and the pending changes agree
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.RLock()
+	memory := s.copyData()
+	pending := append([]journalEntry{}, s.journal...)
+	replacing := s.compactPending
+	path := s.dataPath
+	s.mu.RUnlock()
+
+	// The next write replaces the data file whole; until then it is
+	// expected to be stale
+	if replacing {
+		return nil, nil
+	}
+
+	disk, err := LoadDataFrom(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := replayJournal(path+JournalSuffix, disk); err != nil {
+		return nil, err
+	}
+	for _, entry := range pending {
+		entry.apply(disk)
+	}
+
+	var diffs []string
+	diffs = append(diffs, diffRecords("user", usersToFile(disk.Users), usersToFile(memory.Users), func(u storedUser) string { return strconv.Itoa(u.ID) })...)
+	diffs = append(diffs, diffRecords("task", disk.Tasks, memory.Tasks, func(t model.Task) string { return strconv.Itoa(t.ID) })...)
+	diffs = append(diffs, diffRecords("share", sharesToFile(disk.Shares), sharesToFile(memory.Shares), func(s storedShare) string { return s.ID })...)
+	diffs = append(diffs, diffRecords("announcement", disk.Announcements, memory.Announcements, func(a model.Announcement) string { return strconv.Itoa(a.ID) })...)
+	diffs = append(diffs, diffRecords("project", disk.Projects, memory.Projects, func(p model.Project) string { return strconv.Itoa(p.ID) })...)
+	diffs = append(diffs, diffRecords("audit event", disk.Audit, memory.Audit, func(e model.AuditEvent) string { return strconv.Itoa(e.ID) })...)
+	diffs = append(diffs, diffRecords("webhook", webhooksToFile(disk.Webhooks), webhooksToFile(memory.Webhooks), func(w storedWebhook) string { return strconv.Itoa(w.ID) })...)
+	diffs = append(diffs, diffRecords("stats snapshot", disk.StatsHistory, memory.StatsHistory, func(s model.StatsSnapshot) string { return s.At.Format(time.RFC3339Nano) })...)
+	return diffs, nil
+}
+
+// usersToFile returns users in their on-disk representation.
+func usersToFile(users []model.User) []storedUser {
+	stored := make([]storedUser, len(users))
+	for i, user := range users {
+		stored[i] = storedUser{User: user, PasswordHash: user.PasswordHash}
+	}
+	return stored
+}
+
+// diffRecords describes the records of kind, identified by key, that are
+// missing from disk or memory or differ between them, in key order. Records
+// are compared as they are written to disk.
+func diffRecords[T any](kind string, disk, memory []T, key func(T) string) []string {
+	onDisk := make(map[string][]byte, len(disk))
+	for _, record := range disk {
+		onDisk[key(record)], _ = json.Marshal(record)
+	}
+
+	var diffs []string
+	for _, record := range memory {
+		k := key(record)
+		saved, ok := onDisk[k]
+		delete(onDisk, k)
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s %s is not saved", kind, k))
+			continue
+		}
+		if data, _ := json.Marshal(record); string(data) != string(saved) {
+			diffs = append(diffs, fmt.Sprintf("%s %s is saved with different values", kind, k))
+		}
+	}
+	for k := range onDisk {
+		diffs = append(diffs, fmt.Sprintf("%s %s is saved but was deleted", kind, k))
+	}
+	sort.Strings(diffs)
+	return diffs
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go-backend/internal/model"
+)
+
+// GetWebhooks returns all webhooks in ID order.
+func (s *Store) GetWebhooks(ctx context.Context) []model.Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhooks := make([]model.Webhook, len(s.webhooks))
+	for i, webhook := range s.webhooks {
+		webhooks[i] = copyWebhook(webhook)
+	}
+	return webhooks
+}
+
+// GetWebhookByID returns a webhook by ID or nil if not found.
+func (s *Store) GetWebhookByID(ctx context.Context, id int) *model.Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.webhooks {
+		if s.webhooks[i].ID == id {
+			webhook := copyWebhook(s.webhooks[i])
+			return &webhook
+		}
+	}
+	return nil
+}
+
+// CreateWebhook adds a webhook and returns it with a generated ID and its
+// creation time.
+func (s *Store) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxID := 0
+	if n := len(s.webhooks); n > 0 {
+		maxID = s.webhooks[n-1].ID
+	}
+
+	webhook.ID = s.ids.NextID(KindWebhook, maxID)
+	webhook.Events = slices.Clone(webhook.Events)
+	webhook.CreatedAt = time.Now().UTC()
+
+	s.webhooks = append(s.webhooks, webhook)
+	s.journalWebhook(webhook)
+
+	return copyWebhook(webhook), nil
+}
+
+// DeleteWebhook removes a webhook and reports whether it existed.
+func (s *Store) DeleteWebhook(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, webhook := range s.webhooks {
+		if webhook.ID == id {
+			s.webhooks = append(s.webhooks[:i:i], s.webhooks[i+1:]...)
+			s.journalDeleteWebhook(id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
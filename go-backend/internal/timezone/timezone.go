@@ -0,0 +1,58 @@
+// Package timezone interprets dates and times given in a caller's time
+// zone. Times are stored in UTC; a zone only changes how they are read
+// and shown.
+package timezone
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalid is returned by Load for names that are not IANA time zones.
+var ErrInvalid = errors.New("time zone must be an IANA name such as Europe/Berlin")
+
+// Load returns the location for an IANA time zone name such as
+// "America/New_York" or "UTC". Unlike time.LoadLocation it rejects the
+// empty name and "Local", which would mean the server's zone.
+func Load(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalid
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return loc, nil
+}
+
+// Layouts ParseDue accepts besides RFC 3339: a date, and a date and time
+// without an offset.
+const (
+	dateLayout      = "2006-01-02"
+	localLayout     = "2006-01-02T15:04"
+	localSecsLayout = "2006-01-02T15:04:05"
+)
+
+// ParseDue parses a due date and returns it as an instant in UTC.
+//
+// A timestamp with an offset, such as "2026-03-08T17:00:00+01:00", is
+// used as is. A timestamp without one, such as "2026-03-08T17:00", is read
+// as wall-clock time in loc. A date, such as "2026-03-08", means the end of
+// that day in loc: the task is overdue once the next day starts there.
+// Days are counted on the calendar rather than as 24 hours, so a due date
+// stays at midnight across daylight saving changes.
+func ParseDue(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range []string{localSecsLayout, localLayout} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if d, err := time.ParseInLocation(dateLayout, value, loc); err == nil {
+		return time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, loc).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("due date %q must be a date (YYYY-MM-DD) or an RFC 3339 timestamp", value)
+}
@@ -0,0 +1,80 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		wantErr bool
+	}{
+		{"IANA name", "America/New_York", false},
+		{"UTC", "UTC", false},
+		{"empty", "", true},
+		{"server local zone", "Local", true},
+		{"unknown", "Mars/Olympus_Mons", true},
+		{"offset", "+02:00", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.zone)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load(%q) error = %v, wantErr %v", tt.zone, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	newYork, _ := Load("America/New_York")
+	berlin, _ := Load("Europe/Berlin")
+
+	tests := []struct {
+		name  string
+		value string
+		loc   *time.Location
+		want  string
+	}{
+		{"date in UTC", "2026-06-15", time.UTC, "2026-06-16T00:00:00Z"},
+		{"date in summer time", "2026-06-15", newYork, "2026-06-16T04:00:00Z"},
+		{"date in winter time", "2026-01-15", newYork, "2026-01-16T05:00:00Z"},
+		// Clocks go forward on 2026-03-08: the day is 23 hours long
+		{"date on spring forward", "2026-03-08", newYork, "2026-03-09T04:00:00Z"},
+		{"date before spring forward", "2026-03-07", newYork, "2026-03-08T05:00:00Z"},
+		// Clocks go back on 2026-11-01: the day is 25 hours long
+		{"date on fall back", "2026-11-01", newYork, "2026-11-02T05:00:00Z"},
+		{"date before fall back", "2026-10-31", newYork, "2026-11-01T04:00:00Z"},
+		{"date on Berlin spring forward", "2026-03-29", berlin, "2026-03-29T22:00:00Z"},
+		{"local time", "2026-03-08T17:30", newYork, "2026-03-08T21:30:00Z"},
+		{"local time with seconds", "2026-01-15T09:00:05", berlin, "2026-01-15T08:00:05Z"},
+		{"timestamp with offset ignores zone", "2026-03-08T17:00:00+01:00", newYork, "2026-03-08T16:00:00Z"},
+		{"UTC timestamp", "2026-03-08T17:00:00Z", berlin, "2026-03-08T17:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDue(tt.value, tt.loc)
+			if err != nil {
+				t.Fatalf("ParseDue(%q) failed: %v", tt.value, err)
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("ParseDue(%q) = %s, want %s", tt.value, got.Format(time.RFC3339), tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("expected a UTC time, got %v", got.Location())
+			}
+		})
+	}
+}
+
+func TestParseDue_Invalid(t *testing.T) {
+	for _, value := range []string{"", "tomorrow", "2026-02-30", "08/03/2026", "2026-03-08 17:00"} {
+		if _, err := ParseDue(value, time.UTC); err == nil {
+			t.Errorf("expected ParseDue(%q) to fail", value)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package unfurl fetches the title and favicon of links in task
+// descriptions, so clients can show them as previews.
+//
+// Links point anywhere, so pages are fetched with a fetch.Client, which
+// only reaches public addresses, and only a bounded amount of each page is
+// read.
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-backend/internal/fetch"
+	"go-backend/internal/model"
+)
+
+// Limits on unfurling.
+const (
+	// MaxLinks is how many links of a description are unfurled.
+	MaxLinks = 5
+	// DefaultTimeout bounds fetching one page, redirects included.
+	DefaultTimeout = 5 * time.Second
+	// maxPageBytes is how much of a page is read for its metadata.
+	maxPageBytes = 512 << 10
+	// maxTitleLength caps titles, in characters.
+	maxTitleLength = 300
+)
+
+var (
+	linkPattern  = regexp.MustCompile("https?://[^\\s<>()\\[\\]{}\"'`]+")
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	tagPattern   = regexp.MustCompile(`(?is)<(meta|link)\s[^>]*>`)
+	attrPattern  = regexp.MustCompile(`(?s)([a-zA-Z:-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// Links returns the distinct http and https URLs in text, in order of
+// appearance, at most MaxLinks of them.
+func Links(text string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		// Punctuation ending a sentence is not part of the link
+		link = strings.TrimRight(link, ".,;:!?*_~")
+		if u, err := url.Parse(link); err != nil || u.Host == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+		if len(links) == MaxLinks {
+			break
+		}
+	}
+	return links
+}
+
+// Unfurler fetches link metadata.
+type Unfurler struct {
+	client *fetch.Client
+}
+
+// New returns an unfurler fetching pages with client, or with a client
+// following the default fetch policy if client is nil.
+func New(client *fetch.Client) *Unfurler {
+	if client == nil {
+		client = fetch.New(fetch.Policy{Timeout: DefaultTimeout, MaxBytes: maxPageBytes})
+	}
+	return &Unfurler{client: client}
+}
+
+// Unfurl fetches the page at link and returns its title and favicon. A
+// page that cannot be fetched gives an Unfurl with Error set.
+func (u *Unfurler) Unfurl(ctx context.Context, link string) model.Unfurl {
+	result := model.Unfurl{URL: link, FetchedAt: time.Now().UTC()}
+	if err := u.fetch(ctx, link, &result); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (u *Unfurler) fetch(ctx context.Context, link string, result *model.Unfurl) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "go-backend-unfurl/1.0")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unfurl: %s", resp.Status)
+	}
+
+	// Redirects may have moved the page; relative links resolve against
+	// where it ended up
+	base := resp.Request.URL
+	result.FaviconURL = base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil
+	}
+
+	// Metadata is in the head, so a long page is cut short rather than
+	// rejected
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return err
+	}
+	parsePage(string(page), base, result)
+	return nil
+}
+
+// parsePage reads the title and favicon of an HTML page at base. The
+// Open Graph title is preferred to the <title> element.
+func parsePage(page string, base *url.URL, result *model.Unfurl) {
+	if m := titlePattern.FindStringSubmatch(page); m != nil {
+		result.Title = cleanText(m[1])
+	}
+	for _, tag := range tagPattern.FindAllStringSubmatch(page, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range attrPattern.FindAllStringSubmatch(tag[0], -1) {
+			attrs[strings.ToLower(attr[1])] = html.UnescapeString(strings.Trim(attr[2], `"'`))
+		}
+		switch strings.ToLower(tag[1]) {
+		case "meta":
+			if attrs["property"] == "og:title" && strings.TrimSpace(attrs["content"]) != "" {
+				result.Title = cleanText(attrs["content"])
+			}
+		case "link":
+			if isIconRel(attrs["rel"]) {
+				if icon, err := base.Parse(strings.TrimSpace(attrs["href"])); err == nil && (icon.Scheme == "http" || icon.Scheme == "https") {
+					result.FaviconURL = icon.String()
+				}
+			}
+		}
+	}
+}
+
+// isIconRel reports whether a link's rel names a favicon.
+func isIconRel(rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == "icon" {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanText unescapes text from a page and collapses its white space,
+// truncated to maxTitleLength characters.
+func cleanText(text string) string {
+	text = strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+	if runes := []rune(text); len(runes) > maxTitleLength {
+		text = string(runes[:maxTitleLength])
+	}
+	return text
+}
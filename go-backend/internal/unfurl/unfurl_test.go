@@ -0,0 +1,115 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-backend/internal/fetch"
+	"go-backend/internal/model"
+)
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"none", "no links here", nil},
+		{"punctuation", "See https://example.com/a, and (http://example.org/b).", []string{"https://example.com/a", "http://example.org/b"}},
+		{"markdown link", "[docs](https://example.com/docs?x=1)", []string{"https://example.com/docs?x=1"}},
+		{"duplicates", "https://example.com https://example.com", []string{"https://example.com"}},
+		{"other schemes", "ftp://example.com javascript:alert(1) mailto:a@example.com", nil},
+		{"no host", "http:// and https://", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Links(tt.text); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: Links(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+
+	if got := Links(strings.Repeat("https://example.com/x ", 3) + "https://a.example https://b.example https://c.example https://d.example https://e.example"); len(got) != MaxLinks {
+		t.Errorf("expected at most %d links, got %q", MaxLinks, got)
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+	tests := []struct {
+		name        string
+		page        string
+		wantTitle   string
+		wantFavicon string
+	}{
+		{"title", "<html><head><title>\n  A &amp; B  </title></head></html>", "A & B", ""},
+		{"og title preferred", `<title>Plain</title><meta property="og:title" content="Rich &quot;title&quot;">`, `Rich "title"`, ""},
+		{"relative icon", `<link rel="shortcut icon" href="/static/icon.png">`, "", "https://example.com/static/icon.png"},
+		{"unquoted icon", `<LINK REL=icon HREF=img/fav.ico>`, "", "https://example.com/blog/img/fav.ico"},
+		{"script icon", `<link rel="icon" href="javascript:alert(1)">`, "", ""},
+		{"stylesheet", `<link rel="stylesheet" href="/main.css">`, "", ""},
+	}
+
+	for _, tt := range tests {
+		var got model.Unfurl
+		parsePage(tt.page, base, &got)
+		if got.Title != tt.wantTitle || got.FaviconURL != tt.wantFavicon {
+			t.Errorf("%s: got title %q favicon %q, want %q %q", tt.name, got.Title, got.FaviconURL, tt.wantTitle, tt.wantFavicon)
+		}
+	}
+}
+
+func TestUnfurler_Unfurl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<title>Page</title><link rel="icon" href="/icon.png">`)
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/file":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "<title>Not HTML</title>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u := New(loopbackClient(srv))
+	if got := u.Unfurl(context.Background(), srv.URL+"/moved"); got.Title != "Page" || got.FaviconURL != srv.URL+"/icon.png" || got.Error != "" {
+		t.Errorf("unexpected unfurl of a redirect %+v", got)
+	}
+	if got := u.Unfurl(context.Background(), srv.URL+"/file"); got.Title != "" || got.FaviconURL != srv.URL+"/favicon.ico" {
+		t.Errorf("expected no title for a non-HTML page, got %+v", got)
+	}
+	if got := u.Unfurl(context.Background(), srv.URL+"/missing"); got.Error == "" || got.FetchedAt.IsZero() {
+		t.Errorf("expected an error for a missing page, got %+v", got)
+	}
+}
+
+func TestUnfurler_RefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected no request to reach a loopback server")
+	}))
+	defer srv.Close()
+
+	if got := New(nil).Unfurl(context.Background(), srv.URL); got.Error == "" {
+		t.Errorf("expected the default unfurler to refuse loopback, got %+v", got)
+	}
+}
+
+// loopbackClient returns a fetch client that may reach srv, which the
+// default policy refuses as it listens on loopback.
+func loopbackClient(srv *httptest.Server) *fetch.Client {
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	return fetch.New(fetch.Policy{Ports: []int{port}, AllowNets: []*net.IPNet{loopback}})
+}
@@ -0,0 +1,261 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"go-backend/internal/password"
+	"go-backend/internal/timezone"
+)
+
+// Validate checks the fields of the struct v, or of the struct v points
+// to, against their `validate` tags and returns every failure, in field
+// order, or nil. A tag is a comma-separated list of rules:
+//
+//	required   the value must be set and, for strings, not blank
+//	nonempty   a string that is set must not be blank
+//	omitempty  skip the remaining rules when the value is empty
+//	email      the string must be an email address
+//	status     the string must be a task status
+//	priority   the string must be a task priority
+//	role       the string must be an allowed user role
+//	level      the string must be an announcement level
+//	tags       the strings must be valid tags, at most MaxTags once deduplicated
+//	password   the string must be a valid password
+//	timezone   the string must be an IANA time zone
+//	min=N      the string must have at least N characters, or the number be at least N
+//	max=N      the string must have at most N characters, or the number be at most N
+//
+// Pointer fields that are nil are only checked by required. Each field
+// reports at most its first failure. Fields are named after their JSON
+// names, and the code of a failure is INVALID_ followed by the name, e.g.
+// INVALID_USER_ID for userId, with _FORMAT added for a bad email.
+//
+// Validate panics if v is not a struct or a tag has an unknown rule, as
+// both are programming errors.
+func Validate(v interface{}) []FieldError {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	var errs []FieldError
+	for _, f := range fieldsOf(rv.Type()) {
+		if ferr := f.check(rv.Field(f.index)); ferr != nil {
+			errs = append(errs, *ferr)
+		}
+	}
+	return errs
+}
+
+// rule checks one value, a string or a number, that is set. It returns
+// the code suffix and message of a failure, or ok.
+type rule func(f *field, value reflect.Value) (suffix, message string, ok bool)
+
+// field is a struct field with a validate tag.
+type field struct {
+	index int
+	name  string // JSON name
+	code  string // code of failures, before any suffix
+	label string // name as it starts a message
+
+	required  bool
+	nonempty  bool
+	omitempty bool
+	rules     []rule
+}
+
+// fieldCache maps struct types to their parsed fields.
+var fieldCache sync.Map
+
+// fieldsOf returns the fields of t that have a validate tag.
+func fieldsOf(t reflect.Type) []*field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]*field)
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validator: cannot validate %s, want a struct", t))
+	}
+
+	var fields []*field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok || tag == "-" {
+			continue
+		}
+		fields = append(fields, parseField(t, i, sf, tag))
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// parseField parses the validate tag of the struct field sf.
+func parseField(t reflect.Type, index int, sf reflect.StructField, tag string) *field {
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		name = sf.Name
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	f := &field{
+		index: index,
+		name:  name,
+		code:  "INVALID_" + snake(name),
+		label: string(unicode.ToUpper(first)) + name[size:],
+	}
+
+	for _, r := range strings.Split(tag, ",") {
+		r, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+		switch r {
+		case "required":
+			f.required = true
+		case "nonempty":
+			f.nonempty = true
+		case "omitempty":
+			f.omitempty = true
+		case "email":
+			f.rules = append(f.rules, emailRule)
+		case "status":
+			f.rules = append(f.rules, statusRule)
+		case "priority":
+			f.rules = append(f.rules, priorityRule)
+		case "role":
+			f.rules = append(f.rules, roleRule)
+		case "level":
+			f.rules = append(f.rules, levelRule)
+		case "tags":
+			f.rules = append(f.rules, tagsRule)
+		case "password":
+			f.rules = append(f.rules, passwordRule)
+		case "timezone":
+			f.rules = append(f.rules, timezoneRule)
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validator: %s.%s: invalid %s %q", t, sf.Name, r, arg))
+			}
+			f.rules = append(f.rules, boundRule(r == "min", n))
+		default:
+			panic(fmt.Sprintf("validator: %s.%s: unknown rule %q", t, sf.Name, r))
+		}
+	}
+	return f
+}
+
+// check returns the first failure of value, or nil.
+func (f *field) check(value reflect.Value) *FieldError {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if f.required {
+				return f.fail("", f.label+" is required")
+			}
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	blank := value.Kind() == reflect.String && !NonEmpty(value.String())
+	switch {
+	case blank && f.required:
+		return f.fail("", f.label+" is required and cannot be empty")
+	case blank && f.nonempty:
+		return f.fail("", f.label+" cannot be empty")
+	case f.omitempty && value.IsZero():
+		return nil
+	}
+
+	for _, r := range f.rules {
+		if suffix, message, ok := r(f, value); !ok {
+			return f.fail(suffix, message)
+		}
+	}
+	return nil
+}
+
+func (f *field) fail(suffix, message string) *FieldError {
+	return &FieldError{Field: f.name, Code: f.code + suffix, Message: message}
+}
+
+func emailRule(f *field, value reflect.Value) (string, string, bool) {
+	return "_FORMAT", "Invalid " + f.name + " format", Email(value.String())
+}
+
+func statusRule(f *field, value reflect.Value) (string, string, bool) {
+	return "", "Invalid " + f.name + ". Must be one of: " + strings.Join(statuses, ", "), Status(value.String())
+}
+
+func priorityRule(f *field, value reflect.Value) (string, string, bool) {
+	return "", "Invalid " + f.name + ". Must be one of: " + strings.Join(priorities, ", "), Priority(value.String())
+}
+
+func roleRule(f *field, value reflect.Value) (string, string, bool) {
+	return "", "Invalid " + f.name + ". Must be one of: " + strings.Join(Roles(), ", "), Role(value.String())
+}
+
+func levelRule(f *field, value reflect.Value) (string, string, bool) {
+	return "", "Invalid " + f.name + ". Must be one of: " + strings.Join(levels, ", "), Level(value.String())
+}
+
+func tagsRule(f *field, value reflect.Value) (string, string, bool) {
+	tags := value.Interface().([]string)
+	for _, tag := range tags {
+		if !Tag(tag) {
+			return "", fmt.Sprintf("Invalid tag %q: tags must be 1-%d letters, digits, hyphens, underscores or dots", tag, MaxTagLength), false
+		}
+	}
+	if len(NormalizeTags(tags)) > MaxTags {
+		return "", fmt.Sprintf("%s may have at most %d tags", f.label, MaxTags), false
+	}
+	return "", "", true
+}
+
+func passwordRule(f *field, value reflect.Value) (string, string, bool) {
+	return "", password.ErrInvalidLength.Error(), password.Valid(value.String())
+}
+
+func timezoneRule(f *field, value reflect.Value) (string, string, bool) {
+	if _, err := timezone.Load(value.String()); err != nil {
+		return "", err.Error(), false
+	}
+	return "", "", true
+}
+
+// boundRule returns a rule for min=n, or max=n if min is false. Strings
+// are measured in characters.
+func boundRule(min bool, n int) rule {
+	return func(f *field, value reflect.Value) (string, string, bool) {
+		var size int64
+		unit := ""
+		switch value.Kind() {
+		case reflect.String:
+			size = int64(utf8.RuneCountInString(value.String()))
+			unit = " characters"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			size = value.Int()
+		default:
+			panic(fmt.Sprintf("validator: %s: min and max need a string or integer, got %s", f.name, value.Type()))
+		}
+		if min {
+			return "", fmt.Sprintf("%s must be at least %d%s", f.label, n, unit), size >= int64(n)
+		}
+		return "", fmt.Sprintf("%s must be at most %d%s", f.label, n, unit), size <= int64(n)
+	}
+}
+
+// snake converts a camelCase name to upper snake case, e.g. userId to
+// USER_ID.
+func snake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
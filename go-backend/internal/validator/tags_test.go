@@ -0,0 +1,79 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"go-backend/internal/model"
+)
+
+func TestValidate(t *testing.T) {
+	empty, bad := "", "done"
+	tests := []struct {
+		name  string
+		req   interface{}
+		codes []string
+	}{
+		{"valid user", model.CreateUserRequest{Name: "Ann", Email: "ann@example.com", Role: "developer"}, nil},
+		{"empty user", model.CreateUserRequest{}, []string{"INVALID_NAME", "INVALID_EMAIL", "INVALID_ROLE"}},
+		{"bad email and password", &model.CreateUserRequest{Name: "Ann", Email: "ann", Role: "developer", Password: "short"},
+			[]string{"INVALID_EMAIL_FORMAT", "INVALID_PASSWORD"}},
+		{"bad timezone", model.CreateUserRequest{Name: "Ann", Email: "ann@example.com", Role: "developer", Timezone: "Mars/Base"},
+			[]string{"INVALID_TIMEZONE"}},
+		{"long name", model.CreateUserRequest{Name: strings.Repeat("n", 121), Email: "ann@example.com", Role: "developer"},
+			[]string{"INVALID_NAME"}},
+		{"valid task", model.CreateTaskRequest{Title: "Ship", Status: "pending", UserID: 1}, nil},
+		{"empty task", model.CreateTaskRequest{}, []string{"INVALID_TITLE", "INVALID_STATUS"}},
+		{"empty update", model.UpdateTaskRequest{}, nil},
+		{"bad update", model.UpdateTaskRequest{Title: &empty, Status: &bad}, []string{"INVALID_TITLE", "INVALID_STATUS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range Validate(tt.req) {
+				got = append(got, err.Code)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.codes, ",") {
+				t.Errorf("Validate() codes = %v, want %v", got, tt.codes)
+			}
+		})
+	}
+}
+
+func TestValidate_Messages(t *testing.T) {
+	type request struct {
+		UserID  *int   `json:"userId" validate:"required"`
+		Count   int    `json:"count" validate:"min=1,max=5"`
+		Comment string `json:"comment,omitempty" validate:"omitempty,min=3"`
+		Ignored string `json:"ignored"`
+	}
+
+	errs := Validate(request{Count: 9, Comment: "ok"})
+	want := []FieldError{
+		{"userId", "INVALID_USER_ID", "UserId is required"},
+		{"count", "INVALID_COUNT", "Count must be at most 5"},
+		{"comment", "INVALID_COMMENT", "Comment must be at least 3 characters"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate() = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("Validate()[%d] = %+v, want %+v", i, errs[i], want[i])
+		}
+	}
+}
+
+func TestValidate_UnknownRule(t *testing.T) {
+	type request struct {
+		Name string `validate:"shiny"`
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Validate to panic on an unknown rule")
+		}
+	}()
+	Validate(request{})
+}
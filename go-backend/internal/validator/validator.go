@@ -4,19 +4,81 @@ package validator
 import (
 	"regexp"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
-var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+// EmailPattern is the regular expression valid emails match.
+const EmailPattern = `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
 
-var validStatuses = map[string]bool{
-	"pending":     true,
-	"in-progress": true,
-	"completed":   true,
+var emailRegex = regexp.MustCompile(EmailPattern)
+
+// statuses lists the valid task statuses in workflow order.
+var statuses = []string{"pending", "in-progress", "completed"}
+
+var validStatuses = func() map[string]bool {
+	valid := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		valid[status] = true
+	}
+	return valid
+}()
+
+// priorities lists the valid task priorities, lowest first.
+var priorities = []string{"low", "medium", "high", "urgent"}
+
+// DefaultPriority is the priority of tasks created without one.
+const DefaultPriority = "medium"
+
+var priorityRanks = func() map[string]int {
+	ranks := make(map[string]int, len(priorities))
+	for i, priority := range priorities {
+		ranks[priority] = i + 1
+	}
+	return ranks
+}()
+
+// levels lists the valid announcement levels, least severe first.
+var levels = []string{"info", "warning", "critical"}
+
+// DefaultLevel is the level of announcements created without one.
+const DefaultLevel = "info"
+
+// Limits on task tags.
+const (
+	MaxTags      = 10
+	MaxTagLength = 32
+)
+
+// DefaultRoles are the user roles allowed unless SetRoles configures
+// others.
+var DefaultRoles = []string{"developer", "designer", "manager", "admin"}
+
+var (
+	rolesMu    sync.RWMutex
+	roles      = DefaultRoles
+	validRoles = toSet(DefaultRoles)
+)
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
 }
 
-// Email checks if the given email has a valid format.
+// Email checks if the given email has a valid format once normalized.
 func Email(email string) bool {
-	return emailRegex.MatchString(email)
+	return emailRegex.MatchString(NormalizeEmail(email))
+}
+
+// NormalizeEmail returns the form emails are stored and compared in:
+// trimmed and lowercased, so John@Example.com and john@example.com are the
+// same address.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // Status checks if the given status is one of the allowed values.
@@ -24,6 +86,126 @@ func Status(status string) bool {
 	return validStatuses[status]
 }
 
+// Statuses returns the valid task statuses in workflow order.
+func Statuses() []string {
+	return append([]string{}, statuses...)
+}
+
+// Priority checks if the given priority is one of the allowed values.
+func Priority(priority string) bool {
+	return priorityRanks[priority] > 0
+}
+
+// Priorities returns the valid task priorities, lowest first.
+func Priorities() []string {
+	return append([]string{}, priorities...)
+}
+
+// Level checks if the given announcement level is one of the allowed
+// values.
+func Level(level string) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Levels returns the valid announcement levels, least severe first.
+func Levels() []string {
+	return append([]string{}, levels...)
+}
+
+// PriorityRank orders priorities: higher ranks are more urgent, and
+// invalid priorities rank 0.
+func PriorityRank(priority string) int {
+	return priorityRanks[priority]
+}
+
+// NormalizeTag returns the form tags are stored and compared in: trimmed,
+// lowercased, and with runs of spaces replaced by a hyphen, so "Needs
+// Review" and "needs-review" are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// NormalizeTags normalizes each tag and drops blanks and duplicates,
+// keeping the first occurrence of each. It returns nil for no tags.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// Tag checks if the given tag, once normalized, is 1 to MaxTagLength
+// letters, digits, hyphens, underscores or dots.
+func Tag(tag string) bool {
+	tag = NormalizeTag(tag)
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Role checks if the given role is one of the allowed roles.
+func Role(role string) bool {
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+	return validRoles[role]
+}
+
+// Roles returns the allowed user roles in the order they were configured.
+func Roles() []string {
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+	return append([]string{}, roles...)
+}
+
+// SetRoles replaces the allowed user roles. Blank entries are ignored;
+// with none left, DefaultRoles are allowed. It is meant to be called at
+// startup, before requests are served.
+func SetRoles(allowed []string) {
+	var cleaned []string
+	for _, role := range allowed {
+		if role = strings.TrimSpace(role); role != "" {
+			cleaned = append(cleaned, role)
+		}
+	}
+	if len(cleaned) == 0 {
+		cleaned = DefaultRoles
+	}
+
+	rolesMu.Lock()
+	defer rolesMu.Unlock()
+	roles = cleaned
+	validRoles = toSet(cleaned)
+}
+
+// FieldError describes why a field was rejected. Code is a stable,
+// machine-readable identifier such as INVALID_EMAIL.
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
 // NonEmpty checks if a string is non-empty after trimming whitespace.
 func NonEmpty(s string) bool {
 	return strings.TrimSpace(s) != ""
@@ -1,6 +1,9 @@
 package validator
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestEmail(t *testing.T) {
 	tests := []struct {
@@ -27,6 +30,117 @@ func TestEmail(t *testing.T) {
 	}
 }
 
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"user@example.com", "user@example.com"},
+		{"John@Example.COM", "john@example.com"},
+		{"  user@example.com\n", "user@example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEmail(tt.email); got != tt.want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	tests := []struct {
+		priority string
+		want     bool
+	}{
+		{"low", true},
+		{"urgent", true},
+		{"critical", false},
+		{"", false},
+		{"High", false},
+	}
+
+	for _, tt := range tests {
+		if got := Priority(tt.priority); got != tt.want {
+			t.Errorf("Priority(%q) = %v, want %v", tt.priority, got, tt.want)
+		}
+	}
+	if PriorityRank("urgent") <= PriorityRank("high") || PriorityRank("low") <= PriorityRank("") {
+		t.Error("expected ranks to increase with urgency")
+	}
+}
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"backend", true},
+		{"Needs Review", true},
+		{"v1.2_beta", true},
+		{"café", true},
+		{"", false},
+		{"   ", false},
+		{"a,b", false},
+		{"no/slashes", false},
+		{strings.Repeat("a", MaxTagLength+1), false},
+	}
+
+	for _, tt := range tests {
+		if got := Tag(tt.tag); got != tt.want {
+			t.Errorf("Tag(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" Needs  Review ", "ui", "", "UI", "needs-review"})
+	if strings.Join(got, ",") != "needs-review,ui" {
+		t.Errorf("NormalizeTags = %q, want [needs-review ui]", got)
+	}
+	if got := NormalizeTags([]string{" "}); got != nil {
+		t.Errorf("NormalizeTags of blanks = %q, want nil", got)
+	}
+}
+
+func TestRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		want bool
+	}{
+		{"developer", "developer", true},
+		{"admin", "admin", true},
+		{"typo", "develoepr", false},
+		{"empty", "", false},
+		{"uppercase", "Designer", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Role(tt.role); got != tt.want {
+				t.Errorf("Role(%q) = %v, want %v", tt.role, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetRoles(t *testing.T) {
+	defer SetRoles(nil)
+
+	SetRoles([]string{" qa ", "", "developer"})
+	if got := Roles(); len(got) != 2 || got[0] != "qa" || got[1] != "developer" {
+		t.Errorf("Roles() = %v, want [qa developer]", got)
+	}
+	if !Role("qa") || Role("admin") {
+		t.Error("expected only the configured roles to be allowed")
+	}
+
+	SetRoles([]string{" "})
+	if got := Roles(); len(got) != len(DefaultRoles) {
+		t.Errorf("Roles() = %v, want the defaults", got)
+	}
+}
+
 func TestStatus(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -71,3 +185,15 @@ func TestNonEmpty(t *testing.T) {
 		})
 	}
 }
+
+func TestStatuses(t *testing.T) {
+	got := Statuses()
+	if len(got) != 3 || got[0] != "pending" || got[2] != "completed" {
+		t.Errorf("Statuses() = %v, want [pending in-progress completed]", got)
+	}
+	for _, status := range got {
+		if !Status(status) {
+			t.Errorf("Status(%q) = false for a listed status", status)
+		}
+	}
+}
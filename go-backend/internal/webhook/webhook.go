@@ -0,0 +1,315 @@
+// Package webhook delivers events, such as a task being completed, to the
+// URLs admins register.
+//
+// Each delivery is a POST of a JSON model.WebhookPayload, signed with the
+// webhook's secret: the X-Webhook-Signature header is "sha256=" and the
+// hex HMAC-SHA256 of the body. Failed deliveries are retried with
+// exponential backoff. Webhook URLs may point anywhere, so they are called
+// with a fetch.Client, which only reaches public addresses.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-backend/internal/fetch"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+)
+
+// Events webhooks can subscribe to.
+const (
+	TaskCreated   = "task.created"
+	TaskUpdated   = "task.updated"
+	TaskCompleted = "task.completed"
+	UserCreated   = "user.created"
+)
+
+var events = []string{TaskCreated, TaskUpdated, TaskCompleted, UserCreated}
+
+// Events returns the events webhooks can subscribe to.
+func Events() []string {
+	return slices.Clone(events)
+}
+
+// ValidEvent reports whether webhooks can subscribe to event.
+func ValidEvent(event string) bool {
+	return slices.Contains(events, event)
+}
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with each delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Defaults for Options.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = 2 * time.Second
+	DefaultTimeout     = 10 * time.Second
+	// DefaultLogSize is how many deliveries are kept per webhook.
+	DefaultLogSize = 100
+)
+
+// maxResponseBytes is how much of a response is read before the
+// connection is closed.
+const maxResponseBytes = 64 << 10
+
+// Options configure a Dispatcher. Zero fields use the defaults.
+type Options struct {
+	// Client sends deliveries. When nil, a client following the default
+	// fetch policy is used.
+	Client *fetch.Client
+	// MaxAttempts is how many times a delivery is tried before it fails.
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles with each
+	// retry after that.
+	Backoff time.Duration
+	// LogSize is how many recent deliveries are kept per webhook.
+	LogSize int
+}
+
+// Dispatcher delivers events to the webhooks registered in a store and
+// keeps a log of recent deliveries. The log is kept in memory, so it
+// starts empty after a restart.
+type Dispatcher struct {
+	store       store.Backend
+	client      *fetch.Client
+	maxAttempts int
+	backoff     time.Duration
+	logSize     int
+
+	mu     sync.Mutex
+	nextID int
+	// log holds the recent deliveries to each webhook, oldest first.
+	log map[int][]*model.WebhookDelivery
+
+	// ctx is cancelled by Close, which waits for pending deliveries.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup
+}
+
+// New returns a dispatcher delivering events to the webhooks in s.
+func New(s store.Backend, opts Options) *Dispatcher {
+	if opts.Client == nil {
+		opts.Client = fetch.New(fetch.Policy{Timeout: DefaultTimeout, MaxBytes: maxResponseBytes})
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.LogSize <= 0 {
+		opts.LogSize = DefaultLogSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store:       s,
+		client:      opts.Client,
+		maxAttempts: opts.MaxAttempts,
+		backoff:     opts.Backoff,
+		logSize:     opts.LogSize,
+		log:         make(map[int][]*model.WebhookDelivery),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Notify delivers event, with data and a text summary, to every webhook
+// subscribed to it. Deliveries are made in the background.
+func (d *Dispatcher) Notify(ctx context.Context, event, text string, data interface{}) {
+	if d.ctx.Err() != nil {
+		return
+	}
+	var subscribed []model.Webhook
+	for _, webhook := range d.store.GetWebhooks(ctx) {
+		if slices.Contains(webhook.Events, event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(model.WebhookPayload{Event: event, OccurredAt: time.Now().UTC(), Text: text, Data: data})
+	if err != nil {
+		return
+	}
+	for _, webhook := range subscribed {
+		delivery := d.newDelivery(webhook.ID, event)
+		d.pending.Add(1)
+		go func(webhook model.Webhook) {
+			defer d.pending.Done()
+			d.deliver(webhook, delivery, body)
+		}(webhook)
+	}
+}
+
+// Deliveries returns the recent deliveries to a webhook, newest first.
+func (d *Dispatcher) Deliveries(webhookID int) []model.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	log := d.log[webhookID]
+	deliveries := make([]model.WebhookDelivery, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *log[i])
+	}
+	return deliveries
+}
+
+// Forget drops the delivery log of a deleted webhook. Deliveries still
+// pending are made, but not logged.
+func (d *Dispatcher) Forget(webhookID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.log, webhookID)
+}
+
+// Close stops retrying deliveries, cancels those in flight and waits for
+// them to finish.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.pending.Wait()
+}
+
+// newDelivery logs a pending delivery of event to a webhook.
+func (d *Dispatcher) newDelivery(webhookID int, event string) *model.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nextID++
+	delivery := &model.WebhookDelivery{
+		ID:        d.nextID,
+		WebhookID: webhookID,
+		Event:     event,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	log := append(d.log[webhookID], delivery)
+	if len(log) > d.logSize {
+		log = log[len(log)-d.logSize:]
+	}
+	d.log[webhookID] = log
+	return delivery
+}
+
+// deliver sends body to a webhook until it is accepted, fails for good or
+// runs out of attempts.
+func (d *Dispatcher) deliver(webhook model.Webhook, delivery *model.WebhookDelivery, body []byte) {
+	wait := d.backoff
+	for attempt := 1; ; attempt++ {
+		status, err := d.send(webhook, delivery, body)
+		now := time.Now().UTC()
+
+		d.mu.Lock()
+		delivery.Attempts = attempt
+		delivery.LastAttemptAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.ResponseStatus = status
+		delivery.Error = ""
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		retry := err != nil && retryable(status, err) && attempt < d.maxAttempts && d.ctx.Err() == nil
+		switch {
+		case err == nil:
+			delivery.Status = StatusDelivered
+		case retry:
+			next := now.Add(wait)
+			delivery.NextAttemptAt = &next
+		default:
+			delivery.Status = StatusFailed
+		}
+		d.mu.Unlock()
+		if !retry {
+			return
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-d.ctx.Done():
+			d.mu.Lock()
+			delivery.Status = StatusFailed
+			delivery.NextAttemptAt = nil
+			delivery.Error = "shut down before retrying: " + delivery.Error
+			d.mu.Unlock()
+			return
+		}
+		wait *= 2
+	}
+}
+
+// send makes one attempt to deliver body and returns the response status,
+// if there was a response.
+func (d *Dispatcher) send(webhook model.Webhook, delivery *model.WebhookDelivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-backend-webhook/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Reading the response lets the connection be reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt may succeed if tried again:
+// errors reaching the webhook, server errors and rate limiting are
+// retried, while destinations the fetch policy refuses and other client
+// errors are not.
+func retryable(status int, err error) bool {
+	if errors.Is(err, fetch.ErrForbidden) {
+		return false
+	}
+	switch {
+	case status == 0, status >= 500:
+		return true
+	default:
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+	}
+}
+
+// Sign returns the signature of body sent in SignatureHeader: "sha256="
+// and the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-backend/internal/fetch"
+	"go-backend/internal/model"
+	"go-backend/internal/store"
+)
+
+// loopbackClient returns a fetch client that may reach srv, which the
+// default policy refuses as it listens on loopback.
+func loopbackClient(srv *httptest.Server) *fetch.Client {
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	return fetch.New(fetch.Policy{Ports: []int{port}, AllowNets: []*net.IPNet{loopback}})
+}
+
+// newDispatcher returns a dispatcher that retries quickly, delivering to
+// a webhook for events at srv, and the webhook.
+func newDispatcher(t *testing.T, srv *httptest.Server, events ...string) (*Dispatcher, model.Webhook) {
+	t.Helper()
+	s := store.NewSandbox()
+	hook, _ := s.CreateWebhook(context.Background(), model.Webhook{URL: srv.URL, Events: events, Secret: "0123456789abcdef"})
+	d := New(s, Options{Client: loopbackClient(srv), MaxAttempts: 3, Backoff: time.Millisecond})
+	t.Cleanup(d.Close)
+	return d, hook
+}
+
+// waitFor waits until the last delivery to a webhook is no longer pending
+// and returns it.
+func waitFor(t *testing.T, d *Dispatcher, webhookID int) model.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries := d.Deliveries(webhookID); len(deliveries) > 0 && deliveries[0].Status != StatusPending {
+			return deliveries[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery to webhook %d still pending", webhookID)
+	return model.WebhookDelivery{}
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	t.Parallel()
+
+	type received struct {
+		header  http.Header
+		body    []byte
+		payload model.WebhookPayload
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload model.WebhookPayload
+		json.Unmarshal(body, &payload)
+		got <- received{r.Header, body, payload}
+	}))
+	defer srv.Close()
+
+	d, hook := newDispatcher(t, srv, TaskCompleted)
+	d.Notify(context.Background(), TaskUpdated, "ignored", nil)
+	d.Notify(context.Background(), TaskCompleted, `Task #1 "Ship it" was completed`, model.Task{ID: 1, Title: "Ship it"})
+
+	r := <-got
+	if r.payload.Event != TaskCompleted || r.payload.Text != `Task #1 "Ship it" was completed` {
+		t.Errorf("expected the task.completed payload, got %+v", r.payload)
+	}
+	if r.header.Get(SignatureHeader) != Sign(hook.Secret, r.body) || r.header.Get(EventHeader) != TaskCompleted {
+		t.Errorf("expected the body signed with the secret, got headers %v", r.header)
+	}
+
+	delivery := waitFor(t, d, hook.ID)
+	if delivery.Status != StatusDelivered || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("expected one successful attempt, got %+v", delivery)
+	}
+	if n := len(d.Deliveries(hook.ID)); n != 1 {
+		t.Errorf("expected only the subscribed event to be delivered, got %d deliveries", n)
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	d, hook := newDispatcher(t, srv, UserCreated)
+	d.Notify(context.Background(), UserCreated, "", model.User{ID: 4})
+	if delivery := waitFor(t, d, hook.ID); delivery.Status != StatusDelivered || delivery.Attempts != 3 {
+		t.Errorf("expected delivery on the third attempt, got %+v", delivery)
+	}
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	status := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(<-status)
+	}))
+	defer srv.Close()
+
+	// Client errors are not retried
+	d, hook := newDispatcher(t, srv, UserCreated)
+	status <- http.StatusNotFound
+	d.Notify(context.Background(), UserCreated, "", nil)
+	if delivery := waitFor(t, d, hook.ID); delivery.Status != StatusFailed || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusNotFound {
+		t.Errorf("expected one failed attempt, got %+v", delivery)
+	}
+
+	// Server errors are retried up to MaxAttempts
+	calls.Store(0)
+	go func() {
+		for i := 0; i < 3; i++ {
+			status <- http.StatusInternalServerError
+		}
+	}()
+	d.Notify(context.Background(), UserCreated, "", nil)
+	if delivery := waitFor(t, d, hook.ID); delivery.Status != StatusFailed || delivery.Attempts != 3 || calls.Load() != 3 {
+		t.Errorf("expected three failed attempts, got %+v after %d calls", delivery, calls.Load())
+	}
+}
+
+func TestDispatcher_RefusesLoopbackByDefault(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	s := store.NewSandbox()
+	hook, _ := s.CreateWebhook(context.Background(), model.Webhook{URL: srv.URL, Events: []string{TaskCreated}, Secret: "0123456789abcdef"})
+	d := New(s, Options{Backoff: time.Millisecond})
+	defer d.Close()
+
+	d.Notify(context.Background(), TaskCreated, "", nil)
+	if delivery := waitFor(t, d, hook.ID); delivery.Status != StatusFailed || delivery.Attempts != 1 {
+		t.Errorf("expected the default client to refuse loopback without retrying, got %+v", delivery)
+	}
+}
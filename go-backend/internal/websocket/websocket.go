@@ -0,0 +1,425 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455), enough for the JSON messages of /ws: the opening handshake,
+// text and binary messages, fragmentation, ping, pong and close. There are
+// no extensions or subprotocols.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types, the opcodes of their frames.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// Close codes.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidData     = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseTryAgainLater   = 1013
+)
+
+// DefaultReadLimit caps the size of a message read when SetReadLimit has
+// not been called.
+const DefaultReadLimit = 64 << 10
+
+// writeWait bounds writing a frame, so a client that stops reading cannot
+// block the server.
+const writeWait = 10 * time.Second
+
+// acceptGUID is appended to the client's key to derive Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrClosed is returned when writing to a connection after a close frame
+// was sent.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// CloseError is returned by ReadMessage when the peer closes the
+// connection, or the connection is closed for a protocol violation.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Reason)
+}
+
+// HandshakeError is returned by Upgrade when the request is not a valid
+// WebSocket handshake. Upgrade has already responded with Status.
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// IsUpgrade reports whether r asks to upgrade to a WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return hasToken(r.Header, "Connection", "upgrade") && hasToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the opening handshake and takes over the connection
+// from the HTTP server, clearing the server's deadlines. If r is not a
+// valid handshake, it responds with an error and returns a
+// *HandshakeError.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if err := checkHandshake(r); err != nil {
+		if err.Status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		http.Error(w, err.Message, err.Status)
+		return nil, err
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: hijack: %w", err)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	return &Conn{conn: conn, br: rw.Reader, readLimit: DefaultReadLimit}, nil
+}
+
+// checkHandshake checks that r is a WebSocket opening handshake.
+func checkHandshake(r *http.Request) *HandshakeError {
+	switch {
+	case r.Method != http.MethodGet:
+		return &HandshakeError{http.StatusMethodNotAllowed, "handshake must use GET"}
+	case !IsUpgrade(r):
+		return &HandshakeError{http.StatusBadRequest, "not a WebSocket handshake"}
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		return &HandshakeError{http.StatusUpgradeRequired, "unsupported WebSocket version"}
+	}
+	key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(key) != 16 {
+		return &HandshakeError{http.StatusBadRequest, "invalid Sec-WebSocket-Key"}
+	}
+	return nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for a client's key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// hasToken reports whether the comma-separated header contains token,
+// ignoring case.
+func hasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conn is a server-side WebSocket connection. One goroutine may read while
+// others write; writes are serialized.
+type Conn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	readLimit int64
+	onPong    func()
+
+	writeMu    sync.Mutex
+	closeSent  bool
+	closeOnce  sync.Once
+	closeError error
+}
+
+// SetReadLimit caps the size of the messages read; a larger one closes the
+// connection with CloseMessageTooBig.
+func (c *Conn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+// SetReadDeadline sets the deadline for reading the next frame, which is
+// how a server notices a peer that went away without closing.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// OnPong sets a function called from ReadMessage for each pong received,
+// e.g. to extend the read deadline.
+func (c *Conn) OnPong(fn func()) {
+	c.onPong = fn
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage reads the next text or binary message, answering pings and
+// reporting pongs along the way. When the peer closes the connection, the
+// close is answered and a *CloseError returned. A protocol violation
+// closes the connection with the matching code and returns a *CloseError
+// too.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if f.opcode >= CloseMessage {
+			// Control frames may come between the fragments of a message
+			if err := c.handleControl(f); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+
+		switch {
+		case f.opcode == 0 && messageType == 0:
+			return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+		case f.opcode != 0 && messageType != 0:
+			return 0, nil, c.fail(CloseProtocolError, "expected a continuation frame")
+		case f.opcode == TextMessage, f.opcode == BinaryMessage:
+			messageType = f.opcode
+		case f.opcode != 0:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+		if int64(len(data))+int64(len(f.payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		data = append(data, f.payload...)
+
+		if f.fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				return 0, nil, c.fail(CloseInvalidData, "invalid UTF-8")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// handleControl handles a control frame read by ReadMessage, returning a
+// *CloseError for a close frame.
+func (c *Conn) handleControl(f frame) error {
+	switch f.opcode {
+	case PingMessage:
+		if err := c.WriteControl(PongMessage, f.payload); err != nil && !errors.Is(err, ErrClosed) {
+			return err
+		}
+	case PongMessage:
+		if c.onPong != nil {
+			c.onPong()
+		}
+	case CloseMessage:
+		closeErr := parseClose(f.payload)
+		if closeErr.Code == CloseProtocolError {
+			c.WriteClose(CloseProtocolError, closeErr.Reason)
+		} else {
+			// Echo the code, as the protocol asks
+			c.WriteClose(closeErr.Code, "")
+		}
+		return closeErr
+	default:
+		return c.fail(CloseProtocolError, "unknown opcode")
+	}
+	return nil
+}
+
+// frame is one frame read from the peer, unmasked.
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+// readFrame reads the next frame.
+func (c *Conn) readFrame() (frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: header[0]&0x80 != 0, opcode: int(header[0] & 0x0f)}
+	if header[0]&0x70 != 0 {
+		return f, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return f, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+
+	if f.opcode >= CloseMessage {
+		if !f.fin || length > 125 {
+			return f, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if length > c.readLimit {
+		return f, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// parseClose parses the payload of a close frame.
+func parseClose(payload []byte) *CloseError {
+	switch {
+	case len(payload) == 0:
+		return &CloseError{Code: CloseNoStatus}
+	case len(payload) == 1:
+		return &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+	}
+	closeErr := &CloseError{Code: int(binary.BigEndian.Uint16(payload)), Reason: string(payload[2:])}
+	if !utf8.ValidString(closeErr.Reason) {
+		return &CloseError{Code: CloseProtocolError, Reason: "invalid close reason"}
+	}
+	return closeErr
+}
+
+// fail closes the connection for a violation, returning the error to
+// report.
+func (c *Conn) fail(code int, reason string) error {
+	c.WriteClose(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage writes a text or binary message as a single frame.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// WriteControl writes a ping or pong frame.
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > 125 {
+		return errors.New("websocket: control frame payload too long")
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// WriteClose sends a close frame with code and reason. After it, nothing
+// more can be written; the peer's close frame, if read, ends ReadMessage.
+// Codes that may not be sent, such as CloseNoStatus, send an empty close.
+func (c *Conn) WriteClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatus && code >= 1000 && code < 5000 {
+		if len(reason) > 123 {
+			reason = reason[:123]
+		}
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	return c.writeFrame(CloseMessage, payload)
+}
+
+// writeFrame writes one unmasked, final frame.
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	header := []byte{0x80 | byte(opcode)}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// Close closes the underlying connection without a close frame; send one
+// first with WriteClose for a clean close.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeError = c.conn.Close()
+	})
+	return c.closeError
+}
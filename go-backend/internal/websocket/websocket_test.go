@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-backend/internal/websocket/websockettest"
+)
+
+// echoServer returns a server that upgrades each request and echoes its
+// messages, reporting the error ReadMessage ends with on errs.
+func echoServer(t *testing.T, readLimit int64) (*httptest.Server, <-chan error) {
+	t.Helper()
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if readLimit > 0 {
+			conn.SetReadLimit(readLimit)
+		}
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, errs
+}
+
+func TestConn_Echo(t *testing.T) {
+	t.Parallel()
+
+	srv, errs := echoServer(t, 0)
+	c := websockettest.Dial(t, srv.URL, nil)
+
+	// A ping between fragments is answered before the message
+	c.WriteFrame(false, TextMessage, []byte("hel"))
+	c.WriteFrame(true, PingMessage, []byte("p"))
+	c.WriteFrame(true, 0, []byte("lo"))
+	if opcode, payload := c.ReadFrame(); opcode != PongMessage || string(payload) != "p" {
+		t.Errorf("expected the ping answered, got opcode %d %q", opcode, payload)
+	}
+	if opcode, payload := c.ReadFrame(); opcode != TextMessage || string(payload) != "hello" {
+		t.Errorf("expected the fragments echoed as one message, got opcode %d %q", opcode, payload)
+	}
+
+	c.WriteFrame(true, BinaryMessage, []byte{0xff, 0})
+	if opcode, payload := c.ReadFrame(); opcode != BinaryMessage || len(payload) != 2 {
+		t.Errorf("expected the binary message echoed, got opcode %d %v", opcode, payload)
+	}
+
+	c.WriteClose(CloseNormal)
+	if code := c.ReadClose(); code != CloseNormal {
+		t.Errorf("expected the close echoed, got %d", code)
+	}
+	var closeErr *CloseError
+	if err := <-errs; !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("expected ReadMessage to report the close, got %v", err)
+	}
+}
+
+func TestConn_ProtocolErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		frames   func(c *websockettest.Conn)
+		wantCode int
+	}{
+		{"message too big", func(c *websockettest.Conn) {
+			c.WriteFrame(true, TextMessage, []byte("0123456789"))
+		}, CloseMessageTooBig},
+		{"fragments too big", func(c *websockettest.Conn) {
+			c.WriteFrame(false, TextMessage, []byte("01234"))
+			c.WriteFrame(true, 0, []byte("56789"))
+		}, CloseMessageTooBig},
+		{"invalid UTF-8", func(c *websockettest.Conn) {
+			c.WriteFrame(true, TextMessage, []byte{0xff, 0xfe})
+		}, CloseInvalidData},
+		{"continuation without a message", func(c *websockettest.Conn) {
+			c.WriteFrame(true, 0, []byte("x"))
+		}, CloseProtocolError},
+		{"new message before the last ended", func(c *websockettest.Conn) {
+			c.WriteFrame(false, TextMessage, []byte("a"))
+			c.WriteFrame(true, TextMessage, []byte("b"))
+		}, CloseProtocolError},
+		{"fragmented control frame", func(c *websockettest.Conn) {
+			c.WriteFrame(false, PingMessage, nil)
+		}, CloseProtocolError},
+		{"unknown opcode", func(c *websockettest.Conn) {
+			c.WriteFrame(true, 3, nil)
+		}, CloseProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, errs := echoServer(t, 8)
+			c := websockettest.Dial(t, srv.URL, nil)
+			tt.frames(c)
+
+			if code := c.ReadClose(); code != tt.wantCode {
+				t.Errorf("expected close %d, got %d", tt.wantCode, code)
+			}
+			var closeErr *CloseError
+			if err := <-errs; !errors.As(err, &closeErr) || closeErr.Code != tt.wantCode {
+				t.Errorf("expected ReadMessage to fail with %d, got %v", tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestUpgrade_RejectsInvalidHandshakes(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := echoServer(t, 0)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a plain GET, got %d", resp.StatusCode)
+	}
+
+	conn, resp, err := websockettest.Handshake(srv.URL, http.Header{"Sec-Websocket-Version": {"8"}})
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	conn.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("expected 426 naming version 13, got %d %q", resp.StatusCode, resp.Header.Get("Sec-WebSocket-Version"))
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	t.Parallel()
+
+	// The example from RFC 6455
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("expected the RFC's accept key, got %q", got)
+	}
+}
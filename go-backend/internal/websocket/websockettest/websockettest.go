@@ -0,0 +1,256 @@
+// Package websockettest provides a minimal WebSocket client for testing
+// servers built on package websocket. Frames are sent masked, as clients
+// must, and read whole; opcodes are those of package websocket.
+package websockettest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Opcodes used by the client itself.
+const (
+	opText  = 1
+	opClose = 8
+	opPing  = 9
+	opPong  = 10
+)
+
+// readTimeout bounds each read, so a missing message fails the test
+// rather than hanging it.
+const readTimeout = 5 * time.Second
+
+// Conn is the client end of a WebSocket.
+type Conn struct {
+	t    testing.TB
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// Dial opens a WebSocket to rawURL, an http:// URL such as an
+// httptest.Server's, sending header with the handshake. It fails t unless
+// the server switches protocols, and closes the connection when the test
+// ends.
+func Dial(t testing.TB, rawURL string, header http.Header) *Conn {
+	t.Helper()
+
+	conn, resp, err := Handshake(rawURL, header)
+	if err != nil {
+		t.Fatalf("WebSocket handshake failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		t.Fatalf("expected 101 Switching Protocols, got %d", resp.StatusCode)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &Conn{t: t, conn: conn, br: bufio.NewReader(conn)}
+}
+
+// Handshake sends a WebSocket handshake to rawURL and returns the
+// connection and the server's response, whatever its status. The caller
+// closes the connection.
+func Handshake(rawURL string, header http.Header) (net.Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	if req.Header.Get("Sec-WebSocket-Version") == "" {
+		req.Header.Set("Sec-WebSocket-Version", "13")
+	}
+	conn.SetDeadline(time.Now().Add(readTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	// The response is read byte by byte, so no frames after it are buffered
+	resp, err := http.ReadResponse(bufio.NewReaderSize(oneByteReader{conn}, 16), req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		sum := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+			conn.Close()
+			return nil, nil, errBadAccept
+		}
+	}
+	return conn, resp, nil
+}
+
+var errBadAccept = errors.New("websockettest: wrong Sec-WebSocket-Accept")
+
+// oneByteReader reads at most one byte at a time.
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return o.r.Read(p)
+}
+
+// WriteFrame writes a masked frame.
+func (c *Conn) WriteFrame(fin bool, opcode int, payload []byte) {
+	c.t.Helper()
+
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+	header := []byte{first}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xffff:
+		header = binary.BigEndian.AppendUint16(append(header, 0x80|126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 0x80|127), uint64(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+
+	if _, err := c.conn.Write(append(append(header, mask...), masked...)); err != nil {
+		c.t.Fatalf("writing frame: %v", err)
+	}
+}
+
+// WriteJSON sends v as a JSON text message.
+func (c *Conn) WriteJSON(v interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatalf("encoding message: %v", err)
+	}
+	c.WriteFrame(true, opText, data)
+}
+
+// WriteClose sends a close frame with code.
+func (c *Conn) WriteClose(code int) {
+	c.t.Helper()
+	c.WriteFrame(true, opClose, binary.BigEndian.AppendUint16(nil, uint16(code)))
+}
+
+// ReadFrame reads the next frame, failing the test if none arrives in time.
+func (c *Conn) ReadFrame() (opcode int, payload []byte) {
+	c.t.Helper()
+	opcode, payload, err := c.readFrame()
+	if err != nil {
+		c.t.Fatalf("reading frame: %v", err)
+	}
+	return opcode, payload
+}
+
+func (c *Conn) readFrame() (int, []byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(readTimeout))
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return 0, nil, err
+	}
+	return int(header[0] & 0x0f), payload, nil
+}
+
+// ReadJSON reads the next text message into v, answering pings on the way.
+// It fails the test on any other frame.
+func (c *Conn) ReadJSON(v interface{}) {
+	c.t.Helper()
+	for {
+		opcode, payload := c.ReadFrame()
+		switch opcode {
+		case opPing:
+			c.WriteFrame(true, opPong, payload)
+			continue
+		case opText:
+			if err := json.Unmarshal(payload, v); err != nil {
+				c.t.Fatalf("decoding message %s: %v", payload, err)
+			}
+			return
+		case opClose:
+			c.t.Fatalf("expected a message, got a close: %s", closeText(payload))
+		default:
+			c.t.Fatalf("expected a text message, got opcode %d", opcode)
+		}
+	}
+}
+
+// ReadClose reads frames until a close and returns its code, or 0 if the
+// connection ends without one.
+func (c *Conn) ReadClose() int {
+	c.t.Helper()
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0
+		}
+		if opcode == opClose {
+			if len(payload) < 2 {
+				return 1005
+			}
+			return int(binary.BigEndian.Uint16(payload))
+		}
+	}
+}
+
+// Close closes the connection without a close frame.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// closeText describes a close frame's payload.
+func closeText(payload []byte) string {
+	if len(payload) < 2 {
+		return "no status"
+	}
+	return fmt.Sprintf("%d %s", binary.BigEndian.Uint16(payload), payload[2:])
+}